@@ -22,6 +22,14 @@ func main() {
 	if err != nil {
 		log.Fatal("Unable to create avatar storage dir: " + err.Error())
 	}
+	err = os.MkdirAll(config.ExportStoragePath, 0755)
+	if err != nil {
+		log.Fatal("Unable to create export storage dir: " + err.Error())
+	}
+	err = os.MkdirAll(config.ImportStoragePath, 0755)
+	if err != nil {
+		log.Fatal("Unable to create import storage dir: " + err.Error())
+	}
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
@@ -4,26 +4,27 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
-	"net/mail"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
-	"strings"
 
 	"github.com/emersion/go-smtp"
+	"liokor_mail/internal/app/server"
 	"liokor_mail/internal/pkg/common"
 	"liokor_mail/internal/utils"
 	"liokor_mail/internal/pkg/mail/repository"
+	"liokor_mail/internal/pkg/mail/usecase"
 	liokorMail "liokor_mail/internal/pkg/mail"
-
-	"github.com/microcosm-cc/bluemonday"
 )
 
 const CONFIG_PATH = "config.json"
+const SRS_MAX_AGE = 21 * 24 * time.Hour
 
 var dbConn repository.GormPostgresMailRepository
+var mailUC usecase.MailUseCase
 
 type Backend struct{
 	Config common.Config
@@ -39,8 +40,8 @@ func (bkd *Backend) AnonymousLogin(state *smtp.ConnectionState) (smtp.Session, e
 type Session struct {
 	From       string
 	Recipients []string
-	Header     mail.Header
-	Body       string
+	Message    utils.InboundMessage
+	Raw        []byte
 	Config     common.Config
 }
 
@@ -50,69 +51,151 @@ func (s *Session) Mail(from string, opts smtp.MailOptions) error {
 }
 
 func (s *Session) Rcpt(recipient string) error {
+	if !utils.IsSRSAddress(recipient) && s.isLocal(recipient) {
+		err := mailUC.CheckIncomingSender(s.From, recipient)
+		switch err := err.(type) {
+		case nil:
+		case liokorMail.SenderBlockedError:
+			if !err.Drop {
+				log.Printf("INFO: Mail from %s to %s rejected: %v\n", s.From, recipient, err)
+				return &smtp.SMTPError{
+					Code:         550,
+					EnhancedCode: smtp.EnhancedCode{5, 7, 1},
+					Message:      "Sender is blocked",
+				}
+			}
+		case common.InvalidUserError:
+			// unknown recipient is rejected now, so the sending server reports it instead of our DSN
+			return &smtp.SMTPError{
+				Code:         550,
+				EnhancedCode: smtp.EnhancedCode{5, 1, 1},
+				Message:      "Recipient doesn't exist",
+			}
+		default:
+			log.Printf("ERROR: Unable to check sender %s: %v\n", s.From, err)
+		}
+	}
 	s.Recipients = append(s.Recipients, recipient)
 	return nil
 }
 
 func (s *Session) Data(r io.Reader) error {
-	message, err := mail.ReadMessage(r)
+	raw, err := ioutil.ReadAll(r)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
 
-	body, err := utils.ParseBodyText(message)
+	message, err := utils.ParseInboundMessage(raw)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
 
-	s.Header = message.Header
-	s.Body = body
+	s.Message = message
+	s.Raw = raw
 
 	return s.HandleMail()
 }
 
+// relayBounce sends bounce of the forwarded mail back to the original sender
+func (s *Session) relayBounce(srsRecipient string) {
+	original, err := utils.SRSReverse(srsRecipient, s.Config.SrsSecret, SRS_MAX_AGE)
+	if err != nil {
+		log.Printf("WARN: Bounce to %s was not relayed: %v\n", srsRecipient, err)
+		return
+	}
+	// bounce is relayed as is, it is already signed by the reporting server
+	err = utils.SMTPRelayMail("", original, s.Raw, nil)
+	if err != nil {
+		log.Printf("WARN: Unable to relay bounce to %s: %v\n", original, err)
+	}
+}
+
+// isLocal reports whether recipient belongs to one of the served domains
+func (s *Session) isLocal(recipient string) bool {
+	isLocal, err := mailUC.IsLocalAddress(recipient)
+	if err != nil {
+		log.Printf("ERROR: Unable to check domain of %s: %v\n", recipient, err)
+		return false
+	}
+	return isLocal
+}
+
 func (s *Session) HandleMail() error {
-	if len(s.From) == 0 || len(s.Recipients) == 0 || len(s.Body) == 0 {
+	recipients := make([]string, 0, len(s.Recipients))
+	for _, recipient := range s.Recipients {
+		if utils.IsSRSAddress(recipient) && s.isLocal(recipient) {
+			s.relayBounce(recipient)
+		} else {
+			recipients = append(recipients, recipient)
+		}
+	}
+	if len(s.Recipients) > 0 && len(recipients) == 0 {
+		return nil
+	}
+	s.Recipients = recipients
+
+	if len(s.From) == 0 || len(s.Recipients) == 0 || len(s.Message.Body) == 0 {
 		log.Println("Invalid mail received!")
 		return errors.New("Invalid mail received!")
 	}
 
 	log.Printf("Received mail from %s to %v\n", s.From, s.Recipients)
 
-	subject := utils.ParseSubject(s.Header.Get("Subject"))
-	body := s.Body
-
-	pStrict := bluemonday.StrictPolicy()
-	subject = pStrict.Sanitize(subject)
-
-	pUGC := bluemonday.UGCPolicy()
-	body = pUGC.Sanitize(body)
+	// recipients the accepted mail wasn't stored for are reported to the sender
+	failures := make(map[string]error)
+	stored := 0
 
 	for _, recipient := range s.Recipients {
-		if strings.HasSuffix(recipient, "@" + s.Config.MailDomain) {
+		if s.isLocal(recipient) {
 			newMail := liokorMail.Mail{
 				Sender : s.From,
 				Recipient: recipient,
-				Subject: subject,
-				Body: body,
+				Subject: s.Message.Subject,
+				Body: s.Message.Body,
+				Headers: s.Message.Headers,
+				Raw: s.Raw,
+				MessageId: s.Message.MessageId,
+				References: s.Message.References,
+				RequestReceipt: s.Message.NotifyAddress != "",
+				NotifyAddress: s.Message.NotifyAddress,
+				HasAttachment: s.Message.HasAttachment,
 			}
-			_, err := dbConn.AddMail(newMail, s.Config.MailDomain)
+			err := mailUC.ReceiveEmail(newMail)
 			if err != nil {
 				log.Println(err)
+				failures[recipient] = err
+			} else {
+				stored++
 			}
 		} else {
 			log.Printf("WARN: Mail to %s was not saved!", recipient)
 		}
 	}
+	if len(failures) > 0 && stored == 0 {
+		// nothing is accepted, so the sending server keeps the mail and retries or reports it itself
+		return &smtp.SMTPError{
+			Code:         451,
+			EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+			Message:      "Mail couldn't be stored, try again later",
+		}
+	}
+	if len(failures) > 0 {
+		bounced := liokorMail.Mail{Sender: s.From, Headers: s.Message.Headers, Raw: s.Raw}
+		err := mailUC.BounceEmail(bounced, failures)
+		if err != nil {
+			log.Printf("WARN: Unable to bounce mail from %s: %v\n", s.From, err)
+		}
+	}
 	return nil
 }
 
 func (s *Session) Reset() {
 	s.From = ""
 	s.Recipients = nil
-	s.Body = ""
+	s.Message = utils.InboundMessage{}
+	s.Raw = nil
 }
 
 func (s *Session) Logout() error {
@@ -133,6 +216,21 @@ func main() {
 	defer db.Close()
 	dbConn.DBInstance = db
 
+	privateKey, err := server.GetPrivateKey(config.DkimPrivateKeyPath)
+	if err != nil {
+		log.Printf("WARN: Unable to load private key: %v", err)
+		privateKey = nil
+	}
+	mailUC = usecase.MailUseCase{
+		Repository: &dbConn,
+		Config:     config,
+		PrivateKey: privateKey,
+	}
+	err = mailUC.AddDefaultDomain()
+	if err != nil {
+		log.Fatalf("Unable to add default domain: %v\n", err)
+	}
+
 	b := &Backend{ Config: config }
 	s := smtp.NewServer(b)
 
@@ -147,7 +245,7 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		log.Printf("Starting SMTP server at %s for @%s", s.Addr, config.MailDomain)
+		log.Printf("Starting SMTP server at %s as %s", s.Addr, config.MailDomain)
 		err := s.ListenAndServe()
 		if err != nil {
 			log.Fatal("Error occured while trying to start server: " + err.Error())
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"liokor_mail/internal/utils"
+	"log"
+)
+
+// prints new VAPID key pair to be put into config for Web Push
+func main() {
+	public, private, err := utils.GenerateVapidKeys()
+	if err != nil {
+		log.Fatal("Unable to generate VAPID keys: " + err.Error())
+	}
+	fmt.Printf("\"vapidPublicKey\": \"%s\",\n\"vapidPrivateKey\": \"%s\",\n", public, private)
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 func SetupCSRFAndCORS(e *echo.Echo, allowedOrigin string, debug bool) {
@@ -29,7 +30,9 @@ func SetupCSRFAndCORS(e *echo.Echo, allowedOrigin string, debug bool) {
 
 		e.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
 			Skipper: func(c echo.Context) bool {
-				return debug
+				// CardDAV and JMAP clients authenticate with basic auth on every request, not with cookies
+				path := c.Request().URL.Path
+				return debug || strings.HasPrefix(path, "/carddav") || strings.HasPrefix(path, "/jmap")
 			},
 			CookieSameSite: http.SameSiteStrictMode,
 			CookieDomain:   csrfCookieDomain,
@@ -3,13 +3,22 @@ package middlewareHelpers
 import (
 	"context"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 	"liokor_mail/internal/pkg/common"
 	session "liokor_mail/internal/pkg/common/protobuf_sessions"
 	"liokor_mail/internal/pkg/user"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	BASIC_AUTH_MAX_FAILURES = 10
+	BASIC_AUTH_FAILURE_SPAN = 15 * time.Minute
+	basicAuthMaxTrackedKeys = 10000
+)
+
 type AuthMiddleware struct {
 	UserUsecase    user.UseCase
 	SessionManager session.IsAuthClient
@@ -69,3 +78,91 @@ func (m *AuthMiddleware) deleteSessionCookie(c *echo.Context) {
 		HttpOnly: true,
 	})
 }
+
+// failureLimiter counts failed attempts by key within BASIC_AUTH_FAILURE_SPAN from the first one
+type failureLimiter struct {
+	mutex    sync.Mutex
+	failures map[string]*failureCount
+}
+
+type failureCount struct {
+	count int
+	since time.Time
+}
+
+func newFailureLimiter() *failureLimiter {
+	return &failureLimiter{failures: make(map[string]*failureCount)}
+}
+
+func (l *failureLimiter) blocked(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	failure, ok := l.failures[key]
+	if !ok {
+		return false
+	}
+	if time.Since(failure.since) > BASIC_AUTH_FAILURE_SPAN {
+		delete(l.failures, key)
+		return false
+	}
+	return failure.count >= BASIC_AUTH_MAX_FAILURES
+}
+
+func (l *failureLimiter) fail(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.failures) >= basicAuthMaxTrackedKeys {
+		for k, failure := range l.failures {
+			if time.Since(failure.since) > BASIC_AUTH_FAILURE_SPAN {
+				delete(l.failures, k)
+			}
+		}
+	}
+	failure, ok := l.failures[key]
+	if !ok || time.Since(failure.since) > BASIC_AUTH_FAILURE_SPAN {
+		l.failures[key] = &failureCount{count: 1, since: time.Now()}
+		return
+	}
+	failure.count++
+}
+
+func (l *failureLimiter) reset(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.failures, key)
+}
+
+// basicAuthLimiter limits password guessing, basic auth is checked on every request so it has no session to lock
+var basicAuthLimiter = newFailureLimiter()
+
+// IsAuthBasic authenticates clients that can't keep session cookie, e.g. CardDAV, by username and password
+func (m *AuthMiddleware) IsAuthBasic(next echo.HandlerFunc) echo.HandlerFunc {
+	return middleware.BasicAuthWithConfig(middleware.BasicAuthConfig{
+		Realm: "Liokor mail",
+		Validator: func(username string, password string, c echo.Context) (bool, error) {
+			// clients often ask for the whole address as username
+			username = strings.SplitN(username, "@", 2)[0]
+			userKey := "user:" + username
+			ipKey := "ip:" + c.RealIP()
+			if basicAuthLimiter.blocked(userKey) || basicAuthLimiter.blocked(ipKey) {
+				return false, echo.NewHTTPError(http.StatusTooManyRequests, "too many failed attempts, try again later")
+			}
+			err := m.UserUsecase.Login(user.Credentials{Username: username, Password: password})
+			if err != nil {
+				if _, ok := err.(common.InvalidUserError); ok {
+					basicAuthLimiter.fail(userKey)
+					basicAuthLimiter.fail(ipKey)
+					return false, nil
+				}
+				return false, err
+			}
+			basicAuthLimiter.reset(userKey)
+			sessionUser, err := m.UserUsecase.GetUserByUsername(username)
+			if err != nil {
+				return false, err
+			}
+			c.Set("sessionUser", sessionUser)
+			return true, nil
+		},
+	})(next)
+}
@@ -92,3 +92,84 @@ func TestIsAuthMiddleware(t *testing.T) {
 	}
 }
 
+
+func TestIsAuthBasicMiddleware(t *testing.T) {
+	basicAuthLimiter = newFailureLimiter()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockUC := mocks.NewMockUseCase(mockCtrl)
+	mockSession := sMocks.NewMockIsAuthClient(mockCtrl)
+	authMdlwr := AuthMiddleware{
+		mockUC,
+		mockSession,
+	}
+
+	retUser := user.User{
+		Id:       1,
+		Username: "test",
+	}
+	next := func(c echo.Context) error {
+		if c.Get("sessionUser").(user.User).Username != "test" {
+			t.Errorf("Session user is not set\n")
+		}
+		return c.NoContent(http.StatusOK)
+	}
+
+	mockUC.EXPECT().Login(user.Credentials{Username: "test", Password: "password"}).Return(nil).Times(1)
+	mockUC.EXPECT().GetUserByUsername("test").Return(retUser, nil).Times(1)
+	e := echo.New()
+	req := httptest.NewRequest("PROPFIND", "/carddav/", nil)
+	req.SetBasicAuth("test@liokor.ru", "password")
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	err := authMdlwr.IsAuthBasic(next)(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data:%v\n", err)
+	}
+
+	mockUC.EXPECT().Login(user.Credentials{Username: "test", Password: "wrong"}).Return(common.InvalidUserError{"Invalid credentials"}).Times(1)
+	req = httptest.NewRequest("PROPFIND", "/carddav/", nil)
+	req.SetBasicAuth("test", "wrong")
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	err = authMdlwr.IsAuthBasic(next)(echoContext)
+	if httperr, ok := err.(*echo.HTTPError); !ok || httperr.Code != http.StatusUnauthorized {
+		t.Errorf("Didn't pass invalid credentails: %v\n", err)
+	}
+}
+
+func TestIsAuthBasicLimit(t *testing.T) {
+	basicAuthLimiter = newFailureLimiter()
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockUC := mocks.NewMockUseCase(mockCtrl)
+	mockSession := sMocks.NewMockIsAuthClient(mockCtrl)
+	authMdlwr := AuthMiddleware{
+		mockUC,
+		mockSession,
+	}
+	next := func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}
+
+	mockUC.EXPECT().Login(user.Credentials{Username: "test", Password: "wrong"}).Return(common.InvalidUserError{"Invalid credentials"}).Times(BASIC_AUTH_MAX_FAILURES)
+	e := echo.New()
+	for i := 0; i < BASIC_AUTH_MAX_FAILURES; i++ {
+		req := httptest.NewRequest("PROPFIND", "/carddav/", nil)
+		req.SetBasicAuth("test", "wrong")
+		err := authMdlwr.IsAuthBasic(next)(e.NewContext(req, httptest.NewRecorder()))
+		if httperr, ok := err.(*echo.HTTPError); !ok || httperr.Code != http.StatusUnauthorized {
+			t.Errorf("Didn't pass invalid credentails: %v\n", err)
+		}
+	}
+
+	// even the right password isn't checked until the failures expire
+	req := httptest.NewRequest("PROPFIND", "/carddav/", nil)
+	req.SetBasicAuth("test", "password")
+	err := authMdlwr.IsAuthBasic(next)(e.NewContext(req, httptest.NewRecorder()))
+	if httperr, ok := err.(*echo.HTTPError); !ok || httperr.Code != http.StatusTooManyRequests {
+		t.Errorf("Didn't limit failed attempts: %v\n", err)
+	}
+}
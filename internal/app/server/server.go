@@ -8,41 +8,39 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
 	"liokor_mail/internal/pkg/common"
+	contactsDelivery "liokor_mail/internal/pkg/contacts/delivery"
+	contactsRepository "liokor_mail/internal/pkg/contacts/repository"
+	contactsUsecase "liokor_mail/internal/pkg/contacts/usecase"
+	"liokor_mail/internal/pkg/mail"
 	mailDelivery "liokor_mail/internal/pkg/mail/delivery"
 	mailRepository "liokor_mail/internal/pkg/mail/repository"
 	mailUsecase "liokor_mail/internal/pkg/mail/usecase"
 	userDelivery "liokor_mail/internal/pkg/user/delivery"
 	userRepository "liokor_mail/internal/pkg/user/repository"
 	userUsecase "liokor_mail/internal/pkg/user/usecase"
+	"liokor_mail/internal/utils"
 	"log"
 	"os"
 	"time"
 
 	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
 	"io/ioutil"
 
 	"liokor_mail/internal/app/server/middlewareHelpers"
 	session "liokor_mail/internal/pkg/common/protobuf_sessions"
 )
 
+const SNOOZE_CHECK_INTERVAL = time.Minute
+const EXPORT_CLEANUP_INTERVAL = time.Hour
+const EVENTS_RECONNECT_INTERVAL = 5 * time.Second
+const WEBHOOK_RETRY_INTERVAL = 15 * time.Second
+
 func GetPrivateKey(path string) (*rsa.PrivateKey, error) {
 	keyString, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	block, _ := pem.Decode([]byte(keyString))
-	if block == nil {
-		return nil, err
-	}
-
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-	if err != nil {
-		return nil, err
-	}
-
-	return privateKey, nil
+	return utils.ParsePrivateKey(keyString)
 }
 
 func StartServer(config common.Config, quit chan os.Signal) {
@@ -82,6 +80,28 @@ func StartServer(config common.Config, quit chan os.Signal) {
 	mailRep := &mailRepository.GormPostgresMailRepository{dbInstance}
 	mailUC := &mailUsecase.MailUseCase{mailRep, config, privateKey}
 	mailHander := mailDelivery.MailHandler{mailUC}
+	err = mailUC.AddDefaultDomain()
+	if err != nil {
+		log.Fatalf("Unable to add default domain: %v\n", err)
+	}
+	err = mailUC.InterruptImports()
+	if err != nil {
+		log.Printf("ERROR: Unable to interrupt unfinished imports: %v\n", err)
+	}
+	err = mailUC.InterruptExports()
+	if err != nil {
+		log.Printf("ERROR: Unable to interrupt unfinished exports: %v\n", err)
+	}
+	eventHub := mailDelivery.NewEventHub()
+	eventsOrigin := config.AllowedOrigin
+	if config.Debug {
+		eventsOrigin = ""
+	}
+	eventsHandler := mailDelivery.EventsHandler{mailHander, eventHub, eventsOrigin}
+
+	contactsRep := &contactsRepository.GormPostgresContactsRepository{dbInstance}
+	contactsUC := &contactsUsecase.ContactsUseCase{contactsRep}
+	contactsHandler := contactsDelivery.ContactsHandler{contactsUC}
 
 	e := echo.New()
 
@@ -123,14 +143,177 @@ func StartServer(config common.Config, quit chan os.Signal) {
 	e.GET("/email/dialogues", mailHander.GetDialogues, isAuth.IsAuth)
 	e.POST("/email/dialogue", mailHander.CreateDialogue, isAuth.IsAuth)
 	e.DELETE("/email/dialogue", mailHander.DeleteDialogue, isAuth.IsAuth)
+	e.PUT("/email/dialogue/snooze", mailHander.SnoozeDialogue, isAuth.IsAuth)
+	e.DELETE("/email/dialogue/snooze", mailHander.UnsnoozeDialogue, isAuth.IsAuth)
+	e.PUT("/email/dialogue/pin", mailHander.PinDialogue, isAuth.IsAuth)
+	e.PUT("/email/dialogue/mute", mailHander.MuteDialogue, isAuth.IsAuth)
 	e.GET("/email/emails", mailHander.GetEmails, isAuth.IsAuth)
+	e.GET("/sync", mailHander.Sync, isAuth.IsAuth)
+	e.GET("/email/events", eventsHandler.Events, isAuth.IsAuth)
 	e.POST("/email", mailHander.SendEmail, isAuth.IsAuth)
+	e.POST("/email/preview", mailHander.PreviewEmail, isAuth.IsAuth)
+	e.POST("/email/:id/reply", mailHander.ReplyEmail, isAuth.IsAuth)
+	e.POST("/email/:id/reply-all", mailHander.ReplyAllEmail, isAuth.IsAuth)
+	e.POST("/email/:id/forward", mailHander.ForwardEmail, isAuth.IsAuth)
 	e.DELETE("/email/emails", mailHander.DeleteMail, isAuth.IsAuth)
+	e.POST("/email/bulk", mailHander.Bulk, isAuth.IsAuth)
 
 	e.GET("/email/folders", mailHander.GetFolders, isAuth.IsAuth)
 	e.POST("/email/folder", mailHander.CreateFolder, isAuth.IsAuth)
 	e.PUT("/email/folder", mailHander.UpdateFolder, isAuth.IsAuth)
 	e.DELETE("/email/folder", mailHander.DeleteFolder, isAuth.IsAuth)
+	e.POST("/email/smartfolder", mailHander.CreateSmartFolder, isAuth.IsAuth)
+	e.PUT("/email/smartfolder", mailHander.UpdateSmartFolder, isAuth.IsAuth)
+	e.DELETE("/email/smartfolder", mailHander.DeleteSmartFolder, isAuth.IsAuth)
+
+	e.GET("/email/vacation", mailHander.GetVacation, isAuth.IsAuth)
+	e.PUT("/email/vacation", mailHander.UpdateVacation, isAuth.IsAuth)
+	e.GET("/email/forwarding", mailHander.GetForwarding, isAuth.IsAuth)
+	e.PUT("/email/forwarding", mailHander.UpdateForwarding, isAuth.IsAuth)
+	e.GET("/email/forwarding/confirm", mailHander.ConfirmForwarding)
+
+	e.GET("/email/receipts", mailHander.GetReadReceipts, isAuth.IsAuth)
+	e.PUT("/email/receipts", mailHander.UpdateReadReceipts, isAuth.IsAuth)
+	e.GET("/email/aliases", mailHander.GetAliases, isAuth.IsAuth)
+	e.POST("/email/alias", mailHander.CreateAlias, isAuth.IsAuth)
+	e.DELETE("/email/alias", mailHander.DeleteAlias, isAuth.IsAuth)
+
+	e.GET("/email/domains", mailHander.GetDomains)
+
+	e.GET("/email/mailboxes", mailHander.GetMailboxes, isAuth.IsAuth)
+	e.POST("/email/mailbox", mailHander.CreateMailbox, isAuth.IsAuth)
+	e.GET("/email/mailbox/members", mailHander.GetMailboxMembers, isAuth.IsAuth)
+	e.POST("/email/mailbox/member", mailHander.AddMailboxMember, isAuth.IsAuth)
+	e.DELETE("/email/mailbox/member", mailHander.DeleteMailboxMember, isAuth.IsAuth)
+
+	e.GET("/email/lists", mailHander.GetDistributionLists, isAuth.IsAuth)
+	e.POST("/email/list", mailHander.CreateDistributionList, isAuth.IsAuth)
+	e.PUT("/email/list", mailHander.UpdateListPosting, isAuth.IsAuth)
+	e.DELETE("/email/list", mailHander.DeleteDistributionList, isAuth.IsAuth)
+	e.POST("/email/list/member", mailHander.AddListMember, isAuth.IsAuth)
+	e.DELETE("/email/list/member", mailHander.DeleteListMember, isAuth.IsAuth)
+
+	e.GET("/email/senders", mailHander.GetSenderFilter, isAuth.IsAuth)
+	e.PUT("/email/senders", mailHander.UpdateSenderFilter, isAuth.IsAuth)
+	e.POST("/email/sender", mailHander.AddSenderRule, isAuth.IsAuth)
+	e.DELETE("/email/sender", mailHander.DeleteSenderRule, isAuth.IsAuth)
+	e.GET("/email/signatures", mailHander.GetSignatures, isAuth.IsAuth)
+	e.POST("/email/signature", mailHander.CreateSignature, isAuth.IsAuth)
+	e.PUT("/email/signature", mailHander.UpdateSignature, isAuth.IsAuth)
+	e.DELETE("/email/signature", mailHander.DeleteSignature, isAuth.IsAuth)
+	e.GET("/email/templates", mailHander.GetTemplates, isAuth.IsAuth)
+	e.POST("/email/template", mailHander.CreateTemplate, isAuth.IsAuth)
+	e.PUT("/email/template", mailHander.UpdateTemplate, isAuth.IsAuth)
+	e.DELETE("/email/template", mailHander.DeleteTemplate, isAuth.IsAuth)
+	e.POST("/email/template/render", mailHander.RenderTemplate, isAuth.IsAuth)
+	e.GET("/email/webhooks", mailHander.GetWebhooks, isAuth.IsAuth)
+	e.POST("/email/webhook", mailHander.CreateWebhook, isAuth.IsAuth)
+	e.DELETE("/email/webhook", mailHander.DeleteWebhook, isAuth.IsAuth)
+	e.GET("/email/webhook/deliveries", mailHander.GetWebhookDeliveries, isAuth.IsAuth)
+	e.POST("/email/webhook/test", mailHander.TestWebhook, isAuth.IsAuth)
+	e.GET("/email/exports", mailHander.GetExports, isAuth.IsAuth)
+	e.GET("/email/export", mailHander.GetExport, isAuth.IsAuth)
+	e.POST("/email/export", mailHander.CreateExport, isAuth.IsAuth)
+	e.GET(mail.ExportDownloadPath+":token", mailHander.DownloadExport)
+	e.GET("/email/imports", mailHander.GetImports, isAuth.IsAuth)
+	e.GET("/email/import", mailHander.GetImport, isAuth.IsAuth)
+	e.POST("/email/import", mailHander.ImportArchive, isAuth.IsAuth)
+	e.POST("/email/import/imap", mailHander.ImportIMAP, isAuth.IsAuth)
+	e.POST("/email/import/resume", mailHander.ResumeImport, isAuth.IsAuth)
+	e.GET("/email/push/key", mailHander.GetVapidPublicKey)
+	e.POST("/email/push/subscription", mailHander.SubscribePush, isAuth.IsAuth)
+	e.DELETE("/email/push/subscription", mailHander.UnsubscribePush, isAuth.IsAuth)
+
+	e.GET("/admin/blocklist", mailHander.GetGlobalBlocklist, isAuth.IsAuth)
+	e.POST("/admin/blocklist", mailHander.AddGlobalBlock, isAuth.IsAuth)
+	e.DELETE("/admin/blocklist", mailHander.DeleteGlobalBlock, isAuth.IsAuth)
+
+	e.GET("/contacts", contactsHandler.GetContacts, isAuth.IsAuth)
+	e.GET("/contacts/groups", contactsHandler.GetGroups, isAuth.IsAuth)
+	e.GET("/contacts/autocomplete", contactsHandler.Autocomplete, isAuth.IsAuth)
+	e.GET("/contact", contactsHandler.GetContact, isAuth.IsAuth)
+	e.POST("/contact", contactsHandler.CreateContact, isAuth.IsAuth)
+	e.PUT("/contact", contactsHandler.UpdateContact, isAuth.IsAuth)
+	e.DELETE("/contact", contactsHandler.DeleteContact, isAuth.IsAuth)
+
+	e.GET("/.well-known/jmap", mailHander.WellKnownJMAP)
+	e.GET(mailDelivery.JMAPSessionPath, mailHander.JMAPSession, isAuth.IsAuthBasic)
+	e.POST(mailDelivery.JMAPApiPath, mailHander.JMAP, isAuth.IsAuthBasic)
+	e.GET(mailDelivery.JMAPDownloadPath+":account/:blob/:name", mailHander.JMAPDownload, isAuth.IsAuthBasic)
+	e.GET(mailDelivery.JMAPEventSourcePath, eventsHandler.JMAPEventSource, isAuth.IsAuthBasic)
+
+	cardDAVMethods := []string{
+		"OPTIONS", echo.PROPFIND, echo.REPORT, "GET", "PUT", "DELETE",
+	}
+	e.GET("/.well-known/carddav", contactsHandler.WellKnownCardDAV)
+	e.Match(cardDAVMethods, "/carddav", contactsHandler.CardDAV, isAuth.IsAuthBasic)
+	e.Match(cardDAVMethods, "/carddav/*", contactsHandler.CardDAV, isAuth.IsAuthBasic)
+
+	// background jobs stop once the server is shutting down
+	done := make(chan struct{})
+
+	// snoozed dialogues come back on time even if nobody is online
+	go func() {
+		ticker := time.NewTicker(SNOOZE_CHECK_INTERVAL)
+		defer ticker.Stop()
+		for range ticker.C {
+			err := mailUC.WakeSnoozedDialogues()
+			if err != nil {
+				log.Printf("ERROR: Unable to wake snoozed dialogues: %v\n", err)
+			}
+		}
+	}()
+
+	// archives of exports are removed once their download links expire
+	go func() {
+		ticker := time.NewTicker(EXPORT_CLEANUP_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := mailUC.RemoveExpiredExports()
+				if err != nil {
+					log.Printf("ERROR: Unable to remove expired exports: %v\n", err)
+				}
+			}
+		}
+	}()
+
+	// failed webhook deliveries are retried from the database, so they survive restarts
+	go func() {
+		ticker := time.NewTicker(WEBHOOK_RETRY_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := mailUC.RetryWebhooks()
+				if err != nil {
+					log.Printf("ERROR: Unable to retry webhooks: %v\n", err)
+				}
+			}
+		}
+	}()
+
+	// events come from both this server and smtp server through postgres
+	eventsCtx, stopEvents := context.WithCancel(context.Background())
+	go func() {
+		for {
+			err := mailUC.ListenEvents(eventsCtx, eventHub.Publish)
+			if eventsCtx.Err() != nil {
+				return
+			}
+			log.Printf("ERROR: Mail events listener stopped: %v\n", err)
+			select {
+			case <-eventsCtx.Done():
+				return
+			case <-time.After(EVENTS_RECONNECT_INTERVAL):
+			}
+		}
+	}()
 
 	go func() {
 		addr := fmt.Sprintf("%s:%d", config.Host, config.Port)
@@ -142,6 +325,8 @@ func StartServer(config common.Config, quit chan os.Signal) {
 		}
 	}()
 	<-quit
+	close(done)
+	stopEvents()
 
 	log.Println("Interrupt signal received. Shutting down server...")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -17,17 +17,29 @@ type Config struct {
 
 	Host              string `json:"apiHost"`
 	Port              int    `json:"apiPort"`
+	ApiUrl            string `json:"apiUrl"`
 	AllowedOrigin     string `json:"allowedOrigin"`
 	AvatarStoragePath string `json:"avatarStoragePath"`
+	ExportStoragePath string `json:"exportStoragePath"` // mailbox export archives
+	ImportStoragePath string `json:"importStoragePath"` // uploaded archives until their import is done
 	ApiLogPath        string `json:"apiLogPath"`
 
 	SmtpHost           string `json:"smtpHost"`
 	SmtpPort           int    `json:"smtpPort"`
 	MailDomain         string `json:"mailDomain"`
 	DkimPrivateKeyPath string `json:"dkimPrivateKeyPath"`
+	SrsSecret          string `json:"srsSecret"`
+
+	// Web Push is disabled without VAPID keys, they are generated by cmd/vapid_keys
+	VapidPublicKey  string `json:"vapidPublicKey"`
+	VapidPrivateKey string `json:"vapidPrivateKey"`
+	VapidSubject    string `json:"vapidSubject"` // mailto: or https: contact of the server admin for push services
 
 	AuthHost string `json:"authHost"`
 	AuthPort int    `json:"authPort"`
+
+	// usernames allowed to manage installation wide settings, e.g. global blocklist
+	Admins []string `json:"admins"`
 }
 
 func (config *Config) ReadFromFile(path string) error {
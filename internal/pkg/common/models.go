@@ -3,6 +3,9 @@ package common
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -31,6 +34,71 @@ func (ns *NullString) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// StringList is scanned from comma separated string, e.g. string_agg result
+type StringList []string
+
+func (sl *StringList) Scan(value interface{}) error {
+	var joined string
+	switch v := value.(type) {
+	case nil:
+		*sl = StringList{}
+		return nil
+	case string:
+		joined = v
+	case []byte:
+		joined = string(v)
+	default:
+		return errors.New("unsupported type for string list")
+	}
+	if joined == "" {
+		*sl = StringList{}
+		return nil
+	}
+	*sl = strings.Split(joined, ",")
+	return nil
+}
+
+func (sl StringList) MarshalJSON() ([]byte, error) {
+	if sl == nil {
+		return json.Marshal([]string{})
+	}
+	return json.Marshal([]string(sl))
+}
+
+// IntList is kept as comma separated string of ids
+type IntList []int
+
+func (il *IntList) Scan(value interface{}) error {
+	var list StringList
+	if err := list.Scan(value); err != nil {
+		return err
+	}
+	ids := make(IntList, len(list))
+	for i, id := range list {
+		var err error
+		ids[i], err = strconv.Atoi(id)
+		if err != nil {
+			return err
+		}
+	}
+	*il = ids
+	return nil
+}
+
+func (il IntList) String() string {
+	ids := make([]string, len(il))
+	for i, id := range il {
+		ids[i] = strconv.Itoa(id)
+	}
+	return strings.Join(ids, ",")
+}
+
+func (il IntList) MarshalJSON() ([]byte, error) {
+	if il == nil {
+		return json.Marshal([]int{})
+	}
+	return json.Marshal([]int(il))
+}
 
 type Session struct {
 	UserId       int `gorm:"column:user_id"`
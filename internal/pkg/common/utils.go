@@ -2,6 +2,7 @@ package common
 
 import (
 	"bytes"
+	cryptoRand "crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
@@ -86,3 +87,13 @@ func GenerateRandomString() string {
 	h.Write([]byte(randNumStr))
 	return hex.EncodeToString(h.Sum(nil))
 }
+
+// GenerateSecureToken returns unpredictable token for links sent by email
+func GenerateSecureToken() (string, error) {
+	token := make([]byte, 32)
+	_, err := cryptoRand.Read(token)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
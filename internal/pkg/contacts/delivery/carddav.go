@@ -0,0 +1,352 @@
+package delivery
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/labstack/echo/v4"
+	"io"
+	"io/ioutil"
+	"liokor_mail/internal/pkg/contacts"
+	"liokor_mail/internal/pkg/user"
+	"liokor_mail/internal/utils"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Minimal CardDAV (RFC 6352) server: the user is the principal and has the only address book.
+// Principal and address book home are the root, the address book contains <uid>.vcf resources.
+const (
+	CardDAVRoot     = "/carddav/"
+	cardDAVBook     = CardDAVRoot + "contacts/"
+	maxVCardSize    = 1 << 20
+	vCardType       = "text/vcard; charset=utf-8"
+	multistatusType = "application/xml; charset=utf-8"
+)
+
+type davResponse struct {
+	Href     string
+	Props    string
+	NotFound bool
+}
+
+func xmlText(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func writeMultistatus(c echo.Context, responses []davResponse) error {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:cs="http://calendarserver.org/ns/">`)
+	for _, r := range responses {
+		b.WriteString("<d:response><d:href>" + xmlText(r.Href) + "</d:href>")
+		if r.NotFound {
+			b.WriteString("<d:status>HTTP/1.1 404 Not Found</d:status>")
+		} else {
+			b.WriteString("<d:propstat><d:prop>" + r.Props + "</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>")
+		}
+		b.WriteString("</d:response>")
+	}
+	b.WriteString("</d:multistatus>")
+	return c.Blob(http.StatusMultiStatus, multistatusType, []byte(b.String()))
+}
+
+func contactHref(contact contacts.Contact) string {
+	return cardDAVBook + url.PathEscape(contact.Uid) + ".vcf"
+}
+
+// contactUid returns uid of the resource addressed by path, empty if path is not an address book object
+func contactUid(path string) string {
+	if !strings.HasPrefix(path, cardDAVBook) || !strings.HasSuffix(path, ".vcf") {
+		return ""
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(path, cardDAVBook), ".vcf")
+	if name == "" || strings.Contains(name, "/") {
+		return ""
+	}
+	uid, err := url.PathUnescape(name)
+	if err != nil {
+		return ""
+	}
+	return uid
+}
+
+func contactToVCard(contact contacts.Contact) string {
+	return utils.FormatVCard(utils.VCard{
+		Uid:        contact.Uid,
+		FullName:   contact.Name,
+		Emails:     contact.Emails,
+		Phone:      contact.Phone,
+		Note:       contact.Notes,
+		Categories: contact.Groups,
+	})
+}
+
+func contactProps(contact contacts.Contact, withData bool) string {
+	props := "<d:getetag>" + xmlText(contact.ETag()) + "</d:getetag>" +
+		"<d:getcontenttype>" + vCardType + "</d:getcontenttype>"
+	if withData {
+		props += "<card:address-data>" + xmlText(contactToVCard(contact)) + "</card:address-data>"
+	}
+	return props
+}
+
+func (h *ContactsHandler) rootProps(username string) string {
+	return "<d:resourcetype><d:collection/></d:resourcetype>" +
+		"<d:displayname>" + xmlText(username) + "</d:displayname>" +
+		"<d:current-user-principal><d:href>" + CardDAVRoot + "</d:href></d:current-user-principal>" +
+		"<d:principal-URL><d:href>" + CardDAVRoot + "</d:href></d:principal-URL>" +
+		"<card:addressbook-home-set><d:href>" + CardDAVRoot + "</d:href></card:addressbook-home-set>"
+}
+
+func (h *ContactsHandler) bookProps(username string) (string, error) {
+	tag, err := h.ContactsUsecase.GetAddressBookTag(username)
+	if err != nil {
+		return "", err
+	}
+	return "<d:resourcetype><d:collection/><card:addressbook/></d:resourcetype>" +
+		"<d:displayname>Contacts</d:displayname>" +
+		"<d:current-user-principal><d:href>" + CardDAVRoot + "</d:href></d:current-user-principal>" +
+		"<cs:getctag>" + xmlText(tag) + "</cs:getctag>" +
+		"<d:supported-report-set>" +
+		"<d:supported-report><d:report><card:addressbook-multiget/></d:report></d:supported-report>" +
+		"<d:supported-report><d:report><card:addressbook-query/></d:report></d:supported-report>" +
+		"</d:supported-report-set>", nil
+}
+
+// CardDAV serves all the methods under CardDAVRoot
+func (h *ContactsHandler) CardDAV(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	path := c.Request().URL.Path
+	if path == strings.TrimSuffix(cardDAVBook, "/") || path == strings.TrimSuffix(CardDAVRoot, "/") {
+		path += "/"
+	}
+
+	switch c.Request().Method {
+	case http.MethodOptions:
+		c.Response().Header().Set("DAV", "1, 3, addressbook")
+		c.Response().Header().Set("Allow", "OPTIONS, PROPFIND, REPORT, GET, PUT, DELETE")
+		return c.NoContent(http.StatusOK)
+	case echo.PROPFIND:
+		return h.propfind(c, sessionUser.Username, path)
+	case echo.REPORT:
+		return h.report(c, sessionUser.Username, path)
+	case http.MethodGet:
+		return h.getVCard(c, sessionUser.Username, path)
+	case http.MethodPut:
+		return h.putVCard(c, sessionUser.Username, path)
+	case http.MethodDelete:
+		return h.deleteVCard(c, sessionUser.Username, path)
+	}
+	return echo.NewHTTPError(http.StatusMethodNotAllowed)
+}
+
+func (h *ContactsHandler) propfind(c echo.Context, username string, path string) error {
+	depth := c.Request().Header.Get("Depth")
+	responses := make([]davResponse, 0)
+
+	switch path {
+	case CardDAVRoot:
+		responses = append(responses, davResponse{Href: CardDAVRoot, Props: h.rootProps(username)})
+		if depth != "0" {
+			props, err := h.bookProps(username)
+			if err != nil {
+				return contactsError(err)
+			}
+			responses = append(responses, davResponse{Href: cardDAVBook, Props: props})
+		}
+	case cardDAVBook:
+		props, err := h.bookProps(username)
+		if err != nil {
+			return contactsError(err)
+		}
+		responses = append(responses, davResponse{Href: cardDAVBook, Props: props})
+		if depth != "0" {
+			list, err := h.ContactsUsecase.GetContacts(username, "")
+			if err != nil {
+				return contactsError(err)
+			}
+			for _, contact := range list {
+				responses = append(responses, davResponse{Href: contactHref(contact), Props: contactProps(contact, false)})
+			}
+		}
+	default:
+		uid := contactUid(path)
+		if uid == "" {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		contact, err := h.ContactsUsecase.GetContactByUid(username, uid)
+		if err != nil {
+			return contactsError(err)
+		}
+		responses = append(responses, davResponse{Href: contactHref(contact), Props: contactProps(contact, false)})
+	}
+
+	return writeMultistatus(c, responses)
+}
+
+func (h *ContactsHandler) report(c echo.Context, username string, path string) error {
+	if path != cardDAVBook {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	var report struct {
+		XMLName xml.Name
+		Hrefs   []string `xml:"DAV: href"`
+	}
+	defer c.Request().Body.Close()
+
+	err := xml.NewDecoder(io.LimitReader(c.Request().Body, maxVCardSize)).Decode(&report)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	responses := make([]davResponse, 0)
+	switch report.XMLName.Local {
+	case "addressbook-multiget":
+		for _, href := range report.Hrefs {
+			if u, err := url.Parse(href); err == nil {
+				href = u.Path
+			}
+			contact, err := h.ContactsUsecase.GetContactByUid(username, contactUid(href))
+			if err != nil {
+				if _, ok := err.(contacts.ContactNotFoundError); ok {
+					responses = append(responses, davResponse{Href: href, NotFound: true})
+					continue
+				}
+				return contactsError(err)
+			}
+			responses = append(responses, davResponse{Href: contactHref(contact), Props: contactProps(contact, true)})
+		}
+	case "addressbook-query":
+		// filters are not supported, the whole address book is matched
+		list, err := h.ContactsUsecase.GetContacts(username, "")
+		if err != nil {
+			return contactsError(err)
+		}
+		for _, contact := range list {
+			responses = append(responses, davResponse{Href: contactHref(contact), Props: contactProps(contact, true)})
+		}
+	default:
+		return echo.NewHTTPError(http.StatusForbidden, "unsupported report")
+	}
+
+	return writeMultistatus(c, responses)
+}
+
+func (h *ContactsHandler) getVCard(c echo.Context, username string, path string) error {
+	uid := contactUid(path)
+	if uid == "" {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	contact, err := h.ContactsUsecase.GetContactByUid(username, uid)
+	if err != nil {
+		return contactsError(err)
+	}
+	c.Response().Header().Set("ETag", contact.ETag())
+	return c.Blob(http.StatusOK, vCardType, []byte(contactToVCard(contact)))
+}
+
+// checkPreconditions validates If-Match and If-None-Match headers against current state of the resource
+func checkPreconditions(c echo.Context, current *contacts.Contact) error {
+	ifMatch := c.Request().Header.Get("If-Match")
+	ifNoneMatch := c.Request().Header.Get("If-None-Match")
+	if ifNoneMatch == "*" && current != nil {
+		return echo.NewHTTPError(http.StatusPreconditionFailed, "contact already exists")
+	}
+	if ifMatch != "" && (current == nil || (ifMatch != "*" && ifMatch != current.ETag())) {
+		return echo.NewHTTPError(http.StatusPreconditionFailed, "contact has been changed")
+	}
+	return nil
+}
+
+func (h *ContactsHandler) currentContact(username string, uid string) (*contacts.Contact, error) {
+	contact, err := h.ContactsUsecase.GetContactByUid(username, uid)
+	if err != nil {
+		if _, ok := err.(contacts.ContactNotFoundError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &contact, nil
+}
+
+func (h *ContactsHandler) putVCard(c echo.Context, username string, path string) error {
+	uid := contactUid(path)
+	if uid == "" {
+		return echo.NewHTTPError(http.StatusForbidden, "only vcards can be stored in the address book")
+	}
+
+	defer c.Request().Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(c.Request().Body, maxVCardSize))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	card, err := utils.ParseVCard(string(data))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, err.Error())
+	}
+
+	current, err := h.currentContact(username, uid)
+	if err != nil {
+		return contactsError(err)
+	}
+	if err = checkPreconditions(c, current); err != nil {
+		return err
+	}
+
+	// resource name identifies the contact, clients don't always keep it equal to vcard UID
+	contact, created, err := h.ContactsUsecase.PutContactByUid(username, contacts.Contact{
+		Uid:    uid,
+		Name:   card.FullName,
+		Emails: card.Emails,
+		Phone:  card.Phone,
+		Notes:  card.Note,
+		Groups: card.Categories,
+	})
+	if err != nil {
+		return contactsError(err)
+	}
+
+	c.Response().Header().Set("ETag", contact.ETag())
+	if created {
+		return c.NoContent(http.StatusCreated)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *ContactsHandler) deleteVCard(c echo.Context, username string, path string) error {
+	uid := contactUid(path)
+	if uid == "" {
+		return echo.NewHTTPError(http.StatusForbidden, "address book can't be deleted")
+	}
+
+	current, err := h.currentContact(username, uid)
+	if err != nil {
+		return contactsError(err)
+	}
+	if current == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "contact doesn't exist")
+	}
+	if err = checkPreconditions(c, current); err != nil {
+		return err
+	}
+
+	err = h.ContactsUsecase.DeleteContactByUid(username, uid)
+	if err != nil {
+		return contactsError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// WellKnownCardDAV redirects service discovery (RFC 6764) to the CardDAV root
+func (h *ContactsHandler) WellKnownCardDAV(c echo.Context) error {
+	return c.Redirect(http.StatusMovedPermanently, CardDAVRoot)
+}
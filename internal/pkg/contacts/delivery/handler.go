@@ -0,0 +1,161 @@
+package delivery
+
+import (
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"liokor_mail/internal/pkg/contacts"
+	"liokor_mail/internal/pkg/user"
+	"net/http"
+	"strconv"
+)
+
+type ContactsHandler struct {
+	ContactsUsecase contacts.ContactsUseCase
+}
+
+func contactsError(err error) error {
+	switch err.(type) {
+	case contacts.InvalidContactError:
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case contacts.ContactNotFoundError:
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h *ContactsHandler) GetContacts(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	list, err := h.ContactsUsecase.GetContacts(sessionUser.Username, c.QueryParam("group"))
+	if err != nil {
+		return contactsError(err)
+	}
+
+	return c.JSON(http.StatusOK, list)
+}
+
+func (h *ContactsHandler) GetContact(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	id, err := strconv.Atoi(c.QueryParam("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	contact, err := h.ContactsUsecase.GetContact(sessionUser.Username, id)
+	if err != nil {
+		return contactsError(err)
+	}
+
+	return c.JSON(http.StatusOK, contact)
+}
+
+func (h *ContactsHandler) CreateContact(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var newContact contacts.Contact
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&newContact)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	contact, err := h.ContactsUsecase.CreateContact(sessionUser.Username, newContact)
+	if err != nil {
+		return contactsError(err)
+	}
+
+	return c.JSON(http.StatusCreated, contact)
+}
+
+func (h *ContactsHandler) UpdateContact(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var newData contacts.Contact
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&newData)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	contact, err := h.ContactsUsecase.UpdateContact(sessionUser.Username, newData)
+	if err != nil {
+		return contactsError(err)
+	}
+
+	return c.JSON(http.StatusOK, contact)
+}
+
+func (h *ContactsHandler) DeleteContact(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var deleteContact struct {
+		Id int `json:"id"`
+	}
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&deleteContact)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.ContactsUsecase.DeleteContact(sessionUser.Username, deleteContact.Id)
+	if err != nil {
+		return contactsError(err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *ContactsHandler) GetGroups(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	groups, err := h.ContactsUsecase.GetGroups(sessionUser.Username)
+	if err != nil {
+		return contactsError(err)
+	}
+
+	return c.JSON(http.StatusOK, groups)
+}
+
+func (h *ContactsHandler) Autocomplete(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	suggestions, err := h.ContactsUsecase.Autocomplete(sessionUser.Username, c.QueryParam("q"))
+	if err != nil {
+		return contactsError(err)
+	}
+
+	return c.JSON(http.StatusOK, suggestions)
+}
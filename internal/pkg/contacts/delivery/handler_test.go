@@ -0,0 +1,210 @@
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"liokor_mail/internal/pkg/common"
+	"liokor_mail/internal/pkg/contacts"
+	contactsMocks "liokor_mail/internal/pkg/contacts/mocks"
+	"liokor_mail/internal/pkg/user"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var sessionUser = user.User{
+	Username: "alt",
+	FullName: "Alt",
+}
+
+var johnContact = contacts.Contact{
+	Id:      1,
+	Owner:   "alt",
+	Uid:     "john",
+	Name:    "John",
+	Emails:  common.StringList{"john@ya.ru"},
+	Updated: time.Now(),
+}
+
+func TestCreateContact(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockContactsUC := contactsMocks.NewMockContactsUseCase(mockCtrl)
+	contactsHandler := ContactsHandler{
+		mockContactsUC,
+	}
+
+	e := echo.New()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"name":   "John",
+		"emails": []string{"john@ya.ru"},
+	})
+	req := httptest.NewRequest("POST", "/contact", bytes.NewReader(body))
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockContactsUC.EXPECT().CreateContact("alt", gomock.Any()).Return(johnContact, nil).Times(1)
+	err := contactsHandler.CreateContact(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+	if response.Code != http.StatusCreated {
+		t.Errorf("Wrong status code: %d\n", response.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/contact", bytes.NewReader(body))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockContactsUC.EXPECT().CreateContact("alt", gomock.Any()).Return(contacts.Contact{}, contacts.InvalidContactError{"invalid email"}).Times(1)
+	err = contactsHandler.CreateContact(echoContext)
+	if err == nil || err.(*echo.HTTPError).Code != http.StatusBadRequest {
+		t.Errorf("Didn't pass invalid data: %v\n", err)
+	}
+}
+
+func TestAutocomplete(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockContactsUC := contactsMocks.NewMockContactsUseCase(mockCtrl)
+	contactsHandler := ContactsHandler{
+		mockContactsUC,
+	}
+
+	e := echo.New()
+	req := httptest.NewRequest("GET", "/contacts/autocomplete?q=jo", nil)
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockContactsUC.EXPECT().Autocomplete("alt", "jo").Return([]contacts.Suggestion{{Name: "John", Email: "john@ya.ru"}}, nil).Times(1)
+	err := contactsHandler.Autocomplete(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+
+	suggestions := make([]contacts.Suggestion, 0)
+	err = json.Unmarshal(response.Body.Bytes(), &suggestions)
+	if err != nil || len(suggestions) != 1 {
+		t.Errorf("Wrong suggestions: %v %v\n", suggestions, err)
+	}
+}
+
+func cardDAVRequest(e *echo.Echo, method string, path string, body string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+	return echoContext, response
+}
+
+func TestCardDAVPropfind(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockContactsUC := contactsMocks.NewMockContactsUseCase(mockCtrl)
+	contactsHandler := ContactsHandler{
+		mockContactsUC,
+	}
+
+	e := echo.New()
+
+	echoContext, response := cardDAVRequest(e, echo.PROPFIND, "/carddav/", "")
+	echoContext.Request().Header.Set("Depth", "0")
+	err := contactsHandler.CardDAV(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+	if response.Code != http.StatusMultiStatus || !strings.Contains(response.Body.String(), "addressbook-home-set") {
+		t.Errorf("Wrong principal response: %s\n", response.Body.String())
+	}
+
+	echoContext, response = cardDAVRequest(e, echo.PROPFIND, "/carddav/contacts/", "")
+	echoContext.Request().Header.Set("Depth", "1")
+	mockContactsUC.EXPECT().GetAddressBookTag("alt").Return("1-1", nil).Times(1)
+	mockContactsUC.EXPECT().GetContacts("alt", "").Return([]contacts.Contact{johnContact}, nil).Times(1)
+	err = contactsHandler.CardDAV(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+	if !strings.Contains(response.Body.String(), "/carddav/contacts/john.vcf") {
+		t.Errorf("Contact is not listed: %s\n", response.Body.String())
+	}
+}
+
+func TestCardDAVReport(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockContactsUC := contactsMocks.NewMockContactsUseCase(mockCtrl)
+	contactsHandler := ContactsHandler{
+		mockContactsUC,
+	}
+
+	e := echo.New()
+
+	report := `<?xml version="1.0"?>
+<card:addressbook-multiget xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav">
+  <d:prop><d:getetag/><card:address-data/></d:prop>
+  <d:href>/carddav/contacts/john.vcf</d:href>
+  <d:href>/carddav/contacts/gone.vcf</d:href>
+</card:addressbook-multiget>`
+	echoContext, response := cardDAVRequest(e, echo.REPORT, "/carddav/contacts/", report)
+	mockContactsUC.EXPECT().GetContactByUid("alt", "john").Return(johnContact, nil).Times(1)
+	mockContactsUC.EXPECT().GetContactByUid("alt", "gone").Return(contacts.Contact{}, contacts.ContactNotFoundError{"contact doesn't exist"}).Times(1)
+	err := contactsHandler.CardDAV(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+	if !strings.Contains(response.Body.String(), "EMAIL;TYPE=INTERNET:john@ya.ru") ||
+		!strings.Contains(response.Body.String(), "404 Not Found") {
+		t.Errorf("Wrong multiget response: %s\n", response.Body.String())
+	}
+}
+
+func TestCardDAVPut(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockContactsUC := contactsMocks.NewMockContactsUseCase(mockCtrl)
+	contactsHandler := ContactsHandler{
+		mockContactsUC,
+	}
+
+	e := echo.New()
+	vcard := "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:other\r\nFN:John\r\nEMAIL:john@ya.ru\r\nEND:VCARD\r\n"
+
+	echoContext, response := cardDAVRequest(e, http.MethodPut, "/carddav/contacts/john.vcf", vcard)
+	echoContext.Request().Header.Set("If-None-Match", "*")
+	mockContactsUC.EXPECT().GetContactByUid("alt", "john").Return(contacts.Contact{}, contacts.ContactNotFoundError{"contact doesn't exist"}).Times(1)
+	mockContactsUC.EXPECT().PutContactByUid("alt", gomock.Any()).DoAndReturn(func(owner string, contact contacts.Contact) (contacts.Contact, bool, error) {
+		if contact.Uid != "john" || contact.Name != "John" || len(contact.Emails) != 1 {
+			t.Errorf("Wrong contact parsed: %v\n", contact)
+		}
+		return johnContact, true, nil
+	}).Times(1)
+	err := contactsHandler.CardDAV(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+	if response.Code != http.StatusCreated || response.Header().Get("ETag") != johnContact.ETag() {
+		t.Errorf("Wrong put response: %d %s\n", response.Code, response.Header().Get("ETag"))
+	}
+
+	echoContext, _ = cardDAVRequest(e, http.MethodPut, "/carddav/contacts/john.vcf", vcard)
+	echoContext.Request().Header.Set("If-Match", `"outdated"`)
+	mockContactsUC.EXPECT().GetContactByUid("alt", "john").Return(johnContact, nil).Times(1)
+	err = contactsHandler.CardDAV(echoContext)
+	if err == nil || err.(*echo.HTTPError).Code != http.StatusPreconditionFailed {
+		t.Errorf("Overwrote changed contact: %v\n", err)
+	}
+}
@@ -0,0 +1,169 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: liokor_mail/internal/pkg/contacts (interfaces: ContactsRepository)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	contacts "liokor_mail/internal/pkg/contacts"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockContactsRepository is a mock of ContactsRepository interface.
+type MockContactsRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockContactsRepositoryMockRecorder
+}
+
+// MockContactsRepositoryMockRecorder is the mock recorder for MockContactsRepository.
+type MockContactsRepositoryMockRecorder struct {
+	mock *MockContactsRepository
+}
+
+// NewMockContactsRepository creates a new mock instance.
+func NewMockContactsRepository(ctrl *gomock.Controller) *MockContactsRepository {
+	mock := &MockContactsRepository{ctrl: ctrl}
+	mock.recorder = &MockContactsRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockContactsRepository) EXPECT() *MockContactsRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateContact mocks base method.
+func (m *MockContactsRepository) CreateContact(arg0 contacts.Contact) (contacts.Contact, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateContact", arg0)
+	ret0, _ := ret[0].(contacts.Contact)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateContact indicates an expected call of CreateContact.
+func (mr *MockContactsRepositoryMockRecorder) CreateContact(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateContact", reflect.TypeOf((*MockContactsRepository)(nil).CreateContact), arg0)
+}
+
+// DeleteContact mocks base method.
+func (m *MockContactsRepository) DeleteContact(arg0 string, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteContact", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteContact indicates an expected call of DeleteContact.
+func (mr *MockContactsRepositoryMockRecorder) DeleteContact(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteContact", reflect.TypeOf((*MockContactsRepository)(nil).DeleteContact), arg0, arg1)
+}
+
+// FindSuggestions mocks base method.
+func (m *MockContactsRepository) FindSuggestions(arg0, arg1 string, arg2 int) ([]contacts.Suggestion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindSuggestions", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]contacts.Suggestion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindSuggestions indicates an expected call of FindSuggestions.
+func (mr *MockContactsRepositoryMockRecorder) FindSuggestions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindSuggestions", reflect.TypeOf((*MockContactsRepository)(nil).FindSuggestions), arg0, arg1, arg2)
+}
+
+// GetAddressBookTag mocks base method.
+func (m *MockContactsRepository) GetAddressBookTag(arg0 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAddressBookTag", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAddressBookTag indicates an expected call of GetAddressBookTag.
+func (mr *MockContactsRepositoryMockRecorder) GetAddressBookTag(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAddressBookTag", reflect.TypeOf((*MockContactsRepository)(nil).GetAddressBookTag), arg0)
+}
+
+// GetContact mocks base method.
+func (m *MockContactsRepository) GetContact(arg0 string, arg1 int) (contacts.Contact, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContact", arg0, arg1)
+	ret0, _ := ret[0].(contacts.Contact)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetContact indicates an expected call of GetContact.
+func (mr *MockContactsRepositoryMockRecorder) GetContact(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContact", reflect.TypeOf((*MockContactsRepository)(nil).GetContact), arg0, arg1)
+}
+
+// GetContactByUid mocks base method.
+func (m *MockContactsRepository) GetContactByUid(arg0, arg1 string) (contacts.Contact, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContactByUid", arg0, arg1)
+	ret0, _ := ret[0].(contacts.Contact)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetContactByUid indicates an expected call of GetContactByUid.
+func (mr *MockContactsRepositoryMockRecorder) GetContactByUid(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContactByUid", reflect.TypeOf((*MockContactsRepository)(nil).GetContactByUid), arg0, arg1)
+}
+
+// GetContacts mocks base method.
+func (m *MockContactsRepository) GetContacts(arg0, arg1 string) ([]contacts.Contact, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContacts", arg0, arg1)
+	ret0, _ := ret[0].([]contacts.Contact)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetContacts indicates an expected call of GetContacts.
+func (mr *MockContactsRepositoryMockRecorder) GetContacts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContacts", reflect.TypeOf((*MockContactsRepository)(nil).GetContacts), arg0, arg1)
+}
+
+// GetGroups mocks base method.
+func (m *MockContactsRepository) GetGroups(arg0 string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGroups", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGroups indicates an expected call of GetGroups.
+func (mr *MockContactsRepositoryMockRecorder) GetGroups(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGroups", reflect.TypeOf((*MockContactsRepository)(nil).GetGroups), arg0)
+}
+
+// UpdateContact mocks base method.
+func (m *MockContactsRepository) UpdateContact(arg0 contacts.Contact) (contacts.Contact, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateContact", arg0)
+	ret0, _ := ret[0].(contacts.Contact)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateContact indicates an expected call of UpdateContact.
+func (mr *MockContactsRepositoryMockRecorder) UpdateContact(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateContact", reflect.TypeOf((*MockContactsRepository)(nil).UpdateContact), arg0)
+}
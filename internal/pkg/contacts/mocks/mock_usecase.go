@@ -0,0 +1,199 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: liokor_mail/internal/pkg/contacts (interfaces: ContactsUseCase)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	contacts "liokor_mail/internal/pkg/contacts"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockContactsUseCase is a mock of ContactsUseCase interface.
+type MockContactsUseCase struct {
+	ctrl     *gomock.Controller
+	recorder *MockContactsUseCaseMockRecorder
+}
+
+// MockContactsUseCaseMockRecorder is the mock recorder for MockContactsUseCase.
+type MockContactsUseCaseMockRecorder struct {
+	mock *MockContactsUseCase
+}
+
+// NewMockContactsUseCase creates a new mock instance.
+func NewMockContactsUseCase(ctrl *gomock.Controller) *MockContactsUseCase {
+	mock := &MockContactsUseCase{ctrl: ctrl}
+	mock.recorder = &MockContactsUseCaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockContactsUseCase) EXPECT() *MockContactsUseCaseMockRecorder {
+	return m.recorder
+}
+
+// Autocomplete mocks base method.
+func (m *MockContactsUseCase) Autocomplete(arg0, arg1 string) ([]contacts.Suggestion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Autocomplete", arg0, arg1)
+	ret0, _ := ret[0].([]contacts.Suggestion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Autocomplete indicates an expected call of Autocomplete.
+func (mr *MockContactsUseCaseMockRecorder) Autocomplete(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Autocomplete", reflect.TypeOf((*MockContactsUseCase)(nil).Autocomplete), arg0, arg1)
+}
+
+// CreateContact mocks base method.
+func (m *MockContactsUseCase) CreateContact(arg0 string, arg1 contacts.Contact) (contacts.Contact, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateContact", arg0, arg1)
+	ret0, _ := ret[0].(contacts.Contact)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateContact indicates an expected call of CreateContact.
+func (mr *MockContactsUseCaseMockRecorder) CreateContact(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateContact", reflect.TypeOf((*MockContactsUseCase)(nil).CreateContact), arg0, arg1)
+}
+
+// DeleteContact mocks base method.
+func (m *MockContactsUseCase) DeleteContact(arg0 string, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteContact", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteContact indicates an expected call of DeleteContact.
+func (mr *MockContactsUseCaseMockRecorder) DeleteContact(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteContact", reflect.TypeOf((*MockContactsUseCase)(nil).DeleteContact), arg0, arg1)
+}
+
+// DeleteContactByUid mocks base method.
+func (m *MockContactsUseCase) DeleteContactByUid(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteContactByUid", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteContactByUid indicates an expected call of DeleteContactByUid.
+func (mr *MockContactsUseCaseMockRecorder) DeleteContactByUid(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteContactByUid", reflect.TypeOf((*MockContactsUseCase)(nil).DeleteContactByUid), arg0, arg1)
+}
+
+// GetAddressBookTag mocks base method.
+func (m *MockContactsUseCase) GetAddressBookTag(arg0 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAddressBookTag", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAddressBookTag indicates an expected call of GetAddressBookTag.
+func (mr *MockContactsUseCaseMockRecorder) GetAddressBookTag(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAddressBookTag", reflect.TypeOf((*MockContactsUseCase)(nil).GetAddressBookTag), arg0)
+}
+
+// GetContact mocks base method.
+func (m *MockContactsUseCase) GetContact(arg0 string, arg1 int) (contacts.Contact, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContact", arg0, arg1)
+	ret0, _ := ret[0].(contacts.Contact)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetContact indicates an expected call of GetContact.
+func (mr *MockContactsUseCaseMockRecorder) GetContact(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContact", reflect.TypeOf((*MockContactsUseCase)(nil).GetContact), arg0, arg1)
+}
+
+// GetContactByUid mocks base method.
+func (m *MockContactsUseCase) GetContactByUid(arg0, arg1 string) (contacts.Contact, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContactByUid", arg0, arg1)
+	ret0, _ := ret[0].(contacts.Contact)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetContactByUid indicates an expected call of GetContactByUid.
+func (mr *MockContactsUseCaseMockRecorder) GetContactByUid(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContactByUid", reflect.TypeOf((*MockContactsUseCase)(nil).GetContactByUid), arg0, arg1)
+}
+
+// GetContacts mocks base method.
+func (m *MockContactsUseCase) GetContacts(arg0, arg1 string) ([]contacts.Contact, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContacts", arg0, arg1)
+	ret0, _ := ret[0].([]contacts.Contact)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetContacts indicates an expected call of GetContacts.
+func (mr *MockContactsUseCaseMockRecorder) GetContacts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContacts", reflect.TypeOf((*MockContactsUseCase)(nil).GetContacts), arg0, arg1)
+}
+
+// GetGroups mocks base method.
+func (m *MockContactsUseCase) GetGroups(arg0 string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGroups", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGroups indicates an expected call of GetGroups.
+func (mr *MockContactsUseCaseMockRecorder) GetGroups(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGroups", reflect.TypeOf((*MockContactsUseCase)(nil).GetGroups), arg0)
+}
+
+// PutContactByUid mocks base method.
+func (m *MockContactsUseCase) PutContactByUid(arg0 string, arg1 contacts.Contact) (contacts.Contact, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutContactByUid", arg0, arg1)
+	ret0, _ := ret[0].(contacts.Contact)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// PutContactByUid indicates an expected call of PutContactByUid.
+func (mr *MockContactsUseCaseMockRecorder) PutContactByUid(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutContactByUid", reflect.TypeOf((*MockContactsUseCase)(nil).PutContactByUid), arg0, arg1)
+}
+
+// UpdateContact mocks base method.
+func (m *MockContactsUseCase) UpdateContact(arg0 string, arg1 contacts.Contact) (contacts.Contact, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateContact", arg0, arg1)
+	ret0, _ := ret[0].(contacts.Contact)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateContact indicates an expected call of UpdateContact.
+func (mr *MockContactsUseCaseMockRecorder) UpdateContact(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateContact", reflect.TypeOf((*MockContactsUseCase)(nil).UpdateContact), arg0, arg1)
+}
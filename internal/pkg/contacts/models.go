@@ -0,0 +1,47 @@
+package contacts
+
+import (
+	"liokor_mail/internal/pkg/common"
+	"strconv"
+	"time"
+)
+
+type Contact struct {
+	Id            int               `json:"id" gorm:"column:id"`
+	Owner         string            `json:"-" gorm:"column:owner"`
+	Uid           string            `json:"uid" gorm:"column:uid"`
+	Name          string            `json:"name" gorm:"column:name"`
+	Emails        common.StringList `json:"emails" gorm:"column:emails"`
+	Phone         string            `json:"phone" gorm:"column:phone"`
+	Notes         string            `json:"notes" gorm:"column:notes"`
+	Groups        common.StringList `json:"groups" gorm:"column:groups"`
+	AutoCollected bool              `json:"autoCollected" gorm:"column:auto_collected"`
+	Updated       time.Time         `json:"updated" gorm:"column:updated"`
+}
+
+// ETag changes on every update of the contact
+func (c Contact) ETag() string {
+	return `"` + strconv.FormatInt(c.Updated.UnixNano(), 36) + `"`
+}
+
+// Suggestion is a recipient proposed by autocomplete
+type Suggestion struct {
+	Name  string `json:"name" gorm:"column:name"`
+	Email string `json:"email" gorm:"column:email"`
+}
+
+type InvalidContactError struct {
+	Message string
+}
+
+func (e InvalidContactError) Error() string {
+	return e.Message
+}
+
+type ContactNotFoundError struct {
+	Message string
+}
+
+func (e ContactNotFoundError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,13 @@
+package contacts
+
+type ContactsRepository interface {
+	GetContacts(owner string, group string) ([]Contact, error)
+	GetContact(owner string, id int) (Contact, error)
+	GetContactByUid(owner string, uid string) (Contact, error)
+	CreateContact(contact Contact) (Contact, error)
+	UpdateContact(contact Contact) (Contact, error)
+	DeleteContact(owner string, id int) error
+	GetGroups(owner string) ([]string, error)
+	FindSuggestions(owner string, query string, limit int) ([]Suggestion, error)
+	GetAddressBookTag(owner string) (string, error)
+}
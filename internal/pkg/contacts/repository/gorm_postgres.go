@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+	"liokor_mail/internal/pkg/common"
+	"liokor_mail/internal/pkg/contacts"
+	"strings"
+	"time"
+)
+
+type GormPostgresContactsRepository struct {
+	DBInstance common.GormPostgresDataBase
+}
+
+const contactColumns = "contacts.id, contacts.owner, contacts.uid, contacts.name, contacts.phone, contacts.notes, " +
+	"contacts.auto_collected, contacts.updated, " +
+	"(SELECT string_agg(email, ',' ORDER BY email) FROM contact_emails WHERE contact_id=contacts.id) emails, " +
+	"(SELECT string_agg(name, ',' ORDER BY name) FROM contact_groups WHERE contact_id=contacts.id) groups"
+
+func (gcr *GormPostgresContactsRepository) GetContacts(owner string, group string) ([]contacts.Contact, error) {
+	list := make([]contacts.Contact, 0)
+	query := gcr.DBInstance.DB.
+		Table("contacts").
+		Select(contactColumns).
+		Where("contacts.owner=?", owner)
+	if group != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM contact_groups WHERE contact_id=contacts.id AND name=?)", group)
+	}
+	err := query.
+		Order("contacts.name, contacts.id").
+		Scan(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (gcr *GormPostgresContactsRepository) getContact(cond string, args ...interface{}) (contacts.Contact, error) {
+	var contact contacts.Contact
+	result := gcr.DBInstance.DB.
+		Table("contacts").
+		Select(contactColumns).
+		Where(cond, args...).
+		Limit(1).
+		Scan(&contact)
+	if err := result.Error; err != nil {
+		return contacts.Contact{}, err
+	}
+	if result.RowsAffected == 0 {
+		return contacts.Contact{}, contacts.ContactNotFoundError{"contact doesn't exist"}
+	}
+	return contact, nil
+}
+
+func (gcr *GormPostgresContactsRepository) GetContact(owner string, id int) (contacts.Contact, error) {
+	return gcr.getContact("contacts.owner=? AND contacts.id=?", owner, id)
+}
+
+func (gcr *GormPostgresContactsRepository) GetContactByUid(owner string, uid string) (contacts.Contact, error) {
+	return gcr.getContact("contacts.owner=? AND contacts.uid=?", owner, uid)
+}
+
+// setContactLists replaces emails and groups of the contact
+func setContactLists(tx *gorm.DB, contact contacts.Contact) error {
+	err := tx.Exec("DELETE FROM contact_emails WHERE contact_id=?", contact.Id).Error
+	if err != nil {
+		return err
+	}
+	for _, email := range contact.Emails {
+		err = tx.Exec(
+			"INSERT INTO contact_emails (contact_id, email) VALUES (?, ?) ON CONFLICT DO NOTHING",
+			contact.Id,
+			email,
+		).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Exec("DELETE FROM contact_groups WHERE contact_id=?", contact.Id).Error
+	if err != nil {
+		return err
+	}
+	for _, group := range contact.Groups {
+		err = tx.Exec(
+			"INSERT INTO contact_groups (contact_id, name) VALUES (?, ?) ON CONFLICT DO NOTHING",
+			contact.Id,
+			group,
+		).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gcr *GormPostgresContactsRepository) CreateContact(contact contacts.Contact) (contacts.Contact, error) {
+	err := gcr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		var created struct {
+			Id      int       `gorm:"column:id"`
+			Updated time.Time `gorm:"column:updated"`
+		}
+		err := tx.Raw(
+			"INSERT INTO contacts (owner, uid, name, phone, notes, auto_collected) VALUES (?, ?, ?, ?, ?, ?) RETURNING id, updated",
+			contact.Owner,
+			contact.Uid,
+			contact.Name,
+			contact.Phone,
+			contact.Notes,
+			contact.AutoCollected,
+		).Scan(&created).Error
+		if err != nil {
+			return err
+		}
+		contact.Id = created.Id
+		contact.Updated = created.Updated
+		return setContactLists(tx, contact)
+	})
+	if err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "contacts_owner_uid_key" {
+				return contacts.Contact{}, contacts.InvalidContactError{"contact already exists"}
+			}
+		}
+		return contacts.Contact{}, err
+	}
+	return contact, nil
+}
+
+func (gcr *GormPostgresContactsRepository) UpdateContact(contact contacts.Contact) (contacts.Contact, error) {
+	err := gcr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		var updated struct {
+			Uid     string    `gorm:"column:uid"`
+			Updated time.Time `gorm:"column:updated"`
+		}
+		// edited by the owner, so the contact is no longer auto collected
+		result := tx.Raw(
+			"UPDATE contacts SET name=?, phone=?, notes=?, auto_collected=FALSE, updated=NOW() WHERE id=? AND owner=? RETURNING uid, updated",
+			contact.Name,
+			contact.Phone,
+			contact.Notes,
+			contact.Id,
+			contact.Owner,
+		).Scan(&updated)
+		if err := result.Error; err != nil {
+			return err
+		}
+		if result.RowsAffected == 0 {
+			return contacts.ContactNotFoundError{"contact doesn't exist"}
+		}
+		contact.Uid = updated.Uid
+		contact.Updated = updated.Updated
+		contact.AutoCollected = false
+		return setContactLists(tx, contact)
+	})
+	if err != nil {
+		return contacts.Contact{}, err
+	}
+	return contact, nil
+}
+
+func (gcr *GormPostgresContactsRepository) DeleteContact(owner string, id int) error {
+	result := gcr.DBInstance.DB.Exec("DELETE FROM contacts WHERE owner=? AND id=?", owner, id)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return contacts.ContactNotFoundError{"contact doesn't exist"}
+	}
+	return nil
+}
+
+func (gcr *GormPostgresContactsRepository) GetGroups(owner string) ([]string, error) {
+	groups := make([]string, 0)
+	err := gcr.DBInstance.DB.
+		Table("contact_groups").
+		Joins("JOIN contacts ON contacts.id=contact_groups.contact_id").
+		Where("contacts.owner=?", owner).
+		Distinct().
+		Order("contact_groups.name").
+		Pluck("contact_groups.name", &groups).Error
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// FindSuggestions looks for the query in contact names and addresses, manually added contacts go first
+func (gcr *GormPostgresContactsRepository) FindSuggestions(owner string, query string, limit int) ([]contacts.Suggestion, error) {
+	suggestions := make([]contacts.Suggestion, 0)
+	pattern := "%" + escapeLike(query) + "%"
+	err := gcr.DBInstance.DB.
+		Table("contact_emails").
+		Select("contacts.name, contact_emails.email").
+		Joins("JOIN contacts ON contacts.id=contact_emails.contact_id").
+		Where("contacts.owner=?", owner).
+		Where("contacts.name ILIKE ? OR contact_emails.email ILIKE ?", pattern, pattern).
+		Order("contacts.auto_collected, contacts.name, contact_emails.email").
+		Limit(limit).
+		Scan(&suggestions).Error
+	if err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+// GetAddressBookTag changes whenever any contact of the owner is added, updated or deleted
+func (gcr *GormPostgresContactsRepository) GetAddressBookTag(owner string) (string, error) {
+	var state struct {
+		Count   int       `gorm:"column:count"`
+		Updated time.Time `gorm:"column:updated"`
+	}
+	err := gcr.DBInstance.DB.
+		Table("contacts").
+		Select("COUNT(*) count, COALESCE(MAX(updated), 'epoch') updated").
+		Where("owner=?", owner).
+		Scan(&state).Error
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d", state.Count, state.Updated.UnixNano()), nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
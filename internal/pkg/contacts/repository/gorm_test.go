@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"liokor_mail/internal/pkg/common"
+	"liokor_mail/internal/pkg/contacts"
+	"testing"
+	"time"
+)
+
+type Suite struct {
+	suite.Suite
+	DB   *gorm.DB
+	mock sqlmock.Sqlmock
+	gcr  GormPostgresContactsRepository
+
+	owner   string
+	contact contacts.Contact
+}
+
+func (s *Suite) SetupSuite() {
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	db, s.mock, err = sqlmock.New()
+	require.NoError(s.T(), err)
+
+	s.DB, err = gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{})
+	require.NoError(s.T(), err)
+
+	s.gcr = GormPostgresContactsRepository{
+		common.GormPostgresDataBase{
+			s.DB,
+		},
+	}
+
+	s.owner = "alt"
+	s.contact = contacts.Contact{
+		Id:      1,
+		Owner:   s.owner,
+		Uid:     "uid",
+		Name:    "John",
+		Emails:  common.StringList{"john@ya.ru"},
+		Groups:  common.StringList{"work"},
+		Updated: time.Now(),
+	}
+}
+
+func (s *Suite) AfterTest(_, _ string) {
+	require.NoError(s.T(), s.mock.ExpectationsWereMet())
+}
+
+func TestInit(t *testing.T) {
+	suite.Run(t, new(Suite))
+}
+
+func contactRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{
+		"id", "owner", "uid", "name", "phone", "notes", "auto_collected", "updated", "emails", "groups",
+	})
+}
+
+func (s *Suite) TestGetContacts() {
+	s.mock.ExpectQuery("SELECT contacts.id").
+		WithArgs(s.owner, "work").
+		WillReturnRows(contactRows().
+			AddRow(1, s.owner, "uid", "John", "", "", false, time.Now(), "doe@ya.ru,john@ya.ru", "work"))
+	list, err := s.gcr.GetContacts(s.owner, "work")
+	require.NoError(s.T(), err)
+	require.Len(s.T(), list, 1)
+	require.Equal(s.T(), common.StringList{"doe@ya.ru", "john@ya.ru"}, list[0].Emails)
+}
+
+func (s *Suite) TestGetContactByUid() {
+	s.mock.ExpectQuery("SELECT contacts.id").
+		WithArgs(s.owner, "uid").
+		WillReturnRows(contactRows().
+			AddRow(1, s.owner, "uid", "John", "", "", false, time.Now(), "john@ya.ru", nil))
+	contact, err := s.gcr.GetContactByUid(s.owner, "uid")
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 1, contact.Id)
+
+	s.mock.ExpectQuery("SELECT contacts.id").
+		WithArgs(s.owner, "other").
+		WillReturnRows(contactRows())
+	_, err = s.gcr.GetContactByUid(s.owner, "other")
+	require.IsType(s.T(), contacts.ContactNotFoundError{}, err)
+}
+
+func (s *Suite) TestCreateContact() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery("INSERT INTO contacts").
+		WithArgs(s.owner, s.contact.Uid, s.contact.Name, "", "", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "updated"}).AddRow(5, time.Now()))
+	s.mock.ExpectExec("DELETE FROM contact_emails").
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectExec("INSERT INTO contact_emails").
+		WithArgs(5, "john@ya.ru").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectExec("DELETE FROM contact_groups").
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectExec("INSERT INTO contact_groups").
+		WithArgs(5, "work").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+
+	contact, err := s.gcr.CreateContact(s.contact)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 5, contact.Id)
+}
+
+func (s *Suite) TestUpdateContact() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery("UPDATE contacts").
+		WithArgs(s.contact.Name, "", "", s.contact.Id, s.owner).
+		WillReturnRows(sqlmock.NewRows([]string{"uid", "updated"}))
+	s.mock.ExpectRollback()
+
+	_, err := s.gcr.UpdateContact(s.contact)
+	require.IsType(s.T(), contacts.ContactNotFoundError{}, err)
+}
+
+func (s *Suite) TestDeleteContact() {
+	s.mock.ExpectExec("DELETE FROM contacts").
+		WithArgs(s.owner, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	err := s.gcr.DeleteContact(s.owner, 1)
+	require.NoError(s.T(), err)
+
+	s.mock.ExpectExec("DELETE FROM contacts").
+		WithArgs(s.owner, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	err = s.gcr.DeleteContact(s.owner, 2)
+	require.IsType(s.T(), contacts.ContactNotFoundError{}, err)
+}
+
+func (s *Suite) TestFindSuggestions() {
+	s.mock.ExpectQuery("SELECT contacts.name, contact_emails.email").
+		WithArgs(s.owner, `%jo\_%`, `%jo\_%`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "email"}).AddRow("John", "jo_hn@ya.ru"))
+	suggestions, err := s.gcr.FindSuggestions(s.owner, "jo_", 10)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), []contacts.Suggestion{{Name: "John", Email: "jo_hn@ya.ru"}}, suggestions)
+}
@@ -0,0 +1,16 @@
+package contacts
+
+type ContactsUseCase interface {
+	GetContacts(owner string, group string) ([]Contact, error)
+	GetContact(owner string, id int) (Contact, error)
+	CreateContact(owner string, contact Contact) (Contact, error)
+	UpdateContact(owner string, contact Contact) (Contact, error)
+	DeleteContact(owner string, id int) error
+	GetGroups(owner string) ([]string, error)
+	Autocomplete(owner string, query string) ([]Suggestion, error)
+
+	GetContactByUid(owner string, uid string) (Contact, error)
+	PutContactByUid(owner string, contact Contact) (Contact, bool, error)
+	DeleteContactByUid(owner string, uid string) error
+	GetAddressBookTag(owner string) (string, error)
+}
@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"github.com/microcosm-cc/bluemonday"
+	"liokor_mail/internal/pkg/common"
+	"liokor_mail/internal/pkg/contacts"
+	"strings"
+)
+
+const autocompleteLimit = 10
+
+type ContactsUseCase struct {
+	Repository contacts.ContactsRepository
+}
+
+// normalizeContact trims and sanitizes the fields, emails and groups are deduplicated
+func normalizeContact(contact contacts.Contact) (contacts.Contact, error) {
+	pStrict := bluemonday.StrictPolicy()
+	contact.Name = pStrict.Sanitize(strings.TrimSpace(contact.Name))
+	contact.Phone = pStrict.Sanitize(strings.TrimSpace(contact.Phone))
+	contact.Notes = pStrict.Sanitize(strings.TrimSpace(contact.Notes))
+
+	emails := make(common.StringList, 0, len(contact.Emails))
+	seen := map[string]bool{}
+	for _, email := range contact.Emails {
+		email = strings.ToLower(strings.TrimSpace(email))
+		if email == "" || seen[email] {
+			continue
+		}
+		at := strings.Index(email, "@")
+		if at <= 0 || at != strings.LastIndex(email, "@") || at == len(email)-1 || strings.ContainsAny(email, ", <>\"") {
+			return contacts.Contact{}, contacts.InvalidContactError{"invalid email: " + email}
+		}
+		seen[email] = true
+		emails = append(emails, email)
+	}
+	contact.Emails = emails
+
+	groups := make(common.StringList, 0, len(contact.Groups))
+	seen = map[string]bool{}
+	for _, group := range contact.Groups {
+		group = pStrict.Sanitize(strings.TrimSpace(group))
+		if group == "" || seen[group] {
+			continue
+		}
+		if strings.Contains(group, ",") {
+			return contacts.Contact{}, contacts.InvalidContactError{"group name can't contain commas"}
+		}
+		seen[group] = true
+		groups = append(groups, group)
+	}
+	contact.Groups = groups
+
+	if contact.Name == "" && len(contact.Emails) == 0 {
+		return contacts.Contact{}, contacts.InvalidContactError{"contact needs a name or an email"}
+	}
+	return contact, nil
+}
+
+func (uc *ContactsUseCase) GetContacts(owner string, group string) ([]contacts.Contact, error) {
+	return uc.Repository.GetContacts(owner, group)
+}
+
+func (uc *ContactsUseCase) GetContact(owner string, id int) (contacts.Contact, error) {
+	return uc.Repository.GetContact(owner, id)
+}
+
+func (uc *ContactsUseCase) CreateContact(owner string, contact contacts.Contact) (contacts.Contact, error) {
+	contact, err := normalizeContact(contact)
+	if err != nil {
+		return contacts.Contact{}, err
+	}
+	contact.Owner = owner
+	contact.AutoCollected = false
+	contact.Uid, err = common.GenerateSecureToken()
+	if err != nil {
+		return contacts.Contact{}, err
+	}
+	return uc.Repository.CreateContact(contact)
+}
+
+func (uc *ContactsUseCase) UpdateContact(owner string, contact contacts.Contact) (contacts.Contact, error) {
+	contact, err := normalizeContact(contact)
+	if err != nil {
+		return contacts.Contact{}, err
+	}
+	contact.Owner = owner
+	return uc.Repository.UpdateContact(contact)
+}
+
+func (uc *ContactsUseCase) DeleteContact(owner string, id int) error {
+	return uc.Repository.DeleteContact(owner, id)
+}
+
+func (uc *ContactsUseCase) GetGroups(owner string) ([]string, error) {
+	return uc.Repository.GetGroups(owner)
+}
+
+func (uc *ContactsUseCase) Autocomplete(owner string, query string) ([]contacts.Suggestion, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return []contacts.Suggestion{}, nil
+	}
+	return uc.Repository.FindSuggestions(owner, query, autocompleteLimit)
+}
+
+func (uc *ContactsUseCase) GetContactByUid(owner string, uid string) (contacts.Contact, error) {
+	return uc.Repository.GetContactByUid(owner, uid)
+}
+
+// PutContactByUid creates or replaces the contact uploaded by CardDAV client, returns true if contact was created
+func (uc *ContactsUseCase) PutContactByUid(owner string, contact contacts.Contact) (contacts.Contact, bool, error) {
+	if contact.Uid == "" {
+		return contacts.Contact{}, false, contacts.InvalidContactError{"contact uid is required"}
+	}
+	contact, err := normalizeContact(contact)
+	if err != nil {
+		return contacts.Contact{}, false, err
+	}
+	contact.Owner = owner
+
+	existing, err := uc.Repository.GetContactByUid(owner, contact.Uid)
+	if err != nil {
+		if _, ok := err.(contacts.ContactNotFoundError); !ok {
+			return contacts.Contact{}, false, err
+		}
+		contact.AutoCollected = false
+		created, err := uc.Repository.CreateContact(contact)
+		return created, err == nil, err
+	}
+
+	contact.Id = existing.Id
+	updated, err := uc.Repository.UpdateContact(contact)
+	return updated, false, err
+}
+
+func (uc *ContactsUseCase) DeleteContactByUid(owner string, uid string) error {
+	contact, err := uc.Repository.GetContactByUid(owner, uid)
+	if err != nil {
+		return err
+	}
+	return uc.Repository.DeleteContact(owner, contact.Id)
+}
+
+func (uc *ContactsUseCase) GetAddressBookTag(owner string) (string, error) {
+	return uc.Repository.GetAddressBookTag(owner)
+}
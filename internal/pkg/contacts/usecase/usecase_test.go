@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"github.com/golang/mock/gomock"
+	"liokor_mail/internal/pkg/common"
+	"liokor_mail/internal/pkg/contacts"
+	"liokor_mail/internal/pkg/contacts/mocks"
+	"testing"
+)
+
+func TestCreateContact(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockContactsRepository(mockCtrl)
+	contactsUC := ContactsUseCase{mockRep}
+
+	newContact := contacts.Contact{
+		Name:   " John <b>Doe</b> ",
+		Emails: common.StringList{"John@ya.ru", "john@ya.ru", ""},
+		Groups: common.StringList{"work", " work"},
+	}
+	mockRep.EXPECT().CreateContact(gomock.Any()).DoAndReturn(func(contact contacts.Contact) (contacts.Contact, error) {
+		if contact.Owner != "alt" || contact.Uid == "" || contact.Name != "John Doe" {
+			t.Errorf("Invalid contact passed to repository: %v\n", contact)
+		}
+		if len(contact.Emails) != 1 || contact.Emails[0] != "john@ya.ru" || len(contact.Groups) != 1 {
+			t.Errorf("Emails and groups weren't deduplicated: %v\n", contact)
+		}
+		contact.Id = 1
+		return contact, nil
+	}).Times(1)
+	_, err := contactsUC.CreateContact("alt", newContact)
+	if err != nil {
+		t.Errorf("Didn't create valid contact: %v\n", err)
+	}
+
+	_, err = contactsUC.CreateContact("alt", contacts.Contact{Emails: common.StringList{"not an email"}})
+	if _, ok := err.(contacts.InvalidContactError); !ok {
+		t.Errorf("Created contact with invalid email: %v\n", err)
+	}
+
+	_, err = contactsUC.CreateContact("alt", contacts.Contact{Phone: "+79991234567"})
+	if _, ok := err.(contacts.InvalidContactError); !ok {
+		t.Errorf("Created contact without name and email: %v\n", err)
+	}
+
+	_, err = contactsUC.CreateContact("alt", contacts.Contact{Name: "John", Groups: common.StringList{"a,b"}})
+	if _, ok := err.(contacts.InvalidContactError); !ok {
+		t.Errorf("Created contact with comma in group: %v\n", err)
+	}
+}
+
+func TestAutocomplete(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockContactsRepository(mockCtrl)
+	contactsUC := ContactsUseCase{mockRep}
+
+	suggestions := []contacts.Suggestion{{Name: "John", Email: "john@ya.ru"}}
+	mockRep.EXPECT().FindSuggestions("alt", "jo", autocompleteLimit).Return(suggestions, nil).Times(1)
+	result, err := contactsUC.Autocomplete("alt", " jo ")
+	if err != nil || len(result) != 1 {
+		t.Errorf("Didn't find suggestions: %v %v\n", result, err)
+	}
+
+	result, err = contactsUC.Autocomplete("alt", "")
+	if err != nil || len(result) != 0 {
+		t.Errorf("Found suggestions for empty query: %v %v\n", result, err)
+	}
+}
+
+func TestPutContactByUid(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockContactsRepository(mockCtrl)
+	contactsUC := ContactsUseCase{mockRep}
+
+	contact := contacts.Contact{
+		Uid:    "phone-1",
+		Name:   "John",
+		Emails: common.StringList{"john@ya.ru"},
+	}
+
+	mockRep.EXPECT().GetContactByUid("alt", "phone-1").Return(contacts.Contact{}, contacts.ContactNotFoundError{"contact doesn't exist"}).Times(1)
+	mockRep.EXPECT().CreateContact(gomock.Any()).DoAndReturn(func(c contacts.Contact) (contacts.Contact, error) {
+		c.Id = 1
+		return c, nil
+	}).Times(1)
+	_, created, err := contactsUC.PutContactByUid("alt", contact)
+	if err != nil || !created {
+		t.Errorf("Didn't create new contact: %v\n", err)
+	}
+
+	mockRep.EXPECT().GetContactByUid("alt", "phone-1").Return(contacts.Contact{Id: 1, Uid: "phone-1"}, nil).Times(1)
+	mockRep.EXPECT().UpdateContact(gomock.Any()).DoAndReturn(func(c contacts.Contact) (contacts.Contact, error) {
+		if c.Id != 1 || c.Owner != "alt" {
+			t.Errorf("Updated wrong contact: %v\n", c)
+		}
+		return c, nil
+	}).Times(1)
+	_, created, err = contactsUC.PutContactByUid("alt", contact)
+	if err != nil || created {
+		t.Errorf("Didn't update existing contact: %v\n", err)
+	}
+
+	_, _, err = contactsUC.PutContactByUid("alt", contacts.Contact{Name: "John"})
+	if _, ok := err.(contacts.InvalidContactError); !ok {
+		t.Errorf("Stored contact without uid: %v\n", err)
+	}
+}
+
+func TestDeleteContactByUid(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockContactsRepository(mockCtrl)
+	contactsUC := ContactsUseCase{mockRep}
+
+	mockRep.EXPECT().GetContactByUid("alt", "phone-1").Return(contacts.Contact{Id: 3, Uid: "phone-1"}, nil).Times(1)
+	mockRep.EXPECT().DeleteContact("alt", 3).Return(nil).Times(1)
+	err := contactsUC.DeleteContactByUid("alt", "phone-1")
+	if err != nil {
+		t.Errorf("Didn't delete contact: %v\n", err)
+	}
+
+	mockRep.EXPECT().GetContactByUid("alt", "phone-2").Return(contacts.Contact{}, contacts.ContactNotFoundError{"contact doesn't exist"}).Times(1)
+	err = contactsUC.DeleteContactByUid("alt", "phone-2")
+	if _, ok := err.(contacts.ContactNotFoundError); !ok {
+		t.Errorf("Deleted not existing contact: %v\n", err)
+	}
+}
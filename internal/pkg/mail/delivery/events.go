@@ -0,0 +1,228 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+	"liokor_mail/internal/pkg/mail"
+	"liokor_mail/internal/pkg/user"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keeps idle SSE connections from being closed by proxies
+const EVENTS_HEARTBEAT_INTERVAL = 30 * time.Second
+
+// events not read by a slow client are dropped
+const EVENTS_BUFFER_SIZE = 16
+
+type subscription struct {
+	owner   string
+	ownerId int
+}
+
+// EventHub delivers events of the listener to the connected clients of their owners
+type EventHub struct {
+	mutex       sync.Mutex
+	subscribers map[chan mail.Event]subscription
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{
+		subscribers: make(map[chan mail.Event]subscription),
+	}
+}
+
+// Subscribe returns channel of events of the mailbox owner and of folders of the user ownerId
+func (h *EventHub) Subscribe(owner string, ownerId int) chan mail.Event {
+	events := make(chan mail.Event, EVENTS_BUFFER_SIZE)
+	h.mutex.Lock()
+	h.subscribers[events] = subscription{owner, ownerId}
+	h.mutex.Unlock()
+	return events
+}
+
+func (h *EventHub) Unsubscribe(events chan mail.Event) {
+	h.mutex.Lock()
+	delete(h.subscribers, events)
+	h.mutex.Unlock()
+}
+
+func (h *EventHub) Publish(event mail.Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for events, s := range h.subscribers {
+		if event.OwnerId != 0 && event.OwnerId != s.ownerId {
+			continue
+		}
+		if event.Owner != "" && !strings.EqualFold(event.Owner, s.owner) {
+			continue
+		}
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+type EventsHandler struct {
+	MailHandler
+	Hub           *EventHub
+	AllowedOrigin string // empty allows any origin
+}
+
+// Events streams mailbox events over WebSocket or, for clients not asking for upgrade, as server-sent events
+func (h *EventsHandler) Events(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	events := h.Hub.Subscribe(owner, sessionUser.Id)
+	defer h.Hub.Unsubscribe(events)
+
+	if c.IsWebSocket() {
+		h.streamWebSocket(c, events)
+		return nil
+	}
+	return h.streamSSE(c, events)
+}
+
+func (h *EventsHandler) streamWebSocket(c echo.Context, events chan mail.Event) {
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			// the session cookie is sent by any site, so only the frontend may connect
+			if h.AllowedOrigin != "" && r.Header.Get("Origin") != h.AllowedOrigin {
+				return errors.New("origin not allowed")
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			// clients don't send anything, reading only detects closed connection
+			closed := make(chan struct{})
+			go func() {
+				var message string
+				for websocket.Message.Receive(ws, &message) == nil {
+				}
+				close(closed)
+			}()
+			for {
+				select {
+				case event := <-events:
+					if websocket.JSON.Send(ws, event) != nil {
+						return
+					}
+				case <-closed:
+					return
+				}
+			}
+		},
+	}
+	server.ServeHTTP(c.Response(), c.Request())
+}
+
+func openEventStream(c echo.Context) *echo.Response {
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Header().Set("Connection", "keep-alive")
+	response.WriteHeader(http.StatusOK)
+	response.Flush()
+	return response
+}
+
+func (h *EventsHandler) streamSSE(c echo.Context, events chan mail.Event) error {
+	response := openEventStream(c)
+
+	heartbeat := time.NewTicker(EVENTS_HEARTBEAT_INTERVAL)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(response, "event: %s\ndata: %s\n\n", event.Type, data)
+			if err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			_, err := fmt.Fprint(response, ":\n\n")
+			if err != nil {
+				return nil
+			}
+		case <-c.Request().Context().Done():
+			return nil
+		}
+		response.Flush()
+	}
+}
+
+// JMAPEventSource streams JMAP StateChange objects of the account on every mailbox event
+func (h *EventsHandler) JMAPEventSource(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	ping := EVENTS_HEARTBEAT_INTERVAL
+	if seconds, err := strconv.Atoi(c.QueryParam("ping")); err == nil && seconds > 0 {
+		ping = time.Duration(seconds) * time.Second
+	}
+	closeAfterState := c.QueryParam("closeafter") == "state"
+
+	events := h.Hub.Subscribe(sessionUser.Username, sessionUser.Id)
+	defer h.Hub.Unsubscribe(events)
+	response := openEventStream(c)
+
+	heartbeat := time.NewTicker(ping)
+	defer heartbeat.Stop()
+	ctx := &jmapContext{owner: sessionUser.Username, ownerId: sessionUser.Id}
+	for {
+		select {
+		case event := <-events:
+			emailState, err := h.MailUsecase.GetSyncToken(ctx.owner, ctx.ownerId)
+			if err != nil {
+				return nil
+			}
+			_, mailboxState, err := h.jmapMailboxes(ctx)
+			if err != nil {
+				return nil
+			}
+			changed := map[string]string{"Email": emailState, "Thread": emailState, "Mailbox": mailboxState}
+			if event.Type == mail.EventNewMail {
+				changed["EmailDelivery"] = emailState
+			}
+			data, _ := json.Marshal(echo.Map{
+				"@type":   "StateChange",
+				"changed": map[string]interface{}{ctx.owner: changed},
+			})
+			_, err = fmt.Fprintf(response, "event: state\ndata: %s\n\n", data)
+			if err != nil || closeAfterState {
+				response.Flush()
+				return nil
+			}
+		case <-heartbeat.C:
+			_, err := fmt.Fprintf(response, "event: ping\ndata: {\"interval\":%d}\n\n", int(ping/time.Second))
+			if err != nil {
+				return nil
+			}
+		case <-c.Request().Context().Done():
+			return nil
+		}
+		response.Flush()
+	}
+}
@@ -0,0 +1,127 @@
+package delivery
+
+import (
+	"bufio"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+	"liokor_mail/internal/pkg/mail"
+	mailMocks "liokor_mail/internal/pkg/mail/mocks"
+	"liokor_mail/internal/pkg/user"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEventHub(t *testing.T) {
+	hub := NewEventHub()
+	events := hub.Subscribe("liokor", 1)
+	defer hub.Unsubscribe(events)
+
+	hub.Publish(mail.Event{Type: mail.EventNewMail, Owner: "other", Dialogue: "a@liokor.ru"})
+	hub.Publish(mail.Event{Type: mail.EventFolder, OwnerId: 2, FolderId: 1})
+	hub.Publish(mail.Event{Type: mail.EventRead, Owner: "Liokor", Dialogue: "a@liokor.ru"})
+	hub.Publish(mail.Event{Type: mail.EventFolder, OwnerId: 1, FolderId: 3})
+
+	expected := []mail.Event{
+		{Type: mail.EventRead, Owner: "Liokor", Dialogue: "a@liokor.ru"},
+		{Type: mail.EventFolder, OwnerId: 1, FolderId: 3},
+	}
+	for _, e := range expected {
+		event := <-events
+		if !reflect.DeepEqual(event, e) {
+			t.Errorf("Wrong event: %v, expected: %v\n", event, e)
+		}
+	}
+	select {
+	case event := <-events:
+		t.Errorf("Unexpected event: %v\n", event)
+	default:
+	}
+
+	// full buffer of a slow client doesn't block others
+	for i := 0; i < EVENTS_BUFFER_SIZE+1; i++ {
+		hub.Publish(mail.Event{Type: mail.EventNewMail, Owner: "liokor"})
+	}
+	if len(events) != EVENTS_BUFFER_SIZE {
+		t.Errorf("Wrong buffered events: %d\n", len(events))
+	}
+}
+
+func eventsServer(t *testing.T, allowedOrigin string) (*httptest.Server, *EventHub) {
+	mockCtrl := gomock.NewController(t)
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	hub := NewEventHub()
+	eventsHandler := EventsHandler{MailHandler{mockMailUC}, hub, allowedOrigin}
+
+	e := echo.New()
+	e.GET("/email/events", eventsHandler.Events, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("sessionUser", user.User{Id: 1, Username: "liokor"})
+			return next(c)
+		}
+	})
+	return httptest.NewServer(e), hub
+}
+
+func TestEventsSSE(t *testing.T) {
+	server, hub := eventsServer(t, "")
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/email/events")
+	if err != nil {
+		t.Fatalf("Unable to connect: %v\n", err)
+	}
+	defer response.Body.Close()
+	if response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Wrong content type: %s\n", response.Header.Get("Content-Type"))
+	}
+
+	hub.Publish(mail.Event{Type: mail.EventDeleted, Owner: "liokor", MailIds: []int{1, 2}})
+	reader := bufio.NewReader(response.Body)
+	var lines []string
+	for len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Unable to read event: %v\n", err)
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	expected := []string{
+		"event: deleted",
+		`data: {"type":"deleted","owner":"liokor","mails":[1,2]}`,
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Wrong event: %v\n", lines)
+	}
+}
+
+func TestEventsWebSocket(t *testing.T) {
+	server, hub := eventsServer(t, "https://liokor.ru")
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/email/events"
+
+	_, err := websocket.Dial(url, "", "https://evil.com")
+	if err == nil {
+		t.Errorf("Connected from not allowed origin\n")
+	}
+
+	ws, err := websocket.Dial(url, "", "https://liokor.ru")
+	if err != nil {
+		t.Fatalf("Unable to connect: %v\n", err)
+	}
+	defer ws.Close()
+
+	sent := mail.Event{Type: mail.EventNewMail, Owner: "liokor", Dialogue: "a@liokor.ru", MailIds: []int{5}}
+	hub.Publish(sent)
+	var event mail.Event
+	err = websocket.JSON.Receive(ws, &event)
+	if err != nil {
+		t.Fatalf("Unable to receive event: %v\n", err)
+	}
+	if !reflect.DeepEqual(event, sent) {
+		t.Errorf("Wrong event: %v\n", event)
+	}
+}
@@ -3,11 +3,14 @@ package delivery
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/labstack/echo/v4"
+	"liokor_mail/internal/pkg/common"
 	"liokor_mail/internal/pkg/mail"
 	"liokor_mail/internal/pkg/user"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,16 +18,76 @@ type MailHandler struct {
 	MailUsecase mail.MailUseCase
 }
 
+// mailboxOwner returns username whose mail is managed by the request:
+// the session user or the shared mailbox from "mailbox" query parameter the user is member of
+func (h *MailHandler) mailboxOwner(c echo.Context, sessionUser user.User) (string, error) {
+	mailbox := c.QueryParam("mailbox")
+	if mailbox == "" || strings.EqualFold(mailbox, sessionUser.Username) {
+		return sessionUser.Username, nil
+	}
+
+	err := h.MailUsecase.CheckMailboxAccess(mailbox, sessionUser.Username)
+	if err != nil {
+		switch err.(type) {
+		case mail.AccessDeniedError:
+			return "", echo.NewHTTPError(http.StatusForbidden, err.Error())
+		default:
+			return "", echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+	return mailbox, nil
+}
+
+// mailboxOwnerId returns username and id of the mailbox owner as mailboxOwner does, folders belong to the id
+func (h *MailHandler) mailboxOwnerId(c echo.Context, sessionUser user.User) (string, int, error) {
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return "", 0, err
+	}
+	if owner == sessionUser.Username {
+		return owner, sessionUser.Id, nil
+	}
+	ownerId, err := h.MailUsecase.GetUserId(owner)
+	if err != nil {
+		return "", 0, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return owner, ownerId, nil
+}
+
+// listPage reads "cursor" and "amount" query parameters of the list page, the first page is requested without cursor
+func listPage(c echo.Context) (mail.Page, error) {
+	page := mail.Page{Limit: mail.DefaultPageSize}
+	if amount := c.QueryParam("amount"); amount != "" {
+		limit, err := strconv.Atoi(amount)
+		if err != nil || limit < 1 || limit > mail.MaxPageSize {
+			return mail.Page{}, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("amount must be from 1 to %d", mail.MaxPageSize))
+		}
+		page.Limit = limit
+	}
+	if cursor := c.QueryParam("cursor"); cursor != "" {
+		parsed, err := mail.ParseCursor(cursor)
+		if err != nil {
+			return mail.Page{}, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		page.Cursor = &parsed
+	}
+	return page, nil
+}
+
 func (h *MailHandler) GetDialogues(c echo.Context) error {
 	sUser := c.Get("sessionUser")
 	sessionUser, ok := sUser.(user.User)
 	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
 
-	amount, err := strconv.Atoi(c.QueryParam("amount"))
-	if err != nil || amount > 50 {
-		amount = 50
+	page, err := listPage(c)
+	if err != nil {
+		return err
 	}
 
 	find := c.QueryParam("find")
@@ -34,17 +97,19 @@ func (h *MailHandler) GetDialogues(c echo.Context) error {
 		folder = 0
 	}
 
-	since := c.QueryParam("since")
-	var sinceTime time.Time
-	if since == "" {
-		sinceTime = time.Now()
-	} else {
-		sinceTime, err = time.Parse(time.RFC3339, since)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	var dialogues mail.DialoguesPage
+	if smart, errSmart := strconv.Atoi(c.QueryParam("smart")); errSmart == nil {
+		dialogues, err = h.MailUsecase.GetSmartFolderDialogues(owner, smart, page)
+		if _, ok := err.(mail.InvalidEmailError); ok {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
 		}
+	} else if c.QueryParam("snoozed") == "true" {
+		dialogues, err = h.MailUsecase.GetSnoozedDialogues(owner, page)
+	} else if c.QueryParam("archived") == "true" {
+		dialogues, err = h.MailUsecase.GetArchivedDialogues(owner, page)
+	} else {
+		dialogues, err = h.MailUsecase.GetDialogues(owner, find, folder, page)
 	}
-	dialogues, err := h.MailUsecase.GetDialogues(sessionUser.Username, amount, find, folder, sinceTime)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
@@ -58,17 +123,21 @@ func (h *MailHandler) CreateDialogue(c echo.Context) error {
 	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
 	var dialogueWith struct {
 		With string `json:"username"`
 	}
 	defer c.Request().Body.Close()
 
-	err := json.NewDecoder(c.Request().Body).Decode(&dialogueWith)
+	err = json.NewDecoder(c.Request().Body).Decode(&dialogueWith)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	dialogue, err := h.MailUsecase.CreateDialogue(sessionUser.Username, dialogueWith.With)
+	dialogue, err := h.MailUsecase.CreateDialogue(owner, dialogueWith.With)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
@@ -82,18 +151,22 @@ func (h *MailHandler) DeleteDialogue(c echo.Context) error {
 	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
 
 	var deleteDialogue struct {
 		DialogueId int `json:"id"`
 	}
 	defer c.Request().Body.Close()
 
-	err := json.NewDecoder(c.Request().Body).Decode(&deleteDialogue)
+	err = json.NewDecoder(c.Request().Body).Decode(&deleteDialogue)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	err = h.MailUsecase.DeleteDialogue(sessionUser.Username, deleteDialogue.DialogueId)
+	err = h.MailUsecase.DeleteDialogue(owner, deleteDialogue.DialogueId)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
@@ -101,184 +174,2070 @@ func (h *MailHandler) DeleteDialogue(c echo.Context) error {
 	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Dialogue deleted"})
 }
 
-func (h *MailHandler) DeleteMail(c echo.Context) error {
+func (h *MailHandler) SnoozeDialogue(c echo.Context) error {
 	sUser := c.Get("sessionUser")
 	sessionUser, ok := sUser.(user.User)
 	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
 
-	var idsToDelete struct {
-		Ids []int `json:"ids"`
+	var snooze struct {
+		DialogueId int       `json:"id"`
+		Until      time.Time `json:"until"`
 	}
 	defer c.Request().Body.Close()
 
-	err := json.NewDecoder(c.Request().Body).Decode(&idsToDelete)
+	err = json.NewDecoder(c.Request().Body).Decode(&snooze)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	err = h.MailUsecase.DeleteMails(sessionUser.Username, idsToDelete.Ids)
+	err = h.MailUsecase.SnoozeDialogue(owner, snooze.DialogueId, snooze.Until)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
 	}
 
-	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Mails deleted"})
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Dialogue snoozed"})
 }
 
-func (h *MailHandler) GetEmails(c echo.Context) error {
+func (h *MailHandler) UnsnoozeDialogue(c echo.Context) error {
 	sUser := c.Get("sessionUser")
 	sessionUser, ok := sUser.(user.User)
 	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
 
-	email := c.QueryParam("with")
-	if email == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, errors.New("invalid email"))
+	var unsnooze struct {
+		DialogueId int `json:"id"`
 	}
+	defer c.Request().Body.Close()
 
-	last, err := strconv.Atoi(c.QueryParam("since"))
+	err = json.NewDecoder(c.Request().Body).Decode(&unsnooze)
 	if err != nil {
-		last = 0
-	}
-	amount, err := strconv.Atoi(c.QueryParam("amount"))
-	if err != nil || amount > 50 {
-		amount = 50
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
-	emails, err := h.MailUsecase.GetEmails(sessionUser.Username, email, last, amount)
+
+	err = h.MailUsecase.UnsnoozeDialogue(owner, unsnooze.DialogueId)
 	if err != nil {
 		switch err.(type) {
 		case mail.InvalidEmailError:
-			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
 		default:
 			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 		}
 	}
 
-	return c.JSON(http.StatusOK, emails)
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Dialogue unsnoozed"})
 }
 
-func (h *MailHandler) SendEmail(c echo.Context) error {
+func (h *MailHandler) PinDialogue(c echo.Context) error {
 	sUser := c.Get("sessionUser")
 	sessionUser, ok := sUser.(user.User)
 	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
 
-	newMail := mail.Mail{}
-
+	var pin struct {
+		DialogueId int  `json:"id"`
+		Pinned     bool `json:"pinned"`
+	}
 	defer c.Request().Body.Close()
 
-	err := json.NewDecoder(c.Request().Body).Decode(&newMail)
+	err = json.NewDecoder(c.Request().Body).Decode(&pin)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
-	newMail.Sender = sessionUser.Username
 
-	email, err := h.MailUsecase.SendEmail(newMail)
+	err = h.MailUsecase.PinDialogue(owner, pin.DialogueId, pin.Pinned)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
 	}
-	return c.JSON(http.StatusOK, email)
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Dialogue updated"})
 }
 
-func (h *MailHandler) GetFolders(c echo.Context) error {
+func (h *MailHandler) MuteDialogue(c echo.Context) error {
 	sUser := c.Get("sessionUser")
 	sessionUser, ok := sUser.(user.User)
 	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
 
-	folders, err := h.MailUsecase.GetFolders(sessionUser.Id)
+	var mute struct {
+		DialogueId int  `json:"id"`
+		Muted      bool `json:"muted"`
+	}
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&mute)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	return c.JSON(http.StatusOK, folders)
+	err = h.MailUsecase.MuteDialogue(owner, mute.DialogueId, mute.Muted)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Dialogue updated"})
 }
 
-func (h *MailHandler) CreateFolder(c echo.Context) error {
+func (h *MailHandler) DeleteMail(c echo.Context) error {
 	sUser := c.Get("sessionUser")
 	sessionUser, ok := sUser.(user.User)
 	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
-	var folderName struct {
-		FolderName string `json:"name"`
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var idsToDelete struct {
+		Ids []int `json:"ids"`
 	}
 	defer c.Request().Body.Close()
 
-	err := json.NewDecoder(c.Request().Body).Decode(&folderName)
+	err = json.NewDecoder(c.Request().Body).Decode(&idsToDelete)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	folder, err := h.MailUsecase.CreateFolder(sessionUser.Id, folderName.FolderName)
+	err = h.MailUsecase.DeleteMails(owner, idsToDelete.Ids)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	return c.JSON(http.StatusCreated, folder)
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Mails deleted"})
 }
 
-func (h *MailHandler) UpdateFolder(c echo.Context) error {
+// Bulk applies the action to the listed mails or dialogues, results tell which of them were changed
+func (h *MailHandler) Bulk(c echo.Context) error {
 	sUser := c.Get("sessionUser")
 	sessionUser, ok := sUser.(user.User)
 	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
-
-	var updateFolder struct {
-		FolderId   int     `json:"folderId"`
-		DialogueId *int    `json:"dialogueId"`
-		FolderName *string `json:"name"`
+	// dialogues are moved to folders of the mailbox they belong to
+	owner, ownerId, err := h.mailboxOwnerId(c, sessionUser)
+	if err != nil {
+		return err
 	}
+
+	var bulk mail.Bulk
 	defer c.Request().Body.Close()
 
-	err := json.NewDecoder(c.Request().Body).Decode(&updateFolder)
+	err = json.NewDecoder(c.Request().Body).Decode(&bulk)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	if updateFolder.FolderName != nil {
-		folder, err := h.MailUsecase.UpdateFolderName(sessionUser.Id, updateFolder.FolderId, *updateFolder.FolderName)
-		if err != nil {
+	results, err := h.MailUsecase.Bulk(owner, ownerId, bulk)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
 			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 		}
+	}
 
-		return c.JSON(http.StatusOK, folder)
+	return c.JSON(http.StatusOK, results)
+}
+
+func (h *MailHandler) GetEmails(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	email := c.QueryParam("with")
+	if email == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.New("invalid email"))
 	}
 
-	err = h.MailUsecase.UpdateFolderPutDialogue(sessionUser.Username, updateFolder.FolderId, *updateFolder.DialogueId)
+	page, err := listPage(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
+	}
+	emails, err := h.MailUsecase.GetEmails(owner, email, page)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
 	}
 
-	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Dialogue was added to folder"})
+	return c.JSON(http.StatusOK, emails)
 }
 
-func (h *MailHandler) DeleteFolder(c echo.Context) error {
+// Sync returns ids of mails, dialogues and folders changed since the token,
+// request without token returns everything
+func (h *MailHandler) Sync(c echo.Context) error {
 	sUser := c.Get("sessionUser")
 	sessionUser, ok := sUser.(user.User)
 	if !ok {
 		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
+	owner, ownerId, err := h.mailboxOwnerId(c, sessionUser)
+	if err != nil {
+		return err
+	}
 
-	var deleteFolder struct {
-		FolderId int `json:"id"`
+	changes, err := h.MailUsecase.Sync(owner, ownerId, c.QueryParam("since"))
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, changes)
+}
+
+func (h *MailHandler) SendEmail(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
 	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	newMail := mail.Mail{}
+
 	defer c.Request().Body.Close()
 
-	err := json.NewDecoder(c.Request().Body).Decode(&deleteFolder)
+	err = json.NewDecoder(c.Request().Body).Decode(&newMail)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
+	newMail.Sender = owner
 
-	err = h.MailUsecase.DeleteFolder(sessionUser.Username, sessionUser.Id, deleteFolder.FolderId)
+	email, err := h.MailUsecase.SendEmail(newMail)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
+	return c.JSON(http.StatusOK, email)
+}
 
-	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Folder deleted"})
+func (h *MailHandler) PreviewEmail(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	email := mail.Mail{}
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&email)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	preview, err := h.MailUsecase.PreviewEmail(owner, email)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+	return c.JSON(http.StatusOK, preview)
+}
+
+func (h *MailHandler) ReplyEmail(c echo.Context) error {
+	return h.replyEmail(c, false)
+}
+
+func (h *MailHandler) ReplyAllEmail(c echo.Context) error {
+	return h.replyEmail(c, true)
+}
+
+func (h *MailHandler) replyEmail(c echo.Context, all bool) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	mailId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	reply := mail.Mail{}
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&reply)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	emails, err := h.MailUsecase.ReplyEmail(owner, mailId, reply, all)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+	return c.JSON(http.StatusOK, emails)
+}
+
+func (h *MailHandler) ForwardEmail(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	mailId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	forward := mail.Mail{}
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&forward)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	email, err := h.MailUsecase.ForwardEmail(owner, mailId, forward)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+	return c.JSON(http.StatusOK, email)
+}
+
+func (h *MailHandler) GetFolders(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	owner, ownerId, err := h.mailboxOwnerId(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	page, err := listPage(c)
+	if err != nil {
+		return err
+	}
+
+	folders, err := h.MailUsecase.GetFoldersPage(owner, ownerId, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, folders)
+}
+
+func (h *MailHandler) CreateFolder(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	// folders of the shared mailbox belong to the mailbox
+	_, ownerId, err := h.mailboxOwnerId(c, sessionUser)
+	if err != nil {
+		return err
+	}
+	var folderName struct {
+		FolderName string `json:"name"`
+		Parent     int    `json:"parent"`
+	}
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&folderName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	folder, err := h.MailUsecase.CreateFolder(ownerId, folderName.FolderName, folderName.Parent)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusCreated, folder)
+}
+
+func (h *MailHandler) UpdateFolder(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	// folders of the shared mailbox belong to the mailbox
+	owner, ownerId, err := h.mailboxOwnerId(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var updateFolder struct {
+		FolderId   int     `json:"folderId"`
+		DialogueId *int    `json:"dialogueId"`
+		FolderName *string `json:"name"`
+		Parent     *int    `json:"parent"`
+		Position   *int    `json:"position"`
+	}
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&updateFolder)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if updateFolder.FolderName != nil {
+		folder, err := h.MailUsecase.UpdateFolderName(ownerId, updateFolder.FolderId, *updateFolder.FolderName)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return c.JSON(http.StatusOK, folder)
+	}
+
+	// parent and position are set together, the missing one is 0
+	if updateFolder.Parent != nil || updateFolder.Position != nil {
+		var parent, position int
+		if updateFolder.Parent != nil {
+			parent = *updateFolder.Parent
+		}
+		if updateFolder.Position != nil {
+			position = *updateFolder.Position
+		}
+		folder, err := h.MailUsecase.MoveFolder(ownerId, updateFolder.FolderId, parent, position)
+		if err != nil {
+			switch err.(type) {
+			case mail.InvalidEmailError:
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			default:
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+		}
+
+		return c.JSON(http.StatusOK, folder)
+	}
+
+	err = h.MailUsecase.UpdateFolderPutDialogue(owner, updateFolder.FolderId, *updateFolder.DialogueId)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Dialogue was added to folder"})
+}
+
+func (h *MailHandler) DeleteFolder(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	// folders of the shared mailbox belong to the mailbox
+	owner, ownerId, err := h.mailboxOwnerId(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var deleteFolder struct {
+		FolderId int `json:"id"`
+	}
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&deleteFolder)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.DeleteFolder(owner, ownerId, deleteFolder.FolderId)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Folder deleted"})
+}
+
+func (h *MailHandler) CreateSmartFolder(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var newFolder mail.SmartFolder
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&newFolder)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	folder, err := h.MailUsecase.CreateSmartFolder(owner, newFolder)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusCreated, folder)
+}
+
+func (h *MailHandler) UpdateSmartFolder(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var updatedFolder mail.SmartFolder
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&updatedFolder)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	folder, err := h.MailUsecase.UpdateSmartFolder(owner, updatedFolder)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, folder)
+}
+
+func (h *MailHandler) DeleteSmartFolder(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var deleteFolder struct {
+		FolderId int `json:"id"`
+	}
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&deleteFolder)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.DeleteSmartFolder(owner, deleteFolder.FolderId)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Folder deleted"})
+}
+
+func (h *MailHandler) GetVacation(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	vacation, err := h.MailUsecase.GetVacation(sessionUser.Username)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, vacation)
+}
+
+func (h *MailHandler) UpdateVacation(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	newVacation := mail.Vacation{}
+
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&newVacation)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	vacation, err := h.MailUsecase.UpdateVacation(sessionUser.Username, newVacation)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, vacation)
+}
+
+type readReceipts struct {
+	SendReadReceipts bool `json:"sendReadReceipts"`
+}
+
+func (h *MailHandler) GetReadReceipts(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	allow, err := h.MailUsecase.GetReadReceipts(sessionUser.Username)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, readReceipts{allow})
+}
+
+func (h *MailHandler) UpdateReadReceipts(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var receipts readReceipts
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&receipts)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.UpdateReadReceipts(sessionUser.Username, receipts.SendReadReceipts)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, receipts)
+}
+
+func (h *MailHandler) GetForwarding(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	forwarding, err := h.MailUsecase.GetForwarding(sessionUser.Username)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, forwarding)
+}
+
+func (h *MailHandler) UpdateForwarding(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	newForwarding := mail.Forwarding{}
+
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&newForwarding)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	forwarding, err := h.MailUsecase.UpdateForwarding(sessionUser.Username, newForwarding)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, forwarding)
+}
+
+func (h *MailHandler) ConfirmForwarding(c echo.Context) error {
+	err := h.MailUsecase.ConfirmForwarding(c.QueryParam("token"))
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Forwarding confirmed"})
+}
+
+func (h *MailHandler) GetAliases(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	aliases, err := h.MailUsecase.GetAliases(sessionUser.Username)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, aliases)
+}
+
+func (h *MailHandler) CreateAlias(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var newAlias struct {
+		Alias string `json:"alias"`
+	}
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&newAlias)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	alias, err := h.MailUsecase.CreateAlias(sessionUser.Username, newAlias.Alias)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusCreated, alias)
+}
+
+func (h *MailHandler) DeleteAlias(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var deleteAlias struct {
+		AliasId int `json:"id"`
+	}
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&deleteAlias)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.DeleteAlias(sessionUser.Username, deleteAlias.AliasId)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Alias deleted"})
+}
+
+func (h *MailHandler) GetDomains(c echo.Context) error {
+	domains, err := h.MailUsecase.GetDomains()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, domains)
+}
+
+func (h *MailHandler) GetMailboxes(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	mailboxes, err := h.MailUsecase.GetMailboxes(sessionUser.Username)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, mailboxes)
+}
+
+func (h *MailHandler) CreateMailbox(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var newMailbox struct {
+		Name string `json:"name"`
+	}
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&newMailbox)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	mailbox, err := h.MailUsecase.CreateMailbox(sessionUser.Username, newMailbox.Name)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusCreated, mailbox)
+}
+
+func (h *MailHandler) GetMailboxMembers(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	members, err := h.MailUsecase.GetMailboxMembers(sessionUser.Username, c.QueryParam("mailbox"))
+	if err != nil {
+		switch err.(type) {
+		case mail.AccessDeniedError:
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, members)
+}
+
+func (h *MailHandler) AddMailboxMember(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	newMember := mail.MailboxMember{}
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&newMember)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.AddMailboxMember(sessionUser.Username, newMember)
+	if err != nil {
+		switch err.(type) {
+		case mail.AccessDeniedError:
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		case mail.InvalidEmailError, common.InvalidUserError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusCreated, newMember)
+}
+
+func (h *MailHandler) DeleteMailboxMember(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	member := mail.MailboxMember{}
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&member)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.DeleteMailboxMember(sessionUser.Username, member.Mailbox, member.Member)
+	if err != nil {
+		switch err.(type) {
+		case mail.AccessDeniedError:
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Member deleted"})
+}
+
+func (h *MailHandler) GetDistributionLists(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	lists, err := h.MailUsecase.GetDistributionLists(sessionUser.Username)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, lists)
+}
+
+func (h *MailHandler) CreateDistributionList(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var newList struct {
+		Name string `json:"name"`
+	}
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&newList)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	list, err := h.MailUsecase.CreateDistributionList(sessionUser.Username, newList.Name)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusCreated, list)
+}
+
+func (h *MailHandler) DeleteDistributionList(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var deleteList struct {
+		ListId int `json:"id"`
+	}
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&deleteList)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.DeleteDistributionList(sessionUser.Username, deleteList.ListId)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "List deleted"})
+}
+
+func (h *MailHandler) UpdateListPosting(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var updateList struct {
+		ListId  int    `json:"id"`
+		Posting string `json:"posting"`
+	}
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&updateList)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.UpdateListPosting(sessionUser.Username, updateList.ListId, updateList.Posting)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "List updated"})
+}
+
+type listMember struct {
+	ListId  int    `json:"listId"`
+	Address string `json:"address"`
+}
+
+func (h *MailHandler) AddListMember(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	member := listMember{}
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&member)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.AddListMember(sessionUser.Username, member.ListId, member.Address)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusCreated, member)
+}
+
+func (h *MailHandler) DeleteListMember(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	member := listMember{}
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&member)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.DeleteListMember(sessionUser.Username, member.ListId, member.Address)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Member deleted"})
+}
+
+func (h *MailHandler) GetSenderFilter(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	filter, err := h.MailUsecase.GetSenderFilter(sessionUser.Username)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, filter)
+}
+
+func (h *MailHandler) UpdateSenderFilter(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var filter mail.SenderFilter
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.UpdateSenderFilter(sessionUser.Username, filter.DropBlocked)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Sender filter updated"})
+}
+
+func (h *MailHandler) AddSenderRule(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var newRule mail.SenderRule
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&newRule)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	rule, err := h.MailUsecase.AddSenderRule(sessionUser.Username, newRule)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusCreated, rule)
+}
+
+func (h *MailHandler) DeleteSenderRule(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var deleteRule struct {
+		RuleId int `json:"id"`
+	}
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&deleteRule)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.DeleteSenderRule(sessionUser.Username, deleteRule.RuleId)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Rule deleted"})
+}
+
+func (h *MailHandler) GetGlobalBlocklist(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	rules, err := h.MailUsecase.GetGlobalBlocklist(sessionUser.Username)
+	if err != nil {
+		switch err.(type) {
+		case mail.AccessDeniedError:
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, rules)
+}
+
+func (h *MailHandler) AddGlobalBlock(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var newRule mail.SenderRule
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&newRule)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	rule, err := h.MailUsecase.AddGlobalBlock(sessionUser.Username, newRule.Pattern)
+	if err != nil {
+		switch err.(type) {
+		case mail.AccessDeniedError:
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusCreated, rule)
+}
+
+func (h *MailHandler) DeleteGlobalBlock(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var deleteRule struct {
+		RuleId int `json:"id"`
+	}
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&deleteRule)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.DeleteGlobalBlock(sessionUser.Username, deleteRule.RuleId)
+	if err != nil {
+		switch err.(type) {
+		case mail.AccessDeniedError:
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Rule deleted"})
+}
+
+func (h *MailHandler) GetSignatures(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	signatures, err := h.MailUsecase.GetSignatures(owner)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, signatures)
+}
+
+func (h *MailHandler) CreateSignature(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var newSignature mail.Signature
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&newSignature)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	signature, err := h.MailUsecase.CreateSignature(owner, newSignature)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusCreated, signature)
+}
+
+func (h *MailHandler) UpdateSignature(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var updatedSignature mail.Signature
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&updatedSignature)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	signature, err := h.MailUsecase.UpdateSignature(owner, updatedSignature)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, signature)
+}
+
+func (h *MailHandler) DeleteSignature(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var deleteSignature struct {
+		Id int `json:"id"`
+	}
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&deleteSignature)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.DeleteSignature(owner, deleteSignature.Id)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Signature deleted"})
+}
+
+func (h *MailHandler) GetTemplates(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	templates, err := h.MailUsecase.GetTemplates(owner)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, templates)
+}
+
+func (h *MailHandler) CreateTemplate(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var newTemplate mail.Template
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&newTemplate)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	template, err := h.MailUsecase.CreateTemplate(owner, newTemplate)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusCreated, template)
+}
+
+func (h *MailHandler) UpdateTemplate(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var updatedTemplate mail.Template
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&updatedTemplate)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	template, err := h.MailUsecase.UpdateTemplate(owner, updatedTemplate)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, template)
+}
+
+func (h *MailHandler) DeleteTemplate(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var deleteTemplate struct {
+		Id int `json:"id"`
+	}
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&deleteTemplate)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.DeleteTemplate(owner, deleteTemplate.Id)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Template deleted"})
+}
+
+func (h *MailHandler) RenderTemplate(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var render struct {
+		Id        int    `json:"id"`
+		Recipient string `json:"recipient"`
+	}
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&render)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	rendered, err := h.MailUsecase.RenderTemplate(owner, render.Id, render.Recipient)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, rendered)
+}
+
+func (h *MailHandler) GetWebhooks(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	webhooks, err := h.MailUsecase.GetWebhooks(owner)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, webhooks)
+}
+
+func (h *MailHandler) CreateWebhook(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var newWebhook mail.Webhook
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&newWebhook)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	webhook, err := h.MailUsecase.CreateWebhook(owner, newWebhook)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusCreated, webhook)
+}
+
+func (h *MailHandler) DeleteWebhook(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var deleteWebhook struct {
+		Id int `json:"id"`
+	}
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&deleteWebhook)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.DeleteWebhook(owner, deleteWebhook.Id)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Webhook deleted"})
+}
+
+func (h *MailHandler) GetWebhookDeliveries(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	webhookId, err := strconv.Atoi(c.QueryParam("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid webhook id")
+	}
+	amount, err := strconv.Atoi(c.QueryParam("amount"))
+	if err != nil || amount > 50 {
+		amount = 50
+	}
+
+	deliveries, err := h.MailUsecase.GetWebhookDeliveries(owner, webhookId, amount)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+func (h *MailHandler) TestWebhook(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var testWebhook struct {
+		Id int `json:"id"`
+	}
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&testWebhook)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	delivery, err := h.MailUsecase.TestWebhook(owner, testWebhook.Id)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, delivery)
+}
+
+func (h *MailHandler) GetExports(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	exports, err := h.MailUsecase.GetExports(owner)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, exports)
+}
+
+// GetExport returns progress of the export and its download link when it's done
+func (h *MailHandler) GetExport(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	exportId, err := strconv.Atoi(c.QueryParam("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid export id")
+	}
+
+	export, err := h.MailUsecase.GetExport(owner, exportId)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, export)
+}
+
+func (h *MailHandler) CreateExport(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	owner, err := h.mailboxOwner(c, sessionUser)
+	if err != nil {
+		return err
+	}
+
+	var newExport mail.Export
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&newExport)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	export, err := h.MailUsecase.CreateExport(owner, newExport)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusAccepted, export)
+}
+
+// DownloadExport serves the archive by the token of the download link, it doesn't need the session
+func (h *MailHandler) DownloadExport(c echo.Context) error {
+	export, path, err := h.MailUsecase.GetExportFile(c.Param("token"))
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.Attachment(path, export.FileName())
+}
+
+// GetImports lists imports to the own mailbox of the user
+func (h *MailHandler) GetImports(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	imports, err := h.MailUsecase.GetImports(sessionUser.Username)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, imports)
+}
+
+func (h *MailHandler) GetImport(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	importId, err := strconv.Atoi(c.QueryParam("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid import id")
+	}
+
+	mailImport, err := h.MailUsecase.GetImport(sessionUser.Username, importId)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mailImport)
+}
+
+// ImportArchive accepts multipart form with the archive in the file field and its source, mbox or eml
+func (h *MailHandler) ImportArchive(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "archive file is required")
+	}
+	archive, err := file.Open()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	defer archive.Close()
+
+	mailImport, err := h.MailUsecase.ImportArchive(sessionUser.Username, sessionUser.Id, c.FormValue("source"), archive)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusAccepted, mailImport)
+}
+
+func (h *MailHandler) ImportIMAP(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var account mail.IMAPAccount
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&account)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	mailImport, err := h.MailUsecase.ImportIMAP(sessionUser.Username, sessionUser.Id, account)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusAccepted, mailImport)
+}
+
+// ResumeImport continues the failed import, imap import needs the password again
+func (h *MailHandler) ResumeImport(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var resume struct {
+		Id       int    `json:"id"`
+		Password string `json:"password"`
+	}
+	defer c.Request().Body.Close()
+
+	err := json.NewDecoder(c.Request().Body).Decode(&resume)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	mailImport, err := h.MailUsecase.ResumeImport(sessionUser.Username, sessionUser.Id, resume.Id, resume.Password)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusAccepted, mailImport)
+}
+
+func (h *MailHandler) GetVapidPublicKey(c echo.Context) error {
+	key, err := h.MailUsecase.GetVapidPublicKey()
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"publicKey": key})
+}
+
+// SubscribePush registers browser push subscription of the current session
+func (h *MailHandler) SubscribePush(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	sessionToken, err := c.Cookie("session_token")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+
+	var subscription mail.PushSubscription
+	defer c.Request().Body.Close()
+
+	err = json.NewDecoder(c.Request().Body).Decode(&subscription)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = h.MailUsecase.SubscribePush(sessionUser.Username, sessionToken.Value, subscription)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case common.InvalidUserError:
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusCreated, mail.MessageResponse{Message: "Push subscription saved"})
+}
+
+func (h *MailHandler) UnsubscribePush(c echo.Context) error {
+	sessionToken, err := c.Cookie("session_token")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+
+	err = h.MailUsecase.UnsubscribePush(sessionToken.Value)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, mail.MessageResponse{Message: "Push subscription deleted"})
 }
@@ -10,8 +10,10 @@ import (
 	"liokor_mail/internal/pkg/mail"
 	mailMocks "liokor_mail/internal/pkg/mail/mocks"
 	"liokor_mail/internal/pkg/user"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -63,17 +65,20 @@ func TestGetDialogues(t *testing.T) {
 	}
 	echoContext.Set("sessionUser", sessionUser)
 
-	mockMailUC.EXPECT().GetDialogues(sessionUser.Username, 5, "a", 1, gomock.Any()).Return(dialogues, nil).Times(1)
+	mockMailUC.EXPECT().GetDialogues(sessionUser.Username, "a", 1, mail.Page{Limit: 5}).Return(mail.DialoguesPage{Dialogues: dialogues, PageInfo: mail.PageInfo{HasMore: true, NextCursor: "next"}}, nil).Times(1)
 	err := mailHandler.GetDialogues(echoContext)
 	if err != nil {
 		t.Errorf("Didn't pass valid data: %v\n", err)
 	}
 
-	dEmails := make([]mail.Dialogue, 0)
+	dEmails := mail.DialoguesPage{}
 	err = json.Unmarshal(response.Body.Bytes(), &dEmails)
 	if err != nil {
 		t.Errorf("Json error: %v\n", err.Error())
 	}
+	if len(dEmails.Dialogues) != 2 || !dEmails.HasMore || dEmails.NextCursor != "next" {
+		t.Errorf("Wrong page: %v\n", dEmails)
+	}
 
 	req = httptest.NewRequest("GET", url, nil)
 	req.Header.Add("Cookie", "session_token=sessionToken; Expires=Wed, 03 Jun 2021 03:30:48 GMT; HttpOnly")
@@ -81,7 +86,7 @@ func TestGetDialogues(t *testing.T) {
 	echoContext = e.NewContext(req, response)
 	echoContext.Set("sessionUser", sessionUser)
 
-	mockMailUC.EXPECT().GetDialogues(sessionUser.Username, 5, "a", 1, gomock.Any()).Return(nil, mail.InvalidEmailError{"Error"}).Times(1)
+	mockMailUC.EXPECT().GetDialogues(sessionUser.Username, "a", 1, mail.Page{Limit: 5}).Return(mail.DialoguesPage{}, mail.InvalidEmailError{"Error"}).Times(1)
 	err = mailHandler.GetDialogues(echoContext)
 	if httperr, ok := err.(*echo.HTTPError); ok {
 		if httperr.Code != http.StatusInternalServerError {
@@ -104,7 +109,8 @@ func TestGetEmails(t *testing.T) {
 
 	e := echo.New()
 
-	url := "/email/emails/?with=lio@liokor.ru&since=1&amount=5"
+	cursor := mail.Cursor{Date: time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC), Id: 3}
+	url := "/email/emails/?with=lio@liokor.ru&amount=5&cursor=" + cursor.String()
 	req := httptest.NewRequest("GET", url, nil)
 	req.Header.Add("Cookie", "session_token=sessionToken; Expires=Wed, 03 Jun 2021 03:30:48 GMT; HttpOnly")
 	response := httptest.NewRecorder()
@@ -142,7 +148,7 @@ func TestGetEmails(t *testing.T) {
 	}
 	echoContext.Set("sessionUser", sessionUser)
 
-	mockMailUC.EXPECT().GetEmails(sessionUser.Username, "lio@liokor.ru", 1, 5).Return(emails, nil).Times(1)
+	mockMailUC.EXPECT().GetEmails(sessionUser.Username, "lio@liokor.ru", mail.Page{Cursor: &cursor, Limit: 5}).Return(mail.EmailsPage{Emails: emails}, nil).Times(1)
 	err := mailHandler.GetEmails(echoContext)
 	if err != nil {
 		t.Errorf("Didn't pass valid data: %v\n", err)
@@ -155,7 +161,7 @@ func TestGetEmails(t *testing.T) {
 	echoContext = e.NewContext(req, response)
 	echoContext.Set("sessionUser", sessionUser)
 
-	mockMailUC.EXPECT().GetEmails(sessionUser.Username, "lio@liokor.ru", 0, 50).Return(nil, mail.InvalidEmailError{"error"}).Times(1)
+	mockMailUC.EXPECT().GetEmails(sessionUser.Username, "lio@liokor.ru", mail.Page{Limit: 100}).Return(mail.EmailsPage{}, mail.InvalidEmailError{"error"}).Times(1)
 	err = mailHandler.GetEmails(echoContext)
 	if httperr, ok := err.(*echo.HTTPError); ok {
 		if httperr.Code != http.StatusBadRequest {
@@ -164,6 +170,18 @@ func TestGetEmails(t *testing.T) {
 	} else {
 		t.Errorf("Didn't pass invalid data: %v\n", err)
 	}
+
+	for _, query := range []string{"amount=101", "amount=0", "cursor=abc"} {
+		req = httptest.NewRequest("GET", "/email/emails/?with=lio@liokor.ru&"+query, nil)
+		response = httptest.NewRecorder()
+		echoContext = e.NewContext(req, response)
+		echoContext.Set("sessionUser", sessionUser)
+
+		err = mailHandler.GetEmails(echoContext)
+		if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusBadRequest {
+			t.Errorf("Didn't pass invalid page %s: %v\n", query, err)
+		}
+	}
 }
 
 func TestSendEmail(t *testing.T) {
@@ -275,11 +293,23 @@ func TestGetFolders(t *testing.T) {
 	}
 	echoContext.Set("sessionUser", sessionUser)
 
-	mockMailUC.EXPECT().GetFolders(sessionUser.Id).Return(folders, nil).Times(1)
+	mockMailUC.EXPECT().GetFoldersPage(sessionUser.Username, sessionUser.Id, mail.Page{Limit: mail.DefaultPageSize}).Return(mail.FoldersPage{Folders: folders}, nil).Times(1)
 	err := mailHandler.GetFolders(echoContext)
 	if err != nil {
 		t.Errorf("Didn't get valid folders: %v\n", err.Error())
 	}
+
+	// regular and smart folders of the shared mailbox are both taken for the mailbox
+	req = httptest.NewRequest("GET", "/email/folders?mailbox=support", nil)
+	echoContext = e.NewContext(req, httptest.NewRecorder())
+	echoContext.Set("sessionUser", sessionUser)
+	mockMailUC.EXPECT().CheckMailboxAccess("support", "alt").Return(nil).Times(1)
+	mockMailUC.EXPECT().GetUserId("support").Return(9, nil).Times(1)
+	mockMailUC.EXPECT().GetFoldersPage("support", 9, mail.Page{Limit: mail.DefaultPageSize}).Return(mail.FoldersPage{}, nil).Times(1)
+	err = mailHandler.GetFolders(echoContext)
+	if err != nil {
+		t.Errorf("Didn't get folders of shared mailbox: %v\n", err)
+	}
 }
 
 func TestCreateFolder(t *testing.T) {
@@ -323,7 +353,7 @@ func TestCreateFolder(t *testing.T) {
 	}
 	echoContext.Set("sessionUser", sessionUser)
 
-	mockMailUC.EXPECT().CreateFolder(sessionUser.Id, folderName.FolderName).Return(folder, nil).Times(1)
+	mockMailUC.EXPECT().CreateFolder(sessionUser.Id, folderName.FolderName, 0).Return(folder, nil).Times(1)
 	err := mailHandler.CreateFolder(echoContext)
 	if err != nil {
 		t.Errorf("Didn't get valid folders: %v\n", err.Error())
@@ -334,7 +364,7 @@ func TestCreateFolder(t *testing.T) {
 	response = httptest.NewRecorder()
 	echoContext = e.NewContext(req, response)
 	echoContext.Set("sessionUser", sessionUser)
-	mockMailUC.EXPECT().CreateFolder(sessionUser.Id, folderName.FolderName).Return(mail.Folder{}, common.InvalidUserError{"User doesn't exist"}).Times(1)
+	mockMailUC.EXPECT().CreateFolder(sessionUser.Id, folderName.FolderName, 0).Return(mail.Folder{}, common.InvalidUserError{"User doesn't exist"}).Times(1)
 	err = mailHandler.CreateFolder(echoContext)
 	if httperr, ok := err.(*echo.HTTPError); ok {
 		if httperr.Code != http.StatusInternalServerError {
@@ -585,6 +615,81 @@ func TestDeleteMail(t *testing.T) {
 
 }
 
+func TestBulk(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+	sessionUser := user.User{
+		Id:       1,
+		Username: "alt",
+	}
+
+	bulk := mail.Bulk{Target: mail.BulkDialogues, Action: mail.BulkArchive, Ids: []int{1, 2}}
+	body, _ := json.Marshal(bulk)
+	req := httptest.NewRequest("POST", "/email/bulk", bytes.NewReader(body))
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().Bulk(sessionUser.Username, sessionUser.Id, bulk).Return([]mail.BulkResult{
+		{Id: 1, Ok: true, Other: "lio@liokor.ru"},
+		{Id: 2, Error: "dialogue doesn't exist"},
+	}, nil).Times(1)
+	err := mailHandler.Bulk(echoContext)
+	if err != nil {
+		t.Errorf("Didn't apply valid bulk: %v\n", err)
+	}
+	if strings.TrimSpace(response.Body.String()) != `[{"id":1,"ok":true},{"id":2,"ok":false,"error":"dialogue doesn't exist"}]` {
+		t.Errorf("Wrong results: %s\n", response.Body.String())
+	}
+
+	bulk.Action = mail.BulkRestore
+	body, _ = json.Marshal(bulk)
+	req = httptest.NewRequest("POST", "/email/bulk", bytes.NewReader(body))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().Bulk(sessionUser.Username, sessionUser.Id, bulk).Return(nil, mail.InvalidEmailError{"action isn't supported"}).Times(1)
+	err = mailHandler.Bulk(echoContext)
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusBadRequest {
+		t.Errorf("Didn't pass unsupported action: %v\n", err)
+	}
+
+	// dialogues of the shared mailbox are moved to its folders
+	bulk = mail.Bulk{Target: mail.BulkDialogues, Action: mail.BulkMove, Ids: []int{1}, Folder: 4}
+	body, _ = json.Marshal(bulk)
+	req = httptest.NewRequest("POST", "/email/bulk?mailbox=support", bytes.NewReader(body))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().CheckMailboxAccess("support", "alt").Return(nil).Times(1)
+	mockMailUC.EXPECT().GetUserId("support").Return(9, nil).Times(1)
+	mockMailUC.EXPECT().Bulk("support", 9, bulk).Return([]mail.BulkResult{{Id: 1, Ok: true}}, nil).Times(1)
+	err = mailHandler.Bulk(echoContext)
+	if err != nil {
+		t.Errorf("Didn't move dialogues of shared mailbox: %v\n", err)
+	}
+
+	req = httptest.NewRequest("POST", "/email/bulk", strings.NewReader("{"))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	err = mailHandler.Bulk(echoContext)
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusBadRequest {
+		t.Errorf("Didn't pass invalid json: %v\n", err)
+	}
+}
+
 func TestDeleteFolder(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -642,4 +747,880 @@ func TestDeleteFolder(t *testing.T) {
 		t.Errorf("Didn't pass invalid data: %v\n", err)
 	}
 
-}
\ No newline at end of file
+	// folder of the shared mailbox is deleted from the mailbox, not from the member
+	req = httptest.NewRequest("DELETE", url+"?mailbox=support", bytes.NewReader(body))
+	echoContext = e.NewContext(req, httptest.NewRecorder())
+	echoContext.Set("sessionUser", sessionUser)
+	mockMailUC.EXPECT().CheckMailboxAccess("support", "alt").Return(nil).Times(1)
+	mockMailUC.EXPECT().GetUserId("support").Return(9, nil).Times(1)
+	mockMailUC.EXPECT().DeleteFolder("support", 9, deleteFolder.FolderId).Return(nil).Times(1)
+	err = mailHandler.DeleteFolder(echoContext)
+	if err != nil {
+		t.Errorf("Didn't delete folder of shared mailbox: %v\n", err)
+	}
+}
+func TestUpdateVacation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+	vacation := mail.Vacation{
+		Enabled:       true,
+		StartDate:     time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:       time.Date(2021, 6, 14, 0, 0, 0, 0, time.UTC),
+		Subject:       "Out of office",
+		Body:          "Back on **Monday**",
+		ReplyInterval: 7,
+	}
+	body, _ := json.Marshal(vacation)
+	url := "/email/vacation"
+	req := httptest.NewRequest("PUT", url, bytes.NewReader(body))
+	req.Header.Add("Cookie", "session_token=sessionToken; Expires=Wed, 03 Jun 2021 03:30:48 GMT; HttpOnly")
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+
+	sessionUser := user.User{
+		Id:           1,
+		Username:     "alt",
+		HashPassword: "hash",
+		AvatarURL:    common.NullString{sql.NullString{String: "/media/test", Valid: true}},
+		FullName:     "Test test",
+		ReserveEmail: "test@test.test",
+	}
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().UpdateVacation(sessionUser.Username, vacation).Return(vacation, nil).Times(1)
+	err := mailHandler.UpdateVacation(echoContext)
+	if err != nil {
+		t.Errorf("Didn't update valid vacation: %v\n", err.Error())
+	}
+
+	req = httptest.NewRequest("PUT", url, bytes.NewReader(body))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+	mockMailUC.EXPECT().UpdateVacation(sessionUser.Username, vacation).Return(mail.Vacation{}, mail.InvalidEmailError{"Invalid dates"}).Times(1)
+	err = mailHandler.UpdateVacation(echoContext)
+	if httperr, ok := err.(*echo.HTTPError); ok {
+		if httperr.Code != http.StatusBadRequest {
+			t.Errorf("Didn't pass invalid data: %v\n", err)
+		}
+	} else {
+		t.Errorf("Didn't pass invalid data: %v\n", err)
+	}
+
+	req = httptest.NewRequest("GET", url, nil)
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+	mockMailUC.EXPECT().GetVacation(sessionUser.Username).Return(vacation, nil).Times(1)
+	err = mailHandler.GetVacation(echoContext)
+	if err != nil {
+		t.Errorf("Didn't get valid vacation: %v\n", err.Error())
+	}
+}
+
+func TestConfirmForwarding(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+	url := "/email/forwarding/confirm?token=validToken"
+	req := httptest.NewRequest("GET", url, nil)
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+
+	mockMailUC.EXPECT().ConfirmForwarding("validToken").Return(nil).Times(1)
+	err := mailHandler.ConfirmForwarding(echoContext)
+	if err != nil {
+		t.Errorf("Didn't confirm valid token: %v\n", err.Error())
+	}
+
+	req = httptest.NewRequest("GET", url, nil)
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	mockMailUC.EXPECT().ConfirmForwarding("validToken").Return(mail.InvalidEmailError{"invalid confirmation token"}).Times(1)
+	err = mailHandler.ConfirmForwarding(echoContext)
+	if httperr, ok := err.(*echo.HTTPError); ok {
+		if httperr.Code != http.StatusBadRequest {
+			t.Errorf("Didn't pass invalid token: %v\n", err)
+		}
+	} else {
+		t.Errorf("Didn't pass invalid token: %v\n", err)
+	}
+}
+
+func TestSharedMailboxAccess(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+
+	sessionUser := user.User{
+		Username: "sessionTest",
+	}
+
+	url := "/email/dialogues/?amount=5&mailbox=support"
+	req := httptest.NewRequest("GET", url, nil)
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().CheckMailboxAccess("support", sessionUser.Username).Return(nil).Times(1)
+	mockMailUC.EXPECT().GetDialogues("support", "", 0, mail.Page{Limit: 5}).Return(mail.DialoguesPage{}, nil).Times(1)
+	err := mailHandler.GetDialogues(echoContext)
+	if err != nil {
+		t.Errorf("Member didn't get dialogues of the mailbox: %v\n", err)
+	}
+
+	req = httptest.NewRequest("GET", url, nil)
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().CheckMailboxAccess("support", sessionUser.Username).Return(mail.AccessDeniedError{"not a member of the mailbox"}).Times(1)
+	err = mailHandler.GetDialogues(echoContext)
+	if httperr, ok := err.(*echo.HTTPError); ok {
+		if httperr.Code != http.StatusForbidden {
+			t.Errorf("Not member got dialogues of the mailbox: %v\n", err)
+		}
+	} else {
+		t.Errorf("Not member got dialogues of the mailbox: %v\n", err)
+	}
+}
+
+func TestSenderRulesHandlers(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+
+	sessionUser := user.User{
+		Username: "sessionTest",
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"pattern": "spam.com",
+		"allow":   false,
+	})
+	req := httptest.NewRequest("POST", "/email/sender", bytes.NewReader(body))
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().AddSenderRule(sessionUser.Username, mail.SenderRule{Pattern: "spam.com"}).Return(mail.SenderRule{Id: 1, Pattern: "spam.com"}, nil).Times(1)
+	err := mailHandler.AddSenderRule(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+	if response.Code != http.StatusCreated {
+		t.Errorf("Wrong status code: %d\n", response.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/admin/blocklist", bytes.NewReader(body))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().AddGlobalBlock(sessionUser.Username, "spam.com").Return(mail.SenderRule{}, mail.AccessDeniedError{"only admins can manage global blocklist"}).Times(1)
+	err = mailHandler.AddGlobalBlock(echoContext)
+	if httperr, ok := err.(*echo.HTTPError); !ok || httperr.Code != http.StatusForbidden {
+		t.Errorf("Not admin changed global blocklist: %v\n", err)
+	}
+}
+
+func TestReplyHandlers(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+
+	sessionUser := user.User{
+		Username: "sessionTest",
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"body": "Thanks!",
+	})
+	req := httptest.NewRequest("POST", "/email/7/reply-all", bytes.NewReader(body))
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.SetParamNames("id")
+	echoContext.SetParamValues("7")
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().ReplyEmail(sessionUser.Username, 7, mail.Mail{Body: "Thanks!"}, true).Return([]mail.Mail{{Subject: "Re: Hi"}}, nil).Times(1)
+	err := mailHandler.ReplyAllEmail(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+
+	body, _ = json.Marshal(map[string]interface{}{
+		"recipient": "alt@liokor.ru",
+	})
+	req = httptest.NewRequest("POST", "/email/8/forward", bytes.NewReader(body))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.SetParamNames("id")
+	echoContext.SetParamValues("8")
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().ForwardEmail(sessionUser.Username, 8, mail.Mail{Recipient: "alt@liokor.ru"}).Return(mail.Mail{}, mail.InvalidEmailError{"mail doesn't exist"}).Times(1)
+	err = mailHandler.ForwardEmail(echoContext)
+	if httperr, ok := err.(*echo.HTTPError); !ok || httperr.Code != http.StatusBadRequest {
+		t.Errorf("Forwarded not existing mail: %v\n", err)
+	}
+
+	req = httptest.NewRequest("POST", "/email/abc/reply", bytes.NewReader(body))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.SetParamNames("id")
+	echoContext.SetParamValues("abc")
+	echoContext.Set("sessionUser", sessionUser)
+
+	err = mailHandler.ReplyEmail(echoContext)
+	if httperr, ok := err.(*echo.HTTPError); !ok || httperr.Code != http.StatusBadRequest {
+		t.Errorf("Passed invalid mail id: %v\n", err)
+	}
+}
+
+func TestTemplateHandlers(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+
+	sessionUser := user.User{
+		Username: "sessionTest",
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"name": "Work",
+		"body": "Alt",
+	})
+	req := httptest.NewRequest("POST", "/email/signature", bytes.NewReader(body))
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().CreateSignature(sessionUser.Username, mail.Signature{Name: "Work", Body: "Alt"}).Return(mail.Signature{Id: 1, Name: "Work", Body: "Alt"}, nil).Times(1)
+	err := mailHandler.CreateSignature(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+	if response.Code != http.StatusCreated {
+		t.Errorf("Wrong status code: %d\n", response.Code)
+	}
+
+	body, _ = json.Marshal(map[string]interface{}{
+		"id":        2,
+		"recipient": "john@ya.ru",
+	})
+	req = httptest.NewRequest("POST", "/email/template/render", bytes.NewReader(body))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	rendered := mail.RenderedTemplate{Subject: "Hi", Markdown: "Dear **John**", Html: "<p>Dear <strong>John</strong></p>\n"}
+	mockMailUC.EXPECT().RenderTemplate(sessionUser.Username, 2, "john@ya.ru").Return(rendered, nil).Times(1)
+	err = mailHandler.RenderTemplate(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+	var result mail.RenderedTemplate
+	err = json.Unmarshal(response.Body.Bytes(), &result)
+	if err != nil || result != rendered {
+		t.Errorf("Wrong rendered template: %v %v\n", result, err)
+	}
+
+	req = httptest.NewRequest("POST", "/email/template/render", bytes.NewReader(body))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().RenderTemplate(sessionUser.Username, 2, "john@ya.ru").Return(mail.RenderedTemplate{}, mail.InvalidEmailError{"template doesn't exist"}).Times(1)
+	err = mailHandler.RenderTemplate(echoContext)
+	if httperr, ok := err.(*echo.HTTPError); !ok || httperr.Code != http.StatusNotFound {
+		t.Errorf("Rendered not existing template: %v\n", err)
+	}
+}
+
+func TestPreviewEmail(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+
+	sessionUser := user.User{
+		Username: "sessionTest",
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"body": "Hi <script>alert(1)</script>",
+	})
+	req := httptest.NewRequest("POST", "/email/preview", bytes.NewReader(body))
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	preview := mail.Preview{Html: "<p>Hi</p>\n", Stripped: []string{"<script>"}}
+	mockMailUC.EXPECT().PreviewEmail(sessionUser.Username, mail.Mail{Body: "Hi <script>alert(1)</script>"}).Return(preview, nil).Times(1)
+	err := mailHandler.PreviewEmail(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+
+	var result mail.Preview
+	err = json.Unmarshal(response.Body.Bytes(), &result)
+	if err != nil || result.Html != preview.Html || len(result.Stripped) != 1 {
+		t.Errorf("Wrong preview: %v %v\n", result, err)
+	}
+}
+
+func TestReadReceiptsHandlers(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+
+	sessionUser := user.User{
+		Username: "sessionTest",
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"sendReadReceipts": true,
+	})
+	req := httptest.NewRequest("PUT", "/email/receipts", bytes.NewReader(body))
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().UpdateReadReceipts(sessionUser.Username, true).Return(nil).Times(1)
+	err := mailHandler.UpdateReadReceipts(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+	if response.Code != http.StatusOK {
+		t.Errorf("Wrong status code: %d\n", response.Code)
+	}
+}
+
+func TestSnoozeDialogue(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+
+	sessionUser := user.User{
+		Username: "sessionTest",
+	}
+
+	until := time.Date(2031, 1, 2, 9, 0, 0, 0, time.UTC)
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":    1,
+		"until": until,
+	})
+	req := httptest.NewRequest("PUT", "/email/dialogue/snooze", bytes.NewReader(body))
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().SnoozeDialogue(sessionUser.Username, 1, until).Return(nil).Times(1)
+	err := mailHandler.SnoozeDialogue(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+
+	req = httptest.NewRequest("DELETE", "/email/dialogue/snooze", bytes.NewReader([]byte(`{"id": 2}`)))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().UnsnoozeDialogue(sessionUser.Username, 2).Return(mail.InvalidEmailError{"Dialogue doesn't exist"}).Times(1)
+	err = mailHandler.UnsnoozeDialogue(echoContext)
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusNotFound {
+		t.Errorf("Didn't pass invalid dialogue: %v\n", err)
+	}
+
+	req = httptest.NewRequest("GET", "/email/dialogues?snoozed=true", nil)
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().GetSnoozedDialogues(sessionUser.Username, mail.Page{Limit: mail.DefaultPageSize}).Return(mail.DialoguesPage{}, nil).Times(1)
+	err = mailHandler.GetDialogues(echoContext)
+	if err != nil {
+		t.Errorf("Didn't get snoozed dialogues: %v\n", err)
+	}
+
+	req = httptest.NewRequest("GET", "/email/dialogues?archived=true", nil)
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().GetArchivedDialogues(sessionUser.Username, mail.Page{Limit: mail.DefaultPageSize}).Return(mail.DialoguesPage{}, nil).Times(1)
+	err = mailHandler.GetDialogues(echoContext)
+	if err != nil {
+		t.Errorf("Didn't get archived dialogues: %v\n", err)
+	}
+}
+
+func TestPinAndMuteDialogue(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+
+	sessionUser := user.User{
+		Username: "sessionTest",
+	}
+
+	req := httptest.NewRequest("PUT", "/email/dialogue/pin", bytes.NewReader([]byte(`{"id": 1, "pinned": true}`)))
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().PinDialogue(sessionUser.Username, 1, true).Return(nil).Times(1)
+	err := mailHandler.PinDialogue(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+
+	req = httptest.NewRequest("PUT", "/email/dialogue/mute", bytes.NewReader([]byte(`{"id": 2, "muted": true}`)))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().MuteDialogue(sessionUser.Username, 2, true).Return(mail.InvalidEmailError{"Dialogue doesn't exist"}).Times(1)
+	err = mailHandler.MuteDialogue(echoContext)
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusNotFound {
+		t.Errorf("Didn't pass invalid dialogue: %v\n", err)
+	}
+}
+
+func TestMoveFolder(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+
+	sessionUser := user.User{
+		Id:       1,
+		Username: "sessionTest",
+	}
+
+	req := httptest.NewRequest("PUT", "/email/folder", bytes.NewReader([]byte(`{"folderId": 2, "parent": 4, "position": 3}`)))
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().MoveFolder(1, 2, 4, 3).Return(mail.Folder{Id: 2, Parent: 4, Position: 3}, nil).Times(1)
+	err := mailHandler.UpdateFolder(echoContext)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+
+	req = httptest.NewRequest("PUT", "/email/folder", bytes.NewReader([]byte(`{"folderId": 1, "parent": 3}`)))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().MoveFolder(1, 1, 3, 0).Return(mail.Folder{}, mail.InvalidEmailError{"folder can't be moved into itself or its subfolder"}).Times(1)
+	err = mailHandler.UpdateFolder(echoContext)
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusBadRequest {
+		t.Errorf("Didn't pass cycle: %v\n", err)
+	}
+
+	req = httptest.NewRequest("PUT", "/email/folder?mailbox=support", bytes.NewReader([]byte(`{"folderId": 2, "parent": 4, "position": 3}`)))
+	echoContext = e.NewContext(req, httptest.NewRecorder())
+	echoContext.Set("sessionUser", sessionUser)
+	mockMailUC.EXPECT().CheckMailboxAccess("support", "sessionTest").Return(nil).Times(1)
+	mockMailUC.EXPECT().GetUserId("support").Return(9, nil).Times(1)
+	mockMailUC.EXPECT().MoveFolder(9, 2, 4, 3).Return(mail.Folder{Id: 2, Parent: 4, Position: 3}, nil).Times(1)
+	err = mailHandler.UpdateFolder(echoContext)
+	if err != nil {
+		t.Errorf("Didn't move folder of shared mailbox: %v\n", err)
+	}
+}
+
+func TestSmartFolders(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+
+	sessionUser := user.User{
+		Username: "sessionTest",
+	}
+
+	req := httptest.NewRequest("POST", "/email/smartfolder", bytes.NewReader([]byte(`{"name": "Yandex", "senderDomain": "ya.ru", "unread": true}`)))
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	folder := mail.SmartFolder{Name: "Yandex", SmartQuery: mail.SmartQuery{SenderDomain: "ya.ru", Unread: true}}
+	mockMailUC.EXPECT().CreateSmartFolder(sessionUser.Username, folder).Return(folder, nil).Times(1)
+	err := mailHandler.CreateSmartFolder(echoContext)
+	if err != nil || response.Code != http.StatusCreated {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+
+	req = httptest.NewRequest("GET", "/email/dialogues?smart=3&amount=10", nil)
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().GetSmartFolderDialogues(sessionUser.Username, 3, mail.Page{Limit: 10}).Return(mail.DialoguesPage{}, mail.InvalidEmailError{"smart folder doesn't exist"}).Times(1)
+	err = mailHandler.GetDialogues(echoContext)
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusNotFound {
+		t.Errorf("Didn't pass invalid smart folder: %v\n", err)
+	}
+}
+
+func TestWebhookHandlers(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+
+	sessionUser := user.User{
+		Username: "sessionTest",
+	}
+
+	req := httptest.NewRequest("POST", "/email/webhook", bytes.NewReader([]byte(`{"url": "https://bots.ru/hook", "events": ["received"]}`)))
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	webhook := mail.Webhook{URL: "https://bots.ru/hook", Events: common.StringList{mail.WebhookReceived}}
+	mockMailUC.EXPECT().CreateWebhook(sessionUser.Username, webhook).Return(webhook, nil).Times(1)
+	err := mailHandler.CreateWebhook(echoContext)
+	if err != nil || response.Code != http.StatusCreated {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+
+	req = httptest.NewRequest("GET", "/email/webhook/deliveries?id=2&amount=5", nil)
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().GetWebhookDeliveries(sessionUser.Username, 2, 5).Return(nil, mail.InvalidEmailError{"webhook doesn't exist"}).Times(1)
+	err = mailHandler.GetWebhookDeliveries(echoContext)
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusNotFound {
+		t.Errorf("Didn't pass invalid webhook: %v\n", err)
+	}
+
+	req = httptest.NewRequest("POST", "/email/webhook/test", bytes.NewReader([]byte(`{"id": 2}`)))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	delivery := mail.WebhookDelivery{Webhook: 2, Event: mail.WebhookTest, Attempt: 1, StatusCode: http.StatusOK}
+	mockMailUC.EXPECT().TestWebhook(sessionUser.Username, 2).Return(delivery, nil).Times(1)
+	err = mailHandler.TestWebhook(echoContext)
+	if err != nil || response.Code != http.StatusOK {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+}
+
+func TestPushHandlers(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+
+	e := echo.New()
+
+	sessionUser := user.User{
+		Username: "sessionTest",
+	}
+
+	body := `{"endpoint": "https://push.example.com/abc", "keys": {"p256dh": "key", "auth": "secret"}}`
+	req := httptest.NewRequest("POST", "/email/push/subscription", bytes.NewReader([]byte(body)))
+	req.Header.Add("Cookie", "session_token=sessionToken")
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	subscription := mail.PushSubscription{
+		Endpoint: "https://push.example.com/abc",
+		Keys:     mail.PushKeys{P256dh: "key", Auth: "secret"},
+	}
+	mockMailUC.EXPECT().SubscribePush(sessionUser.Username, "sessionToken", subscription).Return(nil).Times(1)
+	err := mailHandler.SubscribePush(echoContext)
+	if err != nil || response.Code != http.StatusCreated {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+
+	req = httptest.NewRequest("DELETE", "/email/push/subscription", nil)
+	req.Header.Add("Cookie", "session_token=sessionToken")
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().UnsubscribePush("sessionToken").Return(mail.InvalidEmailError{"push subscription doesn't exist"}).Times(1)
+	err = mailHandler.UnsubscribePush(echoContext)
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusNotFound {
+		t.Errorf("Didn't pass missing subscription: %v\n", err)
+	}
+
+	req = httptest.NewRequest("GET", "/email/push/key", nil)
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+
+	mockMailUC.EXPECT().GetVapidPublicKey().Return("BPublicKey", nil).Times(1)
+	err = mailHandler.GetVapidPublicKey(echoContext)
+	if err != nil || !strings.Contains(response.Body.String(), "BPublicKey") {
+		t.Errorf("Didn't return public key: %v\n", err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+	e := echo.New()
+	sessionUser := user.User{Id: 1, Username: "alt"}
+
+	req := httptest.NewRequest("GET", "/sync?since=10", nil)
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	changes := mail.Sync{
+		Token: "15",
+		Mails: mail.SyncChanges{Created: []int{7}, Updated: []int{}, Deleted: []int{}},
+	}
+	mockMailUC.EXPECT().Sync("alt", 1, "10").Return(changes, nil).Times(1)
+	err := mailHandler.Sync(echoContext)
+	if err != nil || !strings.Contains(response.Body.String(), `"token":"15"`) {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+
+	req = httptest.NewRequest("GET", "/sync?since=abc", nil)
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().Sync("alt", 1, "abc").Return(mail.Sync{}, mail.InvalidEmailError{"invalid sync token"}).Times(1)
+	err = mailHandler.Sync(echoContext)
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusBadRequest {
+		t.Errorf("Didn't fail on invalid token: %v\n", err)
+	}
+
+	// folders of the shared mailbox belong to the mailbox
+	req = httptest.NewRequest("GET", "/sync?since=10&mailbox=support", nil)
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().CheckMailboxAccess("support", "alt").Return(nil).Times(1)
+	mockMailUC.EXPECT().GetUserId("support").Return(9, nil).Times(1)
+	mockMailUC.EXPECT().Sync("support", 9, "10").Return(changes, nil).Times(1)
+	err = mailHandler.Sync(echoContext)
+	if err != nil {
+		t.Errorf("Didn't sync shared mailbox: %v\n", err)
+	}
+}
+
+func TestExports(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+	e := echo.New()
+	sessionUser := user.User{Id: 1, Username: "alt"}
+
+	body := `{"format": "eml", "folders": [4]}`
+	req := httptest.NewRequest("POST", "/email/export", strings.NewReader(body))
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	export := mail.Export{Format: mail.ExportEml, Folders: common.IntList{4}}
+	created := export
+	created.Id = 2
+	created.Status = mail.ExportPending
+	mockMailUC.EXPECT().CreateExport("alt", export).Return(created, nil).Times(1)
+	err := mailHandler.CreateExport(echoContext)
+	if err != nil || response.Code != http.StatusAccepted || !strings.Contains(response.Body.String(), `"status":"pending"`) {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+
+	req = httptest.NewRequest("GET", "/email/export?id=3", nil)
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().GetExport("alt", 3).Return(mail.Export{}, mail.InvalidEmailError{"export doesn't exist"}).Times(1)
+	err = mailHandler.GetExport(echoContext)
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusNotFound {
+		t.Errorf("Didn't pass missing export: %v\n", err)
+	}
+
+	req = httptest.NewRequest("GET", mail.ExportDownloadPath+"token", nil)
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.SetParamNames("token")
+	echoContext.SetParamValues("token")
+
+	mockMailUC.EXPECT().GetExportFile("token").Return(mail.Export{}, "", mail.InvalidEmailError{"download link has expired"}).Times(1)
+	err = mailHandler.DownloadExport(echoContext)
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusNotFound {
+		t.Errorf("Downloaded expired export: %v\n", err)
+	}
+}
+
+func TestImports(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{
+		mockMailUC,
+	}
+	e := echo.New()
+	sessionUser := user.User{Id: 1, Username: "alt"}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("source", "mbox")
+	file, _ := form.CreateFormFile("file", "inbox.mbox")
+	file.Write([]byte("From a@ya.ru Sat May  1 12:00:00 2021\nSubject: Hi\n\nHello\n"))
+	form.Close()
+	req := httptest.NewRequest("POST", "/email/import", &body)
+	req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+	response := httptest.NewRecorder()
+	echoContext := e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().ImportArchive("alt", 1, mail.ImportMbox, gomock.Any()).
+		Return(mail.Import{Id: 2, Source: mail.ImportMbox, Status: mail.ExportPending}, nil).Times(1)
+	err := mailHandler.ImportArchive(echoContext)
+	if err != nil || response.Code != http.StatusAccepted || !strings.Contains(response.Body.String(), `"status":"pending"`) {
+		t.Errorf("Didn't pass valid archive: %v\n", err)
+	}
+
+	req = httptest.NewRequest("POST", "/email/import", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	err = mailHandler.ImportArchive(echoContext)
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusBadRequest {
+		t.Errorf("Didn't fail without archive: %v\n", err)
+	}
+
+	req = httptest.NewRequest("POST", "/email/import/imap", strings.NewReader(`{"host": "imap.ya.ru", "username": "friend", "password": "wrong"}`))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	account := mail.IMAPAccount{Host: "imap.ya.ru", Username: "friend", Password: "wrong"}
+	mockMailUC.EXPECT().ImportIMAP("alt", 1, account).
+		Return(mail.Import{}, mail.InvalidEmailError{"unable to log in to imap server"}).Times(1)
+	err = mailHandler.ImportIMAP(echoContext)
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusBadRequest {
+		t.Errorf("Didn't fail on wrong password: %v\n", err)
+	}
+
+	req = httptest.NewRequest("POST", "/email/import/resume", strings.NewReader(`{"id": 2}`))
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().ResumeImport("alt", 1, 2, "").
+		Return(mail.Import{Id: 2, Source: mail.ImportMbox, Status: mail.ExportPending, Processed: 10}, nil).Times(1)
+	err = mailHandler.ResumeImport(echoContext)
+	if err != nil || response.Code != http.StatusAccepted || !strings.Contains(response.Body.String(), `"processed":10`) {
+		t.Errorf("Didn't resume import: %v\n", err)
+	}
+
+	req = httptest.NewRequest("GET", "/email/import?id=3", nil)
+	response = httptest.NewRecorder()
+	echoContext = e.NewContext(req, response)
+	echoContext.Set("sessionUser", sessionUser)
+
+	mockMailUC.EXPECT().GetImport("alt", 3).Return(mail.Import{}, mail.InvalidEmailError{"import doesn't exist"}).Times(1)
+	err = mailHandler.GetImport(echoContext)
+	if httpErr, ok := err.(*echo.HTTPError); !ok || httpErr.Code != http.StatusNotFound {
+		t.Errorf("Didn't pass missing import: %v\n", err)
+	}
+}
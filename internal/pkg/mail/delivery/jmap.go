@@ -0,0 +1,1583 @@
+package delivery
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/microcosm-cc/bluemonday"
+	"html"
+	"io"
+	"liokor_mail/internal/pkg/common"
+	"liokor_mail/internal/pkg/mail"
+	"liokor_mail/internal/pkg/user"
+	"liokor_mail/internal/utils"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Minimal JMAP (RFC 8620, RFC 8621) server: the user has the only account with the username as id,
+// shared mailboxes aren't exposed. Mailboxes are the folders and the virtual inbox of dialogues out of
+// folders, threads are dialogues. Email and thread states are sync tokens, so their changes are calculated,
+// mailbox state is a fingerprint and clients resync mailboxes when it changes.
+const (
+	JMAPSessionPath     = "/jmap/session"
+	JMAPApiPath         = "/jmap"
+	JMAPDownloadPath    = "/jmap/download/"
+	JMAPEventSourcePath = "/jmap/eventsource"
+
+	jmapCore       = "urn:ietf:params:jmap:core"
+	jmapMail       = "urn:ietf:params:jmap:mail"
+	jmapSubmission = "urn:ietf:params:jmap:submission"
+
+	jmapInboxId        = "inbox"
+	jmapMaxRequestSize = 1 << 20
+	jmapMaxCalls       = 32
+	jmapMaxObjects     = 256
+	jmapPreviewLength  = 256
+	jmapDateFormat     = "2006-01-02T15:04:05Z"
+)
+
+var jmapPreviewPolicy = bluemonday.StrictPolicy().AddSpaceWhenStrippingTag(true)
+
+// jmapContext is state of the single API request, created ids are referenced by later calls as #creationId
+type jmapContext struct {
+	owner      string
+	ownerId    int
+	address    string
+	createdIds map[string]string
+	drafts     map[string]mail.Mail // emails created by Email/set, they may only be sent by the same request
+}
+
+type jmapInvocation struct {
+	Name   string
+	Args   interface{}
+	CallId string
+}
+
+func (i jmapInvocation) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{i.Name, i.Args, i.CallId})
+}
+
+// jmapError is both method level error and SetError of the /set methods
+type jmapError struct {
+	Type        string   `json:"type"`
+	Description string   `json:"description,omitempty"`
+	Properties  []string `json:"properties,omitempty"`
+}
+
+func jmapServerFail(err error) *jmapError {
+	return &jmapError{Type: "serverFail", Description: err.Error()}
+}
+
+func jmapInvalidArguments(description string) *jmapError {
+	return &jmapError{Type: "invalidArguments", Description: description}
+}
+
+func jmapInvalidProperties(description string, properties ...string) *jmapError {
+	return &jmapError{Type: "invalidProperties", Description: description, Properties: properties}
+}
+
+func jmapProblem(c echo.Context, problemType string, detail string) error {
+	return c.JSON(http.StatusBadRequest, echo.Map{
+		"type":   "urn:ietf:params:jmap:error:" + problemType,
+		"status": http.StatusBadRequest,
+		"detail": detail,
+	})
+}
+
+func jmapHash(values ...string) string {
+	sum := md5.Sum([]byte(strings.Join(values, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func jmapBaseURL(c echo.Context) string {
+	return c.Scheme() + "://" + c.Request().Host
+}
+
+func (h *MailHandler) WellKnownJMAP(c echo.Context) error {
+	return c.Redirect(http.StatusMovedPermanently, JMAPSessionPath)
+}
+
+func (h *MailHandler) JMAPSession(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	address, err := h.MailUsecase.GetAddress(sessionUser.Username)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	base := jmapBaseURL(c)
+	return c.JSON(http.StatusOK, echo.Map{
+		"capabilities": echo.Map{
+			jmapCore: echo.Map{
+				"maxSizeUpload":         0,
+				"maxConcurrentUpload":   1,
+				"maxSizeRequest":        jmapMaxRequestSize,
+				"maxConcurrentRequests": 4,
+				"maxCallsInRequest":     jmapMaxCalls,
+				"maxObjectsInGet":       jmapMaxObjects,
+				"maxObjectsInSet":       jmapMaxObjects,
+				"collationAlgorithms":   []string{},
+			},
+			jmapMail:       echo.Map{},
+			jmapSubmission: echo.Map{},
+		},
+		"accounts": echo.Map{
+			sessionUser.Username: echo.Map{
+				"name":       address,
+				"isPersonal": true,
+				"isReadOnly": false,
+				"accountCapabilities": echo.Map{
+					jmapMail: echo.Map{
+						"maxMailboxesPerEmail":       1,
+						"maxMailboxDepth":            nil,
+						"maxSizeMailboxName":         255,
+						"maxSizeAttachmentsPerEmail": 0,
+						"emailQuerySortOptions":      []string{"receivedAt"},
+						"mayCreateTopLevelMailbox":   true,
+					},
+					jmapSubmission: echo.Map{
+						"maxDelayedSend":       0,
+						"submissionExtensions": echo.Map{},
+					},
+				},
+			},
+		},
+		"primaryAccounts": echo.Map{
+			jmapMail:       sessionUser.Username,
+			jmapSubmission: sessionUser.Username,
+		},
+		"username":       sessionUser.Username,
+		"apiUrl":         base + JMAPApiPath,
+		"downloadUrl":    base + JMAPDownloadPath + "{accountId}/{blobId}/{name}?accept={type}",
+		"uploadUrl":      base + "/jmap/upload/{accountId}/",
+		"eventSourceUrl": base + JMAPEventSourcePath + "?types={types}&closeafter={closeafter}&ping={ping}",
+		"state":          jmapHash(sessionUser.Username, address),
+	})
+}
+
+// JMAP processes method calls of the request in order, arguments may refer to results of previous calls
+func (h *MailHandler) JMAP(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var request struct {
+		Using       []string            `json:"using"`
+		MethodCalls [][]json.RawMessage `json:"methodCalls"`
+	}
+	defer c.Request().Body.Close()
+	err := json.NewDecoder(io.LimitReader(c.Request().Body, jmapMaxRequestSize)).Decode(&request)
+	if err != nil {
+		return jmapProblem(c, "notRequest", err.Error())
+	}
+	for _, capability := range request.Using {
+		if capability != jmapCore && capability != jmapMail && capability != jmapSubmission {
+			return jmapProblem(c, "unknownCapability", capability+" isn't supported")
+		}
+	}
+	if len(request.MethodCalls) > jmapMaxCalls {
+		return jmapProblem(c, "limit", "maxCallsInRequest")
+	}
+
+	address, err := h.MailUsecase.GetAddress(sessionUser.Username)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	ctx := &jmapContext{
+		owner:      sessionUser.Username,
+		ownerId:    sessionUser.Id,
+		address:    address,
+		createdIds: make(map[string]string),
+		drafts:     make(map[string]mail.Mail),
+	}
+
+	responses := make([]jmapInvocation, 0, len(request.MethodCalls))
+	for _, call := range request.MethodCalls {
+		var name, callId string
+		var args map[string]json.RawMessage
+		if len(call) != 3 || json.Unmarshal(call[0], &name) != nil ||
+			json.Unmarshal(call[1], &args) != nil || json.Unmarshal(call[2], &callId) != nil {
+			return jmapProblem(c, "notRequest", "method call must be [name, arguments, callId]")
+		}
+
+		var result interface{}
+		methodErr := resolveResultReferences(args, responses)
+		if methodErr == nil {
+			result, methodErr = h.jmapMethod(ctx, name, args)
+		}
+		if methodErr != nil {
+			responses = append(responses, jmapInvocation{"error", methodErr, callId})
+		} else {
+			responses = append(responses, jmapInvocation{name, result, callId})
+		}
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"methodResponses": responses,
+		"sessionState":    jmapHash(sessionUser.Username, address),
+	})
+}
+
+// resolveResultReferences replaces "#name" arguments with the values pointed by them in previous responses
+func resolveResultReferences(args map[string]json.RawMessage, responses []jmapInvocation) *jmapError {
+	for key, value := range args {
+		if !strings.HasPrefix(key, "#") {
+			continue
+		}
+		name := strings.TrimPrefix(key, "#")
+		if _, ok := args[name]; ok {
+			return jmapInvalidArguments("both " + name + " and its reference are set")
+		}
+		var reference struct {
+			ResultOf string `json:"resultOf"`
+			Name     string `json:"name"`
+			Path     string `json:"path"`
+		}
+		err := json.Unmarshal(value, &reference)
+		if err != nil {
+			return &jmapError{Type: "invalidResultReference", Description: err.Error()}
+		}
+
+		var resolved interface{}
+		found := false
+		for _, response := range responses {
+			if response.CallId != reference.ResultOf || response.Name != reference.Name {
+				continue
+			}
+			var result interface{}
+			encoded, _ := json.Marshal(response.Args)
+			json.Unmarshal(encoded, &result)
+			resolved, found = evaluatePointer(result, reference.Path)
+			break
+		}
+		if !found {
+			return &jmapError{Type: "invalidResultReference", Description: "unable to resolve " + key}
+		}
+		args[name], _ = json.Marshal(resolved)
+		delete(args, key)
+	}
+	return nil
+}
+
+// evaluatePointer evaluates JSON pointer (RFC 6901) where "*" maps the rest of the path over array items
+func evaluatePointer(value interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return value, true
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	return evaluateTokens(value, strings.Split(path[1:], "/"))
+}
+
+func evaluateTokens(value interface{}, tokens []string) (interface{}, bool) {
+	if len(tokens) == 0 {
+		return value, true
+	}
+	token := strings.ReplaceAll(strings.ReplaceAll(tokens[0], "~1", "/"), "~0", "~")
+	switch v := value.(type) {
+	case map[string]interface{}:
+		next, ok := v[token]
+		if !ok {
+			return nil, false
+		}
+		return evaluateTokens(next, tokens[1:])
+	case []interface{}:
+		if token == "*" {
+			result := make([]interface{}, 0, len(v))
+			for _, item := range v {
+				itemResult, ok := evaluateTokens(item, tokens[1:])
+				if !ok {
+					return nil, false
+				}
+				// arrays are flattened, so ids of every item form a single list
+				if list, isList := itemResult.([]interface{}); isList {
+					result = append(result, list...)
+				} else {
+					result = append(result, itemResult)
+				}
+			}
+			return result, true
+		}
+		index, err := strconv.Atoi(token)
+		if err != nil || index < 0 || index >= len(v) {
+			return nil, false
+		}
+		return evaluateTokens(v[index], tokens[1:])
+	}
+	return nil, false
+}
+
+func decodeJMAPArgs(args map[string]json.RawMessage, request interface{}) *jmapError {
+	encoded, _ := json.Marshal(args)
+	err := json.Unmarshal(encoded, request)
+	if err != nil {
+		return jmapInvalidArguments(err.Error())
+	}
+	return nil
+}
+
+func (h *MailHandler) jmapMethod(ctx *jmapContext, name string, args map[string]json.RawMessage) (interface{}, *jmapError) {
+	if name == "Core/echo" {
+		return args, nil
+	}
+	var accountId string
+	json.Unmarshal(args["accountId"], &accountId)
+	if accountId != ctx.owner {
+		return nil, &jmapError{Type: "accountNotFound"}
+	}
+
+	switch name {
+	case "Mailbox/get":
+		return h.jmapMailboxGet(ctx, args)
+	case "Mailbox/query":
+		return h.jmapMailboxQuery(ctx, args)
+	case "Mailbox/changes":
+		_, state, err := h.jmapMailboxes(ctx)
+		if err != nil {
+			return nil, jmapServerFail(err)
+		}
+		return jmapMailboxChanges(ctx, args, state)
+	case "Mailbox/set":
+		return h.jmapMailboxSet(ctx, args)
+	case "Thread/get":
+		return h.jmapThreadGet(ctx, args)
+	case "Thread/changes":
+		return h.jmapSyncChanges(ctx, args, true)
+	case "Email/changes":
+		return h.jmapSyncChanges(ctx, args, false)
+	case "Email/get":
+		return h.jmapEmailGet(ctx, args)
+	case "Email/query":
+		return h.jmapEmailQuery(ctx, args)
+	case "Email/set":
+		return h.jmapEmailSet(ctx, args)
+	case "Identity/get":
+		return h.jmapIdentityGet(ctx, args)
+	case "EmailSubmission/set":
+		return h.jmapEmailSubmissionSet(ctx, args)
+	}
+	return nil, &jmapError{Type: "unknownMethod", Description: name + " isn't supported"}
+}
+
+// jmapMailboxChanges only reports no changes for the current state as mailbox state is a fingerprint
+func jmapMailboxChanges(ctx *jmapContext, args map[string]json.RawMessage, state string) (interface{}, *jmapError) {
+	var request struct {
+		SinceState string `json:"sinceState"`
+	}
+	if err := decodeJMAPArgs(args, &request); err != nil {
+		return nil, err
+	}
+	if request.SinceState != state {
+		return nil, &jmapError{Type: "cannotCalculateChanges"}
+	}
+	response := echo.Map{
+		"accountId":         ctx.owner,
+		"oldState":          state,
+		"newState":          state,
+		"hasMoreChanges":    false,
+		"created":           []string{},
+		"updated":           []string{},
+		"destroyed":         []string{},
+		"updatedProperties": nil,
+	}
+	return response, nil
+}
+
+// jmapSyncChanges returns changes of threads or emails since the sync token. Moving a dialogue
+// to another folder changes only the dialogue, so its emails are returned updated as well
+func (h *MailHandler) jmapSyncChanges(ctx *jmapContext, args map[string]json.RawMessage, threads bool) (interface{}, *jmapError) {
+	var request struct {
+		SinceState string `json:"sinceState"`
+		MaxChanges *int   `json:"maxChanges"`
+	}
+	if err := decodeJMAPArgs(args, &request); err != nil {
+		return nil, err
+	}
+	if request.MaxChanges != nil && *request.MaxChanges <= 0 {
+		return nil, jmapInvalidArguments("maxChanges must be positive")
+	}
+	if request.SinceState == "" {
+		return nil, &jmapError{Type: "cannotCalculateChanges"}
+	}
+	changes, err := h.MailUsecase.Sync(ctx.owner, ctx.ownerId, request.SinceState)
+	if err != nil {
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			return nil, &jmapError{Type: "cannotCalculateChanges"}
+		default:
+			return nil, jmapServerFail(err)
+		}
+	}
+
+	result := changes.Dialogues
+	if !threads {
+		result = changes.Mails
+		if len(changes.Dialogues.Updated) > 0 {
+			emails, err := h.MailUsecase.GetMailboxEmails(ctx.owner, mail.MailFilter{DialogueIds: changes.Dialogues.Updated})
+			if err != nil {
+				return nil, jmapServerFail(err)
+			}
+			known := make(map[int]bool)
+			for _, ids := range [][]int{result.Created, result.Updated, result.Deleted} {
+				for _, id := range ids {
+					known[id] = true
+				}
+			}
+			for _, email := range emails {
+				if !known[email.Id] {
+					result.Updated = append(result.Updated, email.Id)
+				}
+			}
+		}
+	}
+	if request.MaxChanges != nil && len(result.Created)+len(result.Updated)+len(result.Deleted) > *request.MaxChanges {
+		return nil, &jmapError{Type: "cannotCalculateChanges", Description: "too many changes, resync"}
+	}
+
+	toStrings := func(ids []int) []string {
+		list := make([]string, len(ids))
+		for i, id := range ids {
+			list[i] = strconv.Itoa(id)
+		}
+		return list
+	}
+	return echo.Map{
+		"accountId":      ctx.owner,
+		"oldState":       request.SinceState,
+		"newState":       changes.Token,
+		"hasMoreChanges": changes.HasMore,
+		"created":        toStrings(result.Created),
+		"updated":        toStrings(result.Updated),
+		"destroyed":      toStrings(result.Deleted),
+	}, nil
+}
+
+// selectProperties keeps only requested properties of the objects, id is always returned
+func selectProperties(objects interface{}, properties []string, known interface{}) (interface{}, *jmapError) {
+	if properties == nil {
+		return objects, nil
+	}
+	var knownProperties map[string]json.RawMessage
+	encoded, _ := json.Marshal(known)
+	json.Unmarshal(encoded, &knownProperties)
+	for _, property := range properties {
+		if _, ok := knownProperties[property]; !ok {
+			return nil, jmapInvalidArguments("unknown property " + property)
+		}
+	}
+
+	var list []map[string]json.RawMessage
+	encoded, _ = json.Marshal(objects)
+	json.Unmarshal(encoded, &list)
+	for i, object := range list {
+		selected := map[string]json.RawMessage{"id": object["id"]}
+		for _, property := range properties {
+			selected[property] = object[property]
+		}
+		list[i] = selected
+	}
+	return list, nil
+}
+
+// pageIds applies position and limit of the query, negative position counts from the end
+func pageIds(ids []string, position int, limit int) ([]string, int) {
+	if position < 0 {
+		position += len(ids)
+		if position < 0 {
+			position = 0
+		}
+	}
+	if position > len(ids) {
+		position = len(ids)
+	}
+	end := position + limit
+	if end > len(ids) {
+		end = len(ids)
+	}
+	return ids[position:end], position
+}
+
+func jmapLimit(limit *int) (int, bool) {
+	if limit == nil || *limit > jmapMaxObjects {
+		return jmapMaxObjects, limit != nil
+	}
+	if *limit < 0 {
+		return 0, false
+	}
+	return *limit, false
+}
+
+type jmapRights struct {
+	MayReadItems   bool `json:"mayReadItems"`
+	MayAddItems    bool `json:"mayAddItems"`
+	MayRemoveItems bool `json:"mayRemoveItems"`
+	MaySetSeen     bool `json:"maySetSeen"`
+	MaySetKeywords bool `json:"maySetKeywords"`
+	MayCreateChild bool `json:"mayCreateChild"`
+	MayRename      bool `json:"mayRename"`
+	MayDelete      bool `json:"mayDelete"`
+	MaySubmit      bool `json:"maySubmit"`
+}
+
+type jmapMailbox struct {
+	Id            string     `json:"id"`
+	Name          string     `json:"name"`
+	ParentId      *string    `json:"parentId"`
+	Role          *string    `json:"role"`
+	SortOrder     int        `json:"sortOrder"`
+	TotalEmails   int        `json:"totalEmails"`
+	UnreadEmails  int        `json:"unreadEmails"`
+	TotalThreads  int        `json:"totalThreads"`
+	UnreadThreads int        `json:"unreadThreads"`
+	MyRights      jmapRights `json:"myRights"`
+	IsSubscribed  bool       `json:"isSubscribed"`
+}
+
+func jmapMailboxId(folderId int) string {
+	if folderId == 0 {
+		return jmapInboxId
+	}
+	return strconv.Itoa(folderId)
+}
+
+// jmapMailboxes returns the inbox followed by own folders, smart folders aren't mailboxes as they don't hold mails
+func (h *MailHandler) jmapMailboxes(ctx *jmapContext) ([]jmapMailbox, string, error) {
+	folders, err := h.MailUsecase.GetFolders(ctx.owner, ctx.ownerId)
+	if err != nil {
+		return nil, "", err
+	}
+	counters, err := h.MailUsecase.GetMailboxCounters(ctx.owner)
+	if err != nil {
+		return nil, "", err
+	}
+	countersOf := make(map[int]mail.MailboxCounters)
+	for _, c := range counters {
+		countersOf[c.FolderId] = c
+	}
+
+	role := "inbox"
+	mailboxes := []jmapMailbox{{
+		Id:       jmapInboxId,
+		Name:     "Inbox",
+		Role:     &role,
+		MyRights: jmapRights{true, true, true, true, false, false, false, false, true},
+	}}
+	for _, folder := range folders {
+		if folder.Smart != nil {
+			continue
+		}
+		mailbox := jmapMailbox{
+			Id:        jmapMailboxId(folder.Id),
+			Name:      folder.FolderName,
+			SortOrder: folder.Position,
+			MyRights:  jmapRights{true, true, true, true, false, true, true, true, true},
+		}
+		if folder.Parent != 0 {
+			parent := jmapMailboxId(folder.Parent)
+			mailbox.ParentId = &parent
+		}
+		mailboxes = append(mailboxes, mailbox)
+	}
+	for i := range mailboxes {
+		folderId, _ := strconv.Atoi(mailboxes[i].Id)
+		c := countersOf[folderId]
+		mailboxes[i].TotalEmails = c.Emails
+		mailboxes[i].UnreadEmails = c.UnreadEmails
+		mailboxes[i].TotalThreads = c.Threads
+		mailboxes[i].UnreadThreads = c.UnreadThreads
+		mailboxes[i].IsSubscribed = true
+	}
+
+	encoded, _ := json.Marshal(mailboxes)
+	return mailboxes, jmapHash(string(encoded)), nil
+}
+
+func (h *MailHandler) jmapMailboxGet(ctx *jmapContext, args map[string]json.RawMessage) (interface{}, *jmapError) {
+	var request struct {
+		Ids        *[]string `json:"ids"`
+		Properties []string  `json:"properties"`
+	}
+	if err := decodeJMAPArgs(args, &request); err != nil {
+		return nil, err
+	}
+	if request.Ids != nil && len(*request.Ids) > jmapMaxObjects {
+		return nil, &jmapError{Type: "requestTooLarge"}
+	}
+	mailboxes, state, err := h.jmapMailboxes(ctx)
+	if err != nil {
+		return nil, jmapServerFail(err)
+	}
+
+	list := mailboxes
+	notFound := make([]string, 0)
+	if request.Ids != nil {
+		list = make([]jmapMailbox, 0, len(*request.Ids))
+		for _, id := range *request.Ids {
+			found := false
+			for _, mailbox := range mailboxes {
+				if mailbox.Id == id {
+					list = append(list, mailbox)
+					found = true
+					break
+				}
+			}
+			if !found {
+				notFound = append(notFound, id)
+			}
+		}
+	}
+	selected, jerr := selectProperties(list, request.Properties, mailboxes[0])
+	if jerr != nil {
+		return nil, jerr
+	}
+	return echo.Map{"accountId": ctx.owner, "state": state, "list": selected, "notFound": notFound}, nil
+}
+
+type jmapComparator struct {
+	Property    string `json:"property"`
+	IsAscending *bool  `json:"isAscending"`
+}
+
+func (h *MailHandler) jmapMailboxQuery(ctx *jmapContext, args map[string]json.RawMessage) (interface{}, *jmapError) {
+	var request struct {
+		Filter   map[string]json.RawMessage `json:"filter"`
+		Sort     []jmapComparator           `json:"sort"`
+		Position int                        `json:"position"`
+		Limit    *int                       `json:"limit"`
+	}
+	if err := decodeJMAPArgs(args, &request); err != nil {
+		return nil, err
+	}
+	var parentId **string
+	for key, value := range request.Filter {
+		if key != "parentId" {
+			return nil, &jmapError{Type: "unsupportedFilter", Description: key + " isn't supported"}
+		}
+		var parent *string
+		json.Unmarshal(value, &parent)
+		parentId = &parent
+	}
+	for _, comparator := range request.Sort {
+		if comparator.Property != "sortOrder" {
+			return nil, &jmapError{Type: "unsupportedSort", Description: "mailboxes are sorted by sortOrder only"}
+		}
+	}
+
+	mailboxes, state, err := h.jmapMailboxes(ctx)
+	if err != nil {
+		return nil, jmapServerFail(err)
+	}
+	ids := make([]string, 0, len(mailboxes))
+	for _, mailbox := range mailboxes {
+		if parentId != nil {
+			if (*parentId == nil) != (mailbox.ParentId == nil) {
+				continue
+			}
+			if *parentId != nil && **parentId != *mailbox.ParentId {
+				continue
+			}
+		}
+		ids = append(ids, mailbox.Id)
+	}
+	limit, capped := jmapLimit(request.Limit)
+	page, position := pageIds(ids, request.Position, limit)
+	response := echo.Map{
+		"accountId":           ctx.owner,
+		"queryState":          state,
+		"canCalculateChanges": false,
+		"position":            position,
+		"ids":                 page,
+		"total":               len(ids),
+	}
+	if capped {
+		response["limit"] = limit
+	}
+	return response, nil
+}
+
+// jmapFolderId resolves mailbox id or creation reference to own folder, 0 stands for the inbox
+func (h *MailHandler) jmapFolderId(ctx *jmapContext, id string) (int, bool) {
+	if strings.HasPrefix(id, "#") {
+		created, ok := ctx.createdIds[strings.TrimPrefix(id, "#")]
+		if !ok {
+			return 0, false
+		}
+		id = created
+	}
+	if id == jmapInboxId {
+		return 0, true
+	}
+	folderId, err := strconv.Atoi(id)
+	if err != nil || folderId <= 0 {
+		return 0, false
+	}
+	return folderId, true
+}
+
+func mailboxSetError(err error) *jmapError {
+	switch err.(type) {
+	case mail.InvalidEmailError, common.InvalidUserError:
+		return jmapInvalidProperties(err.Error())
+	default:
+		return jmapServerFail(err)
+	}
+}
+
+// jmapMailboxSet manages folders, emails of destroyed mailbox are moved to the inbox
+func (h *MailHandler) jmapMailboxSet(ctx *jmapContext, args map[string]json.RawMessage) (interface{}, *jmapError) {
+	var request struct {
+		IfInState *string `json:"ifInState"`
+		Create    map[string]struct {
+			Name     string  `json:"name"`
+			ParentId *string `json:"parentId"`
+		} `json:"create"`
+		Update  map[string]map[string]json.RawMessage `json:"update"`
+		Destroy []string                              `json:"destroy"`
+	}
+	if err := decodeJMAPArgs(args, &request); err != nil {
+		return nil, err
+	}
+	if len(request.Create)+len(request.Update)+len(request.Destroy) > jmapMaxObjects {
+		return nil, &jmapError{Type: "requestTooLarge"}
+	}
+	mailboxes, oldState, err := h.jmapMailboxes(ctx)
+	if err != nil {
+		return nil, jmapServerFail(err)
+	}
+	if request.IfInState != nil && *request.IfInState != oldState {
+		return nil, &jmapError{Type: "stateMismatch"}
+	}
+
+	created := make(map[string]interface{})
+	notCreated := make(map[string]*jmapError)
+	for creationId, create := range request.Create {
+		parentId := 0
+		if create.ParentId != nil {
+			var ok bool
+			parentId, ok = h.jmapFolderId(ctx, *create.ParentId)
+			if !ok || parentId == 0 {
+				notCreated[creationId] = jmapInvalidProperties("unknown parent", "parentId")
+				continue
+			}
+		}
+		folder, err := h.MailUsecase.CreateFolder(ctx.ownerId, create.Name, parentId)
+		if err != nil {
+			notCreated[creationId] = mailboxSetError(err)
+			continue
+		}
+		id := jmapMailboxId(folder.Id)
+		ctx.createdIds[creationId] = id
+		created[creationId] = echo.Map{"id": id}
+	}
+
+	updated := make(map[string]interface{})
+	notUpdated := make(map[string]*jmapError)
+	for id, patch := range request.Update {
+		folderId, ok := h.jmapFolderId(ctx, id)
+		var current *jmapMailbox
+		for i := range mailboxes {
+			if mailboxes[i].Id == jmapMailboxId(folderId) {
+				current = &mailboxes[i]
+			}
+		}
+		if !ok || current == nil {
+			notUpdated[id] = &jmapError{Type: "notFound"}
+			continue
+		}
+		if folderId == 0 {
+			notUpdated[id] = &jmapError{Type: "forbidden", Description: "inbox can't be changed"}
+			continue
+		}
+		if setErr := h.jmapUpdateMailbox(ctx, folderId, *current, patch); setErr != nil {
+			notUpdated[id] = setErr
+			continue
+		}
+		updated[id] = nil
+	}
+
+	destroyed := make([]string, 0)
+	notDestroyed := make(map[string]*jmapError)
+	for _, id := range request.Destroy {
+		folderId, ok := h.jmapFolderId(ctx, id)
+		if !ok {
+			notDestroyed[id] = &jmapError{Type: "notFound"}
+			continue
+		}
+		if folderId == 0 {
+			notDestroyed[id] = &jmapError{Type: "forbidden", Description: "inbox can't be destroyed"}
+			continue
+		}
+		err := h.MailUsecase.DeleteFolder(ctx.owner, ctx.ownerId, folderId)
+		if err != nil {
+			if _, ok := err.(mail.InvalidEmailError); ok {
+				notDestroyed[id] = &jmapError{Type: "notFound"}
+			} else {
+				notDestroyed[id] = jmapServerFail(err)
+			}
+			continue
+		}
+		destroyed = append(destroyed, id)
+	}
+
+	_, newState, err := h.jmapMailboxes(ctx)
+	if err != nil {
+		return nil, jmapServerFail(err)
+	}
+	return echo.Map{
+		"accountId":    ctx.owner,
+		"oldState":     oldState,
+		"newState":     newState,
+		"created":      created,
+		"updated":      updated,
+		"destroyed":    destroyed,
+		"notCreated":   notCreated,
+		"notUpdated":   notUpdated,
+		"notDestroyed": notDestroyed,
+	}, nil
+}
+
+func (h *MailHandler) jmapUpdateMailbox(ctx *jmapContext, folderId int, current jmapMailbox, patch map[string]json.RawMessage) *jmapError {
+	parentId := 0
+	if current.ParentId != nil {
+		parentId, _ = strconv.Atoi(*current.ParentId)
+	}
+	position := current.SortOrder
+	moved := false
+	for property, value := range patch {
+		switch property {
+		case "name":
+			var name string
+			if json.Unmarshal(value, &name) != nil {
+				return jmapInvalidProperties("name must be a string", property)
+			}
+			_, err := h.MailUsecase.UpdateFolderName(ctx.ownerId, folderId, name)
+			if err != nil {
+				return mailboxSetError(err)
+			}
+		case "parentId":
+			var parent *string
+			if json.Unmarshal(value, &parent) != nil {
+				return jmapInvalidProperties("parentId must be an id", property)
+			}
+			parentId = 0
+			if parent != nil {
+				var ok bool
+				parentId, ok = h.jmapFolderId(ctx, *parent)
+				if !ok || parentId == 0 {
+					return jmapInvalidProperties("unknown parent", property)
+				}
+			}
+			moved = true
+		case "sortOrder":
+			if json.Unmarshal(value, &position) != nil || position < 0 {
+				return jmapInvalidProperties("sortOrder must be a non-negative number", property)
+			}
+			moved = true
+		default:
+			return jmapInvalidProperties(property+" can't be changed", property)
+		}
+	}
+	if moved {
+		_, err := h.MailUsecase.MoveFolder(ctx.ownerId, folderId, parentId, position)
+		if err != nil {
+			return mailboxSetError(err)
+		}
+	}
+	return nil
+}
+
+func (h *MailHandler) jmapThreadGet(ctx *jmapContext, args map[string]json.RawMessage) (interface{}, *jmapError) {
+	var request struct {
+		Ids []string `json:"ids"`
+	}
+	if err := decodeJMAPArgs(args, &request); err != nil {
+		return nil, err
+	}
+	if request.Ids == nil {
+		return nil, jmapInvalidArguments("ids of threads must be set")
+	}
+	if len(request.Ids) > jmapMaxObjects {
+		return nil, &jmapError{Type: "requestTooLarge"}
+	}
+	state, err := h.MailUsecase.GetSyncToken(ctx.owner, ctx.ownerId)
+	if err != nil {
+		return nil, jmapServerFail(err)
+	}
+
+	dialogueIds := make([]int, 0, len(request.Ids))
+	for _, id := range request.Ids {
+		if dialogueId, err := strconv.Atoi(id); err == nil && dialogueId > 0 {
+			dialogueIds = append(dialogueIds, dialogueId)
+		}
+	}
+	threads := make(map[int][]string)
+	if len(dialogueIds) > 0 {
+		emails, err := h.MailUsecase.GetMailboxEmails(ctx.owner, mail.MailFilter{DialogueIds: dialogueIds})
+		if err != nil {
+			return nil, jmapServerFail(err)
+		}
+		// emails of the thread are sorted from the oldest
+		for i := len(emails) - 1; i >= 0; i-- {
+			threads[emails[i].DialogueId] = append(threads[emails[i].DialogueId], strconv.Itoa(emails[i].Id))
+		}
+	}
+
+	list := make([]echo.Map, 0, len(request.Ids))
+	notFound := make([]string, 0)
+	for _, id := range request.Ids {
+		dialogueId, _ := strconv.Atoi(id)
+		emailIds, ok := threads[dialogueId]
+		if !ok || strconv.Itoa(dialogueId) != id {
+			notFound = append(notFound, id)
+			continue
+		}
+		list = append(list, echo.Map{"id": id, "emailIds": emailIds})
+	}
+	return echo.Map{"accountId": ctx.owner, "state": state, "list": list, "notFound": notFound}, nil
+}
+
+type jmapAddress struct {
+	Name  *string `json:"name"`
+	Email string  `json:"email"`
+}
+
+type jmapBodyPart struct {
+	PartId  string  `json:"partId"`
+	BlobId  *string `json:"blobId"`
+	Size    int     `json:"size"`
+	Type    string  `json:"type"`
+	Charset string  `json:"charset"`
+}
+
+type jmapBodyValue struct {
+	Value             string `json:"value"`
+	IsEncodingProblem bool   `json:"isEncodingProblem"`
+	IsTruncated       bool   `json:"isTruncated"`
+}
+
+type jmapEmail struct {
+	Id            string                   `json:"id"`
+	BlobId        string                   `json:"blobId"`
+	ThreadId      string                   `json:"threadId"`
+	MailboxIds    map[string]bool          `json:"mailboxIds"`
+	Keywords      map[string]bool          `json:"keywords"`
+	Size          int                      `json:"size"`
+	ReceivedAt    string                   `json:"receivedAt"`
+	MessageId     []string                 `json:"messageId"`
+	InReplyTo     []string                 `json:"inReplyTo"`
+	References    []string                 `json:"references"`
+	From          []jmapAddress            `json:"from"`
+	To            []jmapAddress            `json:"to"`
+	Subject       string                   `json:"subject"`
+	SentAt        string                   `json:"sentAt"`
+	HasAttachment bool                     `json:"hasAttachment"`
+	Preview       string                   `json:"preview"`
+	BodyValues    map[string]jmapBodyValue `json:"bodyValues"`
+	TextBody      []jmapBodyPart           `json:"textBody"`
+	HtmlBody      []jmapBodyPart           `json:"htmlBody"`
+	Attachments   []jmapBodyPart           `json:"attachments"`
+}
+
+func messageIdList(ids string) []string {
+	fields := strings.Fields(ids)
+	if len(fields) == 0 {
+		return nil
+	}
+	for i, id := range fields {
+		fields[i] = strings.Trim(id, "<>")
+	}
+	return fields
+}
+
+func emailPreview(body string) string {
+	text := strings.Join(strings.Fields(html.UnescapeString(jmapPreviewPolicy.Sanitize(body))), " ")
+	runes := []rune(text)
+	if len(runes) > jmapPreviewLength {
+		return string(runes[:jmapPreviewLength])
+	}
+	return text
+}
+
+func isSeen(ctx *jmapContext, email mail.MailboxEmail) bool {
+	return !email.Unread || !strings.EqualFold(email.Recipient, ctx.address)
+}
+
+func toJMAPEmail(ctx *jmapContext, email mail.MailboxEmail, fetchBody bool, maxBodyBytes int) jmapEmail {
+	keywords := make(map[string]bool)
+	if isSeen(ctx, email) {
+		keywords["$seen"] = true
+	}
+	references := messageIdList(email.References.String)
+	var inReplyTo []string
+	if len(references) > 0 {
+		inReplyTo = references[len(references)-1:]
+	}
+	id := strconv.Itoa(email.Id)
+	bodyValues := make(map[string]jmapBodyValue)
+	if fetchBody {
+		value := jmapBodyValue{Value: email.Body}
+		if maxBodyBytes > 0 && len(value.Value) > maxBodyBytes {
+			value.Value = strings.ToValidUTF8(value.Value[:maxBodyBytes], "")
+			value.IsTruncated = true
+		}
+		bodyValues["1"] = value
+	}
+	// the stored body is the only html part, it stands for the text body too
+	part := jmapBodyPart{PartId: "1", Size: len(email.Body), Type: "text/html", Charset: "utf-8"}
+	return jmapEmail{
+		Id:            id,
+		BlobId:        "M" + id,
+		ThreadId:      strconv.Itoa(email.DialogueId),
+		MailboxIds:    map[string]bool{jmapMailboxId(email.FolderId): true},
+		Keywords:      keywords,
+		Size:          len(email.Body),
+		ReceivedAt:    email.Received_date.UTC().Format(jmapDateFormat),
+		MessageId:     messageIdList(email.HeaderMessageId()),
+		InReplyTo:     inReplyTo,
+		References:    references,
+		From:          []jmapAddress{{Email: email.Sender}},
+		To:            []jmapAddress{{Email: email.Recipient}},
+		Subject:       email.Subject,
+		SentAt:        email.Received_date.Format(time.RFC3339),
+		HasAttachment: false,
+		Preview:       emailPreview(email.Body),
+		BodyValues:    bodyValues,
+		TextBody:      []jmapBodyPart{part},
+		HtmlBody:      []jmapBodyPart{part},
+		Attachments:   []jmapBodyPart{},
+	}
+}
+
+func (h *MailHandler) jmapEmailGet(ctx *jmapContext, args map[string]json.RawMessage) (interface{}, *jmapError) {
+	var request struct {
+		Ids                 *[]string `json:"ids"`
+		Properties          []string  `json:"properties"`
+		FetchTextBodyValues bool      `json:"fetchTextBodyValues"`
+		FetchHTMLBodyValues bool      `json:"fetchHTMLBodyValues"`
+		FetchAllBodyValues  bool      `json:"fetchAllBodyValues"`
+		MaxBodyValueBytes   int       `json:"maxBodyValueBytes"`
+	}
+	if err := decodeJMAPArgs(args, &request); err != nil {
+		return nil, err
+	}
+	filter := mail.MailFilter{Limit: jmapMaxObjects}
+	notFound := make([]string, 0)
+	if request.Ids != nil {
+		if len(*request.Ids) > jmapMaxObjects {
+			return nil, &jmapError{Type: "requestTooLarge"}
+		}
+		filter.Ids = make([]int, 0, len(*request.Ids))
+		for _, id := range *request.Ids {
+			mailId, err := strconv.Atoi(id)
+			if err != nil || mailId <= 0 {
+				notFound = append(notFound, id)
+				continue
+			}
+			filter.Ids = append(filter.Ids, mailId)
+		}
+	}
+	state, err := h.MailUsecase.GetSyncToken(ctx.owner, ctx.ownerId)
+	if err != nil {
+		return nil, jmapServerFail(err)
+	}
+
+	var emails []mail.MailboxEmail
+	if request.Ids == nil || len(filter.Ids) > 0 {
+		emails, err = h.MailUsecase.GetMailboxEmails(ctx.owner, filter)
+		if err != nil {
+			return nil, jmapServerFail(err)
+		}
+	}
+	fetchBody := request.FetchTextBodyValues || request.FetchHTMLBodyValues || request.FetchAllBodyValues
+	byId := make(map[int]jmapEmail)
+	list := make([]jmapEmail, 0, len(emails))
+	for _, email := range emails {
+		converted := toJMAPEmail(ctx, email, fetchBody, request.MaxBodyValueBytes)
+		byId[email.Id] = converted
+		list = append(list, converted)
+	}
+	if request.Ids != nil {
+		// the list follows order of the requested ids
+		list = list[:0]
+		for _, mailId := range filter.Ids {
+			if email, ok := byId[mailId]; ok {
+				list = append(list, email)
+			} else {
+				notFound = append(notFound, strconv.Itoa(mailId))
+			}
+		}
+	}
+	selected, jerr := selectProperties(list, request.Properties, jmapEmail{})
+	if jerr != nil {
+		return nil, jerr
+	}
+	return echo.Map{"accountId": ctx.owner, "state": state, "list": selected, "notFound": notFound}, nil
+}
+
+func (h *MailHandler) jmapEmailQuery(ctx *jmapContext, args map[string]json.RawMessage) (interface{}, *jmapError) {
+	var request struct {
+		Filter          map[string]json.RawMessage `json:"filter"`
+		Sort            []jmapComparator           `json:"sort"`
+		Position        int                        `json:"position"`
+		Anchor          *string                    `json:"anchor"`
+		Limit           *int                       `json:"limit"`
+		CollapseThreads bool                       `json:"collapseThreads"`
+		CalculateTotal  bool                       `json:"calculateTotal"`
+	}
+	if err := decodeJMAPArgs(args, &request); err != nil {
+		return nil, err
+	}
+	if request.Anchor != nil {
+		return nil, jmapInvalidArguments("anchor isn't supported")
+	}
+	var filter mail.MailFilter
+	for key, value := range request.Filter {
+		if key != "inMailbox" {
+			return nil, &jmapError{Type: "unsupportedFilter", Description: key + " isn't supported"}
+		}
+		var mailboxId string
+		json.Unmarshal(value, &mailboxId)
+		folderId, ok := h.jmapFolderId(ctx, mailboxId)
+		if !ok {
+			return nil, &jmapError{Type: "unsupportedFilter", Description: "unknown mailbox " + mailboxId}
+		}
+		filter.FolderId = &folderId
+	}
+	for _, comparator := range request.Sort {
+		if comparator.Property != "receivedAt" || comparator.IsAscending == nil || *comparator.IsAscending {
+			return nil, &jmapError{Type: "unsupportedSort", Description: "emails are sorted by receivedAt descending only"}
+		}
+	}
+	state, err := h.MailUsecase.GetSyncToken(ctx.owner, ctx.ownerId)
+	if err != nil {
+		return nil, jmapServerFail(err)
+	}
+
+	limit, capped := jmapLimit(request.Limit)
+	filter.CollapseThreads = request.CollapseThreads
+	position := request.Position
+	total := 0
+	if request.CalculateTotal || position < 0 {
+		total, err = h.MailUsecase.CountMailboxEmails(ctx.owner, filter)
+		if err != nil {
+			return nil, jmapServerFail(err)
+		}
+		// negative position counts from the end
+		if position < 0 {
+			position += total
+			if position < 0 {
+				position = 0
+			}
+		}
+	}
+	ids := make([]string, 0, limit)
+	if limit > 0 {
+		filter.Limit = limit
+		filter.Offset = position
+		emailIds, err := h.MailUsecase.GetMailboxEmailIds(ctx.owner, filter)
+		if err != nil {
+			return nil, jmapServerFail(err)
+		}
+		for _, id := range emailIds {
+			ids = append(ids, strconv.Itoa(id))
+		}
+	}
+	response := echo.Map{
+		"accountId":           ctx.owner,
+		"queryState":          state,
+		"canCalculateChanges": false,
+		"position":            position,
+		"ids":                 ids,
+	}
+	if request.CalculateTotal {
+		response["total"] = total
+	}
+	if capped {
+		response["limit"] = limit
+	}
+	return response, nil
+}
+
+type jmapEmailCreate struct {
+	To         []jmapAddress            `json:"to"`
+	Subject    string                   `json:"subject"`
+	TextBody   []jmapBodyPart           `json:"textBody"`
+	HtmlBody   []jmapBodyPart           `json:"htmlBody"`
+	BodyValues map[string]jmapBodyValue `json:"bodyValues"`
+}
+
+// jmapCreateDraft keeps the email for EmailSubmission/set of the request, drafts aren't stored.
+// Text body is sent as markdown, like mails composed in the web client
+func jmapCreateDraft(ctx *jmapContext, create jmapEmailCreate) (string, *jmapError) {
+	if len(create.To) != 1 {
+		return "", jmapInvalidProperties("exactly one recipient is supported", "to")
+	}
+	if len(create.HtmlBody) > 0 {
+		return "", jmapInvalidProperties("only text body is supported", "htmlBody")
+	}
+	if len(create.TextBody) != 1 {
+		return "", jmapInvalidProperties("exactly one text body part is required", "textBody")
+	}
+	body, ok := create.BodyValues[create.TextBody[0].PartId]
+	if !ok {
+		return "", jmapInvalidProperties("text body value is missing", "bodyValues")
+	}
+	id := fmt.Sprintf("draft%d", len(ctx.drafts)+1)
+	ctx.drafts[id] = mail.Mail{
+		Sender:    ctx.owner,
+		Recipient: create.To[0].Email,
+		Subject:   create.Subject,
+		Body:      body.Value,
+	}
+	return id, nil
+}
+
+// jmapEmailSet changes mails the way the web client does: reading a mail reads the whole dialogue
+// and moving a mail moves its dialogue, mails can't be marked unread
+func (h *MailHandler) jmapEmailSet(ctx *jmapContext, args map[string]json.RawMessage) (interface{}, *jmapError) {
+	var request struct {
+		IfInState *string                               `json:"ifInState"`
+		Create    map[string]jmapEmailCreate            `json:"create"`
+		Update    map[string]map[string]json.RawMessage `json:"update"`
+		Destroy   []string                              `json:"destroy"`
+	}
+	if err := decodeJMAPArgs(args, &request); err != nil {
+		return nil, err
+	}
+	if len(request.Create)+len(request.Update)+len(request.Destroy) > jmapMaxObjects {
+		return nil, &jmapError{Type: "requestTooLarge"}
+	}
+	oldState, err := h.MailUsecase.GetSyncToken(ctx.owner, ctx.ownerId)
+	if err != nil {
+		return nil, jmapServerFail(err)
+	}
+	if request.IfInState != nil && *request.IfInState != oldState {
+		return nil, &jmapError{Type: "stateMismatch"}
+	}
+
+	created := make(map[string]interface{})
+	notCreated := make(map[string]*jmapError)
+	for creationId, create := range request.Create {
+		id, setErr := jmapCreateDraft(ctx, create)
+		if setErr != nil {
+			notCreated[creationId] = setErr
+			continue
+		}
+		ctx.createdIds[creationId] = id
+		created[creationId] = echo.Map{"id": id, "size": len(ctx.drafts[id].Body)}
+	}
+
+	updated := make(map[string]interface{})
+	notUpdated := make(map[string]*jmapError)
+	for id, patch := range request.Update {
+		email, found, err := h.jmapFindEmail(ctx, id)
+		if err != nil {
+			return nil, jmapServerFail(err)
+		}
+		if !found {
+			notUpdated[id] = &jmapError{Type: "notFound"}
+			continue
+		}
+		if setErr := h.jmapUpdateEmail(ctx, email, patch); setErr != nil {
+			notUpdated[id] = setErr
+			continue
+		}
+		updated[id] = nil
+	}
+
+	destroyed := make([]string, 0)
+	notDestroyed := make(map[string]*jmapError)
+	mailIds := make([]int, 0, len(request.Destroy))
+	for _, id := range request.Destroy {
+		email, found, err := h.jmapFindEmail(ctx, id)
+		if err != nil {
+			return nil, jmapServerFail(err)
+		}
+		if !found {
+			notDestroyed[id] = &jmapError{Type: "notFound"}
+			continue
+		}
+		mailIds = append(mailIds, email.Id)
+		destroyed = append(destroyed, id)
+	}
+	if len(mailIds) > 0 {
+		err = h.MailUsecase.DeleteMails(ctx.owner, mailIds)
+		if err != nil {
+			return nil, jmapServerFail(err)
+		}
+	}
+
+	newState, err := h.MailUsecase.GetSyncToken(ctx.owner, ctx.ownerId)
+	if err != nil {
+		return nil, jmapServerFail(err)
+	}
+	return echo.Map{
+		"accountId":    ctx.owner,
+		"oldState":     oldState,
+		"newState":     newState,
+		"created":      created,
+		"updated":      updated,
+		"destroyed":    destroyed,
+		"notCreated":   notCreated,
+		"notUpdated":   notUpdated,
+		"notDestroyed": notDestroyed,
+	}, nil
+}
+
+func (h *MailHandler) jmapFindEmail(ctx *jmapContext, id string) (mail.MailboxEmail, bool, error) {
+	mailId, err := strconv.Atoi(id)
+	if err != nil || mailId <= 0 {
+		return mail.MailboxEmail{}, false, nil
+	}
+	emails, err := h.MailUsecase.GetMailboxEmails(ctx.owner, mail.MailFilter{Ids: []int{mailId}})
+	if err != nil || len(emails) == 0 {
+		return mail.MailboxEmail{}, false, err
+	}
+	return emails[0], true, nil
+}
+
+func (h *MailHandler) jmapUpdateEmail(ctx *jmapContext, email mail.MailboxEmail, patch map[string]json.RawMessage) *jmapError {
+	var seen *bool
+	target := ""
+	removesCurrent := false
+	for property, value := range patch {
+		switch {
+		case property == "keywords":
+			var keywords map[string]bool
+			if json.Unmarshal(value, &keywords) != nil {
+				return jmapInvalidProperties("keywords must be an object", property)
+			}
+			for keyword := range keywords {
+				if keyword != "$seen" {
+					return jmapInvalidProperties("only $seen keyword is supported", property)
+				}
+			}
+			s := keywords["$seen"]
+			seen = &s
+		case property == "keywords/$seen":
+			var s *bool
+			json.Unmarshal(value, &s)
+			seen = new(bool)
+			*seen = s != nil && *s
+		case property == "mailboxIds":
+			var mailboxIds map[string]bool
+			if json.Unmarshal(value, &mailboxIds) != nil || len(mailboxIds) != 1 {
+				return jmapInvalidProperties("email belongs to exactly one mailbox", property)
+			}
+			for mailboxId := range mailboxIds {
+				target = mailboxId
+			}
+		case strings.HasPrefix(property, "mailboxIds/"):
+			mailboxId := strings.TrimPrefix(property, "mailboxIds/")
+			if string(value) == "null" {
+				removesCurrent = removesCurrent || mailboxId == jmapMailboxId(email.FolderId)
+			} else {
+				target = mailboxId
+			}
+		default:
+			return jmapInvalidProperties(property+" can't be changed", property)
+		}
+	}
+	if removesCurrent && target == "" {
+		return jmapInvalidProperties("email belongs to exactly one mailbox", "mailboxIds")
+	}
+
+	if seen != nil {
+		if !*seen && isSeen(ctx, email) {
+			return jmapInvalidProperties("emails can't be marked unread", "keywords")
+		}
+		if *seen && !isSeen(ctx, email) {
+			err := h.MailUsecase.ReadDialogueMails(ctx.owner, email.Sender)
+			if err != nil {
+				return jmapServerFail(err)
+			}
+		}
+	}
+	if target != "" && target != jmapMailboxId(email.FolderId) {
+		folderId, ok := h.jmapFolderId(ctx, target)
+		if !ok {
+			return jmapInvalidProperties("unknown mailbox", "mailboxIds")
+		}
+		if folderId != 0 {
+			mailboxes, _, err := h.jmapMailboxes(ctx)
+			if err != nil {
+				return jmapServerFail(err)
+			}
+			own := false
+			for _, mailbox := range mailboxes {
+				own = own || mailbox.Id == jmapMailboxId(folderId)
+			}
+			if !own {
+				return jmapInvalidProperties("unknown mailbox", "mailboxIds")
+			}
+		}
+		err := h.MailUsecase.UpdateFolderPutDialogue(ctx.owner, folderId, email.DialogueId)
+		if err != nil {
+			return mailboxSetError(err)
+		}
+	}
+	return nil
+}
+
+func (h *MailHandler) jmapIdentityGet(ctx *jmapContext, args map[string]json.RawMessage) (interface{}, *jmapError) {
+	var request struct {
+		Ids *[]string `json:"ids"`
+	}
+	if err := decodeJMAPArgs(args, &request); err != nil {
+		return nil, err
+	}
+	list := make([]echo.Map, 0, 1)
+	notFound := make([]string, 0)
+	identity := echo.Map{
+		"id":            ctx.owner,
+		"name":          "",
+		"email":         ctx.address,
+		"replyTo":       nil,
+		"bcc":           nil,
+		"textSignature": "",
+		"htmlSignature": "",
+		"mayDelete":     false,
+	}
+	if request.Ids == nil {
+		list = append(list, identity)
+	} else {
+		for _, id := range *request.Ids {
+			if id == ctx.owner {
+				list = append(list, identity)
+			} else {
+				notFound = append(notFound, id)
+			}
+		}
+	}
+	return echo.Map{"accountId": ctx.owner, "state": jmapHash(ctx.address), "list": list, "notFound": notFound}, nil
+}
+
+// jmapEmailSubmissionSet sends emails created earlier in the same request, submissions are final immediately
+func (h *MailHandler) jmapEmailSubmissionSet(ctx *jmapContext, args map[string]json.RawMessage) (interface{}, *jmapError) {
+	var request struct {
+		Create map[string]struct {
+			IdentityId string `json:"identityId"`
+			EmailId    string `json:"emailId"`
+		} `json:"create"`
+		Update  map[string]json.RawMessage `json:"update"`
+		Destroy []string                   `json:"destroy"`
+	}
+	if err := decodeJMAPArgs(args, &request); err != nil {
+		return nil, err
+	}
+	state, err := h.MailUsecase.GetSyncToken(ctx.owner, ctx.ownerId)
+	if err != nil {
+		return nil, jmapServerFail(err)
+	}
+
+	created := make(map[string]interface{})
+	notCreated := make(map[string]*jmapError)
+	for creationId, create := range request.Create {
+		if create.IdentityId != ctx.owner {
+			notCreated[creationId] = jmapInvalidProperties("unknown identity", "identityId")
+			continue
+		}
+		emailId := create.EmailId
+		if strings.HasPrefix(emailId, "#") {
+			emailId = ctx.createdIds[strings.TrimPrefix(emailId, "#")]
+		}
+		draft, ok := ctx.drafts[emailId]
+		if !ok {
+			notCreated[creationId] = jmapInvalidProperties(
+				"only emails created by Email/set of the same request can be submitted",
+				"emailId",
+			)
+			continue
+		}
+		sent, err := h.MailUsecase.SendEmail(draft)
+		if err != nil {
+			notCreated[creationId] = mailboxSetError(err)
+			continue
+		}
+		delete(ctx.drafts, emailId)
+		id := strconv.Itoa(sent.Id)
+		ctx.createdIds[creationId] = id
+		created[creationId] = echo.Map{
+			"id":         id,
+			"emailId":    id,
+			"sendAt":     time.Now().UTC().Format(jmapDateFormat),
+			"undoStatus": "final",
+		}
+	}
+
+	notUpdated := make(map[string]*jmapError)
+	for id := range request.Update {
+		notUpdated[id] = &jmapError{Type: "notFound"}
+	}
+	notDestroyed := make(map[string]*jmapError)
+	for _, id := range request.Destroy {
+		notDestroyed[id] = &jmapError{Type: "notFound"}
+	}
+
+	newState, err := h.MailUsecase.GetSyncToken(ctx.owner, ctx.ownerId)
+	if err != nil {
+		return nil, jmapServerFail(err)
+	}
+	return echo.Map{
+		"accountId":    ctx.owner,
+		"oldState":     state,
+		"newState":     newState,
+		"created":      created,
+		"updated":      echo.Map{},
+		"destroyed":    []string{},
+		"notCreated":   notCreated,
+		"notUpdated":   notUpdated,
+		"notDestroyed": notDestroyed,
+	}, nil
+}
+
+// JMAPDownload returns the email blob as message built from the stored mail
+func (h *MailHandler) JMAPDownload(c echo.Context) error {
+	sUser := c.Get("sessionUser")
+	sessionUser, ok := sUser.(user.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+	if c.Param("account") != sessionUser.Username || !strings.HasPrefix(c.Param("blob"), "M") {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	mailId, err := strconv.Atoi(strings.TrimPrefix(c.Param("blob"), "M"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	emails, err := h.MailUsecase.GetMailboxEmails(sessionUser.Username, mail.MailFilter{Ids: []int{mailId}})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if len(emails) == 0 {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	email := emails[0]
+	message := utils.FormatMessage(utils.StoredMessage{
+		From:       email.Sender,
+		To:         email.Recipient,
+		Subject:    email.Subject,
+		Date:       email.Received_date,
+		MessageId:  email.HeaderMessageId(),
+		References: email.References.String,
+		Html:       email.Body,
+	})
+	// requested type is ignored, so the message is never rendered by the browser
+	c.Response().Header().Set(
+		echo.HeaderContentDisposition,
+		mime.FormatMediaType("attachment", map[string]string{"filename": c.Param("name")}),
+	)
+	return c.Blob(http.StatusOK, "message/rfc822", message)
+}
@@ -0,0 +1,309 @@
+package delivery
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"liokor_mail/internal/pkg/common"
+	"liokor_mail/internal/pkg/mail"
+	mailMocks "liokor_mail/internal/pkg/mail/mocks"
+	"liokor_mail/internal/pkg/user"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var jmapUser = user.User{Id: 1, Username: "liokor"}
+
+// jmapCall posts the request and returns arguments of the method responses by call id
+func jmapCall(t *testing.T, mailHandler MailHandler, body string) map[string][]interface{} {
+	req := httptest.NewRequest("POST", JMAPApiPath, strings.NewReader(body))
+	response := httptest.NewRecorder()
+	echoContext := echo.New().NewContext(req, response)
+	echoContext.Set("sessionUser", jmapUser)
+
+	err := mailHandler.JMAP(echoContext)
+	if err != nil || response.Code != http.StatusOK {
+		t.Fatalf("Didn't process valid request: %v %s\n", err, response.Body.String())
+	}
+	var result struct {
+		MethodResponses [][]json.RawMessage `json:"methodResponses"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &result)
+	responses := make(map[string][]interface{})
+	for _, r := range result.MethodResponses {
+		var name, callId string
+		var args interface{}
+		json.Unmarshal(r[0], &name)
+		json.Unmarshal(r[1], &args)
+		json.Unmarshal(r[2], &callId)
+		responses[callId] = []interface{}{name, args}
+	}
+	return responses
+}
+
+func TestJMAPSession(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{mockMailUC}
+
+	req := httptest.NewRequest("GET", "http://mail.liokor.ru"+JMAPSessionPath, nil)
+	response := httptest.NewRecorder()
+	echoContext := echo.New().NewContext(req, response)
+	echoContext.Set("sessionUser", jmapUser)
+
+	mockMailUC.EXPECT().GetAddress("liokor").Return("liokor@liokor.ru", nil).Times(1)
+	err := mailHandler.JMAPSession(echoContext)
+	if err != nil {
+		t.Fatalf("Didn't return session: %v\n", err)
+	}
+	var session struct {
+		ApiUrl          string                 `json:"apiUrl"`
+		Accounts        map[string]interface{} `json:"accounts"`
+		PrimaryAccounts map[string]string      `json:"primaryAccounts"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &session)
+	if session.ApiUrl != "http://mail.liokor.ru/jmap" || session.Accounts["liokor"] == nil || session.PrimaryAccounts[jmapMail] != "liokor" {
+		t.Errorf("Wrong session: %s\n", response.Body.String())
+	}
+}
+
+func TestJMAPEmailQueryAndGet(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{mockMailUC}
+
+	received := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	emails := []mail.MailboxEmail{
+		{
+			DialogueEmail: mail.DialogueEmail{
+				Id:            7,
+				Sender:        "friend@ya.ru",
+				Recipient:     "liokor@liokor.ru",
+				Subject:       "Hello",
+				Body:          "<p>How are you?</p><p>Bye</p>",
+				Received_date: received,
+				Unread:        true,
+				MessageId:     common.NullString{sql.NullString{String: "<abc@ya.ru>", Valid: true}},
+			},
+			DialogueId: 3,
+		},
+		{
+			DialogueEmail: mail.DialogueEmail{
+				Id:            5,
+				Sender:        "liokor@liokor.ru",
+				Recipient:     "friend@ya.ru",
+				Subject:       "Hi",
+				Body:          "Hi",
+				Received_date: received.Add(-time.Hour),
+			},
+			DialogueId: 3,
+		},
+	}
+	inbox := 0
+	mockMailUC.EXPECT().GetAddress("liokor").Return("liokor@liokor.ru", nil).Times(1)
+	mockMailUC.EXPECT().GetSyncToken("liokor", 1).Return("state", nil).Times(3)
+	mockMailUC.EXPECT().GetMailboxEmailIds("liokor", mail.MailFilter{FolderId: &inbox, Limit: 10}).Return([]int{7, 5}, nil).Times(1)
+	collapsed := mail.MailFilter{FolderId: &inbox, CollapseThreads: true}
+	mockMailUC.EXPECT().CountMailboxEmails("liokor", collapsed).Return(3, nil).Times(1)
+	collapsed.Limit, collapsed.Offset = 5, 2
+	mockMailUC.EXPECT().GetMailboxEmailIds("liokor", collapsed).Return([]int{4}, nil).Times(1)
+	mockMailUC.EXPECT().GetMailboxEmails("liokor", mail.MailFilter{Ids: []int{7, 5}, Limit: jmapMaxObjects}).Return(emails, nil).Times(1)
+
+	responses := jmapCall(t, mailHandler, `{
+		"using": ["urn:ietf:params:jmap:core", "urn:ietf:params:jmap:mail"],
+		"methodCalls": [
+			["Email/query", {"accountId": "liokor", "filter": {"inMailbox": "inbox"}, "limit": 10}, "q"],
+			["Email/query", {
+				"accountId": "liokor",
+				"filter": {"inMailbox": "inbox"},
+				"position": -1,
+				"limit": 5,
+				"collapseThreads": true,
+				"calculateTotal": true
+			}, "c"],
+			["Email/get", {
+				"accountId": "liokor",
+				"#ids": {"resultOf": "q", "name": "Email/query", "path": "/ids"},
+				"properties": ["threadId", "keywords", "messageId", "preview"]
+			}, "g"],
+			["Email/get", {"accountId": "other", "ids": []}, "a"],
+			["Email/import", {"accountId": "liokor"}, "u"]
+		]
+	}`)
+
+	query := responses["q"][1].(map[string]interface{})
+	if !reflect.DeepEqual(query["ids"], []interface{}{"7", "5"}) {
+		t.Errorf("Wrong query result: %v\n", query)
+	}
+	collapsedQuery := responses["c"][1].(map[string]interface{})
+	if !reflect.DeepEqual(collapsedQuery["ids"], []interface{}{"4"}) || collapsedQuery["total"] != 3.0 || collapsedQuery["position"] != 2.0 {
+		t.Errorf("Wrong collapsed query result: %v\n", collapsedQuery)
+	}
+	list := responses["g"][1].(map[string]interface{})["list"].([]interface{})
+	expected := map[string]interface{}{
+		"id":        "7",
+		"threadId":  "3",
+		"keywords":  map[string]interface{}{},
+		"messageId": []interface{}{"abc@ya.ru"},
+		"preview":   "How are you? Bye",
+	}
+	if len(list) != 2 || !reflect.DeepEqual(list[0], expected) {
+		t.Errorf("Wrong emails: %v\n", list)
+	}
+	if seen := list[1].(map[string]interface{})["keywords"]; !reflect.DeepEqual(seen, map[string]interface{}{"$seen": true}) {
+		t.Errorf("Own mail isn't seen: %v\n", seen)
+	}
+	if responses["a"][0] != "error" || responses["u"][0] != "error" {
+		t.Errorf("Didn't fail on wrong account and unknown method: %v\n", responses)
+	}
+}
+
+func TestJMAPSendEmail(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{mockMailUC}
+
+	draft := mail.Mail{
+		Sender:    "liokor",
+		Recipient: "friend@ya.ru",
+		Subject:   "Hello",
+		Body:      "**Hi**",
+	}
+	mockMailUC.EXPECT().GetAddress("liokor").Return("liokor@liokor.ru", nil).Times(1)
+	mockMailUC.EXPECT().GetSyncToken("liokor", 1).Return("state", nil).AnyTimes()
+	mockMailUC.EXPECT().SendEmail(draft).Return(mail.Mail{Id: 42}, nil).Times(1)
+
+	responses := jmapCall(t, mailHandler, `{
+		"using": ["urn:ietf:params:jmap:core", "urn:ietf:params:jmap:mail", "urn:ietf:params:jmap:submission"],
+		"methodCalls": [
+			["Email/set", {"accountId": "liokor", "create": {"k1": {
+				"to": [{"email": "friend@ya.ru"}],
+				"subject": "Hello",
+				"textBody": [{"partId": "body", "type": "text/plain"}],
+				"bodyValues": {"body": {"value": "**Hi**"}}
+			}}}, "s"],
+			["EmailSubmission/set", {"accountId": "liokor", "create": {
+				"sub": {"identityId": "liokor", "emailId": "#k1"},
+				"unknown": {"identityId": "liokor", "emailId": "12"}
+			}}, "e"]
+		]
+	}`)
+
+	submission := responses["e"][1].(map[string]interface{})
+	sent := submission["created"].(map[string]interface{})["sub"].(map[string]interface{})
+	if sent["id"] != "42" || sent["undoStatus"] != "final" {
+		t.Errorf("Wrong submission: %v\n", submission)
+	}
+	if submission["notCreated"].(map[string]interface{})["unknown"] == nil {
+		t.Errorf("Submitted not created email: %v\n", submission)
+	}
+}
+
+func TestJMAPEmailUpdate(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{mockMailUC}
+
+	email := mail.MailboxEmail{
+		DialogueEmail: mail.DialogueEmail{Id: 7, Sender: "friend@ya.ru", Recipient: "liokor@liokor.ru", Unread: true},
+		DialogueId:    3,
+	}
+	mockMailUC.EXPECT().GetAddress("liokor").Return("liokor@liokor.ru", nil).Times(1)
+	mockMailUC.EXPECT().GetSyncToken("liokor", 1).Return("state", nil).AnyTimes()
+	mockMailUC.EXPECT().GetMailboxEmails("liokor", mail.MailFilter{Ids: []int{7}}).Return([]mail.MailboxEmail{email}, nil).Times(1)
+	mockMailUC.EXPECT().GetMailboxEmails("liokor", mail.MailFilter{Ids: []int{8}}).Return([]mail.MailboxEmail{}, nil).Times(1)
+	mockMailUC.EXPECT().GetFolders("liokor", 1).Return([]mail.Folder{{Id: 2, FolderName: "Work"}}, nil).Times(1)
+	mockMailUC.EXPECT().GetMailboxCounters("liokor").Return([]mail.MailboxCounters{}, nil).Times(1)
+	mockMailUC.EXPECT().ReadDialogueMails("liokor", "friend@ya.ru").Return(nil).Times(1)
+	mockMailUC.EXPECT().UpdateFolderPutDialogue("liokor", 2, 3).Return(nil).Times(1)
+
+	responses := jmapCall(t, mailHandler, `{
+		"using": ["urn:ietf:params:jmap:core", "urn:ietf:params:jmap:mail"],
+		"methodCalls": [
+			["Email/set", {"accountId": "liokor", "update": {
+				"7": {"keywords/$seen": true, "mailboxIds": {"2": true}},
+				"8": {"keywords/$seen": true}
+			}}, "s"]
+		]
+	}`)
+
+	set := responses["s"][1].(map[string]interface{})
+	if _, ok := set["updated"].(map[string]interface{})["7"]; !ok {
+		t.Errorf("Didn't update email: %v\n", set)
+	}
+	if set["notUpdated"].(map[string]interface{})["8"] == nil {
+		t.Errorf("Updated missing email: %v\n", set)
+	}
+}
+
+func TestJMAPEmailChanges(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockMailUC := mailMocks.NewMockMailUseCase(mockCtrl)
+	mailHandler := MailHandler{mockMailUC}
+
+	changes := mail.Sync{
+		Token:     "15",
+		Mails:     mail.SyncChanges{Created: []int{7}, Updated: []int{}, Deleted: []int{4}},
+		Dialogues: mail.SyncChanges{Created: []int{}, Updated: []int{3}, Deleted: []int{}},
+	}
+	moved := []mail.MailboxEmail{
+		{DialogueEmail: mail.DialogueEmail{Id: 7}, DialogueId: 3},
+		{DialogueEmail: mail.DialogueEmail{Id: 5}, DialogueId: 3},
+	}
+	mockMailUC.EXPECT().GetAddress("liokor").Return("liokor@liokor.ru", nil).Times(1)
+	mockMailUC.EXPECT().Sync("liokor", 1, "10").Return(changes, nil).Times(2)
+	mockMailUC.EXPECT().Sync("liokor", 1, "old").Return(mail.Sync{}, mail.InvalidEmailError{"invalid sync token"}).Times(1)
+	mockMailUC.EXPECT().GetMailboxEmails("liokor", mail.MailFilter{DialogueIds: []int{3}}).Return(moved, nil).Times(1)
+
+	responses := jmapCall(t, mailHandler, `{
+		"using": ["urn:ietf:params:jmap:core", "urn:ietf:params:jmap:mail"],
+		"methodCalls": [
+			["Email/changes", {"accountId": "liokor", "sinceState": "10"}, "e"],
+			["Thread/changes", {"accountId": "liokor", "sinceState": "10"}, "t"],
+			["Email/changes", {"accountId": "liokor", "sinceState": "old"}, "o"]
+		]
+	}`)
+
+	emails := responses["e"][1].(map[string]interface{})
+	if emails["newState"] != "15" ||
+		!reflect.DeepEqual(emails["created"], []interface{}{"7"}) ||
+		!reflect.DeepEqual(emails["updated"], []interface{}{"5"}) ||
+		!reflect.DeepEqual(emails["destroyed"], []interface{}{"4"}) {
+		t.Errorf("Wrong email changes: %v\n", emails)
+	}
+	threads := responses["t"][1].(map[string]interface{})
+	if !reflect.DeepEqual(threads["updated"], []interface{}{"3"}) {
+		t.Errorf("Wrong thread changes: %v\n", threads)
+	}
+	if responses["o"][0] != "error" {
+		t.Errorf("Calculated changes from invalid state: %v\n", responses["o"])
+	}
+}
+
+func TestEvaluatePointer(t *testing.T) {
+	var value interface{}
+	json.Unmarshal([]byte(`{"list": [{"id": "1", "emailIds": ["5", "6"]}, {"id": "2", "emailIds": ["7"]}]}`), &value)
+
+	result, ok := evaluatePointer(value, "/list/*/emailIds")
+	if !ok || !reflect.DeepEqual(result, []interface{}{"5", "6", "7"}) {
+		t.Errorf("Wrong result: %v\n", result)
+	}
+	result, ok = evaluatePointer(value, "/list/1/id")
+	if !ok || result != "2" {
+		t.Errorf("Wrong result: %v\n", result)
+	}
+	_, ok = evaluatePointer(value, "/list/5/id")
+	if ok {
+		t.Errorf("Evaluated invalid pointer\n")
+	}
+}
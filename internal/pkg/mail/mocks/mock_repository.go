@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	mail "liokor_mail/internal/pkg/mail"
 	reflect "reflect"
 	time "time"
@@ -35,6 +36,20 @@ func (m *MockMailRepository) EXPECT() *MockMailRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AddDefaultDomain mocks base method.
+func (m *MockMailRepository) AddDefaultDomain(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDefaultDomain", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDefaultDomain indicates an expected call of AddDefaultDomain.
+func (mr *MockMailRepositoryMockRecorder) AddDefaultDomain(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDefaultDomain", reflect.TypeOf((*MockMailRepository)(nil).AddDefaultDomain), arg0)
+}
+
 // AddDialogueToFolder mocks base method.
 func (m *MockMailRepository) AddDialogueToFolder(arg0 string, arg1, arg2 int) error {
 	m.ctrl.T.Helper()
@@ -49,8 +64,22 @@ func (mr *MockMailRepositoryMockRecorder) AddDialogueToFolder(arg0, arg1, arg2 i
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDialogueToFolder", reflect.TypeOf((*MockMailRepository)(nil).AddDialogueToFolder), arg0, arg1, arg2)
 }
 
+// AddListMember mocks base method.
+func (m *MockMailRepository) AddListMember(arg0 string, arg1 int, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddListMember", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddListMember indicates an expected call of AddListMember.
+func (mr *MockMailRepositoryMockRecorder) AddListMember(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddListMember", reflect.TypeOf((*MockMailRepository)(nil).AddListMember), arg0, arg1, arg2)
+}
+
 // AddMail mocks base method.
-func (m *MockMailRepository) AddMail(arg0 mail.Mail, arg1 string) (int, error) {
+func (m *MockMailRepository) AddMail(arg0 mail.Mail, arg1 []string) (int, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "AddMail", arg0, arg1)
 	ret0, _ := ret[0].(int)
@@ -64,6 +93,150 @@ func (mr *MockMailRepositoryMockRecorder) AddMail(arg0, arg1 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMail", reflect.TypeOf((*MockMailRepository)(nil).AddMail), arg0, arg1)
 }
 
+// AddMailLabel mocks base method.
+func (m *MockMailRepository) AddMailLabel(arg0 int, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMailLabel", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddMailLabel indicates an expected call of AddMailLabel.
+func (mr *MockMailRepositoryMockRecorder) AddMailLabel(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMailLabel", reflect.TypeOf((*MockMailRepository)(nil).AddMailLabel), arg0, arg1, arg2)
+}
+
+// AddMailboxMember mocks base method.
+func (m *MockMailRepository) AddMailboxMember(arg0 mail.MailboxMember) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMailboxMember", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddMailboxMember indicates an expected call of AddMailboxMember.
+func (mr *MockMailRepositoryMockRecorder) AddMailboxMember(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMailboxMember", reflect.TypeOf((*MockMailRepository)(nil).AddMailboxMember), arg0)
+}
+
+// AddSenderRule mocks base method.
+func (m *MockMailRepository) AddSenderRule(arg0 mail.SenderRule) (mail.SenderRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddSenderRule", arg0)
+	ret0, _ := ret[0].(mail.SenderRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddSenderRule indicates an expected call of AddSenderRule.
+func (mr *MockMailRepositoryMockRecorder) AddSenderRule(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSenderRule", reflect.TypeOf((*MockMailRepository)(nil).AddSenderRule), arg0)
+}
+
+// AddWebhookDelivery mocks base method.
+func (m *MockMailRepository) AddWebhookDelivery(arg0 mail.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWebhookDelivery", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddWebhookDelivery indicates an expected call of AddWebhookDelivery.
+func (mr *MockMailRepositoryMockRecorder) AddWebhookDelivery(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWebhookDelivery", reflect.TypeOf((*MockMailRepository)(nil).AddWebhookDelivery), arg0)
+}
+
+// AddWebhookRetry mocks base method.
+func (m *MockMailRepository) AddWebhookRetry(arg0 mail.WebhookRetry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWebhookRetry", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddWebhookRetry indicates an expected call of AddWebhookRetry.
+func (mr *MockMailRepositoryMockRecorder) AddWebhookRetry(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWebhookRetry", reflect.TypeOf((*MockMailRepository)(nil).AddWebhookRetry), arg0)
+}
+
+// BulkDialogues mocks base method.
+func (m *MockMailRepository) BulkDialogues(arg0, arg1 string, arg2 mail.Bulk) ([]mail.BulkResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BulkDialogues", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mail.BulkResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BulkDialogues indicates an expected call of BulkDialogues.
+func (mr *MockMailRepositoryMockRecorder) BulkDialogues(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BulkDialogues", reflect.TypeOf((*MockMailRepository)(nil).BulkDialogues), arg0, arg1, arg2)
+}
+
+// BulkMails mocks base method.
+func (m *MockMailRepository) BulkMails(arg0, arg1 string, arg2 mail.Bulk) ([]mail.BulkResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BulkMails", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mail.BulkResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BulkMails indicates an expected call of BulkMails.
+func (mr *MockMailRepositoryMockRecorder) BulkMails(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BulkMails", reflect.TypeOf((*MockMailRepository)(nil).BulkMails), arg0, arg1, arg2)
+}
+
+// CollectContact mocks base method.
+func (m *MockMailRepository) CollectContact(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CollectContact", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CollectContact indicates an expected call of CollectContact.
+func (mr *MockMailRepositoryMockRecorder) CollectContact(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CollectContact", reflect.TypeOf((*MockMailRepository)(nil).CollectContact), arg0, arg1)
+}
+
+// ConfirmForwarding mocks base method.
+func (m *MockMailRepository) ConfirmForwarding(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmForwarding", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfirmForwarding indicates an expected call of ConfirmForwarding.
+func (mr *MockMailRepositoryMockRecorder) ConfirmForwarding(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmForwarding", reflect.TypeOf((*MockMailRepository)(nil).ConfirmForwarding), arg0)
+}
+
+// CountMailboxEmails mocks base method.
+func (m *MockMailRepository) CountMailboxEmails(arg0 string, arg1 []string, arg2 mail.MailFilter) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountMailboxEmails", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountMailboxEmails indicates an expected call of CountMailboxEmails.
+func (mr *MockMailRepositoryMockRecorder) CountMailboxEmails(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountMailboxEmails", reflect.TypeOf((*MockMailRepository)(nil).CountMailboxEmails), arg0, arg1, arg2)
+}
+
 // CountMailsFromUser mocks base method.
 func (m *MockMailRepository) CountMailsFromUser(arg0 string, arg1 time.Duration) (int, error) {
 	m.ctrl.T.Helper()
@@ -79,6 +252,36 @@ func (mr *MockMailRepositoryMockRecorder) CountMailsFromUser(arg0, arg1 interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountMailsFromUser", reflect.TypeOf((*MockMailRepository)(nil).CountMailsFromUser), arg0, arg1)
 }
 
+// CountSmartFoldersUnread mocks base method.
+func (m *MockMailRepository) CountSmartFoldersUnread(arg0, arg1 string, arg2 []mail.SmartQuery) ([]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountSmartFoldersUnread", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountSmartFoldersUnread indicates an expected call of CountSmartFoldersUnread.
+func (mr *MockMailRepositoryMockRecorder) CountSmartFoldersUnread(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountSmartFoldersUnread", reflect.TypeOf((*MockMailRepository)(nil).CountSmartFoldersUnread), arg0, arg1, arg2)
+}
+
+// CreateAlias mocks base method.
+func (m *MockMailRepository) CreateAlias(arg0, arg1 string) (mail.Alias, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAlias", arg0, arg1)
+	ret0, _ := ret[0].(mail.Alias)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAlias indicates an expected call of CreateAlias.
+func (mr *MockMailRepositoryMockRecorder) CreateAlias(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAlias", reflect.TypeOf((*MockMailRepository)(nil).CreateAlias), arg0, arg1)
+}
+
 // CreateDialogue mocks base method.
 func (m *MockMailRepository) CreateDialogue(arg0, arg1 string) (mail.Dialogue, error) {
 	m.ctrl.T.Helper()
@@ -94,19 +297,153 @@ func (mr *MockMailRepositoryMockRecorder) CreateDialogue(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDialogue", reflect.TypeOf((*MockMailRepository)(nil).CreateDialogue), arg0, arg1)
 }
 
+// CreateDistributionList mocks base method.
+func (m *MockMailRepository) CreateDistributionList(arg0 mail.DistributionList) (mail.DistributionList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDistributionList", arg0)
+	ret0, _ := ret[0].(mail.DistributionList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDistributionList indicates an expected call of CreateDistributionList.
+func (mr *MockMailRepositoryMockRecorder) CreateDistributionList(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDistributionList", reflect.TypeOf((*MockMailRepository)(nil).CreateDistributionList), arg0)
+}
+
+// CreateExport mocks base method.
+func (m *MockMailRepository) CreateExport(arg0 mail.Export) (mail.Export, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateExport", arg0)
+	ret0, _ := ret[0].(mail.Export)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateExport indicates an expected call of CreateExport.
+func (mr *MockMailRepositoryMockRecorder) CreateExport(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateExport", reflect.TypeOf((*MockMailRepository)(nil).CreateExport), arg0)
+}
+
 // CreateFolder mocks base method.
-func (m *MockMailRepository) CreateFolder(arg0 int, arg1 string) (mail.Folder, error) {
+func (m *MockMailRepository) CreateFolder(arg0 int, arg1 string, arg2 int) (mail.Folder, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateFolder", arg0, arg1)
+	ret := m.ctrl.Call(m, "CreateFolder", arg0, arg1, arg2)
 	ret0, _ := ret[0].(mail.Folder)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateFolder indicates an expected call of CreateFolder.
-func (mr *MockMailRepositoryMockRecorder) CreateFolder(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockMailRepositoryMockRecorder) CreateFolder(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFolder", reflect.TypeOf((*MockMailRepository)(nil).CreateFolder), arg0, arg1, arg2)
+}
+
+// CreateImport mocks base method.
+func (m *MockMailRepository) CreateImport(arg0 mail.Import) (mail.Import, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateImport", arg0)
+	ret0, _ := ret[0].(mail.Import)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateImport indicates an expected call of CreateImport.
+func (mr *MockMailRepositoryMockRecorder) CreateImport(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateImport", reflect.TypeOf((*MockMailRepository)(nil).CreateImport), arg0)
+}
+
+// CreateMailbox mocks base method.
+func (m *MockMailRepository) CreateMailbox(arg0, arg1, arg2 string) (mail.MailboxMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMailbox", arg0, arg1, arg2)
+	ret0, _ := ret[0].(mail.MailboxMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMailbox indicates an expected call of CreateMailbox.
+func (mr *MockMailRepositoryMockRecorder) CreateMailbox(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMailbox", reflect.TypeOf((*MockMailRepository)(nil).CreateMailbox), arg0, arg1, arg2)
+}
+
+// CreateSignature mocks base method.
+func (m *MockMailRepository) CreateSignature(arg0 mail.Signature) (mail.Signature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSignature", arg0)
+	ret0, _ := ret[0].(mail.Signature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSignature indicates an expected call of CreateSignature.
+func (mr *MockMailRepositoryMockRecorder) CreateSignature(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSignature", reflect.TypeOf((*MockMailRepository)(nil).CreateSignature), arg0)
+}
+
+// CreateSmartFolder mocks base method.
+func (m *MockMailRepository) CreateSmartFolder(arg0 mail.SmartFolder) (mail.SmartFolder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSmartFolder", arg0)
+	ret0, _ := ret[0].(mail.SmartFolder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSmartFolder indicates an expected call of CreateSmartFolder.
+func (mr *MockMailRepositoryMockRecorder) CreateSmartFolder(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSmartFolder", reflect.TypeOf((*MockMailRepository)(nil).CreateSmartFolder), arg0)
+}
+
+// CreateTemplate mocks base method.
+func (m *MockMailRepository) CreateTemplate(arg0 mail.Template) (mail.Template, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTemplate", arg0)
+	ret0, _ := ret[0].(mail.Template)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTemplate indicates an expected call of CreateTemplate.
+func (mr *MockMailRepositoryMockRecorder) CreateTemplate(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTemplate", reflect.TypeOf((*MockMailRepository)(nil).CreateTemplate), arg0)
+}
+
+// CreateWebhook mocks base method.
+func (m *MockMailRepository) CreateWebhook(arg0 mail.Webhook) (mail.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", arg0)
+	ret0, _ := ret[0].(mail.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockMailRepositoryMockRecorder) CreateWebhook(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockMailRepository)(nil).CreateWebhook), arg0)
+}
+
+// DeleteAlias mocks base method.
+func (m *MockMailRepository) DeleteAlias(arg0 string, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAlias", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAlias indicates an expected call of DeleteAlias.
+func (mr *MockMailRepositoryMockRecorder) DeleteAlias(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFolder", reflect.TypeOf((*MockMailRepository)(nil).CreateFolder), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAlias", reflect.TypeOf((*MockMailRepository)(nil).DeleteAlias), arg0, arg1)
 }
 
 // DeleteDialogue mocks base method.
@@ -123,6 +460,20 @@ func (mr *MockMailRepositoryMockRecorder) DeleteDialogue(arg0, arg1, arg2 interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDialogue", reflect.TypeOf((*MockMailRepository)(nil).DeleteDialogue), arg0, arg1, arg2)
 }
 
+// DeleteDistributionList mocks base method.
+func (m *MockMailRepository) DeleteDistributionList(arg0 string, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDistributionList", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDistributionList indicates an expected call of DeleteDistributionList.
+func (mr *MockMailRepositoryMockRecorder) DeleteDistributionList(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDistributionList", reflect.TypeOf((*MockMailRepository)(nil).DeleteDistributionList), arg0, arg1)
+}
+
 // DeleteFolder mocks base method.
 func (m *MockMailRepository) DeleteFolder(arg0, arg1 int) error {
 	m.ctrl.T.Helper()
@@ -137,6 +488,20 @@ func (mr *MockMailRepositoryMockRecorder) DeleteFolder(arg0, arg1 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFolder", reflect.TypeOf((*MockMailRepository)(nil).DeleteFolder), arg0, arg1)
 }
 
+// DeleteListMember mocks base method.
+func (m *MockMailRepository) DeleteListMember(arg0 string, arg1 int, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteListMember", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteListMember indicates an expected call of DeleteListMember.
+func (mr *MockMailRepositoryMockRecorder) DeleteListMember(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteListMember", reflect.TypeOf((*MockMailRepository)(nil).DeleteListMember), arg0, arg1, arg2)
+}
+
 // DeleteMail mocks base method.
 func (m *MockMailRepository) DeleteMail(arg0 string, arg1 []int, arg2 string) error {
 	m.ctrl.T.Helper()
@@ -151,120 +516,1269 @@ func (mr *MockMailRepositoryMockRecorder) DeleteMail(arg0, arg1, arg2 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMail", reflect.TypeOf((*MockMailRepository)(nil).DeleteMail), arg0, arg1, arg2)
 }
 
-// FindDialogues mocks base method.
-func (m *MockMailRepository) FindDialogues(arg0, arg1 string, arg2 int, arg3 string, arg4 time.Time) ([]mail.Dialogue, error) {
+// DeleteMailboxMember mocks base method.
+func (m *MockMailRepository) DeleteMailboxMember(arg0, arg1 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindDialogues", arg0, arg1, arg2, arg3, arg4)
-	ret0, _ := ret[0].([]mail.Dialogue)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "DeleteMailboxMember", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// FindDialogues indicates an expected call of FindDialogues.
-func (mr *MockMailRepositoryMockRecorder) FindDialogues(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+// DeleteMailboxMember indicates an expected call of DeleteMailboxMember.
+func (mr *MockMailRepositoryMockRecorder) DeleteMailboxMember(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDialogues", reflect.TypeOf((*MockMailRepository)(nil).FindDialogues), arg0, arg1, arg2, arg3, arg4)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMailboxMember", reflect.TypeOf((*MockMailRepository)(nil).DeleteMailboxMember), arg0, arg1)
 }
 
-// GetDialoguesInFolder mocks base method.
-func (m *MockMailRepository) GetDialoguesInFolder(arg0 string, arg1, arg2 int, arg3 string, arg4 time.Time) ([]mail.Dialogue, error) {
+// DeletePushEndpoint mocks base method.
+func (m *MockMailRepository) DeletePushEndpoint(arg0 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetDialoguesInFolder", arg0, arg1, arg2, arg3, arg4)
-	ret0, _ := ret[0].([]mail.Dialogue)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "DeletePushEndpoint", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetDialoguesInFolder indicates an expected call of GetDialoguesInFolder.
-func (mr *MockMailRepositoryMockRecorder) GetDialoguesInFolder(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+// DeletePushEndpoint indicates an expected call of DeletePushEndpoint.
+func (mr *MockMailRepositoryMockRecorder) DeletePushEndpoint(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDialoguesInFolder", reflect.TypeOf((*MockMailRepository)(nil).GetDialoguesInFolder), arg0, arg1, arg2, arg3, arg4)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePushEndpoint", reflect.TypeOf((*MockMailRepository)(nil).DeletePushEndpoint), arg0)
 }
 
-// GetFolders mocks base method.
-func (m *MockMailRepository) GetFolders(arg0 int) ([]mail.Folder, error) {
+// DeletePushSubscription mocks base method.
+func (m *MockMailRepository) DeletePushSubscription(arg0 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetFolders", arg0)
-	ret0, _ := ret[0].([]mail.Folder)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "DeletePushSubscription", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetFolders indicates an expected call of GetFolders.
-func (mr *MockMailRepositoryMockRecorder) GetFolders(arg0 interface{}) *gomock.Call {
+// DeletePushSubscription indicates an expected call of DeletePushSubscription.
+func (mr *MockMailRepositoryMockRecorder) DeletePushSubscription(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFolders", reflect.TypeOf((*MockMailRepository)(nil).GetFolders), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePushSubscription", reflect.TypeOf((*MockMailRepository)(nil).DeletePushSubscription), arg0)
 }
 
-// GetMailsForUser mocks base method.
-func (m *MockMailRepository) GetMailsForUser(arg0, arg1 string, arg2, arg3 int) ([]mail.DialogueEmail, error) {
+// DeleteSenderRule mocks base method.
+func (m *MockMailRepository) DeleteSenderRule(arg0 string, arg1 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetMailsForUser", arg0, arg1, arg2, arg3)
-	ret0, _ := ret[0].([]mail.DialogueEmail)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "DeleteSenderRule", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetMailsForUser indicates an expected call of GetMailsForUser.
-func (mr *MockMailRepositoryMockRecorder) GetMailsForUser(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+// DeleteSenderRule indicates an expected call of DeleteSenderRule.
+func (mr *MockMailRepositoryMockRecorder) DeleteSenderRule(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailsForUser", reflect.TypeOf((*MockMailRepository)(nil).GetMailsForUser), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSenderRule", reflect.TypeOf((*MockMailRepository)(nil).DeleteSenderRule), arg0, arg1)
 }
 
-// ReadDialogue mocks base method.
-func (m *MockMailRepository) ReadDialogue(arg0, arg1 string) error {
+// DeleteSignature mocks base method.
+func (m *MockMailRepository) DeleteSignature(arg0 string, arg1 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ReadDialogue", arg0, arg1)
+	ret := m.ctrl.Call(m, "DeleteSignature", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// ReadDialogue indicates an expected call of ReadDialogue.
-func (mr *MockMailRepositoryMockRecorder) ReadDialogue(arg0, arg1 interface{}) *gomock.Call {
+// DeleteSignature indicates an expected call of DeleteSignature.
+func (mr *MockMailRepositoryMockRecorder) DeleteSignature(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadDialogue", reflect.TypeOf((*MockMailRepository)(nil).ReadDialogue), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSignature", reflect.TypeOf((*MockMailRepository)(nil).DeleteSignature), arg0, arg1)
 }
 
-// ReadMail mocks base method.
-func (m *MockMailRepository) ReadMail(arg0, arg1 string) error {
+// DeleteSmartFolder mocks base method.
+func (m *MockMailRepository) DeleteSmartFolder(arg0 string, arg1 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ReadMail", arg0, arg1)
+	ret := m.ctrl.Call(m, "DeleteSmartFolder", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// ReadMail indicates an expected call of ReadMail.
-func (mr *MockMailRepositoryMockRecorder) ReadMail(arg0, arg1 interface{}) *gomock.Call {
+// DeleteSmartFolder indicates an expected call of DeleteSmartFolder.
+func (mr *MockMailRepositoryMockRecorder) DeleteSmartFolder(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadMail", reflect.TypeOf((*MockMailRepository)(nil).ReadMail), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSmartFolder", reflect.TypeOf((*MockMailRepository)(nil).DeleteSmartFolder), arg0, arg1)
 }
 
-// ShiftToMainFolderDialogues mocks base method.
-func (m *MockMailRepository) ShiftToMainFolderDialogues(arg0 string, arg1 int) error {
+// DeleteTemplate mocks base method.
+func (m *MockMailRepository) DeleteTemplate(arg0 string, arg1 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ShiftToMainFolderDialogues", arg0, arg1)
+	ret := m.ctrl.Call(m, "DeleteTemplate", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// ShiftToMainFolderDialogues indicates an expected call of ShiftToMainFolderDialogues.
-func (mr *MockMailRepositoryMockRecorder) ShiftToMainFolderDialogues(arg0, arg1 interface{}) *gomock.Call {
+// DeleteTemplate indicates an expected call of DeleteTemplate.
+func (mr *MockMailRepositoryMockRecorder) DeleteTemplate(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShiftToMainFolderDialogues", reflect.TypeOf((*MockMailRepository)(nil).ShiftToMainFolderDialogues), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTemplate", reflect.TypeOf((*MockMailRepository)(nil).DeleteTemplate), arg0, arg1)
 }
 
-// UpdateDialogueLastMail mocks base method.
-func (m *MockMailRepository) UpdateDialogueLastMail(arg0, arg1, arg2 string) error {
+// DeleteWebhook mocks base method.
+func (m *MockMailRepository) DeleteWebhook(arg0 string, arg1 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateDialogueLastMail", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "DeleteWebhook", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateDialogueLastMail indicates an expected call of UpdateDialogueLastMail.
-func (mr *MockMailRepositoryMockRecorder) UpdateDialogueLastMail(arg0, arg1, arg2 interface{}) *gomock.Call {
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockMailRepositoryMockRecorder) DeleteWebhook(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDialogueLastMail", reflect.TypeOf((*MockMailRepository)(nil).UpdateDialogueLastMail), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockMailRepository)(nil).DeleteWebhook), arg0, arg1)
+}
+
+// DeleteWebhookRetry mocks base method.
+func (m *MockMailRepository) DeleteWebhookRetry(arg0 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhookRetry", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhookRetry indicates an expected call of DeleteWebhookRetry.
+func (mr *MockMailRepositoryMockRecorder) DeleteWebhookRetry(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhookRetry", reflect.TypeOf((*MockMailRepository)(nil).DeleteWebhookRetry), arg0)
+}
+
+// ExpireExports mocks base method.
+func (m *MockMailRepository) ExpireExports(arg0 time.Time) ([]mail.Export, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireExports", arg0)
+	ret0, _ := ret[0].([]mail.Export)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireExports indicates an expected call of ExpireExports.
+func (mr *MockMailRepositoryMockRecorder) ExpireExports(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireExports", reflect.TypeOf((*MockMailRepository)(nil).ExpireExports), arg0)
+}
+
+// FindDialogues mocks base method.
+func (m *MockMailRepository) FindDialogues(arg0, arg1 string, arg2 mail.Page) ([]mail.Dialogue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindDialogues", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mail.Dialogue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindDialogues indicates an expected call of FindDialogues.
+func (mr *MockMailRepositoryMockRecorder) FindDialogues(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDialogues", reflect.TypeOf((*MockMailRepository)(nil).FindDialogues), arg0, arg1, arg2)
+}
+
+// FindSenderRules mocks base method.
+func (m *MockMailRepository) FindSenderRules(arg0 string, arg1 []string) ([]mail.SenderRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindSenderRules", arg0, arg1)
+	ret0, _ := ret[0].([]mail.SenderRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindSenderRules indicates an expected call of FindSenderRules.
+func (mr *MockMailRepositoryMockRecorder) FindSenderRules(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindSenderRules", reflect.TypeOf((*MockMailRepository)(nil).FindSenderRules), arg0, arg1)
+}
+
+// GetAddressOwner mocks base method.
+func (m *MockMailRepository) GetAddressOwner(arg0, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAddressOwner", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAddressOwner indicates an expected call of GetAddressOwner.
+func (mr *MockMailRepositoryMockRecorder) GetAddressOwner(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAddressOwner", reflect.TypeOf((*MockMailRepository)(nil).GetAddressOwner), arg0, arg1)
+}
+
+// GetAliases mocks base method.
+func (m *MockMailRepository) GetAliases(arg0 string) ([]mail.Alias, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAliases", arg0)
+	ret0, _ := ret[0].([]mail.Alias)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAliases indicates an expected call of GetAliases.
+func (mr *MockMailRepositoryMockRecorder) GetAliases(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAliases", reflect.TypeOf((*MockMailRepository)(nil).GetAliases), arg0)
+}
+
+// GetArchivedDialogues mocks base method.
+func (m *MockMailRepository) GetArchivedDialogues(arg0 string, arg1 mail.Page) ([]mail.Dialogue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetArchivedDialogues", arg0, arg1)
+	ret0, _ := ret[0].([]mail.Dialogue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetArchivedDialogues indicates an expected call of GetArchivedDialogues.
+func (mr *MockMailRepositoryMockRecorder) GetArchivedDialogues(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetArchivedDialogues", reflect.TypeOf((*MockMailRepository)(nil).GetArchivedDialogues), arg0, arg1)
+}
+
+// GetContactName mocks base method.
+func (m *MockMailRepository) GetContactName(arg0, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContactName", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetContactName indicates an expected call of GetContactName.
+func (mr *MockMailRepositoryMockRecorder) GetContactName(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContactName", reflect.TypeOf((*MockMailRepository)(nil).GetContactName), arg0, arg1)
+}
+
+// GetDefaultSignature mocks base method.
+func (m *MockMailRepository) GetDefaultSignature(arg0, arg1 string) (mail.Signature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDefaultSignature", arg0, arg1)
+	ret0, _ := ret[0].(mail.Signature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDefaultSignature indicates an expected call of GetDefaultSignature.
+func (mr *MockMailRepositoryMockRecorder) GetDefaultSignature(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDefaultSignature", reflect.TypeOf((*MockMailRepository)(nil).GetDefaultSignature), arg0, arg1)
+}
+
+// GetDialogueChanges mocks base method.
+func (m *MockMailRepository) GetDialogueChanges(arg0 string, arg1 int64, arg2 int) ([]mail.SyncChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDialogueChanges", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mail.SyncChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDialogueChanges indicates an expected call of GetDialogueChanges.
+func (mr *MockMailRepositoryMockRecorder) GetDialogueChanges(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDialogueChanges", reflect.TypeOf((*MockMailRepository)(nil).GetDialogueChanges), arg0, arg1, arg2)
+}
+
+// GetDialoguesInFolder mocks base method.
+func (m *MockMailRepository) GetDialoguesInFolder(arg0 string, arg1 int, arg2 mail.Page) ([]mail.Dialogue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDialoguesInFolder", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mail.Dialogue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDialoguesInFolder indicates an expected call of GetDialoguesInFolder.
+func (mr *MockMailRepositoryMockRecorder) GetDialoguesInFolder(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDialoguesInFolder", reflect.TypeOf((*MockMailRepository)(nil).GetDialoguesInFolder), arg0, arg1, arg2)
+}
+
+// GetDistributionList mocks base method.
+func (m *MockMailRepository) GetDistributionList(arg0, arg1 string) (mail.DistributionList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDistributionList", arg0, arg1)
+	ret0, _ := ret[0].(mail.DistributionList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDistributionList indicates an expected call of GetDistributionList.
+func (mr *MockMailRepositoryMockRecorder) GetDistributionList(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDistributionList", reflect.TypeOf((*MockMailRepository)(nil).GetDistributionList), arg0, arg1)
+}
+
+// GetDistributionLists mocks base method.
+func (m *MockMailRepository) GetDistributionLists(arg0 string) ([]mail.DistributionList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDistributionLists", arg0)
+	ret0, _ := ret[0].([]mail.DistributionList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDistributionLists indicates an expected call of GetDistributionLists.
+func (mr *MockMailRepositoryMockRecorder) GetDistributionLists(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDistributionLists", reflect.TypeOf((*MockMailRepository)(nil).GetDistributionLists), arg0)
+}
+
+// GetDomain mocks base method.
+func (m *MockMailRepository) GetDomain(arg0 string) (mail.Domain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDomain", arg0)
+	ret0, _ := ret[0].(mail.Domain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDomain indicates an expected call of GetDomain.
+func (mr *MockMailRepositoryMockRecorder) GetDomain(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDomain", reflect.TypeOf((*MockMailRepository)(nil).GetDomain), arg0)
+}
+
+// GetDomains mocks base method.
+func (m *MockMailRepository) GetDomains() ([]mail.Domain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDomains")
+	ret0, _ := ret[0].([]mail.Domain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDomains indicates an expected call of GetDomains.
+func (mr *MockMailRepositoryMockRecorder) GetDomains() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDomains", reflect.TypeOf((*MockMailRepository)(nil).GetDomains))
+}
+
+// GetDropBlocked mocks base method.
+func (m *MockMailRepository) GetDropBlocked(arg0 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDropBlocked", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDropBlocked indicates an expected call of GetDropBlocked.
+func (mr *MockMailRepositoryMockRecorder) GetDropBlocked(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDropBlocked", reflect.TypeOf((*MockMailRepository)(nil).GetDropBlocked), arg0)
+}
+
+// GetExport mocks base method.
+func (m *MockMailRepository) GetExport(arg0 string, arg1 int) (mail.Export, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExport", arg0, arg1)
+	ret0, _ := ret[0].(mail.Export)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExport indicates an expected call of GetExport.
+func (mr *MockMailRepositoryMockRecorder) GetExport(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExport", reflect.TypeOf((*MockMailRepository)(nil).GetExport), arg0, arg1)
+}
+
+// GetExportByToken mocks base method.
+func (m *MockMailRepository) GetExportByToken(arg0 string) (mail.Export, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExportByToken", arg0)
+	ret0, _ := ret[0].(mail.Export)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExportByToken indicates an expected call of GetExportByToken.
+func (mr *MockMailRepositoryMockRecorder) GetExportByToken(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExportByToken", reflect.TypeOf((*MockMailRepository)(nil).GetExportByToken), arg0)
+}
+
+// GetExports mocks base method.
+func (m *MockMailRepository) GetExports(arg0 string) ([]mail.Export, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExports", arg0)
+	ret0, _ := ret[0].([]mail.Export)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExports indicates an expected call of GetExports.
+func (mr *MockMailRepositoryMockRecorder) GetExports(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExports", reflect.TypeOf((*MockMailRepository)(nil).GetExports), arg0)
+}
+
+// GetFolderChanges mocks base method.
+func (m *MockMailRepository) GetFolderChanges(arg0 int, arg1 int64, arg2 int) ([]mail.SyncChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFolderChanges", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mail.SyncChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFolderChanges indicates an expected call of GetFolderChanges.
+func (mr *MockMailRepositoryMockRecorder) GetFolderChanges(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFolderChanges", reflect.TypeOf((*MockMailRepository)(nil).GetFolderChanges), arg0, arg1, arg2)
+}
+
+// GetFolders mocks base method.
+func (m *MockMailRepository) GetFolders(arg0 int) ([]mail.Folder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFolders", arg0)
+	ret0, _ := ret[0].([]mail.Folder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFolders indicates an expected call of GetFolders.
+func (mr *MockMailRepositoryMockRecorder) GetFolders(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFolders", reflect.TypeOf((*MockMailRepository)(nil).GetFolders), arg0)
+}
+
+// GetForwarding mocks base method.
+func (m *MockMailRepository) GetForwarding(arg0 string) (mail.Forwarding, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetForwarding", arg0)
+	ret0, _ := ret[0].(mail.Forwarding)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetForwarding indicates an expected call of GetForwarding.
+func (mr *MockMailRepositoryMockRecorder) GetForwarding(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetForwarding", reflect.TypeOf((*MockMailRepository)(nil).GetForwarding), arg0)
+}
+
+// GetFullName mocks base method.
+func (m *MockMailRepository) GetFullName(arg0 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFullName", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFullName indicates an expected call of GetFullName.
+func (mr *MockMailRepositoryMockRecorder) GetFullName(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFullName", reflect.TypeOf((*MockMailRepository)(nil).GetFullName), arg0)
+}
+
+// GetImport mocks base method.
+func (m *MockMailRepository) GetImport(arg0 string, arg1 int) (mail.Import, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImport", arg0, arg1)
+	ret0, _ := ret[0].(mail.Import)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImport indicates an expected call of GetImport.
+func (mr *MockMailRepositoryMockRecorder) GetImport(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImport", reflect.TypeOf((*MockMailRepository)(nil).GetImport), arg0, arg1)
+}
+
+// GetImports mocks base method.
+func (m *MockMailRepository) GetImports(arg0 string) ([]mail.Import, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImports", arg0)
+	ret0, _ := ret[0].([]mail.Import)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImports indicates an expected call of GetImports.
+func (mr *MockMailRepositoryMockRecorder) GetImports(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImports", reflect.TypeOf((*MockMailRepository)(nil).GetImports), arg0)
+}
+
+// GetMail mocks base method.
+func (m *MockMailRepository) GetMail(arg0 string, arg1 int) (mail.DialogueEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMail", arg0, arg1)
+	ret0, _ := ret[0].(mail.DialogueEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMail indicates an expected call of GetMail.
+func (mr *MockMailRepositoryMockRecorder) GetMail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMail", reflect.TypeOf((*MockMailRepository)(nil).GetMail), arg0, arg1)
+}
+
+// GetMailChanges mocks base method.
+func (m *MockMailRepository) GetMailChanges(arg0 string, arg1 int64, arg2 int) ([]mail.SyncChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMailChanges", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mail.SyncChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMailChanges indicates an expected call of GetMailChanges.
+func (mr *MockMailRepositoryMockRecorder) GetMailChanges(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailChanges", reflect.TypeOf((*MockMailRepository)(nil).GetMailChanges), arg0, arg1, arg2)
+}
+
+// GetMailRaw mocks base method.
+func (m *MockMailRepository) GetMailRaw(arg0 int) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMailRaw", arg0)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMailRaw indicates an expected call of GetMailRaw.
+func (mr *MockMailRepositoryMockRecorder) GetMailRaw(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailRaw", reflect.TypeOf((*MockMailRepository)(nil).GetMailRaw), arg0)
+}
+
+// GetMailboxCounters mocks base method.
+func (m *MockMailRepository) GetMailboxCounters(arg0 string, arg1 []string) ([]mail.MailboxCounters, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMailboxCounters", arg0, arg1)
+	ret0, _ := ret[0].([]mail.MailboxCounters)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMailboxCounters indicates an expected call of GetMailboxCounters.
+func (mr *MockMailRepositoryMockRecorder) GetMailboxCounters(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailboxCounters", reflect.TypeOf((*MockMailRepository)(nil).GetMailboxCounters), arg0, arg1)
+}
+
+// GetMailboxEmailIds mocks base method.
+func (m *MockMailRepository) GetMailboxEmailIds(arg0 string, arg1 []string, arg2 mail.MailFilter) ([]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMailboxEmailIds", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMailboxEmailIds indicates an expected call of GetMailboxEmailIds.
+func (mr *MockMailRepositoryMockRecorder) GetMailboxEmailIds(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailboxEmailIds", reflect.TypeOf((*MockMailRepository)(nil).GetMailboxEmailIds), arg0, arg1, arg2)
+}
+
+// GetMailboxEmails mocks base method.
+func (m *MockMailRepository) GetMailboxEmails(arg0 string, arg1 []string, arg2 mail.MailFilter) ([]mail.MailboxEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMailboxEmails", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mail.MailboxEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMailboxEmails indicates an expected call of GetMailboxEmails.
+func (mr *MockMailRepositoryMockRecorder) GetMailboxEmails(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailboxEmails", reflect.TypeOf((*MockMailRepository)(nil).GetMailboxEmails), arg0, arg1, arg2)
+}
+
+// GetMailboxMember mocks base method.
+func (m *MockMailRepository) GetMailboxMember(arg0, arg1 string) (mail.MailboxMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMailboxMember", arg0, arg1)
+	ret0, _ := ret[0].(mail.MailboxMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMailboxMember indicates an expected call of GetMailboxMember.
+func (mr *MockMailRepositoryMockRecorder) GetMailboxMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailboxMember", reflect.TypeOf((*MockMailRepository)(nil).GetMailboxMember), arg0, arg1)
+}
+
+// GetMailboxMembers mocks base method.
+func (m *MockMailRepository) GetMailboxMembers(arg0 string) ([]mail.MailboxMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMailboxMembers", arg0)
+	ret0, _ := ret[0].([]mail.MailboxMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMailboxMembers indicates an expected call of GetMailboxMembers.
+func (mr *MockMailRepositoryMockRecorder) GetMailboxMembers(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailboxMembers", reflect.TypeOf((*MockMailRepository)(nil).GetMailboxMembers), arg0)
+}
+
+// GetMailboxes mocks base method.
+func (m *MockMailRepository) GetMailboxes(arg0 string) ([]mail.MailboxMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMailboxes", arg0)
+	ret0, _ := ret[0].([]mail.MailboxMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMailboxes indicates an expected call of GetMailboxes.
+func (mr *MockMailRepositoryMockRecorder) GetMailboxes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailboxes", reflect.TypeOf((*MockMailRepository)(nil).GetMailboxes), arg0)
+}
+
+// GetMailsForUser mocks base method.
+func (m *MockMailRepository) GetMailsForUser(arg0, arg1 string, arg2 mail.Page) ([]mail.DialogueEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMailsForUser", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mail.DialogueEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMailsForUser indicates an expected call of GetMailsForUser.
+func (mr *MockMailRepositoryMockRecorder) GetMailsForUser(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailsForUser", reflect.TypeOf((*MockMailRepository)(nil).GetMailsForUser), arg0, arg1, arg2)
+}
+
+// GetModseq mocks base method.
+func (m *MockMailRepository) GetModseq(arg0, arg1 string, arg2 int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetModseq", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetModseq indicates an expected call of GetModseq.
+func (mr *MockMailRepositoryMockRecorder) GetModseq(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetModseq", reflect.TypeOf((*MockMailRepository)(nil).GetModseq), arg0, arg1, arg2)
+}
+
+// GetModseqLimit mocks base method.
+func (m *MockMailRepository) GetModseqLimit() (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetModseqLimit")
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetModseqLimit indicates an expected call of GetModseqLimit.
+func (mr *MockMailRepositoryMockRecorder) GetModseqLimit() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetModseqLimit", reflect.TypeOf((*MockMailRepository)(nil).GetModseqLimit))
+}
+
+// GetOwnerAddresses mocks base method.
+func (m *MockMailRepository) GetOwnerAddresses(arg0 string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOwnerAddresses", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOwnerAddresses indicates an expected call of GetOwnerAddresses.
+func (mr *MockMailRepositoryMockRecorder) GetOwnerAddresses(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOwnerAddresses", reflect.TypeOf((*MockMailRepository)(nil).GetOwnerAddresses), arg0)
+}
+
+// GetPinnedDialogues mocks base method.
+func (m *MockMailRepository) GetPinnedDialogues(arg0 string, arg1 int) ([]mail.Dialogue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPinnedDialogues", arg0, arg1)
+	ret0, _ := ret[0].([]mail.Dialogue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPinnedDialogues indicates an expected call of GetPinnedDialogues.
+func (mr *MockMailRepositoryMockRecorder) GetPinnedDialogues(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPinnedDialogues", reflect.TypeOf((*MockMailRepository)(nil).GetPinnedDialogues), arg0, arg1)
+}
+
+// GetPushSubscriptions mocks base method.
+func (m *MockMailRepository) GetPushSubscriptions(arg0, arg1 string) ([]mail.PushSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPushSubscriptions", arg0, arg1)
+	ret0, _ := ret[0].([]mail.PushSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPushSubscriptions indicates an expected call of GetPushSubscriptions.
+func (mr *MockMailRepositoryMockRecorder) GetPushSubscriptions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPushSubscriptions", reflect.TypeOf((*MockMailRepository)(nil).GetPushSubscriptions), arg0, arg1)
+}
+
+// GetReadReceipts mocks base method.
+func (m *MockMailRepository) GetReadReceipts(arg0 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReadReceipts", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReadReceipts indicates an expected call of GetReadReceipts.
+func (mr *MockMailRepositoryMockRecorder) GetReadReceipts(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReadReceipts", reflect.TypeOf((*MockMailRepository)(nil).GetReadReceipts), arg0)
+}
+
+// GetSenderRules mocks base method.
+func (m *MockMailRepository) GetSenderRules(arg0 string) ([]mail.SenderRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSenderRules", arg0)
+	ret0, _ := ret[0].([]mail.SenderRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSenderRules indicates an expected call of GetSenderRules.
+func (mr *MockMailRepositoryMockRecorder) GetSenderRules(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSenderRules", reflect.TypeOf((*MockMailRepository)(nil).GetSenderRules), arg0)
+}
+
+// GetSignature mocks base method.
+func (m *MockMailRepository) GetSignature(arg0 string, arg1 int) (mail.Signature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSignature", arg0, arg1)
+	ret0, _ := ret[0].(mail.Signature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSignature indicates an expected call of GetSignature.
+func (mr *MockMailRepositoryMockRecorder) GetSignature(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSignature", reflect.TypeOf((*MockMailRepository)(nil).GetSignature), arg0, arg1)
+}
+
+// GetSignatures mocks base method.
+func (m *MockMailRepository) GetSignatures(arg0 string) ([]mail.Signature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSignatures", arg0)
+	ret0, _ := ret[0].([]mail.Signature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSignatures indicates an expected call of GetSignatures.
+func (mr *MockMailRepositoryMockRecorder) GetSignatures(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSignatures", reflect.TypeOf((*MockMailRepository)(nil).GetSignatures), arg0)
+}
+
+// GetSmartFolder mocks base method.
+func (m *MockMailRepository) GetSmartFolder(arg0 string, arg1 int) (mail.SmartFolder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSmartFolder", arg0, arg1)
+	ret0, _ := ret[0].(mail.SmartFolder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSmartFolder indicates an expected call of GetSmartFolder.
+func (mr *MockMailRepositoryMockRecorder) GetSmartFolder(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSmartFolder", reflect.TypeOf((*MockMailRepository)(nil).GetSmartFolder), arg0, arg1)
+}
+
+// GetSmartFolderDialogues mocks base method.
+func (m *MockMailRepository) GetSmartFolderDialogues(arg0, arg1 string, arg2 mail.SmartQuery, arg3 mail.Page) ([]mail.Dialogue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSmartFolderDialogues", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]mail.Dialogue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSmartFolderDialogues indicates an expected call of GetSmartFolderDialogues.
+func (mr *MockMailRepositoryMockRecorder) GetSmartFolderDialogues(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSmartFolderDialogues", reflect.TypeOf((*MockMailRepository)(nil).GetSmartFolderDialogues), arg0, arg1, arg2, arg3)
+}
+
+// GetSmartFolders mocks base method.
+func (m *MockMailRepository) GetSmartFolders(arg0 string) ([]mail.SmartFolder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSmartFolders", arg0)
+	ret0, _ := ret[0].([]mail.SmartFolder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSmartFolders indicates an expected call of GetSmartFolders.
+func (mr *MockMailRepositoryMockRecorder) GetSmartFolders(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSmartFolders", reflect.TypeOf((*MockMailRepository)(nil).GetSmartFolders), arg0)
+}
+
+// GetSnoozedDialogues mocks base method.
+func (m *MockMailRepository) GetSnoozedDialogues(arg0 string, arg1 mail.Page) ([]mail.Dialogue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSnoozedDialogues", arg0, arg1)
+	ret0, _ := ret[0].([]mail.Dialogue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSnoozedDialogues indicates an expected call of GetSnoozedDialogues.
+func (mr *MockMailRepositoryMockRecorder) GetSnoozedDialogues(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSnoozedDialogues", reflect.TypeOf((*MockMailRepository)(nil).GetSnoozedDialogues), arg0, arg1)
+}
+
+// GetTemplate mocks base method.
+func (m *MockMailRepository) GetTemplate(arg0 string, arg1 int) (mail.Template, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTemplate", arg0, arg1)
+	ret0, _ := ret[0].(mail.Template)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTemplate indicates an expected call of GetTemplate.
+func (mr *MockMailRepositoryMockRecorder) GetTemplate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTemplate", reflect.TypeOf((*MockMailRepository)(nil).GetTemplate), arg0, arg1)
+}
+
+// GetTemplates mocks base method.
+func (m *MockMailRepository) GetTemplates(arg0 string) ([]mail.Template, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTemplates", arg0)
+	ret0, _ := ret[0].([]mail.Template)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTemplates indicates an expected call of GetTemplates.
+func (mr *MockMailRepositoryMockRecorder) GetTemplates(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTemplates", reflect.TypeOf((*MockMailRepository)(nil).GetTemplates), arg0)
+}
+
+// GetUserDomain mocks base method.
+func (m *MockMailRepository) GetUserDomain(arg0 string) (mail.Domain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserDomain", arg0)
+	ret0, _ := ret[0].(mail.Domain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserDomain indicates an expected call of GetUserDomain.
+func (mr *MockMailRepositoryMockRecorder) GetUserDomain(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserDomain", reflect.TypeOf((*MockMailRepository)(nil).GetUserDomain), arg0)
+}
+
+// GetUserId mocks base method.
+func (m *MockMailRepository) GetUserId(arg0 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserId", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserId indicates an expected call of GetUserId.
+func (mr *MockMailRepositoryMockRecorder) GetUserId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserId", reflect.TypeOf((*MockMailRepository)(nil).GetUserId), arg0)
+}
+
+// GetVacation mocks base method.
+func (m *MockMailRepository) GetVacation(arg0 string) (mail.Vacation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVacation", arg0)
+	ret0, _ := ret[0].(mail.Vacation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVacation indicates an expected call of GetVacation.
+func (mr *MockMailRepositoryMockRecorder) GetVacation(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVacation", reflect.TypeOf((*MockMailRepository)(nil).GetVacation), arg0)
+}
+
+// GetWebhook mocks base method.
+func (m *MockMailRepository) GetWebhook(arg0 string, arg1 int) (mail.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhook", arg0, arg1)
+	ret0, _ := ret[0].(mail.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhook indicates an expected call of GetWebhook.
+func (mr *MockMailRepositoryMockRecorder) GetWebhook(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhook", reflect.TypeOf((*MockMailRepository)(nil).GetWebhook), arg0, arg1)
+}
+
+// GetWebhookDeliveries mocks base method.
+func (m *MockMailRepository) GetWebhookDeliveries(arg0, arg1 int) ([]mail.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveries", arg0, arg1)
+	ret0, _ := ret[0].([]mail.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveries indicates an expected call of GetWebhookDeliveries.
+func (mr *MockMailRepositoryMockRecorder) GetWebhookDeliveries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveries", reflect.TypeOf((*MockMailRepository)(nil).GetWebhookDeliveries), arg0, arg1)
+}
+
+// GetWebhooks mocks base method.
+func (m *MockMailRepository) GetWebhooks(arg0 string) ([]mail.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhooks", arg0)
+	ret0, _ := ret[0].([]mail.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhooks indicates an expected call of GetWebhooks.
+func (mr *MockMailRepositoryMockRecorder) GetWebhooks(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhooks", reflect.TypeOf((*MockMailRepository)(nil).GetWebhooks), arg0)
+}
+
+// InterruptExports mocks base method.
+func (m *MockMailRepository) InterruptExports() ([]mail.Export, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InterruptExports")
+	ret0, _ := ret[0].([]mail.Export)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InterruptExports indicates an expected call of InterruptExports.
+func (mr *MockMailRepositoryMockRecorder) InterruptExports() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InterruptExports", reflect.TypeOf((*MockMailRepository)(nil).InterruptExports))
+}
+
+// InterruptImports mocks base method.
+func (m *MockMailRepository) InterruptImports() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InterruptImports")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InterruptImports indicates an expected call of InterruptImports.
+func (mr *MockMailRepositoryMockRecorder) InterruptImports() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InterruptImports", reflect.TypeOf((*MockMailRepository)(nil).InterruptImports))
+}
+
+// IsContact mocks base method.
+func (m *MockMailRepository) IsContact(arg0, arg1 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsContact", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsContact indicates an expected call of IsContact.
+func (mr *MockMailRepositoryMockRecorder) IsContact(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsContact", reflect.TypeOf((*MockMailRepository)(nil).IsContact), arg0, arg1)
+}
+
+// ListenEvents mocks base method.
+func (m *MockMailRepository) ListenEvents(arg0 context.Context, arg1 func(mail.Event)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListenEvents", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListenEvents indicates an expected call of ListenEvents.
+func (mr *MockMailRepositoryMockRecorder) ListenEvents(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListenEvents", reflect.TypeOf((*MockMailRepository)(nil).ListenEvents), arg0, arg1)
+}
+
+// MailExists mocks base method.
+func (m *MockMailRepository) MailExists(arg0, arg1, arg2 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MailExists", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MailExists indicates an expected call of MailExists.
+func (mr *MockMailRepositoryMockRecorder) MailExists(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MailExists", reflect.TypeOf((*MockMailRepository)(nil).MailExists), arg0, arg1, arg2)
+}
+
+// MoveFolder mocks base method.
+func (m *MockMailRepository) MoveFolder(arg0, arg1, arg2, arg3 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MoveFolder", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MoveFolder indicates an expected call of MoveFolder.
+func (mr *MockMailRepositoryMockRecorder) MoveFolder(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveFolder", reflect.TypeOf((*MockMailRepository)(nil).MoveFolder), arg0, arg1, arg2, arg3)
+}
+
+// ReadDialogue mocks base method.
+func (m *MockMailRepository) ReadDialogue(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadDialogue", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReadDialogue indicates an expected call of ReadDialogue.
+func (mr *MockMailRepositoryMockRecorder) ReadDialogue(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadDialogue", reflect.TypeOf((*MockMailRepository)(nil).ReadDialogue), arg0, arg1)
+}
+
+// ReadMail mocks base method.
+func (m *MockMailRepository) ReadMail(arg0 []string, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadMail", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReadMail indicates an expected call of ReadMail.
+func (mr *MockMailRepositoryMockRecorder) ReadMail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadMail", reflect.TypeOf((*MockMailRepository)(nil).ReadMail), arg0, arg1)
+}
+
+// RebuildDialogue mocks base method.
+func (m *MockMailRepository) RebuildDialogue(arg0, arg1, arg2 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RebuildDialogue", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RebuildDialogue indicates an expected call of RebuildDialogue.
+func (mr *MockMailRepositoryMockRecorder) RebuildDialogue(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RebuildDialogue", reflect.TypeOf((*MockMailRepository)(nil).RebuildDialogue), arg0, arg1, arg2)
+}
+
+// SaveMailRead mocks base method.
+func (m *MockMailRepository) SaveMailRead(arg0 int, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveMailRead", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveMailRead indicates an expected call of SaveMailRead.
+func (mr *MockMailRepositoryMockRecorder) SaveMailRead(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveMailRead", reflect.TypeOf((*MockMailRepository)(nil).SaveMailRead), arg0, arg1, arg2)
+}
+
+// SavePushSubscription mocks base method.
+func (m *MockMailRepository) SavePushSubscription(arg0 mail.PushSubscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SavePushSubscription", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SavePushSubscription indicates an expected call of SavePushSubscription.
+func (mr *MockMailRepositoryMockRecorder) SavePushSubscription(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SavePushSubscription", reflect.TypeOf((*MockMailRepository)(nil).SavePushSubscription), arg0)
+}
+
+// SaveReadReceipts mocks base method.
+func (m *MockMailRepository) SaveReadReceipts(arg0, arg1 string) ([]mail.DialogueEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveReadReceipts", arg0, arg1)
+	ret0, _ := ret[0].([]mail.DialogueEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SaveReadReceipts indicates an expected call of SaveReadReceipts.
+func (mr *MockMailRepositoryMockRecorder) SaveReadReceipts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveReadReceipts", reflect.TypeOf((*MockMailRepository)(nil).SaveReadReceipts), arg0, arg1)
+}
+
+// SaveVacationReply mocks base method.
+func (m *MockMailRepository) SaveVacationReply(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveVacationReply", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveVacationReply indicates an expected call of SaveVacationReply.
+func (mr *MockMailRepositoryMockRecorder) SaveVacationReply(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveVacationReply", reflect.TypeOf((*MockMailRepository)(nil).SaveVacationReply), arg0, arg1)
+}
+
+// ShiftToMainFolderDialogues mocks base method.
+func (m *MockMailRepository) ShiftToMainFolderDialogues(arg0 string, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ShiftToMainFolderDialogues", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ShiftToMainFolderDialogues indicates an expected call of ShiftToMainFolderDialogues.
+func (mr *MockMailRepositoryMockRecorder) ShiftToMainFolderDialogues(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShiftToMainFolderDialogues", reflect.TypeOf((*MockMailRepository)(nil).ShiftToMainFolderDialogues), arg0, arg1)
+}
+
+// SnoozeDialogue mocks base method.
+func (m *MockMailRepository) SnoozeDialogue(arg0 string, arg1 int, arg2 *time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SnoozeDialogue", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SnoozeDialogue indicates an expected call of SnoozeDialogue.
+func (mr *MockMailRepositoryMockRecorder) SnoozeDialogue(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SnoozeDialogue", reflect.TypeOf((*MockMailRepository)(nil).SnoozeDialogue), arg0, arg1, arg2)
+}
+
+// TakeWebhookRetries mocks base method.
+func (m *MockMailRepository) TakeWebhookRetries(arg0 time.Duration, arg1 int) ([]mail.WebhookRetry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeWebhookRetries", arg0, arg1)
+	ret0, _ := ret[0].([]mail.WebhookRetry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeWebhookRetries indicates an expected call of TakeWebhookRetries.
+func (mr *MockMailRepositoryMockRecorder) TakeWebhookRetries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeWebhookRetries", reflect.TypeOf((*MockMailRepository)(nil).TakeWebhookRetries), arg0, arg1)
+}
+
+// UpdateDeliveryStatus mocks base method.
+func (m *MockMailRepository) UpdateDeliveryStatus(arg0 int, arg1, arg2 string, arg3 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDeliveryStatus", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDeliveryStatus indicates an expected call of UpdateDeliveryStatus.
+func (mr *MockMailRepositoryMockRecorder) UpdateDeliveryStatus(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDeliveryStatus", reflect.TypeOf((*MockMailRepository)(nil).UpdateDeliveryStatus), arg0, arg1, arg2, arg3)
+}
+
+// UpdateDialogueLastMail mocks base method.
+func (m *MockMailRepository) UpdateDialogueLastMail(arg0, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDialogueLastMail", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDialogueLastMail indicates an expected call of UpdateDialogueLastMail.
+func (mr *MockMailRepositoryMockRecorder) UpdateDialogueLastMail(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDialogueLastMail", reflect.TypeOf((*MockMailRepository)(nil).UpdateDialogueLastMail), arg0, arg1, arg2)
+}
+
+// UpdateDialogueMuted mocks base method.
+func (m *MockMailRepository) UpdateDialogueMuted(arg0 string, arg1 int, arg2 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDialogueMuted", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDialogueMuted indicates an expected call of UpdateDialogueMuted.
+func (mr *MockMailRepositoryMockRecorder) UpdateDialogueMuted(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDialogueMuted", reflect.TypeOf((*MockMailRepository)(nil).UpdateDialogueMuted), arg0, arg1, arg2)
+}
+
+// UpdateDialoguePinned mocks base method.
+func (m *MockMailRepository) UpdateDialoguePinned(arg0 string, arg1 int, arg2 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDialoguePinned", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDialoguePinned indicates an expected call of UpdateDialoguePinned.
+func (mr *MockMailRepositoryMockRecorder) UpdateDialoguePinned(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDialoguePinned", reflect.TypeOf((*MockMailRepository)(nil).UpdateDialoguePinned), arg0, arg1, arg2)
+}
+
+// UpdateDropBlocked mocks base method.
+func (m *MockMailRepository) UpdateDropBlocked(arg0 string, arg1 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDropBlocked", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDropBlocked indicates an expected call of UpdateDropBlocked.
+func (mr *MockMailRepositoryMockRecorder) UpdateDropBlocked(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDropBlocked", reflect.TypeOf((*MockMailRepository)(nil).UpdateDropBlocked), arg0, arg1)
+}
+
+// UpdateExportProgress mocks base method.
+func (m *MockMailRepository) UpdateExportProgress(arg0 mail.Export) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateExportProgress", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateExportProgress indicates an expected call of UpdateExportProgress.
+func (mr *MockMailRepositoryMockRecorder) UpdateExportProgress(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateExportProgress", reflect.TypeOf((*MockMailRepository)(nil).UpdateExportProgress), arg0)
 }
 
 // UpdateFolderName mocks base method.
@@ -282,6 +1796,49 @@ func (mr *MockMailRepositoryMockRecorder) UpdateFolderName(arg0, arg1, arg2 inte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateFolderName", reflect.TypeOf((*MockMailRepository)(nil).UpdateFolderName), arg0, arg1, arg2)
 }
 
+// UpdateForwarding mocks base method.
+func (m *MockMailRepository) UpdateForwarding(arg0 mail.Forwarding) (mail.Forwarding, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateForwarding", arg0)
+	ret0, _ := ret[0].(mail.Forwarding)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateForwarding indicates an expected call of UpdateForwarding.
+func (mr *MockMailRepositoryMockRecorder) UpdateForwarding(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateForwarding", reflect.TypeOf((*MockMailRepository)(nil).UpdateForwarding), arg0)
+}
+
+// UpdateImportProgress mocks base method.
+func (m *MockMailRepository) UpdateImportProgress(arg0 mail.Import) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateImportProgress", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateImportProgress indicates an expected call of UpdateImportProgress.
+func (mr *MockMailRepositoryMockRecorder) UpdateImportProgress(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateImportProgress", reflect.TypeOf((*MockMailRepository)(nil).UpdateImportProgress), arg0)
+}
+
+// UpdateListPosting mocks base method.
+func (m *MockMailRepository) UpdateListPosting(arg0 string, arg1 int, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateListPosting", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateListPosting indicates an expected call of UpdateListPosting.
+func (mr *MockMailRepositoryMockRecorder) UpdateListPosting(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateListPosting", reflect.TypeOf((*MockMailRepository)(nil).UpdateListPosting), arg0, arg1, arg2)
+}
+
 // UpdateMailStatus mocks base method.
 func (m *MockMailRepository) UpdateMailStatus(arg0, arg1 int) error {
 	m.ctrl.T.Helper()
@@ -295,3 +1852,121 @@ func (mr *MockMailRepositoryMockRecorder) UpdateMailStatus(arg0, arg1 interface{
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMailStatus", reflect.TypeOf((*MockMailRepository)(nil).UpdateMailStatus), arg0, arg1)
 }
+
+// UpdateReadReceipts mocks base method.
+func (m *MockMailRepository) UpdateReadReceipts(arg0 string, arg1 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateReadReceipts", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateReadReceipts indicates an expected call of UpdateReadReceipts.
+func (mr *MockMailRepositoryMockRecorder) UpdateReadReceipts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateReadReceipts", reflect.TypeOf((*MockMailRepository)(nil).UpdateReadReceipts), arg0, arg1)
+}
+
+// UpdateSignature mocks base method.
+func (m *MockMailRepository) UpdateSignature(arg0 mail.Signature) (mail.Signature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSignature", arg0)
+	ret0, _ := ret[0].(mail.Signature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSignature indicates an expected call of UpdateSignature.
+func (mr *MockMailRepositoryMockRecorder) UpdateSignature(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSignature", reflect.TypeOf((*MockMailRepository)(nil).UpdateSignature), arg0)
+}
+
+// UpdateSmartFolder mocks base method.
+func (m *MockMailRepository) UpdateSmartFolder(arg0 mail.SmartFolder) (mail.SmartFolder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSmartFolder", arg0)
+	ret0, _ := ret[0].(mail.SmartFolder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSmartFolder indicates an expected call of UpdateSmartFolder.
+func (mr *MockMailRepositoryMockRecorder) UpdateSmartFolder(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSmartFolder", reflect.TypeOf((*MockMailRepository)(nil).UpdateSmartFolder), arg0)
+}
+
+// UpdateTemplate mocks base method.
+func (m *MockMailRepository) UpdateTemplate(arg0 mail.Template) (mail.Template, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTemplate", arg0)
+	ret0, _ := ret[0].(mail.Template)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTemplate indicates an expected call of UpdateTemplate.
+func (mr *MockMailRepositoryMockRecorder) UpdateTemplate(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTemplate", reflect.TypeOf((*MockMailRepository)(nil).UpdateTemplate), arg0)
+}
+
+// UpdateVacation mocks base method.
+func (m *MockMailRepository) UpdateVacation(arg0 mail.Vacation) (mail.Vacation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateVacation", arg0)
+	ret0, _ := ret[0].(mail.Vacation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateVacation indicates an expected call of UpdateVacation.
+func (mr *MockMailRepositoryMockRecorder) UpdateVacation(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateVacation", reflect.TypeOf((*MockMailRepository)(nil).UpdateVacation), arg0)
+}
+
+// UpdateWebhookRetry mocks base method.
+func (m *MockMailRepository) UpdateWebhookRetry(arg0 mail.WebhookRetry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWebhookRetry", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWebhookRetry indicates an expected call of UpdateWebhookRetry.
+func (mr *MockMailRepositoryMockRecorder) UpdateWebhookRetry(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebhookRetry", reflect.TypeOf((*MockMailRepository)(nil).UpdateWebhookRetry), arg0)
+}
+
+// VacationReplied mocks base method.
+func (m *MockMailRepository) VacationReplied(arg0, arg1 string, arg2 time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VacationReplied", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VacationReplied indicates an expected call of VacationReplied.
+func (mr *MockMailRepositoryMockRecorder) VacationReplied(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VacationReplied", reflect.TypeOf((*MockMailRepository)(nil).VacationReplied), arg0, arg1, arg2)
+}
+
+// WakeSnoozedDialogues mocks base method.
+func (m *MockMailRepository) WakeSnoozedDialogues(arg0 time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WakeSnoozedDialogues", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WakeSnoozedDialogues indicates an expected call of WakeSnoozedDialogues.
+func (mr *MockMailRepositoryMockRecorder) WakeSnoozedDialogues(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WakeSnoozedDialogues", reflect.TypeOf((*MockMailRepository)(nil).WakeSnoozedDialogues), arg0)
+}
@@ -5,6 +5,8 @@
 package mocks
 
 import (
+	context "context"
+	io "io"
 	mail "liokor_mail/internal/pkg/mail"
 	reflect "reflect"
 	time "time"
@@ -35,6 +37,179 @@ func (m *MockMailUseCase) EXPECT() *MockMailUseCaseMockRecorder {
 	return m.recorder
 }
 
+// AddDefaultDomain mocks base method.
+func (m *MockMailUseCase) AddDefaultDomain() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDefaultDomain")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDefaultDomain indicates an expected call of AddDefaultDomain.
+func (mr *MockMailUseCaseMockRecorder) AddDefaultDomain() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDefaultDomain", reflect.TypeOf((*MockMailUseCase)(nil).AddDefaultDomain))
+}
+
+// AddGlobalBlock mocks base method.
+func (m *MockMailUseCase) AddGlobalBlock(arg0, arg1 string) (mail.SenderRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddGlobalBlock", arg0, arg1)
+	ret0, _ := ret[0].(mail.SenderRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddGlobalBlock indicates an expected call of AddGlobalBlock.
+func (mr *MockMailUseCaseMockRecorder) AddGlobalBlock(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddGlobalBlock", reflect.TypeOf((*MockMailUseCase)(nil).AddGlobalBlock), arg0, arg1)
+}
+
+// AddListMember mocks base method.
+func (m *MockMailUseCase) AddListMember(arg0 string, arg1 int, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddListMember", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddListMember indicates an expected call of AddListMember.
+func (mr *MockMailUseCaseMockRecorder) AddListMember(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddListMember", reflect.TypeOf((*MockMailUseCase)(nil).AddListMember), arg0, arg1, arg2)
+}
+
+// AddMailboxMember mocks base method.
+func (m *MockMailUseCase) AddMailboxMember(arg0 string, arg1 mail.MailboxMember) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMailboxMember", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddMailboxMember indicates an expected call of AddMailboxMember.
+func (mr *MockMailUseCaseMockRecorder) AddMailboxMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMailboxMember", reflect.TypeOf((*MockMailUseCase)(nil).AddMailboxMember), arg0, arg1)
+}
+
+// AddSenderRule mocks base method.
+func (m *MockMailUseCase) AddSenderRule(arg0 string, arg1 mail.SenderRule) (mail.SenderRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddSenderRule", arg0, arg1)
+	ret0, _ := ret[0].(mail.SenderRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddSenderRule indicates an expected call of AddSenderRule.
+func (mr *MockMailUseCaseMockRecorder) AddSenderRule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddSenderRule", reflect.TypeOf((*MockMailUseCase)(nil).AddSenderRule), arg0, arg1)
+}
+
+// BounceEmail mocks base method.
+func (m *MockMailUseCase) BounceEmail(arg0 mail.Mail, arg1 map[string]error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BounceEmail", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BounceEmail indicates an expected call of BounceEmail.
+func (mr *MockMailUseCaseMockRecorder) BounceEmail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BounceEmail", reflect.TypeOf((*MockMailUseCase)(nil).BounceEmail), arg0, arg1)
+}
+
+// Bulk mocks base method.
+func (m *MockMailUseCase) Bulk(arg0 string, arg1 int, arg2 mail.Bulk) ([]mail.BulkResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Bulk", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mail.BulkResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Bulk indicates an expected call of Bulk.
+func (mr *MockMailUseCaseMockRecorder) Bulk(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Bulk", reflect.TypeOf((*MockMailUseCase)(nil).Bulk), arg0, arg1, arg2)
+}
+
+// CheckIncomingSender mocks base method.
+func (m *MockMailUseCase) CheckIncomingSender(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckIncomingSender", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckIncomingSender indicates an expected call of CheckIncomingSender.
+func (mr *MockMailUseCaseMockRecorder) CheckIncomingSender(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckIncomingSender", reflect.TypeOf((*MockMailUseCase)(nil).CheckIncomingSender), arg0, arg1)
+}
+
+// CheckMailboxAccess mocks base method.
+func (m *MockMailUseCase) CheckMailboxAccess(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckMailboxAccess", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckMailboxAccess indicates an expected call of CheckMailboxAccess.
+func (mr *MockMailUseCaseMockRecorder) CheckMailboxAccess(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckMailboxAccess", reflect.TypeOf((*MockMailUseCase)(nil).CheckMailboxAccess), arg0, arg1)
+}
+
+// ConfirmForwarding mocks base method.
+func (m *MockMailUseCase) ConfirmForwarding(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmForwarding", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfirmForwarding indicates an expected call of ConfirmForwarding.
+func (mr *MockMailUseCaseMockRecorder) ConfirmForwarding(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmForwarding", reflect.TypeOf((*MockMailUseCase)(nil).ConfirmForwarding), arg0)
+}
+
+// CountMailboxEmails mocks base method.
+func (m *MockMailUseCase) CountMailboxEmails(arg0 string, arg1 mail.MailFilter) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountMailboxEmails", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountMailboxEmails indicates an expected call of CountMailboxEmails.
+func (mr *MockMailUseCaseMockRecorder) CountMailboxEmails(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountMailboxEmails", reflect.TypeOf((*MockMailUseCase)(nil).CountMailboxEmails), arg0, arg1)
+}
+
+// CreateAlias mocks base method.
+func (m *MockMailUseCase) CreateAlias(arg0, arg1 string) (mail.Alias, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAlias", arg0, arg1)
+	ret0, _ := ret[0].(mail.Alias)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAlias indicates an expected call of CreateAlias.
+func (mr *MockMailUseCaseMockRecorder) CreateAlias(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAlias", reflect.TypeOf((*MockMailUseCase)(nil).CreateAlias), arg0, arg1)
+}
+
 // CreateDialogue mocks base method.
 func (m *MockMailUseCase) CreateDialogue(arg0, arg1 string) (mail.Dialogue, error) {
 	m.ctrl.T.Helper()
@@ -50,19 +225,138 @@ func (mr *MockMailUseCaseMockRecorder) CreateDialogue(arg0, arg1 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDialogue", reflect.TypeOf((*MockMailUseCase)(nil).CreateDialogue), arg0, arg1)
 }
 
+// CreateDistributionList mocks base method.
+func (m *MockMailUseCase) CreateDistributionList(arg0, arg1 string) (mail.DistributionList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDistributionList", arg0, arg1)
+	ret0, _ := ret[0].(mail.DistributionList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDistributionList indicates an expected call of CreateDistributionList.
+func (mr *MockMailUseCaseMockRecorder) CreateDistributionList(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDistributionList", reflect.TypeOf((*MockMailUseCase)(nil).CreateDistributionList), arg0, arg1)
+}
+
+// CreateExport mocks base method.
+func (m *MockMailUseCase) CreateExport(arg0 string, arg1 mail.Export) (mail.Export, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateExport", arg0, arg1)
+	ret0, _ := ret[0].(mail.Export)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateExport indicates an expected call of CreateExport.
+func (mr *MockMailUseCaseMockRecorder) CreateExport(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateExport", reflect.TypeOf((*MockMailUseCase)(nil).CreateExport), arg0, arg1)
+}
+
 // CreateFolder mocks base method.
-func (m *MockMailUseCase) CreateFolder(arg0 int, arg1 string) (mail.Folder, error) {
+func (m *MockMailUseCase) CreateFolder(arg0 int, arg1 string, arg2 int) (mail.Folder, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateFolder", arg0, arg1)
+	ret := m.ctrl.Call(m, "CreateFolder", arg0, arg1, arg2)
 	ret0, _ := ret[0].(mail.Folder)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateFolder indicates an expected call of CreateFolder.
-func (mr *MockMailUseCaseMockRecorder) CreateFolder(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockMailUseCaseMockRecorder) CreateFolder(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFolder", reflect.TypeOf((*MockMailUseCase)(nil).CreateFolder), arg0, arg1, arg2)
+}
+
+// CreateMailbox mocks base method.
+func (m *MockMailUseCase) CreateMailbox(arg0, arg1 string) (mail.MailboxMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMailbox", arg0, arg1)
+	ret0, _ := ret[0].(mail.MailboxMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMailbox indicates an expected call of CreateMailbox.
+func (mr *MockMailUseCaseMockRecorder) CreateMailbox(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMailbox", reflect.TypeOf((*MockMailUseCase)(nil).CreateMailbox), arg0, arg1)
+}
+
+// CreateSignature mocks base method.
+func (m *MockMailUseCase) CreateSignature(arg0 string, arg1 mail.Signature) (mail.Signature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSignature", arg0, arg1)
+	ret0, _ := ret[0].(mail.Signature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSignature indicates an expected call of CreateSignature.
+func (mr *MockMailUseCaseMockRecorder) CreateSignature(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFolder", reflect.TypeOf((*MockMailUseCase)(nil).CreateFolder), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSignature", reflect.TypeOf((*MockMailUseCase)(nil).CreateSignature), arg0, arg1)
+}
+
+// CreateSmartFolder mocks base method.
+func (m *MockMailUseCase) CreateSmartFolder(arg0 string, arg1 mail.SmartFolder) (mail.SmartFolder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSmartFolder", arg0, arg1)
+	ret0, _ := ret[0].(mail.SmartFolder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSmartFolder indicates an expected call of CreateSmartFolder.
+func (mr *MockMailUseCaseMockRecorder) CreateSmartFolder(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSmartFolder", reflect.TypeOf((*MockMailUseCase)(nil).CreateSmartFolder), arg0, arg1)
+}
+
+// CreateTemplate mocks base method.
+func (m *MockMailUseCase) CreateTemplate(arg0 string, arg1 mail.Template) (mail.Template, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTemplate", arg0, arg1)
+	ret0, _ := ret[0].(mail.Template)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTemplate indicates an expected call of CreateTemplate.
+func (mr *MockMailUseCaseMockRecorder) CreateTemplate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTemplate", reflect.TypeOf((*MockMailUseCase)(nil).CreateTemplate), arg0, arg1)
+}
+
+// CreateWebhook mocks base method.
+func (m *MockMailUseCase) CreateWebhook(arg0 string, arg1 mail.Webhook) (mail.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", arg0, arg1)
+	ret0, _ := ret[0].(mail.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockMailUseCaseMockRecorder) CreateWebhook(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockMailUseCase)(nil).CreateWebhook), arg0, arg1)
+}
+
+// DeleteAlias mocks base method.
+func (m *MockMailUseCase) DeleteAlias(arg0 string, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAlias", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAlias indicates an expected call of DeleteAlias.
+func (mr *MockMailUseCaseMockRecorder) DeleteAlias(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAlias", reflect.TypeOf((*MockMailUseCase)(nil).DeleteAlias), arg0, arg1)
 }
 
 // DeleteDialogue mocks base method.
@@ -79,6 +373,20 @@ func (mr *MockMailUseCaseMockRecorder) DeleteDialogue(arg0, arg1 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDialogue", reflect.TypeOf((*MockMailUseCase)(nil).DeleteDialogue), arg0, arg1)
 }
 
+// DeleteDistributionList mocks base method.
+func (m *MockMailUseCase) DeleteDistributionList(arg0 string, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDistributionList", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDistributionList indicates an expected call of DeleteDistributionList.
+func (mr *MockMailUseCaseMockRecorder) DeleteDistributionList(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDistributionList", reflect.TypeOf((*MockMailUseCase)(nil).DeleteDistributionList), arg0, arg1)
+}
+
 // DeleteFolder mocks base method.
 func (m *MockMailUseCase) DeleteFolder(arg0 string, arg1, arg2 int) error {
 	m.ctrl.T.Helper()
@@ -93,6 +401,48 @@ func (mr *MockMailUseCaseMockRecorder) DeleteFolder(arg0, arg1, arg2 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFolder", reflect.TypeOf((*MockMailUseCase)(nil).DeleteFolder), arg0, arg1, arg2)
 }
 
+// DeleteGlobalBlock mocks base method.
+func (m *MockMailUseCase) DeleteGlobalBlock(arg0 string, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteGlobalBlock", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteGlobalBlock indicates an expected call of DeleteGlobalBlock.
+func (mr *MockMailUseCaseMockRecorder) DeleteGlobalBlock(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteGlobalBlock", reflect.TypeOf((*MockMailUseCase)(nil).DeleteGlobalBlock), arg0, arg1)
+}
+
+// DeleteListMember mocks base method.
+func (m *MockMailUseCase) DeleteListMember(arg0 string, arg1 int, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteListMember", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteListMember indicates an expected call of DeleteListMember.
+func (mr *MockMailUseCaseMockRecorder) DeleteListMember(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteListMember", reflect.TypeOf((*MockMailUseCase)(nil).DeleteListMember), arg0, arg1, arg2)
+}
+
+// DeleteMailboxMember mocks base method.
+func (m *MockMailUseCase) DeleteMailboxMember(arg0, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMailboxMember", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMailboxMember indicates an expected call of DeleteMailboxMember.
+func (mr *MockMailUseCaseMockRecorder) DeleteMailboxMember(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMailboxMember", reflect.TypeOf((*MockMailUseCase)(nil).DeleteMailboxMember), arg0, arg1, arg2)
+}
+
 // DeleteMails mocks base method.
 func (m *MockMailUseCase) DeleteMails(arg0 string, arg1 []int) error {
 	m.ctrl.T.Helper()
@@ -107,91 +457,1090 @@ func (mr *MockMailUseCaseMockRecorder) DeleteMails(arg0, arg1 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMails", reflect.TypeOf((*MockMailUseCase)(nil).DeleteMails), arg0, arg1)
 }
 
-// GetDialogues mocks base method.
-func (m *MockMailUseCase) GetDialogues(arg0 string, arg1 int, arg2 string, arg3 int, arg4 time.Time) ([]mail.Dialogue, error) {
+// DeleteSenderRule mocks base method.
+func (m *MockMailUseCase) DeleteSenderRule(arg0 string, arg1 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetDialogues", arg0, arg1, arg2, arg3, arg4)
-	ret0, _ := ret[0].([]mail.Dialogue)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "DeleteSenderRule", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetDialogues indicates an expected call of GetDialogues.
-func (mr *MockMailUseCaseMockRecorder) GetDialogues(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+// DeleteSenderRule indicates an expected call of DeleteSenderRule.
+func (mr *MockMailUseCaseMockRecorder) DeleteSenderRule(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDialogues", reflect.TypeOf((*MockMailUseCase)(nil).GetDialogues), arg0, arg1, arg2, arg3, arg4)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSenderRule", reflect.TypeOf((*MockMailUseCase)(nil).DeleteSenderRule), arg0, arg1)
 }
 
-// GetEmails mocks base method.
-func (m *MockMailUseCase) GetEmails(arg0, arg1 string, arg2, arg3 int) ([]mail.DialogueEmail, error) {
+// DeleteSignature mocks base method.
+func (m *MockMailUseCase) DeleteSignature(arg0 string, arg1 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetEmails", arg0, arg1, arg2, arg3)
-	ret0, _ := ret[0].([]mail.DialogueEmail)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "DeleteSignature", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetEmails indicates an expected call of GetEmails.
-func (mr *MockMailUseCaseMockRecorder) GetEmails(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+// DeleteSignature indicates an expected call of DeleteSignature.
+func (mr *MockMailUseCaseMockRecorder) DeleteSignature(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmails", reflect.TypeOf((*MockMailUseCase)(nil).GetEmails), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSignature", reflect.TypeOf((*MockMailUseCase)(nil).DeleteSignature), arg0, arg1)
 }
 
-// GetFolders mocks base method.
-func (m *MockMailUseCase) GetFolders(arg0 int) ([]mail.Folder, error) {
+// DeleteSmartFolder mocks base method.
+func (m *MockMailUseCase) DeleteSmartFolder(arg0 string, arg1 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetFolders", arg0)
-	ret0, _ := ret[0].([]mail.Folder)
+	ret := m.ctrl.Call(m, "DeleteSmartFolder", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSmartFolder indicates an expected call of DeleteSmartFolder.
+func (mr *MockMailUseCaseMockRecorder) DeleteSmartFolder(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSmartFolder", reflect.TypeOf((*MockMailUseCase)(nil).DeleteSmartFolder), arg0, arg1)
+}
+
+// DeleteTemplate mocks base method.
+func (m *MockMailUseCase) DeleteTemplate(arg0 string, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTemplate", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTemplate indicates an expected call of DeleteTemplate.
+func (mr *MockMailUseCaseMockRecorder) DeleteTemplate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTemplate", reflect.TypeOf((*MockMailUseCase)(nil).DeleteTemplate), arg0, arg1)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockMailUseCase) DeleteWebhook(arg0 string, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockMailUseCaseMockRecorder) DeleteWebhook(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockMailUseCase)(nil).DeleteWebhook), arg0, arg1)
+}
+
+// ForwardEmail mocks base method.
+func (m *MockMailUseCase) ForwardEmail(arg0 string, arg1 int, arg2 mail.Mail) (mail.Mail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForwardEmail", arg0, arg1, arg2)
+	ret0, _ := ret[0].(mail.Mail)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetFolders indicates an expected call of GetFolders.
-func (mr *MockMailUseCaseMockRecorder) GetFolders(arg0 interface{}) *gomock.Call {
+// ForwardEmail indicates an expected call of ForwardEmail.
+func (mr *MockMailUseCaseMockRecorder) ForwardEmail(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFolders", reflect.TypeOf((*MockMailUseCase)(nil).GetFolders), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForwardEmail", reflect.TypeOf((*MockMailUseCase)(nil).ForwardEmail), arg0, arg1, arg2)
 }
 
-// SendEmail mocks base method.
-func (m *MockMailUseCase) SendEmail(arg0 mail.Mail) (mail.Mail, error) {
+// GetAddress mocks base method.
+func (m *MockMailUseCase) GetAddress(arg0 string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SendEmail", arg0)
-	ret0, _ := ret[0].(mail.Mail)
+	ret := m.ctrl.Call(m, "GetAddress", arg0)
+	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// SendEmail indicates an expected call of SendEmail.
-func (mr *MockMailUseCaseMockRecorder) SendEmail(arg0 interface{}) *gomock.Call {
+// GetAddress indicates an expected call of GetAddress.
+func (mr *MockMailUseCaseMockRecorder) GetAddress(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendEmail", reflect.TypeOf((*MockMailUseCase)(nil).SendEmail), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAddress", reflect.TypeOf((*MockMailUseCase)(nil).GetAddress), arg0)
 }
 
-// UpdateFolderName mocks base method.
-func (m *MockMailUseCase) UpdateFolderName(arg0, arg1 int, arg2 string) (mail.Folder, error) {
+// GetAliases mocks base method.
+func (m *MockMailUseCase) GetAliases(arg0 string) ([]mail.Alias, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateFolderName", arg0, arg1, arg2)
-	ret0, _ := ret[0].(mail.Folder)
+	ret := m.ctrl.Call(m, "GetAliases", arg0)
+	ret0, _ := ret[0].([]mail.Alias)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// UpdateFolderName indicates an expected call of UpdateFolderName.
-func (mr *MockMailUseCaseMockRecorder) UpdateFolderName(arg0, arg1, arg2 interface{}) *gomock.Call {
+// GetAliases indicates an expected call of GetAliases.
+func (mr *MockMailUseCaseMockRecorder) GetAliases(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateFolderName", reflect.TypeOf((*MockMailUseCase)(nil).UpdateFolderName), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAliases", reflect.TypeOf((*MockMailUseCase)(nil).GetAliases), arg0)
 }
 
-// UpdateFolderPutDialogue mocks base method.
-func (m *MockMailUseCase) UpdateFolderPutDialogue(arg0 string, arg1, arg2 int) error {
+// GetArchivedDialogues mocks base method.
+func (m *MockMailUseCase) GetArchivedDialogues(arg0 string, arg1 mail.Page) (mail.DialoguesPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateFolderPutDialogue", arg0, arg1, arg2)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "GetArchivedDialogues", arg0, arg1)
+	ret0, _ := ret[0].(mail.DialoguesPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// UpdateFolderPutDialogue indicates an expected call of UpdateFolderPutDialogue.
-func (mr *MockMailUseCaseMockRecorder) UpdateFolderPutDialogue(arg0, arg1, arg2 interface{}) *gomock.Call {
+// GetArchivedDialogues indicates an expected call of GetArchivedDialogues.
+func (mr *MockMailUseCaseMockRecorder) GetArchivedDialogues(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateFolderPutDialogue", reflect.TypeOf((*MockMailUseCase)(nil).UpdateFolderPutDialogue), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetArchivedDialogues", reflect.TypeOf((*MockMailUseCase)(nil).GetArchivedDialogues), arg0, arg1)
+}
+
+// GetDialogues mocks base method.
+func (m *MockMailUseCase) GetDialogues(arg0, arg1 string, arg2 int, arg3 mail.Page) (mail.DialoguesPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDialogues", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(mail.DialoguesPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDialogues indicates an expected call of GetDialogues.
+func (mr *MockMailUseCaseMockRecorder) GetDialogues(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDialogues", reflect.TypeOf((*MockMailUseCase)(nil).GetDialogues), arg0, arg1, arg2, arg3)
+}
+
+// GetDistributionLists mocks base method.
+func (m *MockMailUseCase) GetDistributionLists(arg0 string) ([]mail.DistributionList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDistributionLists", arg0)
+	ret0, _ := ret[0].([]mail.DistributionList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDistributionLists indicates an expected call of GetDistributionLists.
+func (mr *MockMailUseCaseMockRecorder) GetDistributionLists(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDistributionLists", reflect.TypeOf((*MockMailUseCase)(nil).GetDistributionLists), arg0)
+}
+
+// GetDomains mocks base method.
+func (m *MockMailUseCase) GetDomains() ([]mail.Domain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDomains")
+	ret0, _ := ret[0].([]mail.Domain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDomains indicates an expected call of GetDomains.
+func (mr *MockMailUseCaseMockRecorder) GetDomains() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDomains", reflect.TypeOf((*MockMailUseCase)(nil).GetDomains))
+}
+
+// GetEmails mocks base method.
+func (m *MockMailUseCase) GetEmails(arg0, arg1 string, arg2 mail.Page) (mail.EmailsPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEmails", arg0, arg1, arg2)
+	ret0, _ := ret[0].(mail.EmailsPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEmails indicates an expected call of GetEmails.
+func (mr *MockMailUseCaseMockRecorder) GetEmails(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEmails", reflect.TypeOf((*MockMailUseCase)(nil).GetEmails), arg0, arg1, arg2)
+}
+
+// GetExport mocks base method.
+func (m *MockMailUseCase) GetExport(arg0 string, arg1 int) (mail.Export, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExport", arg0, arg1)
+	ret0, _ := ret[0].(mail.Export)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExport indicates an expected call of GetExport.
+func (mr *MockMailUseCaseMockRecorder) GetExport(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExport", reflect.TypeOf((*MockMailUseCase)(nil).GetExport), arg0, arg1)
+}
+
+// GetExportFile mocks base method.
+func (m *MockMailUseCase) GetExportFile(arg0 string) (mail.Export, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExportFile", arg0)
+	ret0, _ := ret[0].(mail.Export)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetExportFile indicates an expected call of GetExportFile.
+func (mr *MockMailUseCaseMockRecorder) GetExportFile(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExportFile", reflect.TypeOf((*MockMailUseCase)(nil).GetExportFile), arg0)
+}
+
+// GetExports mocks base method.
+func (m *MockMailUseCase) GetExports(arg0 string) ([]mail.Export, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExports", arg0)
+	ret0, _ := ret[0].([]mail.Export)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExports indicates an expected call of GetExports.
+func (mr *MockMailUseCaseMockRecorder) GetExports(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExports", reflect.TypeOf((*MockMailUseCase)(nil).GetExports), arg0)
+}
+
+// GetFolders mocks base method.
+func (m *MockMailUseCase) GetFolders(arg0 string, arg1 int) ([]mail.Folder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFolders", arg0, arg1)
+	ret0, _ := ret[0].([]mail.Folder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFolders indicates an expected call of GetFolders.
+func (mr *MockMailUseCaseMockRecorder) GetFolders(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFolders", reflect.TypeOf((*MockMailUseCase)(nil).GetFolders), arg0, arg1)
+}
+
+// GetFoldersPage mocks base method.
+func (m *MockMailUseCase) GetFoldersPage(arg0 string, arg1 int, arg2 mail.Page) (mail.FoldersPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFoldersPage", arg0, arg1, arg2)
+	ret0, _ := ret[0].(mail.FoldersPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFoldersPage indicates an expected call of GetFoldersPage.
+func (mr *MockMailUseCaseMockRecorder) GetFoldersPage(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFoldersPage", reflect.TypeOf((*MockMailUseCase)(nil).GetFoldersPage), arg0, arg1, arg2)
+}
+
+// GetForwarding mocks base method.
+func (m *MockMailUseCase) GetForwarding(arg0 string) (mail.Forwarding, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetForwarding", arg0)
+	ret0, _ := ret[0].(mail.Forwarding)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetForwarding indicates an expected call of GetForwarding.
+func (mr *MockMailUseCaseMockRecorder) GetForwarding(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetForwarding", reflect.TypeOf((*MockMailUseCase)(nil).GetForwarding), arg0)
+}
+
+// GetGlobalBlocklist mocks base method.
+func (m *MockMailUseCase) GetGlobalBlocklist(arg0 string) ([]mail.SenderRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGlobalBlocklist", arg0)
+	ret0, _ := ret[0].([]mail.SenderRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGlobalBlocklist indicates an expected call of GetGlobalBlocklist.
+func (mr *MockMailUseCaseMockRecorder) GetGlobalBlocklist(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGlobalBlocklist", reflect.TypeOf((*MockMailUseCase)(nil).GetGlobalBlocklist), arg0)
+}
+
+// GetImport mocks base method.
+func (m *MockMailUseCase) GetImport(arg0 string, arg1 int) (mail.Import, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImport", arg0, arg1)
+	ret0, _ := ret[0].(mail.Import)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImport indicates an expected call of GetImport.
+func (mr *MockMailUseCaseMockRecorder) GetImport(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImport", reflect.TypeOf((*MockMailUseCase)(nil).GetImport), arg0, arg1)
+}
+
+// GetImports mocks base method.
+func (m *MockMailUseCase) GetImports(arg0 string) ([]mail.Import, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImports", arg0)
+	ret0, _ := ret[0].([]mail.Import)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImports indicates an expected call of GetImports.
+func (mr *MockMailUseCaseMockRecorder) GetImports(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImports", reflect.TypeOf((*MockMailUseCase)(nil).GetImports), arg0)
+}
+
+// GetMailboxCounters mocks base method.
+func (m *MockMailUseCase) GetMailboxCounters(arg0 string) ([]mail.MailboxCounters, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMailboxCounters", arg0)
+	ret0, _ := ret[0].([]mail.MailboxCounters)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMailboxCounters indicates an expected call of GetMailboxCounters.
+func (mr *MockMailUseCaseMockRecorder) GetMailboxCounters(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailboxCounters", reflect.TypeOf((*MockMailUseCase)(nil).GetMailboxCounters), arg0)
+}
+
+// GetMailboxEmailIds mocks base method.
+func (m *MockMailUseCase) GetMailboxEmailIds(arg0 string, arg1 mail.MailFilter) ([]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMailboxEmailIds", arg0, arg1)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMailboxEmailIds indicates an expected call of GetMailboxEmailIds.
+func (mr *MockMailUseCaseMockRecorder) GetMailboxEmailIds(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailboxEmailIds", reflect.TypeOf((*MockMailUseCase)(nil).GetMailboxEmailIds), arg0, arg1)
+}
+
+// GetMailboxEmails mocks base method.
+func (m *MockMailUseCase) GetMailboxEmails(arg0 string, arg1 mail.MailFilter) ([]mail.MailboxEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMailboxEmails", arg0, arg1)
+	ret0, _ := ret[0].([]mail.MailboxEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMailboxEmails indicates an expected call of GetMailboxEmails.
+func (mr *MockMailUseCaseMockRecorder) GetMailboxEmails(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailboxEmails", reflect.TypeOf((*MockMailUseCase)(nil).GetMailboxEmails), arg0, arg1)
+}
+
+// GetMailboxMembers mocks base method.
+func (m *MockMailUseCase) GetMailboxMembers(arg0, arg1 string) ([]mail.MailboxMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMailboxMembers", arg0, arg1)
+	ret0, _ := ret[0].([]mail.MailboxMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMailboxMembers indicates an expected call of GetMailboxMembers.
+func (mr *MockMailUseCaseMockRecorder) GetMailboxMembers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailboxMembers", reflect.TypeOf((*MockMailUseCase)(nil).GetMailboxMembers), arg0, arg1)
+}
+
+// GetMailboxes mocks base method.
+func (m *MockMailUseCase) GetMailboxes(arg0 string) ([]mail.MailboxMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMailboxes", arg0)
+	ret0, _ := ret[0].([]mail.MailboxMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMailboxes indicates an expected call of GetMailboxes.
+func (mr *MockMailUseCaseMockRecorder) GetMailboxes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMailboxes", reflect.TypeOf((*MockMailUseCase)(nil).GetMailboxes), arg0)
+}
+
+// GetReadReceipts mocks base method.
+func (m *MockMailUseCase) GetReadReceipts(arg0 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReadReceipts", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReadReceipts indicates an expected call of GetReadReceipts.
+func (mr *MockMailUseCaseMockRecorder) GetReadReceipts(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReadReceipts", reflect.TypeOf((*MockMailUseCase)(nil).GetReadReceipts), arg0)
+}
+
+// GetSenderFilter mocks base method.
+func (m *MockMailUseCase) GetSenderFilter(arg0 string) (mail.SenderFilter, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSenderFilter", arg0)
+	ret0, _ := ret[0].(mail.SenderFilter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSenderFilter indicates an expected call of GetSenderFilter.
+func (mr *MockMailUseCaseMockRecorder) GetSenderFilter(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSenderFilter", reflect.TypeOf((*MockMailUseCase)(nil).GetSenderFilter), arg0)
+}
+
+// GetSignatures mocks base method.
+func (m *MockMailUseCase) GetSignatures(arg0 string) ([]mail.Signature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSignatures", arg0)
+	ret0, _ := ret[0].([]mail.Signature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSignatures indicates an expected call of GetSignatures.
+func (mr *MockMailUseCaseMockRecorder) GetSignatures(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSignatures", reflect.TypeOf((*MockMailUseCase)(nil).GetSignatures), arg0)
+}
+
+// GetSmartFolderDialogues mocks base method.
+func (m *MockMailUseCase) GetSmartFolderDialogues(arg0 string, arg1 int, arg2 mail.Page) (mail.DialoguesPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSmartFolderDialogues", arg0, arg1, arg2)
+	ret0, _ := ret[0].(mail.DialoguesPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSmartFolderDialogues indicates an expected call of GetSmartFolderDialogues.
+func (mr *MockMailUseCaseMockRecorder) GetSmartFolderDialogues(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSmartFolderDialogues", reflect.TypeOf((*MockMailUseCase)(nil).GetSmartFolderDialogues), arg0, arg1, arg2)
+}
+
+// GetSnoozedDialogues mocks base method.
+func (m *MockMailUseCase) GetSnoozedDialogues(arg0 string, arg1 mail.Page) (mail.DialoguesPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSnoozedDialogues", arg0, arg1)
+	ret0, _ := ret[0].(mail.DialoguesPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSnoozedDialogues indicates an expected call of GetSnoozedDialogues.
+func (mr *MockMailUseCaseMockRecorder) GetSnoozedDialogues(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSnoozedDialogues", reflect.TypeOf((*MockMailUseCase)(nil).GetSnoozedDialogues), arg0, arg1)
+}
+
+// GetSyncToken mocks base method.
+func (m *MockMailUseCase) GetSyncToken(arg0 string, arg1 int) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSyncToken", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSyncToken indicates an expected call of GetSyncToken.
+func (mr *MockMailUseCaseMockRecorder) GetSyncToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSyncToken", reflect.TypeOf((*MockMailUseCase)(nil).GetSyncToken), arg0, arg1)
+}
+
+// GetTemplates mocks base method.
+func (m *MockMailUseCase) GetTemplates(arg0 string) ([]mail.Template, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTemplates", arg0)
+	ret0, _ := ret[0].([]mail.Template)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTemplates indicates an expected call of GetTemplates.
+func (mr *MockMailUseCaseMockRecorder) GetTemplates(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTemplates", reflect.TypeOf((*MockMailUseCase)(nil).GetTemplates), arg0)
+}
+
+// GetUserId mocks base method.
+func (m *MockMailUseCase) GetUserId(arg0 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserId", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserId indicates an expected call of GetUserId.
+func (mr *MockMailUseCaseMockRecorder) GetUserId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserId", reflect.TypeOf((*MockMailUseCase)(nil).GetUserId), arg0)
+}
+
+// GetVacation mocks base method.
+func (m *MockMailUseCase) GetVacation(arg0 string) (mail.Vacation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVacation", arg0)
+	ret0, _ := ret[0].(mail.Vacation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVacation indicates an expected call of GetVacation.
+func (mr *MockMailUseCaseMockRecorder) GetVacation(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVacation", reflect.TypeOf((*MockMailUseCase)(nil).GetVacation), arg0)
+}
+
+// GetVapidPublicKey mocks base method.
+func (m *MockMailUseCase) GetVapidPublicKey() (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVapidPublicKey")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVapidPublicKey indicates an expected call of GetVapidPublicKey.
+func (mr *MockMailUseCaseMockRecorder) GetVapidPublicKey() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVapidPublicKey", reflect.TypeOf((*MockMailUseCase)(nil).GetVapidPublicKey))
+}
+
+// GetWebhookDeliveries mocks base method.
+func (m *MockMailUseCase) GetWebhookDeliveries(arg0 string, arg1, arg2 int) ([]mail.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveries", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]mail.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveries indicates an expected call of GetWebhookDeliveries.
+func (mr *MockMailUseCaseMockRecorder) GetWebhookDeliveries(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveries", reflect.TypeOf((*MockMailUseCase)(nil).GetWebhookDeliveries), arg0, arg1, arg2)
+}
+
+// GetWebhooks mocks base method.
+func (m *MockMailUseCase) GetWebhooks(arg0 string) ([]mail.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhooks", arg0)
+	ret0, _ := ret[0].([]mail.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhooks indicates an expected call of GetWebhooks.
+func (mr *MockMailUseCaseMockRecorder) GetWebhooks(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhooks", reflect.TypeOf((*MockMailUseCase)(nil).GetWebhooks), arg0)
+}
+
+// ImportArchive mocks base method.
+func (m *MockMailUseCase) ImportArchive(arg0 string, arg1 int, arg2 string, arg3 io.Reader) (mail.Import, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportArchive", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(mail.Import)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportArchive indicates an expected call of ImportArchive.
+func (mr *MockMailUseCaseMockRecorder) ImportArchive(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportArchive", reflect.TypeOf((*MockMailUseCase)(nil).ImportArchive), arg0, arg1, arg2, arg3)
+}
+
+// ImportIMAP mocks base method.
+func (m *MockMailUseCase) ImportIMAP(arg0 string, arg1 int, arg2 mail.IMAPAccount) (mail.Import, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportIMAP", arg0, arg1, arg2)
+	ret0, _ := ret[0].(mail.Import)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportIMAP indicates an expected call of ImportIMAP.
+func (mr *MockMailUseCaseMockRecorder) ImportIMAP(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportIMAP", reflect.TypeOf((*MockMailUseCase)(nil).ImportIMAP), arg0, arg1, arg2)
+}
+
+// InterruptExports mocks base method.
+func (m *MockMailUseCase) InterruptExports() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InterruptExports")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InterruptExports indicates an expected call of InterruptExports.
+func (mr *MockMailUseCaseMockRecorder) InterruptExports() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InterruptExports", reflect.TypeOf((*MockMailUseCase)(nil).InterruptExports))
+}
+
+// InterruptImports mocks base method.
+func (m *MockMailUseCase) InterruptImports() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InterruptImports")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InterruptImports indicates an expected call of InterruptImports.
+func (mr *MockMailUseCaseMockRecorder) InterruptImports() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InterruptImports", reflect.TypeOf((*MockMailUseCase)(nil).InterruptImports))
+}
+
+// IsLocalAddress mocks base method.
+func (m *MockMailUseCase) IsLocalAddress(arg0 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsLocalAddress", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsLocalAddress indicates an expected call of IsLocalAddress.
+func (mr *MockMailUseCaseMockRecorder) IsLocalAddress(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsLocalAddress", reflect.TypeOf((*MockMailUseCase)(nil).IsLocalAddress), arg0)
+}
+
+// ListenEvents mocks base method.
+func (m *MockMailUseCase) ListenEvents(arg0 context.Context, arg1 func(mail.Event)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListenEvents", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListenEvents indicates an expected call of ListenEvents.
+func (mr *MockMailUseCaseMockRecorder) ListenEvents(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListenEvents", reflect.TypeOf((*MockMailUseCase)(nil).ListenEvents), arg0, arg1)
+}
+
+// MoveFolder mocks base method.
+func (m *MockMailUseCase) MoveFolder(arg0, arg1, arg2, arg3 int) (mail.Folder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MoveFolder", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(mail.Folder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MoveFolder indicates an expected call of MoveFolder.
+func (mr *MockMailUseCaseMockRecorder) MoveFolder(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveFolder", reflect.TypeOf((*MockMailUseCase)(nil).MoveFolder), arg0, arg1, arg2, arg3)
+}
+
+// MuteDialogue mocks base method.
+func (m *MockMailUseCase) MuteDialogue(arg0 string, arg1 int, arg2 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MuteDialogue", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MuteDialogue indicates an expected call of MuteDialogue.
+func (mr *MockMailUseCaseMockRecorder) MuteDialogue(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MuteDialogue", reflect.TypeOf((*MockMailUseCase)(nil).MuteDialogue), arg0, arg1, arg2)
+}
+
+// PinDialogue mocks base method.
+func (m *MockMailUseCase) PinDialogue(arg0 string, arg1 int, arg2 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PinDialogue", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PinDialogue indicates an expected call of PinDialogue.
+func (mr *MockMailUseCaseMockRecorder) PinDialogue(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PinDialogue", reflect.TypeOf((*MockMailUseCase)(nil).PinDialogue), arg0, arg1, arg2)
+}
+
+// PreviewEmail mocks base method.
+func (m *MockMailUseCase) PreviewEmail(arg0 string, arg1 mail.Mail) (mail.Preview, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PreviewEmail", arg0, arg1)
+	ret0, _ := ret[0].(mail.Preview)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PreviewEmail indicates an expected call of PreviewEmail.
+func (mr *MockMailUseCaseMockRecorder) PreviewEmail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreviewEmail", reflect.TypeOf((*MockMailUseCase)(nil).PreviewEmail), arg0, arg1)
+}
+
+// ReadDialogueMails mocks base method.
+func (m *MockMailUseCase) ReadDialogueMails(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadDialogueMails", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReadDialogueMails indicates an expected call of ReadDialogueMails.
+func (mr *MockMailUseCaseMockRecorder) ReadDialogueMails(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadDialogueMails", reflect.TypeOf((*MockMailUseCase)(nil).ReadDialogueMails), arg0, arg1)
+}
+
+// ReceiveEmail mocks base method.
+func (m *MockMailUseCase) ReceiveEmail(arg0 mail.Mail) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReceiveEmail", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReceiveEmail indicates an expected call of ReceiveEmail.
+func (mr *MockMailUseCaseMockRecorder) ReceiveEmail(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceiveEmail", reflect.TypeOf((*MockMailUseCase)(nil).ReceiveEmail), arg0)
+}
+
+// RemoveExpiredExports mocks base method.
+func (m *MockMailUseCase) RemoveExpiredExports() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveExpiredExports")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveExpiredExports indicates an expected call of RemoveExpiredExports.
+func (mr *MockMailUseCaseMockRecorder) RemoveExpiredExports() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveExpiredExports", reflect.TypeOf((*MockMailUseCase)(nil).RemoveExpiredExports))
+}
+
+// RenderTemplate mocks base method.
+func (m *MockMailUseCase) RenderTemplate(arg0 string, arg1 int, arg2 string) (mail.RenderedTemplate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RenderTemplate", arg0, arg1, arg2)
+	ret0, _ := ret[0].(mail.RenderedTemplate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RenderTemplate indicates an expected call of RenderTemplate.
+func (mr *MockMailUseCaseMockRecorder) RenderTemplate(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenderTemplate", reflect.TypeOf((*MockMailUseCase)(nil).RenderTemplate), arg0, arg1, arg2)
+}
+
+// ReplyEmail mocks base method.
+func (m *MockMailUseCase) ReplyEmail(arg0 string, arg1 int, arg2 mail.Mail, arg3 bool) ([]mail.Mail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplyEmail", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]mail.Mail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplyEmail indicates an expected call of ReplyEmail.
+func (mr *MockMailUseCaseMockRecorder) ReplyEmail(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplyEmail", reflect.TypeOf((*MockMailUseCase)(nil).ReplyEmail), arg0, arg1, arg2, arg3)
+}
+
+// ResumeImport mocks base method.
+func (m *MockMailUseCase) ResumeImport(arg0 string, arg1, arg2 int, arg3 string) (mail.Import, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeImport", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(mail.Import)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResumeImport indicates an expected call of ResumeImport.
+func (mr *MockMailUseCaseMockRecorder) ResumeImport(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeImport", reflect.TypeOf((*MockMailUseCase)(nil).ResumeImport), arg0, arg1, arg2, arg3)
+}
+
+// RetryWebhooks mocks base method.
+func (m *MockMailUseCase) RetryWebhooks() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryWebhooks")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetryWebhooks indicates an expected call of RetryWebhooks.
+func (mr *MockMailUseCaseMockRecorder) RetryWebhooks() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryWebhooks", reflect.TypeOf((*MockMailUseCase)(nil).RetryWebhooks))
+}
+
+// SendEmail mocks base method.
+func (m *MockMailUseCase) SendEmail(arg0 mail.Mail) (mail.Mail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendEmail", arg0)
+	ret0, _ := ret[0].(mail.Mail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendEmail indicates an expected call of SendEmail.
+func (mr *MockMailUseCaseMockRecorder) SendEmail(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendEmail", reflect.TypeOf((*MockMailUseCase)(nil).SendEmail), arg0)
+}
+
+// SnoozeDialogue mocks base method.
+func (m *MockMailUseCase) SnoozeDialogue(arg0 string, arg1 int, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SnoozeDialogue", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SnoozeDialogue indicates an expected call of SnoozeDialogue.
+func (mr *MockMailUseCaseMockRecorder) SnoozeDialogue(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SnoozeDialogue", reflect.TypeOf((*MockMailUseCase)(nil).SnoozeDialogue), arg0, arg1, arg2)
+}
+
+// SubscribePush mocks base method.
+func (m *MockMailUseCase) SubscribePush(arg0, arg1 string, arg2 mail.PushSubscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribePush", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SubscribePush indicates an expected call of SubscribePush.
+func (mr *MockMailUseCaseMockRecorder) SubscribePush(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribePush", reflect.TypeOf((*MockMailUseCase)(nil).SubscribePush), arg0, arg1, arg2)
+}
+
+// Sync mocks base method.
+func (m *MockMailUseCase) Sync(arg0 string, arg1 int, arg2 string) (mail.Sync, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sync", arg0, arg1, arg2)
+	ret0, _ := ret[0].(mail.Sync)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Sync indicates an expected call of Sync.
+func (mr *MockMailUseCaseMockRecorder) Sync(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sync", reflect.TypeOf((*MockMailUseCase)(nil).Sync), arg0, arg1, arg2)
+}
+
+// TestWebhook mocks base method.
+func (m *MockMailUseCase) TestWebhook(arg0 string, arg1 int) (mail.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TestWebhook", arg0, arg1)
+	ret0, _ := ret[0].(mail.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TestWebhook indicates an expected call of TestWebhook.
+func (mr *MockMailUseCaseMockRecorder) TestWebhook(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TestWebhook", reflect.TypeOf((*MockMailUseCase)(nil).TestWebhook), arg0, arg1)
+}
+
+// UnsnoozeDialogue mocks base method.
+func (m *MockMailUseCase) UnsnoozeDialogue(arg0 string, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnsnoozeDialogue", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnsnoozeDialogue indicates an expected call of UnsnoozeDialogue.
+func (mr *MockMailUseCaseMockRecorder) UnsnoozeDialogue(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnsnoozeDialogue", reflect.TypeOf((*MockMailUseCase)(nil).UnsnoozeDialogue), arg0, arg1)
+}
+
+// UnsubscribePush mocks base method.
+func (m *MockMailUseCase) UnsubscribePush(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnsubscribePush", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnsubscribePush indicates an expected call of UnsubscribePush.
+func (mr *MockMailUseCaseMockRecorder) UnsubscribePush(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnsubscribePush", reflect.TypeOf((*MockMailUseCase)(nil).UnsubscribePush), arg0)
+}
+
+// UpdateFolderName mocks base method.
+func (m *MockMailUseCase) UpdateFolderName(arg0, arg1 int, arg2 string) (mail.Folder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateFolderName", arg0, arg1, arg2)
+	ret0, _ := ret[0].(mail.Folder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateFolderName indicates an expected call of UpdateFolderName.
+func (mr *MockMailUseCaseMockRecorder) UpdateFolderName(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateFolderName", reflect.TypeOf((*MockMailUseCase)(nil).UpdateFolderName), arg0, arg1, arg2)
+}
+
+// UpdateFolderPutDialogue mocks base method.
+func (m *MockMailUseCase) UpdateFolderPutDialogue(arg0 string, arg1, arg2 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateFolderPutDialogue", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateFolderPutDialogue indicates an expected call of UpdateFolderPutDialogue.
+func (mr *MockMailUseCaseMockRecorder) UpdateFolderPutDialogue(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateFolderPutDialogue", reflect.TypeOf((*MockMailUseCase)(nil).UpdateFolderPutDialogue), arg0, arg1, arg2)
+}
+
+// UpdateForwarding mocks base method.
+func (m *MockMailUseCase) UpdateForwarding(arg0 string, arg1 mail.Forwarding) (mail.Forwarding, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateForwarding", arg0, arg1)
+	ret0, _ := ret[0].(mail.Forwarding)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateForwarding indicates an expected call of UpdateForwarding.
+func (mr *MockMailUseCaseMockRecorder) UpdateForwarding(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateForwarding", reflect.TypeOf((*MockMailUseCase)(nil).UpdateForwarding), arg0, arg1)
+}
+
+// UpdateListPosting mocks base method.
+func (m *MockMailUseCase) UpdateListPosting(arg0 string, arg1 int, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateListPosting", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateListPosting indicates an expected call of UpdateListPosting.
+func (mr *MockMailUseCaseMockRecorder) UpdateListPosting(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateListPosting", reflect.TypeOf((*MockMailUseCase)(nil).UpdateListPosting), arg0, arg1, arg2)
+}
+
+// UpdateReadReceipts mocks base method.
+func (m *MockMailUseCase) UpdateReadReceipts(arg0 string, arg1 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateReadReceipts", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateReadReceipts indicates an expected call of UpdateReadReceipts.
+func (mr *MockMailUseCaseMockRecorder) UpdateReadReceipts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateReadReceipts", reflect.TypeOf((*MockMailUseCase)(nil).UpdateReadReceipts), arg0, arg1)
+}
+
+// UpdateSenderFilter mocks base method.
+func (m *MockMailUseCase) UpdateSenderFilter(arg0 string, arg1 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSenderFilter", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSenderFilter indicates an expected call of UpdateSenderFilter.
+func (mr *MockMailUseCaseMockRecorder) UpdateSenderFilter(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSenderFilter", reflect.TypeOf((*MockMailUseCase)(nil).UpdateSenderFilter), arg0, arg1)
+}
+
+// UpdateSignature mocks base method.
+func (m *MockMailUseCase) UpdateSignature(arg0 string, arg1 mail.Signature) (mail.Signature, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSignature", arg0, arg1)
+	ret0, _ := ret[0].(mail.Signature)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSignature indicates an expected call of UpdateSignature.
+func (mr *MockMailUseCaseMockRecorder) UpdateSignature(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSignature", reflect.TypeOf((*MockMailUseCase)(nil).UpdateSignature), arg0, arg1)
+}
+
+// UpdateSmartFolder mocks base method.
+func (m *MockMailUseCase) UpdateSmartFolder(arg0 string, arg1 mail.SmartFolder) (mail.SmartFolder, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSmartFolder", arg0, arg1)
+	ret0, _ := ret[0].(mail.SmartFolder)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSmartFolder indicates an expected call of UpdateSmartFolder.
+func (mr *MockMailUseCaseMockRecorder) UpdateSmartFolder(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSmartFolder", reflect.TypeOf((*MockMailUseCase)(nil).UpdateSmartFolder), arg0, arg1)
+}
+
+// UpdateTemplate mocks base method.
+func (m *MockMailUseCase) UpdateTemplate(arg0 string, arg1 mail.Template) (mail.Template, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTemplate", arg0, arg1)
+	ret0, _ := ret[0].(mail.Template)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTemplate indicates an expected call of UpdateTemplate.
+func (mr *MockMailUseCaseMockRecorder) UpdateTemplate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTemplate", reflect.TypeOf((*MockMailUseCase)(nil).UpdateTemplate), arg0, arg1)
+}
+
+// UpdateVacation mocks base method.
+func (m *MockMailUseCase) UpdateVacation(arg0 string, arg1 mail.Vacation) (mail.Vacation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateVacation", arg0, arg1)
+	ret0, _ := ret[0].(mail.Vacation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateVacation indicates an expected call of UpdateVacation.
+func (mr *MockMailUseCaseMockRecorder) UpdateVacation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateVacation", reflect.TypeOf((*MockMailUseCase)(nil).UpdateVacation), arg0, arg1)
+}
+
+// WakeSnoozedDialogues mocks base method.
+func (m *MockMailUseCase) WakeSnoozedDialogues() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WakeSnoozedDialogues")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WakeSnoozedDialogues indicates an expected call of WakeSnoozedDialogues.
+func (mr *MockMailUseCaseMockRecorder) WakeSnoozedDialogues() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WakeSnoozedDialogues", reflect.TypeOf((*MockMailUseCase)(nil).WakeSnoozedDialogues))
 }
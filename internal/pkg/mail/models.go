@@ -1,7 +1,11 @@
 package mail
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"liokor_mail/internal/pkg/common"
+	"strings"
 	"time"
 )
 
@@ -12,33 +16,590 @@ type Mail struct {
 	Subject       string    `json:"subject" gorm:"column:subject"`
 	Body          string    `json:"body" gorm:"column:body"`
 	Received_date time.Time `json:"-" gorm:"received_date"`
+	Alias         string    `json:"from,omitempty" gorm:"column:sender_alias"` // own alias to send from
+	List          string    `json:"-" gorm:"column:list_address"`              // list the copy was delivered through
+
+	DeletedBySender    bool `json:"-" gorm:"column:deleted_by_sender"`
+	DeletedByRecipient bool `json:"-" gorm:"column:deleted_by_recipient"` // set for mail dropped by blocklist
+
+	MessageId  string `json:"-" gorm:"column:message_id"`
+	References string `json:"-" gorm:"column:message_references"`
+	Quote      string `json:"-" gorm:"-"` // quoted original as html, appended to the rendered body
+
+	RequestReceipt bool   `json:"requestReceipt,omitempty" gorm:"column:receipt_requested"`
+	NotifyAddress  string `json:"-" gorm:"column:notify_address"` // Disposition-Notification-To of the received mail
+
+	HasAttachment bool  `json:"-" gorm:"column:has_attachment"`
+	Unread        *bool `json:"-" gorm:"column:unread"` // set by import, new mails are unread
+
+	Signature   int  `json:"signature,omitempty" gorm:"-"`   // signature to append instead of the default one
+	NoSignature bool `json:"noSignature,omitempty" gorm:"-"` // don't append any signature
+
+	Headers   map[string]string `json:"-" gorm:"-"`
+	Raw       []byte            `json:"-" gorm:"column:raw"` // original message as received by smtp server, stored with attachments only
+	Forwarded []byte            `json:"-" gorm:"-"`          // raw message attached to the forward
 }
 
+// statuses of the sent mail
+const (
+	MailStatusFailed = 0
+	MailStatusSent   = 1
+)
+
 type DialogueEmail struct {
-	Id            int       `json:"id" gorm:"column:id"`
-	Sender        string    `json:"sender" gorm:"column:sender"`
-	Subject       string    `json:"title" gorm:"column:subject"`
-	Received_date time.Time `json:"time" gorm:"column:received_date"`
-	Body          string    `json:"body" gorm:"column:body"`
-	Unread        bool      `json:"new" gorm:"column:unread"`
-	Status        int       `json:"status" gorm:"column:status"`
+	Id            int               `json:"id" gorm:"column:id"`
+	Sender        string            `json:"sender" gorm:"column:sender"`
+	Subject       string            `json:"title" gorm:"column:subject"`
+	Received_date time.Time         `json:"time" gorm:"column:received_date"`
+	Body          string            `json:"body" gorm:"column:body"`
+	Unread        bool              `json:"new" gorm:"column:unread"`
+	Status        int               `json:"status" gorm:"column:status"`
+	SenderAlias   common.NullString `json:"senderAlias" gorm:"column:sender_alias"`
+	Labels        common.StringList `json:"labels" gorm:"column:labels"`
+	List          common.NullString `json:"list" gorm:"column:list_address"`
+	Recipient     string            `json:"-" gorm:"column:recipient"`
+	MessageId     common.NullString `json:"-" gorm:"column:message_id"`
+	References    common.NullString `json:"-" gorm:"column:message_references"`
+	ReadDate      *time.Time        `json:"readDate,omitempty" gorm:"column:read_date"` // set for the sender only
+	NotifyAddress common.NullString `json:"-" gorm:"column:notify_address"`
+	Starred       bool              `json:"starred" gorm:"column:starred"` // by the user the mail is returned to
+}
+
+// HeaderMessageId returns Message-ID of the mail, mails sent by us have it derived from the mail id
+func (e DialogueEmail) HeaderMessageId() string {
+	if e.MessageId.Valid && e.MessageId.String != "" {
+		return e.MessageId.String
+	}
+	splitted := strings.Split(e.Sender, "@")
+	return fmt.Sprintf("<%d@%s>", e.Id, splitted[len(splitted)-1])
 }
 
 type Dialogue struct {
 	Id            int               `json:"id" gorm:"column:id"`
 	Email         string            `json:"username" gorm:"column:other"`
+	Name          common.NullString `json:"name" gorm:"column:display_name"`
 	AvatarURL     common.NullString `json:"avatarUrl" gorm:"column:avatar_url"`
 	Body          string            `json:"body" gorm:"column:body"`
 	Received_date time.Time         `json:"time" gorm:"column:received_date"`
 	Unread        int               `json:"new" gorm:"column:unread"`
 	Owner         string			`gorm:"column:owner"`
+	SnoozedUntil  *time.Time        `json:"snoozedUntil,omitempty" gorm:"column:snoozed_until"`
+	Pinned        bool              `json:"pinned" gorm:"column:pinned"`
+	Muted         bool              `json:"muted" gorm:"column:muted"`
+	Archived      bool              `json:"archived" gorm:"column:archived"`
 }
 
 type Folder struct {
 	Id         int    `json:"id" gorm:"column:id"`
 	FolderName string `json:"name" gorm:"column:folder_name"`
 	Owner      int    `json:"owner" gorm:"column:owner"`
-	Unread     int    `json:"new" gorm:"column:unread"`
+	Unread     int    `json:"new" gorm:"column:unread"`    // unread dialogues of the folder and its subfolders
+	Parent     int    `json:"parent" gorm:"column:parent"` // 0 for top level folders
+	Position   int    `json:"position" gorm:"column:position"`
+
+	Smart *SmartQuery `json:"smart,omitempty" gorm:"-"` // set for smart folders, they have own ids
+}
+
+// SmartQuery selects dialogues having a mail that matches all the set conditions
+type SmartQuery struct {
+	SenderDomain  string     `json:"senderDomain,omitempty" gorm:"column:sender_domain"`
+	Unread        bool       `json:"unread,omitempty" gorm:"column:unread_only"` // dialogues with unread mails
+	After         *time.Time `json:"after,omitempty" gorm:"column:received_after"`
+	Before        *time.Time `json:"before,omitempty" gorm:"column:received_before"`
+	Label         string     `json:"label,omitempty" gorm:"column:label"`
+	HasAttachment bool       `json:"hasAttachment,omitempty" gorm:"column:has_attachment"`
+}
+
+type SmartFolder struct {
+	Id         int    `json:"id" gorm:"column:id"`
+	Owner      string `json:"-" gorm:"column:owner"`
+	Name       string `json:"name" gorm:"column:name"`
+	SmartQuery `gorm:"embedded"`
+}
+
+// page sizes of dialogues, mails and folders lists
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 100
+)
+
+// Cursor is an opaque position between items of a list, lists of dialogues and mails are ordered
+// by date and folders by position, id breaks ties so items with the same key aren't skipped
+type Cursor struct {
+	Date     time.Time `json:"d"`
+	Position int       `json:"p,omitempty"`
+	Smart    bool      `json:"s,omitempty"` // smart folders follow regular ones
+	Id       int       `json:"i"`
+	Backward bool      `json:"b,omitempty"` // pages towards the list start, to newer dialogues and mails
+}
+
+func (c Cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Reversed points to the same position paging in the opposite direction
+func (c Cursor) Reversed() Cursor {
+	c.Backward = !c.Backward
+	return c
+}
+
+func ParseCursor(cursor string) (Cursor, error) {
+	var parsed Cursor
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Cursor{}, InvalidEmailError{"invalid cursor"}
+	}
+	err = json.Unmarshal(data, &parsed)
+	if err != nil {
+		return Cursor{}, InvalidEmailError{"invalid cursor"}
+	}
+	return parsed, nil
+}
+
+// Page selects items after the cursor, the first page starts from the list start
+type Page struct {
+	Cursor *Cursor
+	Limit  int
+}
+
+func (p Page) Backward() bool {
+	return p.Cursor != nil && p.Cursor.Backward
+}
+
+// PageInfo tells whether the list continues after the page, items of any page are in the list order
+type PageInfo struct {
+	HasMore    bool   `json:"hasMore"`
+	NextCursor string `json:"nextCursor,omitempty"` // continues in the same direction, set if there are more
+	PrevCursor string `json:"prevCursor,omitempty"` // pages back, polls for newer items from the first page
+}
+
+type DialoguesPage struct {
+	Dialogues []Dialogue `json:"items"`
+	PageInfo
+}
+
+type EmailsPage struct {
+	Emails []DialogueEmail `json:"items"`
+	PageInfo
+}
+
+type FoldersPage struct {
+	Folders []Folder `json:"items"`
+	PageInfo
+}
+
+// targets of the bulk operation
+const (
+	BulkMails     = "mails"
+	BulkDialogues = "dialogues"
+)
+
+// actions of the bulk operation
+const (
+	BulkRead      = "read"
+	BulkUnread    = "unread"
+	BulkMove      = "move" // dialogues only
+	BulkLabel     = "label"
+	BulkStar      = "star"      // stars the last mail of the dialogue
+	BulkUnstar    = "unstar"    // unstars every mail of the dialogue
+	BulkArchive   = "archive"   // dialogues only
+	BulkUnarchive = "unarchive" // dialogues only
+	BulkDelete    = "delete"
+	BulkRestore   = "restore" // mails only, deleted dialogues are gone with their ids
+)
+
+const MaxBulkItems = 500
+
+// Bulk is an action applied to each mail or dialogue of the list in one transaction
+type Bulk struct {
+	Target string `json:"target"`
+	Action string `json:"action"`
+	Ids    []int  `json:"ids"`
+	Folder int    `json:"folder"` // destination of the move, 0 is the main folder
+	Label  string `json:"label"`
+}
+
+// BulkResult tells whether the action was applied to the item, missing items don't fail the others
+type BulkResult struct {
+	Id    int    `json:"id"`
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	Other string `json:"-"` // other side of the dialogue the item belongs to
+}
+
+// MailFilter selects mails of the mailbox, zero fields don't filter
+type MailFilter struct {
+	Ids         []int
+	DialogueIds []int // DialogueIds and FolderIds together select mails of any of them
+	FolderIds   []int
+	FolderId    *int // 0 selects dialogues out of folders
+	AfterId     int  // selects mails with greater ids
+	OrderById   bool // sorts from the oldest id instead of the latest received mail
+	// CollapseThreads selects only the latest mail of each dialogue
+	CollapseThreads bool
+	Limit           int
+	Offset      int
+}
+
+// MailboxEmail is a mail with the dialogue of the owner it belongs to
+type MailboxEmail struct {
+	DialogueEmail `gorm:"embedded"`
+	DialogueId    int `gorm:"column:dialogue_id"`
+	FolderId      int `gorm:"column:folder"` // 0 for dialogues out of folders
+}
+
+// MailboxCounters count dialogues and mails of the folder without its subfolders
+type MailboxCounters struct {
+	FolderId      int `gorm:"column:folder"` // 0 for dialogues out of folders
+	Threads       int `gorm:"column:threads"`
+	UnreadThreads int `gorm:"column:unread_threads"`
+	Emails        int `gorm:"column:emails"`
+	UnreadEmails  int `gorm:"column:unread_emails"`
+}
+
+// formats of the mailbox export
+const (
+	ExportMbox = "mbox"
+	ExportEml  = "eml" // zip of a message file per mail
+)
+
+// statuses of the mailbox export
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+	ExportExpired = "expired" // archive is removed after the download link expired
+)
+
+// ExportDownloadPath is followed by the token in download links of exports
+const ExportDownloadPath = "/email/export/download/"
+
+// ExportLifetime is how long the download link of the export works
+const ExportLifetime = 24 * time.Hour
+
+// Export is a job writing mails of the selected dialogues and folders, or all of them, to an archive
+type Export struct {
+	Id          int            `json:"id" gorm:"column:id"`
+	Owner       string         `json:"-" gorm:"column:owner"`
+	Format      string         `json:"format" gorm:"column:format"`
+	Dialogues   common.IntList `json:"dialogues" gorm:"column:dialogues"`
+	Folders     common.IntList `json:"folders" gorm:"column:folders"` // folders without their subfolders
+	Status      string         `json:"status" gorm:"column:status"`
+	Total       int            `json:"total" gorm:"column:total"`
+	Processed   int            `json:"processed" gorm:"column:processed"`
+	Error       string         `json:"error,omitempty" gorm:"column:error"`
+	Token       string         `json:"-" gorm:"column:token"`
+	CreatedDate time.Time      `json:"created" gorm:"column:created_date"`
+	ExpiresDate *time.Time     `json:"expires,omitempty" gorm:"column:expires_date"`
+	DownloadURL string         `json:"downloadUrl,omitempty" gorm:"-"` // set while the archive can be downloaded
+}
+
+// FileName is the name of the archive offered for download
+func (e Export) FileName() string {
+	if e.Format == ExportEml {
+		return fmt.Sprintf("export_%d.zip", e.Id)
+	}
+	return fmt.Sprintf("export_%d.mbox", e.Id)
+}
+
+// sources of the mailbox import
+const (
+	ImportMbox = "mbox"
+	ImportEml  = "eml" // zip of message files, directories are imported to folders
+	ImportIMAP = "imap"
+)
+
+// Import is a job adding mails of the uploaded archive or remote IMAP account to the mailbox,
+// statuses are the same as of the export
+type Import struct {
+	Id          int       `json:"id" gorm:"column:id"`
+	Owner       string    `json:"-" gorm:"column:owner"`
+	Source      string    `json:"source" gorm:"column:source"`
+	Host        string    `json:"host,omitempty" gorm:"column:imap_host"`
+	Username    string    `json:"username,omitempty" gorm:"column:imap_username"`
+	Status      string    `json:"status" gorm:"column:status"`
+	Processed   int       `json:"processed" gorm:"column:processed"`
+	Imported    int       `json:"imported" gorm:"column:imported"`
+	Duplicates  int       `json:"duplicates" gorm:"column:duplicates"` // skipped by Message-ID
+	Failed      int       `json:"failed" gorm:"column:failed"`         // messages which couldn't be parsed or have no recipients
+	Progress    string    `json:"-" gorm:"column:progress"`            // JSON of the last imported position by source folder
+	Error       string    `json:"error,omitempty" gorm:"column:error"`
+	Token       string    `json:"-" gorm:"column:token"` // names the uploaded archive in the storage
+	CreatedDate time.Time `json:"created" gorm:"column:created_date"`
+}
+
+// IMAPAccount is the remote account to import from, the password is used only by the running import
+type IMAPAccount struct {
+	Host     string `json:"host"` // port 993 is used if the host doesn't have one
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// SyncChange is a mail, dialogue or folder changed after the sync token
+type SyncChange struct {
+	Id            int   `gorm:"column:id"`
+	Modseq        int64 `gorm:"column:modseq"`
+	CreatedModseq int64 `gorm:"column:created_modseq"`
+	Deleted       bool  `gorm:"column:deleted"`
+}
+
+// SyncChanges lists ids of the objects by the kind of their last change
+type SyncChanges struct {
+	Created []int `json:"created"`
+	Updated []int `json:"updated"`
+	Deleted []int `json:"deleted"`
+}
+
+// Sync holds changes of the mailbox since the token the client has,
+// the client should ask for more with the returned token while HasMore is set
+type Sync struct {
+	Token     string      `json:"token"`
+	HasMore   bool        `json:"hasMore"`
+	Mails     SyncChanges `json:"mails"`
+	Dialogues SyncChanges `json:"dialogues"`
+	Folders   SyncChanges `json:"folders"`
+}
+
+// EventsChannel is the postgres channel mail events are published to
+const EventsChannel = "mail_events"
+
+// types of the mail events
+const (
+	EventNewMail = "new_mail"
+	EventRead    = "read"
+	EventDeleted = "deleted"
+	EventFolder  = "folder"
+)
+
+// Event tells clients of the owner what has changed in the mailbox,
+// folder events are addressed by the owner id as folders belong to users
+type Event struct {
+	Type     string `json:"type"`
+	Owner    string `json:"owner,omitempty"`
+	OwnerId  int    `json:"ownerId,omitempty"`
+	Dialogue string `json:"dialogue,omitempty"`
+	FolderId int    `json:"folder,omitempty"`
+	MailIds  []int  `json:"mails,omitempty"`
+}
+
+const DefaultVacationReplyInterval = 7
+
+type Vacation struct {
+	Owner         string    `json:"-" gorm:"column:owner"`
+	Enabled       bool      `json:"enabled" gorm:"column:enabled"`
+	StartDate     time.Time `json:"startDate" gorm:"column:start_date"`
+	EndDate       time.Time `json:"endDate" gorm:"column:end_date"`
+	Subject       string    `json:"subject" gorm:"column:subject"`
+	Body          string    `json:"body" gorm:"column:body"`
+	OnlyContacts  bool      `json:"onlyContacts" gorm:"column:only_contacts"`
+	ReplyInterval int       `json:"replyInterval" gorm:"column:reply_interval"` // in days
+}
+
+// zero EndDate means that vacation lasts until it is disabled
+func (v Vacation) IsActive(now time.Time) bool {
+	if !v.Enabled || now.Before(v.StartDate) {
+		return false
+	}
+	return v.EndDate.IsZero() || now.Before(v.EndDate)
+}
+
+// added by every forwarding hop, used to detect forwarding loops
+const ForwardTraceHeader = "X-Liokor-Forwarded"
+const MaxForwardHops = 5
+
+type Forwarding struct {
+	Owner         string            `json:"-" gorm:"column:owner"`
+	Enabled       bool              `json:"enabled" gorm:"column:enabled"`
+	Address       string            `json:"address" gorm:"column:address"`
+	Verified      bool              `json:"verified" gorm:"column:verified"`
+	Token         common.NullString `json:"-" gorm:"column:token"`
+	KeepCopy      bool              `json:"keepCopy" gorm:"column:keep_copy"`
+	FilterSender  string            `json:"filterSender" gorm:"column:filter_sender"`
+	FilterSubject string            `json:"filterSubject" gorm:"column:filter_subject"`
+}
+
+// empty filters match every mail
+func (f Forwarding) Matches(email Mail) bool {
+	if f.FilterSender != "" && !strings.Contains(strings.ToLower(email.Sender), strings.ToLower(f.FilterSender)) {
+		return false
+	}
+	if f.FilterSubject != "" && !strings.Contains(strings.ToLower(email.Subject), strings.ToLower(f.FilterSubject)) {
+		return false
+	}
+	return true
+}
+
+type Alias struct {
+	Id    int    `json:"id" gorm:"column:id"`
+	Owner string `json:"-" gorm:"column:owner"`
+	Alias string `json:"alias" gorm:"column:alias"`
+}
+
+type Signature struct {
+	Id         int               `json:"id" gorm:"column:id"`
+	Owner      string            `json:"-" gorm:"column:owner"`
+	Name       string            `json:"name" gorm:"column:name"`
+	Body       string            `json:"body" gorm:"column:body"`
+	DefaultFor common.StringList `json:"defaultFor" gorm:"column:default_for"` // own addresses using the signature by default
+}
+
+type Template struct {
+	Id      int    `json:"id" gorm:"column:id"`
+	Owner   string `json:"-" gorm:"column:owner"`
+	Name    string `json:"name" gorm:"column:name"`
+	Subject string `json:"subject" gorm:"column:subject"`
+	Body    string `json:"body" gorm:"column:body"`
+}
+
+// events webhooks can subscribe to, test event is sent by the owner only
+const (
+	WebhookReceived = "received"
+	WebhookSent     = "sent"
+	WebhookBounced  = "bounced"
+	WebhookRead     = "read"
+	WebhookTest     = "test"
+)
+
+// header with hex HMAC-SHA256 of the payload keyed by the webhook secret
+const WebhookSignatureHeader = "X-Liokor-Signature"
+const WebhookEventHeader = "X-Liokor-Event"
+
+type Webhook struct {
+	Id     int               `json:"id" gorm:"column:id"`
+	Owner  string            `json:"-" gorm:"column:owner"`
+	URL    string            `json:"url" gorm:"column:url"`
+	Events common.StringList `json:"events" gorm:"column:events"`
+	Secret string            `json:"secret" gorm:"column:secret"` // generated if empty
+}
+
+func (w Webhook) Subscribed(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookPayload is posted as JSON to the webhook url
+type WebhookPayload struct {
+	Event    string       `json:"event"`
+	Owner    string       `json:"owner"`
+	Time     time.Time    `json:"time"`
+	Mail     *WebhookMail `json:"mail,omitempty"`
+	Dialogue string       `json:"dialogue,omitempty"` // set for read event
+}
+
+type WebhookMail struct {
+	Id        int    `json:"id"`
+	Sender    string `json:"sender"`
+	Recipient string `json:"recipient"`
+	Subject   string `json:"subject"`
+}
+
+type WebhookDelivery struct {
+	Id           int       `json:"id" gorm:"column:id"`
+	Webhook      int       `json:"-" gorm:"column:webhook"`
+	Event        string    `json:"event" gorm:"column:event"`
+	Attempt      int       `json:"attempt" gorm:"column:attempt"`
+	StatusCode   int       `json:"statusCode" gorm:"column:status_code"` // 0 if no response was received
+	Error        string    `json:"error,omitempty" gorm:"column:error"`
+	DeliveryDate time.Time `json:"time" gorm:"column:delivery_date"`
+}
+
+func (d WebhookDelivery) Succeeded() bool {
+	return d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+// WebhookRetry is the failed delivery waiting for its next attempt
+type WebhookRetry struct {
+	Id          int       `gorm:"column:id"`
+	Webhook     int       `gorm:"column:webhook"`
+	Payload     string    `gorm:"column:payload"` // JSON of WebhookPayload
+	Attempt     int       `gorm:"column:attempt"` // number of the next attempt
+	NextAttempt time.Time `gorm:"column:next_attempt"`
+}
+
+// PushSubscription is the browser PushSubscription JSON, it belongs to the session registered it
+type PushSubscription struct {
+	Id       int      `json:"-" gorm:"column:id"`
+	Owner    string   `json:"-" gorm:"column:owner"`
+	Session  string   `json:"-" gorm:"column:session"`
+	Endpoint string   `json:"endpoint" gorm:"column:endpoint"`
+	Keys     PushKeys `json:"keys" gorm:"embedded"`
+}
+
+type PushKeys struct {
+	P256dh string `json:"p256dh" gorm:"column:p256dh"`
+	Auth   string `json:"auth" gorm:"column:auth"`
+}
+
+// PushMessage is shown as notification by the service worker of the frontend
+type PushMessage struct {
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+	Dialogue string `json:"dialogue"`
+	MailId   int    `json:"mailId"`
+}
+
+type RenderedTemplate struct {
+	Subject  string `json:"subject"`
+	Markdown string `json:"markdown"`
+	Html     string `json:"html"`
+}
+
+type Preview struct {
+	Html     string   `json:"html"`
+	Stripped []string `json:"stripped"` // tags and attributes removed by sanitizer
+}
+
+type Domain struct {
+	Id             int               `json:"-" gorm:"column:id"`
+	Name           string            `json:"name" gorm:"column:name"`
+	DkimSelector   string            `json:"-" gorm:"column:dkim_selector"`
+	DkimPrivateKey common.NullString `json:"-" gorm:"column:dkim_private_key"`
+	CatchAll       common.NullString `json:"-" gorm:"column:catch_all"`
+}
+
+type MailboxMember struct {
+	Mailbox string `json:"mailbox" gorm:"column:mailbox"`
+	Member  string `json:"member" gorm:"column:member"`
+	IsAdmin bool   `json:"isAdmin" gorm:"column:is_admin"`
+}
+
+type DistributionList struct {
+	Id      int               `json:"id" gorm:"column:id"`
+	Name    string            `json:"name" gorm:"column:name"`
+	Domain  string            `json:"domain" gorm:"column:domain"`
+	Owner   string            `json:"-" gorm:"column:owner"`
+	Members common.StringList `json:"members" gorm:"column:members"`
+	Posting string            `json:"posting" gorm:"column:posting"`
+}
+
+// posting policies of the distribution list, unknown one is treated as ListPostMembers
+const (
+	ListPostMembers = "members" // members and the owner of the list
+	ListPostLocal   = "local"   // users of the served domains
+	ListPostAnyone  = "anyone"
+)
+
+func (l DistributionList) Address() string {
+	return l.Name + "@" + l.Domain
+}
+
+// SenderRule blocks or allows mail from the address or the domain, rule without owner is global
+type SenderRule struct {
+	Id      int               `json:"id" gorm:"column:id"`
+	Owner   common.NullString `json:"-" gorm:"column:owner"`
+	Pattern string            `json:"pattern" gorm:"column:pattern"`
+	Allow   bool              `json:"allow" gorm:"column:allow"`
+}
+
+type SenderFilter struct {
+	DropBlocked bool         `json:"dropBlocked"`
+	Rules       []SenderRule `json:"rules"`
 }
 
 type MessageResponse struct {
@@ -52,3 +613,21 @@ type InvalidEmailError struct {
 func (e InvalidEmailError) Error() string {
 	return e.Message
 }
+
+type AccessDeniedError struct {
+	Message string
+}
+
+func (e AccessDeniedError) Error() string {
+	return e.Message
+}
+
+// SenderBlockedError is returned for mail from blocked sender, Drop means it should be accepted and discarded
+type SenderBlockedError struct {
+	Message string
+	Drop    bool
+}
+
+func (e SenderBlockedError) Error() string {
+	return e.Message
+}
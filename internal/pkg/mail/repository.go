@@ -1,26 +1,159 @@
 package mail
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type MailRepository interface {
-	AddMail(mail Mail, domain string) (int, error)
-	GetMailsForUser(username string, email string, limit int, last int) ([]DialogueEmail, error)
-	ReadMail(owner, other string) error
+	AddMail(mail Mail, owners []string) (int, error)
+	GetMailsForUser(username string, email string, page Page) ([]DialogueEmail, error)
+	GetMail(username string, mailId int) (DialogueEmail, error)
+	GetMailRaw(mailId int) ([]byte, error)
+	ReadMail(owners []string, other string) error
 	CountMailsFromUser(username string, interval time.Duration) (int, error)
 	UpdateMailStatus(mailId, status int) error
+	UpdateDeliveryStatus(mailId int, sender string, recipient string, status int) error
+	SaveMailRead(mailId int, sender string, recipient string) error
+	SaveReadReceipts(owner string, other string) ([]DialogueEmail, error)
+	GetReadReceipts(owner string) (bool, error)
+	GetUserId(username string) (int, error)
+	UpdateReadReceipts(owner string, allow bool) error
 	DeleteMail(owner string, mailIds []int, domain string) error
+	BulkMails(owner string, domain string, bulk Bulk) ([]BulkResult, error)
 
 	CreateDialogue(owner string, other string) (Dialogue, error)
 	UpdateDialogueLastMail(owner string, other string, domain string) error
-	GetDialoguesInFolder(username string, limit int, folderId int, domain string, since time.Time) ([]Dialogue, error)
-	FindDialogues(username string, find string, limit int, domain string, since time.Time) ([]Dialogue, error)
+	GetDialoguesInFolder(username string, folderId int, page Page) ([]Dialogue, error)
+	FindDialogues(username string, find string, page Page) ([]Dialogue, error)
+	GetSnoozedDialogues(username string, page Page) ([]Dialogue, error)
+	GetArchivedDialogues(username string, page Page) ([]Dialogue, error)
+	GetPinnedDialogues(username string, folderId int) ([]Dialogue, error)
+	UpdateDialoguePinned(owner string, dialogueId int, pinned bool) error
+	UpdateDialogueMuted(owner string, dialogueId int, muted bool) error
+	SnoozeDialogue(owner string, dialogueId int, until *time.Time) error
+	WakeSnoozedDialogues(now time.Time) (int, error)
 	ReadDialogue(owner, other string) error
 	DeleteDialogue(owner string, dialogueId int, domain string) error
+	BulkDialogues(owner string, domain string, bulk Bulk) ([]BulkResult, error)
 
-	CreateFolder(ownerId int, folderName string) (Folder, error)
+	CreateFolder(ownerId int, folderName string, parentId int) (Folder, error)
 	GetFolders(ownerId int) ([]Folder, error)
 	AddDialogueToFolder(owner string, folderId, dialogueId int) error
 	UpdateFolderName(owner, folderId int, folderName string) (Folder, error)
+	MoveFolder(owner, folderId int, parentId int, position int) error
 	ShiftToMainFolderDialogues(owner string, folderId int) error
 	DeleteFolder(owner, folderId int) error
+
+	GetMailboxEmails(owner string, addresses []string, filter MailFilter) ([]MailboxEmail, error)
+	GetMailboxEmailIds(owner string, addresses []string, filter MailFilter) ([]int, error)
+	GetMailboxCounters(owner string, addresses []string) ([]MailboxCounters, error)
+	CountMailboxEmails(owner string, addresses []string, filter MailFilter) (int, error)
+	GetMailChanges(address string, since int64, limit int) ([]SyncChange, error)
+	GetDialogueChanges(owner string, since int64, limit int) ([]SyncChange, error)
+	GetFolderChanges(ownerId int, since int64, limit int) ([]SyncChange, error)
+	GetModseq(owner string, address string, ownerId int) (int64, error)
+	GetModseqLimit() (int64, error)
+
+	GetSmartFolders(owner string) ([]SmartFolder, error)
+	GetSmartFolder(owner string, folderId int) (SmartFolder, error)
+	CreateSmartFolder(folder SmartFolder) (SmartFolder, error)
+	UpdateSmartFolder(folder SmartFolder) (SmartFolder, error)
+	DeleteSmartFolder(owner string, folderId int) error
+	CountSmartFoldersUnread(owner string, address string, queries []SmartQuery) ([]int, error)
+	GetSmartFolderDialogues(owner string, address string, query SmartQuery, page Page) ([]Dialogue, error)
+
+	GetVacation(owner string) (Vacation, error)
+	UpdateVacation(vacation Vacation) (Vacation, error)
+	VacationReplied(owner string, sender string, interval time.Duration) (bool, error)
+	SaveVacationReply(owner string, sender string) error
+	IsContact(ownerEmail string, other string) (bool, error)
+	CollectContact(owner string, address string) error
+
+	GetForwarding(owner string) (Forwarding, error)
+	UpdateForwarding(forwarding Forwarding) (Forwarding, error)
+	ConfirmForwarding(token string) error
+
+	GetAliases(owner string) ([]Alias, error)
+	CreateAlias(owner string, alias string) (Alias, error)
+	DeleteAlias(owner string, aliasId int) error
+	GetAddressOwner(localPart string, domain string) (string, error)
+	GetOwnerAddresses(owner string) ([]string, error)
+	AddMailLabel(mailId int, owner string, label string) error
+
+	GetDomains() ([]Domain, error)
+	GetDomain(name string) (Domain, error)
+	AddDefaultDomain(name string) error
+	GetUserDomain(username string) (Domain, error)
+
+	CreateMailbox(owner string, name string, domain string) (MailboxMember, error)
+	GetMailboxes(member string) ([]MailboxMember, error)
+	GetMailboxMembers(mailbox string) ([]MailboxMember, error)
+	GetMailboxMember(mailbox string, member string) (MailboxMember, error)
+	AddMailboxMember(member MailboxMember) error
+	DeleteMailboxMember(mailbox string, member string) error
+
+	GetDistributionLists(owner string) ([]DistributionList, error)
+	GetDistributionList(name string, domain string) (DistributionList, error)
+	CreateDistributionList(list DistributionList) (DistributionList, error)
+	DeleteDistributionList(owner string, listId int) error
+	UpdateListPosting(owner string, listId int, posting string) error
+	AddListMember(owner string, listId int, address string) error
+	DeleteListMember(owner string, listId int, address string) error
+
+	GetSenderRules(owner string) ([]SenderRule, error)
+	FindSenderRules(owner string, patterns []string) ([]SenderRule, error)
+	AddSenderRule(rule SenderRule) (SenderRule, error)
+	DeleteSenderRule(owner string, ruleId int) error
+	GetDropBlocked(owner string) (bool, error)
+	UpdateDropBlocked(owner string, drop bool) error
+
+	GetSignatures(owner string) ([]Signature, error)
+	GetSignature(owner string, signatureId int) (Signature, error)
+	GetDefaultSignature(owner string, alias string) (Signature, error)
+	CreateSignature(signature Signature) (Signature, error)
+	UpdateSignature(signature Signature) (Signature, error)
+	DeleteSignature(owner string, signatureId int) error
+
+	GetTemplates(owner string) ([]Template, error)
+	GetTemplate(owner string, templateId int) (Template, error)
+	CreateTemplate(template Template) (Template, error)
+	UpdateTemplate(template Template) (Template, error)
+	DeleteTemplate(owner string, templateId int) error
+	GetFullName(username string) (string, error)
+	GetContactName(owner string, address string) (string, error)
+
+	GetWebhooks(owner string) ([]Webhook, error)
+	GetWebhook(owner string, webhookId int) (Webhook, error)
+	CreateWebhook(webhook Webhook) (Webhook, error)
+	DeleteWebhook(owner string, webhookId int) error
+	AddWebhookDelivery(delivery WebhookDelivery) error
+	GetWebhookDeliveries(webhookId int, limit int) ([]WebhookDelivery, error)
+	AddWebhookRetry(retry WebhookRetry) error
+	TakeWebhookRetries(lease time.Duration, limit int) ([]WebhookRetry, error)
+	UpdateWebhookRetry(retry WebhookRetry) error
+	DeleteWebhookRetry(retryId int) error
+
+	CreateExport(export Export) (Export, error)
+	GetExports(owner string) ([]Export, error)
+	GetExport(owner string, exportId int) (Export, error)
+	GetExportByToken(token string) (Export, error)
+	UpdateExportProgress(export Export) error
+	InterruptExports() ([]Export, error)
+	ExpireExports(now time.Time) ([]Export, error)
+
+	MailExists(sender string, recipient string, messageId string) (bool, error)
+	RebuildDialogue(owner string, other string, domain string) (int, error)
+	CreateImport(mailImport Import) (Import, error)
+	GetImports(owner string) ([]Import, error)
+	GetImport(owner string, importId int) (Import, error)
+	UpdateImportProgress(mailImport Import) error
+	InterruptImports() error
+
+	SavePushSubscription(subscription PushSubscription) error
+	DeletePushSubscription(session string) error
+	DeletePushEndpoint(endpoint string) error
+	GetPushSubscriptions(owner string, other string) ([]PushSubscription, error)
+
+	ListenEvents(ctx context.Context, handle func(Event)) error
 }
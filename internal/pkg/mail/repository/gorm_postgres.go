@@ -1,12 +1,17 @@
 package repository
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4/stdlib"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"liokor_mail/internal/pkg/common"
 	"liokor_mail/internal/pkg/mail"
+	"log"
 	"strings"
 	"time"
 )
@@ -16,83 +21,190 @@ type GormPostgresMailRepository struct {
 }
 
 
-func (gmr *GormPostgresMailRepository) AddMail(email mail.Mail, domain string) (int, error) {
-	result := gmr.DBInstance.DB.
-		Table("mails").
-		Select("sender", "recipient", "subject", "body").
-		Create(&email)
-	if err := result.Error; err != nil {
-		return 0, err
+func containsAddress(addresses []string, address string) bool {
+	for _, a := range addresses {
+		if strings.EqualFold(a, address) {
+			return true
+		}
 	}
+	return false
+}
 
-	sender := strings.Split(email.Sender, "@")
-	recipient := strings.Split(email.Recipient, "@")
-	if len(sender) == 2 && sender[1] == domain {
-		if !gmr.DialogueExists(sender[0], email.Recipient) {
-			_, err := gmr.CreateDialogue(sender[0], email.Recipient)
-			if err != nil {
-				return email.Id, err
-			}
+// AddMail saves mail and updates dialogues of the local participants listed in owners
+func (gmr *GormPostgresMailRepository) AddMail(email mail.Mail, owners []string) (int, error) {
+	columns := []string{"sender", "recipient", "subject", "body"}
+	if email.Alias != "" {
+		columns = append(columns, "sender_alias")
+	}
+	if email.List != "" {
+		columns = append(columns, "list_address")
+	}
+	if email.DeletedBySender {
+		columns = append(columns, "deleted_by_sender")
+	}
+	if email.DeletedByRecipient {
+		columns = append(columns, "deleted_by_recipient")
+	}
+	if email.MessageId != "" {
+		columns = append(columns, "message_id")
+	}
+	if email.References != "" {
+		columns = append(columns, "message_references")
+	}
+	if email.RequestReceipt {
+		columns = append(columns, "receipt_requested")
+	}
+	if email.NotifyAddress != "" {
+		columns = append(columns, "notify_address")
+	}
+	if email.HasAttachment {
+		columns = append(columns, "has_attachment")
+		// attachments aren't kept anywhere else, the raw message is needed to forward them
+		if len(email.Raw) != 0 {
+			columns = append(columns, "raw")
 		}
-		err := gmr.UpdateDialogueLastMail(sender[0], email.Recipient, domain)
+	}
+	if !email.Received_date.IsZero() {
+		columns = append(columns, "received_date")
+	}
+	if email.Unread != nil {
+		columns = append(columns, "unread")
+	}
+	err := gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		txRepo := gmr.inTransaction(tx)
+		err := tx.Table("mails").
+			Select(columns).
+			Create(&email).Error
 		if err != nil {
-			return email.Id, err
+			return err
 		}
-	}
-	if len(recipient) == 2 && recipient[1] == domain {
-		if !gmr.DialogueExists(recipient[0], email.Sender) {
-			_, err := gmr.CreateDialogue(recipient[0], email.Sender)
+
+		sender := strings.Split(email.Sender, "@")
+		recipient := strings.Split(email.Recipient, "@")
+		if len(sender) == 2 && containsAddress(owners, email.Sender) {
+			if !txRepo.DialogueExists(sender[0], email.Recipient) {
+				_, err := txRepo.CreateDialogue(sender[0], email.Recipient)
+				if err != nil {
+					return err
+				}
+			}
+			err := txRepo.UpdateDialogueLastMail(sender[0], email.Recipient, sender[1])
 			if err != nil {
-				return email.Id, err
+				return err
+			}
+			err = publish(tx, mail.Event{
+				Type:     mail.EventNewMail,
+				Owner:    sender[0],
+				Dialogue: email.Recipient,
+				MailIds:  []int{email.Id},
+			})
+			if err != nil {
+				return err
 			}
 		}
-		err := gmr.UpdateDialogueLastMail(recipient[0], email.Sender, domain)
-		if err != nil {
-			return email.Id, err
+		if len(recipient) == 2 && containsAddress(owners, email.Recipient) {
+			if !txRepo.DialogueExists(recipient[0], email.Sender) {
+				_, err := txRepo.CreateDialogue(recipient[0], email.Sender)
+				if err != nil {
+					return err
+				}
+			}
+			err := txRepo.UpdateDialogueLastMail(recipient[0], email.Sender, recipient[1])
+			if err != nil {
+				return err
+			}
+			return publish(tx, mail.Event{
+				Type:     mail.EventNewMail,
+				Owner:    recipient[0],
+				Dialogue: email.Sender,
+				MailIds:  []int{email.Id},
+			})
 		}
-
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 	return email.Id, nil
 }
 
-func (gmr *GormPostgresMailRepository) GetMailsForUser(username string, email string, limit int, last int) ([]mail.DialogueEmail, error) {
-	mails := make([]mail.DialogueEmail, 0)
-	gmr.DBInstance.DB.
+// GetMail returns the mail if the user is its sender or recipient and didn't delete it
+func (gmr *GormPostgresMailRepository) GetMail(username string, mailId int) (mail.DialogueEmail, error) {
+	var email mail.DialogueEmail
+	result := gmr.DBInstance.DB.
 		Table("mails").
-		Select("id, sender, subject, received_date, body, unread, status").
-		Limit(limit).
-		Order("id desc").
+		Select("id, sender, recipient, subject, received_date, body, unread, status, sender_alias, list_address, message_id, message_references").
+		Where("id=?", mailId).
 		Where(
 			gmr.DBInstance.DB.Where(
-				"sender=? AND recipient=? AND deleted_by_sender=FALSE",
+				"sender=? AND deleted_by_sender=FALSE",
 				username,
-				email,
 			).Or(
-				"sender=? AND recipient=? AND deleted_by_recipient=FALSE",
-				email,
+				"recipient=? AND deleted_by_recipient=FALSE",
 				username,
 			)).
+		Limit(1).
+		Scan(&email)
+	if err := result.Error; err != nil {
+		return mail.DialogueEmail{}, err
+	}
+	if result.RowsAffected == 0 {
+		return mail.DialogueEmail{}, mail.InvalidEmailError{"mail doesn't exist"}
+	}
+	return email, nil
+}
+
+// GetMailRaw returns the stored raw message of the mail, nil if the mail has no attachments
+func (gmr *GormPostgresMailRepository) GetMailRaw(mailId int) ([]byte, error) {
+	var email mail.Mail
+	err := gmr.DBInstance.DB.
+		Table("mails").
+		Select("raw").
+		Where("id=?", mailId).
+		Limit(1).
+		Scan(&email).Error
+	if err != nil {
+		return nil, err
+	}
+	return email.Raw, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetMailsForUser(username string, email string, page mail.Page) ([]mail.DialogueEmail, error) {
+	mails := make([]mail.DialogueEmail, 0)
+	err := pageQuery(gmr.DBInstance.DB.Table("mails"), "received_date", "id", page).
+		Select(
+			"id, sender, recipient, subject, received_date, body, unread, status, sender_alias, list_address, "+
+				"CASE WHEN sender=? THEN read_date END read_date, "+
+				"CASE WHEN sender=? THEN starred_by_sender ELSE starred_by_recipient END starred, "+
+				"(SELECT string_agg(label, ',') FROM mail_labels WHERE mail_labels.mail_id=mails.id AND mail_labels.owner=?) labels",
+			username,
+			username,
+			username,
+		).
 		Where(
 			gmr.DBInstance.DB.Where(
-				"id < ?",
-				last, // last is actually before
+				"sender=? AND recipient=? AND deleted_by_sender=FALSE",
+				username,
+				email,
 			).Or(
-				"? <= 0",
-				last,
+				"sender=? AND recipient=? AND deleted_by_recipient=FALSE",
+				email,
+				username,
 			)).
-		Scan(&mails)
-	if err := gmr.DBInstance.DB.Error; err != nil {
+		Scan(&mails).Error
+	if err != nil {
 		return nil, err
 	}
 	return mails, nil
 }
 
-func (gmr *GormPostgresMailRepository) ReadMail(owner, other string) error {
+// ReadMail marks read mails from other to any of the owner addresses
+func (gmr *GormPostgresMailRepository) ReadMail(owners []string, other string) error {
 	result := gmr.DBInstance.DB.
 		Table("mails").
 		Where(
-			"recipient=? AND sender=?",
-			owner,
+			"recipient IN ? AND sender=?",
+			owners,
 			other,
 			).
 		Update("unread", false)
@@ -116,6 +228,90 @@ func (gmr *GormPostgresMailRepository) UpdateMailStatus(mailId, status int) erro
 	return nil
 }
 
+// UpdateDeliveryStatus changes status of the mail reported by DSN, the mail is checked to be sent by sender to recipient.
+// Imported mails keep foreign Message-ID, so only mails with Message-ID generated from the id are reported
+func (gmr *GormPostgresMailRepository) UpdateDeliveryStatus(mailId int, sender string, recipient string, status int) error {
+	return gmr.DBInstance.DB.Exec(
+		"UPDATE mails SET status=? WHERE id=? AND sender=? AND LOWER(recipient)=LOWER(?) AND COALESCE(message_id, '')=''",
+		status,
+		mailId,
+		sender,
+		recipient,
+	).Error
+}
+
+// SaveMailRead saves read date of the external mail reported by MDN, checked as in UpdateDeliveryStatus
+func (gmr *GormPostgresMailRepository) SaveMailRead(mailId int, sender string, recipient string) error {
+	return gmr.DBInstance.DB.Exec(
+		"UPDATE mails SET read_date=NOW() WHERE id=? AND sender=? AND LOWER(recipient)=LOWER(?) "+
+			"AND COALESCE(message_id, '')='' AND receipt_requested AND read_date IS NULL",
+		mailId,
+		sender,
+		recipient,
+	).Error
+}
+
+// SaveReadReceipts saves read date of unread mails from other asking for receipt and returns them
+func (gmr *GormPostgresMailRepository) SaveReadReceipts(owner string, other string) ([]mail.DialogueEmail, error) {
+	mails := make([]mail.DialogueEmail, 0)
+	err := gmr.DBInstance.DB.Raw(
+		"UPDATE mails SET read_date=NOW() WHERE recipient=? AND sender=? AND unread "+
+			"AND receipt_requested AND read_date IS NULL AND deleted_by_recipient=FALSE "+
+			"RETURNING id, sender, recipient, subject, message_id, notify_address, read_date",
+		owner,
+		other,
+	).Scan(&mails).Error
+	if err != nil {
+		return nil, err
+	}
+	return mails, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetUserId(username string) (int, error) {
+	var user struct {
+		Id int `gorm:"column:id"`
+	}
+	result := gmr.DBInstance.DB.
+		Table("users").
+		Select("id").
+		Where("username=?", username).
+		Limit(1).
+		Scan(&user)
+	if err := result.Error; err != nil {
+		return 0, err
+	}
+	if result.RowsAffected == 0 {
+		return 0, common.InvalidUserError{"user doesn't exist"}
+	}
+	return user.Id, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetReadReceipts(owner string) (bool, error) {
+	var receipts struct {
+		ReadReceipts bool `gorm:"column:read_receipts"`
+	}
+	result := gmr.DBInstance.DB.
+		Table("users").
+		Select("read_receipts").
+		Where("username=?", owner).
+		Limit(1).
+		Scan(&receipts)
+	if err := result.Error; err != nil {
+		return false, err
+	}
+	if result.RowsAffected == 0 {
+		return false, common.InvalidUserError{"user doesn't exist"}
+	}
+	return receipts.ReadReceipts, nil
+}
+
+func (gmr *GormPostgresMailRepository) UpdateReadReceipts(owner string, allow bool) error {
+	return gmr.DBInstance.DB.
+		Table("users").
+		Where("username=?", owner).
+		Update("read_receipts", allow).Error
+}
+
 func (gmr *GormPostgresMailRepository) DeleteMail(owner string, mailIds []int, domain string) error {
 	ownerMail := owner + "@" + domain
 	others := make([]string, 0)
@@ -161,17 +357,24 @@ func (gmr *GormPostgresMailRepository) DeleteMail(owner string, mailIds []int, d
 		}
 		others = append(others, other)
 	}
-	err := tx.Commit().Error
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
+	txRepo := gmr.inTransaction(tx)
 	for _, other := range others {
-		err = gmr.UpdateDialogueLastMail(owner, other, domain)
+		err := txRepo.UpdateDialogueLastMail(owner, other, domain)
 		if err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
+	err := publish(tx, mail.Event{Type: mail.EventDeleted, Owner: owner, MailIds: mailIds})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 	return nil
 }
 
@@ -257,7 +460,9 @@ func (gmr *GormPostgresMailRepository) UpdateDialogueLastMail(owner string, othe
 	}
 
 	if lastMail.Sender == other && lastMail.Unread && lastMail.Status == 1{
-		updates["unread"] = gorm.Expr("unread + 1")
+		// muted dialogue gets the mail silently, archived one is back in its folder
+		updates["unread"] = gorm.Expr("CASE WHEN muted THEN unread ELSE unread + 1 END")
+		updates["archived"] = false
 	} else {
 		updates["unread"] = 0
 	}
@@ -278,7 +483,34 @@ func (gmr *GormPostgresMailRepository) UpdateDialogueLastMail(owner string, othe
 	return nil
 }
 
-func (gmr *GormPostgresMailRepository) GetDialoguesInFolder(username string, limit int, folderId int, domain string, since time.Time) ([]mail.Dialogue, error) {
+// dialogueContactName is the name of the other side in the owner's address book
+const dialogueContactName = "(SELECT contacts.name FROM contacts JOIN contact_emails ON contact_emails.contact_id=contacts.id " +
+	"WHERE contacts.owner=dialogues.owner AND contact_emails.email=dialogues.other AND contacts.name<>'' " +
+	"ORDER BY contacts.auto_collected, contacts.id LIMIT 1) display_name"
+
+// pageQuery selects the page after the cursor ordered by the date and id columns from the newest,
+// backward page is selected in the opposite order, one more item is selected to tell whether there are more
+func pageQuery(query *gorm.DB, dateColumn string, idColumn string, page mail.Page) *gorm.DB {
+	order := "DESC"
+	if page.Cursor != nil {
+		comparison := "<"
+		if page.Cursor.Backward {
+			comparison = ">"
+			order = "ASC"
+		}
+		query = query.Where(
+			fmt.Sprintf("(%s, %s) %s (?, ?)", dateColumn, idColumn, comparison),
+			page.Cursor.Date,
+			page.Cursor.Id,
+		)
+	}
+	return query.
+		Where(dateColumn + " IS NOT NULL").
+		Order(fmt.Sprintf("%s %s, %s %s", dateColumn, order, idColumn, order)).
+		Limit(page.Limit + 1)
+}
+
+func (gmr *GormPostgresMailRepository) GetDialoguesInFolder(username string, folderId int, page mail.Page) ([]mail.Dialogue, error) {
 	dialogues := make([]mail.Dialogue, 0)
 	var folderCond string
 	if folderId == 0 {
@@ -286,13 +518,12 @@ func (gmr *GormPostgresMailRepository) GetDialoguesInFolder(username string, lim
 	} else {
 		folderCond = fmt.Sprintf("dialogues.folder=%d", folderId)
 	}
-	err := gmr.DBInstance.DB.
-		Table("dialogues").
-		Limit(limit).
-		Order("dialogues.received_date desc").
+	err := pageQuery(gmr.DBInstance.DB.Table("dialogues"), "dialogues.received_date", "dialogues.id", page).
 		Where("dialogues.owner=?", username).
 		Where(folderCond).
-		Where("dialogues.received_date<?", since).
+		Where("dialogues.snoozed_until IS NULL").
+		Where("dialogues.archived=FALSE").
+		Where("dialogues.pinned=FALSE").
 		Select(
 			"dialogues.id",
 			"dialogues.other",
@@ -300,8 +531,38 @@ func (gmr *GormPostgresMailRepository) GetDialoguesInFolder(username string, lim
 				"dialogues.body",
 				"dialogues.received_date",
 				"dialogues.unread",
+				"dialogues.pinned",
+				"dialogues.muted",
+				dialogueContactName,
 			).
-		Joins("LEFT JOIN users ON LOWER(SPLIT_PART(dialogues.other, ?, 1))=LOWER(users.username)", domain).
+		Joins("LEFT JOIN users ON LOWER(dialogues.other)=LOWER(users.username || '@' || users.domain)").
+		Scan(&dialogues).Error
+
+	if err != nil {
+		return nil, err
+	}
+	return dialogues, nil
+}
+
+func (gmr *GormPostgresMailRepository) FindDialogues(username string, find string, page mail.Page) ([]mail.Dialogue, error) {
+	dialogues := make([]mail.Dialogue, 0)
+	err := pageQuery(gmr.DBInstance.DB.Table("dialogues"), "dialogues.received_date", "dialogues.id", page).
+		Where("dialogues.owner=?", username).
+		Where("dialogues.other LIKE ?", "%" + find + "%").
+		Select(
+			"dialogues.id",
+			"dialogues.other",
+			"users.avatar_url",
+			"dialogues.body",
+			"dialogues.received_date",
+			"dialogues.unread",
+			"dialogues.snoozed_until",
+			"dialogues.pinned",
+			"dialogues.muted",
+			"dialogues.archived",
+			dialogueContactName,
+		).
+		Joins("LEFT JOIN users ON LOWER(dialogues.other)=LOWER(users.username || '@' || users.domain)").
 		Scan(&dialogues).Error
 
 	if err != nil {
@@ -310,15 +571,23 @@ func (gmr *GormPostgresMailRepository) GetDialoguesInFolder(username string, lim
 	return dialogues, nil
 }
 
-func (gmr *GormPostgresMailRepository) FindDialogues(username string, find string, limit int, domain string, since time.Time) ([]mail.Dialogue, error) {
+// GetPinnedDialogues returns pinned dialogues of the folder, they are shown above the others and aren't paginated
+func (gmr *GormPostgresMailRepository) GetPinnedDialogues(username string, folderId int) ([]mail.Dialogue, error) {
 	dialogues := make([]mail.Dialogue, 0)
+	var folderCond string
+	if folderId == 0 {
+		folderCond = "dialogues.folder IS NULL"
+	} else {
+		folderCond = fmt.Sprintf("dialogues.folder=%d", folderId)
+	}
 	err := gmr.DBInstance.DB.
 		Table("dialogues").
-		Limit(limit).
 		Order("dialogues.received_date desc").
 		Where("dialogues.owner=?", username).
-		Where("dialogues.received_date<?", since).
-		Where("dialogues.other LIKE ?", "%" + find + "%").
+		Where(folderCond).
+		Where("dialogues.snoozed_until IS NULL").
+		Where("dialogues.archived=FALSE").
+		Where("dialogues.pinned=TRUE").
 		Select(
 			"dialogues.id",
 			"dialogues.other",
@@ -326,8 +595,11 @@ func (gmr *GormPostgresMailRepository) FindDialogues(username string, find strin
 			"dialogues.body",
 			"dialogues.received_date",
 			"dialogues.unread",
+			"dialogues.pinned",
+			"dialogues.muted",
+			dialogueContactName,
 		).
-		Joins("LEFT JOIN users ON LOWER(SPLIT_PART(dialogues.other, ?, 1))=LOWER(users.username)", domain).
+		Joins("LEFT JOIN users ON LOWER(dialogues.other)=LOWER(users.username || '@' || users.domain)").
 		Scan(&dialogues).Error
 
 	if err != nil {
@@ -336,19 +608,130 @@ func (gmr *GormPostgresMailRepository) FindDialogues(username string, find strin
 	return dialogues, nil
 }
 
-func (gmr *GormPostgresMailRepository) ReadDialogue(owner, other string) error {
-		result := gmr.DBInstance.DB.
+func (gmr *GormPostgresMailRepository) UpdateDialoguePinned(owner string, dialogueId int, pinned bool) error {
+	result := gmr.DBInstance.DB.
 		Table("dialogues").
-		Where(
-		"owner=? AND other=?",
-		owner,
-		other,
-	).
-		Update("unread", 0)
-		if err:= result.Error; err != nil {
+		Where("id=? AND owner=?", dialogueId, owner).
+		Update("pinned", pinned)
+	if err := result.Error; err != nil {
 		return err
 	}
-		return nil
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"Dialogue doesn't exist"}
+	}
+	return nil
+}
+
+func (gmr *GormPostgresMailRepository) UpdateDialogueMuted(owner string, dialogueId int, muted bool) error {
+	result := gmr.DBInstance.DB.
+		Table("dialogues").
+		Where("id=? AND owner=?", dialogueId, owner).
+		Update("muted", muted)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"Dialogue doesn't exist"}
+	}
+	return nil
+}
+
+func (gmr *GormPostgresMailRepository) GetSnoozedDialogues(username string, page mail.Page) ([]mail.Dialogue, error) {
+	dialogues := make([]mail.Dialogue, 0)
+	err := pageQuery(gmr.DBInstance.DB.Table("dialogues"), "dialogues.received_date", "dialogues.id", page).
+		Where("dialogues.owner=?", username).
+		Where("dialogues.snoozed_until IS NOT NULL").
+		Select(
+			"dialogues.id",
+			"dialogues.other",
+			"users.avatar_url",
+			"dialogues.body",
+			"dialogues.received_date",
+			"dialogues.unread",
+			"dialogues.snoozed_until",
+			"dialogues.pinned",
+			"dialogues.muted",
+			dialogueContactName,
+		).
+		Joins("LEFT JOIN users ON LOWER(dialogues.other)=LOWER(users.username || '@' || users.domain)").
+		Scan(&dialogues).Error
+
+	if err != nil {
+		return nil, err
+	}
+	return dialogues, nil
+}
+
+// GetArchivedDialogues returns archived dialogues of all folders
+func (gmr *GormPostgresMailRepository) GetArchivedDialogues(username string, page mail.Page) ([]mail.Dialogue, error) {
+	dialogues := make([]mail.Dialogue, 0)
+	err := pageQuery(gmr.DBInstance.DB.Table("dialogues"), "dialogues.received_date", "dialogues.id", page).
+		Where("dialogues.owner=?", username).
+		Where("dialogues.archived=TRUE").
+		Select(
+			"dialogues.id",
+			"dialogues.other",
+			"users.avatar_url",
+			"dialogues.body",
+			"dialogues.received_date",
+			"dialogues.unread",
+			"dialogues.snoozed_until",
+			"dialogues.pinned",
+			"dialogues.muted",
+			"dialogues.archived",
+			dialogueContactName,
+		).
+		Joins("LEFT JOIN users ON LOWER(dialogues.other)=LOWER(users.username || '@' || users.domain)").
+		Scan(&dialogues).Error
+
+	if err != nil {
+		return nil, err
+	}
+	return dialogues, nil
+}
+
+// SnoozeDialogue hides the dialogue from its folder until the time, nil time shows it again
+func (gmr *GormPostgresMailRepository) SnoozeDialogue(owner string, dialogueId int, until *time.Time) error {
+	result := gmr.DBInstance.DB.
+		Table("dialogues").
+		Where("id=? AND owner=?", dialogueId, owner).
+		Update("snoozed_until", until)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"Dialogue doesn't exist"}
+	}
+	return nil
+}
+
+// WakeSnoozedDialogues moves dialogues snoozed until the time to the top of their folders as unread.
+// Dialogues woken late get the wake time, ones with mails received meanwhile keep their date
+func (gmr *GormPostgresMailRepository) WakeSnoozedDialogues(now time.Time) (int, error) {
+	result := gmr.DBInstance.DB.
+		Table("dialogues").
+		Where("snoozed_until<=?", now).
+		Updates(map[string]interface{}{
+			"received_date": gorm.Expr("GREATEST(received_date, ?)", now),
+			"unread":        gorm.Expr("unread + 1"),
+			"snoozed_until": nil,
+		})
+	if err := result.Error; err != nil {
+		return 0, err
+	}
+	return int(result.RowsAffected), nil
+}
+
+func (gmr *GormPostgresMailRepository) ReadDialogue(owner, other string) error {
+	return gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("dialogues").
+			Where("owner=? AND other=?", owner, other).
+			Update("unread", 0).Error
+		if err != nil {
+			return err
+		}
+		return publish(tx, mail.Event{Type: mail.EventRead, Owner: owner, Dialogue: other})
+	})
 }
 func (gmr *GormPostgresMailRepository) DeleteDialogue(owner string, dialogueId int, domain string) error {
 	var dialogue mail.Dialogue
@@ -361,14 +744,19 @@ func (gmr *GormPostgresMailRepository) DeleteDialogue(owner string, dialogueId i
 		}
 		return err
 	}
-	gmr.DBInstance.DB.
-		Table("dialogues").
-		Where("id=? AND owner=?", dialogueId, owner).
-		Delete(&dialogue)
-	if err = gmr.DBInstance.DB.Error; err != nil{
-		return err
-	}
-	return gmr.DeleteDialogueMails(owner, dialogue.Email, domain)
+	return gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("dialogues").
+			Where("id=? AND owner=?", dialogueId, owner).
+			Delete(&dialogue).Error
+		if err != nil {
+			return err
+		}
+		err = gmr.inTransaction(tx).DeleteDialogueMails(owner, dialogue.Email, domain)
+		if err != nil {
+			return err
+		}
+		return publish(tx, mail.Event{Type: mail.EventDeleted, Owner: owner, Dialogue: dialogue.Email})
+	})
 }
 
 func (gmr *GormPostgresMailRepository) DeleteDialogueMails(owner string, other string, domain string) error {
@@ -388,33 +776,352 @@ func (gmr *GormPostgresMailRepository) DeleteDialogueMails(owner string, other s
 	return nil
 }
 
-func (gmr *GormPostgresMailRepository) CreateFolder(ownerId int, folderName string) (mail.Folder, error) {
-	folder := mail.Folder {
-		FolderName: folderName,
-		Owner: ownerId,
-	}
-	result := gmr.DBInstance.DB.Table("folders").Select("folder_name", "owner").Create(&folder)
-	if err := result.Error; err != nil {
-		if pgerr, ok := err.(*pgconn.PgError); ok {
-			if pgerr.ConstraintName == "folders_owner_fkey" {
-				return mail.Folder{}, common.InvalidUserError{"user doesn't exist"}
-			} else if pgerr.ConstraintName == "folders_folder_name_owner_key" {
-				return mail.Folder{}, mail.InvalidEmailError{"folder already exists"}
+// BulkMails applies the action to mails of the owner in one transaction, mails the owner didn't send
+// or receive are reported as missing, dialogues of the changed mails are recalculated
+func (gmr *GormPostgresMailRepository) BulkMails(owner string, domain string, bulk mail.Bulk) ([]mail.BulkResult, error) {
+	address := owner + "@" + domain
+	results := make([]mail.BulkResult, 0, len(bulk.Ids))
+	err := gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		changed := make([]string, 0)
+		for _, id := range bulk.Ids {
+			var m struct {
+				Sender             string `gorm:"column:sender"`
+				Recipient          string `gorm:"column:recipient"`
+				DeletedBySender    bool   `gorm:"column:deleted_by_sender"`
+				DeletedByRecipient bool   `gorm:"column:deleted_by_recipient"`
 			}
-		}
-		return mail.Folder{}, err
-	}
-	return folder, nil
-}
-
-func (gmr *GormPostgresMailRepository) GetFolders(ownerId int) ([]mail.Folder, error) {
+			found := tx.Table("mails").
+				Select("sender, recipient, deleted_by_sender, deleted_by_recipient").
+				Where("id=?", id).
+				Limit(1).
+				Scan(&m)
+			if err := found.Error; err != nil {
+				return err
+			}
+			// the side of the mail the owner is on, the sender side is taken for mails to self
+			side, other, deleted := "sender", m.Recipient, m.DeletedBySender
+			if m.Sender != address {
+				side, other, deleted = "recipient", m.Sender, m.DeletedByRecipient
+			}
+			result := mail.BulkResult{Id: id, Other: other}
+			if found.RowsAffected == 0 || (m.Sender != address && m.Recipient != address) ||
+				(deleted && bulk.Action != mail.BulkRestore) {
+				result.Error = "mail doesn't exist"
+				results = append(results, result)
+				continue
+			}
+
+			var err error
+			switch bulk.Action {
+			case mail.BulkRead, mail.BulkUnread:
+				// only the recipient has the mail unread
+				if side == "recipient" {
+					err = tx.Table("mails").Where("id=?", id).Update("unread", bulk.Action == mail.BulkUnread).Error
+				} else if bulk.Action == mail.BulkUnread {
+					result.Error = "sent mail can't be unread"
+				}
+			case mail.BulkStar, mail.BulkUnstar:
+				err = tx.Table("mails").Where("id=?", id).Update("starred_by_"+side, bulk.Action == mail.BulkStar).Error
+			case mail.BulkLabel:
+				err = tx.Exec(
+					"INSERT INTO mail_labels (mail_id, owner, label) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+					id,
+					address,
+					bulk.Label,
+				).Error
+			case mail.BulkDelete, mail.BulkRestore:
+				err = tx.Table("mails").Where("id=?", id).Update("deleted_by_"+side, bulk.Action == mail.BulkDelete).Error
+			default:
+				return mail.InvalidEmailError{"action isn't supported for mails"}
+			}
+			if err != nil {
+				return err
+			}
+			result.Ok = result.Error == ""
+			results = append(results, result)
+			if result.Ok && bulk.Action != mail.BulkStar && bulk.Action != mail.BulkUnstar && bulk.Action != mail.BulkLabel {
+				changed = append(changed, other)
+			}
+		}
+
+		refreshed := make(map[string]bool)
+		for _, other := range changed {
+			if refreshed[other] {
+				continue
+			}
+			refreshed[other] = true
+			err := refreshDialogue(tx, owner, address, other, bulk.Action == mail.BulkRestore)
+			if err != nil {
+				return err
+			}
+		}
+
+		ids := make([]int, 0, len(results))
+		read := make(map[string]bool)
+		for _, result := range results {
+			if !result.Ok {
+				continue
+			}
+			ids = append(ids, result.Id)
+			if bulk.Action == mail.BulkRead && !read[result.Other] {
+				read[result.Other] = true
+				err := publish(tx, mail.Event{Type: mail.EventRead, Owner: owner, Dialogue: result.Other})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if bulk.Action == mail.BulkDelete && len(ids) != 0 {
+			return publish(tx, mail.Event{Type: mail.EventDeleted, Owner: owner, MailIds: ids})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// BulkDialogues applies the action to dialogues of the owner and their mails in one transaction,
+// dialogues of other users are reported as missing
+func (gmr *GormPostgresMailRepository) BulkDialogues(owner string, domain string, bulk mail.Bulk) ([]mail.BulkResult, error) {
+	address := owner + "@" + domain
+	results := make([]mail.BulkResult, 0, len(bulk.Ids))
+	err := gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		for _, id := range bulk.Ids {
+			var dialogue struct {
+				Other string `gorm:"column:other"`
+			}
+			found := tx.Table("dialogues").
+				Select("other").
+				Where("id=? AND owner=?", id, owner).
+				Limit(1).
+				Scan(&dialogue)
+			if err := found.Error; err != nil {
+				return err
+			}
+			result := mail.BulkResult{Id: id, Other: dialogue.Other}
+			if found.RowsAffected == 0 {
+				result.Error = "dialogue doesn't exist"
+				results = append(results, result)
+				continue
+			}
+			other := dialogue.Other
+			dialogueMails := gmr.DBInstance.DB.Where(
+				"sender=? AND recipient=? AND deleted_by_sender=FALSE",
+				address,
+				other,
+			).Or(
+				"sender=? AND recipient=? AND deleted_by_recipient=FALSE",
+				other,
+				address,
+			)
+
+			var err error
+			switch bulk.Action {
+			case mail.BulkRead:
+				err = tx.Table("mails").
+					Where("sender=? AND recipient=? AND unread=TRUE", other, address).
+					Update("unread", false).Error
+				if err == nil {
+					err = tx.Table("dialogues").Where("id=?", id).Update("unread", 0).Error
+				}
+			case mail.BulkUnread:
+				// the last received mail becomes unread
+				marked := tx.Exec(
+					"UPDATE mails SET unread=TRUE WHERE id=(SELECT id FROM mails "+
+						"WHERE sender=? AND recipient=? AND deleted_by_recipient=FALSE "+
+						"ORDER BY received_date DESC, id DESC LIMIT 1)",
+					other,
+					address,
+				)
+				err = marked.Error
+				if err == nil && marked.RowsAffected == 0 {
+					result.Error = "dialogue has no received mails"
+				} else if err == nil {
+					err = refreshDialogue(tx, owner, address, other, false)
+				}
+			case mail.BulkMove:
+				var folder interface{}
+				if bulk.Folder != 0 {
+					folder = bulk.Folder
+				}
+				err = tx.Table("dialogues").Where("id=?", id).Update("folder", folder).Error
+				if pgerr, ok := err.(*pgconn.PgError); ok && pgerr.ConstraintName == "dialogues_folder_fkey" {
+					return mail.InvalidEmailError{"Folder doesn't exists"}
+				}
+			case mail.BulkLabel:
+				err = tx.Exec(
+					"INSERT INTO mail_labels (mail_id, owner, label) "+
+						"SELECT id, ?, ? FROM mails WHERE (sender=? AND recipient=? AND deleted_by_sender=FALSE) "+
+						"OR (sender=? AND recipient=? AND deleted_by_recipient=FALSE) ON CONFLICT DO NOTHING",
+					address,
+					bulk.Label,
+					address,
+					other,
+					other,
+					address,
+				).Error
+			case mail.BulkStar:
+				var last struct {
+					Id     int    `gorm:"column:id"`
+					Sender string `gorm:"column:sender"`
+				}
+				lastResult := tx.Table("mails").
+					Select("id, sender").
+					Where(dialogueMails).
+					Order("received_date DESC, id DESC").
+					Limit(1).
+					Scan(&last)
+				err = lastResult.Error
+				if err == nil && lastResult.RowsAffected == 0 {
+					result.Error = "dialogue has no mails"
+				} else if err == nil {
+					column := "starred_by_recipient"
+					if last.Sender == address {
+						column = "starred_by_sender"
+					}
+					err = tx.Table("mails").Where("id=?", last.Id).Update(column, true).Error
+				}
+			case mail.BulkUnstar:
+				err = tx.Table("mails").
+					Where("sender=? AND recipient=?", address, other).
+					Update("starred_by_sender", false).Error
+				if err == nil {
+					err = tx.Table("mails").
+						Where("sender=? AND recipient=?", other, address).
+						Update("starred_by_recipient", false).Error
+				}
+			case mail.BulkArchive, mail.BulkUnarchive:
+				err = tx.Table("dialogues").Where("id=?", id).Update("archived", bulk.Action == mail.BulkArchive).Error
+			case mail.BulkDelete:
+				err = tx.Exec("DELETE FROM dialogues WHERE id=?", id).Error
+				if err == nil {
+					err = tx.Table("mails").
+						Where("sender=? AND recipient=?", address, other).
+						Update("deleted_by_sender", true).Error
+				}
+				if err == nil {
+					err = tx.Table("mails").
+						Where("sender=? AND recipient=?", other, address).
+						Update("deleted_by_recipient", true).Error
+				}
+			default:
+				return mail.InvalidEmailError{"action isn't supported for dialogues"}
+			}
+			if err != nil {
+				return err
+			}
+			result.Ok = result.Error == ""
+			results = append(results, result)
+		}
+
+		for _, result := range results {
+			if !result.Ok {
+				continue
+			}
+			var err error
+			switch bulk.Action {
+			case mail.BulkRead:
+				err = publish(tx, mail.Event{Type: mail.EventRead, Owner: owner, Dialogue: result.Other})
+			case mail.BulkDelete:
+				err = publish(tx, mail.Event{Type: mail.EventDeleted, Owner: owner, Dialogue: result.Other})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if bulk.Action == mail.BulkMove {
+			return publish(tx, mail.Event{Type: mail.EventFolder, Owner: owner, FolderId: bulk.Folder})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// refreshDialogue recalculates the last mail and unread counter of the dialogue in the transaction,
+// create brings back the dialogue deleted with its mails
+func refreshDialogue(tx *gorm.DB, owner string, address string, other string, create bool) error {
+	if create {
+		err := tx.Exec(
+			"INSERT INTO dialogues (owner, other) VALUES (?, ?) ON CONFLICT (owner, other) DO NOTHING",
+			owner,
+			other,
+		).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Exec(
+		"WITH last AS (SELECT id, received_date, body FROM mails "+
+			"WHERE (sender=? AND recipient=? AND deleted_by_sender=FALSE) "+
+			"OR (sender=? AND recipient=? AND deleted_by_recipient=FALSE) "+
+			"ORDER BY received_date DESC, id DESC LIMIT 1) "+
+			"UPDATE dialogues SET last_mail_id=(SELECT id FROM last), received_date=(SELECT received_date FROM last), "+
+			"body=(SELECT body FROM last), unread=(SELECT COUNT(*) FROM mails "+
+			"WHERE sender=? AND recipient=? AND deleted_by_recipient=FALSE AND unread=TRUE) "+
+			"WHERE owner=? AND other=?",
+		address,
+		other,
+		other,
+		address,
+		other,
+		address,
+		owner,
+		other,
+	).Error
+}
+
+func (gmr *GormPostgresMailRepository) CreateFolder(ownerId int, folderName string, parentId int) (mail.Folder, error) {
+	folder := mail.Folder {
+		FolderName: folderName,
+		Owner: ownerId,
+		Parent: parentId,
+	}
+	columns := []string{"folder_name", "owner"}
+	if parentId != 0 {
+		columns = append(columns, "parent")
+	}
+	err := gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("folders").Select(columns).Create(&folder).Error
+		if err != nil {
+			return err
+		}
+		return publish(tx, mail.Event{Type: mail.EventFolder, OwnerId: ownerId, FolderId: folder.Id})
+	})
+	if err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "folders_owner_fkey" {
+				return mail.Folder{}, common.InvalidUserError{"user doesn't exist"}
+			} else if pgerr.ConstraintName == "folders_folder_name_owner_key" {
+				return mail.Folder{}, mail.InvalidEmailError{"folder already exists"}
+			}
+		}
+		return mail.Folder{}, err
+	}
+	return folder, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetFolders(ownerId int) ([]mail.Folder, error) {
 	folders := make([]mail.Folder, 0)
+	// subtree lists every folder of the owner with each of its ancestors as root
 	err := gmr.DBInstance.DB.Raw(
-		"SELECT folders.id, folders.folder_name, folders.owner, COUNT(CASE WHEN dialogues.unread > 0 THEN 1 END) unread "+
+		"WITH RECURSIVE subtree AS ("+
+			"SELECT id, id root FROM folders WHERE owner=? "+
+			"UNION SELECT folders.id, subtree.root FROM folders JOIN subtree ON folders.parent=subtree.id"+
+			") "+
+			"SELECT folders.id, folders.folder_name, folders.owner, COALESCE(folders.parent, 0) parent, folders.position, "+
+			"COUNT(CASE WHEN dialogues.unread > 0 THEN 1 END) unread "+
 			"FROM folders " +
-			"LEFT JOIN dialogues ON dialogues.folder=folders.id "+
+			"JOIN subtree ON subtree.root=folders.id "+
+			"LEFT JOIN dialogues ON dialogues.folder=subtree.id "+
 			"WHERE folders.owner=? "+
-			"GROUP BY folders.id",
+			"GROUP BY folders.id "+
+			"ORDER BY folders.position, folders.id",
+			ownerId,
 			ownerId,
 		).
 		Scan(&folders).Error
@@ -429,16 +1136,22 @@ func (gmr *GormPostgresMailRepository) AddDialogueToFolder(owner string, folderI
 	if folderId == 0 {
 		updates["folder"] = nil
 	}
-	result := gmr.DBInstance.DB.
-		Table("dialogues").
-		Where("id=? AND owner=?", dialogueId, owner).
-		Select("folder").
-		Updates(updates)
-	if err := result.Error; err != nil {	if pgerr, ok := err.(*pgconn.PgError); ok {
-		if pgerr.ConstraintName == "dialogues_folder_fkey" {
-			return mail.InvalidEmailError{"Folder doesn't exists"}
+	err := gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("dialogues").
+			Where("id=? AND owner=?", dialogueId, owner).
+			Select("folder").
+			Updates(updates).Error
+		if err != nil {
+			return err
+		}
+		return publish(tx, mail.Event{Type: mail.EventFolder, Owner: owner, FolderId: folderId})
+	})
+	if err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "dialogues_folder_fkey" {
+				return mail.InvalidEmailError{"Folder doesn't exists"}
+			}
 		}
-	}
 		return err
 	}
 	return nil
@@ -450,19 +1163,86 @@ func (gmr *GormPostgresMailRepository) UpdateFolderName(owner, folderId int, fol
 		FolderName: folderName,
 		Owner: owner,
 	}
-	result := gmr.DBInstance.DB.Table("folders").
-		Where("id=? AND owner=?", folderId, owner).
-		Update("folder_name", folderName)
-	if err := result.Error; err != nil {
+	err := gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("folders").
+			Where("id=? AND owner=?", folderId, owner).
+			Update("folder_name", folderName).Error
+		if err != nil {
+			return err
+		}
+		return publish(tx, mail.Event{Type: mail.EventFolder, OwnerId: owner, FolderId: folderId})
+	})
+	if err != nil {
 		return mail.Folder{}, err
 	}
 	return folder, nil
 }
 
+// MoveFolder puts the folder into the parent, folders of the owner are locked while the parent is checked
+// not to be in the moved subtree, so concurrent moves can't make a cycle
+func (gmr *GormPostgresMailRepository) MoveFolder(owner, folderId int, parentId int, position int) error {
+	updates := map[string]interface{}{"parent": parentId, "position": position}
+	if parentId == 0 {
+		updates["parent"] = nil
+	}
+	return gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("SELECT id FROM folders WHERE owner=? FOR UPDATE", owner).Error
+		if err != nil {
+			return err
+		}
+		if parentId != 0 {
+			var subtree struct {
+				Cycle bool `gorm:"column:cycle"`
+			}
+			err = tx.Raw(
+				"WITH RECURSIVE subtree AS ("+
+					"SELECT id FROM folders WHERE id=? AND owner=? "+
+					"UNION SELECT folders.id FROM folders JOIN subtree ON folders.parent=subtree.id"+
+					") "+
+					"SELECT EXISTS (SELECT 1 FROM subtree WHERE id=?) cycle",
+				folderId,
+				owner,
+				parentId,
+			).Scan(&subtree).Error
+			if err != nil {
+				return err
+			}
+			if subtree.Cycle {
+				return mail.InvalidEmailError{"folder can't be moved into itself or its subfolder"}
+			}
+		}
+
+		result := tx.Table("folders").
+			Where("id=? AND owner=?", folderId, owner).
+			Updates(updates)
+		if err := result.Error; err != nil {
+			if pgerr, ok := err.(*pgconn.PgError); ok {
+				if pgerr.ConstraintName == "folders_parent_fkey" {
+					return mail.InvalidEmailError{"parent folder doesn't exist"}
+				} else if pgerr.ConstraintName == "folders_folder_name_owner_key" {
+					return mail.InvalidEmailError{"folder already exists"}
+				}
+			}
+			return err
+		}
+		if result.RowsAffected == 0 {
+			return mail.InvalidEmailError{"folder doesn't exist"}
+		}
+		return publish(tx, mail.Event{Type: mail.EventFolder, OwnerId: owner, FolderId: folderId})
+	})
+}
+
+// ShiftToMainFolderDialogues moves dialogues of the folder and all its subfolders out of them
 func (gmr *GormPostgresMailRepository) ShiftToMainFolderDialogues(owner string, folderId int) error {
-	result := gmr.DBInstance.DB.Table("dialogues").
-		Where("owner=? AND folder=?", owner, folderId).
-		Update("folder", nil)
+	result := gmr.DBInstance.DB.Exec(
+		"WITH RECURSIVE subtree AS ("+
+			"SELECT id FROM folders WHERE id=? "+
+			"UNION SELECT folders.id FROM folders JOIN subtree ON folders.parent=subtree.id"+
+			") "+
+			"UPDATE dialogues SET folder=NULL WHERE owner=? AND folder IN (SELECT id FROM subtree)",
+		folderId,
+		owner,
+	)
 	if err := result.Error; err != nil {
 		return err
 	}
@@ -470,12 +1250,1803 @@ func (gmr *GormPostgresMailRepository) ShiftToMainFolderDialogues(owner string,
 }
 
 func (gmr *GormPostgresMailRepository) DeleteFolder(owner, folderId int) error {
-	gmr.DBInstance.DB.
-		Table("folders").
-		Where("id=? AND owner=?", folderId, owner).
-		Delete(&mail.Folder{})
-	if err := gmr.DBInstance.DB.Error; err != nil || gmr.DBInstance.DB.RowsAffected == 0{
+	return gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Table("folders").
+			Where("id=? AND owner=?", folderId, owner).
+			Delete(&mail.Folder{})
+		if err := result.Error; err != nil {
+			return err
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		return publish(tx, mail.Event{Type: mail.EventFolder, OwnerId: owner, FolderId: folderId})
+	})
+}
+
+// mailboxJoin joins mails with the dialogues of the owner they belong to and weren't deleted from,
+// mails are sent from and to any of the owner addresses
+const mailboxJoin = "JOIN dialogues ON dialogues.owner=? AND (" +
+	"(mails.sender IN ? AND mails.recipient=dialogues.other AND mails.deleted_by_sender=FALSE) OR " +
+	"(mails.recipient IN ? AND mails.sender=dialogues.other AND mails.deleted_by_recipient=FALSE))"
+
+// mailboxQuery selects mails of the mailbox matching the filter, without paging and thread collapsing
+func (gmr *GormPostgresMailRepository) mailboxQuery(owner string, addresses []string, filter mail.MailFilter) *gorm.DB {
+	query := gmr.DBInstance.DB.
+		Table("mails").
+		Joins(mailboxJoin, owner, addresses, addresses)
+	if len(filter.Ids) > 0 {
+		query = query.Where("mails.id IN ?", filter.Ids)
+	}
+	switch {
+	case len(filter.DialogueIds) > 0 && len(filter.FolderIds) > 0:
+		query = query.Where("(dialogues.id IN ? OR dialogues.folder IN ?)", filter.DialogueIds, filter.FolderIds)
+	case len(filter.DialogueIds) > 0:
+		query = query.Where("dialogues.id IN ?", filter.DialogueIds)
+	case len(filter.FolderIds) > 0:
+		query = query.Where("dialogues.folder IN ?", filter.FolderIds)
+	}
+	if filter.FolderId != nil {
+		if *filter.FolderId == 0 {
+			query = query.Where("dialogues.folder IS NULL")
+		} else {
+			query = query.Where("dialogues.folder=?", *filter.FolderId)
+		}
+	}
+	if filter.AfterId > 0 {
+		query = query.Where("mails.id>?", filter.AfterId)
+	}
+	return query
+}
+
+func (gmr *GormPostgresMailRepository) GetMailboxEmails(owner string, addresses []string, filter mail.MailFilter) ([]mail.MailboxEmail, error) {
+	emails := make([]mail.MailboxEmail, 0)
+	query := gmr.mailboxQuery(owner, addresses, filter).
+		Select(
+			"mails.id, mails.sender, mails.recipient, mails.subject, mails.received_date, mails.body, mails.unread, "+
+				"mails.status, mails.sender_alias, mails.list_address, mails.message_id, mails.message_references, "+
+				"(SELECT string_agg(label, ',') FROM mail_labels WHERE mail_labels.mail_id=mails.id AND mail_labels.owner IN ?) labels, "+
+				"dialogues.id dialogue_id, COALESCE(dialogues.folder, 0) folder",
+			addresses,
+		)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+	if filter.OrderById {
+		query = query.Order("mails.id")
+	} else {
+		query = query.Order("mails.received_date desc, mails.id desc")
+	}
+	err := query.Scan(&emails).Error
+	if err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+// GetMailboxEmailIds selects only ids of the mails, the latest received first
+func (gmr *GormPostgresMailRepository) GetMailboxEmailIds(owner string, addresses []string, filter mail.MailFilter) ([]int, error) {
+	ids := make([]int, 0)
+	var query *gorm.DB
+	if filter.CollapseThreads {
+		latest := gmr.mailboxQuery(owner, addresses, filter).
+			Select("DISTINCT ON (dialogues.id) mails.id, mails.received_date").
+			Order("dialogues.id, mails.received_date desc, mails.id desc")
+		query = gmr.DBInstance.DB.
+			Table("(?) latest", latest).
+			Select("id").
+			Order("received_date desc, id desc")
+	} else {
+		query = gmr.mailboxQuery(owner, addresses, filter).
+			Select("mails.id").
+			Order("mails.received_date desc, mails.id desc")
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+	err := query.Scan(&ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// CountMailboxEmails counts mails matching the filter, or their dialogues if threads are collapsed
+func (gmr *GormPostgresMailRepository) CountMailboxEmails(owner string, addresses []string, filter mail.MailFilter) (int, error) {
+	var count int64
+	query := gmr.mailboxQuery(owner, addresses, filter)
+	if filter.CollapseThreads {
+		query = query.Distinct("dialogues.id")
+	}
+	err := query.Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+func (gmr *GormPostgresMailRepository) GetMailboxCounters(owner string, addresses []string) ([]mail.MailboxCounters, error) {
+	counters := make([]mail.MailboxCounters, 0)
+	err := gmr.DBInstance.DB.
+		Table("dialogues").
+		Select(
+			"COALESCE(dialogues.folder, 0) folder, "+
+				"COUNT(DISTINCT dialogues.id) threads, "+
+				"COUNT(DISTINCT CASE WHEN dialogues.unread > 0 THEN dialogues.id END) unread_threads, "+
+				"COUNT(mails.id) emails, "+
+				"COUNT(CASE WHEN mails.unread AND mails.recipient IN ? THEN 1 END) unread_emails",
+			addresses,
+		).
+		Joins(
+			"LEFT JOIN mails ON "+
+				"(mails.sender IN ? AND mails.recipient=dialogues.other AND mails.deleted_by_sender=FALSE) OR "+
+				"(mails.recipient IN ? AND mails.sender=dialogues.other AND mails.deleted_by_recipient=FALSE)",
+			addresses,
+			addresses,
+		).
+		Where("dialogues.owner=?", owner).
+		Group("COALESCE(dialogues.folder, 0)").
+		Scan(&counters).Error
+	if err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
+
+// GetMailChanges returns mails of the address changed after since ordered by modseq,
+// mail deleted by the address is returned deleted though the other side may still have it
+func (gmr *GormPostgresMailRepository) GetMailChanges(address string, since int64, limit int) ([]mail.SyncChange, error) {
+	changes := make([]mail.SyncChange, 0)
+	err := gmr.DBInstance.DB.
+		Table("mails").
+		Select(
+			"id, modseq, created_modseq, "+
+				"CASE WHEN sender=? THEN deleted_by_sender ELSE deleted_by_recipient END deleted",
+			address,
+		).
+		Where("(sender=? OR recipient=?) AND modseq>?", address, address, since).
+		Order("modseq").
+		Limit(limit).
+		Scan(&changes).Error
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetDialogueChanges(owner string, since int64, limit int) ([]mail.SyncChange, error) {
+	changes := make([]mail.SyncChange, 0)
+	err := gmr.DBInstance.DB.Raw(
+		"SELECT id, modseq, created_modseq, FALSE deleted FROM dialogues WHERE owner=? AND modseq>? "+
+			"UNION ALL "+
+			"SELECT object_id id, modseq, 0 created_modseq, TRUE deleted FROM sync_tombstones "+
+			"WHERE kind='dialogue' AND owner=? AND modseq>? "+
+			"ORDER BY modseq LIMIT ?",
+		owner, since, owner, since, limit,
+	).Scan(&changes).Error
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetFolderChanges(ownerId int, since int64, limit int) ([]mail.SyncChange, error) {
+	changes := make([]mail.SyncChange, 0)
+	err := gmr.DBInstance.DB.Raw(
+		"SELECT id, modseq, created_modseq, FALSE deleted FROM folders WHERE owner=? AND modseq>? "+
+			"UNION ALL "+
+			"SELECT object_id id, modseq, 0 created_modseq, TRUE deleted FROM sync_tombstones "+
+			"WHERE kind='folder' AND owner_id=? AND modseq>? "+
+			"ORDER BY modseq LIMIT ?",
+		ownerId, since, ownerId, since, limit,
+	).Scan(&changes).Error
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// GetModseq returns the last modseq of the mailbox, it grows with every change of its mails, dialogues or folders
+func (gmr *GormPostgresMailRepository) GetModseq(owner string, address string, ownerId int) (int64, error) {
+	var modseq struct {
+		Modseq int64 `gorm:"column:modseq"`
+	}
+	err := gmr.DBInstance.DB.Raw(
+		"SELECT COALESCE(GREATEST("+
+			"(SELECT MAX(modseq) FROM mails WHERE sender=? OR recipient=?), "+
+			"(SELECT MAX(modseq) FROM dialogues WHERE owner=?), "+
+			"(SELECT MAX(modseq) FROM folders WHERE owner=?), "+
+			"(SELECT MAX(modseq) FROM sync_tombstones WHERE owner=? OR owner_id=?)"+
+			"), 0) modseq",
+		address, address, owner, ownerId, owner, ownerId,
+	).Scan(&modseq).Error
+	if err != nil {
+		return 0, err
+	}
+	return modseq.Modseq, nil
+}
+
+// GetModseqLimit returns modseq the changes are committed up to: transactions taking greater modseqs may still
+// be running and commit changes with lower ones. It must be read before the changes
+func (gmr *GormPostgresMailRepository) GetModseqLimit() (int64, error) {
+	var modseq struct {
+		Modseq int64 `gorm:"column:modseq"`
+	}
+	// running transactions hold shared advisory lock on the last modseq taken before their first one
+	err := gmr.DBInstance.DB.Raw(
+		"SELECT LEAST(" +
+			"(SELECT last_value FROM modseq), " +
+			"(SELECT MIN((classid::bigint << 32) | objid::bigint) FROM pg_locks " +
+			"WHERE locktype='advisory' AND objsubid=1 AND database=(SELECT oid FROM pg_database WHERE datname=current_database()))" +
+			") modseq",
+	).Scan(&modseq).Error
+	if err != nil {
+		return 0, err
+	}
+	return modseq.Modseq, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetVacation(owner string) (mail.Vacation, error) {
+	vacation := mail.Vacation{
+		Owner:         owner,
+		ReplyInterval: mail.DefaultVacationReplyInterval,
+	}
+	err := gmr.DBInstance.DB.
+		Table("vacations").
+		Where("owner=?", owner).
+		Take(&vacation).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return mail.Vacation{}, err
+	}
+	return vacation, nil
+}
+
+func (gmr *GormPostgresMailRepository) UpdateVacation(vacation mail.Vacation) (mail.Vacation, error) {
+	result := gmr.DBInstance.DB.
+		Table("vacations").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "owner"}},
+			UpdateAll: true,
+		}).
+		Create(&vacation)
+	if err := result.Error; err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "vacations_owner_fkey" {
+				return mail.Vacation{}, common.InvalidUserError{"user doesn't exist"}
+			}
+		}
+		return mail.Vacation{}, err
+	}
+	return vacation, nil
+}
+
+func (gmr *GormPostgresMailRepository) VacationReplied(owner string, sender string, interval time.Duration) (bool, error) {
+	timeLimit := time.Now().Add(-interval)
+	var count int64
+	err := gmr.DBInstance.DB.
+		Table("vacation_replies").
+		Where(
+			"owner=? AND sender=? AND replied_date>?",
+			owner,
+			sender,
+			timeLimit,
+		).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (gmr *GormPostgresMailRepository) SaveVacationReply(owner string, sender string) error {
+	err := gmr.DBInstance.DB.Exec(
+		"INSERT INTO vacation_replies (owner, sender, replied_date) VALUES (?, ?, NOW()) "+
+			"ON CONFLICT (owner, sender) DO UPDATE SET replied_date=NOW()",
+		owner,
+		sender,
+	).Error
+	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func (gmr *GormPostgresMailRepository) IsContact(ownerEmail string, other string) (bool, error) {
+	var count int64
+	err := gmr.DBInstance.DB.
+		Table("mails").
+		Where("sender=? AND recipient=?", ownerEmail, other).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CollectContact adds the address to the owner's address book unless some contact already has it
+func (gmr *GormPostgresMailRepository) CollectContact(owner string, address string) error {
+	return gmr.DBInstance.DB.Exec(
+		"WITH contact AS ("+
+			"INSERT INTO contacts (owner, uid, auto_collected) "+
+			"SELECT ?, md5(random()::text || clock_timestamp()::text), TRUE "+
+			"WHERE NOT EXISTS (SELECT 1 FROM contact_emails JOIN contacts ON contacts.id=contact_emails.contact_id WHERE contacts.owner=? AND contact_emails.email=?) "+
+			"RETURNING id"+
+			") INSERT INTO contact_emails (contact_id, email) SELECT id, ? FROM contact",
+		owner,
+		owner,
+		address,
+		address,
+	).Error
+}
+
+func (gmr *GormPostgresMailRepository) GetForwarding(owner string) (mail.Forwarding, error) {
+	forwarding := mail.Forwarding{
+		Owner:    owner,
+		KeepCopy: true,
+	}
+	err := gmr.DBInstance.DB.
+		Table("forwardings").
+		Where("owner=?", owner).
+		Take(&forwarding).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return mail.Forwarding{}, err
+	}
+	return forwarding, nil
+}
+
+func (gmr *GormPostgresMailRepository) UpdateForwarding(forwarding mail.Forwarding) (mail.Forwarding, error) {
+	result := gmr.DBInstance.DB.
+		Table("forwardings").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "owner"}},
+			UpdateAll: true,
+		}).
+		Create(&forwarding)
+	if err := result.Error; err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "forwardings_owner_fkey" {
+				return mail.Forwarding{}, common.InvalidUserError{"user doesn't exist"}
+			}
+		}
+		return mail.Forwarding{}, err
+	}
+	return forwarding, nil
+}
+
+func (gmr *GormPostgresMailRepository) ConfirmForwarding(token string) error {
+	result := gmr.DBInstance.DB.
+		Table("forwardings").
+		Where("token=?", token).
+		Updates(map[string]interface{}{
+			"verified": true,
+			"token":    nil,
+		})
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"invalid confirmation token"}
+	}
+	return nil
+}
+
+func (gmr *GormPostgresMailRepository) GetAliases(owner string) ([]mail.Alias, error) {
+	aliases := make([]mail.Alias, 0)
+	err := gmr.DBInstance.DB.
+		Table("aliases").
+		Where("owner=?", owner).
+		Order("id").
+		Find(&aliases).Error
+	if err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+func (gmr *GormPostgresMailRepository) CreateAlias(owner string, alias string) (mail.Alias, error) {
+	newAlias := mail.Alias{
+		Owner: owner,
+		Alias: alias,
+	}
+	result := gmr.DBInstance.DB.
+		Table("aliases").
+		Select("owner", "alias").
+		Create(&newAlias)
+	if err := result.Error; err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "aliases_owner_fkey" {
+				return mail.Alias{}, common.InvalidUserError{"user doesn't exist"}
+			} else if pgerr.ConstraintName == "aliases_alias_key" {
+				return mail.Alias{}, mail.InvalidEmailError{"address is already taken"}
+			}
+		}
+		return mail.Alias{}, err
+	}
+	return newAlias, nil
+}
+
+func (gmr *GormPostgresMailRepository) DeleteAlias(owner string, aliasId int) error {
+	result := gmr.DBInstance.DB.
+		Table("aliases").
+		Where("id=? AND owner=?", aliasId, owner).
+		Delete(&mail.Alias{})
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"Alias doesn't exist"}
+	}
+	return nil
+}
+
+// GetAddressOwner returns username of the user owning local part in the domain as username or alias
+func (gmr *GormPostgresMailRepository) GetAddressOwner(localPart string, domain string) (string, error) {
+	var owner string
+	err := gmr.DBInstance.DB.Raw(
+		"SELECT username FROM users WHERE username=? AND domain=? "+
+			"UNION ALL "+
+			"SELECT aliases.owner FROM aliases JOIN users ON users.username=aliases.owner "+
+			"WHERE aliases.alias=? AND users.domain=? "+
+			"LIMIT 1",
+		localPart,
+		domain,
+		localPart,
+		domain,
+	).
+		Scan(&owner).Error
+	if err != nil {
+		return "", err
+	}
+	if owner == "" {
+		return "", common.InvalidUserError{"user doesn't exist"}
+	}
+	return owner, nil
+}
+
+// GetOwnerAddresses returns the address of the user, of its aliases and on domains it catches all mail of
+func (gmr *GormPostgresMailRepository) GetOwnerAddresses(owner string) ([]string, error) {
+	addresses := make([]string, 0)
+	err := gmr.DBInstance.DB.Raw(
+		"SELECT username || '@' || domain FROM users WHERE username=? "+
+			"UNION "+
+			"SELECT aliases.alias || '@' || users.domain FROM aliases JOIN users ON users.username=aliases.owner "+
+			"WHERE aliases.owner=? "+
+			"UNION "+
+			"SELECT CAST(? AS TEXT) || '@' || name FROM domains WHERE catch_all=?",
+		owner,
+		owner,
+		owner,
+		owner,
+	).
+		Scan(&addresses).Error
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetDomains() ([]mail.Domain, error) {
+	domains := make([]mail.Domain, 0)
+	err := gmr.DBInstance.DB.
+		Table("domains").
+		Order("id").
+		Find(&domains).Error
+	if err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// AddDefaultDomain creates the domain if it doesn't exist, users created before domains are moved to it
+func (gmr *GormPostgresMailRepository) AddDefaultDomain(name string) error {
+	return gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("INSERT INTO domains (name) VALUES (?) ON CONFLICT DO NOTHING", name).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE users SET domain=? WHERE domain IS NULL", name).Error
+	})
+}
+
+func (gmr *GormPostgresMailRepository) GetDomain(name string) (mail.Domain, error) {
+	var domain mail.Domain
+	err := gmr.DBInstance.DB.
+		Table("domains").
+		Where("name=?", name).
+		Take(&domain).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return mail.Domain{}, mail.InvalidEmailError{"domain doesn't exist"}
+		}
+		return mail.Domain{}, err
+	}
+	return domain, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetUserDomain(username string) (mail.Domain, error) {
+	var domain mail.Domain
+	err := gmr.DBInstance.DB.
+		Table("domains").
+		Select("domains.*").
+		Joins("JOIN users ON users.domain=domains.name").
+		Where("users.username=?", username).
+		Take(&domain).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return mail.Domain{}, common.InvalidUserError{"user doesn't exist"}
+		}
+		return mail.Domain{}, err
+	}
+	return domain, nil
+}
+
+func (gmr *GormPostgresMailRepository) AddMailLabel(mailId int, owner string, label string) error {
+	err := gmr.DBInstance.DB.Exec(
+		"INSERT INTO mail_labels (mail_id, owner, label) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+		mailId,
+		owner,
+		label,
+	).Error
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (gmr *GormPostgresMailRepository) CreateMailbox(owner string, name string, domain string) (mail.MailboxMember, error) {
+	member := mail.MailboxMember{
+		Mailbox: name,
+		Member:  owner,
+		IsAdmin: true,
+	}
+	err := gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		// shared mailbox can't be logged in, "!" is never a valid password hash
+		err := tx.Exec(
+			"INSERT INTO users (username, password_hash, fullname, domain, is_shared) VALUES (?, '!', ?, ?, TRUE)",
+			name,
+			name,
+			domain,
+		).Error
+		if err != nil {
+			return err
+		}
+		return tx.Table("mailbox_members").Create(&member).Error
+	})
+	if err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "users_username_key" {
+				return mail.MailboxMember{}, mail.InvalidEmailError{"address is taken"}
+			}
+		}
+		return mail.MailboxMember{}, err
+	}
+	return member, nil
+}
+
+// GetMailboxes returns shared mailboxes the user is member of
+func (gmr *GormPostgresMailRepository) GetMailboxes(member string) ([]mail.MailboxMember, error) {
+	mailboxes := make([]mail.MailboxMember, 0)
+	err := gmr.DBInstance.DB.
+		Table("mailbox_members").
+		Where("member=?", member).
+		Order("mailbox").
+		Find(&mailboxes).Error
+	if err != nil {
+		return nil, err
+	}
+	return mailboxes, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetMailboxMembers(mailbox string) ([]mail.MailboxMember, error) {
+	members := make([]mail.MailboxMember, 0)
+	err := gmr.DBInstance.DB.
+		Table("mailbox_members").
+		Where("mailbox=?", mailbox).
+		Order("member").
+		Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetMailboxMember(mailbox string, member string) (mail.MailboxMember, error) {
+	var mailboxMember mail.MailboxMember
+	err := gmr.DBInstance.DB.
+		Table("mailbox_members").
+		Where("mailbox=? AND member=?", mailbox, member).
+		Take(&mailboxMember).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return mail.MailboxMember{}, mail.AccessDeniedError{"not a member of the mailbox"}
+		}
+		return mail.MailboxMember{}, err
+	}
+	return mailboxMember, nil
+}
+
+func (gmr *GormPostgresMailRepository) AddMailboxMember(member mail.MailboxMember) error {
+	err := gmr.DBInstance.DB.
+		Table("mailbox_members").
+		Create(&member).Error
+	if err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "mailbox_members_member_fkey" {
+				return common.InvalidUserError{"user doesn't exist"}
+			} else if pgerr.ConstraintName == "mailbox_members_mailbox_member_key" {
+				return mail.InvalidEmailError{"user is already a member"}
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (gmr *GormPostgresMailRepository) DeleteMailboxMember(mailbox string, member string) error {
+	return gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		// members are locked, so two admins can't leave the mailbox without an admin at once
+		members := make([]mail.MailboxMember, 0)
+		err := tx.Raw("SELECT mailbox, member, is_admin FROM mailbox_members WHERE mailbox=? FOR UPDATE", mailbox).
+			Scan(&members).Error
+		if err != nil {
+			return err
+		}
+		var removed *mail.MailboxMember
+		admins := 0
+		for i := range members {
+			if members[i].IsAdmin {
+				admins++
+			}
+			if members[i].Member == member {
+				removed = &members[i]
+			}
+		}
+		if removed == nil {
+			return mail.InvalidEmailError{"user is not a member"}
+		}
+		if removed.IsAdmin && admins == 1 {
+			return mail.InvalidEmailError{"the last admin can't leave the mailbox"}
+		}
+
+		return tx.Table("mailbox_members").
+			Where("mailbox=? AND member=?", mailbox, member).
+			Delete(&mail.MailboxMember{}).Error
+	})
+}
+
+const distributionListColumns = "distribution_lists.id, distribution_lists.name, distribution_lists.domain, distribution_lists.owner, distribution_lists.posting, " +
+	"(SELECT string_agg(address, ',') FROM distribution_list_members WHERE list_id=distribution_lists.id) members"
+
+func (gmr *GormPostgresMailRepository) GetDistributionLists(owner string) ([]mail.DistributionList, error) {
+	lists := make([]mail.DistributionList, 0)
+	err := gmr.DBInstance.DB.
+		Table("distribution_lists").
+		Select(distributionListColumns).
+		Where("owner=?", owner).
+		Order("id").
+		Scan(&lists).Error
+	if err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetDistributionList(name string, domain string) (mail.DistributionList, error) {
+	var list mail.DistributionList
+	result := gmr.DBInstance.DB.
+		Table("distribution_lists").
+		Select(distributionListColumns).
+		Where("name=? AND domain=?", name, domain).
+		Limit(1).
+		Scan(&list)
+	if err := result.Error; err != nil {
+		return mail.DistributionList{}, err
+	}
+	if result.RowsAffected == 0 {
+		return mail.DistributionList{}, mail.InvalidEmailError{"list doesn't exist"}
+	}
+	return list, nil
+}
+
+func (gmr *GormPostgresMailRepository) CreateDistributionList(list mail.DistributionList) (mail.DistributionList, error) {
+	err := gmr.DBInstance.DB.
+		Table("distribution_lists").
+		Select("name", "domain", "owner").
+		Create(&list).Error
+	if err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "distribution_lists_name_key" {
+				return mail.DistributionList{}, mail.InvalidEmailError{"address is taken"}
+			}
+		}
+		return mail.DistributionList{}, err
+	}
+	list.Members = common.StringList{}
+	return list, nil
+}
+
+func (gmr *GormPostgresMailRepository) DeleteDistributionList(owner string, listId int) error {
+	result := gmr.DBInstance.DB.
+		Table("distribution_lists").
+		Where("id=? AND owner=?", listId, owner).
+		Delete(&mail.DistributionList{})
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"list doesn't exist"}
+	}
+	return nil
+}
+
+func (gmr *GormPostgresMailRepository) UpdateListPosting(owner string, listId int, posting string) error {
+	result := gmr.DBInstance.DB.
+		Table("distribution_lists").
+		Where("id=? AND owner=?", listId, owner).
+		Update("posting", posting)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"list doesn't exist"}
+	}
+	return nil
+}
+
+func (gmr *GormPostgresMailRepository) AddListMember(owner string, listId int, address string) error {
+	result := gmr.DBInstance.DB.Exec(
+		"INSERT INTO distribution_list_members (list_id, address) "+
+			"SELECT id, ? FROM distribution_lists WHERE id=? AND owner=? "+
+			"ON CONFLICT DO NOTHING",
+		address,
+		listId,
+		owner,
+	)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"list doesn't exist or address is already a member"}
+	}
+	return nil
+}
+
+func (gmr *GormPostgresMailRepository) DeleteListMember(owner string, listId int, address string) error {
+	result := gmr.DBInstance.DB.Exec(
+		"DELETE FROM distribution_list_members USING distribution_lists "+
+			"WHERE distribution_list_members.list_id=distribution_lists.id "+
+			"AND distribution_lists.id=? AND distribution_lists.owner=? AND distribution_list_members.address=?",
+		listId,
+		owner,
+		address,
+	)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"address is not a member of the list"}
+	}
+	return nil
+}
+
+// ownerCondition selects rules of the user or global rules if owner is empty
+func ownerCondition(db *gorm.DB, owner string) *gorm.DB {
+	if owner == "" {
+		return db.Where("owner IS NULL")
+	}
+	return db.Where("owner=?", owner)
+}
+
+func (gmr *GormPostgresMailRepository) GetSenderRules(owner string) ([]mail.SenderRule, error) {
+	rules := make([]mail.SenderRule, 0)
+	err := ownerCondition(gmr.DBInstance.DB.Table("sender_rules"), owner).
+		Order("pattern").
+		Scan(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// FindSenderRules returns rules of the user and global rules having one of the patterns
+func (gmr *GormPostgresMailRepository) FindSenderRules(owner string, patterns []string) ([]mail.SenderRule, error) {
+	rules := make([]mail.SenderRule, 0)
+	err := gmr.DBInstance.DB.
+		Table("sender_rules").
+		Where("owner=? OR owner IS NULL", owner).
+		Where("pattern IN ?", patterns).
+		Scan(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (gmr *GormPostgresMailRepository) AddSenderRule(rule mail.SenderRule) (mail.SenderRule, error) {
+	err := gmr.DBInstance.DB.
+		Table("sender_rules").
+		Select("owner", "pattern", "allow").
+		Create(&rule).Error
+	if err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "sender_rules_owner_pattern_idx" {
+				return mail.SenderRule{}, mail.InvalidEmailError{"rule for the sender already exists"}
+			}
+		}
+		return mail.SenderRule{}, err
+	}
+	return rule, nil
+}
+
+func (gmr *GormPostgresMailRepository) DeleteSenderRule(owner string, ruleId int) error {
+	result := ownerCondition(gmr.DBInstance.DB.Table("sender_rules"), owner).
+		Where("id=?", ruleId).
+		Delete(&mail.SenderRule{})
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"rule doesn't exist"}
+	}
+	return nil
+}
+
+func (gmr *GormPostgresMailRepository) GetDropBlocked(owner string) (bool, error) {
+	var drop struct {
+		DropBlocked bool `gorm:"column:drop_blocked"`
+	}
+	result := gmr.DBInstance.DB.
+		Table("users").
+		Select("drop_blocked").
+		Where("username=?", owner).
+		Limit(1).
+		Scan(&drop)
+	if err := result.Error; err != nil {
+		return false, err
+	}
+	if result.RowsAffected == 0 {
+		return false, common.InvalidUserError{"user doesn't exist"}
+	}
+	return drop.DropBlocked, nil
+}
+
+func (gmr *GormPostgresMailRepository) UpdateDropBlocked(owner string, drop bool) error {
+	return gmr.DBInstance.DB.
+		Table("users").
+		Where("username=?", owner).
+		Update("drop_blocked", drop).Error
+}
+
+const signatureColumns = "signatures.id, signatures.owner, signatures.name, signatures.body, " +
+	"(SELECT string_agg(alias, ',' ORDER BY alias) FROM signature_defaults WHERE signature_defaults.signature_id=signatures.id) default_for"
+
+func (gmr *GormPostgresMailRepository) GetSignatures(owner string) ([]mail.Signature, error) {
+	signatures := make([]mail.Signature, 0)
+	err := gmr.DBInstance.DB.
+		Table("signatures").
+		Select(signatureColumns).
+		Where("owner=?", owner).
+		Order("id").
+		Scan(&signatures).Error
+	if err != nil {
+		return nil, err
+	}
+	return signatures, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetSignature(owner string, signatureId int) (mail.Signature, error) {
+	var signature mail.Signature
+	result := gmr.DBInstance.DB.
+		Table("signatures").
+		Select(signatureColumns).
+		Where("owner=? AND id=?", owner, signatureId).
+		Limit(1).
+		Scan(&signature)
+	if err := result.Error; err != nil {
+		return mail.Signature{}, err
+	}
+	if result.RowsAffected == 0 {
+		return mail.Signature{}, mail.InvalidEmailError{"signature doesn't exist"}
+	}
+	return signature, nil
+}
+
+// GetDefaultSignature returns empty signature if the address has no default one
+func (gmr *GormPostgresMailRepository) GetDefaultSignature(owner string, alias string) (mail.Signature, error) {
+	var signature mail.Signature
+	err := gmr.DBInstance.DB.
+		Table("signatures").
+		Select(signatureColumns).
+		Joins("JOIN signature_defaults ON signature_defaults.signature_id=signatures.id").
+		Where("signature_defaults.owner=? AND signature_defaults.alias=?", owner, alias).
+		Limit(1).
+		Scan(&signature).Error
+	if err != nil {
+		return mail.Signature{}, err
+	}
+	return signature, nil
+}
+
+// setSignatureDefaults makes the signature default for the listed addresses, replacing their previous defaults
+func setSignatureDefaults(tx *gorm.DB, signature mail.Signature) error {
+	err := tx.Exec("DELETE FROM signature_defaults WHERE signature_id=?", signature.Id).Error
+	if err != nil {
+		return err
+	}
+	for _, alias := range signature.DefaultFor {
+		err = tx.Exec(
+			"INSERT INTO signature_defaults (owner, alias, signature_id) VALUES (?, ?, ?) "+
+				"ON CONFLICT (owner, alias) DO UPDATE SET signature_id=EXCLUDED.signature_id",
+			signature.Owner,
+			alias,
+			signature.Id,
+		).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gmr *GormPostgresMailRepository) CreateSignature(signature mail.Signature) (mail.Signature, error) {
+	err := gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(
+			"INSERT INTO signatures (owner, name, body) VALUES (?, ?, ?) RETURNING id",
+			signature.Owner,
+			signature.Name,
+			signature.Body,
+		).Scan(&signature.Id).Error
+		if err != nil {
+			return err
+		}
+		return setSignatureDefaults(tx, signature)
+	})
+	if err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "signatures_owner_fkey" {
+				return mail.Signature{}, common.InvalidUserError{"user doesn't exist"}
+			}
+		}
+		return mail.Signature{}, err
+	}
+	return signature, nil
+}
+
+func (gmr *GormPostgresMailRepository) UpdateSignature(signature mail.Signature) (mail.Signature, error) {
+	err := gmr.DBInstance.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec(
+			"UPDATE signatures SET name=?, body=? WHERE id=? AND owner=?",
+			signature.Name,
+			signature.Body,
+			signature.Id,
+			signature.Owner,
+		)
+		if err := result.Error; err != nil {
+			return err
+		}
+		if result.RowsAffected == 0 {
+			return mail.InvalidEmailError{"signature doesn't exist"}
+		}
+		return setSignatureDefaults(tx, signature)
+	})
+	if err != nil {
+		return mail.Signature{}, err
+	}
+	return signature, nil
+}
+
+func (gmr *GormPostgresMailRepository) DeleteSignature(owner string, signatureId int) error {
+	result := gmr.DBInstance.DB.Exec("DELETE FROM signatures WHERE owner=? AND id=?", owner, signatureId)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"signature doesn't exist"}
+	}
+	return nil
+}
+
+func (gmr *GormPostgresMailRepository) GetTemplates(owner string) ([]mail.Template, error) {
+	templates := make([]mail.Template, 0)
+	err := gmr.DBInstance.DB.
+		Table("templates").
+		Where("owner=?", owner).
+		Order("name").
+		Find(&templates).Error
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetTemplate(owner string, templateId int) (mail.Template, error) {
+	var template mail.Template
+	err := gmr.DBInstance.DB.
+		Table("templates").
+		Where("owner=? AND id=?", owner, templateId).
+		Take(&template).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return mail.Template{}, mail.InvalidEmailError{"template doesn't exist"}
+		}
+		return mail.Template{}, err
+	}
+	return template, nil
+}
+
+func (gmr *GormPostgresMailRepository) CreateTemplate(template mail.Template) (mail.Template, error) {
+	err := gmr.DBInstance.DB.
+		Table("templates").
+		Select("owner", "name", "subject", "body").
+		Create(&template).Error
+	if err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "templates_owner_fkey" {
+				return mail.Template{}, common.InvalidUserError{"user doesn't exist"}
+			}
+		}
+		return mail.Template{}, err
+	}
+	return template, nil
+}
+
+func (gmr *GormPostgresMailRepository) UpdateTemplate(template mail.Template) (mail.Template, error) {
+	result := gmr.DBInstance.DB.Exec(
+		"UPDATE templates SET name=?, subject=?, body=? WHERE id=? AND owner=?",
+		template.Name,
+		template.Subject,
+		template.Body,
+		template.Id,
+		template.Owner,
+	)
+	if err := result.Error; err != nil {
+		return mail.Template{}, err
+	}
+	if result.RowsAffected == 0 {
+		return mail.Template{}, mail.InvalidEmailError{"template doesn't exist"}
+	}
+	return template, nil
+}
+
+func (gmr *GormPostgresMailRepository) DeleteTemplate(owner string, templateId int) error {
+	result := gmr.DBInstance.DB.Exec("DELETE FROM templates WHERE owner=? AND id=?", owner, templateId)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"template doesn't exist"}
+	}
+	return nil
+}
+
+func (gmr *GormPostgresMailRepository) GetFullName(username string) (string, error) {
+	var user struct {
+		FullName string `gorm:"column:fullname"`
+	}
+	result := gmr.DBInstance.DB.
+		Table("users").
+		Select("fullname").
+		Where("username=?", username).
+		Limit(1).
+		Scan(&user)
+	if err := result.Error; err != nil {
+		return "", err
+	}
+	if result.RowsAffected == 0 {
+		return "", common.InvalidUserError{"user doesn't exist"}
+	}
+	return user.FullName, nil
+}
+
+// GetContactName returns name of the address in the owner's address book, empty if there is none
+func (gmr *GormPostgresMailRepository) GetContactName(owner string, address string) (string, error) {
+	names := make([]string, 0, 1)
+	err := gmr.DBInstance.DB.
+		Table("contacts").
+		Joins("JOIN contact_emails ON contact_emails.contact_id=contacts.id").
+		Where("contacts.owner=? AND contact_emails.email=? AND contacts.name<>''", owner, address).
+		Order("contacts.auto_collected, contacts.id").
+		Limit(1).
+		Pluck("contacts.name", &names).Error
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	return names[0], nil
+}
+
+func (gmr *GormPostgresMailRepository) GetSmartFolders(owner string) ([]mail.SmartFolder, error) {
+	folders := make([]mail.SmartFolder, 0)
+	err := gmr.DBInstance.DB.
+		Table("smart_folders").
+		Where("owner=?", owner).
+		Order("name").
+		Find(&folders).Error
+	if err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetSmartFolder(owner string, folderId int) (mail.SmartFolder, error) {
+	var folder mail.SmartFolder
+	err := gmr.DBInstance.DB.
+		Table("smart_folders").
+		Where("owner=? AND id=?", owner, folderId).
+		Take(&folder).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return mail.SmartFolder{}, mail.InvalidEmailError{"smart folder doesn't exist"}
+		}
+		return mail.SmartFolder{}, err
+	}
+	return folder, nil
+}
+
+func (gmr *GormPostgresMailRepository) CreateSmartFolder(folder mail.SmartFolder) (mail.SmartFolder, error) {
+	err := gmr.DBInstance.DB.
+		Table("smart_folders").
+		Select("owner", "name", "sender_domain", "unread_only", "received_after", "received_before", "label", "has_attachment").
+		Create(&folder).Error
+	if err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "smart_folders_owner_fkey" {
+				return mail.SmartFolder{}, common.InvalidUserError{"user doesn't exist"}
+			} else if pgerr.ConstraintName == "smart_folders_owner_name_key" {
+				return mail.SmartFolder{}, mail.InvalidEmailError{"folder already exists"}
+			}
+		}
+		return mail.SmartFolder{}, err
+	}
+	return folder, nil
+}
+
+func (gmr *GormPostgresMailRepository) UpdateSmartFolder(folder mail.SmartFolder) (mail.SmartFolder, error) {
+	result := gmr.DBInstance.DB.Exec(
+		"UPDATE smart_folders SET name=?, sender_domain=?, unread_only=?, received_after=?, received_before=?, label=?, has_attachment=? "+
+			"WHERE id=? AND owner=?",
+		folder.Name,
+		folder.SenderDomain,
+		folder.Unread,
+		folder.After,
+		folder.Before,
+		folder.Label,
+		folder.HasAttachment,
+		folder.Id,
+		folder.Owner,
+	)
+	if err := result.Error; err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok && pgerr.ConstraintName == "smart_folders_owner_name_key" {
+			return mail.SmartFolder{}, mail.InvalidEmailError{"folder already exists"}
+		}
+		return mail.SmartFolder{}, err
+	}
+	if result.RowsAffected == 0 {
+		return mail.SmartFolder{}, mail.InvalidEmailError{"smart folder doesn't exist"}
+	}
+	return folder, nil
+}
+
+func (gmr *GormPostgresMailRepository) DeleteSmartFolder(owner string, folderId int) error {
+	result := gmr.DBInstance.DB.Exec("DELETE FROM smart_folders WHERE owner=? AND id=?", owner, folderId)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"smart folder doesn't exist"}
+	}
+	return nil
+}
+
+// smartQueryCondition builds condition on dialogues of the owner's address for the set fields of the query
+func smartQueryCondition(address string, query mail.SmartQuery) (string, []interface{}) {
+	mailConds := []string{
+		"((mails.sender=? AND mails.recipient=dialogues.other AND mails.deleted_by_sender=FALSE) OR " +
+			"(mails.sender=dialogues.other AND mails.recipient=? AND mails.deleted_by_recipient=FALSE))",
+	}
+	args := []interface{}{address, address}
+	if query.SenderDomain != "" {
+		mailConds = append(mailConds, "mails.sender ILIKE ?")
+		args = append(args, "%@"+query.SenderDomain)
+	}
+	if query.After != nil {
+		mailConds = append(mailConds, "mails.received_date>=?")
+		args = append(args, *query.After)
+	}
+	if query.Before != nil {
+		mailConds = append(mailConds, "mails.received_date<?")
+		args = append(args, *query.Before)
+	}
+	if query.Label != "" {
+		mailConds = append(mailConds, "EXISTS (SELECT 1 FROM mail_labels WHERE mail_labels.mail_id=mails.id AND mail_labels.owner=? AND mail_labels.label=?)")
+		args = append(args, address, query.Label)
+	}
+	if query.HasAttachment {
+		mailConds = append(mailConds, "mails.has_attachment=TRUE")
+	}
+
+	condition := "EXISTS (SELECT 1 FROM mails WHERE " + strings.Join(mailConds, " AND ") + ")"
+	if query.Unread {
+		condition = "dialogues.unread>0 AND " + condition
+	}
+	return condition, args
+}
+
+// CountSmartFoldersUnread counts dialogues having unread mails of each smart folder in one query
+func (gmr *GormPostgresMailRepository) CountSmartFoldersUnread(owner string, address string, queries []mail.SmartQuery) ([]int, error) {
+	counts := make([]int, len(queries))
+	if len(queries) == 0 {
+		return counts, nil
+	}
+	columns := make([]string, 0, len(queries))
+	args := make([]interface{}, 0)
+	for _, query := range queries {
+		condition, conditionArgs := smartQueryCondition(address, query)
+		columns = append(columns, "COUNT(*) FILTER (WHERE "+condition+")")
+		args = append(args, conditionArgs...)
+	}
+	args = append(args, owner)
+	row := gmr.DBInstance.DB.Raw(
+		"SELECT "+strings.Join(columns, ", ")+" FROM dialogues "+
+			"WHERE dialogues.owner=? AND dialogues.snoozed_until IS NULL AND dialogues.unread>0",
+		args...,
+	).Row()
+	values := make([]interface{}, len(counts))
+	for i := range counts {
+		values[i] = &counts[i]
+	}
+	err := row.Scan(values...)
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetSmartFolderDialogues(owner string, address string, query mail.SmartQuery, page mail.Page) ([]mail.Dialogue, error) {
+	condition, args := smartQueryCondition(address, query)
+	dialogues := make([]mail.Dialogue, 0)
+	err := pageQuery(gmr.DBInstance.DB.Table("dialogues"), "dialogues.received_date", "dialogues.id", page).
+		Where("dialogues.owner=?", owner).
+		Where("dialogues.snoozed_until IS NULL").
+		Where(condition, args...).
+		Select(
+			"dialogues.id",
+			"dialogues.other",
+			"users.avatar_url",
+			"dialogues.body",
+			"dialogues.received_date",
+			"dialogues.unread",
+			"dialogues.pinned",
+			"dialogues.muted",
+			dialogueContactName,
+		).
+		Joins("LEFT JOIN users ON LOWER(dialogues.other)=LOWER(users.username || '@' || users.domain)").
+		Scan(&dialogues).Error
+
+	if err != nil {
+		return nil, err
+	}
+	return dialogues, nil
+}
+
+// publish notifies listeners of every server process about the event in the transaction of the change,
+// so the event is delivered only once the change is committed
+func publish(tx *gorm.DB, event mail.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		// clients resync on reconnect, so the change is kept without the event
+		log.Printf("ERROR: Unable to marshal %s event: %v\n", event.Type, err)
+		return nil
+	}
+	err = tx.Exec("SELECT pg_notify(?, ?)", mail.EventsChannel, string(payload)).Error
+	if err != nil {
+		log.Printf("ERROR: Unable to publish %s event: %v\n", event.Type, err)
+	}
+	return err
+}
+
+// inTransaction returns the repository making its queries in the transaction
+func (gmr *GormPostgresMailRepository) inTransaction(tx *gorm.DB) *GormPostgresMailRepository {
+	return &GormPostgresMailRepository{common.GormPostgresDataBase{tx}}
+}
+
+// ListenEvents holds a connection listening to the events channel until ctx is done or the connection fails
+func (gmr *GormPostgresMailRepository) ListenEvents(ctx context.Context, handle func(mail.Event)) error {
+	sqlDB, err := gmr.DBInstance.DB.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, "LISTEN "+mail.EventsChannel)
+	if err != nil {
+		return err
+	}
+	return conn.Raw(func(driverConn interface{}) error {
+		pgxConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("listening requires pgx connection")
+		}
+		for {
+			notification, err := pgxConn.Conn().WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			var event mail.Event
+			if json.Unmarshal([]byte(notification.Payload), &event) == nil {
+				handle(event)
+			}
+		}
+	})
+}
+
+func (gmr *GormPostgresMailRepository) GetWebhooks(owner string) ([]mail.Webhook, error) {
+	webhooks := make([]mail.Webhook, 0)
+	err := gmr.DBInstance.DB.
+		Table("webhooks").
+		Where("owner=?", owner).
+		Order("id").
+		Scan(&webhooks).Error
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetWebhook(owner string, webhookId int) (mail.Webhook, error) {
+	var webhook mail.Webhook
+	err := gmr.DBInstance.DB.
+		Table("webhooks").
+		Where("owner=? AND id=?", owner, webhookId).
+		Take(&webhook).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return mail.Webhook{}, mail.InvalidEmailError{"webhook doesn't exist"}
+		}
+		return mail.Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (gmr *GormPostgresMailRepository) CreateWebhook(webhook mail.Webhook) (mail.Webhook, error) {
+	err := gmr.DBInstance.DB.Raw(
+		"INSERT INTO webhooks (owner, url, events, secret) VALUES (?, ?, ?, ?) RETURNING id",
+		webhook.Owner,
+		webhook.URL,
+		strings.Join(webhook.Events, ","),
+		webhook.Secret,
+	).Scan(&webhook.Id).Error
+	if err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "webhooks_owner_fkey" {
+				return mail.Webhook{}, common.InvalidUserError{"user doesn't exist"}
+			}
+		}
+		return mail.Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (gmr *GormPostgresMailRepository) DeleteWebhook(owner string, webhookId int) error {
+	result := gmr.DBInstance.DB.Exec("DELETE FROM webhooks WHERE owner=? AND id=?", owner, webhookId)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"webhook doesn't exist"}
+	}
+	return nil
+}
+
+func (gmr *GormPostgresMailRepository) AddWebhookDelivery(delivery mail.WebhookDelivery) error {
+	return gmr.DBInstance.DB.
+		Table("webhook_deliveries").
+		Select("webhook", "event", "attempt", "status_code", "error").
+		Create(&delivery).Error
+}
+
+// GetWebhookDeliveries returns the latest delivery attempts first
+func (gmr *GormPostgresMailRepository) GetWebhookDeliveries(webhookId int, limit int) ([]mail.WebhookDelivery, error) {
+	deliveries := make([]mail.WebhookDelivery, 0)
+	err := gmr.DBInstance.DB.
+		Table("webhook_deliveries").
+		Where("webhook=?", webhookId).
+		Order("delivery_date DESC, id DESC").
+		Limit(limit).
+		Scan(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (gmr *GormPostgresMailRepository) AddWebhookRetry(retry mail.WebhookRetry) error {
+	return gmr.DBInstance.DB.
+		Table("webhook_retries").
+		Select("webhook", "payload", "attempt", "next_attempt").
+		Create(&retry).Error
+}
+
+// TakeWebhookRetries returns retries which are due and postpones them by lease,
+// so other servers don't take them while they are delivered
+func (gmr *GormPostgresMailRepository) TakeWebhookRetries(lease time.Duration, limit int) ([]mail.WebhookRetry, error) {
+	retries := make([]mail.WebhookRetry, 0)
+	err := gmr.DBInstance.DB.Raw(
+		"UPDATE webhook_retries SET next_attempt=? "+
+			"WHERE id IN (SELECT id FROM webhook_retries WHERE next_attempt<=NOW() ORDER BY next_attempt LIMIT ? FOR UPDATE SKIP LOCKED) "+
+			"RETURNING id, webhook, payload, attempt, next_attempt",
+		time.Now().Add(lease),
+		limit,
+	).
+		Scan(&retries).Error
+	if err != nil {
+		return nil, err
+	}
+	return retries, nil
+}
+
+func (gmr *GormPostgresMailRepository) UpdateWebhookRetry(retry mail.WebhookRetry) error {
+	return gmr.DBInstance.DB.
+		Table("webhook_retries").
+		Where("id=?", retry.Id).
+		Updates(map[string]interface{}{"attempt": retry.Attempt, "next_attempt": retry.NextAttempt}).Error
+}
+
+func (gmr *GormPostgresMailRepository) DeleteWebhookRetry(retryId int) error {
+	return gmr.DBInstance.DB.Exec("DELETE FROM webhook_retries WHERE id=?", retryId).Error
+}
+
+func (gmr *GormPostgresMailRepository) CreateExport(export mail.Export) (mail.Export, error) {
+	var created struct {
+		Id          int       `gorm:"column:id"`
+		CreatedDate time.Time `gorm:"column:created_date"`
+	}
+	err := gmr.DBInstance.DB.Raw(
+		"INSERT INTO exports (owner, format, dialogues, folders, status, token) VALUES (?, ?, ?, ?, ?, ?) "+
+			"RETURNING id, created_date",
+		export.Owner,
+		export.Format,
+		export.Dialogues.String(),
+		export.Folders.String(),
+		export.Status,
+		export.Token,
+	).Scan(&created).Error
+	if err != nil {
+		// scan of raw query wraps the error
+		var pgerr *pgconn.PgError
+		if errors.As(err, &pgerr) {
+			if pgerr.ConstraintName == "exports_owner_fkey" {
+				return mail.Export{}, common.InvalidUserError{"user doesn't exist"}
+			} else if pgerr.ConstraintName == "exports_unfinished_owner_key" {
+				return mail.Export{}, mail.InvalidEmailError{"previous export isn't finished yet"}
+			}
+		}
+		return mail.Export{}, err
+	}
+	export.Id = created.Id
+	export.CreatedDate = created.CreatedDate
+	return export, nil
+}
+
+// GetExports returns the latest exports first
+func (gmr *GormPostgresMailRepository) GetExports(owner string) ([]mail.Export, error) {
+	exports := make([]mail.Export, 0)
+	err := gmr.DBInstance.DB.
+		Table("exports").
+		Where("owner=?", owner).
+		Order("created_date DESC, id DESC").
+		Scan(&exports).Error
+	if err != nil {
+		return nil, err
+	}
+	return exports, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetExport(owner string, exportId int) (mail.Export, error) {
+	var export mail.Export
+	err := gmr.DBInstance.DB.
+		Table("exports").
+		Where("owner=? AND id=?", owner, exportId).
+		Take(&export).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return mail.Export{}, mail.InvalidEmailError{"export doesn't exist"}
+		}
+		return mail.Export{}, err
+	}
+	return export, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetExportByToken(token string) (mail.Export, error) {
+	var export mail.Export
+	err := gmr.DBInstance.DB.
+		Table("exports").
+		Where("token=?", token).
+		Take(&export).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return mail.Export{}, mail.InvalidEmailError{"export doesn't exist"}
+		}
+		return mail.Export{}, err
+	}
+	return export, nil
+}
+
+// UpdateExportProgress saves status, progress, error and expiration of the export
+func (gmr *GormPostgresMailRepository) UpdateExportProgress(export mail.Export) error {
+	return gmr.DBInstance.DB.Exec(
+		"UPDATE exports SET status=?, total=?, processed=?, error=?, expires_date=? WHERE id=?",
+		export.Status,
+		export.Total,
+		export.Processed,
+		export.Error,
+		export.ExpiresDate,
+		export.Id,
+	).Error
+}
+
+// InterruptExports fails exports left unfinished by the stopped server and returns them to remove their archives
+func (gmr *GormPostgresMailRepository) InterruptExports() ([]mail.Export, error) {
+	exports := make([]mail.Export, 0)
+	err := gmr.DBInstance.DB.Raw(
+		"UPDATE exports SET status=?, error=? WHERE status IN (?, ?) RETURNING id, format, token",
+		mail.ExportFailed,
+		"export was interrupted",
+		mail.ExportPending,
+		mail.ExportRunning,
+	).Scan(&exports).Error
+	if err != nil {
+		return nil, err
+	}
+	return exports, nil
+}
+
+// ExpireExports marks finished exports expired by the time and returns them to remove their archives
+func (gmr *GormPostgresMailRepository) ExpireExports(now time.Time) ([]mail.Export, error) {
+	exports := make([]mail.Export, 0)
+	err := gmr.DBInstance.DB.Raw(
+		"UPDATE exports SET status=? WHERE status=? AND expires_date<=? RETURNING id, format, token",
+		mail.ExportExpired,
+		mail.ExportDone,
+		now,
+	).Scan(&exports).Error
+	if err != nil {
+		return nil, err
+	}
+	return exports, nil
+}
+
+// MailExists checks whether the mail from the sender to the recipient with the Message-ID is stored, even a deleted one
+func (gmr *GormPostgresMailRepository) MailExists(sender string, recipient string, messageId string) (bool, error) {
+	var count int64
+	err := gmr.DBInstance.DB.
+		Table("mails").
+		Where("message_id=? AND sender=? AND recipient=?", messageId, sender, recipient).
+		Limit(1).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count != 0, nil
+}
+
+// RebuildDialogue creates the dialogue if it doesn't exist and recalculates its last mail and unread counter,
+// unlike UpdateDialogueLastMail the last mail is the latest by date, as imported mails come in any order
+func (gmr *GormPostgresMailRepository) RebuildDialogue(owner string, other string, domain string) (int, error) {
+	var dialogue struct {
+		Id int `gorm:"column:id"`
+	}
+	err := gmr.DBInstance.DB.Raw(
+		"INSERT INTO dialogues (owner, other) VALUES (?, ?) "+
+			"ON CONFLICT (owner, other) DO UPDATE SET other=EXCLUDED.other RETURNING id",
+		owner,
+		other,
+	).Scan(&dialogue).Error
+	if err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "dialogues_owner_fkey" {
+				return 0, common.InvalidUserError{"username doesn't exist"}
+			}
+		}
+		return 0, err
+	}
+
+	address := owner + "@" + domain
+	dialogueMails := gmr.DBInstance.DB.Where(
+		"sender=? AND recipient=? AND deleted_by_sender=FALSE",
+		address,
+		other,
+	).Or(
+		"sender=? AND recipient=? AND deleted_by_recipient=FALSE",
+		other,
+		address,
+	)
+	var lastMail mail.DialogueEmail
+	err = gmr.DBInstance.DB.
+		Table("mails").
+		Select("id, received_date, body").
+		Where(dialogueMails).
+		Order("received_date DESC, id DESC").
+		Take(&lastMail).Error
+	updates := map[string]interface{}{
+		"last_mail_id":  nil,
+		"received_date": nil,
+		"body":          nil,
+	}
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, err
+		}
+	} else {
+		updates["last_mail_id"] = lastMail.Id
+		updates["received_date"] = lastMail.Received_date
+		updates["body"] = lastMail.Body
+	}
+	var unread int64
+	err = gmr.DBInstance.DB.
+		Table("mails").
+		Where("sender=? AND recipient=? AND deleted_by_recipient=FALSE AND unread=TRUE", other, address).
+		Count(&unread).Error
+	if err != nil {
+		return 0, err
+	}
+	updates["unread"] = unread
+
+	err = gmr.DBInstance.DB.
+		Table("dialogues").
+		Where("id=?", dialogue.Id).
+		Updates(updates).Error
+	if err != nil {
+		return 0, err
+	}
+	return dialogue.Id, nil
+}
+
+func (gmr *GormPostgresMailRepository) CreateImport(mailImport mail.Import) (mail.Import, error) {
+	var created struct {
+		Id          int       `gorm:"column:id"`
+		CreatedDate time.Time `gorm:"column:created_date"`
+	}
+	err := gmr.DBInstance.DB.Raw(
+		"INSERT INTO imports (owner, source, imap_host, imap_username, status, token) VALUES (?, ?, ?, ?, ?, ?) "+
+			"RETURNING id, created_date",
+		mailImport.Owner,
+		mailImport.Source,
+		mailImport.Host,
+		mailImport.Username,
+		mailImport.Status,
+		mailImport.Token,
+	).Scan(&created).Error
+	if err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "imports_owner_fkey" {
+				return mail.Import{}, common.InvalidUserError{"user doesn't exist"}
+			}
+		}
+		return mail.Import{}, err
+	}
+	mailImport.Id = created.Id
+	mailImport.CreatedDate = created.CreatedDate
+	return mailImport, nil
+}
+
+// GetImports returns the latest imports first
+func (gmr *GormPostgresMailRepository) GetImports(owner string) ([]mail.Import, error) {
+	imports := make([]mail.Import, 0)
+	err := gmr.DBInstance.DB.
+		Table("imports").
+		Where("owner=?", owner).
+		Order("created_date DESC, id DESC").
+		Scan(&imports).Error
+	if err != nil {
+		return nil, err
+	}
+	return imports, nil
+}
+
+func (gmr *GormPostgresMailRepository) GetImport(owner string, importId int) (mail.Import, error) {
+	var mailImport mail.Import
+	err := gmr.DBInstance.DB.
+		Table("imports").
+		Where("owner=? AND id=?", owner, importId).
+		Take(&mailImport).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return mail.Import{}, mail.InvalidEmailError{"import doesn't exist"}
+		}
+		return mail.Import{}, err
+	}
+	return mailImport, nil
+}
+
+// UpdateImportProgress saves status, counters, position and error of the import
+func (gmr *GormPostgresMailRepository) UpdateImportProgress(mailImport mail.Import) error {
+	return gmr.DBInstance.DB.Exec(
+		"UPDATE imports SET status=?, processed=?, imported=?, duplicates=?, failed=?, progress=?, error=? WHERE id=?",
+		mailImport.Status,
+		mailImport.Processed,
+		mailImport.Imported,
+		mailImport.Duplicates,
+		mailImport.Failed,
+		mailImport.Progress,
+		mailImport.Error,
+		mailImport.Id,
+	).Error
+}
+
+// InterruptImports fails imports left unfinished by the stopped server, so they can be resumed
+func (gmr *GormPostgresMailRepository) InterruptImports() error {
+	return gmr.DBInstance.DB.Exec(
+		"UPDATE imports SET status=?, error=? WHERE status IN (?, ?)",
+		mail.ExportFailed,
+		"import was interrupted",
+		mail.ExportPending,
+		mail.ExportRunning,
+	).Error
+}
+
+// SavePushSubscription replaces the subscription of the session
+func (gmr *GormPostgresMailRepository) SavePushSubscription(subscription mail.PushSubscription) error {
+	err := gmr.DBInstance.DB.Exec(
+		"INSERT INTO push_subscriptions (owner, session, endpoint, p256dh, auth) VALUES (?, ?, ?, ?, ?) "+
+			"ON CONFLICT (session) DO UPDATE SET owner=EXCLUDED.owner, endpoint=EXCLUDED.endpoint, "+
+			"p256dh=EXCLUDED.p256dh, auth=EXCLUDED.auth",
+		subscription.Owner,
+		subscription.Session,
+		subscription.Endpoint,
+		subscription.Keys.P256dh,
+		subscription.Keys.Auth,
+	).Error
+	if err != nil {
+		if pgerr, ok := err.(*pgconn.PgError); ok {
+			if pgerr.ConstraintName == "push_subscriptions_owner_fkey" ||
+				pgerr.ConstraintName == "push_subscriptions_session_fkey" {
+				return common.InvalidUserError{"session doesn't exist"}
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (gmr *GormPostgresMailRepository) DeletePushSubscription(session string) error {
+	result := gmr.DBInstance.DB.Exec("DELETE FROM push_subscriptions WHERE session=?", session)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return mail.InvalidEmailError{"push subscription doesn't exist"}
+	}
+	return nil
+}
+
+// DeletePushEndpoint removes subscriptions expired at the push service
+func (gmr *GormPostgresMailRepository) DeletePushEndpoint(endpoint string) error {
+	return gmr.DBInstance.DB.Exec("DELETE FROM push_subscriptions WHERE endpoint=?", endpoint).Error
+}
+
+// GetPushSubscriptions returns subscriptions of the owner to notify about mail from other,
+// there are none if their dialogue is muted
+func (gmr *GormPostgresMailRepository) GetPushSubscriptions(owner string, other string) ([]mail.PushSubscription, error) {
+	subscriptions := make([]mail.PushSubscription, 0)
+	err := gmr.DBInstance.DB.
+		Table("push_subscriptions").
+		Where("owner=? AND NOT EXISTS (SELECT 1 FROM dialogues WHERE owner=? AND other=? AND muted)", owner, owner, other).
+		Order("id").
+		Scan(&subscriptions).Error
+	if err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jackc/pgconn"
 	"github.com/stretchr/testify/require"
@@ -103,7 +104,6 @@ func (s *Suite) TestAddMail() {
 		).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).
 			AddRow(1))
-	s.mock.ExpectCommit()
 	s.mock.ExpectQuery(regexp.QuoteMeta(
 		`SELECT "id" FROM "dialogues" WHERE owner=$1 AND other=$2 LIMIT 1`)).
 		WithArgs(s.owner, s.other).
@@ -131,12 +131,14 @@ func (s *Suite) TestAddMail() {
 		s.dialogueEmail.Unread,
 		s.dialogueEmail.Status,
 		))
-
-	s.mock.ExpectBegin()
 	s.mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(1, 1))
+	// the event is published in the transaction of the mail
+	s.mock.ExpectExec("SELECT pg_notify").
+		WithArgs(mail.EventsChannel, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
 	s.mock.ExpectCommit()
 
-	id, err := s.gmr.AddMail(s.email, s.domain)
+	id, err := s.gmr.AddMail(s.email, []string{s.email.Sender})
 	require.NoError(s.T(), err)
 	require.Equal(s.T(), s.email.Id, id)
 
@@ -152,7 +154,7 @@ func (s *Suite) TestAddMail() {
 		).
 		WillReturnError(errors.New("Error"))
 	s.mock.ExpectRollback()
-	_, err = s.gmr.AddMail(s.email, s.domain)
+	_, err = s.gmr.AddMail(s.email, []string{s.email.Sender})
 	require.Error(s.T(), err)
 
 
@@ -167,15 +169,11 @@ func (s *Suite) TestAddMail() {
 		).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).
 			AddRow(1))
-	s.mock.ExpectCommit()
 	s.mock.ExpectQuery("SELECT").
 		WillReturnError(gorm.ErrRecordNotFound)
-
-	s.mock.ExpectBegin()
 	s.mock.ExpectQuery("INSERT INTO").
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).
 			AddRow(1))
-	s.mock.ExpectCommit()
 	s.mock.ExpectQuery("SELECT").
 		WillReturnRows(sqlmock.NewRows([]string{
 			"id",
@@ -195,34 +193,42 @@ func (s *Suite) TestAddMail() {
 			s.dialogueEmail.Status,
 		))
 
-	s.mock.ExpectBegin()
 	s.mock.ExpectExec("UPDATE").WillReturnError(gorm.ErrRecordNotFound)
 	s.mock.ExpectRollback()
 
 	newEmail := s.email
 	newEmail.Sender = s.email.Recipient
 	newEmail.Recipient = s.email.Sender
-	_, err = s.gmr.AddMail(newEmail, s.domain)
+	_, err = s.gmr.AddMail(newEmail, []string{newEmail.Recipient})
 	require.Error(s.T(), err)
 }
 
 func (s *Suite) TestGetMailsForUser() {
+	cursor := mail.Cursor{Date: time.Now(), Id: 5}
 	s.mock.ExpectQuery(regexp.QuoteMeta(
-		`SELECT "id", "sender", "subject", "received_date", "body", "unread", "status" FROM "dialogues"
-			WHERE ((sender=$1 AND recipient=$2 AND deleted_by_sender=FALSE) OR
-			(sender=$3 AND recipient=$4 AND deleted_by_recipient=FALSE)) AND
-			(id > $5) ORDER BY "id" DESC LIMIT $6`)).
+		`SELECT id, sender, recipient, subject, received_date, body, unread, status, sender_alias, list_address, `+
+			`CASE WHEN sender=$1 THEN read_date END read_date, `+
+			`CASE WHEN sender=$2 THEN starred_by_sender ELSE starred_by_recipient END starred, `+
+			`(SELECT string_agg(label, ',') FROM mail_labels WHERE mail_labels.mail_id=mails.id AND mail_labels.owner=$3) labels `+
+			`FROM "mails" WHERE (received_date, id) < ($4, $5) AND received_date IS NOT NULL AND `+
+			`((sender=$6 AND recipient=$7 AND deleted_by_sender=FALSE) OR `+
+			`(sender=$8 AND recipient=$9 AND deleted_by_recipient=FALSE)) `+
+			`ORDER BY received_date DESC, id DESC LIMIT 11`)).
 		WithArgs(
+			s.email.Sender,
+			s.email.Sender,
+			s.email.Sender,
+			cursor.Date,
+			cursor.Id,
 			s.email.Sender,
 			s.email.Recipient,
 			s.email.Recipient,
 			s.email.Sender,
-			0,
-			10,
 			).
 		WillReturnRows(sqlmock.NewRows([]string{
 			"id",
 			"sender",
+			"recipient",
 			"subject",
 			"received_date",
 			"body",
@@ -231,14 +237,18 @@ func (s *Suite) TestGetMailsForUser() {
 		}).AddRow(
 			s.dialogueEmail.Id,
 			s.dialogueEmail.Sender,
+			s.email.Recipient,
 			s.dialogueEmail.Subject,
 			s.dialogueEmail.Received_date,
 			s.dialogueEmail.Body,
 			s.dialogueEmail.Unread,
 			s.dialogueEmail.Status,
 		))
-	_, err := s.gmr.GetMailsForUser(s.email.Sender, s.email.Recipient, 10, 0)
+	mails, err := s.gmr.GetMailsForUser(s.email.Sender, s.email.Recipient, mail.Page{Cursor: &cursor, Limit: 10})
 	require.NoError(s.T(), err)
+	// unread mails are told apart by the recipient when the dialogue is read
+	require.Len(s.T(), mails, 1)
+	require.Equal(s.T(), s.email.Recipient, mails[0].Recipient)
 }
 
 func (s *Suite) TestReadMail() {
@@ -252,7 +262,7 @@ func (s *Suite) TestReadMail() {
 			).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	s.mock.ExpectCommit()
-	err := s.gmr.ReadMail(s.email.Sender, s.email.Recipient)
+	err := s.gmr.ReadMail([]string{s.email.Sender}, s.email.Recipient)
 	require.NoError(s.T(), err)
 }
 
@@ -301,9 +311,8 @@ func (s *Suite) TestDeleteMails() {
 			s.dialogueEmail.Unread,
 			s.dialogueEmail.Status,
 		))
-	s.mock.ExpectBegin()
 	s.mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(1, 1))
-	s.mock.ExpectCommit()
+	s.mock.ExpectExec("SELECT pg_notify").WillReturnResult(sqlmock.NewResult(0, 0))
 	s.mock.ExpectCommit()
 
 	err := s.gmr.DeleteMail(s.owner, []int{1}, s.domain)
@@ -415,8 +424,8 @@ func (s *Suite) TestUpdateDialogueLastMail() {
 }
 
 func (s *Suite) TestGetDialoguesInFolder() {
-	since := time.Now()
-	s.mock.ExpectQuery("SELECT").
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`WHERE dialogues.received_date IS NOT NULL AND dialogues.owner=$1 AND dialogues.folder IS NULL`)).
 		WillReturnRows(sqlmock.NewRows([]string{
 		"dialogues.id",
 		"dialogues.other",
@@ -424,6 +433,7 @@ func (s *Suite) TestGetDialoguesInFolder() {
 		"dialogues.body",
 		"dialogues.received_date",
 		"dialogues.unread",
+		"display_name",
 		}).AddRow(
 			s.dialogue.Id,
 			s.dialogue.Email,
@@ -431,14 +441,19 @@ func (s *Suite) TestGetDialoguesInFolder() {
 			s.dialogue.Body,
 			s.dialogue.Received_date,
 			s.dialogue.Unread,
+			"Other",
 	))
-	_, err := s.gmr.GetDialoguesInFolder(s.owner, 10, 0, s.domain, since)
+	dialogues, err := s.gmr.GetDialoguesInFolder(s.owner, 0, mail.Page{Limit: 10})
 	require.NoError(s.T(), err)
+	require.Equal(s.T(), "Other", dialogues[0].Name.String)
 }
 
 func (s *Suite) TestFindDialogues() {
-	since := time.Now()
-	s.mock.ExpectQuery("SELECT").
+	cursor := mail.Cursor{Date: time.Now(), Id: 3, Backward: true}
+	s.mock.ExpectQuery(
+		`WHERE \(dialogues.received_date, dialogues.id\) > \(\$1, \$2\) .*` +
+			`ORDER BY dialogues.received_date ASC, dialogues.id ASC LIMIT 11`).
+		WithArgs(cursor.Date, cursor.Id, s.owner, "%o%").
 		WillReturnRows(sqlmock.NewRows([]string{
 			"dialogues.id",
 			"dialogues.other",
@@ -454,7 +469,7 @@ func (s *Suite) TestFindDialogues() {
 			s.dialogue.Received_date,
 			s.dialogue.Unread,
 		))
-	_, err := s.gmr.FindDialogues(s.owner, "o", 10, s.domain, since)
+	_, err := s.gmr.FindDialogues(s.owner, "o", mail.Page{Cursor: &cursor, Limit: 10})
 	require.NoError(s.T(), err)
 
 }
@@ -470,6 +485,9 @@ func (s *Suite) TestReadDialogue() {
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	s.mock.ExpectCommit()
+	s.mock.ExpectExec(regexp.QuoteMeta("SELECT pg_notify($1, $2)")).
+		WithArgs(mail.EventsChannel, `{"type":"read","owner":"`+s.owner+`","dialogue":"`+s.other+`"}`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
 	err := s.gmr.ReadDialogue(s.owner, s.other)
 	require.NoError(s.T(), err)
 
@@ -503,19 +521,16 @@ func (s *Suite) TestDeleteDialogue() {
 		WithArgs(
 		s.dialogue.Id,
 		s.owner,
+		s.dialogue.Id,
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
-	s.mock.ExpectCommit()
-	s.mock.ExpectBegin()
 	s.mock.ExpectExec("UPDATE").
 		WillReturnResult(sqlmock.NewResult(1, 1))
-	s.mock.ExpectCommit()
-	s.mock.ExpectBegin()
 	s.mock.ExpectExec("UPDATE").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectExec("SELECT pg_notify").WillReturnResult(sqlmock.NewResult(0, 0))
 	s.mock.ExpectCommit()
 
-
 	err := s.gmr.DeleteDialogue(s.owner, s.dialogue.Id, s.domain)
 	require.NoError(s.T(), err)
 
@@ -561,8 +576,9 @@ func (s *Suite) TestCreateFolder() {
 		).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).
 			AddRow(1))
+	s.mock.ExpectExec("SELECT pg_notify").WillReturnResult(sqlmock.NewResult(0, 0))
 	s.mock.ExpectCommit()
-	f, err := s.gmr.CreateFolder(s.folder.Owner, s.folder.FolderName)
+	f, err := s.gmr.CreateFolder(s.folder.Owner, s.folder.FolderName, 0)
 	require.NoError(s.T(), err)
 	require.Equal(s.T(), 1, f.Id)
 
@@ -575,26 +591,38 @@ func (s *Suite) TestCreateFolder() {
 		).
 		WillReturnError(&pgconn.PgError{ConstraintName: "folders_owner_fkey"})
 	s.mock.ExpectRollback()
-	_, err = s.gmr.CreateFolder(s.folder.Owner, s.folder.FolderName)
+	_, err = s.gmr.CreateFolder(s.folder.Owner, s.folder.FolderName, 0)
 	require.Error(s.T(), common.InvalidUserError{"user doesn't exist"}, err)
 }
 
 func (s *Suite) TestGetFolders() {
-	s.mock.ExpectQuery("SELECT").
-		WithArgs(s.folder.Id).
+	s.mock.ExpectQuery("WITH RECURSIVE subtree .* SELECT").
+		WithArgs(s.folder.Owner, s.folder.Owner).
 		WillReturnRows(sqlmock.NewRows([]string{
 		"id",
 		"fodler_name",
 		"owner",
+		"parent",
+		"position",
 		"unread",
 	}).AddRow(
 		s.folder.Id,
 		s.folder.FolderName,
 		s.folder.Owner,
 		0,
+		0,
+		0,
+		).AddRow(
+		2,
+		"Subfolder",
+		s.folder.Owner,
+		s.folder.Id,
+		1,
+		0,
 		))
-   _, err := s.gmr.GetFolders(s.folder.Owner)
+	folders, err := s.gmr.GetFolders(s.folder.Owner)
 	require.NoError(s.T(), err)
+	require.Equal(s.T(), s.folder.Id, folders[1].Parent)
 }
 
 func (s *Suite) TestAddDialogueToFolder() {
@@ -608,6 +636,7 @@ func (s *Suite) TestAddDialogueToFolder() {
 			s.owner,
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectExec("SELECT pg_notify").WillReturnResult(sqlmock.NewResult(0, 0))
 	s.mock.ExpectCommit()
 	err := s.gmr.AddDialogueToFolder(s.owner, s.folder.Id, s.dialogue.Id)
 	require.NoError(s.T(), err)
@@ -624,6 +653,7 @@ func (s *Suite) TestUpdateFolderName() {
 			s.folder.Owner,
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectExec("SELECT pg_notify").WillReturnResult(sqlmock.NewResult(0, 0))
 	s.mock.ExpectCommit()
 	_, err := s.gmr.UpdateFolderName(s.folder.Owner, s.folder.Id, "New folder name")
 	require.NoError(s.T(), err)
@@ -633,11 +663,10 @@ func (s *Suite) TestShiftToMainFolderDialogues() {
 	s.mock.MatchExpectationsInOrder(false)
 	s.mock.ExpectCommit()
 	s.mock.ExpectBegin()
-	s.mock.ExpectExec("UPDATE").
+	s.mock.ExpectExec("WITH RECURSIVE subtree .* UPDATE dialogues SET folder=NULL").
 		WithArgs(
-			nil,
-			s.owner,
 			s.folder.Id,
+			s.owner,
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	s.mock.ExpectCommit()
@@ -645,6 +674,46 @@ func (s *Suite) TestShiftToMainFolderDialogues() {
 	require.NoError(s.T(), err)
 }
 
+func (s *Suite) TestMoveFolder() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("SELECT id FROM folders WHERE owner=$1 FOR UPDATE")).
+		WithArgs(s.folder.Owner).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	s.mock.ExpectQuery("WITH RECURSIVE subtree .* SELECT EXISTS").
+		WithArgs(s.folder.Id, s.folder.Owner, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"cycle"}).AddRow(false))
+	s.mock.ExpectExec("UPDATE \"folders\" SET \"parent\"=\\$1,\"position\"=\\$2").
+		WithArgs(2, 1, s.folder.Id, s.folder.Owner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+	s.mock.ExpectExec("SELECT pg_notify").WillReturnResult(sqlmock.NewResult(0, 0))
+	err := s.gmr.MoveFolder(s.folder.Owner, s.folder.Id, 2, 1)
+	require.NoError(s.T(), err)
+
+	// the parent moved into the subtree by a concurrent request is found under lock
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("SELECT id FROM folders WHERE owner=$1 FOR UPDATE")).
+		WithArgs(s.folder.Owner).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	s.mock.ExpectQuery("WITH RECURSIVE subtree .* SELECT EXISTS").
+		WithArgs(s.folder.Id, s.folder.Owner, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"cycle"}).AddRow(true))
+	s.mock.ExpectRollback()
+	err = s.gmr.MoveFolder(s.folder.Owner, s.folder.Id, 3, 0)
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("SELECT id FROM folders WHERE owner=$1 FOR UPDATE")).
+		WithArgs(s.folder.Owner).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	s.mock.ExpectExec("UPDATE \"folders\"").
+		WithArgs(nil, 0, s.folder.Id, s.folder.Owner).
+		WillReturnError(&pgconn.PgError{ConstraintName: "folders_folder_name_owner_key"})
+	s.mock.ExpectRollback()
+	err = s.gmr.MoveFolder(s.folder.Owner, s.folder.Id, 0, 0)
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
 func (s *Suite) TestDeleteFolder() {
 	s.mock.MatchExpectationsInOrder(false)
 	s.mock.ExpectBegin()
@@ -655,6 +724,946 @@ func (s *Suite) TestDeleteFolder() {
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	s.mock.ExpectCommit()
+	s.mock.ExpectExec(regexp.QuoteMeta("SELECT pg_notify($1, $2)")).
+		WithArgs(mail.EventsChannel, fmt.Sprintf(`{"type":"folder","ownerId":%d,"folder":%d}`, s.folder.Owner, s.folder.Id)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
 	err := s.gmr.DeleteFolder(s.folder.Owner, s.folder.Id)
 	require.NoError(s.T(), err)
 }
+
+func (s *Suite) TestGetVacation() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "vacations" WHERE owner=$1 LIMIT 1`)).
+		WithArgs(s.owner).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"owner",
+			"enabled",
+			"subject",
+			"body",
+			"reply_interval",
+		}).AddRow(
+			s.owner,
+			true,
+			"Away",
+			"Back soon",
+			3,
+		))
+	vacation, err := s.gmr.GetVacation(s.owner)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 3, vacation.ReplyInterval)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "vacations" WHERE owner=$1 LIMIT 1`)).
+		WithArgs(s.owner).
+		WillReturnError(gorm.ErrRecordNotFound)
+	vacation, err = s.gmr.GetVacation(s.owner)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), false, vacation.Enabled)
+	require.Equal(s.T(), mail.DefaultVacationReplyInterval, vacation.ReplyInterval)
+}
+
+func (s *Suite) TestVacationReplied() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT count(1) FROM "vacation_replies" WHERE owner=$1 AND sender=$2 AND replied_date>$3`)).
+		WithArgs(s.owner, s.other, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	replied, err := s.gmr.VacationReplied(s.owner, s.other, time.Hour)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), true, replied)
+}
+
+func (s *Suite) TestSaveVacationReply() {
+	s.mock.ExpectExec("INSERT INTO vacation_replies").
+		WithArgs(s.owner, s.other).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	err := s.gmr.SaveVacationReply(s.owner, s.other)
+	require.NoError(s.T(), err)
+}
+
+func (s *Suite) TestConfirmForwarding() {
+	s.mock.MatchExpectationsInOrder(false)
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec("UPDATE").
+		WithArgs(nil, true, "token").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectCommit()
+	err := s.gmr.ConfirmForwarding("token")
+	require.NoError(s.T(), err)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec("UPDATE").
+		WithArgs(nil, true, "expired").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectCommit()
+	err = s.gmr.ConfirmForwarding("expired")
+	require.Error(s.T(), err)
+}
+
+func (s *Suite) TestGetAddressOwner() {
+	s.mock.ExpectQuery("SELECT username FROM users").
+		WithArgs("support", s.domain, "support", s.domain).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alt"))
+	owner, err := s.gmr.GetAddressOwner("support", s.domain)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "alt", owner)
+
+	s.mock.ExpectQuery("SELECT username FROM users").
+		WithArgs("nobody", s.domain, "nobody", s.domain).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}))
+	_, err = s.gmr.GetAddressOwner("nobody", s.domain)
+	require.Error(s.T(), err)
+}
+
+func (s *Suite) TestGetDomain() {
+	s.mock.ExpectQuery("SELECT \\* FROM \"domains\"").
+		WithArgs(s.domain).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "dkim_selector", "dkim_private_key", "catch_all"}).
+			AddRow(1, s.domain, "wolf", nil, nil))
+	domain, err := s.gmr.GetDomain(s.domain)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "wolf", domain.DkimSelector)
+
+	s.mock.ExpectQuery("SELECT \\* FROM \"domains\"").
+		WithArgs("ya.ru").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	_, err = s.gmr.GetDomain("ya.ru")
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
+func (s *Suite) TestGetUserDomain() {
+	s.mock.ExpectQuery("SELECT domains.\\* FROM \"domains\" JOIN users").
+		WithArgs(s.owner).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "dkim_selector", "dkim_private_key", "catch_all"}).
+			AddRow(2, "lioteam.ru", "wolf", nil, "postbox"))
+	domain, err := s.gmr.GetUserDomain(s.owner)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "lioteam.ru", domain.Name)
+	require.Equal(s.T(), "postbox", domain.CatchAll.String)
+}
+
+func (s *Suite) TestAddDefaultDomain() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO domains (name) VALUES ($1) ON CONFLICT DO NOTHING")).
+		WithArgs(s.domain).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET domain=$1 WHERE domain IS NULL")).
+		WithArgs(s.domain).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectCommit()
+	err := s.gmr.AddDefaultDomain(s.domain)
+	require.NoError(s.T(), err)
+}
+
+func (s *Suite) TestGetDistributionList() {
+	s.mock.ExpectQuery("SELECT distribution_lists.id").
+		WithArgs("dev", s.domain).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "domain", "owner", "members"}).
+			AddRow(1, "dev", s.domain, s.owner, "alt@liokor.ru,friend@ya.ru"))
+	list, err := s.gmr.GetDistributionList("dev", s.domain)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), common.StringList{"alt@liokor.ru", "friend@ya.ru"}, list.Members)
+
+	s.mock.ExpectQuery("SELECT distribution_lists.id").
+		WithArgs("qa", s.domain).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "domain", "owner", "members"}))
+	_, err = s.gmr.GetDistributionList("qa", s.domain)
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
+func (s *Suite) TestCollectContact() {
+	s.mock.ExpectExec("WITH contact AS \\(INSERT INTO contacts").
+		WithArgs(s.owner, s.owner, s.other, s.other).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	err := s.gmr.CollectContact(s.owner, s.other)
+	require.NoError(s.T(), err)
+}
+
+func (s *Suite) TestUpdateListPosting() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "distribution_lists" SET "posting"=$1 WHERE id=$2 AND owner=$3`)).
+		WithArgs(mail.ListPostLocal, 1, s.owner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+	err := s.gmr.UpdateListPosting(s.owner, 1, mail.ListPostLocal)
+	require.NoError(s.T(), err)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "distribution_lists" SET "posting"=$1 WHERE id=$2 AND owner=$3`)).
+		WithArgs(mail.ListPostLocal, 2, s.owner).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectCommit()
+	err = s.gmr.UpdateListPosting(s.owner, 2, mail.ListPostLocal)
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
+func (s *Suite) TestAddListMember() {
+	s.mock.ExpectExec("INSERT INTO distribution_list_members").
+		WithArgs("friend@ya.ru", 1, s.owner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	err := s.gmr.AddListMember(s.owner, 1, "friend@ya.ru")
+	require.NoError(s.T(), err)
+
+	s.mock.ExpectExec("INSERT INTO distribution_list_members").
+		WithArgs("friend@ya.ru", 2, s.owner).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	err = s.gmr.AddListMember(s.owner, 2, "friend@ya.ru")
+	require.Error(s.T(), err)
+}
+
+func (s *Suite) TestFindSenderRules() {
+	s.mock.ExpectQuery("SELECT \\* FROM \"sender_rules\"").
+		WithArgs(s.owner, "spam@ya.ru", "ya.ru", "ru").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner", "pattern", "allow"}).
+			AddRow(1, s.owner, "ya.ru", false).
+			AddRow(2, nil, "ru", false))
+	rules, err := s.gmr.FindSenderRules(s.owner, []string{"spam@ya.ru", "ya.ru", "ru"})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), rules, 2)
+	require.False(s.T(), rules[1].Owner.Valid)
+}
+
+func (s *Suite) TestAddSenderRule() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery("INSERT INTO \"sender_rules\"").
+		WithArgs(nil, "spam.com", false).
+		WillReturnError(&pgconn.PgError{ConstraintName: "sender_rules_owner_pattern_idx"})
+	s.mock.ExpectRollback()
+	_, err := s.gmr.AddSenderRule(mail.SenderRule{Pattern: "spam.com"})
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
+func (s *Suite) TestDeleteSenderRule() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec("DELETE FROM \"sender_rules\" WHERE owner IS NULL AND id=\\$1").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectCommit()
+	err := s.gmr.DeleteSenderRule("", 1)
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
+func (s *Suite) TestGetMail() {
+	s.mock.ExpectQuery("SELECT id, sender, recipient").
+		WithArgs(7, "alt@liokor.ru", "alt@liokor.ru").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "sender", "recipient", "subject", "message_id"}).
+			AddRow(7, "altana@liokor.ru", "alt@liokor.ru", "Hi", "<abc@ya.ru>"))
+	email, err := s.gmr.GetMail("alt@liokor.ru", 7)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "<abc@ya.ru>", email.MessageId.String)
+
+	s.mock.ExpectQuery("SELECT id, sender, recipient").
+		WithArgs(8, "alt@liokor.ru", "alt@liokor.ru").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	_, err = s.gmr.GetMail("alt@liokor.ru", 8)
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
+func (s *Suite) TestGetMailRaw() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT raw FROM "mails" WHERE id=$1 LIMIT 1`)).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"raw"}).AddRow([]byte("From: friend@ya.ru\r\n\r\nHi\r\n")))
+	raw, err := s.gmr.GetMailRaw(7)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "From: friend@ya.ru\r\n\r\nHi\r\n", string(raw))
+}
+
+func (s *Suite) TestCreateSignature() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery("INSERT INTO signatures").
+		WithArgs(s.owner, "Work", "Alt").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	s.mock.ExpectExec("DELETE FROM signature_defaults").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectExec("INSERT INTO signature_defaults").
+		WithArgs(s.owner, "support", 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+
+	signature, err := s.gmr.CreateSignature(mail.Signature{
+		Owner:      s.owner,
+		Name:       "Work",
+		Body:       "Alt",
+		DefaultFor: common.StringList{"support"},
+	})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 3, signature.Id)
+}
+
+func (s *Suite) TestUpdateTemplate() {
+	s.mock.ExpectExec("UPDATE templates").
+		WithArgs("Thanks", "", "Thank you", 2, s.owner).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	_, err := s.gmr.UpdateTemplate(mail.Template{Id: 2, Owner: s.owner, Name: "Thanks", Body: "Thank you"})
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
+func (s *Suite) TestSaveReadReceipts() {
+	s.mock.ExpectQuery("UPDATE mails SET read_date=NOW\\(\\)").
+		WithArgs("alt@liokor.ru", "friend@ya.ru").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "sender", "recipient", "subject", "message_id", "notify_address", "read_date"}).
+			AddRow(3, "friend@ya.ru", "alt@liokor.ru", "Hi", "<abc@ya.ru>", "friend@ya.ru", time.Now()))
+	read, err := s.gmr.SaveReadReceipts("alt@liokor.ru", "friend@ya.ru")
+	require.NoError(s.T(), err)
+	require.Len(s.T(), read, 1)
+	require.Equal(s.T(), "friend@ya.ru", read[0].NotifyAddress.String)
+}
+
+func (s *Suite) TestUpdateDeliveryStatus() {
+	// imported mails with foreign Message-ID aren't reported
+	s.mock.ExpectExec("UPDATE mails SET status=.* AND COALESCE\\(message_id, ''\\)=''").
+		WithArgs(mail.MailStatusFailed, 12, "alt@liokor.ru", "nobody@ya.ru").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	err := s.gmr.UpdateDeliveryStatus(12, "alt@liokor.ru", "nobody@ya.ru", mail.MailStatusFailed)
+	require.NoError(s.T(), err)
+}
+
+func (s *Suite) TestSnoozeDialogue() {
+	until := time.Now().Add(time.Hour)
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec("UPDATE \"dialogues\" SET \"snoozed_until\"").
+		WithArgs(&until, 1, s.owner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+	err := s.gmr.SnoozeDialogue(s.owner, 1, &until)
+	require.NoError(s.T(), err)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec("UPDATE \"dialogues\" SET \"snoozed_until\"").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectCommit()
+	err = s.gmr.SnoozeDialogue(s.owner, 2, nil)
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
+func (s *Suite) TestGetSnoozedDialogues() {
+	until := time.Now().Add(time.Hour)
+	s.mock.ExpectQuery("SELECT .* FROM \"dialogues\" .*snoozed_until IS NOT NULL").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id",
+			"other",
+			"received_date",
+			"snoozed_until",
+		}).AddRow(
+			s.dialogue.Id,
+			s.dialogue.Email,
+			s.dialogue.Received_date,
+			until,
+		))
+	dialogues, err := s.gmr.GetSnoozedDialogues(s.owner, mail.Page{Limit: 10})
+	require.NoError(s.T(), err)
+	require.True(s.T(), dialogues[0].SnoozedUntil.Equal(until))
+}
+
+func (s *Suite) TestWakeSnoozedDialogues() {
+	now := time.Now()
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta(
+		`UPDATE "dialogues" SET "received_date"=GREATEST(received_date, $1),"snoozed_until"=$2,"unread"=unread + 1 WHERE snoozed_until<=$3`)).
+		WithArgs(now, nil, now).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	s.mock.ExpectCommit()
+	woken, err := s.gmr.WakeSnoozedDialogues(now)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 2, woken)
+}
+
+func (s *Suite) TestGetArchivedDialogues() {
+	s.mock.ExpectQuery("SELECT .* FROM \"dialogues\" .*dialogues.archived=TRUE").
+		WithArgs(s.owner).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "other", "received_date", "archived"}).
+			AddRow(s.dialogue.Id, s.dialogue.Email, s.dialogue.Received_date, true))
+	dialogues, err := s.gmr.GetArchivedDialogues(s.owner, mail.Page{Limit: 10})
+	require.NoError(s.T(), err)
+	require.True(s.T(), dialogues[0].Archived)
+}
+
+func (s *Suite) TestBulkMails() {
+	address := s.owner + "@" + s.domain
+	mailColumns := []string{"sender", "recipient", "deleted_by_sender", "deleted_by_recipient"}
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT sender, recipient, deleted_by_sender, deleted_by_recipient FROM "mails" WHERE id=$1 LIMIT 1`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(mailColumns).AddRow(address, s.other, false, false))
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "mails" SET "starred_by_sender"=$1 WHERE id=$2`)).
+		WithArgs(true, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectQuery("SELECT sender, recipient").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(mailColumns).AddRow(s.other, "another@ya.ru", false, false))
+	s.mock.ExpectCommit()
+	results, err := s.gmr.BulkMails(s.owner, s.domain, mail.Bulk{Target: mail.BulkMails, Action: mail.BulkStar, Ids: []int{1, 2}})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), []mail.BulkResult{
+		{Id: 1, Ok: true, Other: s.other},
+		{Id: 2, Error: "mail doesn't exist", Other: s.other},
+	}, results)
+
+	// deleted mail is restored with its dialogue
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery("SELECT sender, recipient").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(mailColumns).AddRow(s.other, address, false, true))
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "mails" SET "deleted_by_recipient"=$1 WHERE id=$2`)).
+		WithArgs(false, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO dialogues (owner, other) VALUES ($1, $2) ON CONFLICT")).
+		WithArgs(s.owner, s.other).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectExec("WITH last AS .* UPDATE dialogues SET last_mail_id").
+		WithArgs(address, s.other, s.other, address, s.other, address, s.owner, s.other).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+	results, err = s.gmr.BulkMails(s.owner, s.domain, mail.Bulk{Target: mail.BulkMails, Action: mail.BulkRestore, Ids: []int{3}})
+	require.NoError(s.T(), err)
+	require.True(s.T(), results[0].Ok)
+
+	// failed update rolls back the items changed before it
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery("SELECT sender, recipient").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(mailColumns).AddRow(s.other, address, false, false))
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "mails" SET "unread"=$1 WHERE id=$2`)).
+		WithArgs(false, 3).
+		WillReturnError(errors.New("connection lost"))
+	s.mock.ExpectRollback()
+	_, err = s.gmr.BulkMails(s.owner, s.domain, mail.Bulk{Target: mail.BulkMails, Action: mail.BulkRead, Ids: []int{3, 4}})
+	require.Error(s.T(), err)
+}
+
+func (s *Suite) TestBulkDialogues() {
+	address := s.owner + "@" + s.domain
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT other FROM "dialogues" WHERE id=$1 AND owner=$2 LIMIT 1`)).
+		WithArgs(1, s.owner).
+		WillReturnRows(sqlmock.NewRows([]string{"other"}).AddRow(s.other))
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "dialogues" SET "archived"=$1 WHERE id=$2`)).
+		WithArgs(true, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectQuery("SELECT other FROM \"dialogues\"").
+		WithArgs(2, s.owner).
+		WillReturnRows(sqlmock.NewRows([]string{"other"}))
+	s.mock.ExpectCommit()
+	results, err := s.gmr.BulkDialogues(s.owner, s.domain, mail.Bulk{Target: mail.BulkDialogues, Action: mail.BulkArchive, Ids: []int{1, 2}})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), []mail.BulkResult{
+		{Id: 1, Ok: true, Other: s.other},
+		{Id: 2, Error: "dialogue doesn't exist"},
+	}, results)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery("SELECT other FROM \"dialogues\"").
+		WithArgs(1, s.owner).
+		WillReturnRows(sqlmock.NewRows([]string{"other"}).AddRow(s.other))
+	s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM dialogues WHERE id=$1")).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "mails" SET "deleted_by_sender"=$1 WHERE sender=$2 AND recipient=$3`)).
+		WithArgs(true, address, s.other).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "mails" SET "deleted_by_recipient"=$1 WHERE sender=$2 AND recipient=$3`)).
+		WithArgs(true, s.other, address).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	s.mock.ExpectCommit()
+	s.mock.ExpectExec(regexp.QuoteMeta("SELECT pg_notify($1, $2)")).
+		WithArgs(mail.EventsChannel, `{"type":"deleted","owner":"liokor","dialogue":"otherMail@ya.ru"}`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	results, err = s.gmr.BulkDialogues(s.owner, s.domain, mail.Bulk{Target: mail.BulkDialogues, Action: mail.BulkDelete, Ids: []int{1}})
+	require.NoError(s.T(), err)
+	require.True(s.T(), results[0].Ok)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery("SELECT other FROM \"dialogues\"").
+		WithArgs(1, s.owner).
+		WillReturnRows(sqlmock.NewRows([]string{"other"}).AddRow(s.other))
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "dialogues" SET "folder"=$1 WHERE id=$2`)).
+		WithArgs(7, 1).
+		WillReturnError(&pgconn.PgError{ConstraintName: "dialogues_folder_fkey"})
+	s.mock.ExpectRollback()
+	_, err = s.gmr.BulkDialogues(s.owner, s.domain, mail.Bulk{Target: mail.BulkDialogues, Action: mail.BulkMove, Ids: []int{1}, Folder: 7})
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
+func (s *Suite) TestGetPinnedDialogues() {
+	s.mock.ExpectQuery("SELECT .* FROM \"dialogues\" .*dialogues.pinned=TRUE").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id",
+			"other",
+			"pinned",
+		}).AddRow(
+			s.dialogue.Id,
+			s.dialogue.Email,
+			true,
+		))
+	dialogues, err := s.gmr.GetPinnedDialogues(s.owner, 0)
+	require.NoError(s.T(), err)
+	require.True(s.T(), dialogues[0].Pinned)
+}
+
+func (s *Suite) TestUpdateDialoguePinnedAndMuted() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec("UPDATE \"dialogues\" SET \"pinned\"").
+		WithArgs(true, 1, s.owner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+	err := s.gmr.UpdateDialoguePinned(s.owner, 1, true)
+	require.NoError(s.T(), err)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec("UPDATE \"dialogues\" SET \"muted\"").
+		WithArgs(true, 2, s.owner).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectCommit()
+	err = s.gmr.UpdateDialogueMuted(s.owner, 2, true)
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
+func (s *Suite) TestCreateSmartFolder() {
+	folder := mail.SmartFolder{Owner: s.owner, Name: "Yandex", SmartQuery: mail.SmartQuery{SenderDomain: "ya.ru"}}
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery("INSERT INTO \"smart_folders\"").
+		WithArgs(s.owner, "Yandex", "ya.ru", false, nil, nil, "", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	s.mock.ExpectCommit()
+	created, err := s.gmr.CreateSmartFolder(folder)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 3, created.Id)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery("INSERT INTO \"smart_folders\"").
+		WillReturnError(&pgconn.PgError{ConstraintName: "smart_folders_owner_name_key"})
+	s.mock.ExpectRollback()
+	_, err = s.gmr.CreateSmartFolder(folder)
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
+func (s *Suite) TestDeleteSmartFolder() {
+	s.mock.ExpectExec("DELETE FROM smart_folders").
+		WithArgs(s.owner, 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	err := s.gmr.DeleteSmartFolder(s.owner, 3)
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
+func (s *Suite) TestGetSmartFolderDialogues() {
+	cursor := mail.Cursor{Date: time.Now(), Id: 3}
+	after := cursor.Date.Add(-24 * time.Hour)
+	query := mail.SmartQuery{SenderDomain: "ya.ru", After: &after, Label: "work", HasAttachment: true, Unread: true}
+	address := s.owner + "@liokor.ru"
+	s.mock.ExpectQuery("SELECT .* FROM \"dialogues\" .*dialogues.unread>0 AND EXISTS \\(SELECT 1 FROM mails WHERE .*mails.sender ILIKE .*mails.received_date>=.*mail_labels.*mails.has_attachment=TRUE").
+		WithArgs(cursor.Date, cursor.Id, s.owner, address, address, "%@ya.ru", after, address, "work").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "other"}).AddRow(s.dialogue.Id, s.dialogue.Email))
+	dialogues, err := s.gmr.GetSmartFolderDialogues(s.owner, address, query, mail.Page{Cursor: &cursor, Limit: 10})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), s.dialogue.Email, dialogues[0].Email)
+
+	// unread counts of all the folders are taken by one query
+	s.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FILTER \\(WHERE .*mails.sender ILIKE.*\\), COUNT\\(\\*\\) FILTER \\(WHERE .*mails.has_attachment=TRUE.*\\) FROM dialogues .*dialogues.unread>0").
+		WithArgs(address, address, "%@ya.ru", address, address, s.owner).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "count"}).AddRow(2, 5))
+	counts, err := s.gmr.CountSmartFoldersUnread(s.owner, address, []mail.SmartQuery{{SenderDomain: "ya.ru"}, {HasAttachment: true}})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), []int{2, 5}, counts)
+}
+
+func (s *Suite) TestTakeWebhookRetries() {
+	now := time.Now()
+	s.mock.ExpectQuery(regexp.QuoteMeta("UPDATE webhook_retries SET next_attempt=$1 WHERE id IN (SELECT id FROM webhook_retries WHERE next_attempt<=NOW() ORDER BY next_attempt LIMIT $2 FOR UPDATE SKIP LOCKED)")).
+		WithArgs(sqlmock.AnyArg(), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "webhook", "payload", "attempt", "next_attempt"}).
+			AddRow(3, 1, `{"event":"sent"}`, 2, now))
+	retries, err := s.gmr.TakeWebhookRetries(time.Minute, 100)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), []mail.WebhookRetry{{Id: 3, Webhook: 1, Payload: `{"event":"sent"}`, Attempt: 2, NextAttempt: now}}, retries)
+}
+
+func (s *Suite) TestCreateWebhook() {
+	s.mock.ExpectQuery("INSERT INTO webhooks").
+		WithArgs(s.owner, "https://bots.ru/hook", "received,sent", "secret").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+
+	webhook, err := s.gmr.CreateWebhook(mail.Webhook{
+		Owner:  s.owner,
+		URL:    "https://bots.ru/hook",
+		Events: common.StringList{mail.WebhookReceived, mail.WebhookSent},
+		Secret: "secret",
+	})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 4, webhook.Id)
+}
+
+func (s *Suite) TestGetWebhookDeliveries() {
+	now := time.Now()
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "webhook_deliveries" WHERE webhook=$1 ORDER BY delivery_date DESC, id DESC LIMIT 10`)).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "webhook", "event", "attempt", "status_code", "error", "delivery_date"}).
+			AddRow(2, 4, mail.WebhookSent, 2, 200, "", now).
+			AddRow(1, 4, mail.WebhookSent, 1, 0, "timeout", now))
+
+	deliveries, err := s.gmr.GetWebhookDeliveries(4, 10)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), deliveries, 2)
+	require.True(s.T(), deliveries[0].Succeeded())
+	require.Equal(s.T(), "timeout", deliveries[1].Error)
+}
+
+func (s *Suite) TestGetPushSubscriptions() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "push_subscriptions" WHERE owner=$1 AND NOT EXISTS (SELECT 1 FROM dialogues WHERE owner=$2 AND other=$3 AND muted) ORDER BY id`)).
+		WithArgs(s.owner, s.owner, s.other).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner", "session", "endpoint", "p256dh", "auth"}).
+			AddRow(1, s.owner, "token", "https://push.example.com/abc", "key", "secret"))
+
+	subscriptions, err := s.gmr.GetPushSubscriptions(s.owner, s.other)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), subscriptions, 1)
+	require.Equal(s.T(), "key", subscriptions[0].Keys.P256dh)
+	require.Equal(s.T(), "secret", subscriptions[0].Keys.Auth)
+}
+
+func (s *Suite) TestSavePushSubscription() {
+	s.mock.ExpectExec("INSERT INTO push_subscriptions .* ON CONFLICT \\(session\\) DO UPDATE").
+		WithArgs(s.owner, "token", "https://push.example.com/abc", "key", "secret").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.gmr.SavePushSubscription(mail.PushSubscription{
+		Owner:    s.owner,
+		Session:  "token",
+		Endpoint: "https://push.example.com/abc",
+		Keys:     mail.PushKeys{P256dh: "key", Auth: "secret"},
+	})
+	require.NoError(s.T(), err)
+}
+
+func (s *Suite) TestGetMailboxEmails() {
+	address := s.owner + "@" + s.domain
+	alias := "support@" + s.domain
+	addresses := []string{address, alias}
+	inbox := 0
+	s.mock.ExpectQuery(regexp.QuoteMeta(`mail_labels.owner IN ($1,$2)) labels, `+
+		`dialogues.id dialogue_id, COALESCE(dialogues.folder, 0) folder FROM "mails" `+
+		`JOIN dialogues ON dialogues.owner=$3 AND (`+
+		`(mails.sender IN ($4,$5) AND mails.recipient=dialogues.other AND mails.deleted_by_sender=FALSE) OR `+
+		`(mails.recipient IN ($6,$7) AND mails.sender=dialogues.other AND mails.deleted_by_recipient=FALSE)) `+
+		`WHERE dialogues.folder IS NULL ORDER BY mails.received_date desc, mails.id desc LIMIT 10`)).
+		WithArgs(address, alias, s.owner, address, alias, address, alias).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "sender", "recipient", "subject", "body", "unread", "dialogue_id", "folder"}).
+			AddRow(7, s.other, alias, "Test", "Testing test", true, 3, 0))
+
+	emails, err := s.gmr.GetMailboxEmails(s.owner, addresses, mail.MailFilter{FolderId: &inbox, Limit: 10})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), emails, 1)
+	require.Equal(s.T(), 7, emails[0].Id)
+	require.Equal(s.T(), s.other, emails[0].Sender)
+	require.Equal(s.T(), 3, emails[0].DialogueId)
+}
+
+func (s *Suite) TestGetMailboxEmailIds() {
+	address := s.owner + "@" + s.domain
+	addresses := []string{address}
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT mails.id FROM "mails" JOIN dialogues ON dialogues.owner=$1`)).
+		WithArgs(s.owner, address, address).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9).AddRow(7))
+
+	ids, err := s.gmr.GetMailboxEmailIds(s.owner, addresses, mail.MailFilter{Limit: 2})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), []int{9, 7}, ids)
+
+	// only the latest mail of each dialogue
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM (SELECT DISTINCT ON (dialogues.id) mails.id, mails.received_date FROM "mails" `+
+		`JOIN dialogues ON dialogues.owner=$1`)).
+		WithArgs(s.owner, address, address).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9).AddRow(4))
+
+	ids, err = s.gmr.GetMailboxEmailIds(s.owner, addresses, mail.MailFilter{CollapseThreads: true, Limit: 2, Offset: 2})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), []int{9, 4}, ids)
+}
+
+func (s *Suite) TestGetMailChanges() {
+	address := s.owner + "@" + s.domain
+	s.mock.ExpectQuery(regexp.QuoteMeta(`FROM "mails" WHERE (sender=$2 OR recipient=$3) AND modseq>$4 ORDER BY modseq LIMIT 100`)).
+		WithArgs(address, address, address, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "modseq", "created_modseq", "deleted"}).
+			AddRow(7, 11, 11, false).
+			AddRow(5, 12, 3, true))
+
+	changes, err := s.gmr.GetMailChanges(address, 10, 100)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), []mail.SyncChange{
+		{Id: 7, Modseq: 11, CreatedModseq: 11},
+		{Id: 5, Modseq: 12, CreatedModseq: 3, Deleted: true},
+	}, changes)
+}
+
+func (s *Suite) TestGetDialogueChanges() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(`FROM sync_tombstones WHERE kind='dialogue' AND owner=$3 AND modseq>$4 ORDER BY modseq LIMIT $5`)).
+		WithArgs(s.owner, 10, s.owner, 10, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "modseq", "created_modseq", "deleted"}).
+			AddRow(3, 14, 0, true))
+
+	changes, err := s.gmr.GetDialogueChanges(s.owner, 10, 100)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), []mail.SyncChange{{Id: 3, Modseq: 14, Deleted: true}}, changes)
+}
+
+func (s *Suite) TestGetModseq() {
+	address := s.owner + "@" + s.domain
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(GREATEST(`)).
+		WithArgs(address, address, s.owner, 1, s.owner, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"modseq"}).AddRow(42))
+
+	modseq, err := s.gmr.GetModseq(s.owner, address, 1)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int64(42), modseq)
+}
+
+func (s *Suite) TestGetModseqLimit() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT LEAST((SELECT last_value FROM modseq), (SELECT MIN(`)).
+		WillReturnRows(sqlmock.NewRows([]string{"modseq"}).AddRow(40))
+
+	modseq, err := s.gmr.GetModseqLimit()
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int64(40), modseq)
+}
+
+func (s *Suite) TestGetUserId() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM "users" WHERE username=$1 LIMIT 1`)).
+		WithArgs("support").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	id, err := s.gmr.GetUserId("support")
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 9, id)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM "users" WHERE username=$1 LIMIT 1`)).
+		WithArgs("nobody").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	_, err = s.gmr.GetUserId("nobody")
+	require.IsType(s.T(), common.InvalidUserError{}, err)
+}
+
+func (s *Suite) TestCreateExport() {
+	now := time.Now()
+	s.mock.ExpectQuery("INSERT INTO exports").
+		WithArgs(s.owner, mail.ExportMbox, "3,5", "", mail.ExportPending, "token").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_date"}).AddRow(2, now))
+
+	export, err := s.gmr.CreateExport(mail.Export{
+		Owner:     s.owner,
+		Format:    mail.ExportMbox,
+		Dialogues: common.IntList{3, 5},
+		Status:    mail.ExportPending,
+		Token:     "token",
+	})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 2, export.Id)
+	require.Equal(s.T(), now, export.CreatedDate)
+
+	// one export of the user runs at a time
+	s.mock.ExpectQuery("INSERT INTO exports").
+		WithArgs(s.owner, mail.ExportMbox, "", "", mail.ExportPending, "another").
+		WillReturnError(&pgconn.PgError{ConstraintName: "exports_unfinished_owner_key"})
+	_, err = s.gmr.CreateExport(mail.Export{
+		Owner:  s.owner,
+		Format: mail.ExportMbox,
+		Status: mail.ExportPending,
+		Token:  "another",
+	})
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
+func (s *Suite) TestGetExport() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "exports" WHERE owner=$1 AND id=$2 LIMIT 1`)).
+		WithArgs(s.owner, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner", "format", "dialogues", "folders", "status", "total", "processed"}).
+			AddRow(2, s.owner, mail.ExportEml, "", "1,4", mail.ExportRunning, 10, 5))
+
+	export, err := s.gmr.GetExport(s.owner, 2)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), common.IntList{1, 4}, export.Folders)
+	require.Equal(s.T(), 5, export.Processed)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "exports" WHERE owner=$1 AND id=$2 LIMIT 1`)).
+		WithArgs(s.owner, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = s.gmr.GetExport(s.owner, 3)
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
+
+func (s *Suite) TestCountMailboxEmails() {
+	address := s.owner + "@" + s.domain
+	addresses := []string{address}
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(1) FROM "mails" JOIN dialogues ON dialogues.owner=$1`)).
+		WithArgs(s.owner, address, address, 3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+
+	count, err := s.gmr.CountMailboxEmails(s.owner, addresses, mail.MailFilter{DialogueIds: []int{3}, FolderIds: []int{1}})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 12, count)
+
+	// collapsed threads are counted by dialogues
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(DISTINCT("dialogues"."id")) FROM "mails" JOIN dialogues ON dialogues.owner=$1`)).
+		WithArgs(s.owner, address, address).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+
+	count, err = s.gmr.CountMailboxEmails(s.owner, addresses, mail.MailFilter{CollapseThreads: true})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 4, count)
+}
+
+func (s *Suite) TestAddImportedMail() {
+	address := s.owner + "@" + s.domain
+	date := time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC)
+	unread := false
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`INSERT INTO "mails" ("sender","recipient","subject","body","received_date","deleted_by_sender","message_id","unread")`)).
+		WithArgs(s.other, address, "Hi", "Hello", date, true, "<1@ya.ru>", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	s.mock.ExpectCommit()
+
+	id, err := s.gmr.AddMail(mail.Mail{
+		Sender:          s.other,
+		Recipient:       address,
+		Subject:         "Hi",
+		Body:            "Hello",
+		DeletedBySender: true,
+		MessageId:       "<1@ya.ru>",
+		Received_date:   date,
+		Unread:          &unread,
+	}, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 7, id)
+}
+
+func (s *Suite) TestInterruptExports() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`UPDATE exports SET status=$1, error=$2 WHERE status IN ($3, $4) RETURNING id, format, token`)).
+		WithArgs(mail.ExportFailed, "export was interrupted", mail.ExportPending, mail.ExportRunning).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "format", "token"}).AddRow(2, mail.ExportEml, "token"))
+
+	exports, err := s.gmr.InterruptExports()
+	require.NoError(s.T(), err)
+	require.Len(s.T(), exports, 1)
+	require.Equal(s.T(), "token", exports[0].Token)
+}
+
+func (s *Suite) TestExpireExports() {
+	now := time.Now()
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`UPDATE exports SET status=$1 WHERE status=$2 AND expires_date<=$3 RETURNING id, format, token`)).
+		WithArgs(mail.ExportExpired, mail.ExportDone, now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "format", "token"}).AddRow(2, mail.ExportMbox, "token"))
+
+	exports, err := s.gmr.ExpireExports(now)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), exports, 1)
+	require.Equal(s.T(), mail.ExportMbox, exports[0].Format)
+}
+
+func (s *Suite) TestMailExists() {
+	address := s.owner + "@" + s.domain
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(1) FROM "mails" WHERE message_id=$1 AND sender=$2 AND recipient=$3`)).
+		WithArgs("<1@ya.ru>", "friend@ya.ru", address).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	exists, err := s.gmr.MailExists("friend@ya.ru", address, "<1@ya.ru>")
+	require.NoError(s.T(), err)
+	require.True(s.T(), exists)
+}
+
+func (s *Suite) TestRebuildDialogue() {
+	address := s.owner + "@" + s.domain
+	date := time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC)
+	s.mock.ExpectQuery("INSERT INTO dialogues").
+		WithArgs(s.owner, s.other).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, received_date, body FROM "mails"`)).
+		WithArgs(address, s.other, s.other, address).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "received_date", "body"}).AddRow(9, date, "Hello"))
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(1) FROM "mails"`)).
+		WithArgs(s.other, address).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "dialogues" SET`)).
+		WithArgs("Hello", 9, date, int64(2), 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+
+	dialogueId, err := s.gmr.RebuildDialogue(s.owner, s.other, s.domain)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 4, dialogueId)
+}
+
+func (s *Suite) TestCreateImport() {
+	now := time.Now()
+	s.mock.ExpectQuery("INSERT INTO imports").
+		WithArgs(s.owner, mail.ImportIMAP, "imap.ya.ru", "friend", mail.ExportPending, "token").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_date"}).AddRow(3, now))
+
+	mailImport, err := s.gmr.CreateImport(mail.Import{
+		Owner:    s.owner,
+		Source:   mail.ImportIMAP,
+		Host:     "imap.ya.ru",
+		Username: "friend",
+		Status:   mail.ExportPending,
+		Token:    "token",
+	})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 3, mailImport.Id)
+	require.Equal(s.T(), now, mailImport.CreatedDate)
+}
+
+func (s *Suite) TestUpdateImportProgress() {
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE imports SET status=$1")).
+		WithArgs(mail.ExportRunning, 10, 7, 2, 1, `{"INBOX":42}`, "", 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.gmr.UpdateImportProgress(mail.Import{
+		Id:         3,
+		Status:     mail.ExportRunning,
+		Processed:  10,
+		Imported:   7,
+		Duplicates: 2,
+		Failed:     1,
+		Progress:   `{"INBOX":42}`,
+	})
+	require.NoError(s.T(), err)
+}
+
+func (s *Suite) TestDeleteMailboxMember() {
+	membersQuery := regexp.QuoteMeta("SELECT mailbox, member, is_admin FROM mailbox_members WHERE mailbox=$1 FOR UPDATE")
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(membersQuery).
+		WithArgs("support").
+		WillReturnRows(sqlmock.NewRows([]string{"mailbox", "member", "is_admin"}).
+			AddRow("support", "lio", true).
+			AddRow("support", "alt", true))
+	s.mock.ExpectExec("DELETE FROM \"mailbox_members\"").
+		WithArgs("support", "alt").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+	err := s.gmr.DeleteMailboxMember("support", "alt")
+	require.NoError(s.T(), err)
+
+	// the mailbox isn't left without an admin
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(membersQuery).
+		WithArgs("support").
+		WillReturnRows(sqlmock.NewRows([]string{"mailbox", "member", "is_admin"}).
+			AddRow("support", "lio", true).
+			AddRow("support", "alt", false))
+	s.mock.ExpectRollback()
+	err = s.gmr.DeleteMailboxMember("support", "lio")
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(membersQuery).
+		WithArgs("support").
+		WillReturnRows(sqlmock.NewRows([]string{"mailbox", "member", "is_admin"}).
+			AddRow("support", "lio", true))
+	s.mock.ExpectRollback()
+	err = s.gmr.DeleteMailboxMember("support", "wolf")
+	require.IsType(s.T(), mail.InvalidEmailError{}, err)
+}
@@ -1,17 +1,118 @@
 package mail
 
-import "time"
+import (
+	"context"
+	"io"
+	"time"
+)
 
 type MailUseCase interface {
-	GetDialogues(username string, amount int, find string, folderId int, since time.Time) ([]Dialogue, error)
+	GetDialogues(username string, find string, folderId int, page Page) (DialoguesPage, error)
+	GetSnoozedDialogues(username string, page Page) (DialoguesPage, error)
+	GetArchivedDialogues(username string, page Page) (DialoguesPage, error)
+	SnoozeDialogue(owner string, dialogueId int, until time.Time) error
+	UnsnoozeDialogue(owner string, dialogueId int) error
+	WakeSnoozedDialogues() error
+	PinDialogue(owner string, dialogueId int, pinned bool) error
+	MuteDialogue(owner string, dialogueId int, muted bool) error
 	CreateDialogue(owner, with string) (Dialogue, error)
 	DeleteDialogue(owner string, dialogueId int) error
-	GetEmails(username string, email string, last int, amount int) ([]DialogueEmail, error)
+	GetEmails(username string, email string, page Page) (EmailsPage, error)
+	ReadDialogueMails(owner string, other string) error
+	GetAddress(owner string) (string, error)
+	GetMailboxEmails(owner string, filter MailFilter) ([]MailboxEmail, error)
+	GetMailboxEmailIds(owner string, filter MailFilter) ([]int, error)
+	CountMailboxEmails(owner string, filter MailFilter) (int, error)
+	GetMailboxCounters(owner string) ([]MailboxCounters, error)
+	Sync(owner string, ownerId int, token string) (Sync, error)
+	GetSyncToken(owner string, ownerId int) (string, error)
 	SendEmail(mail Mail) (Mail, error)
+	PreviewEmail(owner string, email Mail) (Preview, error)
+	ReplyEmail(owner string, mailId int, reply Mail, all bool) ([]Mail, error)
+	ForwardEmail(owner string, mailId int, forward Mail) (Mail, error)
 	DeleteMails(owner string, mailIds []int) error
-	GetFolders(owner int) ([]Folder, error)
-	CreateFolder(owner int, folderName string) (Folder, error)
+	Bulk(ownerName string, owner int, bulk Bulk) ([]BulkResult, error)
+	GetFolders(ownerName string, owner int) ([]Folder, error)
+	GetFoldersPage(ownerName string, owner int, page Page) (FoldersPage, error)
+	CreateSmartFolder(owner string, folder SmartFolder) (SmartFolder, error)
+	UpdateSmartFolder(owner string, folder SmartFolder) (SmartFolder, error)
+	DeleteSmartFolder(owner string, folderId int) error
+	GetSmartFolderDialogues(username string, folderId int, page Page) (DialoguesPage, error)
+	CreateFolder(owner int, folderName string, parentId int) (Folder, error)
 	UpdateFolderPutDialogue(owner string, folderId int, dialogueId int) error
 	UpdateFolderName(owner, folderId int, folderName string) (Folder, error)
+	MoveFolder(owner, folderId int, parentId int, position int) (Folder, error)
 	DeleteFolder(ownerName string, owner, folderId int) error
+	GetVacation(owner string) (Vacation, error)
+	UpdateVacation(owner string, vacation Vacation) (Vacation, error)
+	GetReadReceipts(owner string) (bool, error)
+	UpdateReadReceipts(owner string, allow bool) error
+	ReceiveEmail(email Mail) error
+	BounceEmail(email Mail, failures map[string]error) error
+	GetForwarding(owner string) (Forwarding, error)
+	UpdateForwarding(owner string, forwarding Forwarding) (Forwarding, error)
+	ConfirmForwarding(token string) error
+	GetAliases(owner string) ([]Alias, error)
+	CreateAlias(owner string, alias string) (Alias, error)
+	DeleteAlias(owner string, aliasId int) error
+	GetDomains() ([]Domain, error)
+	AddDefaultDomain() error
+	IsLocalAddress(address string) (bool, error)
+	GetMailboxes(username string) ([]MailboxMember, error)
+	CreateMailbox(owner string, name string) (MailboxMember, error)
+	CheckMailboxAccess(mailbox string, username string) error
+	GetUserId(username string) (int, error)
+	GetMailboxMembers(username string, mailbox string) ([]MailboxMember, error)
+	AddMailboxMember(username string, member MailboxMember) error
+	DeleteMailboxMember(username string, mailbox string, member string) error
+	GetDistributionLists(owner string) ([]DistributionList, error)
+	CreateDistributionList(owner string, name string) (DistributionList, error)
+	DeleteDistributionList(owner string, listId int) error
+	UpdateListPosting(owner string, listId int, posting string) error
+	AddListMember(owner string, listId int, address string) error
+	DeleteListMember(owner string, listId int, address string) error
+	CheckIncomingSender(sender string, recipient string) error
+	GetSenderFilter(owner string) (SenderFilter, error)
+	UpdateSenderFilter(owner string, dropBlocked bool) error
+	AddSenderRule(owner string, rule SenderRule) (SenderRule, error)
+	DeleteSenderRule(owner string, ruleId int) error
+	GetGlobalBlocklist(username string) ([]SenderRule, error)
+	AddGlobalBlock(username string, pattern string) (SenderRule, error)
+	DeleteGlobalBlock(username string, ruleId int) error
+	GetSignatures(owner string) ([]Signature, error)
+	CreateSignature(owner string, signature Signature) (Signature, error)
+	UpdateSignature(owner string, signature Signature) (Signature, error)
+	DeleteSignature(owner string, signatureId int) error
+	GetTemplates(owner string) ([]Template, error)
+	CreateTemplate(owner string, template Template) (Template, error)
+	UpdateTemplate(owner string, template Template) (Template, error)
+	DeleteTemplate(owner string, templateId int) error
+	RenderTemplate(owner string, templateId int, recipient string) (RenderedTemplate, error)
+
+	GetWebhooks(owner string) ([]Webhook, error)
+	CreateWebhook(owner string, webhook Webhook) (Webhook, error)
+	DeleteWebhook(owner string, webhookId int) error
+	GetWebhookDeliveries(owner string, webhookId int, amount int) ([]WebhookDelivery, error)
+	TestWebhook(owner string, webhookId int) (WebhookDelivery, error)
+	RetryWebhooks() error
+
+	CreateExport(owner string, export Export) (Export, error)
+	GetExports(owner string) ([]Export, error)
+	GetExport(owner string, exportId int) (Export, error)
+	GetExportFile(token string) (Export, string, error)
+	InterruptExports() error
+	RemoveExpiredExports() error
+
+	ImportArchive(owner string, ownerId int, source string, archive io.Reader) (Import, error)
+	ImportIMAP(owner string, ownerId int, account IMAPAccount) (Import, error)
+	ResumeImport(owner string, ownerId int, importId int, password string) (Import, error)
+	GetImports(owner string) ([]Import, error)
+	GetImport(owner string, importId int) (Import, error)
+	InterruptImports() error
+
+	GetVapidPublicKey() (string, error)
+	SubscribePush(owner string, session string, subscription PushSubscription) error
+	UnsubscribePush(session string) error
+
+	ListenEvents(ctx context.Context, handle func(Event)) error
 }
@@ -1,39 +1,247 @@
 package usecase
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
 	"liokor_mail/internal/pkg/common"
 	"liokor_mail/internal/pkg/mail"
+	"liokor_mail/internal/pkg/user/validators"
 	"liokor_mail/internal/utils"
 	"log"
+	"net/http"
+	netMail "net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"crypto/rsa"
 
-	"github.com/gomarkdown/markdown"
-	"github.com/gomarkdown/markdown/parser"
 	"github.com/microcosm-cc/bluemonday"
 )
 
+// replaced in tests to not send real mail
+var smtpSendMail = utils.SMTPSendMail
+var smtpRelayMail = utils.SMTPRelayMail
+var sendWebPush = utils.SendWebPush
+
+// failed webhook delivery is retried after each of the delays
+var webhookRetryDelays = []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute, time.Hour}
+
+// retries taken by RetryWebhooks at once, they aren't taken again during the lease
+const webhookRetryBatch = 100
+const webhookRetryLease = 30 * time.Minute
+
+// user given urls mustn't reach the server's own network, replaced in tests with loopback servers
+var webhookClient = utils.NewPublicHTTPClient(10 * time.Second)
+
+// runs webhook deliveries in background, replaced in tests to wait for them
+var runAsync = func(f func()) { go f() }
+
+// max changes of each kind returned by one sync, replaced in tests
+var syncLimit = 1000
+
+// limits of the uploaded import archive and of each message read from it, replaced in tests
+var maxImportSize int64 = 1 << 30
+var maxImportMessageSize = 32 << 20
+
+// mails written to the export archive between progress updates
+const exportPageSize = 100
+
+// messages imported between progress updates, also fetched from imap server at once
+const importBatchSize = 50
+
+// replaced in tests to not connect to real imap server
+var dialIMAP = utils.DialIMAP
+
+// renders mail body both for sending and preview
+var markdownRenderer = utils.NewMarkdownRenderer()
+
 type MailUseCase struct {
 	Repository mail.MailRepository
 	Config     common.Config
-	PrivateKey *rsa.PrivateKey
+	PrivateKey *rsa.PrivateKey // used for the default domain without own DKIM key
+}
+
+func (uc *MailUseCase) GetDomains() ([]mail.Domain, error) {
+	domains, err := uc.Repository.GetDomains()
+	if err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// AddDefaultDomain creates the domain of the installation from config on start
+func (uc *MailUseCase) AddDefaultDomain() error {
+	return uc.Repository.AddDefaultDomain(uc.Config.MailDomain)
+}
+
+// localDomain returns domain of the address if it is served by the installation
+func (uc *MailUseCase) localDomain(address string) (mail.Domain, bool, error) {
+	splitted := strings.Split(address, "@")
+	if len(splitted) != 2 || splitted[1] == "" {
+		return mail.Domain{}, false, nil
+	}
+	domain, err := uc.Repository.GetDomain(splitted[1])
+	if err != nil {
+		if _, ok := err.(mail.InvalidEmailError); ok {
+			return mail.Domain{}, false, nil
+		}
+		return mail.Domain{}, false, err
+	}
+	return domain, true, nil
+}
+
+func (uc *MailUseCase) IsLocalAddress(address string) (bool, error) {
+	_, isLocal, err := uc.localDomain(address)
+	return isLocal, err
+}
+
+// dkimKey returns key to sign mail from the domain, nil if the domain has no valid key
+func (uc *MailUseCase) dkimKey(domain mail.Domain) *utils.DkimKey {
+	if domain.DkimPrivateKey.Valid && domain.DkimPrivateKey.String != "" {
+		privateKey, err := utils.ParsePrivateKey([]byte(domain.DkimPrivateKey.String))
+		if err != nil {
+			log.Printf("ERROR: Invalid DKIM key of %s: %v\n", domain.Name, err)
+			return nil
+		}
+		return &utils.DkimKey{domain.Name, domain.DkimSelector, privateKey}
+	}
+	if uc.PrivateKey != nil && strings.EqualFold(domain.Name, uc.Config.MailDomain) {
+		return &utils.DkimKey{domain.Name, domain.DkimSelector, uc.PrivateKey}
+	}
+	return nil
 }
 
-func (uc *MailUseCase) GetDialogues(username string, amount int, find string, folderId int, since time.Time) ([]mail.Dialogue, error) {
+// GetDialogues returns page of the dialogues from the latest received, the first page
+// starts with pinned dialogues of the folder, they aren't paged
+func (uc *MailUseCase) GetDialogues(username string, find string, folderId int, page mail.Page) (mail.DialoguesPage, error) {
 	var dialogues []mail.Dialogue
 	var err error
 	if find == "" {
-		dialogues, err = uc.Repository.GetDialoguesInFolder(username, amount, folderId, ("@" + uc.Config.MailDomain), since)
+		dialogues, err = uc.Repository.GetDialoguesInFolder(username, folderId, page)
 	} else {
-		dialogues, err = uc.Repository.FindDialogues(username, find, amount, ("@" + uc.Config.MailDomain), since)
+		dialogues, err = uc.Repository.FindDialogues(username, find, page)
 	}
 	if err != nil {
-		return nil, err
+		return mail.DialoguesPage{}, err
+	}
+	result := dialoguesPage(page, dialogues)
+	if find == "" && page.Cursor == nil {
+		pinned, err := uc.Repository.GetPinnedDialogues(username, folderId)
+		if err != nil {
+			return mail.DialoguesPage{}, err
+		}
+		result.Dialogues = append(pinned, result.Dialogues...)
+	}
+	return result, nil
+}
+
+func (uc *MailUseCase) GetSnoozedDialogues(username string, page mail.Page) (mail.DialoguesPage, error) {
+	dialogues, err := uc.Repository.GetSnoozedDialogues(username, page)
+	if err != nil {
+		return mail.DialoguesPage{}, err
+	}
+	return dialoguesPage(page, dialogues), nil
+}
+
+func (uc *MailUseCase) GetArchivedDialogues(username string, page mail.Page) (mail.DialoguesPage, error) {
+	dialogues, err := uc.Repository.GetArchivedDialogues(username, page)
+	if err != nil {
+		return mail.DialoguesPage{}, err
+	}
+	return dialoguesPage(page, dialogues), nil
+}
+
+// pageResult cuts the extra item selected to tell whether there are more, puts items of the backward page
+// in the list order and returns length of the page with its cursors
+func pageResult(page mail.Page, items interface{}, length int, cursorAt func(i int) mail.Cursor) (int, mail.PageInfo) {
+	info := mail.PageInfo{HasMore: length > page.Limit}
+	if info.HasMore {
+		length = page.Limit
+	}
+	if page.Backward() {
+		swap := reflect.Swapper(items)
+		for i := 0; i < length/2; i++ {
+			swap(i, length-1-i)
+		}
+	}
+	if length == 0 {
+		if page.Cursor != nil {
+			info.PrevCursor = page.Cursor.Reversed().String()
+		}
+		return 0, info
+	}
+	first := cursorAt(0)
+	first.Backward = true
+	last := cursorAt(length - 1)
+	if page.Backward() {
+		first, last = last, first
+	}
+	if info.HasMore {
+		info.NextCursor = last.String()
+	}
+	info.PrevCursor = first.String()
+	return length, info
+}
+
+func dialoguesPage(page mail.Page, dialogues []mail.Dialogue) mail.DialoguesPage {
+	length, info := pageResult(page, dialogues, len(dialogues), func(i int) mail.Cursor {
+		return mail.Cursor{Date: dialogues[i].Received_date, Id: dialogues[i].Id}
+	})
+	return mail.DialoguesPage{Dialogues: dialogues[:length], PageInfo: info}
+}
+
+func (uc *MailUseCase) SnoozeDialogue(owner string, dialogueId int, until time.Time) error {
+	if !until.After(time.Now()) {
+		return mail.InvalidEmailError{"snooze time must be in the future"}
+	}
+	return uc.Repository.SnoozeDialogue(owner, dialogueId, &until)
+}
+
+func (uc *MailUseCase) UnsnoozeDialogue(owner string, dialogueId int) error {
+	return uc.Repository.SnoozeDialogue(owner, dialogueId, nil)
+}
+
+// WakeSnoozedDialogues brings back dialogues whose snooze time has come, it is run periodically by the server
+func (uc *MailUseCase) WakeSnoozedDialogues() error {
+	woken, err := uc.Repository.WakeSnoozedDialogues(time.Now())
+	if err != nil {
+		return err
 	}
-	return dialogues, nil
+	if woken > 0 {
+		log.Printf("INFO: %d snoozed dialogues are back\n", woken)
+	}
+	return nil
+}
+
+// ListenEvents passes mail events published by any server process to handle until ctx is done
+func (uc *MailUseCase) ListenEvents(ctx context.Context, handle func(mail.Event)) error {
+	return uc.Repository.ListenEvents(ctx, handle)
+}
+
+func (uc *MailUseCase) PinDialogue(owner string, dialogueId int, pinned bool) error {
+	return uc.Repository.UpdateDialoguePinned(owner, dialogueId, pinned)
+}
+
+func (uc *MailUseCase) MuteDialogue(owner string, dialogueId int, muted bool) error {
+	return uc.Repository.UpdateDialogueMuted(owner, dialogueId, muted)
 }
 
 func (uc *MailUseCase) CreateDialogue(owner, with string) (mail.Dialogue, error) {
@@ -45,32 +253,287 @@ func (uc *MailUseCase) CreateDialogue(owner, with string) (mail.Dialogue, error)
 }
 
 func (uc *MailUseCase) DeleteDialogue(owner string, dialogueId int) error {
-	err := uc.Repository.DeleteDialogue(owner, dialogueId, uc.Config.MailDomain)
+	domain, err := uc.Repository.GetUserDomain(owner)
+	if err != nil {
+		return err
+	}
+	err = uc.Repository.DeleteDialogue(owner, dialogueId, domain.Name)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetEmails returns page of the dialogue mails from the latest received and marks the dialogue read
+func (uc *MailUseCase) GetEmails(username string, email string, page mail.Page) (mail.EmailsPage, error) {
+	domain, err := uc.Repository.GetUserDomain(username)
+	if err != nil {
+		return mail.EmailsPage{}, err
+	}
+	emails, err := uc.Repository.GetMailsForUser(username+"@"+domain.Name, email, page)
+	if err != nil {
+		return mail.EmailsPage{}, err
+	}
+	length, info := pageResult(page, emails, len(emails), func(i int) mail.Cursor {
+		return mail.Cursor{Date: emails[i].Received_date, Id: emails[i].Id}
+	})
+	emails = emails[:length]
+	addresses, err := uc.Repository.GetOwnerAddresses(username)
+	if err != nil {
+		return mail.EmailsPage{}, err
+	}
+	unread := false
+	for _, e := range emails {
+		if e.Unread && containsFold(addresses, e.Recipient) {
+			unread = true
+			break
+		}
+	}
+	err = uc.readDialogue(username, domain, addresses, email, unread)
+	if err != nil {
+		return mail.EmailsPage{}, err
+	}
+	return mail.EmailsPage{Emails: emails, PageInfo: info}, nil
+}
+
+// readDialogue marks mails from other to any of the owner addresses as read, notify fires read webhooks of the owner
+func (uc *MailUseCase) readDialogue(owner string, domain mail.Domain, addresses []string, other string, notify bool) error {
+	uc.sendReadReceipts(owner, domain, other)
+	err := uc.Repository.ReadMail(addresses, other)
+	if err != nil {
+		return err
+	}
+	err = uc.Repository.ReadDialogue(owner, other)
 	if err != nil {
 		return err
 	}
+	if notify {
+		uc.fireWebhooks(mail.WebhookPayload{Event: mail.WebhookRead, Owner: owner, Dialogue: other})
+	}
 	return nil
 }
 
-func (uc *MailUseCase) GetEmails(username string, email string, last int, amount int) ([]mail.DialogueEmail, error) {
-	emails, err := uc.Repository.GetMailsForUser(username + "@" + uc.Config.MailDomain, email, amount, last)
+func (uc *MailUseCase) ReadDialogueMails(owner string, other string) error {
+	domain, err := uc.Repository.GetUserDomain(owner)
+	if err != nil {
+		return err
+	}
+	addresses, err := uc.Repository.GetOwnerAddresses(owner)
+	if err != nil {
+		return err
+	}
+	return uc.readDialogue(owner, domain, addresses, other, true)
+}
+
+func (uc *MailUseCase) GetAddress(owner string) (string, error) {
+	domain, err := uc.Repository.GetUserDomain(owner)
+	if err != nil {
+		return "", err
+	}
+	return owner + "@" + domain.Name, nil
+}
+
+func (uc *MailUseCase) GetMailboxEmails(owner string, filter mail.MailFilter) ([]mail.MailboxEmail, error) {
+	addresses, err := uc.Repository.GetOwnerAddresses(owner)
 	if err != nil {
 		return nil, err
 	}
-	err = uc.Repository.ReadMail(username + "@" + uc.Config.MailDomain, email)
+	return uc.Repository.GetMailboxEmails(owner, addresses, filter)
+}
+
+func (uc *MailUseCase) GetMailboxEmailIds(owner string, filter mail.MailFilter) ([]int, error) {
+	addresses, err := uc.Repository.GetOwnerAddresses(owner)
 	if err != nil {
 		return nil, err
 	}
-	err = uc.Repository.ReadDialogue(username, email)
+	return uc.Repository.GetMailboxEmailIds(owner, addresses, filter)
+}
+
+func (uc *MailUseCase) CountMailboxEmails(owner string, filter mail.MailFilter) (int, error) {
+	addresses, err := uc.Repository.GetOwnerAddresses(owner)
+	if err != nil {
+		return 0, err
+	}
+	return uc.Repository.CountMailboxEmails(owner, addresses, filter)
+}
+
+func (uc *MailUseCase) GetMailboxCounters(owner string) ([]mail.MailboxCounters, error) {
+	addresses, err := uc.Repository.GetOwnerAddresses(owner)
 	if err != nil {
 		return nil, err
 	}
-	return emails, nil
+	return uc.Repository.GetMailboxCounters(owner, addresses)
+}
+
+// GetSyncToken returns the token of the current state of the mailbox
+func (uc *MailUseCase) GetSyncToken(owner string, ownerId int) (string, error) {
+	domain, err := uc.Repository.GetUserDomain(owner)
+	if err != nil {
+		return "", err
+	}
+	limit, err := uc.Repository.GetModseqLimit()
+	if err != nil {
+		return "", err
+	}
+	modseq, err := uc.Repository.GetModseq(owner, owner+"@"+domain.Name, ownerId)
+	if err != nil {
+		return "", err
+	}
+	if modseq > limit {
+		modseq = limit
+	}
+	return strconv.FormatInt(modseq, 10), nil
+}
+
+// Sync returns mails, dialogues and folders changed since the token, empty token returns everything.
+// When any kind has more changes than the limit, the token points to the last change all the kinds
+// are complete up to, so the next sync may return some of the changes again.
+// Changes after the modseq of running transactions are left to the next sync
+func (uc *MailUseCase) Sync(owner string, ownerId int, token string) (mail.Sync, error) {
+	var since int64
+	if token != "" {
+		var err error
+		since, err = strconv.ParseInt(token, 10, 64)
+		if err != nil || since < 0 {
+			return mail.Sync{}, mail.InvalidEmailError{"invalid sync token"}
+		}
+	}
+	domain, err := uc.Repository.GetUserDomain(owner)
+	if err != nil {
+		return mail.Sync{}, err
+	}
+	limit, err := uc.Repository.GetModseqLimit()
+	if err != nil {
+		return mail.Sync{}, err
+	}
+	mails, err := uc.Repository.GetMailChanges(owner+"@"+domain.Name, since, syncLimit+1)
+	if err != nil {
+		return mail.Sync{}, err
+	}
+	dialogues, err := uc.Repository.GetDialogueChanges(owner, since, syncLimit+1)
+	if err != nil {
+		return mail.Sync{}, err
+	}
+	folders, err := uc.Repository.GetFolderChanges(ownerId, since, syncLimit+1)
+	if err != nil {
+		return mail.Sync{}, err
+	}
+
+	// changes are ordered by modseq, so every kind is complete up to its last returned change
+	last := int64(-1)
+	for _, changes := range [][]mail.SyncChange{mails, dialogues, folders} {
+		if len(changes) > syncLimit {
+			if modseq := changes[syncLimit-1].Modseq; last < 0 || modseq < last {
+				last = modseq
+			}
+		}
+	}
+	result := mail.Sync{HasMore: last >= 0}
+	if !result.HasMore {
+		last = since
+		for _, changes := range [][]mail.SyncChange{mails, dialogues, folders} {
+			if len(changes) > 0 && changes[len(changes)-1].Modseq > last {
+				last = changes[len(changes)-1].Modseq
+			}
+		}
+	}
+	if last > limit {
+		// the rest is returned after the running transactions end, not by the repeated sync
+		last, result.HasMore = limit, false
+		if last < since {
+			last = since
+		}
+	}
+	result.Token = strconv.FormatInt(last, 10)
+	result.Mails = syncChanges(mails, since, last)
+	result.Dialogues = syncChanges(dialogues, since, last)
+	result.Folders = syncChanges(folders, since, last)
+	return result, nil
+}
+
+// syncChanges sorts ids of the changes up to the last modseq by the kind of the change
+func syncChanges(changes []mail.SyncChange, since int64, last int64) mail.SyncChanges {
+	result := mail.SyncChanges{Created: []int{}, Updated: []int{}, Deleted: []int{}}
+	for _, change := range changes {
+		if change.Modseq > last {
+			break
+		}
+		switch {
+		case change.Deleted:
+			result.Deleted = append(result.Deleted, change.Id)
+		case change.CreatedModseq > since:
+			result.Created = append(result.Created, change.Id)
+		default:
+			result.Updated = append(result.Updated, change.Id)
+		}
+	}
+	return result
 }
 
 func (uc *MailUseCase) SendEmail(email mail.Mail) (mail.Mail, error) {
-	email.Sender += "@" + uc.Config.MailDomain
-	isInternal := strings.HasSuffix(email.Recipient, uc.Config.MailDomain)
+	owner := email.Sender
+	senderDomain, err := uc.Repository.GetUserDomain(owner)
+	if err != nil {
+		return email, err
+	}
+	email.Sender += "@" + senderDomain.Name
+	recipientAddress := strings.ToLower(email.Recipient)
+	recipientDomain, isInternal, err := uc.localDomain(email.Recipient)
+	if err != nil {
+		return email, err
+	}
+
+	if email.Alias != "" {
+		alias, err := uc.ownAlias(owner, email.Alias, senderDomain)
+		if err != nil {
+			return email, err
+		}
+		email.Alias = alias
+	}
+
+	if !email.NoSignature {
+		err = uc.appendSignature(owner, &email)
+		if err != nil {
+			return email, err
+		}
+	}
+
+	var tag string
+	var list *mail.DistributionList
+	if isInternal {
+		email.Recipient, tag, list, err = uc.resolveRecipient(email.Recipient, recipientDomain)
+		if err != nil {
+			if _, ok := err.(common.InvalidUserError); ok {
+				return email, mail.InvalidEmailError{"recipient doesn't exist"}
+			}
+			return email, err
+		}
+		if list != nil {
+			err = checkListSender(*list, email, true)
+			if err != nil {
+				return email, mail.InvalidEmailError{err.Error()}
+			}
+		}
+	}
+
+	blocked := false
+	if isInternal && list == nil {
+		from := email.Sender
+		if email.Alias != "" {
+			from = email.Alias
+		}
+		err = uc.checkSender(strings.Split(email.Recipient, "@")[0], from)
+		if blockedErr, ok := err.(mail.SenderBlockedError); ok {
+			if !blockedErr.Drop {
+				return email, mail.InvalidEmailError{"recipient doesn't accept mail from you"}
+			}
+			// the sender keeps own copy and isn't told about the block
+			blocked = true
+			email.DeletedByRecipient = true
+		} else if err != nil {
+			return email, err
+		}
+	}
 
 	if !(uc.Config.Debug || isInternal) {
 		lastMailsCount, err := uc.Repository.CountMailsFromUser(email.Sender, 3*time.Minute)
@@ -84,92 +547,2821 @@ func (uc *MailUseCase) SendEmail(email mail.Mail) (mail.Mail, error) {
 
 	pStrict := bluemonday.StrictPolicy()
 	email.Subject = pStrict.Sanitize(email.Subject)
-	// to strip all non-markdown tags
-	email.Body = pStrict.Sanitize(email.Body)
-
-	extensions := parser.CommonExtensions | parser.AutoHeadingIDs
-	parser := parser.NewWithExtensions(extensions)
-
-	md := []byte(email.Body)
-	email.Body = string(markdown.ToHTML(md, parser, nil))
-
-	// to remove restricted tags and add nofollow to links
-	pUGC := bluemonday.UGCPolicy()
-	email.Body = pUGC.Sanitize(email.Body)
+	email.Body = markdownRenderer.Render(email.Body, email.Quote)
 
 	if len(email.Subject) == 0 || len(email.Body) == 0 {
 		return email, errors.New("Empty subject or body after sanitizing!")
 	}
 
-	mailId, err := uc.Repository.AddMail(email, uc.Config.MailDomain)
+	if len(email.Forwarded) != 0 {
+		// the copies keep the forwarded message, so its attachments are carried on by the next forward
+		email.Raw = email.Forwarded
+		email.HasAttachment = true
+	}
+
+	dialogueOwners := []string{email.Sender}
+	if isInternal && list == nil && !blocked {
+		dialogueOwners = append(dialogueOwners, email.Recipient)
+	}
+	mailId, err := uc.Repository.AddMail(email, dialogueOwners)
 	if err != nil {
 		return email, err
 	}
 	email.Id = mailId
 
+	if !isAutomatedMail(email) {
+		// addresses the user writes to are proposed by recipient autocomplete
+		errCollect := uc.Repository.CollectContact(owner, recipientAddress)
+		if errCollect != nil {
+			log.Printf("WARN: Unable to collect contact %s: %v\n", recipientAddress, errCollect)
+		}
+	}
+
 	if !isInternal {
-		err = utils.SMTPSendMail(email.Sender, email.Recipient, email.Subject, email.Body, uc.PrivateKey)
+		from := email.Sender
+		if email.Alias != "" {
+			from = email.Alias
+		}
+		headers := map[string]string{"Message-ID": localMessageId(mailId, senderDomain.Name)}
+		if email.RequestReceipt {
+			headers["Disposition-Notification-To"] = "<" + from + ">"
+		}
+		for key, value := range email.Headers {
+			headers[key] = value
+		}
+		if len(email.Forwarded) != 0 {
+			message := utils.FormatForwardMessage(from, email.Recipient, email.Subject, email.Body, headers, email.Forwarded)
+			err = smtpRelayMail(from, email.Recipient, message, uc.dkimKey(senderDomain))
+		} else {
+			err = smtpSendMail(from, email.Recipient, email.Subject, email.Body, headers, uc.dkimKey(senderDomain))
+		}
 		if err != nil {
 			log.Printf("WARN: Unable to send email to %s\n", email.Recipient)
-			errDb := uc.Repository.UpdateMailStatus(mailId, 0)
+			errDb := uc.Repository.UpdateMailStatus(mailId, mail.MailStatusFailed)
 			if errDb != nil {
 				log.Printf("ERROR: Unable to change mail status!\n")
 			}
+			// temporary failures aren't bounced, the sender gets the error and may send the mail again
+			if utils.PermanentFailure(err) {
+				sentHeaders := fmt.Sprintf("From: <%s>\r\nTo: %s\r\nSubject: %s\r\n%s", from, email.Recipient, email.Subject, utils.FormatHeaders(headers))
+				uc.bounceToMailbox(email.Sender, senderDomain, []utils.ReportRecipient{utils.FailedRecipient(email.Recipient, err)}, []byte(sentHeaders))
+			}
 			return email, err
 		}
+	} else if list != nil {
+		uc.distribute(email, *list, recipientDomain)
+	} else if !blocked {
+		uc.labelMail(mailId, email.Recipient, tag)
+		recipient := strings.Split(email.Recipient, "@")[0]
+		if !uc.forward(email, recipientDomain) {
+			err = uc.Repository.DeleteMail(recipient, []int{mailId}, recipientDomain.Name)
+			if err != nil {
+				log.Printf("ERROR: Unable to delete forwarded mail: %v\n", err)
+			}
+		} else {
+			uc.mailReceived(recipient, email)
+		}
+		uc.autoReply(email, recipientDomain)
 	}
 
+	uc.fireWebhooks(mail.WebhookPayload{Event: mail.WebhookSent, Owner: owner, Mail: webhookMail(email)})
 	return email, nil
 }
 
-func (uc *MailUseCase) DeleteMails(owner string, mailIds []int) error{
-	err := uc.Repository.DeleteMail(owner, mailIds, uc.Config.MailDomain)
-	if err != nil {
-		return err
+// PreviewEmail renders the body with the signature as SendEmail would, listing markup removed by sanitizer
+func (uc *MailUseCase) PreviewEmail(owner string, email mail.Mail) (mail.Preview, error) {
+	if !email.NoSignature {
+		if email.Alias != "" {
+			domain, err := uc.Repository.GetUserDomain(owner)
+			if err != nil {
+				return mail.Preview{}, err
+			}
+			email.Alias, err = uc.ownAlias(owner, email.Alias, domain)
+			if err != nil {
+				return mail.Preview{}, err
+			}
+		}
+		err := uc.appendSignature(owner, &email)
+		if err != nil {
+			return mail.Preview{}, err
+		}
 	}
-	return nil
+
+	body, stripped := markdownRenderer.RenderWithReport(email.Body, "")
+	return mail.Preview{Html: body, Stripped: stripped}, nil
 }
 
-func (uc *MailUseCase) GetFolders(owner int) ([]mail.Folder, error) {
-	folders, err := uc.Repository.GetFolders(owner)
+func (uc *MailUseCase) ReceiveEmail(email mail.Mail) error {
+	domain, isLocal, err := uc.localDomain(email.Recipient)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return folders, nil
-}
-
-func (uc *MailUseCase) CreateFolder(owner int, folderName string) (mail.Folder, error) {
-	folder, err := uc.Repository.CreateFolder(owner, folderName)
+	if !isLocal {
+		return mail.InvalidEmailError{"recipient domain is not served"}
+	}
+	recipient, tag, list, err := uc.resolveRecipient(email.Recipient, domain)
 	if err != nil {
-		return mail.Folder{}, err
+		return err
 	}
-	return folder, nil
-}
+	email.Recipient = recipient
 
-func (uc *MailUseCase) UpdateFolderPutDialogue(owner string, folderId int, dialogueId int) error {
-	err := uc.Repository.AddDialogueToFolder(owner, folderId, dialogueId)
+	owner := ""
+	if list == nil {
+		owner = strings.Split(recipient, "@")[0]
+	}
+	err = uc.checkSender(owner, email.Sender)
 	if err != nil {
+		if _, ok := err.(mail.SenderBlockedError); ok {
+			// rejecting senders is done by SMTP server before the mail is accepted
+			log.Printf("INFO: Mail from %s to %s dropped: %v\n", email.Sender, recipient, err)
+			return nil
+		}
 		return err
 	}
+
+	if list != nil {
+		err = checkListSender(*list, email, false)
+		if err != nil {
+			// rejecting senders is done by SMTP server before the mail is accepted
+			log.Printf("INFO: Mail from %s to %s dropped: %v\n", email.Sender, recipient, err)
+			return nil
+		}
+		uc.distribute(email, *list, domain)
+		return nil
+	}
+
+	uc.processReport(email, domain)
+
+	if uc.forward(email, domain) {
+		mailId, err := uc.Repository.AddMail(email, []string{email.Recipient})
+		if err != nil {
+			return err
+		}
+		uc.labelMail(mailId, email.Recipient, tag)
+		email.Id = mailId
+		uc.mailReceived(owner, email)
+	}
+
+	uc.autoReply(email, domain)
 	return nil
 }
 
-func (uc *MailUseCase) UpdateFolderName(owner, folderId int, folderName string) (mail.Folder, error) {
-	folder, err := uc.Repository.UpdateFolderName(owner, folderId, folderName)
+// resolveRecipient maps alias, user+tag or unknown (for catch-all) address of the local domain to the owner address.
+// Returns owner address and tag from sub-addressing or the distribution list if address belongs to it
+func (uc *MailUseCase) resolveRecipient(address string, domain mail.Domain) (string, string, *mail.DistributionList, error) {
+	local, tag := strings.Split(address, "@")[0], ""
+	if plusIndex := strings.Index(local, "+"); plusIndex >= 0 {
+		local, tag = local[:plusIndex], strings.ToLower(local[plusIndex+1:])
+		if !validators.ValidateLabel(tag) {
+			tag = ""
+		}
+	}
+
+	owner, err := uc.Repository.GetAddressOwner(local, domain.Name)
 	if err != nil {
-		return mail.Folder{}, err
+		if _, ok := err.(common.InvalidUserError); !ok {
+			return "", "", nil, err
+		}
+		list, listErr := uc.Repository.GetDistributionList(local, domain.Name)
+		if listErr == nil {
+			return list.Address(), "", &list, nil
+		}
+		if _, ok := listErr.(mail.InvalidEmailError); !ok {
+			return "", "", nil, listErr
+		}
+		if !domain.CatchAll.Valid {
+			return "", "", nil, err
+		}
+		owner = domain.CatchAll.String
 	}
-	return folder, nil
+	return owner + "@" + domain.Name, tag, nil, nil
 }
 
-func (uc *MailUseCase) DeleteFolder(ownerName string, owner, folderId int) error {
-	err := uc.Repository.ShiftToMainFolderDialogues(ownerName, folderId)
-	if err != nil {
-		return err
+// ownAlias checks that alias belongs to owner and returns its full address
+func (uc *MailUseCase) ownAlias(owner string, alias string, domain mail.Domain) (string, error) {
+	splitted := strings.Split(alias, "@")
+	if len(splitted) > 2 || (len(splitted) == 2 && !strings.EqualFold(splitted[1], domain.Name)) {
+		return "", mail.InvalidEmailError{"invalid alias"}
 	}
-	err = uc.Repository.DeleteFolder(owner, folderId)
+
+	aliasOwner, err := uc.Repository.GetAddressOwner(splitted[0], domain.Name)
 	if err != nil {
-		return err
+		if _, ok := err.(common.InvalidUserError); ok {
+			return "", mail.InvalidEmailError{"alias doesn't exist"}
+		}
+		return "", err
+	}
+	if !strings.EqualFold(aliasOwner, owner) {
+		return "", mail.InvalidEmailError{"alias doesn't belong to the user"}
+	}
+	if strings.EqualFold(splitted[0], owner) {
+		return "", nil
+	}
+	return splitted[0] + "@" + domain.Name, nil
+}
+
+func (uc *MailUseCase) labelMail(mailId int, owner string, label string) {
+	if label == "" {
+		return
+	}
+	err := uc.Repository.AddMailLabel(mailId, owner, label)
+	if err != nil {
+		log.Printf("ERROR: Unable to label mail %d: %v\n", mailId, err)
+	}
+}
+
+func (uc *MailUseCase) forwardedMessage(email mail.Mail) []byte {
+	trace := mail.ForwardTraceHeader + ": " + email.Recipient + "\r\n"
+	if len(email.Raw) > 0 {
+		return append([]byte(trace), email.Raw...)
+	}
+	return []byte(fmt.Sprintf(
+		"From: <%s>\r\nTo: %s\r\nContent-Type: text/html\r\nSubject: %s\r\n%s%s\r\n%s\r\n",
+		email.Sender,
+		email.Recipient,
+		email.Subject,
+		trace,
+		utils.FormatHeaders(email.Headers),
+		email.Body,
+	))
+}
+
+// forward sends mail to the verified forwarding address of the recipient.
+// Returns false if the recipient doesn't want to keep a local copy of the mail
+func (uc *MailUseCase) forward(email mail.Mail, domain mail.Domain) bool {
+	recipient := strings.Split(email.Recipient, "@")
+	if len(recipient) != 2 {
+		return true
+	}
+
+	forwarding, err := uc.Repository.GetForwarding(recipient[0])
+	if err != nil {
+		log.Printf("ERROR: Unable to get forwarding of %s: %v\n", recipient[0], err)
+		return true
+	}
+	if !forwarding.Enabled || !forwarding.Verified || !forwarding.Matches(email) {
+		return true
+	}
+
+	trace := strings.Split(email.Headers[mail.ForwardTraceHeader], ",")
+	for _, hop := range trace {
+		hop = strings.TrimSpace(hop)
+		if strings.EqualFold(hop, email.Recipient) || strings.EqualFold(hop, forwarding.Address) {
+			log.Printf("WARN: Forwarding loop detected for %s\n", email.Recipient)
+			return true
+		}
+	}
+	if len(trace) >= mail.MaxForwardHops {
+		log.Printf("WARN: Too many forwarding hops for %s\n", email.Recipient)
+		return true
+	}
+
+	err = uc.relay(email, forwarding.Address, domain)
+	if err != nil {
+		log.Printf("WARN: Unable to forward mail to %s: %v\n", forwarding.Address, err)
+		return true
+	}
+	return forwarding.KeepCopy
+}
+
+// relay sends mail received by the local address of the domain to the external address
+func (uc *MailUseCase) relay(email mail.Mail, to string, domain mail.Domain) error {
+	// rewrite external sender, so relayed mail passes SPF of the target server
+	envelopeFrom := strings.Trim(email.Sender, "<>")
+	if envelopeFrom != "" && !strings.HasSuffix(strings.ToLower(envelopeFrom), "@"+strings.ToLower(domain.Name)) {
+		var err error
+		envelopeFrom, err = utils.SRSForward(envelopeFrom, domain.Name, uc.Config.SrsSecret)
+		if err != nil {
+			envelopeFrom = email.Recipient
+		}
+	}
+
+	return smtpRelayMail(envelopeFrom, to, uc.forwardedMessage(email), uc.dkimKey(domain))
+}
+
+// distribute delivers copy of the mail sent to the list to every member of the list
+func (uc *MailUseCase) distribute(email mail.Mail, list mail.DistributionList, domain mail.Domain) {
+	listAddress := list.Address()
+	trace := strings.Split(email.Headers[mail.ForwardTraceHeader], ",")
+	for _, hop := range trace {
+		if strings.EqualFold(strings.TrimSpace(hop), listAddress) {
+			log.Printf("WARN: Distribution list loop detected for %s\n", listAddress)
+			return
+		}
+	}
+	if len(trace) >= mail.MaxForwardHops {
+		log.Printf("WARN: Too many forwarding hops for %s\n", listAddress)
+		return
+	}
+
+	listHeaders := make(map[string]string, len(email.Headers)+1)
+	for key, value := range email.Headers {
+		listHeaders[key] = value
+	}
+	listHeaders["List-Id"] = fmt.Sprintf("<%s.%s>", list.Name, list.Domain)
+	localHeaders := make(map[string]string, len(listHeaders)+1)
+	for key, value := range listHeaders {
+		localHeaders[key] = value
+	}
+	if localHeaders[mail.ForwardTraceHeader] == "" {
+		localHeaders[mail.ForwardTraceHeader] = listAddress
+	} else {
+		localHeaders[mail.ForwardTraceHeader] += ", " + listAddress
+	}
+
+	email.Id = 0
+	email.Recipient = listAddress
+	for _, member := range list.Members {
+		_, isLocal, err := uc.localDomain(member)
+		if err != nil {
+			log.Printf("ERROR: Unable to check domain of %s: %v\n", member, err)
+			continue
+		}
+
+		memberMail := email
+		if isLocal {
+			memberMail.Recipient = member
+			memberMail.Headers = localHeaders
+			memberMail.List = listAddress
+			memberMail.DeletedBySender = true
+			err = uc.ReceiveEmail(memberMail)
+		} else {
+			memberMail.Headers = listHeaders
+			err = uc.relay(memberMail, member, domain)
+		}
+		if err != nil {
+			log.Printf("WARN: Unable to deliver mail from %s to %s: %v\n", listAddress, member, err)
+		}
+	}
+}
+
+// isAutomatedMail reports whether replying to the mail may cause a loop or spam a list
+func isAutomatedMail(email mail.Mail) bool {
+	sender := strings.Trim(email.Sender, "<>")
+	if sender == "" || strings.HasPrefix(strings.ToLower(sender), "mailer-daemon@") {
+		return true
+	}
+
+	autoSubmitted := strings.ToLower(strings.TrimSpace(email.Headers["Auto-Submitted"]))
+	if autoSubmitted != "" && autoSubmitted != "no" {
+		return true
+	}
+	for _, header := range []string{"List-Id", "List-Unsubscribe", "List-Post"} {
+		if email.Headers[header] != "" {
+			return true
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(email.Headers["Precedence"])) {
+	case "bulk", "list", "junk":
+		return true
+	}
+	return false
+}
+
+// autoReply sends vacation reply once per sender per vacation reply interval
+func (uc *MailUseCase) autoReply(email mail.Mail, domain mail.Domain) {
+	recipient := strings.Split(email.Recipient, "@")
+	if len(recipient) != 2 || email.Sender == email.Recipient {
+		return
+	}
+	if isAutomatedMail(email) {
+		return
+	}
+
+	vacation, err := uc.Repository.GetVacation(recipient[0])
+	if err != nil {
+		log.Printf("ERROR: Unable to get vacation of %s: %v\n", recipient[0], err)
+		return
+	}
+	if !vacation.IsActive(time.Now()) {
+		return
+	}
+
+	if vacation.OnlyContacts {
+		isContact, err := uc.Repository.IsContact(email.Recipient, email.Sender)
+		if err != nil {
+			log.Printf("ERROR: Unable to check contact of %s: %v\n", recipient[0], err)
+			return
+		}
+		if !isContact {
+			return
+		}
+	}
+
+	interval := time.Duration(vacation.ReplyInterval) * 24 * time.Hour
+	replied, err := uc.Repository.VacationReplied(recipient[0], email.Sender, interval)
+	if err != nil {
+		log.Printf("ERROR: Unable to check vacation replies of %s: %v\n", recipient[0], err)
+		return
+	}
+	if replied {
+		return
+	}
+
+	// the reply is saved before sending, so the failed one isn't retried on every next mail
+	err = uc.Repository.SaveVacationReply(recipient[0], email.Sender)
+	if err != nil {
+		log.Printf("ERROR: Unable to save vacation reply: %v\n", err)
+		return
+	}
+
+	subject := vacation.Subject
+	if subject == "" {
+		subject = "Auto: " + email.Subject
+	}
+	reply := mail.Mail{
+		Sender:    email.Recipient,
+		Recipient: strings.Trim(email.Sender, "<>"),
+		Subject:   bluemonday.StrictPolicy().Sanitize(subject),
+		Body:      markdownRenderer.Render(vacation.Body, ""),
+		Headers: map[string]string{
+			"Auto-Submitted": "auto-replied",
+		},
+		NoSignature: true,
+	}
+	// the reply isn't limited as the mails sent by the user and doesn't hold receiving of the mail
+	runAsync(func() {
+		uc.sendVacationReply(reply, domain)
+	})
+}
+
+// sendVacationReply stores the reply into the mailboxes and relays it to the external sender
+func (uc *MailUseCase) sendVacationReply(reply mail.Mail, domain mail.Domain) {
+	_, isInternal, err := uc.localDomain(reply.Recipient)
+	if err != nil {
+		log.Printf("ERROR: Unable to check domain of %s: %v\n", reply.Recipient, err)
+		return
+	}
+	dialogueOwners := []string{reply.Sender}
+	if isInternal {
+		dialogueOwners = append(dialogueOwners, reply.Recipient)
+	}
+	mailId, err := uc.Repository.AddMail(reply, dialogueOwners)
+	if err != nil {
+		log.Printf("ERROR: Unable to store vacation reply to %s: %v\n", reply.Recipient, err)
+		return
+	}
+	reply.Id = mailId
+
+	owner := strings.Split(reply.Sender, "@")[0]
+	if isInternal {
+		uc.mailReceived(strings.Split(reply.Recipient, "@")[0], reply)
+	} else {
+		headers := map[string]string{"Message-ID": localMessageId(mailId, domain.Name)}
+		for key, value := range reply.Headers {
+			headers[key] = value
+		}
+		err = smtpSendMail(reply.Sender, reply.Recipient, reply.Subject, reply.Body, headers, uc.dkimKey(domain))
+		if err != nil {
+			log.Printf("WARN: Unable to send vacation reply to %s: %v\n", reply.Recipient, err)
+			err = uc.Repository.UpdateMailStatus(mailId, mail.MailStatusFailed)
+			if err != nil {
+				log.Printf("ERROR: Unable to change mail status!\n")
+			}
+			return
+		}
+	}
+	uc.fireWebhooks(mail.WebhookPayload{Event: mail.WebhookSent, Owner: owner, Mail: webhookMail(reply)})
+}
+
+func (uc *MailUseCase) DeleteMails(owner string, mailIds []int) error{
+	domain, err := uc.Repository.GetUserDomain(owner)
+	if err != nil {
+		return err
+	}
+	err = uc.Repository.DeleteMail(owner, mailIds, domain.Name)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// bulkActions lists actions supported for each target of the bulk operation
+var bulkActions = map[string][]string{
+	mail.BulkMails: {
+		mail.BulkRead, mail.BulkUnread, mail.BulkStar, mail.BulkUnstar, mail.BulkLabel, mail.BulkDelete, mail.BulkRestore,
+	},
+	mail.BulkDialogues: {
+		mail.BulkRead, mail.BulkUnread, mail.BulkMove, mail.BulkLabel, mail.BulkStar, mail.BulkUnstar,
+		mail.BulkArchive, mail.BulkUnarchive, mail.BulkDelete,
+	},
+}
+
+// Bulk applies the action to every listed mail or dialogue of the owner at once, items which don't exist
+// are reported in the results without failing the others. Read receipts aren't sent, as mails marked
+// read from the list weren't opened
+func (uc *MailUseCase) Bulk(ownerName string, owner int, bulk mail.Bulk) ([]mail.BulkResult, error) {
+	actions, ok := bulkActions[bulk.Target]
+	if !ok {
+		return nil, mail.InvalidEmailError{"target must be mails or dialogues"}
+	}
+	supported := false
+	for _, action := range actions {
+		if action == bulk.Action {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return nil, mail.InvalidEmailError{fmt.Sprintf("action %q isn't supported for %s", bulk.Action, bulk.Target)}
+	}
+	if len(bulk.Ids) == 0 || len(bulk.Ids) > mail.MaxBulkItems {
+		return nil, mail.InvalidEmailError{fmt.Sprintf("from 1 to %d ids are allowed", mail.MaxBulkItems)}
+	}
+	bulk.Label = strings.TrimSpace(bulk.Label)
+	if bulk.Action == mail.BulkLabel && !validators.ValidateLabel(bulk.Label) {
+		return nil, mail.InvalidEmailError{"invalid label"}
+	}
+	if bulk.Action == mail.BulkMove && bulk.Folder != 0 {
+		folders, err := uc.Repository.GetFolders(owner)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := ownFolder(folders, bulk.Folder); !ok {
+			return nil, mail.InvalidEmailError{"folder doesn't exist"}
+		}
+	}
+
+	domain, err := uc.Repository.GetUserDomain(ownerName)
+	if err != nil {
+		return nil, err
+	}
+	var results []mail.BulkResult
+	if bulk.Target == mail.BulkMails {
+		results, err = uc.Repository.BulkMails(ownerName, domain.Name, bulk)
+	} else {
+		results, err = uc.Repository.BulkDialogues(ownerName, domain.Name, bulk)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if bulk.Action == mail.BulkRead {
+		read := make(map[string]bool)
+		for _, result := range results {
+			if result.Ok && !read[result.Other] {
+				read[result.Other] = true
+				uc.fireWebhooks(mail.WebhookPayload{Event: mail.WebhookRead, Owner: ownerName, Dialogue: result.Other})
+			}
+		}
+	}
+	return results, nil
+}
+
+// GetFolders returns folders of the mailbox followed by smart folders with live unread counts,
+// ownerName and owner are username and id of the same mailbox
+func (uc *MailUseCase) GetFolders(ownerName string, owner int) ([]mail.Folder, error) {
+	folders, err := uc.Repository.GetFolders(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	smartFolders, err := uc.Repository.GetSmartFolders(ownerName)
+	if err != nil {
+		return nil, err
+	}
+	if len(smartFolders) == 0 {
+		return folders, nil
+	}
+	domain, err := uc.Repository.GetUserDomain(ownerName)
+	if err != nil {
+		return nil, err
+	}
+	queries := make([]mail.SmartQuery, 0, len(smartFolders))
+	for _, smartFolder := range smartFolders {
+		queries = append(queries, smartFolder.SmartQuery)
+	}
+	unread, err := uc.Repository.CountSmartFoldersUnread(ownerName, ownerName+"@"+domain.Name, queries)
+	if err != nil {
+		return nil, err
+	}
+	// smart folders are positioned after the regular ones
+	regular := len(folders)
+	for i, smartFolder := range smartFolders {
+		folders = append(folders, mail.Folder{
+			Id:         smartFolder.Id,
+			FolderName: smartFolder.Name,
+			Owner:      owner,
+			Unread:     unread[i],
+			Position:   regular + i,
+			Smart:      &smartFolders[i].SmartQuery,
+		})
+	}
+	return folders, nil
+}
+
+// GetFoldersPage pages folders in the order of GetFolders, regular folders by position and smart ones after them
+func (uc *MailUseCase) GetFoldersPage(ownerName string, owner int, page mail.Page) (mail.FoldersPage, error) {
+	folders, err := uc.GetFolders(ownerName, owner)
+	if err != nil {
+		return mail.FoldersPage{}, err
+	}
+	selected := make([]mail.Folder, 0, page.Limit+1)
+	for i := range folders {
+		// backward page is selected from the end like pages of dialogues
+		folder := folders[i]
+		if page.Backward() {
+			folder = folders[len(folders)-1-i]
+		}
+		if page.Cursor != nil {
+			// whether the folder follows the cursor in the paging direction
+			follows := folderCursorBefore(*page.Cursor, folderCursor(folder))
+			if page.Backward() {
+				follows = folderCursorBefore(folderCursor(folder), *page.Cursor)
+			}
+			if !follows {
+				continue
+			}
+		}
+		selected = append(selected, folder)
+		if len(selected) > page.Limit {
+			break
+		}
+	}
+	length, info := pageResult(page, selected, len(selected), func(i int) mail.Cursor {
+		return folderCursor(selected[i])
+	})
+	return mail.FoldersPage{Folders: selected[:length], PageInfo: info}, nil
+}
+
+func folderCursor(folder mail.Folder) mail.Cursor {
+	return mail.Cursor{Position: folder.Position, Smart: folder.Smart != nil, Id: folder.Id}
+}
+
+// folderCursorBefore compares positions of folders in the list
+func folderCursorBefore(a mail.Cursor, b mail.Cursor) bool {
+	if a.Smart != b.Smart {
+		return !a.Smart
+	}
+	if a.Position != b.Position {
+		return a.Position < b.Position
+	}
+	return a.Id < b.Id
+}
+
+// ownFolder returns the folder if it belongs to the owner
+func ownFolder(folders []mail.Folder, folderId int) (mail.Folder, bool) {
+	for _, folder := range folders {
+		if folder.Id == folderId {
+			return folder, true
+		}
+	}
+	return mail.Folder{}, false
+}
+
+// isInSubtree reports whether the folder is the root or one of its subfolders
+func isInSubtree(folders []mail.Folder, root int, folderId int) bool {
+	parents := make(map[int]int, len(folders))
+	for _, folder := range folders {
+		parents[folder.Id] = folder.Parent
+	}
+	// the depth can't exceed the number of folders, it also stops on cycles
+	for i := 0; folderId != 0 && i <= len(folders); i++ {
+		if folderId == root {
+			return true
+		}
+		folderId = parents[folderId]
+	}
+	return false
+}
+
+func (uc *MailUseCase) CreateFolder(owner int, folderName string, parentId int) (mail.Folder, error) {
+	if parentId != 0 {
+		folders, err := uc.Repository.GetFolders(owner)
+		if err != nil {
+			return mail.Folder{}, err
+		}
+		if _, ok := ownFolder(folders, parentId); !ok {
+			return mail.Folder{}, mail.InvalidEmailError{"parent folder doesn't exist"}
+		}
+	}
+	folder, err := uc.Repository.CreateFolder(owner, folderName, parentId)
+	if err != nil {
+		return mail.Folder{}, err
+	}
+	return folder, nil
+}
+
+func (uc *MailUseCase) UpdateFolderPutDialogue(owner string, folderId int, dialogueId int) error {
+	err := uc.Repository.AddDialogueToFolder(owner, folderId, dialogueId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (uc *MailUseCase) UpdateFolderName(owner, folderId int, folderName string) (mail.Folder, error) {
+	folder, err := uc.Repository.UpdateFolderName(owner, folderId, folderName)
+	if err != nil {
+		return mail.Folder{}, err
+	}
+	return folder, nil
+}
+
+// MoveFolder puts the folder with its subfolders into the parent, 0 is the top level,
+// position orders the folder among the new siblings
+func (uc *MailUseCase) MoveFolder(owner, folderId int, parentId int, position int) (mail.Folder, error) {
+	folders, err := uc.Repository.GetFolders(owner)
+	if err != nil {
+		return mail.Folder{}, err
+	}
+	folder, ok := ownFolder(folders, folderId)
+	if !ok {
+		return mail.Folder{}, mail.InvalidEmailError{"folder doesn't exist"}
+	}
+	if parentId != 0 {
+		if _, ok := ownFolder(folders, parentId); !ok {
+			return mail.Folder{}, mail.InvalidEmailError{"parent folder doesn't exist"}
+		}
+		// the repository checks it again under lock, the folders may be moved concurrently
+		if isInSubtree(folders, folderId, parentId) {
+			return mail.Folder{}, mail.InvalidEmailError{"folder can't be moved into itself or its subfolder"}
+		}
+	}
+
+	err = uc.Repository.MoveFolder(owner, folderId, parentId, position)
+	if err != nil {
+		return mail.Folder{}, err
+	}
+	folder.Parent = parentId
+	folder.Position = position
+	return folder, nil
+}
+
+// DeleteFolder deletes the folder with its subfolders, their dialogues are moved to the main folder
+func (uc *MailUseCase) DeleteFolder(ownerName string, owner, folderId int) error {
+	err := uc.Repository.ShiftToMainFolderDialogues(ownerName, folderId)
+	if err != nil {
+		return err
+	}
+	err = uc.Repository.DeleteFolder(owner, folderId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func normalizeSmartFolder(owner string, folder mail.SmartFolder) (mail.SmartFolder, error) {
+	folder.Owner = owner
+	folder.Name = strings.TrimSpace(folder.Name)
+	folder.SenderDomain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(folder.SenderDomain)), "@")
+	folder.Label = strings.TrimSpace(folder.Label)
+	if folder.Name == "" {
+		return mail.SmartFolder{}, mail.InvalidEmailError{"empty folder name"}
+	}
+	if folder.SenderDomain != "" && !validators.ValidateDomain(folder.SenderDomain) {
+		return mail.SmartFolder{}, mail.InvalidEmailError{"invalid sender domain"}
+	}
+	if folder.Label != "" && !validators.ValidateLabel(folder.Label) {
+		return mail.SmartFolder{}, mail.InvalidEmailError{"invalid label"}
+	}
+	if folder.After != nil && folder.Before != nil && !folder.Before.After(*folder.After) {
+		return mail.SmartFolder{}, mail.InvalidEmailError{"empty date range"}
+	}
+	return folder, nil
+}
+
+func (uc *MailUseCase) CreateSmartFolder(owner string, folder mail.SmartFolder) (mail.SmartFolder, error) {
+	folder, err := normalizeSmartFolder(owner, folder)
+	if err != nil {
+		return mail.SmartFolder{}, err
+	}
+	return uc.Repository.CreateSmartFolder(folder)
+}
+
+func (uc *MailUseCase) UpdateSmartFolder(owner string, folder mail.SmartFolder) (mail.SmartFolder, error) {
+	folder, err := normalizeSmartFolder(owner, folder)
+	if err != nil {
+		return mail.SmartFolder{}, err
+	}
+	return uc.Repository.UpdateSmartFolder(folder)
+}
+
+func (uc *MailUseCase) DeleteSmartFolder(owner string, folderId int) error {
+	return uc.Repository.DeleteSmartFolder(owner, folderId)
+}
+
+// GetSmartFolderDialogues evaluates the saved query, pages are the same as of GetDialogues
+func (uc *MailUseCase) GetSmartFolderDialogues(username string, folderId int, page mail.Page) (mail.DialoguesPage, error) {
+	folder, err := uc.Repository.GetSmartFolder(username, folderId)
+	if err != nil {
+		return mail.DialoguesPage{}, err
+	}
+	domain, err := uc.Repository.GetUserDomain(username)
+	if err != nil {
+		return mail.DialoguesPage{}, err
+	}
+	dialogues, err := uc.Repository.GetSmartFolderDialogues(username, username+"@"+domain.Name, folder.SmartQuery, page)
+	if err != nil {
+		return mail.DialoguesPage{}, err
+	}
+	return dialoguesPage(page, dialogues), nil
+}
+
+func (uc *MailUseCase) GetVacation(owner string) (mail.Vacation, error) {
+	vacation, err := uc.Repository.GetVacation(owner)
+	if err != nil {
+		return mail.Vacation{}, err
+	}
+	return vacation, nil
+}
+
+// sendReadReceipts saves read date of the mails asking for receipt and sends MDNs to external senders
+func (uc *MailUseCase) sendReadReceipts(owner string, domain mail.Domain, other string) {
+	allow, err := uc.Repository.GetReadReceipts(owner)
+	if err != nil {
+		log.Printf("ERROR: Unable to get read receipts setting of %s: %v\n", owner, err)
+		return
+	}
+	if !allow {
+		return
+	}
+
+	address := owner + "@" + domain.Name
+	read, err := uc.Repository.SaveReadReceipts(address, other)
+	if err != nil {
+		log.Printf("ERROR: Unable to save read receipts of %s: %v\n", address, err)
+		return
+	}
+	// the read mails aren't held by relaying
+	runAsync(func() {
+		for _, email := range read {
+			if !email.NotifyAddress.Valid {
+				// internal sender sees read date of the mail
+				continue
+			}
+			mdn := utils.FormatMDN(utils.MDN{
+				From:              address,
+				To:                email.NotifyAddress.String,
+				Subject:           email.Subject,
+				OriginalMessageId: email.MessageId.String,
+				ReportingDomain:   domain.Name,
+			})
+			// MDN is sent with null envelope sender to not get notifications back
+			err := smtpRelayMail("", email.NotifyAddress.String, mdn, uc.dkimKey(domain))
+			if err != nil {
+				log.Printf("WARN: Unable to send MDN to %s: %v\n", email.NotifyAddress.String, err)
+			}
+		}
+	})
+}
+
+// BounceEmail queues DSN to the external sender of the accepted mail that couldn't be stored for some of the recipients,
+// the mail failed for all of them is rejected by SMTP server instead
+func (uc *MailUseCase) BounceEmail(email mail.Mail, failures map[string]error) error {
+	sender := strings.Trim(email.Sender, "<>")
+	if len(failures) == 0 || sender == "" || strings.HasPrefix(strings.ToLower(sender), "mailer-daemon@") {
+		// no bounces for bounces
+		return nil
+	}
+	_, isLocal, err := uc.localDomain(sender)
+	if err != nil {
+		return err
+	}
+	if isLocal {
+		// local senders are told about failures by SendEmail
+		return nil
+	}
+
+	recipients := make([]string, 0, len(failures))
+	for recipient := range failures {
+		recipients = append(recipients, recipient)
+	}
+	sort.Strings(recipients)
+
+	domain, isLocal, err := uc.localDomain(recipients[0])
+	if err != nil {
+		return err
+	}
+	if !isLocal {
+		return mail.InvalidEmailError{"recipient domain is not served"}
+	}
+
+	report := make([]utils.ReportRecipient, 0, len(recipients))
+	for _, recipient := range recipients {
+		failed := utils.ReportRecipient{
+			Address:    recipient,
+			Action:     "failed",
+			Status:     "5.3.0",
+			Diagnostic: "smtp; 550 5.3.0 Mail couldn't be stored",
+		}
+		switch failures[recipient].(type) {
+		case common.InvalidUserError, mail.InvalidEmailError:
+			failed.Status = "5.1.1"
+			failed.Diagnostic = "smtp; 550 5.1.1 Recipient doesn't exist"
+		}
+		report = append(report, failed)
+	}
+
+	dsn := utils.FormatDSN(utils.DSN{
+		From:            "MAILER-DAEMON@" + domain.Name,
+		To:              sender,
+		ReportingDomain: domain.Name,
+		Recipients:      report,
+		OriginalHeaders: utils.MessageHeaders(email.Raw),
+	})
+	// DSN is sent with null envelope sender to not get bounces back, the SMTP session isn't held by relaying
+	runAsync(func() {
+		err := smtpRelayMail("", sender, dsn, uc.dkimKey(domain))
+		if err != nil {
+			log.Printf("WARN: Unable to send DSN to %s: %v\n", sender, err)
+		}
+	})
+	return nil
+}
+
+// bounceToMailbox stores system mail about failed delivery into the sender's mailbox
+func (uc *MailUseCase) bounceToMailbox(address string, domain mail.Domain, recipients []utils.ReportRecipient, headers []byte) {
+	var body strings.Builder
+	body.WriteString("<p>Your message couldn't be delivered to the following recipients:</p>\n<ul>\n")
+	for _, recipient := range recipients {
+		fmt.Fprintf(&body, "<li>%s: %s</li>\n", html.EscapeString(recipient.Address), html.EscapeString(recipient.Diagnostic))
+	}
+	body.WriteString("</ul>\n<p>Original headers:</p>\n<pre>" + html.EscapeString(string(headers)) + "</pre>\n")
+
+	bounce := mail.Mail{
+		Sender:    "mailer-daemon@" + domain.Name,
+		Recipient: address,
+		Subject:   "Undelivered Mail Returned to Sender",
+		Body:      body.String(),
+	}
+	bounceId, err := uc.Repository.AddMail(bounce, []string{address})
+	if err != nil {
+		log.Printf("ERROR: Unable to store bounce for %s: %v\n", address, err)
+		return
+	}
+	bounce.Id = bounceId
+	uc.fireWebhooks(mail.WebhookPayload{Event: mail.WebhookBounced, Owner: strings.Split(address, "@")[0], Mail: webhookMail(bounce)})
+}
+
+var localMessageIdRegexp = regexp.MustCompile(`^<(\d+)@([^>]+)>$`)
+
+// processReport updates the sent mail reported by DSN or MDN addressed to its sender
+func (uc *MailUseCase) processReport(email mail.Mail, domain mail.Domain) {
+	report, ok := utils.ParseReport(email.Raw)
+	if !ok {
+		return
+	}
+	// only own Message-IDs point to the mails
+	matches := localMessageIdRegexp.FindStringSubmatch(report.OriginalMessageId)
+	if matches == nil || !strings.EqualFold(matches[2], domain.Name) {
+		return
+	}
+	mailId, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return
+	}
+
+	for _, recipient := range report.Recipients {
+		// the mail must have been sent to the reported recipient, not to the forwarding target
+		address := recipient.OriginalAddress()
+		switch {
+		case report.Type == utils.ReportDeliveryStatus && recipient.Action == "failed":
+			err = uc.Repository.UpdateDeliveryStatus(mailId, email.Recipient, address, mail.MailStatusFailed)
+		case report.Type == utils.ReportDisposition && report.Disposition == "displayed":
+			err = uc.Repository.SaveMailRead(mailId, email.Recipient, address)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("ERROR: Unable to process %s report for mail %d: %v\n", report.Type, mailId, err)
+		}
+	}
+}
+
+func (uc *MailUseCase) GetReadReceipts(owner string) (bool, error) {
+	return uc.Repository.GetReadReceipts(owner)
+}
+
+func (uc *MailUseCase) UpdateReadReceipts(owner string, allow bool) error {
+	return uc.Repository.UpdateReadReceipts(owner, allow)
+}
+
+func (uc *MailUseCase) UpdateVacation(owner string, vacation mail.Vacation) (mail.Vacation, error) {
+	if !vacation.EndDate.IsZero() && !vacation.EndDate.After(vacation.StartDate) {
+		return mail.Vacation{}, mail.InvalidEmailError{"vacation end date must be after start date"}
+	}
+	if vacation.ReplyInterval <= 0 {
+		vacation.ReplyInterval = mail.DefaultVacationReplyInterval
+	}
+
+	pStrict := bluemonday.StrictPolicy()
+	vacation.Subject = pStrict.Sanitize(vacation.Subject)
+	vacation.Owner = owner
+
+	vacation, err := uc.Repository.UpdateVacation(vacation)
+	if err != nil {
+		return mail.Vacation{}, err
+	}
+	return vacation, nil
+}
+
+func (uc *MailUseCase) GetForwarding(owner string) (mail.Forwarding, error) {
+	forwarding, err := uc.Repository.GetForwarding(owner)
+	if err != nil {
+		return mail.Forwarding{}, err
+	}
+	return forwarding, nil
+}
+
+func (uc *MailUseCase) UpdateForwarding(owner string, forwarding mail.Forwarding) (mail.Forwarding, error) {
+	forwarding.Address = strings.TrimSpace(forwarding.Address)
+	if strings.ContainsAny(forwarding.Address, "\r\n") {
+		return mail.Forwarding{}, mail.InvalidEmailError{"invalid forwarding address"}
+	}
+	parsed, err := netMail.ParseAddress(forwarding.Address)
+	if err != nil || parsed.Name != "" || parsed.Address != forwarding.Address {
+		return mail.Forwarding{}, mail.InvalidEmailError{"invalid forwarding address"}
+	}
+	_, isLocal, err := uc.localDomain(forwarding.Address)
+	if err != nil {
+		return mail.Forwarding{}, err
+	}
+	if isLocal {
+		return mail.Forwarding{}, mail.InvalidEmailError{"forwarding is allowed only to external addresses"}
+	}
+
+	current, err := uc.Repository.GetForwarding(owner)
+	if err != nil {
+		return mail.Forwarding{}, err
+	}
+
+	forwarding.Owner = owner
+	needsConfirmation := !current.Verified || !strings.EqualFold(current.Address, forwarding.Address)
+	if needsConfirmation {
+		token, err := common.GenerateSecureToken()
+		if err != nil {
+			return mail.Forwarding{}, err
+		}
+		forwarding.Verified = false
+		forwarding.Token = common.NullString{sql.NullString{String: token, Valid: true}}
+
+		// the new address is saved only when the confirmation reached it
+		domain, err := uc.Repository.GetUserDomain(owner)
+		if err != nil {
+			return mail.Forwarding{}, err
+		}
+		ownerEmail := owner + "@" + domain.Name
+		link := uc.Config.ApiUrl + "/email/forwarding/confirm?token=" + forwarding.Token.String
+		body := fmt.Sprintf(
+			"<p>%s wants to forward mail to this address.</p><p><a href=\"%s\">Confirm forwarding</a></p>",
+			ownerEmail,
+			link,
+		)
+		headers := map[string]string{"Auto-Submitted": "auto-generated"}
+		err = smtpSendMail(ownerEmail, forwarding.Address, "Confirm mail forwarding from "+ownerEmail, body, headers, uc.dkimKey(domain))
+		if err != nil {
+			return mail.Forwarding{}, err
+		}
+	} else {
+		forwarding.Verified = current.Verified
+		forwarding.Token = current.Token
+	}
+
+	return uc.Repository.UpdateForwarding(forwarding)
+}
+
+func (uc *MailUseCase) ConfirmForwarding(token string) error {
+	if token == "" {
+		return mail.InvalidEmailError{"invalid confirmation token"}
+	}
+	return uc.Repository.ConfirmForwarding(token)
+}
+
+func (uc *MailUseCase) GetAliases(owner string) ([]mail.Alias, error) {
+	aliases, err := uc.Repository.GetAliases(owner)
+	if err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+func (uc *MailUseCase) CreateAlias(owner string, alias string) (mail.Alias, error) {
+	domain, err := uc.Repository.GetUserDomain(owner)
+	if err != nil {
+		return mail.Alias{}, err
+	}
+	alias = strings.TrimSpace(alias)
+	if splitted := strings.Split(alias, "@"); len(splitted) == 2 && strings.EqualFold(splitted[1], domain.Name) {
+		alias = splitted[0]
+	}
+	if !validators.ValidateUsername(alias) {
+		return mail.Alias{}, mail.InvalidEmailError{"invalid alias"}
+	}
+
+	newAlias, err := uc.Repository.CreateAlias(owner, alias)
+	if err != nil {
+		return mail.Alias{}, err
+	}
+	return newAlias, nil
+}
+
+func (uc *MailUseCase) DeleteAlias(owner string, aliasId int) error {
+	err := uc.Repository.DeleteAlias(owner, aliasId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (uc *MailUseCase) GetMailboxes(username string) ([]mail.MailboxMember, error) {
+	mailboxes, err := uc.Repository.GetMailboxes(username)
+	if err != nil {
+		return nil, err
+	}
+	return mailboxes, nil
+}
+
+func (uc *MailUseCase) CreateMailbox(owner string, name string) (mail.MailboxMember, error) {
+	domain, err := uc.Repository.GetUserDomain(owner)
+	if err != nil {
+		return mail.MailboxMember{}, err
+	}
+	name = strings.TrimSuffix(strings.TrimSpace(name), "@"+domain.Name)
+	if !validators.ValidateUsername(name) {
+		return mail.MailboxMember{}, mail.InvalidEmailError{"invalid mailbox name"}
+	}
+
+	mailbox, err := uc.Repository.CreateMailbox(owner, name, domain.Name)
+	if err != nil {
+		return mail.MailboxMember{}, err
+	}
+	return mailbox, nil
+}
+
+// CheckMailboxAccess returns AccessDeniedError if the user is not a member of the shared mailbox
+func (uc *MailUseCase) CheckMailboxAccess(mailbox string, username string) error {
+	_, err := uc.Repository.GetMailboxMember(mailbox, username)
+	return err
+}
+
+// GetUserId returns id of the user or the shared mailbox, folders of the mailbox belong to it
+func (uc *MailUseCase) GetUserId(username string) (int, error) {
+	return uc.Repository.GetUserId(username)
+}
+
+func (uc *MailUseCase) checkMailboxAdmin(mailbox string, username string) error {
+	member, err := uc.Repository.GetMailboxMember(mailbox, username)
+	if err != nil {
+		return err
+	}
+	if !member.IsAdmin {
+		return mail.AccessDeniedError{"only mailbox admins can manage members"}
+	}
+	return nil
+}
+
+func (uc *MailUseCase) GetMailboxMembers(username string, mailbox string) ([]mail.MailboxMember, error) {
+	err := uc.CheckMailboxAccess(mailbox, username)
+	if err != nil {
+		return nil, err
+	}
+	members, err := uc.Repository.GetMailboxMembers(mailbox)
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (uc *MailUseCase) AddMailboxMember(username string, member mail.MailboxMember) error {
+	err := uc.checkMailboxAdmin(member.Mailbox, username)
+	if err != nil {
+		return err
+	}
+	return uc.Repository.AddMailboxMember(member)
+}
+
+// DeleteMailboxMember removes member from the mailbox, any member can leave the mailbox
+func (uc *MailUseCase) DeleteMailboxMember(username string, mailbox string, member string) error {
+	if !strings.EqualFold(username, member) {
+		err := uc.checkMailboxAdmin(mailbox, username)
+		if err != nil {
+			return err
+		}
+	}
+	return uc.Repository.DeleteMailboxMember(mailbox, member)
+}
+
+func (uc *MailUseCase) GetDistributionLists(owner string) ([]mail.DistributionList, error) {
+	lists, err := uc.Repository.GetDistributionLists(owner)
+	if err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (uc *MailUseCase) CreateDistributionList(owner string, name string) (mail.DistributionList, error) {
+	domain, err := uc.Repository.GetUserDomain(owner)
+	if err != nil {
+		return mail.DistributionList{}, err
+	}
+	name = strings.TrimSuffix(strings.TrimSpace(name), "@"+domain.Name)
+	if !validators.ValidateUsername(name) {
+		return mail.DistributionList{}, mail.InvalidEmailError{"invalid list name"}
+	}
+
+	list, err := uc.Repository.CreateDistributionList(mail.DistributionList{
+		Name:   name,
+		Domain: domain.Name,
+		Owner:  owner,
+	})
+	if err != nil {
+		return mail.DistributionList{}, err
+	}
+	return list, nil
+}
+
+func (uc *MailUseCase) DeleteDistributionList(owner string, listId int) error {
+	return uc.Repository.DeleteDistributionList(owner, listId)
+}
+
+func (uc *MailUseCase) UpdateListPosting(owner string, listId int, posting string) error {
+	posting = strings.ToLower(strings.TrimSpace(posting))
+	if posting != mail.ListPostMembers && posting != mail.ListPostLocal && posting != mail.ListPostAnyone {
+		return mail.InvalidEmailError{"unknown posting policy"}
+	}
+	return uc.Repository.UpdateListPosting(owner, listId, posting)
+}
+
+// checkListSender applies the posting policy of the list. Local senders are authenticated by SendEmail only,
+// mail received from other servers may have any sender
+func checkListSender(list mail.DistributionList, email mail.Mail, authenticated bool) error {
+	switch list.Posting {
+	case mail.ListPostAnyone:
+		return nil
+	case mail.ListPostLocal:
+		if authenticated {
+			return nil
+		}
+		return mail.SenderBlockedError{Message: "only local users may post to the list"}
+	}
+	if authenticated && strings.EqualFold(strings.Split(email.Sender, "@")[0], list.Owner) {
+		return nil
+	}
+	for _, member := range list.Members {
+		if strings.EqualFold(member, email.Sender) || (email.Alias != "" && strings.EqualFold(member, email.Alias)) {
+			return nil
+		}
+	}
+	return mail.SenderBlockedError{Message: "only members may post to the list"}
+}
+
+func (uc *MailUseCase) AddListMember(owner string, listId int, address string) error {
+	address = strings.TrimSpace(address)
+	splitted := strings.Split(address, "@")
+	if len(splitted) != 2 || splitted[0] == "" || splitted[1] == "" {
+		return mail.InvalidEmailError{"invalid member address"}
+	}
+	return uc.Repository.AddListMember(owner, listId, address)
+}
+
+func (uc *MailUseCase) DeleteListMember(owner string, listId int, address string) error {
+	return uc.Repository.DeleteListMember(owner, listId, strings.TrimSpace(address))
+}
+
+func (uc *MailUseCase) isAdmin(username string) bool {
+	for _, admin := range uc.Config.Admins {
+		if strings.EqualFold(admin, username) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeSenderPattern accepts address, domain, "@domain" or "*.domain"
+func normalizeSenderPattern(pattern string) (string, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	pattern = strings.TrimPrefix(pattern, "*.")
+	pattern = strings.TrimPrefix(pattern, "@")
+	splitted := strings.Split(pattern, "@")
+	if len(splitted) > 2 || (len(splitted) == 2 && splitted[0] == "") || !validators.ValidateDomain(splitted[len(splitted)-1]) {
+		return "", mail.InvalidEmailError{"pattern should be an address or a domain"}
+	}
+	return pattern, nil
+}
+
+// senderPatterns lists patterns matching the sender from the most specific: address, its domain and parent domains
+func senderPatterns(sender string) []string {
+	sender = strings.ToLower(strings.Trim(sender, "<> "))
+	splitted := strings.Split(sender, "@")
+	if len(splitted) != 2 || splitted[1] == "" {
+		return nil
+	}
+	patterns := []string{sender}
+	labels := strings.Split(splitted[1], ".")
+	for i := range labels {
+		patterns = append(patterns, strings.Join(labels[i:], "."))
+	}
+	return patterns
+}
+
+func patternRank(patterns []string, pattern string) int {
+	for i, p := range patterns {
+		if strings.EqualFold(p, pattern) {
+			return i
+		}
+	}
+	return len(patterns)
+}
+
+// checkSender returns SenderBlockedError if the owner doesn't accept mail from the sender.
+// Global blocklist can't be overridden by the owner, otherwise the most specific rule of the owner wins.
+// Only global blocklist is checked if owner is empty
+func (uc *MailUseCase) checkSender(owner string, sender string) error {
+	patterns := senderPatterns(sender)
+	if len(patterns) == 0 {
+		return nil
+	}
+	rules, err := uc.Repository.FindSenderRules(owner, patterns)
+	if err != nil {
+		return err
+	}
+
+	var ownerRule *mail.SenderRule
+	ownerRank, globalBlocked := len(patterns), false
+	for i, rule := range rules {
+		if !rule.Owner.Valid {
+			globalBlocked = globalBlocked || !rule.Allow
+			continue
+		}
+		if rank := patternRank(patterns, rule.Pattern); rank < ownerRank {
+			ownerRule, ownerRank = &rules[i], rank
+		}
+	}
+
+	if globalBlocked {
+		return mail.SenderBlockedError{"sender is blocked", false}
+	}
+	if ownerRule != nil {
+		if ownerRule.Allow {
+			return nil
+		}
+		drop, err := uc.Repository.GetDropBlocked(owner)
+		if err != nil {
+			return err
+		}
+		return mail.SenderBlockedError{"sender is blocked by the recipient", drop}
+	}
+	return nil
+}
+
+// CheckIncomingSender is called before accepting mail from another server to reject blocked senders
+// and unknown recipients early, so nothing is bounced for them after the mail is accepted
+func (uc *MailUseCase) CheckIncomingSender(sender string, recipient string) error {
+	domain, isLocal, err := uc.localDomain(recipient)
+	if err != nil || !isLocal {
+		return err
+	}
+	recipient, _, list, err := uc.resolveRecipient(recipient, domain)
+	if err != nil {
+		return err
+	}
+	owner := ""
+	if list == nil {
+		owner = strings.Split(recipient, "@")[0]
+	} else {
+		err = checkListSender(*list, mail.Mail{Sender: sender}, false)
+		if err != nil {
+			return err
+		}
+	}
+	return uc.checkSender(owner, sender)
+}
+
+func (uc *MailUseCase) GetSenderFilter(owner string) (mail.SenderFilter, error) {
+	drop, err := uc.Repository.GetDropBlocked(owner)
+	if err != nil {
+		return mail.SenderFilter{}, err
+	}
+	rules, err := uc.Repository.GetSenderRules(owner)
+	if err != nil {
+		return mail.SenderFilter{}, err
+	}
+	return mail.SenderFilter{
+		DropBlocked: drop,
+		Rules:       rules,
+	}, nil
+}
+
+func (uc *MailUseCase) UpdateSenderFilter(owner string, dropBlocked bool) error {
+	return uc.Repository.UpdateDropBlocked(owner, dropBlocked)
+}
+
+func (uc *MailUseCase) AddSenderRule(owner string, rule mail.SenderRule) (mail.SenderRule, error) {
+	pattern, err := normalizeSenderPattern(rule.Pattern)
+	if err != nil {
+		return mail.SenderRule{}, err
+	}
+	return uc.Repository.AddSenderRule(mail.SenderRule{
+		Owner:   common.NullString{sql.NullString{String: owner, Valid: true}},
+		Pattern: pattern,
+		Allow:   rule.Allow,
+	})
+}
+
+func (uc *MailUseCase) DeleteSenderRule(owner string, ruleId int) error {
+	return uc.Repository.DeleteSenderRule(owner, ruleId)
+}
+
+func (uc *MailUseCase) GetGlobalBlocklist(username string) ([]mail.SenderRule, error) {
+	if !uc.isAdmin(username) {
+		return nil, mail.AccessDeniedError{"only admins can manage global blocklist"}
+	}
+	return uc.Repository.GetSenderRules("")
+}
+
+func (uc *MailUseCase) AddGlobalBlock(username string, pattern string) (mail.SenderRule, error) {
+	if !uc.isAdmin(username) {
+		return mail.SenderRule{}, mail.AccessDeniedError{"only admins can manage global blocklist"}
+	}
+	pattern, err := normalizeSenderPattern(pattern)
+	if err != nil {
+		return mail.SenderRule{}, err
+	}
+	return uc.Repository.AddSenderRule(mail.SenderRule{Pattern: pattern})
+}
+
+func (uc *MailUseCase) DeleteGlobalBlock(username string, ruleId int) error {
+	if !uc.isAdmin(username) {
+		return mail.AccessDeniedError{"only admins can manage global blocklist"}
+	}
+	return uc.Repository.DeleteSenderRule("", ruleId)
+}
+
+// localMessageId is Message-ID of the mail sent from the installation
+func localMessageId(mailId int, domain string) string {
+	return fmt.Sprintf("<%d@%s>", mailId, domain)
+}
+
+func originalMessageId(original mail.DialogueEmail) string {
+	if original.MessageId.Valid && original.MessageId.String != "" {
+		return original.MessageId.String
+	}
+	splitted := strings.Split(original.Sender, "@")
+	return localMessageId(original.Id, splitted[len(splitted)-1])
+}
+
+// threadReferences returns References header of the answer to the original mail
+func threadReferences(original mail.DialogueEmail) string {
+	return strings.TrimSpace(original.References.String + " " + originalMessageId(original))
+}
+
+// prefixSubject adds Re: or Fwd: unless the subject already has one of the prefixes
+func prefixSubject(subject string, prefix string, known ...string) string {
+	lowered := strings.ToLower(strings.TrimSpace(subject))
+	for _, p := range append(known, prefix) {
+		if strings.HasPrefix(lowered, strings.ToLower(p)) {
+			return subject
+		}
+	}
+	return prefix + " " + subject
+}
+
+var htmlTag = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+
+// quoteOriginal quotes body of the original mail as html: plain text is quoted with Markdown >, html with blockquote
+func quoteOriginal(intro string, body string) string {
+	if htmlTag.MatchString(body) {
+		return "<p>" + html.EscapeString(intro) + "</p>\n<blockquote>" + body + "</blockquote>\n"
+	}
+	var quote strings.Builder
+	quote.WriteString(html.EscapeString(intro) + "\n\n")
+	for _, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+		quote.WriteString("> " + html.EscapeString(line) + "\n")
+	}
+	return markdownRenderer.ToHTML(quote.String())
+}
+
+func (uc *MailUseCase) getOriginal(owner string, mailId int) (mail.DialogueEmail, string, error) {
+	domain, err := uc.Repository.GetUserDomain(owner)
+	if err != nil {
+		return mail.DialogueEmail{}, "", err
+	}
+	address := owner + "@" + domain.Name
+	original, err := uc.Repository.GetMail(address, mailId)
+	if err != nil {
+		return mail.DialogueEmail{}, "", err
+	}
+	return original, address, nil
+}
+
+// ReplyEmail answers the mail. Reply-all answers the list the mail came through or every other participant.
+// Mail has the only recipient, so one reply is sent per recipient
+func (uc *MailUseCase) ReplyEmail(owner string, mailId int, reply mail.Mail, all bool) ([]mail.Mail, error) {
+	original, address, err := uc.getOriginal(owner, mailId)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := make([]string, 0, 2)
+	if all && original.List.Valid && original.List.String != "" {
+		recipients = append(recipients, original.List.String)
+	} else {
+		for _, participant := range []string{original.Sender, original.Recipient} {
+			if !strings.EqualFold(participant, address) && !containsFold(recipients, participant) {
+				recipients = append(recipients, participant)
+			}
+		}
+		if !all && len(recipients) > 1 {
+			recipients = recipients[:1]
+		}
+	}
+	if len(recipients) == 0 {
+		// reply to the mail sent to oneself
+		recipients = append(recipients, address)
+	}
+
+	intro := fmt.Sprintf("On %s, %s wrote:", original.Received_date.Format("Mon, 2 Jan 2006 15:04"), original.Sender)
+	replies := make([]mail.Mail, 0, len(recipients))
+	for _, recipient := range recipients {
+		sent, err := uc.SendEmail(mail.Mail{
+			Sender:      owner,
+			Recipient:   recipient,
+			Alias:       reply.Alias,
+			Signature:   reply.Signature,
+			NoSignature: reply.NoSignature,
+			Subject:     prefixSubject(original.Subject, "Re:"),
+			Body:        reply.Body,
+			Quote:       quoteOriginal(intro, original.Body),
+			References:  threadReferences(original),
+			Headers: map[string]string{
+				"In-Reply-To": originalMessageId(original),
+				"References":  threadReferences(original),
+			},
+		})
+		if err != nil {
+			return replies, err
+		}
+		replies = append(replies, sent)
+	}
+	return replies, nil
+}
+
+// ForwardEmail sends the mail to the new recipient with the optional comment above it
+func (uc *MailUseCase) ForwardEmail(owner string, mailId int, forward mail.Mail) (mail.Mail, error) {
+	original, _, err := uc.getOriginal(owner, mailId)
+	if err != nil {
+		return mail.Mail{}, err
+	}
+	// only raw message has the attachments, it's attached to the forward as is
+	raw, err := uc.Repository.GetMailRaw(original.Id)
+	if err != nil {
+		return mail.Mail{}, err
+	}
+
+	intro := fmt.Sprintf(
+		"---------- Forwarded message ----------\nFrom: %s\nDate: %s\nSubject: %s\nTo: %s",
+		original.Sender,
+		original.Received_date.Format("Mon, 2 Jan 2006 15:04"),
+		original.Subject,
+		original.Recipient,
+	)
+	var quote string
+	if htmlTag.MatchString(original.Body) {
+		// stored html is sanitized on receiving, but the quote is sent on as is, so it's sanitized again
+		quote = "<p>" + strings.ReplaceAll(html.EscapeString(intro), "\n", "<br>") + "</p>\n" +
+			markdownRenderer.OutputPolicy.Sanitize(original.Body)
+	} else {
+		quote = "<p>" + strings.ReplaceAll(html.EscapeString(intro), "\n", "<br>") + "</p>\n" +
+			markdownRenderer.ToHTML(html.EscapeString(original.Body))
+	}
+
+	// forward starts a new thread, References only links it to the original
+	return uc.SendEmail(mail.Mail{
+		Sender:      owner,
+		Recipient:   forward.Recipient,
+		Alias:       forward.Alias,
+		Signature:   forward.Signature,
+		NoSignature: forward.NoSignature,
+		Subject:     prefixSubject(original.Subject, "Fwd:", "Fw:"),
+		Body:        forward.Body,
+		Quote:       quote,
+		References:  threadReferences(original),
+		Headers: map[string]string{
+			"References": threadReferences(original),
+		},
+		Forwarded: raw,
+	})
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendSignature adds chosen or default signature of the sending address to the Markdown body
+func (uc *MailUseCase) appendSignature(owner string, email *mail.Mail) error {
+	var signature mail.Signature
+	var err error
+	if email.Signature != 0 {
+		signature, err = uc.Repository.GetSignature(owner, email.Signature)
+	} else {
+		address := owner
+		if email.Alias != "" {
+			address = strings.Split(email.Alias, "@")[0]
+		}
+		signature, err = uc.Repository.GetDefaultSignature(owner, address)
+	}
+	if err != nil {
+		return err
+	}
+	if signature.Body != "" {
+		email.Body = strings.TrimRight(email.Body, "\n") + "\n\n" + signature.Body
+	}
+	return nil
+}
+
+// normalizeSignature validates signature and turns addresses it's default for into own local parts
+func (uc *MailUseCase) normalizeSignature(owner string, signature mail.Signature) (mail.Signature, error) {
+	signature.Owner = owner
+	signature.Name = strings.TrimSpace(signature.Name)
+	signature.Body = strings.TrimSpace(signature.Body)
+	if signature.Name == "" || signature.Body == "" {
+		return mail.Signature{}, mail.InvalidEmailError{"empty signature name or body"}
+	}
+
+	defaultFor := make(common.StringList, 0, len(signature.DefaultFor))
+	if len(signature.DefaultFor) > 0 {
+		domain, err := uc.Repository.GetUserDomain(owner)
+		if err != nil {
+			return mail.Signature{}, err
+		}
+		for _, address := range signature.DefaultFor {
+			address = strings.TrimSpace(address)
+			localPart := owner
+			if address != "" && !strings.EqualFold(address, owner) {
+				alias, err := uc.ownAlias(owner, address, domain)
+				if err != nil {
+					return mail.Signature{}, err
+				}
+				if alias != "" {
+					localPart = strings.Split(alias, "@")[0]
+				}
+			}
+			if !containsFold(defaultFor, localPart) {
+				defaultFor = append(defaultFor, localPart)
+			}
+		}
+	}
+	signature.DefaultFor = defaultFor
+	return signature, nil
+}
+
+func (uc *MailUseCase) GetSignatures(owner string) ([]mail.Signature, error) {
+	return uc.Repository.GetSignatures(owner)
+}
+
+func (uc *MailUseCase) CreateSignature(owner string, signature mail.Signature) (mail.Signature, error) {
+	signature, err := uc.normalizeSignature(owner, signature)
+	if err != nil {
+		return mail.Signature{}, err
+	}
+	return uc.Repository.CreateSignature(signature)
+}
+
+func (uc *MailUseCase) UpdateSignature(owner string, signature mail.Signature) (mail.Signature, error) {
+	signature, err := uc.normalizeSignature(owner, signature)
+	if err != nil {
+		return mail.Signature{}, err
+	}
+	return uc.Repository.UpdateSignature(signature)
+}
+
+func (uc *MailUseCase) DeleteSignature(owner string, signatureId int) error {
+	return uc.Repository.DeleteSignature(owner, signatureId)
+}
+
+func normalizeTemplate(owner string, template mail.Template) (mail.Template, error) {
+	template.Owner = owner
+	template.Name = strings.TrimSpace(template.Name)
+	template.Subject = strings.TrimSpace(template.Subject)
+	if template.Name == "" || strings.TrimSpace(template.Body) == "" {
+		return mail.Template{}, mail.InvalidEmailError{"empty template name or body"}
+	}
+	return template, nil
+}
+
+func (uc *MailUseCase) GetTemplates(owner string) ([]mail.Template, error) {
+	return uc.Repository.GetTemplates(owner)
+}
+
+func (uc *MailUseCase) CreateTemplate(owner string, template mail.Template) (mail.Template, error) {
+	template, err := normalizeTemplate(owner, template)
+	if err != nil {
+		return mail.Template{}, err
+	}
+	return uc.Repository.CreateTemplate(template)
+}
+
+func (uc *MailUseCase) UpdateTemplate(owner string, template mail.Template) (mail.Template, error) {
+	template, err := normalizeTemplate(owner, template)
+	if err != nil {
+		return mail.Template{}, err
+	}
+	return uc.Repository.UpdateTemplate(template)
+}
+
+func (uc *MailUseCase) DeleteTemplate(owner string, templateId int) error {
+	return uc.Repository.DeleteTemplate(owner, templateId)
+}
+
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([a-zA-Z]+(?:\.[a-zA-Z]+)?)\s*\}\}`)
+
+// expandPlaceholders replaces known {{placeholders}}, unknown ones are kept as is
+func expandPlaceholders(text string, values map[string]string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := templatePlaceholder.FindStringSubmatch(placeholder)[1]
+		if value, ok := values[strings.ToLower(name)]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// RenderTemplate expands placeholders of the template for the recipient and previews it as the mail would be sent
+func (uc *MailUseCase) RenderTemplate(owner string, templateId int, recipient string) (mail.RenderedTemplate, error) {
+	template, err := uc.Repository.GetTemplate(owner, templateId)
+	if err != nil {
+		return mail.RenderedTemplate{}, err
+	}
+	domain, err := uc.Repository.GetUserDomain(owner)
+	if err != nil {
+		return mail.RenderedTemplate{}, err
+	}
+	senderName, err := uc.Repository.GetFullName(owner)
+	if err != nil {
+		return mail.RenderedTemplate{}, err
+	}
+
+	recipient = strings.ToLower(strings.TrimSpace(recipient))
+	recipientName := ""
+	if recipient != "" {
+		recipientName, err = uc.Repository.GetContactName(owner, recipient)
+		if err != nil {
+			return mail.RenderedTemplate{}, err
+		}
+		if recipientName == "" {
+			recipientName = strings.Split(recipient, "@")[0]
+		}
+	}
+
+	values := map[string]string{
+		"recipient.name":  recipientName,
+		"recipient.email": recipient,
+		"sender.name":     senderName,
+		"sender.email":    owner + "@" + domain.Name,
+		"date":            time.Now().Format("2 Jan 2006"),
+	}
+	rendered := mail.RenderedTemplate{
+		Subject:  expandPlaceholders(template.Subject, values),
+		Markdown: expandPlaceholders(template.Body, values),
+	}
+
+	rendered.Html = markdownRenderer.Render(rendered.Markdown, "")
+	return rendered, nil
+}
+
+// mailReceived notifies the owner about mail stored into their mailbox
+func (uc *MailUseCase) mailReceived(owner string, email mail.Mail) {
+	uc.fireWebhooks(mail.WebhookPayload{Event: mail.WebhookReceived, Owner: owner, Mail: webhookMail(email)})
+	uc.sendPush(owner, email)
+}
+
+var webhookEvents = []string{mail.WebhookReceived, mail.WebhookSent, mail.WebhookBounced, mail.WebhookRead}
+
+func (uc *MailUseCase) GetWebhooks(owner string) ([]mail.Webhook, error) {
+	return uc.Repository.GetWebhooks(owner)
+}
+
+func (uc *MailUseCase) CreateWebhook(owner string, webhook mail.Webhook) (mail.Webhook, error) {
+	webhook.Owner = owner
+	webhook.URL = strings.TrimSpace(webhook.URL)
+	hookUrl, err := url.Parse(webhook.URL)
+	if err != nil || (hookUrl.Scheme != "http" && hookUrl.Scheme != "https") || hookUrl.Host == "" {
+		return mail.Webhook{}, mail.InvalidEmailError{"webhook url must be absolute http or https url"}
+	}
+	if utils.CheckPublicHost(hookUrl.Host) != nil {
+		return mail.Webhook{}, mail.InvalidEmailError{"webhook url must not point to internal address"}
+	}
+
+	events := make(common.StringList, 0, len(webhook.Events))
+	for _, event := range webhook.Events {
+		event = strings.ToLower(strings.TrimSpace(event))
+		if !containsFold(webhookEvents, event) {
+			return mail.Webhook{}, mail.InvalidEmailError{"unknown webhook event " + event}
+		}
+		if !containsFold(events, event) {
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		return mail.Webhook{}, mail.InvalidEmailError{"webhook must subscribe to some events"}
+	}
+	webhook.Events = events
+
+	if webhook.Secret == "" {
+		webhook.Secret, err = common.GenerateSecureToken()
+		if err != nil {
+			return mail.Webhook{}, err
+		}
+	}
+	return uc.Repository.CreateWebhook(webhook)
+}
+
+func (uc *MailUseCase) DeleteWebhook(owner string, webhookId int) error {
+	return uc.Repository.DeleteWebhook(owner, webhookId)
+}
+
+func (uc *MailUseCase) GetWebhookDeliveries(owner string, webhookId int, amount int) ([]mail.WebhookDelivery, error) {
+	_, err := uc.Repository.GetWebhook(owner, webhookId)
+	if err != nil {
+		return nil, err
+	}
+	return uc.Repository.GetWebhookDeliveries(webhookId, amount)
+}
+
+// TestWebhook delivers test event once regardless of the subscribed events
+func (uc *MailUseCase) TestWebhook(owner string, webhookId int) (mail.WebhookDelivery, error) {
+	webhook, err := uc.Repository.GetWebhook(owner, webhookId)
+	if err != nil {
+		return mail.WebhookDelivery{}, err
+	}
+	payload := mail.WebhookPayload{Event: mail.WebhookTest, Owner: owner, Time: time.Now()}
+	return uc.deliverWebhook(webhook, payload, 1), nil
+}
+
+func webhookMail(email mail.Mail) *mail.WebhookMail {
+	return &mail.WebhookMail{
+		Id:        email.Id,
+		Sender:    email.Sender,
+		Recipient: email.Recipient,
+		Subject:   email.Subject,
+	}
+}
+
+func signWebhookPayload(secret string, payload []byte) string {
+	signer := hmac.New(sha256.New, []byte(secret))
+	signer.Write(payload)
+	return hex.EncodeToString(signer.Sum(nil))
+}
+
+// fireWebhooks delivers the event to webhooks of its owner subscribed to it in background,
+// failed deliveries are saved and retried by RetryWebhooks
+func (uc *MailUseCase) fireWebhooks(payload mail.WebhookPayload) {
+	webhooks, err := uc.Repository.GetWebhooks(payload.Owner)
+	if err != nil {
+		log.Printf("ERROR: Unable to get webhooks of %s: %v\n", payload.Owner, err)
+		return
+	}
+	payload.Time = time.Now()
+	for _, webhook := range webhooks {
+		if !webhook.Subscribed(payload.Event) {
+			continue
+		}
+		webhook := webhook
+		runAsync(func() {
+			delivery := uc.deliverWebhook(webhook, payload, 1)
+			if delivery.Succeeded() || len(webhookRetryDelays) == 0 {
+				return
+			}
+			body, err := json.Marshal(payload)
+			if err != nil {
+				log.Printf("ERROR: Unable to marshal webhook %d payload: %v\n", webhook.Id, err)
+				return
+			}
+			err = uc.Repository.AddWebhookRetry(mail.WebhookRetry{
+				Webhook:     webhook.Id,
+				Payload:     string(body),
+				Attempt:     2,
+				NextAttempt: time.Now().Add(webhookRetryDelays[0]),
+			})
+			if err != nil {
+				log.Printf("ERROR: Unable to save webhook %d retry: %v\n", webhook.Id, err)
+			}
+		})
+	}
+}
+
+// RetryWebhooks makes the next attempts of failed deliveries which are due,
+// deliveries are given up after the last of webhookRetryDelays
+func (uc *MailUseCase) RetryWebhooks() error {
+	retries, err := uc.Repository.TakeWebhookRetries(webhookRetryLease, webhookRetryBatch)
+	if err != nil {
+		return err
+	}
+	for _, retry := range retries {
+		var payload mail.WebhookPayload
+		err = json.Unmarshal([]byte(retry.Payload), &payload)
+		if err != nil {
+			log.Printf("ERROR: Invalid payload of webhook %d retry: %v\n", retry.Webhook, err)
+			uc.deleteWebhookRetry(retry)
+			continue
+		}
+		webhook, err := uc.Repository.GetWebhook(payload.Owner, retry.Webhook)
+		if err != nil {
+			if _, ok := err.(mail.InvalidEmailError); !ok {
+				log.Printf("ERROR: Unable to get webhook %d: %v\n", retry.Webhook, err)
+				continue
+			}
+			// the webhook was deleted
+			uc.deleteWebhookRetry(retry)
+			continue
+		}
+
+		delivery := uc.deliverWebhook(webhook, payload, retry.Attempt)
+		if delivery.Succeeded() || retry.Attempt > len(webhookRetryDelays) {
+			uc.deleteWebhookRetry(retry)
+			continue
+		}
+		retry.NextAttempt = time.Now().Add(webhookRetryDelays[retry.Attempt-1])
+		retry.Attempt++
+		err = uc.Repository.UpdateWebhookRetry(retry)
+		if err != nil {
+			log.Printf("ERROR: Unable to save webhook %d retry: %v\n", retry.Webhook, err)
+		}
+	}
+	return nil
+}
+
+func (uc *MailUseCase) deleteWebhookRetry(retry mail.WebhookRetry) {
+	err := uc.Repository.DeleteWebhookRetry(retry.Id)
+	if err != nil {
+		log.Printf("ERROR: Unable to delete webhook %d retry: %v\n", retry.Webhook, err)
+	}
+}
+
+// deliverWebhook posts the signed payload and saves the attempt to the delivery log
+func (uc *MailUseCase) deliverWebhook(webhook mail.Webhook, payload mail.WebhookPayload, attempt int) mail.WebhookDelivery {
+	delivery := mail.WebhookDelivery{
+		Webhook:      webhook.Id,
+		Event:        payload.Event,
+		Attempt:      attempt,
+		DeliveryDate: time.Now(),
+	}
+	statusCode, err := postWebhook(webhook, payload)
+	delivery.StatusCode = statusCode
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	errDb := uc.Repository.AddWebhookDelivery(delivery)
+	if errDb != nil {
+		log.Printf("ERROR: Unable to save webhook %d delivery: %v\n", webhook.Id, errDb)
+	}
+	return delivery
+}
+
+func postWebhook(webhook mail.Webhook, payload mail.WebhookPayload) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(mail.WebhookEventHeader, payload.Event)
+	request.Header.Set(mail.WebhookSignatureHeader, "sha256="+signWebhookPayload(webhook.Secret, body))
+	response, err := webhookClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, errors.New("unexpected response status " + response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+// vapidKeys returns false if Web Push isn't configured
+func (uc *MailUseCase) vapidKeys() (utils.VapidKeys, bool) {
+	if uc.Config.VapidPublicKey == "" || uc.Config.VapidPrivateKey == "" {
+		return utils.VapidKeys{}, false
+	}
+	keys, err := utils.ParseVapidKeys(uc.Config.VapidPublicKey, uc.Config.VapidPrivateKey)
+	if err != nil {
+		log.Printf("ERROR: Invalid VAPID keys: %v\n", err)
+		return utils.VapidKeys{}, false
+	}
+	return keys, true
+}
+
+// CreateExport starts writing the archive of the selected dialogues and folders, or all of them, in background
+func (uc *MailUseCase) CreateExport(owner string, export mail.Export) (mail.Export, error) {
+	if export.Format == "" {
+		export.Format = mail.ExportMbox
+	}
+	if export.Format != mail.ExportMbox && export.Format != mail.ExportEml {
+		return mail.Export{}, mail.InvalidEmailError{"unknown export format"}
+	}
+	addresses, err := uc.Repository.GetOwnerAddresses(owner)
+	if err != nil {
+		return mail.Export{}, err
+	}
+	export.Owner = owner
+	export.Status = mail.ExportPending
+	export.Token, err = common.GenerateSecureToken()
+	if err != nil {
+		return mail.Export{}, err
+	}
+	export, err = uc.Repository.CreateExport(export)
+	if err != nil {
+		return mail.Export{}, err
+	}
+	runAsync(func() {
+		uc.runExport(export, addresses)
+	})
+	return export, nil
+}
+
+func (uc *MailUseCase) GetExports(owner string) ([]mail.Export, error) {
+	exports, err := uc.Repository.GetExports(owner)
+	if err != nil {
+		return nil, err
+	}
+	for i := range exports {
+		exports[i] = uc.withDownloadURL(exports[i])
+	}
+	return exports, nil
+}
+
+func (uc *MailUseCase) GetExport(owner string, exportId int) (mail.Export, error) {
+	export, err := uc.Repository.GetExport(owner, exportId)
+	if err != nil {
+		return mail.Export{}, err
+	}
+	return uc.withDownloadURL(export), nil
+}
+
+// GetExportFile returns the export and path to its archive by the token of the download link
+func (uc *MailUseCase) GetExportFile(token string) (mail.Export, string, error) {
+	export, err := uc.Repository.GetExportByToken(token)
+	if err != nil {
+		return mail.Export{}, "", err
+	}
+	if export.Status == mail.ExportExpired {
+		return mail.Export{}, "", mail.InvalidEmailError{"download link has expired"}
+	}
+	if export.Status != mail.ExportDone {
+		return mail.Export{}, "", mail.InvalidEmailError{"export isn't finished"}
+	}
+	if export.ExpiresDate == nil || export.ExpiresDate.Before(time.Now()) {
+		os.Remove(uc.exportPath(export))
+		return mail.Export{}, "", mail.InvalidEmailError{"download link has expired"}
+	}
+	return export, uc.exportPath(export), nil
+}
+
+// InterruptExports marks exports stopped with the server as failed and removes their partial archives
+func (uc *MailUseCase) InterruptExports() error {
+	exports, err := uc.Repository.InterruptExports()
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		os.Remove(uc.exportPath(export))
+	}
+	return nil
+}
+
+// RemoveExpiredExports removes archives whose download links have expired, it is run periodically by the server
+func (uc *MailUseCase) RemoveExpiredExports() error {
+	exports, err := uc.Repository.ExpireExports(time.Now())
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		os.Remove(uc.exportPath(export))
+	}
+	if len(exports) > 0 {
+		log.Printf("INFO: %d expired exports are removed\n", len(exports))
+	}
+	return nil
+}
+
+func (uc *MailUseCase) withDownloadURL(export mail.Export) mail.Export {
+	if export.Status == mail.ExportDone && export.ExpiresDate != nil && export.ExpiresDate.After(time.Now()) {
+		export.DownloadURL = uc.Config.ApiUrl + mail.ExportDownloadPath + export.Token
+	}
+	return export
+}
+
+// exportPath is named by the token, so archives can't be guessed in the storage
+func (uc *MailUseCase) exportPath(export mail.Export) string {
+	return filepath.Join(uc.Config.ExportStoragePath, export.Token+filepath.Ext(export.FileName()))
+}
+
+// runExport writes the archive and saves the result, failed export leaves no file
+func (uc *MailUseCase) runExport(export mail.Export, addresses []string) {
+	err := uc.writeExport(&export, addresses)
+	if err != nil {
+		log.Printf("ERROR: Unable to export mails of %s: %v\n", export.Owner, err)
+		os.Remove(uc.exportPath(export))
+		export.Status = mail.ExportFailed
+		export.Error = "unable to export mails"
+	} else {
+		expires := time.Now().Add(mail.ExportLifetime)
+		export.Status = mail.ExportDone
+		export.ExpiresDate = &expires
+	}
+	err = uc.Repository.UpdateExportProgress(export)
+	if err != nil {
+		log.Printf("ERROR: Unable to save export %d: %v\n", export.Id, err)
+	}
+}
+
+// writeExport walks mails of the export by id, so mails received meanwhile are exported too
+func (uc *MailUseCase) writeExport(export *mail.Export, addresses []string) error {
+	filter := mail.MailFilter{DialogueIds: export.Dialogues, FolderIds: export.Folders}
+	total, err := uc.Repository.CountMailboxEmails(export.Owner, addresses, filter)
+	if err != nil {
+		return err
+	}
+	export.Total = total
+	export.Status = mail.ExportRunning
+	err = uc.Repository.UpdateExportProgress(*export)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(uc.exportPath(*export))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var archive *zip.Writer
+	if export.Format == mail.ExportEml {
+		archive = zip.NewWriter(file)
+	}
+
+	filter.OrderById = true
+	filter.Limit = exportPageSize
+	for {
+		emails, err := uc.Repository.GetMailboxEmails(export.Owner, addresses, filter)
+		if err != nil {
+			return err
+		}
+		if len(emails) == 0 {
+			break
+		}
+		for _, email := range emails {
+			message := utils.FormatMessage(utils.StoredMessage{
+				From:       email.Sender,
+				To:         email.Recipient,
+				Subject:    email.Subject,
+				Date:       email.Received_date,
+				MessageId:  email.HeaderMessageId(),
+				References: email.References.String,
+				Html:       email.Body,
+			})
+			if archive != nil {
+				var entry io.Writer
+				entry, err = archive.CreateHeader(&zip.FileHeader{
+					Name:     fmt.Sprintf("%d.eml", email.Id),
+					Method:   zip.Deflate,
+					Modified: email.Received_date,
+				})
+				if err == nil {
+					_, err = entry.Write(message)
+				}
+			} else {
+				err = utils.WriteMbox(file, email.Sender, email.Received_date, message)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		filter.AfterId = emails[len(emails)-1].Id
+		export.Processed += len(emails)
+		if export.Processed > export.Total {
+			export.Total = export.Processed
+		}
+		err = uc.Repository.UpdateExportProgress(*export)
+		if err != nil {
+			return err
+		}
+	}
+	if archive != nil {
+		err = archive.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// ImportArchive saves the uploaded mbox or zip of message files and imports it in background
+func (uc *MailUseCase) ImportArchive(owner string, ownerId int, source string, archive io.Reader) (mail.Import, error) {
+	if source == "" {
+		source = mail.ImportMbox
+	}
+	if source != mail.ImportMbox && source != mail.ImportEml {
+		return mail.Import{}, mail.InvalidEmailError{"unknown import source"}
+	}
+	mailImport := mail.Import{Owner: owner, Source: source, Status: mail.ExportPending}
+	token, err := common.GenerateSecureToken()
+	if err != nil {
+		return mail.Import{}, err
+	}
+	mailImport.Token = token
+
+	path := uc.importPath(mailImport)
+	file, err := os.Create(path)
+	if err != nil {
+		return mail.Import{}, err
+	}
+	written, err := io.Copy(file, io.LimitReader(archive, maxImportSize+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return mail.Import{}, err
+	}
+	if written > maxImportSize {
+		os.Remove(path)
+		return mail.Import{}, mail.InvalidEmailError{"archive is too large"}
+	}
+	if source == mail.ImportEml {
+		zipArchive, err := zip.OpenReader(path)
+		if err != nil {
+			os.Remove(path)
+			return mail.Import{}, mail.InvalidEmailError{"archive isn't a zip file"}
+		}
+		zipArchive.Close()
+	}
+
+	mailImport, err = uc.Repository.CreateImport(mailImport)
+	if err != nil {
+		os.Remove(path)
+		return mail.Import{}, err
+	}
+	runAsync(func() {
+		uc.runImport(mailImport, ownerId, "")
+	})
+	return mailImport, nil
+}
+
+// ImportIMAP checks the account and imports its mails in background
+func (uc *MailUseCase) ImportIMAP(owner string, ownerId int, account mail.IMAPAccount) (mail.Import, error) {
+	if account.Host == "" || account.Username == "" {
+		return mail.Import{}, mail.InvalidEmailError{"host and username are required"}
+	}
+	err := checkIMAPAccount(account.Host, account.Username, account.Password)
+	if err != nil {
+		return mail.Import{}, err
+	}
+	mailImport := mail.Import{
+		Owner:    owner,
+		Source:   mail.ImportIMAP,
+		Host:     account.Host,
+		Username: account.Username,
+		Status:   mail.ExportPending,
+	}
+	mailImport.Token, err = common.GenerateSecureToken()
+	if err != nil {
+		return mail.Import{}, err
+	}
+	mailImport, err = uc.Repository.CreateImport(mailImport)
+	if err != nil {
+		return mail.Import{}, err
+	}
+	runAsync(func() {
+		uc.runImport(mailImport, ownerId, account.Password)
+	})
+	return mailImport, nil
+}
+
+// ResumeImport continues the failed import after its last saved position, imap import needs the password again
+func (uc *MailUseCase) ResumeImport(owner string, ownerId int, importId int, password string) (mail.Import, error) {
+	mailImport, err := uc.Repository.GetImport(owner, importId)
+	if err != nil {
+		return mail.Import{}, err
+	}
+	if mailImport.Status != mail.ExportFailed {
+		return mail.Import{}, mail.InvalidEmailError{"only failed import can be resumed"}
+	}
+	if mailImport.Source == mail.ImportIMAP {
+		err = checkIMAPAccount(mailImport.Host, mailImport.Username, password)
+		if err != nil {
+			return mail.Import{}, err
+		}
+	} else if _, err := os.Stat(uc.importPath(mailImport)); err != nil {
+		return mail.Import{}, mail.InvalidEmailError{"uploaded archive doesn't exist anymore"}
+	}
+	mailImport.Status = mail.ExportPending
+	mailImport.Error = ""
+	err = uc.Repository.UpdateImportProgress(mailImport)
+	if err != nil {
+		return mail.Import{}, err
+	}
+	runAsync(func() {
+		uc.runImport(mailImport, ownerId, password)
+	})
+	return mailImport, nil
+}
+
+func (uc *MailUseCase) GetImports(owner string) ([]mail.Import, error) {
+	return uc.Repository.GetImports(owner)
+}
+
+func (uc *MailUseCase) GetImport(owner string, importId int) (mail.Import, error) {
+	return uc.Repository.GetImport(owner, importId)
+}
+
+// InterruptImports marks imports stopped with the server as failed, so they can be resumed
+func (uc *MailUseCase) InterruptImports() error {
+	return uc.Repository.InterruptImports()
+}
+
+// checkIMAPAccount logs in to the account, so wrong credentials are reported before the import starts
+func checkIMAPAccount(host string, username string, password string) error {
+	if utils.CheckPublicHost(host) != nil {
+		return mail.InvalidEmailError{"imap server must not be internal address"}
+	}
+	client, err := dialIMAP(host)
+	if err != nil {
+		// dial errors describe the server's network, so they are logged only
+		log.Printf("ERROR: Unable to connect to imap server %s: %v\n", host, err)
+		return mail.InvalidEmailError{"unable to connect to imap server"}
+	}
+	defer client.Logout()
+	err = client.Login(username, password)
+	if err != nil {
+		return mail.InvalidEmailError{"unable to log in to imap server: " + err.Error()}
+	}
+	return nil
+}
+
+// importPath is named by the token like the export archive
+func (uc *MailUseCase) importPath(mailImport mail.Import) string {
+	return filepath.Join(uc.Config.ImportStoragePath, mailImport.Token+"."+mailImport.Source)
+}
+
+// runImport imports mails and saves the result, the archive is kept until the import is done to resume it
+func (uc *MailUseCase) runImport(mailImport mail.Import, ownerId int, password string) {
+	err := uc.doImport(&mailImport, ownerId, password)
+	if err != nil {
+		log.Printf("ERROR: Unable to import mails of %s: %v\n", mailImport.Owner, err)
+		mailImport.Status = mail.ExportFailed
+		mailImport.Error = "unable to import mails"
+	} else {
+		mailImport.Status = mail.ExportDone
+		if mailImport.Source != mail.ImportIMAP {
+			os.Remove(uc.importPath(mailImport))
+		}
+	}
+	err = uc.Repository.UpdateImportProgress(mailImport)
+	if err != nil {
+		log.Printf("ERROR: Unable to save import %d: %v\n", mailImport.Id, err)
+	}
+}
+
+func (uc *MailUseCase) doImport(mailImport *mail.Import, ownerId int, password string) error {
+	domain, err := uc.Repository.GetUserDomain(mailImport.Owner)
+	if err != nil {
+		return err
+	}
+	aliases, err := uc.Repository.GetAliases(mailImport.Owner)
+	if err != nil {
+		return err
+	}
+	folders, err := uc.Repository.GetFolders(ownerId)
+	if err != nil {
+		return err
+	}
+	importer := &mailImporter{
+		uc:         uc,
+		mailImport: mailImport,
+		ownerId:    ownerId,
+		domain:     domain.Name,
+		address:    mailImport.Owner + "@" + domain.Name,
+		aliases:    make(map[string]string),
+		progress:   make(map[string]int),
+		folders:    folders,
+		dialogues:  make(map[string]int),
+	}
+	for _, alias := range aliases {
+		importer.aliases[strings.ToLower(alias.Alias+"@"+domain.Name)] = alias.Alias
+	}
+	if mailImport.Progress != "" {
+		err = json.Unmarshal([]byte(mailImport.Progress), &importer.progress)
+		if err != nil {
+			return err
+		}
+	}
+
+	mailImport.Status = mail.ExportRunning
+	err = uc.Repository.UpdateImportProgress(*mailImport)
+	if err != nil {
+		return err
+	}
+	switch mailImport.Source {
+	case mail.ImportMbox:
+		err = importer.importMbox(uc.importPath(*mailImport))
+	case mail.ImportEml:
+		err = importer.importEml(uc.importPath(*mailImport))
+	case mail.ImportIMAP:
+		err = importer.importIMAP(password)
+	}
+	// mails added before the error get their dialogues too
+	saveErr := importer.save()
+	if err != nil {
+		return err
+	}
+	return saveErr
+}
+
+// mailImporter adds messages of the running import to the mailbox
+type mailImporter struct {
+	uc         *MailUseCase
+	mailImport *mail.Import
+	ownerId    int
+	domain     string
+	address    string
+	aliases    map[string]string // alias by its address, messages from the owner and aliases are sent ones
+	progress   map[string]int    // last imported position by source folder
+	folders    []mail.Folder     // folders of the owner, created ones are appended
+	dialogues  map[string]int    // dialogues to rebuild by the other address, with the folder to put them in
+}
+
+// importMbox imports messages after the saved position in the file
+func (im *mailImporter) importMbox(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	position := 0
+	return utils.ReadMbox(file, func(message []byte) error {
+		position++
+		if position <= im.progress[""] {
+			return nil
+		}
+		return im.add(nil, "", position, message, true)
+	})
+}
+
+// importEml imports .eml files of the zip after the saved position, directories become folders
+func (im *mailImporter) importEml(path string) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	for i, file := range archive.File {
+		position := i + 1
+		if position <= im.progress[""] || file.FileInfo().IsDir() || !strings.EqualFold(filepath.Ext(file.Name), ".eml") {
+			continue
+		}
+		message, ok, err := readZipEntry(file)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			im.mailImport.Failed++
+			err = im.done("", position)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		var folder []string
+		if i := strings.LastIndex(file.Name, "/"); i > 0 {
+			folder = strings.Split(file.Name[:i], "/")
+		}
+		err = im.add(folder, "", position, message, true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readZipEntry reads the message from the archive, ok is false for messages over the size limit.
+// Sizes in the zip may lie, so the entry is read through the limit too
+func readZipEntry(file *zip.File) ([]byte, bool, error) {
+	if file.UncompressedSize64 > uint64(maxImportMessageSize) {
+		return nil, false, nil
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, false, err
+	}
+	defer reader.Close()
+	message, err := ioutil.ReadAll(io.LimitReader(reader, int64(maxImportMessageSize)+1))
+	if err != nil {
+		return nil, false, err
+	}
+	return message, len(message) <= maxImportMessageSize, nil
+}
+
+// importIMAP imports messages of each mailbox with uids after the saved one, seen flags are kept
+func (im *mailImporter) importIMAP(password string) error {
+	client, err := dialIMAP(im.mailImport.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Logout()
+	err = client.Login(im.mailImport.Username, password)
+	if err != nil {
+		return err
+	}
+	mailboxes, err := client.List()
+	if err != nil {
+		return err
+	}
+	for _, mailbox := range mailboxes {
+		folder, ok := importedFolder(mailbox)
+		if !ok {
+			continue
+		}
+		err = client.Examine(mailbox.Name)
+		if err != nil {
+			return err
+		}
+		uids, err := client.SearchAfter(im.progress[mailbox.Name])
+		if err != nil {
+			return err
+		}
+		for start := 0; start < len(uids); start += importBatchSize {
+			end := start + importBatchSize
+			if end > len(uids) {
+				end = len(uids)
+			}
+			messages, err := client.Fetch(uids[start:end])
+			if err != nil {
+				return err
+			}
+			sort.Slice(messages, func(i, j int) bool {
+				return messages[i].Uid < messages[j].Uid
+			})
+			for _, message := range messages {
+				err = im.add(folder, mailbox.Name, message.Uid, message.Raw, message.Seen)
+				if err != nil {
+					return err
+				}
+			}
+		}
 	}
 	return nil
 }
+
+// importedFolder maps the remote mailbox to the folder path, inbox and sent mails stay out of folders
+// and service mailboxes aren't imported
+func importedFolder(mailbox utils.IMAPMailbox) ([]string, bool) {
+	for _, attribute := range []string{`\Trash`, `\Junk`, `\Drafts`, `\All`, `\Flagged`, `\Important`} {
+		if mailbox.HasAttribute(attribute) {
+			return nil, false
+		}
+	}
+	if strings.EqualFold(mailbox.Name, "INBOX") || mailbox.HasAttribute(`\Sent`) {
+		return nil, true
+	}
+	folder := []string{mailbox.DisplayName()}
+	if mailbox.Delimiter != "" {
+		folder = strings.Split(folder[0], mailbox.Delimiter)
+	}
+	// subfolders of the inbox are imported to the top level
+	if len(folder) > 1 && strings.EqualFold(folder[0], "INBOX") {
+		folder = folder[1:]
+	}
+	return folder, true
+}
+
+// add imports the message at the position of the source folder, the progress is saved by batches.
+// The position is passed only after the message is stored, so the message failed to store is imported on resume
+func (im *mailImporter) add(folder []string, key string, position int, raw []byte, seen bool) error {
+	message, err := utils.ParseInboundMessage(raw)
+	if err != nil || message.From == "" {
+		im.mailImport.Failed++
+		return im.done(key, position)
+	}
+
+	date := message.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	alias, sent := im.aliases[message.From]
+	sent = sent || message.From == im.address
+	mails := make([]mail.Mail, 0)
+	if sent {
+		// the copy is hidden from local recipients, they have own mailboxes
+		read := false
+		for _, recipient := range message.Recipients {
+			if recipient == im.address || im.aliases[recipient] != "" {
+				continue
+			}
+			mails = append(mails, mail.Mail{
+				Sender:             im.address,
+				Recipient:          recipient,
+				Alias:              alias,
+				Unread:             &read,
+				DeletedByRecipient: true,
+			})
+		}
+	} else {
+		unread := !seen
+		mails = append(mails, mail.Mail{
+			Sender:          message.From,
+			Recipient:       im.address,
+			Unread:          &unread,
+			DeletedBySender: true,
+		})
+	}
+	if len(mails) == 0 {
+		im.mailImport.Failed++
+		return im.done(key, position)
+	}
+	// copies stored before the interrupted import are skipped one by one, the rest of them is added on resume
+	if message.MessageId != "" {
+		missing := make([]mail.Mail, 0, len(mails))
+		for _, email := range mails {
+			exists, err := im.uc.Repository.MailExists(email.Sender, email.Recipient, message.MessageId)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				missing = append(missing, email)
+			}
+		}
+		if len(missing) == 0 {
+			im.mailImport.Duplicates++
+			return im.done(key, position)
+		}
+		mails = missing
+	}
+
+	folderId := 0
+	if len(folder) != 0 {
+		folderId, err = im.folder(folder)
+		if err != nil {
+			return err
+		}
+	}
+	for _, email := range mails {
+		email.Subject = message.Subject
+		email.Body = message.Body
+		email.Received_date = date
+		email.MessageId = message.MessageId
+		email.References = message.References
+		email.HasAttachment = message.HasAttachment
+		email.Raw = raw
+		_, err = im.uc.Repository.AddMail(email, nil)
+		if err != nil {
+			return err
+		}
+		other := email.Sender
+		if sent {
+			other = email.Recipient
+		}
+		if _, ok := im.dialogues[other]; !ok || folderId != 0 {
+			im.dialogues[other] = folderId
+		}
+	}
+	im.mailImport.Imported++
+	return im.done(key, position)
+}
+
+// done passes the position of the handled message
+func (im *mailImporter) done(key string, position int) error {
+	im.mailImport.Processed++
+	im.progress[key] = position
+	return im.saveBatch()
+}
+
+// folder finds or creates the nested folder by names from the top level one
+func (im *mailImporter) folder(names []string) (int, error) {
+	parent := 0
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		found := 0
+		for _, folder := range im.folders {
+			if folder.Smart == nil && folder.Parent == parent && strings.EqualFold(folder.FolderName, name) {
+				found = folder.Id
+				break
+			}
+		}
+		if found == 0 {
+			folder, err := im.uc.Repository.CreateFolder(im.ownerId, name, parent)
+			if err != nil {
+				return 0, err
+			}
+			im.folders = append(im.folders, folder)
+			found = folder.Id
+		}
+		parent = found
+	}
+	return parent, nil
+}
+
+func (im *mailImporter) saveBatch() error {
+	if im.mailImport.Processed%importBatchSize != 0 {
+		return nil
+	}
+	return im.save()
+}
+
+// save rebuilds dialogues of the imported mails and saves the progress,
+// mails after the saved position are checked by Message-ID again on resume
+func (im *mailImporter) save() error {
+	for other, folderId := range im.dialogues {
+		dialogueId, err := im.uc.Repository.RebuildDialogue(im.mailImport.Owner, other, im.domain)
+		if err != nil {
+			return err
+		}
+		if folderId != 0 {
+			err = im.uc.Repository.AddDialogueToFolder(im.mailImport.Owner, folderId, dialogueId)
+			if err != nil {
+				return err
+			}
+		}
+		delete(im.dialogues, other)
+	}
+	progress, err := json.Marshal(im.progress)
+	if err != nil {
+		return err
+	}
+	im.mailImport.Progress = string(progress)
+	return im.uc.Repository.UpdateImportProgress(*im.mailImport)
+}
+
+func (uc *MailUseCase) GetVapidPublicKey() (string, error) {
+	keys, ok := uc.vapidKeys()
+	if !ok {
+		return "", mail.InvalidEmailError{"web push is not configured"}
+	}
+	return keys.PublicKey, nil
+}
+
+func (uc *MailUseCase) SubscribePush(owner string, session string, subscription mail.PushSubscription) error {
+	if _, ok := uc.vapidKeys(); !ok {
+		return mail.InvalidEmailError{"web push is not configured"}
+	}
+	endpoint, err := url.Parse(subscription.Endpoint)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		return mail.InvalidEmailError{"push endpoint must be https url"}
+	}
+	if utils.CheckPublicHost(endpoint.Host) != nil {
+		return mail.InvalidEmailError{"push endpoint must not point to internal address"}
+	}
+	// subscription keys are checked by encrypting empty message with them
+	_, err = utils.EncryptPush(nil, subscription.Keys.P256dh, subscription.Keys.Auth)
+	if err != nil {
+		return mail.InvalidEmailError{err.Error()}
+	}
+	subscription.Owner = owner
+	subscription.Session = session
+	return uc.Repository.SavePushSubscription(subscription)
+}
+
+func (uc *MailUseCase) UnsubscribePush(session string) error {
+	return uc.Repository.DeletePushSubscription(session)
+}
+
+// sendPush notifies browsers of the owner about the mail in background unless its dialogue is muted
+func (uc *MailUseCase) sendPush(owner string, email mail.Mail) {
+	keys, ok := uc.vapidKeys()
+	if !ok {
+		return
+	}
+	subscriptions, err := uc.Repository.GetPushSubscriptions(owner, email.Sender)
+	if err != nil {
+		log.Printf("ERROR: Unable to get push subscriptions of %s: %v\n", owner, err)
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+	message, err := json.Marshal(mail.PushMessage{
+		Title:    email.Sender,
+		Body:     email.Subject,
+		Dialogue: email.Sender,
+		MailId:   email.Id,
+	})
+	if err != nil {
+		log.Printf("ERROR: Unable to create push message: %v\n", err)
+		return
+	}
+	for _, subscription := range subscriptions {
+		subscription := subscription
+		runAsync(func() {
+			status, err := sendWebPush(subscription.Endpoint, subscription.Keys.P256dh, subscription.Keys.Auth, message, keys, uc.Config.VapidSubject)
+			if status == http.StatusNotFound || status == http.StatusGone {
+				// the browser unsubscribed
+				err = uc.Repository.DeletePushEndpoint(subscription.Endpoint)
+				if err != nil {
+					log.Printf("ERROR: Unable to delete expired push subscription: %v\n", err)
+				}
+			} else if err != nil {
+				log.Printf("WARN: Unable to send push to %s: %v\n", owner, err)
+			}
+		})
+	}
+}
@@ -1,11 +1,26 @@
 package usecase
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"github.com/golang/mock/gomock"
+	"io/ioutil"
 	"liokor_mail/internal/pkg/common"
 	"liokor_mail/internal/pkg/mail"
 	"liokor_mail/internal/pkg/mail/mocks"
+	"liokor_mail/internal/utils"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -14,6 +29,58 @@ var config = common.Config{
 	MailDomain: "liokor.ru",
 }
 
+var defaultDomain = mail.Domain{
+	Id:           1,
+	Name:         "liokor.ru",
+	DkimSelector: "wolf",
+}
+
+// every user belongs to the default domain, other domains are external
+func expectDefaultDomain(mockRep *mocks.MockMailRepository) {
+	mockRep.EXPECT().GetUserDomain(gomock.Any()).Return(defaultDomain, nil).AnyTimes()
+	mockRep.EXPECT().
+		GetDomain(gomock.Any()).
+		DoAndReturn(func(name string) (mail.Domain, error) {
+			if name == defaultDomain.Name {
+				return defaultDomain, nil
+			}
+			return mail.Domain{}, mail.InvalidEmailError{"domain doesn't exist"}
+		}).
+		AnyTimes()
+	mockRep.EXPECT().
+		GetOwnerAddresses(gomock.Any()).
+		DoAndReturn(func(owner string) ([]string, error) {
+			return []string{owner + "@" + defaultDomain.Name}, nil
+		}).
+		AnyTimes()
+}
+
+// localOwners returns participants of the mail from the default domain
+func localOwners(email mail.Mail) []string {
+	owners := make([]string, 0, 2)
+	for _, address := range []string{email.Sender, email.Recipient} {
+		if strings.HasSuffix(address, "@"+defaultDomain.Name) {
+			owners = append(owners, address)
+		}
+	}
+	return owners
+}
+
+// every local part belongs to the user with the same username
+func expectPlainAddresses(mockRep *mocks.MockMailRepository) {
+	mockRep.EXPECT().
+		GetAddressOwner(gomock.Any(), defaultDomain.Name).
+		DoAndReturn(func(localPart string, domain string) (string, error) {
+			return localPart, nil
+		}).
+		AnyTimes()
+}
+
+// nobody blocks anybody
+func expectNoSenderRules(mockRep *mocks.MockMailRepository) {
+	mockRep.EXPECT().FindSenderRules(gomock.Any(), gomock.Any()).Return([]mail.SenderRule{}, nil).AnyTimes()
+}
+
 func TestGetDialogues(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -42,35 +109,36 @@ func TestGetDialogues(t *testing.T) {
 			Unread:        1,
 		},
 	}
+	page := mail.Page{Cursor: &mail.Cursor{Date: time.Now(), Id: 9}, Limit: 10}
 
 	mockRep.
 		EXPECT().
-		GetDialoguesInFolder("alt", 10, 0, "@liokor.ru", gomock.Any()).
+		GetDialoguesInFolder("alt", 0, page).
 		Return(dialogues, nil).
 		Times(1)
-	_, err := mailUC.GetDialogues("alt", 10, "", 0, time.Now())
+	_, err := mailUC.GetDialogues("alt", "", 0, page)
 	if err != nil {
 		t.Errorf("Didn't pass valid data: %v\n", err)
 	}
 
 	mockRep.
 		EXPECT().
-		FindDialogues("alt", "a", 10,"@liokor.ru", gomock.Any()).
+		FindDialogues("alt", "a", page).
 		Return(dialogues, nil).
 		Times(1)
-	_, err = mailUC.GetDialogues("alt", 10, "a", 0, time.Now())
+	_, err = mailUC.GetDialogues("alt", "a", 0, page)
 	if err != nil {
 		t.Errorf("Didn't pass valid data: %v\n", err)
 	}
 
 	mockRep.
 		EXPECT().
-		GetDialoguesInFolder("alt", 10, 0, "@liokor.ru", gomock.Any()).
+		GetDialoguesInFolder("alt", 0, page).
 		Return(nil, mail.InvalidEmailError{
 			"Error",
 		}).
 		Times(1)
-	_, err = mailUC.GetDialogues("alt", 10, "", 0, time.Now())
+	_, err = mailUC.GetDialogues("alt", "", 0, page)
 	switch err.(type) {
 	case mail.InvalidEmailError:
 		break
@@ -80,12 +148,12 @@ func TestGetDialogues(t *testing.T) {
 
 	mockRep.
 		EXPECT().
-		FindDialogues("alt", "a", 10,"@liokor.ru", gomock.Any()).
+		FindDialogues("alt", "a", page).
 		Return(nil, mail.InvalidEmailError{
 			"Error",
 		}).
 		Times(1)
-	_, err = mailUC.GetDialogues("alt", 10, "a", 0, time.Now())
+	_, err = mailUC.GetDialogues("alt", "a", 0, page)
 	switch err.(type) {
 	case mail.InvalidEmailError:
 		break
@@ -103,6 +171,7 @@ func TestGetEmails(t *testing.T) {
 		Repository: mockRep,
 		Config:     config,
 	}
+	expectDefaultDomain(mockRep)
 
 	emails := []mail.DialogueEmail{
 		{
@@ -128,12 +197,17 @@ func TestGetEmails(t *testing.T) {
 	gomock.InOrder(
 		mockRep.
 			EXPECT().
-			GetMailsForUser("alt@liokor.ru", "lio@liokor.ru", 10, 0).
+			GetMailsForUser("alt@liokor.ru", "lio@liokor.ru", mail.Page{Limit: 10}).
 			Return(emails, nil).
 			Times(1),
 		mockRep.
 			EXPECT().
-			ReadMail("alt@liokor.ru", "lio@liokor.ru").
+			GetReadReceipts("alt").
+			Return(false, nil).
+			Times(1),
+		mockRep.
+			EXPECT().
+			ReadMail([]string{"alt@liokor.ru"}, "lio@liokor.ru").
 			Return(nil).
 			Times(1),
 		mockRep.
@@ -142,25 +216,53 @@ func TestGetEmails(t *testing.T) {
 			Return(nil).
 			Times(1),
 	)
-	_, err := mailUC.GetEmails("alt", "lio@liokor.ru", 0, 10)
+	_, err := mailUC.GetEmails("alt", "lio@liokor.ru", mail.Page{Limit: 10})
 	if err != nil {
 		t.Errorf("Didn't pass valid data: %v\n", err)
 	}
 
 	mockRep.
 		EXPECT().
-		GetMailsForUser("alt@liokor.ru", "lio@liokor.ru", 10, 0).
+		GetMailsForUser("alt@liokor.ru", "lio@liokor.ru", mail.Page{Limit: 10}).
 		Return(nil, mail.InvalidEmailError{
 			"Error",
 		}).
 		Times(1)
-	_, err = mailUC.GetEmails("alt", "lio@liokor.ru", 0, 10)
+	_, err = mailUC.GetEmails("alt", "lio@liokor.ru", mail.Page{Limit: 10})
 	switch err.(type) {
 	case mail.InvalidEmailError:
 		break
 	default:
 		t.Errorf("Didn't pass invalid data: %v\n", err)
 	}
+
+	// unread mail to the alias fires read webhook too
+	aliasRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC.Repository = aliasRep
+	aliasRep.EXPECT().GetUserDomain("alt").Return(defaultDomain, nil).AnyTimes()
+	addresses := []string{"alt@liokor.ru", "support@liokor.ru", "alt@liokor.com"}
+	aliasEmails := []mail.DialogueEmail{{Id: 3, Sender: "lio@liokor.ru", Recipient: "Support@liokor.ru", Unread: true}}
+	gomock.InOrder(
+		aliasRep.EXPECT().GetMailsForUser("alt@liokor.ru", "lio@liokor.ru", mail.Page{Limit: 10}).Return(aliasEmails, nil),
+		aliasRep.EXPECT().GetOwnerAddresses("alt").Return(addresses, nil),
+		aliasRep.EXPECT().GetReadReceipts("alt").Return(false, nil),
+		aliasRep.EXPECT().ReadMail(addresses, "lio@liokor.ru").Return(nil),
+		aliasRep.EXPECT().ReadDialogue("alt", "lio@liokor.ru").Return(nil),
+		aliasRep.EXPECT().GetWebhooks("alt").Return([]mail.Webhook{}, nil),
+	)
+	_, err = mailUC.GetEmails("alt", "lio@liokor.ru", mail.Page{Limit: 10})
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+}
+
+// nobody has default signatures
+func expectNoSignatures(mockRep *mocks.MockMailRepository) {
+	mockRep.EXPECT().GetDefaultSignature(gomock.Any(), gomock.Any()).Return(mail.Signature{}, nil).AnyTimes()
+}
+
+func expectNoWebhooks(mockRep *mocks.MockMailRepository) {
+	mockRep.EXPECT().GetWebhooks(gomock.Any()).Return([]mail.Webhook{}, nil).AnyTimes()
 }
 
 func TestSendEmail(t *testing.T) {
@@ -168,10 +270,16 @@ func TestSendEmail(t *testing.T) {
 	defer mockCtrl.Finish()
 
 	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	expectNoWebhooks(mockRep)
 	mailUC := MailUseCase{
 		Repository: mockRep,
 		Config:     config,
 	}
+	expectDefaultDomain(mockRep)
+	expectPlainAddresses(mockRep)
+	expectNoSenderRules(mockRep)
+	expectNoSignatures(mockRep)
+	mockRep.EXPECT().GetForwarding(gomock.Any()).Return(mail.Forwarding{}, nil).AnyTimes()
 
 	email := mail.Mail{
 		Sender:    "alt",
@@ -185,13 +293,15 @@ func TestSendEmail(t *testing.T) {
 		Body:      "<p>Testing</p>\n",
 		Subject:   "Test",
 	}
-	mockRep.EXPECT().AddMail(emailSent, "liokor.ru").Return(1, nil).Times(1)
+	mockRep.EXPECT().AddMail(emailSent, localOwners(emailSent)).Return(1, nil).Times(1)
+	mockRep.EXPECT().CollectContact("alt", "altana@liokor.ru").Return(nil).Times(1)
+	mockRep.EXPECT().GetVacation("altana").Return(mail.Vacation{Owner: "altana"}, nil).Times(1)
     _, err := mailUC.SendEmail(email)
 	if err != nil {
 		t.Errorf("Couldn't send email: %v\n", err)
 	}
 
-	mockRep.EXPECT().AddMail(emailSent, "liokor.ru").Return(0, mail.InvalidEmailError{"Error"}).Times(1)
+	mockRep.EXPECT().AddMail(emailSent, localOwners(emailSent)).Return(0, mail.InvalidEmailError{"Error"}).Times(1)
 	_, err = mailUC.SendEmail(email)
 	switch err.(type) {
 	case mail.InvalidEmailError:
@@ -237,7 +347,8 @@ func TestGetFolders(t *testing.T) {
 		},
 	}
 	mockRep.EXPECT().GetFolders(1).Return(folders, nil).Times(1)
-	_, err := mailUC.GetFolders(1)
+	mockRep.EXPECT().GetSmartFolders("alt").Return([]mail.SmartFolder{}, nil).Times(1)
+	_, err := mailUC.GetFolders("alt", 1)
 	if err != nil {
 		t.Errorf("Didn't get valid folders: %v\n", err)
 	}
@@ -258,14 +369,14 @@ func TestCreateFolder(t *testing.T) {
 		FolderName: "NewFolder",
 		Owner:      1,
 	}
-	mockRep.EXPECT().CreateFolder(folder.Owner, folder.FolderName).Return(folder, nil).Times(1)
-	_, err := mailUC.CreateFolder(folder.Owner, folder.FolderName)
+	mockRep.EXPECT().CreateFolder(folder.Owner, folder.FolderName, 0).Return(folder, nil).Times(1)
+	_, err := mailUC.CreateFolder(folder.Owner, folder.FolderName, 0)
 	if err != nil {
 		t.Errorf("Didn't create valid folders: %v\n", err)
 	}
 
-	mockRep.EXPECT().CreateFolder(folder.Owner, folder.FolderName).Return(mail.Folder{}, common.InvalidUserError{"User doesn't exist"}).Times(1)
-	_, err = mailUC.CreateFolder(folder.Owner, folder.FolderName)
+	mockRep.EXPECT().CreateFolder(folder.Owner, folder.FolderName, 0).Return(mail.Folder{}, common.InvalidUserError{"User doesn't exist"}).Times(1)
+	_, err = mailUC.CreateFolder(folder.Owner, folder.FolderName, 0)
 	switch err.(type) {
 	case common.InvalidUserError:
 		break
@@ -340,6 +451,7 @@ func TestDeleteDialogue(t *testing.T) {
 		Repository: mockRep,
 		Config:     config,
 	}
+	expectDefaultDomain(mockRep)
 
 	owner := "altana"
 	dialogueId := 1
@@ -369,6 +481,7 @@ func TestDeleteMails(t *testing.T) {
 		Repository: mockRep,
 		Config:     config,
 	}
+	expectDefaultDomain(mockRep)
 
 	owner := "altana"
 	mailsId := []int{1, 2}
@@ -442,4 +555,2650 @@ func TestDeleteFolder(t *testing.T) {
 	if err != nil {
 		t.Errorf("Didn't delete valid folder: %v\n", err)
 	}
-}
\ No newline at end of file
+}
+func TestReceiveEmailVacation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	expectNoWebhooks(mockRep)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+	expectPlainAddresses(mockRep)
+	expectNoSenderRules(mockRep)
+	expectNoSignatures(mockRep)
+	mockRep.EXPECT().GetForwarding(gomock.Any()).Return(mail.Forwarding{}, nil).AnyTimes()
+
+	email := mail.Mail{
+		Sender:    "friend@ya.ru",
+		Recipient: "alt@liokor.ru",
+		Subject:   "Hello",
+		Body:      "Testing",
+	}
+	vacation := mail.Vacation{
+		Owner:         "alt",
+		Enabled:       true,
+		StartDate:     time.Now().Add(-time.Hour),
+		EndDate:       time.Now().Add(time.Hour),
+		Subject:       "Out of office",
+		Body:          "I'm away",
+		ReplyInterval: 7,
+	}
+
+	// external reply is relayed after the mail is received and isn't rate limited
+	pending := make([]func(), 0)
+	runAsync = func(f func()) { pending = append(pending, f) }
+	var sentTo string
+	smtpSendMail = func(from string, to string, subject string, body string, headers map[string]string, key *utils.DkimKey) error {
+		if from != "alt@liokor.ru" || subject != "Out of office" || headers["Auto-Submitted"] != "auto-replied" {
+			t.Errorf("Wrong vacation reply: %s %s %v\n", from, subject, headers)
+		}
+		sentTo = to
+		return errors.New("connection refused")
+	}
+	defer func() {
+		runAsync = func(f func()) { go f() }
+		smtpSendMail = utils.SMTPSendMail
+	}()
+	gomock.InOrder(
+		mockRep.EXPECT().AddMail(email, localOwners(email)).Return(1, nil).Times(1),
+		mockRep.EXPECT().GetVacation("alt").Return(vacation, nil).Times(1),
+		mockRep.EXPECT().VacationReplied("alt", "friend@ya.ru", 7*24*time.Hour).Return(false, nil).Times(1),
+		mockRep.EXPECT().SaveVacationReply("alt", "friend@ya.ru").Return(nil).Times(1),
+	)
+	err := mailUC.ReceiveEmail(email)
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("Didn't receive valid email: %v\n", err)
+	}
+	// failed reply stays marked as sent, so it isn't retried on every next mail
+	gomock.InOrder(
+		mockRep.EXPECT().AddMail(gomock.Any(), []string{"alt@liokor.ru"}).Return(2, nil).Times(1),
+		mockRep.EXPECT().UpdateMailStatus(2, mail.MailStatusFailed).Return(nil).Times(1),
+	)
+	pending[0]()
+	if sentTo != "friend@ya.ru" {
+		t.Errorf("Vacation reply isn't relayed: %s\n", sentTo)
+	}
+
+	mockRep.EXPECT().AddMail(email, localOwners(email)).Return(1, nil).Times(1)
+	mockRep.EXPECT().GetVacation("alt").Return(vacation, nil).Times(1)
+	mockRep.EXPECT().VacationReplied("alt", "friend@ya.ru", 7*24*time.Hour).Return(true, nil).Times(1)
+	err = mailUC.ReceiveEmail(email)
+	if err != nil {
+		t.Errorf("Didn't receive valid email: %v\n", err)
+	}
+
+	vacation.OnlyContacts = true
+	mockRep.EXPECT().AddMail(email, localOwners(email)).Return(1, nil).Times(1)
+	mockRep.EXPECT().GetVacation("alt").Return(vacation, nil).Times(1)
+	mockRep.EXPECT().IsContact("alt@liokor.ru", "friend@ya.ru").Return(false, nil).Times(1)
+	err = mailUC.ReceiveEmail(email)
+	if err != nil {
+		t.Errorf("Didn't receive valid email: %v\n", err)
+	}
+
+	listEmail := email
+	listEmail.Headers = map[string]string{"List-Id": "<news.ya.ru>"}
+	mockRep.EXPECT().AddMail(listEmail, localOwners(listEmail)).Return(1, nil).Times(1)
+	err = mailUC.ReceiveEmail(listEmail)
+	if err != nil {
+		t.Errorf("Didn't receive valid email: %v\n", err)
+	}
+
+	mockRep.EXPECT().AddMail(email, localOwners(email)).Return(0, mail.InvalidEmailError{"Error"}).Times(1)
+	err = mailUC.ReceiveEmail(email)
+	if err == nil {
+		t.Errorf("Didn't pass invalid data\n")
+	}
+}
+
+func TestInternalVacationReply(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	expectNoWebhooks(mockRep)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+	expectPlainAddresses(mockRep)
+	expectNoSenderRules(mockRep)
+	expectNoSignatures(mockRep)
+	mockRep.EXPECT().GetForwarding(gomock.Any()).Return(mail.Forwarding{}, nil).AnyTimes()
+	mockRep.EXPECT().CollectContact(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	runAsync = func(f func()) { f() }
+	defer func() {
+		runAsync = func(f func()) { go f() }
+	}()
+
+	email := mail.Mail{
+		Sender:    "lio",
+		Recipient: "alt@liokor.ru",
+		Subject:   "Hello",
+		Body:      "Testing",
+	}
+	sent := mail.Mail{
+		Sender:    "lio@liokor.ru",
+		Recipient: "alt@liokor.ru",
+		Subject:   "Hello",
+		Body:      "<p>Testing</p>\n",
+	}
+	reply := mail.Mail{
+		Sender:      "alt@liokor.ru",
+		Recipient:   "lio@liokor.ru",
+		Subject:     "Auto: Hello",
+		Body:        "<p>I am away</p>\n",
+		NoSignature: true,
+		Headers:     map[string]string{"Auto-Submitted": "auto-replied"},
+	}
+	vacation := mail.Vacation{
+		Owner:         "alt",
+		Enabled:       true,
+		StartDate:     time.Now().Add(-time.Hour),
+		Body:          "I am away",
+		ReplyInterval: 3,
+	}
+
+	gomock.InOrder(
+		mockRep.EXPECT().AddMail(sent, localOwners(sent)).Return(1, nil).Times(1),
+		mockRep.EXPECT().GetVacation("alt").Return(vacation, nil).Times(1),
+		mockRep.EXPECT().VacationReplied("alt", "lio@liokor.ru", 3*24*time.Hour).Return(false, nil).Times(1),
+		mockRep.EXPECT().SaveVacationReply("alt", "lio@liokor.ru").Return(nil).Times(1),
+		mockRep.EXPECT().AddMail(reply, localOwners(reply)).Return(2, nil).Times(1),
+	)
+	_, err := mailUC.SendEmail(email)
+	if err != nil {
+		t.Errorf("Couldn't send email: %v\n", err)
+	}
+}
+
+func TestUpdateVacation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+
+	start := time.Now()
+	vacation := mail.Vacation{
+		Enabled:   true,
+		StartDate: start,
+		EndDate:   start.Add(24 * time.Hour),
+		Subject:   "Away",
+		Body:      "Back soon",
+	}
+	saved := vacation
+	saved.Owner = "alt"
+	saved.ReplyInterval = mail.DefaultVacationReplyInterval
+
+	mockRep.EXPECT().UpdateVacation(saved).Return(saved, nil).Times(1)
+	_, err := mailUC.UpdateVacation("alt", vacation)
+	if err != nil {
+		t.Errorf("Didn't update valid vacation: %v\n", err)
+	}
+
+	vacation.EndDate = start.Add(-time.Hour)
+	_, err = mailUC.UpdateVacation("alt", vacation)
+	switch err.(type) {
+	case mail.InvalidEmailError:
+		break
+	default:
+		t.Errorf("Didn't pass invalid data: %v\n", err)
+	}
+}
+
+func TestForwarding(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	expectNoWebhooks(mockRep)
+	forwardingConfig := config
+	forwardingConfig.SrsSecret = "secret"
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     forwardingConfig,
+	}
+	expectDefaultDomain(mockRep)
+	expectPlainAddresses(mockRep)
+	expectNoSenderRules(mockRep)
+	expectNoSignatures(mockRep)
+
+	var relayedFrom, relayedTo string
+	var relayedMessage []byte
+	smtpRelayMail = func(envelopeFrom string, to string, message []byte, key *utils.DkimKey) error {
+		relayedFrom, relayedTo, relayedMessage = envelopeFrom, to, message
+		return nil
+	}
+	defer func() { smtpRelayMail = utils.SMTPRelayMail }()
+
+	email := mail.Mail{
+		Sender:    "friend@ya.ru",
+		Recipient: "alt@liokor.ru",
+		Subject:   "Hello",
+		Body:      "Testing",
+		Raw:       []byte("Subject: Hello\r\n\r\nTesting\r\n"),
+	}
+	forwarding := mail.Forwarding{
+		Owner:    "alt",
+		Enabled:  true,
+		Address:  "alt@gmail.com",
+		Verified: true,
+		KeepCopy: false,
+	}
+	mockRep.EXPECT().GetVacation("alt").Return(mail.Vacation{Owner: "alt"}, nil).AnyTimes()
+
+	mockRep.EXPECT().GetForwarding("alt").Return(forwarding, nil).Times(1)
+	err := mailUC.ReceiveEmail(email)
+	if err != nil {
+		t.Errorf("Didn't receive valid email: %v\n", err)
+	}
+	if relayedTo != "alt@gmail.com" || !utils.IsSRSAddress(relayedFrom) {
+		t.Errorf("Didn't forward email: %s -> %s\n", relayedFrom, relayedTo)
+	}
+	if !strings.HasPrefix(string(relayedMessage), mail.ForwardTraceHeader+": alt@liokor.ru\r\n") {
+		t.Errorf("Forwarded email has no trace header\n")
+	}
+
+	// already forwarded by this mailbox => loop
+	relayedTo = ""
+	loopEmail := email
+	loopEmail.Headers = map[string]string{mail.ForwardTraceHeader: "alt@gmail.com, alt@liokor.ru"}
+	mockRep.EXPECT().GetForwarding("alt").Return(forwarding, nil).Times(1)
+	mockRep.EXPECT().AddMail(loopEmail, localOwners(loopEmail)).Return(1, nil).Times(1)
+	err = mailUC.ReceiveEmail(loopEmail)
+	if err != nil {
+		t.Errorf("Didn't receive valid email: %v\n", err)
+	}
+	if relayedTo != "" {
+		t.Errorf("Forwarded email in loop\n")
+	}
+
+	forwarding.KeepCopy = true
+	forwarding.FilterSubject = "hello"
+	mockRep.EXPECT().GetForwarding("alt").Return(forwarding, nil).Times(1)
+	mockRep.EXPECT().AddMail(email, localOwners(email)).Return(1, nil).Times(1)
+	err = mailUC.ReceiveEmail(email)
+	if err != nil || relayedTo != "alt@gmail.com" {
+		t.Errorf("Didn't forward and keep valid email: %v\n", err)
+	}
+
+	forwarding.Verified = false
+	relayedTo = ""
+	mockRep.EXPECT().GetForwarding("alt").Return(forwarding, nil).Times(1)
+	mockRep.EXPECT().AddMail(email, localOwners(email)).Return(1, nil).Times(1)
+	err = mailUC.ReceiveEmail(email)
+	if err != nil || relayedTo != "" {
+		t.Errorf("Forwarded email to unverified address: %v\n", err)
+	}
+}
+
+func TestUpdateForwarding(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	forwardingConfig := config
+	forwardingConfig.ApiUrl = "https://api.mail.liokor.ru"
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     forwardingConfig,
+	}
+	expectDefaultDomain(mockRep)
+
+	var sentTo, sentBody string
+	smtpSendMail = func(from string, to string, subject string, data string, headers map[string]string, key *utils.DkimKey) error {
+		sentTo, sentBody = to, data
+		return nil
+	}
+	defer func() { smtpSendMail = utils.SMTPSendMail }()
+
+	forwarding := mail.Forwarding{
+		Enabled:  true,
+		Address:  "alt@gmail.com",
+		KeepCopy: true,
+	}
+	mockRep.EXPECT().GetForwarding("alt").Return(mail.Forwarding{Owner: "alt", KeepCopy: true}, nil).Times(1)
+	mockRep.EXPECT().UpdateForwarding(gomock.Any()).DoAndReturn(func(f mail.Forwarding) (mail.Forwarding, error) {
+		if f.Verified || !f.Token.Valid || f.Owner != "alt" {
+			t.Errorf("Saved forwarding without confirmation: %v\n", f)
+		}
+		return f, nil
+	}).Times(1)
+	updated, err := mailUC.UpdateForwarding("alt", forwarding)
+	if err != nil {
+		t.Errorf("Didn't update valid forwarding: %v\n", err)
+	}
+	if sentTo != "alt@gmail.com" || !strings.Contains(sentBody, "/email/forwarding/confirm?token="+updated.Token.String) {
+		t.Errorf("Didn't send confirmation link\n")
+	}
+
+	for _, address := range []string{"lio@liokor.ru", "alt@gmail.com\r\nBcc: spam@gmail.com", "Alt <alt@gmail.com>", "alt@@gmail.com"} {
+		forwarding.Address = address
+		_, err = mailUC.UpdateForwarding("alt", forwarding)
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			break
+		default:
+			t.Errorf("Didn't pass invalid address %q: %v\n", address, err)
+		}
+	}
+
+	// forwarding isn't saved when the confirmation can't be sent
+	smtpSendMail = func(from string, to string, subject string, data string, headers map[string]string, key *utils.DkimKey) error {
+		return errors.New("connection refused")
+	}
+	forwarding.Address = "alt@yandex.ru"
+	mockRep.EXPECT().GetForwarding("alt").Return(mail.Forwarding{Owner: "alt", KeepCopy: true}, nil).Times(1)
+	mockRep.EXPECT().UpdateForwarding(gomock.Any()).Times(0)
+	_, err = mailUC.UpdateForwarding("alt", forwarding)
+	if err == nil {
+		t.Errorf("Saved forwarding without sent confirmation\n")
+	}
+
+	mockRep.EXPECT().ConfirmForwarding("token").Return(nil).Times(1)
+	err = mailUC.ConfirmForwarding("token")
+	if err != nil {
+		t.Errorf("Didn't confirm valid token: %v\n", err)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	expectNoWebhooks(mockRep)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	catchAllDomain := defaultDomain
+	catchAllDomain.CatchAll = common.NullString{sql.NullString{String: "postbox", Valid: true}}
+	mockRep.EXPECT().GetUserDomain(gomock.Any()).Return(defaultDomain, nil).AnyTimes()
+	mockRep.EXPECT().GetDomain("liokor.ru").Return(catchAllDomain, nil).AnyTimes()
+	mockRep.EXPECT().GetDomain("ya.ru").Return(mail.Domain{}, mail.InvalidEmailError{"domain doesn't exist"}).AnyTimes()
+	mockRep.EXPECT().GetForwarding(gomock.Any()).Return(mail.Forwarding{}, nil).AnyTimes()
+	mockRep.EXPECT().GetVacation(gomock.Any()).Return(mail.Vacation{}, nil).AnyTimes()
+	mockRep.EXPECT().CollectContact(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	expectNoSenderRules(mockRep)
+	expectNoSignatures(mockRep)
+
+	// plus-addressing routes to the user and labels the mail
+	email := mail.Mail{
+		Sender:    "friend@ya.ru",
+		Recipient: "alt+News@liokor.ru",
+		Subject:   "Hello",
+		Body:      "Testing",
+	}
+	received := email
+	received.Recipient = "alt@liokor.ru"
+	gomock.InOrder(
+		mockRep.EXPECT().GetAddressOwner("alt", "liokor.ru").Return("alt", nil).Times(1),
+		mockRep.EXPECT().AddMail(received, localOwners(received)).Return(5, nil).Times(1),
+		mockRep.EXPECT().AddMailLabel(5, "alt@liokor.ru", "news").Return(nil).Times(1),
+	)
+	err := mailUC.ReceiveEmail(email)
+	if err != nil {
+		t.Errorf("Didn't receive valid email: %v\n", err)
+	}
+
+	// alias routes to its owner
+	email.Recipient = "support@liokor.ru"
+	mockRep.EXPECT().GetAddressOwner("support", "liokor.ru").Return("alt", nil).Times(1)
+	mockRep.EXPECT().AddMail(received, localOwners(received)).Return(6, nil).Times(1)
+	err = mailUC.ReceiveEmail(email)
+	if err != nil {
+		t.Errorf("Didn't receive valid email: %v\n", err)
+	}
+
+	// unknown address goes to catch-all
+	email.Recipient = "unknown@liokor.ru"
+	catchAll := email
+	catchAll.Recipient = "postbox@liokor.ru"
+	mockRep.EXPECT().GetAddressOwner("unknown", "liokor.ru").Return("", common.InvalidUserError{"user doesn't exist"}).Times(1)
+	mockRep.EXPECT().GetDistributionList("unknown", "liokor.ru").Return(mail.DistributionList{}, mail.InvalidEmailError{"list doesn't exist"}).Times(1)
+	mockRep.EXPECT().AddMail(catchAll, localOwners(catchAll)).Return(7, nil).Times(1)
+	err = mailUC.ReceiveEmail(email)
+	if err != nil {
+		t.Errorf("Didn't receive valid email: %v\n", err)
+	}
+
+	// sending from alias of another user
+	mockRep.EXPECT().GetAddressOwner("support", "liokor.ru").Return("lio", nil).Times(1)
+	_, err = mailUC.SendEmail(mail.Mail{
+		Sender:    "alt",
+		Recipient: "friend@ya.ru",
+		Subject:   "Hello",
+		Body:      "Testing",
+		Alias:     "support",
+	})
+	switch err.(type) {
+	case mail.InvalidEmailError:
+		break
+	default:
+		t.Errorf("Sent email from foreign alias: %v\n", err)
+	}
+
+	// sending from own alias to internal recipient
+	sent := mail.Mail{
+		Sender:    "alt@liokor.ru",
+		Recipient: "lio@liokor.ru",
+		Subject:   "Hello",
+		Body:      "<p>Testing</p>\n",
+		Alias:     "support@liokor.ru",
+	}
+	mockRep.EXPECT().GetAddressOwner("support", "liokor.ru").Return("alt", nil).Times(1)
+	mockRep.EXPECT().GetAddressOwner("lio", "liokor.ru").Return("lio", nil).Times(1)
+	mockRep.EXPECT().AddMail(sent, localOwners(sent)).Return(8, nil).Times(1)
+	_, err = mailUC.SendEmail(mail.Mail{
+		Sender:    "alt",
+		Recipient: "lio@liokor.ru",
+		Subject:   "Hello",
+		Body:      "Testing",
+		Alias:     "support@liokor.ru",
+	})
+	if err != nil {
+		t.Errorf("Didn't send email from own alias: %v\n", err)
+	}
+
+	mockRep.EXPECT().CreateAlias("alt", "support").Return(mail.Alias{Id: 1, Owner: "alt", Alias: "support"}, nil).Times(1)
+	_, err = mailUC.CreateAlias("alt", "support@liokor.ru")
+	if err != nil {
+		t.Errorf("Didn't create valid alias: %v\n", err)
+	}
+
+	_, err = mailUC.CreateAlias("alt", "postmaster")
+	switch err.(type) {
+	case mail.InvalidEmailError:
+		break
+	default:
+		t.Errorf("Created invalid alias: %v\n", err)
+	}
+}
+
+func TestMultipleDomains(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	expectNoWebhooks(mockRep)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	brandDomain := mail.Domain{
+		Id:           2,
+		Name:         "lioteam.ru",
+		DkimSelector: "brand",
+	}
+	mockRep.EXPECT().GetUserDomain("alt").Return(brandDomain, nil).AnyTimes()
+	mockRep.EXPECT().GetUserDomain("lio").Return(defaultDomain, nil).AnyTimes()
+	mockRep.EXPECT().GetDomain("liokor.ru").Return(defaultDomain, nil).AnyTimes()
+	mockRep.EXPECT().GetDomain("lioteam.ru").Return(brandDomain, nil).AnyTimes()
+	mockRep.EXPECT().GetDomain(gomock.Any()).Return(mail.Domain{}, mail.InvalidEmailError{"domain doesn't exist"}).AnyTimes()
+	mockRep.EXPECT().GetForwarding(gomock.Any()).Return(mail.Forwarding{}, nil).AnyTimes()
+	mockRep.EXPECT().GetVacation(gomock.Any()).Return(mail.Vacation{}, nil).AnyTimes()
+	mockRep.EXPECT().CountMailsFromUser(gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
+	mockRep.EXPECT().CollectContact(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	expectNoSenderRules(mockRep)
+	expectNoSignatures(mockRep)
+
+	// mail between users of different local domains updates both dialogues
+	sent := mail.Mail{
+		Sender:    "alt@lioteam.ru",
+		Recipient: "lio@liokor.ru",
+		Subject:   "Hello",
+		Body:      "<p>Testing</p>\n",
+	}
+	mockRep.EXPECT().GetAddressOwner("lio", "liokor.ru").Return("lio", nil).Times(1)
+	mockRep.EXPECT().AddMail(sent, []string{"alt@lioteam.ru", "lio@liokor.ru"}).Return(1, nil).Times(1)
+	_, err := mailUC.SendEmail(mail.Mail{
+		Sender:    "alt",
+		Recipient: "lio@liokor.ru",
+		Subject:   "Hello",
+		Body:      "Testing",
+	})
+	if err != nil {
+		t.Errorf("Didn't send email between domains: %v\n", err)
+	}
+
+	// user of another domain doesn't own the address
+	mockRep.EXPECT().GetAddressOwner("alt", "liokor.ru").Return("", common.InvalidUserError{"user doesn't exist"}).Times(1)
+	mockRep.EXPECT().GetDistributionList("alt", "liokor.ru").Return(mail.DistributionList{}, mail.InvalidEmailError{"list doesn't exist"}).Times(1)
+	_, err = mailUC.SendEmail(mail.Mail{
+		Sender:    "lio",
+		Recipient: "alt@liokor.ru",
+		Subject:   "Hello",
+		Body:      "Testing",
+	})
+	switch err.(type) {
+	case mail.InvalidEmailError:
+		break
+	default:
+		t.Errorf("Sent email to address of another domain: %v\n", err)
+	}
+
+	// external mail is sent from the user's domain
+	var sentFrom string
+	smtpSendMail = func(from string, to string, subject string, data string, headers map[string]string, key *utils.DkimKey) error {
+		sentFrom = from
+		return nil
+	}
+	defer func() { smtpSendMail = utils.SMTPSendMail }()
+	mockRep.EXPECT().AddMail(gomock.Any(), []string{"alt@lioteam.ru"}).Return(2, nil).Times(1)
+	_, err = mailUC.SendEmail(mail.Mail{
+		Sender:    "alt",
+		Recipient: "friend@ya.ru",
+		Subject:   "Hello",
+		Body:      "Testing",
+	})
+	if err != nil {
+		t.Errorf("Didn't send external email: %v\n", err)
+	}
+	if sentFrom != "alt@lioteam.ru" {
+		t.Errorf("Sent external email from wrong address: %s\n", sentFrom)
+	}
+
+	err = mailUC.ReceiveEmail(mail.Mail{
+		Sender:    "friend@ya.ru",
+		Recipient: "alt@example.com",
+		Subject:   "Hello",
+		Body:      "Testing",
+	})
+	switch err.(type) {
+	case mail.InvalidEmailError:
+		break
+	default:
+		t.Errorf("Received email for not served domain: %v\n", err)
+	}
+}
+
+func TestDistributionList(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	expectNoWebhooks(mockRep)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+	mockRep.EXPECT().GetForwarding(gomock.Any()).Return(mail.Forwarding{}, nil).AnyTimes()
+	mockRep.EXPECT().GetAddressOwner("alt", "liokor.ru").Return("alt", nil).AnyTimes()
+	mockRep.EXPECT().GetAddressOwner("dev", "liokor.ru").Return("", common.InvalidUserError{"user doesn't exist"}).AnyTimes()
+	mockRep.EXPECT().GetDistributionList("dev", "liokor.ru").Return(mail.DistributionList{
+		Id:      1,
+		Name:    "dev",
+		Domain:  "liokor.ru",
+		Owner:   "lio",
+		Members: common.StringList{"alt@liokor.ru", "dev@liokor.ru", "friend@ya.ru"},
+	}, nil).AnyTimes()
+	mockRep.EXPECT().CollectContact(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	expectNoSenderRules(mockRep)
+	expectNoSignatures(mockRep)
+
+	var relayedTo []string
+	smtpRelayMail = func(envelopeFrom string, to string, message []byte, key *utils.DkimKey) error {
+		relayedTo = append(relayedTo, to)
+		return nil
+	}
+	defer func() { smtpRelayMail = utils.SMTPRelayMail }()
+
+	// sender keeps dialogue with the list, local member gets hidden from sender copy
+	sent := mail.Mail{
+		Sender:    "lio@liokor.ru",
+		Recipient: "dev@liokor.ru",
+		Subject:   "Release",
+		Body:      "<p>Testing</p>\n",
+	}
+	memberCopy := sent
+	memberCopy.Recipient = "alt@liokor.ru"
+	memberCopy.List = "dev@liokor.ru"
+	memberCopy.DeletedBySender = true
+	memberCopy.Headers = map[string]string{
+		"List-Id":               "<dev.liokor.ru>",
+		mail.ForwardTraceHeader: "dev@liokor.ru",
+	}
+	gomock.InOrder(
+		mockRep.EXPECT().AddMail(sent, []string{"lio@liokor.ru"}).Return(1, nil).Times(1),
+		mockRep.EXPECT().AddMail(memberCopy, []string{"alt@liokor.ru"}).Return(2, nil).Times(1),
+	)
+	_, err := mailUC.SendEmail(mail.Mail{
+		Sender:    "lio",
+		Recipient: "dev@liokor.ru",
+		Subject:   "Release",
+		Body:      "Testing",
+	})
+	if err != nil {
+		t.Errorf("Didn't send email to the list: %v\n", err)
+	}
+	if len(relayedTo) != 1 || relayedTo[0] != "friend@ya.ru" {
+		t.Errorf("Didn't relay email to external member: %v\n", relayedTo)
+	}
+
+	// inbound mail isn't stored for the list itself
+	relayedTo = nil
+	inbound := mail.Mail{
+		Sender:    "friend@ya.ru",
+		Recipient: "dev@liokor.ru",
+		Subject:   "Bug",
+		Body:      "Testing",
+	}
+	inboundCopy := inbound
+	inboundCopy.Recipient = "alt@liokor.ru"
+	inboundCopy.List = "dev@liokor.ru"
+	inboundCopy.DeletedBySender = true
+	inboundCopy.Headers = memberCopy.Headers
+	mockRep.EXPECT().AddMail(inboundCopy, []string{"alt@liokor.ru"}).Return(3, nil).Times(1)
+	err = mailUC.ReceiveEmail(inbound)
+	if err != nil {
+		t.Errorf("Didn't receive email for the list: %v\n", err)
+	}
+	if len(relayedTo) != 1 {
+		t.Errorf("Didn't relay inbound email to external member: %v\n", relayedTo)
+	}
+
+	// only members post to the list by default
+	relayedTo = nil
+	err = mailUC.ReceiveEmail(mail.Mail{Sender: "spam@ya.ru", Recipient: "dev@liokor.ru", Subject: "Buy", Body: "Testing"})
+	if err != nil || len(relayedTo) != 0 {
+		t.Errorf("Distributed email of not member: %v, %v\n", relayedTo, err)
+	}
+	err = mailUC.CheckIncomingSender("spam@ya.ru", "dev@liokor.ru")
+	if _, ok := err.(mail.SenderBlockedError); !ok {
+		t.Errorf("Didn't reject not member at smtp: %v\n", err)
+	}
+	_, err = mailUC.SendEmail(mail.Mail{Sender: "wolf", Recipient: "dev@liokor.ru", Subject: "Hi", Body: "Testing"})
+	if _, ok := err.(mail.InvalidEmailError); !ok || err.Error() != "only members may post to the list" {
+		t.Errorf("Local not member posted to the list: %v\n", err)
+	}
+}
+
+func TestCheckListSender(t *testing.T) {
+	list := mail.DistributionList{
+		Name:    "dev",
+		Domain:  "liokor.ru",
+		Owner:   "lio",
+		Members: common.StringList{"alt@liokor.ru", "friend@ya.ru"},
+	}
+	cases := []struct {
+		posting       string
+		sender        string
+		authenticated bool
+		allowed       bool
+	}{
+		{"", "friend@ya.ru", false, true},
+		{"", "spam@ya.ru", false, false},
+		{mail.ListPostMembers, "lio@liokor.ru", true, true},
+		{mail.ListPostMembers, "lio@liokor.ru", false, false},
+		{mail.ListPostMembers, "wolf@liokor.ru", true, false},
+		{mail.ListPostLocal, "wolf@liokor.ru", true, true},
+		{mail.ListPostLocal, "wolf@liokor.ru", false, false},
+		{mail.ListPostLocal, "friend@ya.ru", false, false},
+		{mail.ListPostAnyone, "spam@ya.ru", false, true},
+	}
+	for _, c := range cases {
+		list.Posting = c.posting
+		err := checkListSender(list, mail.Mail{Sender: c.sender}, c.authenticated)
+		if (err == nil) != c.allowed {
+			t.Errorf("Wrong check of %s for %q list: %v\n", c.sender, c.posting, err)
+		}
+	}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{mockRep, config, nil}
+	err := mailUC.UpdateListPosting("lio", 1, "everyone")
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Didn't reject unknown policy: %v\n", err)
+	}
+	mockRep.EXPECT().UpdateListPosting("lio", 1, mail.ListPostLocal).Return(nil).Times(1)
+	err = mailUC.UpdateListPosting("lio", 1, " Local ")
+	if err != nil {
+		t.Errorf("Didn't update posting policy: %v\n", err)
+	}
+}
+
+func TestMailboxMembers(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	mockRep.EXPECT().GetMailboxMember("support", "lio").Return(mail.MailboxMember{"support", "lio", true}, nil).AnyTimes()
+	mockRep.EXPECT().GetMailboxMember("support", "alt").Return(mail.MailboxMember{"support", "alt", false}, nil).AnyTimes()
+	mockRep.EXPECT().GetMailboxMember("support", "wolf").Return(mail.MailboxMember{}, mail.AccessDeniedError{"not a member of the mailbox"}).AnyTimes()
+
+	newMember := mail.MailboxMember{Mailbox: "support", Member: "wolf"}
+	mockRep.EXPECT().AddMailboxMember(newMember).Return(nil).Times(1)
+	err := mailUC.AddMailboxMember("lio", newMember)
+	if err != nil {
+		t.Errorf("Admin didn't add member: %v\n", err)
+	}
+
+	err = mailUC.AddMailboxMember("alt", newMember)
+	switch err.(type) {
+	case mail.AccessDeniedError:
+		break
+	default:
+		t.Errorf("Not admin added member: %v\n", err)
+	}
+
+	err = mailUC.CheckMailboxAccess("support", "wolf")
+	switch err.(type) {
+	case mail.AccessDeniedError:
+		break
+	default:
+		t.Errorf("Not member got access to the mailbox: %v\n", err)
+	}
+
+	// member can leave the mailbox, but can't remove others
+	mockRep.EXPECT().DeleteMailboxMember("support", "alt").Return(nil).Times(1)
+	err = mailUC.DeleteMailboxMember("alt", "support", "alt")
+	if err != nil {
+		t.Errorf("Member didn't leave the mailbox: %v\n", err)
+	}
+	err = mailUC.DeleteMailboxMember("alt", "support", "lio")
+	switch err.(type) {
+	case mail.AccessDeniedError:
+		break
+	default:
+		t.Errorf("Not admin removed member: %v\n", err)
+	}
+}
+
+func TestSenderRules(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+	expectPlainAddresses(mockRep)
+
+	owned := common.NullString{sql.NullString{String: "alt", Valid: true}}
+	patterns := []string{"spam@mail.ya.ru", "mail.ya.ru", "ya.ru", "ru"}
+
+	// allowed address wins over blocked domain
+	mockRep.EXPECT().FindSenderRules("alt", patterns).Return([]mail.SenderRule{
+		{Id: 1, Owner: owned, Pattern: "ya.ru"},
+		{Id: 2, Owner: owned, Pattern: "spam@mail.ya.ru", Allow: true},
+	}, nil).Times(1)
+	err := mailUC.CheckIncomingSender("spam@mail.ya.ru", "alt@liokor.ru")
+	if err != nil {
+		t.Errorf("Blocked allowed sender: %v\n", err)
+	}
+
+	mockRep.EXPECT().FindSenderRules("alt", patterns).Return([]mail.SenderRule{
+		{Id: 1, Owner: owned, Pattern: "ya.ru"},
+	}, nil).Times(1)
+	mockRep.EXPECT().GetDropBlocked("alt").Return(false, nil).Times(1)
+	err = mailUC.CheckIncomingSender("spam@mail.ya.ru", "alt@liokor.ru")
+	if blockedErr, ok := err.(mail.SenderBlockedError); !ok || blockedErr.Drop {
+		t.Errorf("Didn't reject blocked sender: %v\n", err)
+	}
+
+	// user's allowlist doesn't override global blocklist
+	mockRep.EXPECT().FindSenderRules("alt", patterns).Return([]mail.SenderRule{
+		{Id: 3, Pattern: "ya.ru"},
+		{Id: 4, Owner: owned, Pattern: "spam@mail.ya.ru", Allow: true},
+	}, nil).Times(1)
+	err = mailUC.CheckIncomingSender("spam@mail.ya.ru", "alt@liokor.ru")
+	if blockedErr, ok := err.(mail.SenderBlockedError); !ok || blockedErr.Drop {
+		t.Errorf("Allowed globally blocked sender: %v\n", err)
+	}
+
+	mockRep.EXPECT().FindSenderRules("alt", patterns).Return([]mail.SenderRule{
+		{Id: 3, Pattern: "ya.ru"},
+	}, nil).Times(1)
+	err = mailUC.CheckIncomingSender("spam@mail.ya.ru", "alt@liokor.ru")
+	if _, ok := err.(mail.SenderBlockedError); !ok {
+		t.Errorf("Didn't reject globally blocked sender: %v\n", err)
+	}
+
+	// dropped mail isn't saved
+	mockRep.EXPECT().FindSenderRules("alt", patterns).Return([]mail.SenderRule{
+		{Id: 1, Owner: owned, Pattern: "ya.ru"},
+	}, nil).Times(1)
+	mockRep.EXPECT().GetDropBlocked("alt").Return(true, nil).Times(1)
+	err = mailUC.ReceiveEmail(mail.Mail{
+		Sender:    "spam@mail.ya.ru",
+		Recipient: "alt@liokor.ru",
+		Subject:   "Buy",
+		Body:      "Buy",
+	})
+	if err != nil {
+		t.Errorf("Didn't drop blocked mail: %v\n", err)
+	}
+}
+
+func TestSendEmailToBlockingUser(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	expectNoWebhooks(mockRep)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+	expectPlainAddresses(mockRep)
+	expectNoSignatures(mockRep)
+	mockRep.EXPECT().CollectContact(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockRep.EXPECT().FindSenderRules("altana", gomock.Any()).Return([]mail.SenderRule{
+		{Id: 1, Owner: common.NullString{sql.NullString{String: "altana", Valid: true}}, Pattern: "alt@liokor.ru"},
+	}, nil).AnyTimes()
+
+	email := mail.Mail{
+		Sender:    "alt",
+		Recipient: "altana@liokor.ru",
+		Body:      "Testing",
+		Subject:   "Test",
+	}
+
+	mockRep.EXPECT().GetDropBlocked("altana").Return(false, nil).Times(1)
+	_, err := mailUC.SendEmail(email)
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Sent mail to blocking user: %v\n", err)
+	}
+
+	// only the sender keeps the copy
+	dropped := mail.Mail{
+		Sender:             "alt@liokor.ru",
+		Recipient:          "altana@liokor.ru",
+		Body:               "<p>Testing</p>\n",
+		Subject:            "Test",
+		DeletedByRecipient: true,
+	}
+	mockRep.EXPECT().GetDropBlocked("altana").Return(true, nil).Times(1)
+	mockRep.EXPECT().AddMail(dropped, []string{"alt@liokor.ru"}).Return(1, nil).Times(1)
+	_, err = mailUC.SendEmail(email)
+	if err != nil {
+		t.Errorf("Didn't pretend mail was sent: %v\n", err)
+	}
+}
+
+func TestGlobalBlocklist(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	adminConfig := config
+	adminConfig.Admins = []string{"lio"}
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     adminConfig,
+	}
+
+	_, err := mailUC.AddGlobalBlock("alt", "ya.ru")
+	if _, ok := err.(mail.AccessDeniedError); !ok {
+		t.Errorf("Not admin changed global blocklist: %v\n", err)
+	}
+
+	mockRep.EXPECT().AddSenderRule(mail.SenderRule{Pattern: "spam.com"}).Return(mail.SenderRule{Id: 1, Pattern: "spam.com"}, nil).Times(1)
+	_, err = mailUC.AddGlobalBlock("lio", " *.Spam.com ")
+	if err != nil {
+		t.Errorf("Admin couldn't change global blocklist: %v\n", err)
+	}
+
+	_, err = mailUC.AddGlobalBlock("lio", "not a domain")
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Added invalid pattern: %v\n", err)
+	}
+}
+
+func TestReplyEmail(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	expectNoWebhooks(mockRep)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+	expectPlainAddresses(mockRep)
+	expectNoSenderRules(mockRep)
+	expectNoSignatures(mockRep)
+	mockRep.EXPECT().CollectContact(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockRep.EXPECT().GetForwarding(gomock.Any()).Return(mail.Forwarding{}, nil).AnyTimes()
+	mockRep.EXPECT().GetVacation(gomock.Any()).Return(mail.Vacation{}, nil).AnyTimes()
+
+	original := mail.DialogueEmail{
+		Id:            7,
+		Sender:        "altana@liokor.ru",
+		Recipient:     "alt@liokor.ru",
+		Subject:       "Hi",
+		Body:          "How are you?",
+		Received_date: time.Now(),
+		MessageId:     common.NullString{sql.NullString{String: "<abc@ya.ru>", Valid: true}},
+		References:    common.NullString{sql.NullString{String: "<first@ya.ru>", Valid: true}},
+	}
+	mockRep.EXPECT().GetMail("alt@liokor.ru", 7).Return(original, nil).Times(2)
+	mockRep.EXPECT().AddMail(gomock.Any(), gomock.Any()).DoAndReturn(func(email mail.Mail, owners []string) (int, error) {
+		if email.Recipient != "altana@liokor.ru" || email.Subject != "Re: Hi" {
+			t.Errorf("Wrong reply: %v\n", email)
+		}
+		if email.Headers["In-Reply-To"] != "<abc@ya.ru>" || email.References != "<first@ya.ru> <abc@ya.ru>" {
+			t.Errorf("Reply isn't threaded: %v\n", email)
+		}
+		if !strings.HasPrefix(email.Body, "<p>Fine</p>") || !strings.Contains(email.Body, "<blockquote>") ||
+			!strings.Contains(email.Body, "How are you?") {
+			t.Errorf("Original isn't quoted: %s\n", email.Body)
+		}
+		return 8, nil
+	}).Times(2)
+
+	replies, err := mailUC.ReplyEmail("alt", 7, mail.Mail{Body: "Fine"}, false)
+	if err != nil || len(replies) != 1 {
+		t.Errorf("Didn't reply: %v %v\n", replies, err)
+	}
+
+	// the only other participant is the sender
+	replies, err = mailUC.ReplyEmail("alt", 7, mail.Mail{Body: "Fine"}, true)
+	if err != nil || len(replies) != 1 {
+		t.Errorf("Didn't reply to all: %v %v\n", replies, err)
+	}
+
+	mockRep.EXPECT().GetMail("alt@liokor.ru", 9).Return(mail.DialogueEmail{}, mail.InvalidEmailError{"mail doesn't exist"}).Times(1)
+	_, err = mailUC.ReplyEmail("alt", 9, mail.Mail{Body: "Fine"}, false)
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Replied to not existing mail: %v\n", err)
+	}
+}
+
+func TestForwardEmail(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	expectNoWebhooks(mockRep)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+	expectPlainAddresses(mockRep)
+	expectNoSenderRules(mockRep)
+	expectNoSignatures(mockRep)
+	mockRep.EXPECT().CollectContact(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockRep.EXPECT().GetForwarding(gomock.Any()).Return(mail.Forwarding{}, nil).AnyTimes()
+	mockRep.EXPECT().GetVacation(gomock.Any()).Return(mail.Vacation{}, nil).AnyTimes()
+
+	original := mail.DialogueEmail{
+		Id:            7,
+		Sender:        "alt@liokor.ru",
+		Recipient:     "altana@liokor.ru",
+		Subject:       "Fwd: Report",
+		Body:          "<p>See <b>report</b></p><img src=\"x\" onerror=\"alert(1)\">",
+		Received_date: time.Now(),
+	}
+	mockRep.EXPECT().GetMail("alt@liokor.ru", 7).Return(original, nil).Times(1)
+	mockRep.EXPECT().GetMailRaw(7).Return(nil, nil).Times(1)
+	mockRep.EXPECT().AddMail(gomock.Any(), gomock.Any()).DoAndReturn(func(email mail.Mail, owners []string) (int, error) {
+		if email.HasAttachment || email.Raw != nil {
+			t.Errorf("Forward without attachments has them: %v\n", email)
+		}
+		if email.Recipient != "boss@liokor.ru" || email.Subject != "Fwd: Report" {
+			t.Errorf("Wrong forward: %v\n", email)
+		}
+		if email.References != "<7@liokor.ru>" || email.Headers["In-Reply-To"] != "" {
+			t.Errorf("Wrong forward references: %v\n", email)
+		}
+		if !strings.Contains(email.Body, "Forwarded message") || !strings.Contains(email.Body, "<b>report</b>") {
+			t.Errorf("Original isn't included: %s\n", email.Body)
+		}
+		if strings.Contains(email.Body, "onerror") || strings.Contains(email.Quote, "onerror") {
+			t.Errorf("Original isn't sanitized: %s\n", email.Body)
+		}
+		return 8, nil
+	}).Times(1)
+
+	_, err := mailUC.ForwardEmail("alt", 7, mail.Mail{Recipient: "boss@liokor.ru", Body: "FYI"})
+	if err != nil {
+		t.Errorf("Didn't forward: %v\n", err)
+	}
+
+	// stored raw message carries the attachments to other servers
+	raw := []byte("From: friend@ya.ru\r\nSubject: Report\r\nContent-Type: multipart/mixed; boundary=\"B\"\r\n\r\n--B--\r\n")
+	smtpRelayMail = func(envelopeFrom string, to string, message []byte, key *utils.DkimKey) error {
+		if to != "friend@ya.ru" || !bytes.Contains(message, []byte("Content-Type: message/rfc822")) || !bytes.Contains(message, raw) {
+			t.Errorf("Wrong forwarded message to %s: %s\n", to, message)
+		}
+		return nil
+	}
+	defer func() { smtpRelayMail = utils.SMTPRelayMail }()
+	mockRep.EXPECT().GetMail("alt@liokor.ru", 7).Return(original, nil).Times(1)
+	mockRep.EXPECT().GetMailRaw(7).Return(raw, nil).Times(1)
+	mockRep.EXPECT().CountMailsFromUser(gomock.Any(), gomock.Any()).Return(0, nil).Times(1)
+	mockRep.EXPECT().AddMail(gomock.Any(), gomock.Any()).DoAndReturn(func(email mail.Mail, owners []string) (int, error) {
+		if !email.HasAttachment || !bytes.Equal(email.Raw, raw) {
+			t.Errorf("Forward doesn't keep the attachments: %v\n", email)
+		}
+		return 9, nil
+	}).Times(1)
+	_, err = mailUC.ForwardEmail("alt", 7, mail.Mail{Recipient: "friend@ya.ru", Body: "FYI"})
+	if err != nil {
+		t.Errorf("Didn't forward with attachments: %v\n", err)
+	}
+}
+
+func TestSignatures(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	expectNoWebhooks(mockRep)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+	expectNoSenderRules(mockRep)
+	mockRep.EXPECT().GetAddressOwner("support", defaultDomain.Name).Return("alt", nil).AnyTimes()
+	mockRep.EXPECT().GetAddressOwner(gomock.Any(), defaultDomain.Name).DoAndReturn(func(localPart string, domain string) (string, error) {
+		return localPart, nil
+	}).AnyTimes()
+	mockRep.EXPECT().CollectContact(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockRep.EXPECT().GetForwarding(gomock.Any()).Return(mail.Forwarding{}, nil).AnyTimes()
+	mockRep.EXPECT().GetVacation(gomock.Any()).Return(mail.Vacation{}, nil).AnyTimes()
+
+	mockRep.EXPECT().CreateSignature(gomock.Any()).DoAndReturn(func(signature mail.Signature) (mail.Signature, error) {
+		if signature.Owner != "alt" || signature.Body != "Alt, support team" {
+			t.Errorf("Wrong signature: %v\n", signature)
+		}
+		if len(signature.DefaultFor) != 2 || signature.DefaultFor[0] != "alt" || signature.DefaultFor[1] != "support" {
+			t.Errorf("Wrong default addresses: %v\n", signature.DefaultFor)
+		}
+		signature.Id = 1
+		return signature, nil
+	}).Times(1)
+	_, err := mailUC.CreateSignature("alt", mail.Signature{
+		Name:       "Support",
+		Body:       " Alt, support team\n",
+		DefaultFor: common.StringList{"", "support@liokor.ru", "alt"},
+	})
+	if err != nil {
+		t.Errorf("Didn't create signature: %v\n", err)
+	}
+
+	_, err = mailUC.CreateSignature("alt", mail.Signature{Name: "Other", Body: "Alt", DefaultFor: common.StringList{"altana"}})
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Made signature default for address of another user: %v\n", err)
+	}
+
+	_, err = mailUC.CreateSignature("alt", mail.Signature{Name: "Empty"})
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Created empty signature: %v\n", err)
+	}
+
+	// default signature of the alias is appended before Markdown is rendered
+	mockRep.EXPECT().GetDefaultSignature("alt", "support").Return(mail.Signature{Id: 1, Body: "*Alt*, support team"}, nil).Times(1)
+	mockRep.EXPECT().AddMail(gomock.Any(), gomock.Any()).DoAndReturn(func(email mail.Mail, owners []string) (int, error) {
+		if email.Body != "<p>Hello</p>\n\n<p><em>Alt</em>, support team</p>\n" {
+			t.Errorf("Signature isn't appended: %q\n", email.Body)
+		}
+		return 1, nil
+	}).Times(1)
+	_, err = mailUC.SendEmail(mail.Mail{Sender: "alt", Recipient: "altana@liokor.ru", Alias: "support", Subject: "Hi", Body: "Hello"})
+	if err != nil {
+		t.Errorf("Couldn't send email: %v\n", err)
+	}
+
+	mockRep.EXPECT().AddMail(gomock.Any(), gomock.Any()).DoAndReturn(func(email mail.Mail, owners []string) (int, error) {
+		if email.Body != "<p>Hello</p>\n" {
+			t.Errorf("Signature is appended: %q\n", email.Body)
+		}
+		return 2, nil
+	}).Times(1)
+	_, err = mailUC.SendEmail(mail.Mail{Sender: "alt", Recipient: "altana@liokor.ru", Subject: "Hi", Body: "Hello", NoSignature: true})
+	if err != nil {
+		t.Errorf("Couldn't send email: %v\n", err)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+
+	template := mail.Template{
+		Id:      1,
+		Owner:   "alt",
+		Subject: "Hello, {{recipient.name}}",
+		Body:    "Dear **{{ recipient.name }}**,\n\n<script>alert(1)</script>{{unknown}}\n\n{{sender.name}} <{{sender.email}}>",
+	}
+	mockRep.EXPECT().GetTemplate("alt", 1).Return(template, nil).Times(2)
+	mockRep.EXPECT().GetFullName("alt").Return("Alt Liokor", nil).Times(2)
+	mockRep.EXPECT().GetContactName("alt", "john@ya.ru").Return("John", nil).Times(1)
+	rendered, err := mailUC.RenderTemplate("alt", 1, " John@ya.ru")
+	if err != nil {
+		t.Errorf("Didn't render template: %v\n", err)
+	}
+	if rendered.Subject != "Hello, John" ||
+		rendered.Markdown != "Dear **John**,\n\n<script>alert(1)</script>{{unknown}}\n\nAlt Liokor <alt@liokor.ru>" {
+		t.Errorf("Wrong placeholders expansion: %v\n", rendered)
+	}
+	if !strings.Contains(rendered.Html, "<strong>John</strong>") || strings.Contains(rendered.Html, "<script>") {
+		t.Errorf("Wrong preview: %s\n", rendered.Html)
+	}
+
+	mockRep.EXPECT().GetContactName("alt", "doe@ya.ru").Return("", nil).Times(1)
+	rendered, err = mailUC.RenderTemplate("alt", 1, "doe@ya.ru")
+	if err != nil || rendered.Subject != "Hello, doe" {
+		t.Errorf("Didn't fall back to local part: %v %v\n", rendered, err)
+	}
+}
+
+func TestPreviewEmail(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+	expectPlainAddresses(mockRep)
+
+	mockRep.EXPECT().GetDefaultSignature("alt", "alt").Return(mail.Signature{Id: 1, Body: "*Alt*"}, nil).Times(1)
+	preview, err := mailUC.PreviewEmail("alt", mail.Mail{Body: "Hello <b onclick=\"x()\">there</b>"})
+	if err != nil {
+		t.Errorf("Didn't preview mail: %v\n", err)
+	}
+	if preview.Html != "<p>Hello there</p>\n\n<p><em>Alt</em></p>\n" {
+		t.Errorf("Preview differs from sent mail: %q\n", preview.Html)
+	}
+	if strings.Join(preview.Stripped, ",") != "<b>,<b onclick>" {
+		t.Errorf("Wrong stripped markup: %v\n", preview.Stripped)
+	}
+
+	preview, err = mailUC.PreviewEmail("alt", mail.Mail{Body: "Hello", NoSignature: true})
+	if err != nil || preview.Html != "<p>Hello</p>\n" || len(preview.Stripped) != 0 {
+		t.Errorf("Wrong preview without signature: %v %v\n", preview, err)
+	}
+}
+
+func TestReadReceipts(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+
+	var relayed []byte
+	smtpRelayMail = func(envelopeFrom string, to string, message []byte, key *utils.DkimKey) error {
+		if envelopeFrom != "" || to != "friend@ya.ru" {
+			t.Errorf("Wrong MDN envelope: %s %s\n", envelopeFrom, to)
+		}
+		relayed = message
+		return nil
+	}
+	// MDNs are sent after the mails are returned
+	pending := make([]func(), 0)
+	runAsync = func(f func()) { pending = append(pending, f) }
+	defer func() {
+		smtpRelayMail = utils.SMTPRelayMail
+		runAsync = func(f func()) { go f() }
+	}()
+
+	read := []mail.DialogueEmail{
+		{Id: 1, Sender: "friend@ya.ru", Subject: "Hi",
+			MessageId:     common.NullString{sql.NullString{String: "<abc@ya.ru>", Valid: true}},
+			NotifyAddress: common.NullString{sql.NullString{String: "friend@ya.ru", Valid: true}}},
+	}
+	mockRep.EXPECT().GetMailsForUser("alt@liokor.ru", "friend@ya.ru", mail.Page{Limit: 10}).Return([]mail.DialogueEmail{}, nil).Times(1)
+	mockRep.EXPECT().GetReadReceipts("alt").Return(true, nil).Times(1)
+	mockRep.EXPECT().SaveReadReceipts("alt@liokor.ru", "friend@ya.ru").Return(read, nil).Times(1)
+	mockRep.EXPECT().ReadMail([]string{"alt@liokor.ru"}, "friend@ya.ru").Return(nil).Times(1)
+	mockRep.EXPECT().ReadDialogue("alt", "friend@ya.ru").Return(nil).Times(1)
+	_, err := mailUC.GetEmails("alt", "friend@ya.ru", mail.Page{Limit: 10})
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+	if relayed != nil || len(pending) != 1 {
+		t.Fatalf("MDN isn't sent asynchronously: %d\n", len(pending))
+	}
+	pending[0]()
+
+	report, ok := utils.ParseReport(relayed)
+	if !ok || report.Disposition != "displayed" || report.OriginalMessageId != "<abc@ya.ru>" {
+		t.Errorf("Wrong MDN sent: %s\n", relayed)
+	}
+}
+
+func TestReceiveReports(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	expectNoWebhooks(mockRep)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+	expectPlainAddresses(mockRep)
+	expectNoSenderRules(mockRep)
+	mockRep.EXPECT().GetForwarding(gomock.Any()).Return(mail.Forwarding{}, nil).AnyTimes()
+	mockRep.EXPECT().GetVacation(gomock.Any()).Return(mail.Vacation{}, nil).AnyTimes()
+	mockRep.EXPECT().AddMail(gomock.Any(), gomock.Any()).Return(1, nil).AnyTimes()
+
+	dsn := []byte("Content-Type: multipart/report; report-type=delivery-status; boundary=\"B\"\r\n\r\n" +
+		"--B\r\nContent-Type: message/delivery-status\r\n\r\n" +
+		"Reporting-MTA: dns; mx.ya.ru\r\n\r\n" +
+		"Original-Recipient: rfc822; friend@ya.ru\r\n" +
+		"Final-Recipient: rfc822; nobody@ya.ru\r\nAction: failed\r\nStatus: 5.1.1\r\n\r\n" +
+		"--B\r\nContent-Type: text/rfc822-headers\r\n\r\n" +
+		"Message-ID: <12@liokor.ru>\r\n\r\n" +
+		"--B--\r\n")
+	// the mail was sent to the original recipient and forwarded by its server
+	mockRep.EXPECT().UpdateDeliveryStatus(12, "alt@liokor.ru", "friend@ya.ru", mail.MailStatusFailed).Return(nil).Times(1)
+	err := mailUC.ReceiveEmail(mail.Mail{
+		Sender:    "MAILER-DAEMON@ya.ru",
+		Recipient: "alt@liokor.ru",
+		Subject:   "Undelivered Mail Returned to Sender",
+		Body:      "Your message could not be delivered",
+		Raw:       dsn,
+	})
+	if err != nil {
+		t.Errorf("Didn't receive DSN: %v\n", err)
+	}
+
+	mdn := utils.FormatMDN(utils.MDN{
+		From:              "friend@ya.ru",
+		To:                "alt@liokor.ru",
+		Subject:           "Hi",
+		OriginalMessageId: "<13@liokor.ru>",
+		ReportingDomain:   "ya.ru",
+	})
+	mockRep.EXPECT().SaveMailRead(13, "alt@liokor.ru", "friend@ya.ru").Return(nil).Times(1)
+	err = mailUC.ReceiveEmail(mail.Mail{
+		Sender:    "friend@ya.ru",
+		Recipient: "alt@liokor.ru",
+		Subject:   "Read: Hi",
+		Body:      "Displayed",
+		Raw:       mdn,
+	})
+	if err != nil {
+		t.Errorf("Didn't receive MDN: %v\n", err)
+	}
+
+	// reports about other domains' mail are ignored
+	mdn = utils.FormatMDN(utils.MDN{From: "friend@ya.ru", To: "alt@liokor.ru", OriginalMessageId: "<13@ya.ru>"})
+	err = mailUC.ReceiveEmail(mail.Mail{Sender: "friend@ya.ru", Recipient: "alt@liokor.ru", Subject: "Read", Body: "Displayed", Raw: mdn})
+	if err != nil {
+		t.Errorf("Didn't receive MDN: %v\n", err)
+	}
+}
+
+func TestBounceEmail(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+
+	var relayedTo string
+	var relayed []byte
+	smtpRelayMail = func(envelopeFrom string, to string, message []byte, key *utils.DkimKey) error {
+		if envelopeFrom != "" {
+			t.Errorf("DSN isn't sent with null sender: %s\n", envelopeFrom)
+		}
+		relayedTo, relayed = to, message
+		return nil
+	}
+	// DSN is sent after the SMTP session is answered
+	pending := make([]func(), 0)
+	runAsync = func(f func()) { pending = append(pending, f) }
+	defer func() {
+		smtpRelayMail = utils.SMTPRelayMail
+		runAsync = func(f func()) { go f() }
+	}()
+
+	email := mail.Mail{
+		Sender: "friend@ya.ru",
+		Raw:    []byte("From: <friend@ya.ru>\r\nMessage-ID: <abc@ya.ru>\r\n\r\nHello\r\n"),
+	}
+	failures := map[string]error{
+		"nobody@liokor.ru": common.InvalidUserError{"user doesn't exist"},
+		"alt@liokor.ru":    errors.New("connection refused"),
+	}
+	err := mailUC.BounceEmail(email, failures)
+	if err != nil || relayedTo != "" || len(pending) != 1 {
+		t.Fatalf("DSN isn't queued: %v\n", err)
+	}
+	pending[0]()
+	if relayedTo != "friend@ya.ru" {
+		t.Errorf("DSN isn't sent: %s\n", relayedTo)
+	}
+	report, ok := utils.ParseReport(relayed)
+	if !ok || report.Type != utils.ReportDeliveryStatus || report.OriginalMessageId != "<abc@ya.ru>" {
+		t.Fatalf("Wrong DSN sent: %s\n", relayed)
+	}
+	if len(report.Recipients) != 2 || report.Recipients[0].Status != "5.3.0" || report.Recipients[1].Status != "5.1.1" {
+		t.Errorf("Wrong DSN recipients: %v\n", report.Recipients)
+	}
+	if strings.Contains(string(relayed), "connection refused") {
+		t.Errorf("Internal error is exposed in DSN: %s\n", relayed)
+	}
+
+	// bounces and local mail aren't bounced
+	relayedTo = ""
+	for _, sender := range []string{"", "MAILER-DAEMON@ya.ru", "alt@liokor.ru"} {
+		email.Sender = sender
+		err = mailUC.BounceEmail(email, failures)
+		if err != nil || len(pending) != 1 {
+			t.Errorf("Bounced mail from %q: %v\n", sender, err)
+		}
+	}
+
+	// unknown recipients are rejected before the mail is accepted, so they aren't bounced
+	mockRep.EXPECT().GetAddressOwner("nobody", "liokor.ru").Return("", common.InvalidUserError{"user doesn't exist"}).Times(1)
+	mockRep.EXPECT().GetDistributionList("nobody", "liokor.ru").Return(mail.DistributionList{}, mail.InvalidEmailError{"list doesn't exist"}).Times(1)
+	err = mailUC.CheckIncomingSender("friend@ya.ru", "nobody@liokor.ru")
+	if _, ok := err.(common.InvalidUserError); !ok {
+		t.Errorf("Didn't reject unknown recipient: %v\n", err)
+	}
+}
+
+func TestSendEmailBounce(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	expectNoWebhooks(mockRep)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+	expectPlainAddresses(mockRep)
+	expectNoSignatures(mockRep)
+	mockRep.EXPECT().CountMailsFromUser(gomock.Any(), gomock.Any()).Return(0, nil).AnyTimes()
+	mockRep.EXPECT().CollectContact(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	smtpSendMail = func(from string, to string, subject string, data string, headers map[string]string, key *utils.DkimKey) error {
+		return &textproto.Error{Code: 550, Msg: "5.1.1 User <nobody@ya.ru> unknown"}
+	}
+	defer func() { smtpSendMail = utils.SMTPSendMail }()
+
+	email := mail.Mail{
+		Sender:    "alt",
+		Recipient: "nobody@ya.ru",
+		Body:      "Testing",
+		Subject:   "Test",
+	}
+	mockRep.EXPECT().AddMail(gomock.Any(), []string{"alt@liokor.ru"}).Return(1, nil).Times(1)
+	mockRep.EXPECT().UpdateMailStatus(1, mail.MailStatusFailed).Return(nil).Times(1)
+	mockRep.EXPECT().AddMail(gomock.Any(), []string{"alt@liokor.ru"}).DoAndReturn(func(bounce mail.Mail, owners []string) (int, error) {
+		if bounce.Sender != "mailer-daemon@liokor.ru" || bounce.Recipient != "alt@liokor.ru" {
+			t.Errorf("Wrong bounce participants: %v\n", bounce)
+		}
+		if !strings.Contains(bounce.Body, "nobody@ya.ru: smtp; 550 5.1.1 User &lt;nobody@ya.ru&gt; unknown") ||
+			!strings.Contains(bounce.Body, "Message-ID: &lt;1@liokor.ru&gt;") {
+			t.Errorf("Wrong bounce body: %s\n", bounce.Body)
+		}
+		return 2, nil
+	}).Times(1)
+	_, err := mailUC.SendEmail(email)
+	if err == nil {
+		t.Errorf("Failed delivery isn't reported\n")
+	}
+
+	// temporary failure is returned to the sender without bounce
+	smtpSendMail = func(from string, to string, subject string, data string, headers map[string]string, key *utils.DkimKey) error {
+		return &textproto.Error{Code: 451, Msg: "4.7.1 Greylisted, try again later"}
+	}
+	mockRep.EXPECT().AddMail(gomock.Any(), []string{"alt@liokor.ru"}).Return(3, nil).Times(1)
+	mockRep.EXPECT().UpdateMailStatus(3, mail.MailStatusFailed).Return(nil).Times(1)
+	_, err = mailUC.SendEmail(email)
+	if err == nil {
+		t.Errorf("Temporary failure isn't reported\n")
+	}
+}
+
+func TestSnoozeDialogue(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+
+	until := time.Now().Add(time.Hour)
+	mockRep.EXPECT().SnoozeDialogue("alt", 1, &until).Return(nil).Times(1)
+	err := mailUC.SnoozeDialogue("alt", 1, until)
+	if err != nil {
+		t.Errorf("Didn't snooze dialogue: %v\n", err)
+	}
+
+	err = mailUC.SnoozeDialogue("alt", 1, time.Now().Add(-time.Hour))
+	switch err.(type) {
+	case mail.InvalidEmailError:
+		break
+	default:
+		t.Errorf("Snoozed dialogue into the past: %v\n", err)
+	}
+
+	mockRep.EXPECT().SnoozeDialogue("alt", 1, nil).Return(nil).Times(1)
+	err = mailUC.UnsnoozeDialogue("alt", 1)
+	if err != nil {
+		t.Errorf("Didn't unsnooze dialogue: %v\n", err)
+	}
+
+	mockRep.EXPECT().WakeSnoozedDialogues(gomock.Any()).Return(2, nil).Times(1)
+	err = mailUC.WakeSnoozedDialogues()
+	if err != nil {
+		t.Errorf("Didn't wake dialogues: %v\n", err)
+	}
+}
+
+func TestGetDialoguesPinned(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+
+	pinned := []mail.Dialogue{{Id: 1, Email: "lio@liokor.ru", Pinned: true}}
+	others := []mail.Dialogue{{Id: 2, Email: "ser@liokor.ru"}}
+
+	// pinned dialogues start the first page only
+	firstPage := mail.Page{Limit: 10}
+	nextPage := mail.Page{Cursor: &mail.Cursor{Date: time.Now(), Id: 3}, Limit: 10}
+	mockRep.EXPECT().GetDialoguesInFolder("alt", 0, firstPage).Return(others, nil).Times(1)
+	mockRep.EXPECT().GetDialoguesInFolder("alt", 0, nextPage).Return(others, nil).Times(1)
+	mockRep.EXPECT().GetPinnedDialogues("alt", 0).Return(pinned, nil).Times(1)
+	dialogues, err := mailUC.GetDialogues("alt", "", 0, firstPage)
+	if err != nil || len(dialogues.Dialogues) != 2 || dialogues.Dialogues[0].Id != 1 {
+		t.Errorf("Pinned dialogues aren't first: %v %v\n", dialogues, err)
+	}
+
+	dialogues, err = mailUC.GetDialogues("alt", "", 0, nextPage)
+	if err != nil || len(dialogues.Dialogues) != 1 || dialogues.Dialogues[0].Id != 2 {
+		t.Errorf("Pinned dialogues are repeated on the next page: %v %v\n", dialogues, err)
+	}
+}
+
+func TestGetDialoguesPages(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+
+	date := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	// dialogues with the same date are told apart by ids
+	newer := []mail.Dialogue{{Id: 5, Received_date: date}, {Id: 4, Received_date: date}, {Id: 3, Received_date: date}}
+	page := mail.Page{Cursor: &mail.Cursor{Date: date, Id: 6}, Limit: 2}
+	mockRep.EXPECT().FindDialogues("alt", "a", page).Return(newer, nil).Times(1)
+	result, err := mailUC.GetDialogues("alt", "a", 0, page)
+	if err != nil || len(result.Dialogues) != 2 || !result.HasMore {
+		t.Fatalf("Wrong page: %v %v\n", result, err)
+	}
+	next, err := mail.ParseCursor(result.NextCursor)
+	if err != nil || next.Id != 4 || !next.Date.Equal(date) || next.Backward {
+		t.Errorf("Wrong next cursor: %v %v\n", next, err)
+	}
+	prev, err := mail.ParseCursor(result.PrevCursor)
+	if err != nil || prev.Id != 5 || !prev.Backward {
+		t.Errorf("Wrong previous cursor: %v %v\n", prev, err)
+	}
+
+	// backward page is selected in ascending order
+	page = mail.Page{Cursor: &prev, Limit: 2}
+	mockRep.EXPECT().FindDialogues("alt", "a", page).Return([]mail.Dialogue{{Id: 6, Received_date: date}}, nil).Times(1)
+	result, err = mailUC.GetDialogues("alt", "a", 0, page)
+	if err != nil || len(result.Dialogues) != 1 || result.HasMore || result.NextCursor != "" {
+		t.Errorf("Wrong backward page: %v %v\n", result, err)
+	}
+	// previous cursor of backward page leads back to older dialogues
+	prev, err = mail.ParseCursor(result.PrevCursor)
+	if err != nil || prev.Id != 6 || prev.Backward {
+		t.Errorf("Wrong previous cursor of backward page: %v %v\n", prev, err)
+	}
+
+	page = mail.Page{Cursor: &mail.Cursor{Date: date, Id: 6, Backward: true}, Limit: 2}
+	mockRep.EXPECT().FindDialogues("alt", "a", page).Return([]mail.Dialogue{{Id: 7}, {Id: 8}, {Id: 9}}, nil).Times(1)
+	result, err = mailUC.GetDialogues("alt", "a", 0, page)
+	if err != nil || len(result.Dialogues) != 2 || result.Dialogues[0].Id != 8 || result.Dialogues[1].Id != 7 {
+		t.Errorf("Backward page isn't in the list order: %v %v\n", result, err)
+	}
+
+	_, err = mail.ParseCursor("abc")
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Parsed invalid cursor: %v\n", err)
+	}
+}
+
+func TestGetFoldersPage(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+
+	folders := []mail.Folder{{Id: 3, Position: 0}, {Id: 1, Position: 1}}
+	mockRep.EXPECT().GetFolders(1).Return(folders, nil).AnyTimes()
+	mockRep.EXPECT().GetSmartFolders("alt").Return([]mail.SmartFolder{{Id: 2, Name: "Smart"}}, nil).AnyTimes()
+	mockRep.EXPECT().CountSmartFoldersUnread("alt", "alt@liokor.ru", gomock.Any()).Return([]int{0}, nil).AnyTimes()
+
+	result, err := mailUC.GetFoldersPage("alt", 1, mail.Page{Limit: 2})
+	if err != nil || len(result.Folders) != 2 || result.Folders[0].Id != 3 || !result.HasMore {
+		t.Fatalf("Wrong first page: %v %v\n", result, err)
+	}
+	next, _ := mail.ParseCursor(result.NextCursor)
+	result, err = mailUC.GetFoldersPage("alt", 1, mail.Page{Cursor: &next, Limit: 2})
+	if err != nil || len(result.Folders) != 1 || result.Folders[0].Id != 2 || result.HasMore {
+		t.Fatalf("Smart folders don't follow regular ones: %v %v\n", result, err)
+	}
+	prev, _ := mail.ParseCursor(result.PrevCursor)
+	result, err = mailUC.GetFoldersPage("alt", 1, mail.Page{Cursor: &prev, Limit: 1})
+	if err != nil || len(result.Folders) != 1 || result.Folders[0].Id != 1 || !result.HasMore {
+		t.Errorf("Wrong previous page: %v %v\n", result, err)
+	}
+}
+
+func TestBulk(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+
+	invalid := []mail.Bulk{
+		{Target: "users", Action: mail.BulkRead, Ids: []int{1}},
+		{Target: mail.BulkMails, Action: mail.BulkMove, Ids: []int{1}},
+		{Target: mail.BulkDialogues, Action: mail.BulkRestore, Ids: []int{1}},
+		{Target: mail.BulkDialogues, Action: mail.BulkRead},
+		{Target: mail.BulkDialogues, Action: mail.BulkRead, Ids: make([]int, mail.MaxBulkItems+1)},
+		{Target: mail.BulkMails, Action: mail.BulkLabel, Ids: []int{1}, Label: " "},
+		{Target: mail.BulkMails, Action: mail.BulkLabel, Ids: []int{1}, Label: "work, home"},
+		{Target: mail.BulkMails, Action: mail.BulkLabel, Ids: []int{1}, Label: strings.Repeat("a", 65)},
+	}
+	for _, bulk := range invalid {
+		_, err := mailUC.Bulk("alt", 1, bulk)
+		if _, ok := err.(mail.InvalidEmailError); !ok {
+			t.Errorf("Didn't fail on invalid bulk %v: %v\n", bulk, err)
+		}
+	}
+
+	mockRep.EXPECT().GetFolders(1).Return([]mail.Folder{{Id: 3, FolderName: "Work", Owner: 1}}, nil).Times(2)
+	_, err := mailUC.Bulk("alt", 1, mail.Bulk{Target: mail.BulkDialogues, Action: mail.BulkMove, Ids: []int{1}, Folder: 4})
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Moved dialogues to folder of another user: %v\n", err)
+	}
+	move := mail.Bulk{Target: mail.BulkDialogues, Action: mail.BulkMove, Ids: []int{1, 2}, Folder: 3}
+	moved := []mail.BulkResult{{Id: 1, Ok: true}, {Id: 2, Error: "dialogue doesn't exist"}}
+	mockRep.EXPECT().BulkDialogues("alt", "liokor.ru", move).Return(moved, nil).Times(1)
+	results, err := mailUC.Bulk("alt", 1, move)
+	if err != nil || !reflect.DeepEqual(results, moved) {
+		t.Errorf("Wrong results: %v %v\n", results, err)
+	}
+
+	// read webhook fires once per dialogue
+	read := mail.Bulk{Target: mail.BulkMails, Action: mail.BulkRead, Ids: []int{5, 6, 7}}
+	mockRep.EXPECT().BulkMails("alt", "liokor.ru", read).Return([]mail.BulkResult{
+		{Id: 5, Ok: true, Other: "lio@liokor.ru"},
+		{Id: 6, Ok: true, Other: "lio@liokor.ru"},
+		{Id: 7, Error: "mail doesn't exist"},
+	}, nil).Times(1)
+	mockRep.EXPECT().GetWebhooks("alt").Return([]mail.Webhook{}, nil).Times(1)
+	_, err = mailUC.Bulk("alt", 1, read)
+	if err != nil {
+		t.Errorf("Didn't read mails: %v\n", err)
+	}
+}
+
+func TestMoveFolder(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+
+	// Work > Projects > Archive, Personal
+	folders := []mail.Folder{
+		{Id: 1, FolderName: "Work", Owner: 1},
+		{Id: 2, FolderName: "Projects", Owner: 1, Parent: 1},
+		{Id: 3, FolderName: "Archive", Owner: 1, Parent: 2},
+		{Id: 4, FolderName: "Personal", Owner: 1},
+	}
+	mockRep.EXPECT().GetFolders(1).Return(folders, nil).AnyTimes()
+
+	mockRep.EXPECT().MoveFolder(1, 2, 4, 3).Return(nil).Times(1)
+	folder, err := mailUC.MoveFolder(1, 2, 4, 3)
+	if err != nil || folder.Parent != 4 || folder.Position != 3 {
+		t.Errorf("Didn't move folder: %v %v\n", folder, err)
+	}
+
+	for _, parent := range []int{1, 3, 5} {
+		_, err = mailUC.MoveFolder(1, 1, parent, 0)
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			break
+		default:
+			t.Errorf("Moved folder into %d: %v\n", parent, err)
+		}
+	}
+
+	mockRep.EXPECT().CreateFolder(1, "Drafts", 2).Return(mail.Folder{Id: 5, Parent: 2}, nil).Times(1)
+	_, err = mailUC.CreateFolder(1, "Drafts", 2)
+	if err != nil {
+		t.Errorf("Didn't create subfolder: %v\n", err)
+	}
+	_, err = mailUC.CreateFolder(1, "Drafts", 7)
+	switch err.(type) {
+	case mail.InvalidEmailError:
+		break
+	default:
+		t.Errorf("Created folder in other's folder: %v\n", err)
+	}
+}
+
+func TestSmartFolders(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+
+	query := mail.SmartQuery{SenderDomain: "ya.ru", Unread: true}
+	mockRep.EXPECT().CreateSmartFolder(mail.SmartFolder{Owner: "alt", Name: "Yandex", SmartQuery: query}).
+		DoAndReturn(func(folder mail.SmartFolder) (mail.SmartFolder, error) {
+			folder.Id = 3
+			return folder, nil
+		}).Times(1)
+	folder, err := mailUC.CreateSmartFolder("alt", mail.SmartFolder{Name: " Yandex ", SmartQuery: mail.SmartQuery{SenderDomain: "@Ya.ru", Unread: true}})
+	if err != nil || folder.Id != 3 {
+		t.Errorf("Didn't create smart folder: %v %v\n", folder, err)
+	}
+
+	after := time.Now()
+	before := after.Add(-time.Hour)
+	for _, invalid := range []mail.SmartFolder{
+		{Name: ""},
+		{Name: "Bad", SmartQuery: mail.SmartQuery{SenderDomain: "ya ru"}},
+		{Name: "Empty", SmartQuery: mail.SmartQuery{After: &after, Before: &before}},
+	} {
+		_, err = mailUC.CreateSmartFolder("alt", invalid)
+		switch err.(type) {
+		case mail.InvalidEmailError:
+			break
+		default:
+			t.Errorf("Created invalid smart folder %v: %v\n", invalid, err)
+		}
+	}
+
+	// smart folders follow the manual ones with live unread counts
+	mockRep.EXPECT().GetFolders(1).Return([]mail.Folder{{Id: 3, FolderName: "Work", Owner: 1}}, nil).Times(1)
+	mockRep.EXPECT().GetSmartFolders("alt").Return([]mail.SmartFolder{folder}, nil).Times(1)
+	mockRep.EXPECT().CountSmartFoldersUnread("alt", "alt@liokor.ru", []mail.SmartQuery{query}).Return([]int{2}, nil).Times(1)
+	folders, err := mailUC.GetFolders("alt", 1)
+	if err != nil || len(folders) != 2 || folders[1].Smart == nil || folders[1].Unread != 2 || folders[0].Smart != nil ||
+		folders[1].Position != 1 {
+		t.Errorf("Wrong folders: %v %v\n", folders, err)
+	}
+
+	mockRep.EXPECT().GetSmartFolder("alt", 3).Return(folder, nil).Times(1)
+	mockRep.EXPECT().GetSmartFolderDialogues("alt", "alt@liokor.ru", query, mail.Page{Limit: 10}).Return([]mail.Dialogue{}, nil).Times(1)
+	_, err = mailUC.GetSmartFolderDialogues("alt", 3, mail.Page{Limit: 10})
+	if err != nil {
+		t.Errorf("Didn't get smart folder dialogues: %v\n", err)
+	}
+}
+
+func TestCreateWebhook(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{mockRep, config, nil}
+
+	invalid := []mail.Webhook{
+		{URL: "ftp://bots.ru/hook", Events: common.StringList{mail.WebhookSent}},
+		{URL: "/hook", Events: common.StringList{mail.WebhookSent}},
+		{URL: "https://bots.ru/hook", Events: common.StringList{"deleted"}},
+		{URL: "https://bots.ru/hook"},
+		{URL: "http://127.0.0.1:8080/hook", Events: common.StringList{mail.WebhookSent}},
+		{URL: "http://169.254.169.254/latest/meta-data", Events: common.StringList{mail.WebhookSent}},
+		{URL: "http://localhost/hook", Events: common.StringList{mail.WebhookSent}},
+	}
+	for _, webhook := range invalid {
+		_, err := mailUC.CreateWebhook("alt", webhook)
+		if _, ok := err.(mail.InvalidEmailError); !ok {
+			t.Errorf("Didn't reject webhook %v: %v\n", webhook, err)
+		}
+	}
+
+	mockRep.EXPECT().
+		CreateWebhook(gomock.Any()).
+		DoAndReturn(func(webhook mail.Webhook) (mail.Webhook, error) {
+			if webhook.Owner != "alt" || webhook.URL != "https://bots.ru/hook" || len(webhook.Secret) == 0 {
+				t.Errorf("Wrong webhook: %v\n", webhook)
+			}
+			if strings.Join(webhook.Events, ",") != "sent,read" {
+				t.Errorf("Wrong webhook events: %v\n", webhook.Events)
+			}
+			webhook.Id = 1
+			return webhook, nil
+		}).
+		Times(1)
+	_, err := mailUC.CreateWebhook("alt", mail.Webhook{
+		URL:    " https://bots.ru/hook ",
+		Events: common.StringList{"Sent", "read", "sent"},
+	})
+	if err != nil {
+		t.Errorf("Didn't create webhook: %v\n", err)
+	}
+}
+
+func TestFireWebhooks(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{mockRep, config, nil}
+
+	runAsync = func(f func()) { f() }
+	webhookRetryDelays = []time.Duration{0, 0}
+	// test server listens on loopback, which the public client refuses
+	webhookClient = &http.Client{Timeout: 10 * time.Second}
+	defer func() {
+		runAsync = func(f func()) { go f() }
+		webhookRetryDelays = []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute, time.Hour}
+		webhookClient = utils.NewPublicHTTPClient(10 * time.Second)
+	}()
+
+	requests := 0
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(mail.WebhookSignatureHeader) != "sha256="+signWebhookPayload("secret", body) {
+			t.Errorf("Wrong signature: %s\n", r.Header.Get(mail.WebhookSignatureHeader))
+		}
+		if r.Header.Get(mail.WebhookEventHeader) != mail.WebhookReceived {
+			t.Errorf("Wrong event header: %s\n", r.Header.Get(mail.WebhookEventHeader))
+		}
+		var payload mail.WebhookPayload
+		err := json.Unmarshal(body, &payload)
+		if err != nil || payload.Mail == nil || payload.Mail.Id != 7 || payload.Owner != "alt" {
+			t.Errorf("Wrong payload: %s\n", body)
+		}
+		// the first attempt fails and is retried
+		if requests == 1 || failing {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	mockRep.EXPECT().GetWebhooks("alt").Return([]mail.Webhook{
+		{Id: 1, URL: server.URL, Events: common.StringList{mail.WebhookReceived}, Secret: "secret"},
+		{Id: 2, URL: server.URL, Events: common.StringList{mail.WebhookSent}, Secret: "secret"},
+	}, nil).Times(1)
+	var retry mail.WebhookRetry
+	gomock.InOrder(
+		mockRep.EXPECT().
+			AddWebhookDelivery(gomock.Any()).
+			DoAndReturn(func(delivery mail.WebhookDelivery) error {
+				if delivery.Webhook != 1 || delivery.Attempt != 1 || delivery.StatusCode != http.StatusBadGateway || delivery.Error == "" {
+					t.Errorf("Wrong failed delivery: %v\n", delivery)
+				}
+				return nil
+			}),
+		mockRep.EXPECT().
+			AddWebhookRetry(gomock.Any()).
+			DoAndReturn(func(r mail.WebhookRetry) error {
+				retry = r
+				return nil
+			}),
+	)
+
+	mailUC.fireWebhooks(mail.WebhookPayload{
+		Event: mail.WebhookReceived,
+		Owner: "alt",
+		Mail:  webhookMail(mail.Mail{Id: 7, Sender: "a@ya.ru", Recipient: "alt@liokor.ru", Subject: "Hi"}),
+	})
+	if requests != 1 || retry.Webhook != 1 || retry.Attempt != 2 {
+		t.Errorf("Didn't save failed delivery for retry: %d %v\n", requests, retry)
+	}
+
+	// the retry is delivered by the ticker and removed
+	retry.Id = 3
+	gomock.InOrder(
+		mockRep.EXPECT().TakeWebhookRetries(webhookRetryLease, webhookRetryBatch).Return([]mail.WebhookRetry{retry}, nil),
+		mockRep.EXPECT().
+			GetWebhook("alt", 1).
+			Return(mail.Webhook{Id: 1, URL: server.URL, Events: common.StringList{mail.WebhookReceived}, Secret: "secret"}, nil),
+		mockRep.EXPECT().
+			AddWebhookDelivery(gomock.Any()).
+			DoAndReturn(func(delivery mail.WebhookDelivery) error {
+				if delivery.Webhook != 1 || delivery.Attempt != 2 || !delivery.Succeeded() {
+					t.Errorf("Wrong delivery: %v\n", delivery)
+				}
+				return nil
+			}),
+		mockRep.EXPECT().DeleteWebhookRetry(3).Return(nil),
+	)
+	err := mailUC.RetryWebhooks()
+	if err != nil || requests != 2 {
+		t.Errorf("Didn't retry delivery: %d %v\n", requests, err)
+	}
+
+	// failed retry waits for the next attempt, the last one is given up
+	failing = true
+	last := retry
+	last.Id = 4
+	last.Attempt = len(webhookRetryDelays) + 1
+	mockRep.EXPECT().TakeWebhookRetries(webhookRetryLease, webhookRetryBatch).Return([]mail.WebhookRetry{retry, last}, nil)
+	mockRep.EXPECT().
+		GetWebhook("alt", 1).
+		Return(mail.Webhook{Id: 1, URL: server.URL, Events: common.StringList{mail.WebhookReceived}, Secret: "secret"}, nil).
+		Times(2)
+	mockRep.EXPECT().AddWebhookDelivery(gomock.Any()).Return(nil).Times(2)
+	mockRep.EXPECT().
+		UpdateWebhookRetry(gomock.Any()).
+		DoAndReturn(func(r mail.WebhookRetry) error {
+			if r.Id != 3 || r.Attempt != 3 {
+				t.Errorf("Wrong next attempt: %v\n", r)
+			}
+			return nil
+		})
+	mockRep.EXPECT().DeleteWebhookRetry(4).Return(nil)
+	err = mailUC.RetryWebhooks()
+	if err != nil {
+		t.Errorf("Didn't retry deliveries: %v\n", err)
+	}
+
+	// retries of deleted webhooks are dropped
+	mockRep.EXPECT().TakeWebhookRetries(webhookRetryLease, webhookRetryBatch).Return([]mail.WebhookRetry{retry}, nil)
+	mockRep.EXPECT().GetWebhook("alt", 1).Return(mail.Webhook{}, mail.InvalidEmailError{"webhook doesn't exist"})
+	mockRep.EXPECT().DeleteWebhookRetry(3).Return(nil)
+	err = mailUC.RetryWebhooks()
+	if err != nil {
+		t.Errorf("Didn't drop retry of deleted webhook: %v\n", err)
+	}
+}
+
+func TestTestWebhook(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{mockRep, config, nil}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(mail.WebhookEventHeader) != mail.WebhookTest {
+			t.Errorf("Wrong event header: %s\n", r.Header.Get(mail.WebhookEventHeader))
+		}
+	}))
+	defer server.Close()
+
+	mockRep.EXPECT().GetWebhook("alt", 5).Return(mail.Webhook{}, mail.InvalidEmailError{"webhook doesn't exist"}).Times(1)
+	_, err := mailUC.TestWebhook("alt", 5)
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Didn't fail for not existing webhook: %v\n", err)
+	}
+
+	mockRep.EXPECT().
+		GetWebhook("alt", 1).
+		Return(mail.Webhook{Id: 1, URL: server.URL, Events: common.StringList{mail.WebhookSent}, Secret: "secret"}, nil).
+		Times(2)
+	mockRep.EXPECT().AddWebhookDelivery(gomock.Any()).Return(nil).Times(2)
+	delivery, err := mailUC.TestWebhook("alt", 1)
+	if err != nil || delivery.Succeeded() {
+		t.Errorf("Delivered to loopback server: %v, %v\n", delivery, err)
+	}
+
+	webhookClient = &http.Client{Timeout: 10 * time.Second}
+	defer func() { webhookClient = utils.NewPublicHTTPClient(10 * time.Second) }()
+	delivery, err = mailUC.TestWebhook("alt", 1)
+	if err != nil || !delivery.Succeeded() || delivery.Event != mail.WebhookTest {
+		t.Errorf("Wrong test delivery: %v, %v\n", delivery, err)
+	}
+}
+
+func TestWebPush(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	public, private, _ := utils.GenerateVapidKeys()
+	pushConfig := config
+	pushConfig.VapidPublicKey = public
+	pushConfig.VapidPrivateKey = private
+	pushConfig.VapidSubject = "mailto:admin@liokor.ru"
+	mailUC := MailUseCase{mockRep, pushConfig, nil}
+
+	key, err := mailUC.GetVapidPublicKey()
+	if err != nil || key != public {
+		t.Errorf("Wrong public key: %s %v\n", key, err)
+	}
+
+	// browser keys are any P-256 key pair
+	userKey, _, _ := utils.GenerateVapidKeys()
+	subscription := mail.PushSubscription{
+		Endpoint: "https://push.example.com/send/abc",
+		Keys:     mail.PushKeys{P256dh: userKey, Auth: "BTBZMqHH6r4Tts7J_aSIgg"},
+	}
+	invalid := subscription
+	invalid.Endpoint = "http://push.example.com/send/abc"
+	err = mailUC.SubscribePush("alt", "token", invalid)
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Didn't reject insecure endpoint: %v\n", err)
+	}
+	invalid = subscription
+	invalid.Endpoint = "https://10.0.0.5/send/abc"
+	err = mailUC.SubscribePush("alt", "token", invalid)
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Didn't reject internal endpoint: %v\n", err)
+	}
+	invalid = subscription
+	invalid.Keys.P256dh = "abc"
+	err = mailUC.SubscribePush("alt", "token", invalid)
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Didn't reject invalid key: %v\n", err)
+	}
+
+	saved := subscription
+	saved.Owner = "alt"
+	saved.Session = "token"
+	mockRep.EXPECT().SavePushSubscription(saved).Return(nil).Times(1)
+	err = mailUC.SubscribePush("alt", "token", subscription)
+	if err != nil {
+		t.Errorf("Didn't subscribe: %v\n", err)
+	}
+
+	runAsync = func(f func()) { f() }
+	defer func() {
+		runAsync = func(f func()) { go f() }
+		sendWebPush = utils.SendWebPush
+	}()
+	sent := 0
+	sendWebPush = func(endpoint string, p256dh string, auth string, payload []byte, keys utils.VapidKeys, subject string) (int, error) {
+		sent++
+		var message mail.PushMessage
+		json.Unmarshal(payload, &message)
+		if message.Dialogue != "friend@ya.ru" || message.Body != "Hi" || message.MailId != 3 {
+			t.Errorf("Wrong push message: %s\n", payload)
+		}
+		if keys.PublicKey != public || subject != pushConfig.VapidSubject {
+			t.Errorf("Wrong VAPID identity\n")
+		}
+		if endpoint == "https://push.example.com/send/gone" {
+			return http.StatusGone, errors.New("push service responded 410 Gone")
+		}
+		return http.StatusCreated, nil
+	}
+	gone := subscription
+	gone.Endpoint = "https://push.example.com/send/gone"
+	mockRep.EXPECT().GetPushSubscriptions("alt", "friend@ya.ru").Return([]mail.PushSubscription{subscription, gone}, nil).Times(1)
+	mockRep.EXPECT().DeletePushEndpoint(gone.Endpoint).Return(nil).Times(1)
+
+	mailUC.sendPush("alt", mail.Mail{Id: 3, Sender: "friend@ya.ru", Recipient: "alt@liokor.ru", Subject: "Hi"})
+	if sent != 2 {
+		t.Errorf("Wrong amount of pushes: %d\n", sent)
+	}
+
+	// muted dialogue has no subscriptions to notify
+	mockRep.EXPECT().GetPushSubscriptions("alt", "spam@ya.ru").Return([]mail.PushSubscription{}, nil).Times(1)
+	mailUC.sendPush("alt", mail.Mail{Id: 4, Sender: "spam@ya.ru", Recipient: "alt@liokor.ru", Subject: "Hi"})
+	if sent != 2 {
+		t.Errorf("Pushed to muted dialogue\n")
+	}
+}
+
+func TestReadDialogueMails(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+
+	gomock.InOrder(
+		mockRep.EXPECT().GetReadReceipts("alt").Return(false, nil).Times(1),
+		mockRep.EXPECT().ReadMail([]string{"alt@liokor.ru"}, "lio@liokor.ru").Return(nil).Times(1),
+		mockRep.EXPECT().ReadDialogue("alt", "lio@liokor.ru").Return(nil).Times(1),
+		mockRep.EXPECT().GetWebhooks("alt").Return([]mail.Webhook{}, nil).Times(1),
+	)
+	err := mailUC.ReadDialogueMails("alt", "lio@liokor.ru")
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+
+	inbox := 0
+	filter := mail.MailFilter{FolderId: &inbox, Limit: 10}
+	mockRep.EXPECT().GetMailboxEmails("alt", []string{"alt@liokor.ru"}, filter).Return([]mail.MailboxEmail{}, nil).Times(1)
+	_, err = mailUC.GetMailboxEmails("alt", filter)
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+
+	mailChanges := []mail.SyncChange{
+		{Id: 7, Modseq: 11, CreatedModseq: 11},
+		{Id: 5, Modseq: 13, CreatedModseq: 3},
+		{Id: 4, Modseq: 15, CreatedModseq: 2, Deleted: true},
+	}
+	dialogueChanges := []mail.SyncChange{
+		{Id: 2, Modseq: 12, Deleted: true},
+	}
+	gomock.InOrder(
+		mockRep.EXPECT().GetModseqLimit().Return(int64(20), nil),
+		mockRep.EXPECT().GetMailChanges("alt@liokor.ru", int64(10), syncLimit+1).Return(mailChanges, nil),
+	)
+	mockRep.EXPECT().GetDialogueChanges("alt", int64(10), syncLimit+1).Return(dialogueChanges, nil).Times(1)
+	mockRep.EXPECT().GetFolderChanges(1, int64(10), syncLimit+1).Return([]mail.SyncChange{}, nil).Times(1)
+
+	result, err := mailUC.Sync("alt", 1, "10")
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+	expected := mail.Sync{
+		Token:     "15",
+		Mails:     mail.SyncChanges{Created: []int{7}, Updated: []int{5}, Deleted: []int{4}},
+		Dialogues: mail.SyncChanges{Created: []int{}, Updated: []int{}, Deleted: []int{2}},
+		Folders:   mail.SyncChanges{Created: []int{}, Updated: []int{}, Deleted: []int{}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Wrong sync: %v\n", result)
+	}
+
+	// changes after modseq of the running transaction are held back, it may commit an older change
+	mockRep.EXPECT().GetModseqLimit().Return(int64(12), nil).Times(1)
+	mockRep.EXPECT().GetMailChanges("alt@liokor.ru", int64(10), syncLimit+1).Return(mailChanges, nil).Times(1)
+	mockRep.EXPECT().GetDialogueChanges("alt", int64(10), syncLimit+1).Return(dialogueChanges, nil).Times(1)
+	mockRep.EXPECT().GetFolderChanges(1, int64(10), syncLimit+1).Return([]mail.SyncChange{}, nil).Times(1)
+	result, err = mailUC.Sync("alt", 1, "10")
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+	expected.Token = "12"
+	expected.Mails = mail.SyncChanges{Created: []int{7}, Updated: []int{}, Deleted: []int{}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Wrong sync with running transaction: %v\n", result)
+	}
+
+	_, err = mailUC.Sync("alt", 1, "abc")
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Didn't fail on invalid token: %v\n", err)
+	}
+}
+
+func TestSyncHasMore(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{
+		Repository: mockRep,
+		Config:     config,
+	}
+	expectDefaultDomain(mockRep)
+	defer func(limit int) { syncLimit = limit }(syncLimit)
+	syncLimit = 2
+
+	mockRep.EXPECT().GetModseqLimit().Return(int64(100), nil).Times(1)
+	mockRep.EXPECT().GetMailChanges("alt@liokor.ru", int64(0), 3).Return([]mail.SyncChange{
+		{Id: 1, Modseq: 1, CreatedModseq: 1},
+		{Id: 2, Modseq: 4, CreatedModseq: 4},
+		{Id: 3, Modseq: 6, CreatedModseq: 6},
+	}, nil).Times(1)
+	mockRep.EXPECT().GetDialogueChanges("alt", int64(0), 3).Return([]mail.SyncChange{
+		{Id: 1, Modseq: 2, CreatedModseq: 2},
+		{Id: 2, Modseq: 5, CreatedModseq: 5},
+	}, nil).Times(1)
+	mockRep.EXPECT().GetFolderChanges(1, int64(0), 3).Return([]mail.SyncChange{}, nil).Times(1)
+
+	result, err := mailUC.Sync("alt", 1, "")
+	if err != nil {
+		t.Errorf("Didn't pass valid data: %v\n", err)
+	}
+	if !result.HasMore || result.Token != "4" ||
+		!reflect.DeepEqual(result.Mails.Created, []int{1, 2}) ||
+		!reflect.DeepEqual(result.Dialogues.Created, []int{1}) {
+		t.Errorf("Wrong sync: %v\n", result)
+	}
+}
+
+func TestCreateExport(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	exportConfig := config
+	exportConfig.ApiUrl = "https://api.liokor.ru"
+	exportConfig.ExportStoragePath = t.TempDir()
+	mailUC := MailUseCase{mockRep, exportConfig, nil}
+	expectDefaultDomain(mockRep)
+
+	runAsync = func(f func()) { f() }
+	defer func() {
+		runAsync = func(f func()) { go f() }
+	}()
+
+	filter := mail.MailFilter{DialogueIds: common.IntList{3}}
+	page := filter
+	page.OrderById = true
+	page.Limit = exportPageSize
+	lastPage := page
+	lastPage.AfterId = 7
+	emails := []mail.MailboxEmail{
+		{DialogueEmail: mail.DialogueEmail{
+			Id:            7,
+			Sender:        "lio@liokor.ru",
+			Recipient:     "alt@liokor.ru",
+			Subject:       "Hello",
+			Body:          "<p>From me</p>",
+			Received_date: time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC),
+		}},
+	}
+	var saved mail.Export
+	gomock.InOrder(
+		mockRep.EXPECT().CreateExport(gomock.Any()).DoAndReturn(func(export mail.Export) (mail.Export, error) {
+			if export.Owner != "alt" || export.Format != mail.ExportMbox || export.Status != mail.ExportPending || export.Token == "" {
+				t.Errorf("Wrong export: %v\n", export)
+			}
+			export.Id = 2
+			return export, nil
+		}).Times(1),
+		mockRep.EXPECT().CountMailboxEmails("alt", []string{"alt@liokor.ru"}, filter).Return(1, nil).Times(1),
+		mockRep.EXPECT().UpdateExportProgress(gomock.Any()).Return(nil).Times(1),
+		mockRep.EXPECT().GetMailboxEmails("alt", []string{"alt@liokor.ru"}, page).Return(emails, nil).Times(1),
+		mockRep.EXPECT().UpdateExportProgress(gomock.Any()).Return(nil).Times(1),
+		mockRep.EXPECT().GetMailboxEmails("alt", []string{"alt@liokor.ru"}, lastPage).Return([]mail.MailboxEmail{}, nil).Times(1),
+		mockRep.EXPECT().UpdateExportProgress(gomock.Any()).DoAndReturn(func(export mail.Export) error {
+			saved = export
+			return nil
+		}).Times(1),
+	)
+
+	export, err := mailUC.CreateExport("alt", mail.Export{Dialogues: common.IntList{3}})
+	if err != nil {
+		t.Fatalf("Didn't pass valid data: %v\n", err)
+	}
+	if saved.Status != mail.ExportDone || saved.Processed != 1 || saved.Total != 1 || saved.ExpiresDate == nil {
+		t.Errorf("Wrong export result: %v\n", saved)
+	}
+
+	mockRep.EXPECT().GetExportByToken(export.Token).Return(saved, nil).Times(1)
+	_, path, err := mailUC.GetExportFile(export.Token)
+	if err != nil {
+		t.Fatalf("Didn't return export file: %v\n", err)
+	}
+	mbox, _ := ioutil.ReadFile(path)
+	if !strings.HasPrefix(string(mbox), "From lio@liokor.ru Sat May  1 12:00:00 2021\n") ||
+		!strings.Contains(string(mbox), "Message-ID: <7@liokor.ru>") {
+		t.Errorf("Wrong mbox: %s\n", mbox)
+	}
+
+	mockRep.EXPECT().GetExport("alt", 2).Return(saved, nil).Times(1)
+	export, err = mailUC.GetExport("alt", 2)
+	if err != nil || export.DownloadURL != "https://api.liokor.ru/email/export/download/"+saved.Token {
+		t.Errorf("Wrong download link: %v %v\n", export, err)
+	}
+
+	_, err = mailUC.CreateExport("alt", mail.Export{Format: "pst"})
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Didn't fail on unknown format: %v\n", err)
+	}
+}
+
+func TestGetExportFileExpired(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{mockRep, config, nil}
+
+	expired := time.Now().Add(-time.Hour)
+	mockRep.EXPECT().GetExportByToken("token").Return(mail.Export{
+		Id:          2,
+		Status:      mail.ExportDone,
+		Token:       "token",
+		ExpiresDate: &expired,
+	}, nil).Times(1)
+	_, _, err := mailUC.GetExportFile("token")
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Returned expired export: %v\n", err)
+	}
+}
+
+func TestRemoveExpiredExports(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	exportConfig := config
+	exportConfig.ExportStoragePath = t.TempDir()
+	mailUC := MailUseCase{mockRep, exportConfig, nil}
+
+	expired := mail.Export{Id: 2, Format: mail.ExportMbox, Token: "expired"}
+	interrupted := mail.Export{Id: 3, Format: mail.ExportEml, Token: "interrupted"}
+	kept := mail.Export{Id: 4, Format: mail.ExportMbox, Token: "kept"}
+	for _, export := range []mail.Export{expired, interrupted, kept} {
+		ioutil.WriteFile(mailUC.exportPath(export), []byte("archive"), 0600)
+	}
+
+	mockRep.EXPECT().ExpireExports(gomock.Any()).Return([]mail.Export{expired}, nil).Times(1)
+	err := mailUC.RemoveExpiredExports()
+	if err != nil {
+		t.Errorf("Didn't remove expired exports: %v\n", err)
+	}
+	mockRep.EXPECT().InterruptExports().Return([]mail.Export{interrupted}, nil).Times(1)
+	err = mailUC.InterruptExports()
+	if err != nil {
+		t.Errorf("Didn't interrupt exports: %v\n", err)
+	}
+
+	for _, export := range []mail.Export{expired, interrupted} {
+		if _, err := os.Stat(mailUC.exportPath(export)); !os.IsNotExist(err) {
+			t.Errorf("Archive of export %d isn't removed: %v\n", export.Id, err)
+		}
+	}
+	if _, err := os.Stat(mailUC.exportPath(kept)); err != nil {
+		t.Errorf("Archive of active export is removed: %v\n", err)
+	}
+
+	mockRep.EXPECT().GetExportByToken("expired").Return(mail.Export{Id: 2, Status: mail.ExportExpired, Token: "expired"}, nil).Times(1)
+	_, _, err = mailUC.GetExportFile("expired")
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Returned removed export: %v\n", err)
+	}
+}
+
+func TestImportArchive(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	importConfig := config
+	importConfig.ImportStoragePath = t.TempDir()
+	mailUC := MailUseCase{mockRep, importConfig, nil}
+	expectDefaultDomain(mockRep)
+
+	runAsync = func(f func()) { f() }
+	defer func() {
+		runAsync = func(f func()) { go f() }
+	}()
+
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	files := []struct{ name, message string }{
+		{"Work/1.eml", "From: Friend <friend@ya.ru>\r\nTo: alt@liokor.ru\r\nSubject: Hi\r\n" +
+			"Date: Sun, 01 Mar 2020 10:00:00 +0000\r\nMessage-ID: <1@ya.ru>\r\n" +
+			"MIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+			"--b\r\nContent-Type: text/plain\r\n\r\nHello\r\n" +
+			"--b\r\nContent-Type: text/plain\r\nContent-Disposition: attachment; filename=\"a.txt\"\r\n\r\nfile\r\n--b--\r\n"},
+		{"readme.txt", "not a message"},
+		{"2.eml", "From: lio@liokor.ru\r\nTo: friend@ya.ru, alt@liokor.ru\r\nSubject: Re: Hi\r\n" +
+			"Date: Mon, 02 Mar 2020 10:00:00 +0000\r\nMessage-ID: <2@liokor.ru>\r\n\r\nHi there\r\n"},
+		{"3.eml", "From: friend@ya.ru\r\nTo: alt@liokor.ru\r\nMessage-ID: <3@ya.ru>\r\n\r\nAgain\r\n"},
+	}
+	for _, file := range files {
+		entry, _ := writer.Create(file.name)
+		entry.Write([]byte(file.message))
+	}
+	writer.Close()
+
+	mockRep.EXPECT().CreateImport(gomock.Any()).DoAndReturn(func(mailImport mail.Import) (mail.Import, error) {
+		if mailImport.Owner != "alt" || mailImport.Source != mail.ImportEml || mailImport.Token == "" {
+			t.Errorf("Wrong import: %v\n", mailImport)
+		}
+		mailImport.Id = 4
+		return mailImport, nil
+	}).Times(1)
+	mockRep.EXPECT().GetAliases("alt").Return([]mail.Alias{{Id: 1, Owner: "alt", Alias: "lio"}}, nil).Times(1)
+	mockRep.EXPECT().GetFolders(5).Return([]mail.Folder{}, nil).Times(1)
+	var saved mail.Import
+	mockRep.EXPECT().UpdateImportProgress(gomock.Any()).DoAndReturn(func(mailImport mail.Import) error {
+		saved = mailImport
+		return nil
+	}).MinTimes(1)
+	mockRep.EXPECT().MailExists("friend@ya.ru", "alt@liokor.ru", "<1@ya.ru>").Return(false, nil).Times(1)
+	mockRep.EXPECT().MailExists("alt@liokor.ru", "friend@ya.ru", "<2@liokor.ru>").Return(false, nil).Times(1)
+	mockRep.EXPECT().MailExists("friend@ya.ru", "alt@liokor.ru", "<3@ya.ru>").Return(true, nil).Times(1)
+	mockRep.EXPECT().CreateFolder(5, "Work", 0).Return(mail.Folder{Id: 8, FolderName: "Work", Owner: 5}, nil).Times(1)
+	added := make([]mail.Mail, 0)
+	mockRep.EXPECT().AddMail(gomock.Any(), nil).DoAndReturn(func(email mail.Mail, owners []string) (int, error) {
+		added = append(added, email)
+		return len(added), nil
+	}).Times(2)
+	mockRep.EXPECT().RebuildDialogue("alt", "friend@ya.ru", "liokor.ru").Return(11, nil).Times(1)
+	mockRep.EXPECT().AddDialogueToFolder("alt", 8, 11).Return(nil).Times(1)
+
+	mailImport, err := mailUC.ImportArchive("alt", 5, mail.ImportEml, &archive)
+	if err != nil {
+		t.Fatalf("Didn't pass valid archive: %v\n", err)
+	}
+	if saved.Status != mail.ExportDone || saved.Processed != 3 || saved.Imported != 2 || saved.Duplicates != 1 ||
+		saved.Progress != `{"":4}` {
+		t.Errorf("Wrong import result: %v\n", saved)
+	}
+	if _, err := os.Stat(mailUC.importPath(mailImport)); !os.IsNotExist(err) {
+		t.Errorf("Archive isn't removed after import: %v\n", err)
+	}
+
+	received, sent := added[0], added[1]
+	if received.Sender != "friend@ya.ru" || received.Recipient != "alt@liokor.ru" || !received.DeletedBySender ||
+		received.Unread == nil || *received.Unread ||
+		!received.Received_date.Equal(time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Wrong received mail: %v\n", received)
+	}
+	if !received.HasAttachment || !bytes.Equal(received.Raw, []byte(files[0].message)) {
+		t.Errorf("Imported mail lost its attachment: %v\n", received)
+	}
+	if sent.Sender != "alt@liokor.ru" || sent.Alias != "lio" || sent.Recipient != "friend@ya.ru" || sent.MessageId != "<2@liokor.ru>" {
+		t.Errorf("Wrong sent mail: %v\n", sent)
+	}
+
+	_, err = mailUC.ImportArchive("alt", 5, mail.ImportEml, strings.NewReader("not a zip"))
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Didn't fail on invalid archive: %v\n", err)
+	}
+}
+
+func TestImportArchiveLimits(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	importConfig := config
+	importConfig.ImportStoragePath = t.TempDir()
+	mailUC := MailUseCase{mockRep, importConfig, nil}
+	expectDefaultDomain(mockRep)
+
+	runAsync = func(f func()) { f() }
+	maxImportMessageSize = 64
+	defer func() {
+		runAsync = func(f func()) { go f() }
+		maxImportSize = 1 << 30
+		maxImportMessageSize = 32 << 20
+	}()
+
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	entry, _ := writer.Create("1.eml")
+	entry.Write([]byte("From: friend@ya.ru\r\nTo: alt@liokor.ru\r\nMessage-ID: <1@ya.ru>\r\n\r\n" +
+		strings.Repeat("Hello", 20) + "\r\n"))
+	writer.Close()
+	data := archive.Bytes()
+
+	// the oversized message is skipped as failed
+	mockRep.EXPECT().CreateImport(gomock.Any()).DoAndReturn(func(mailImport mail.Import) (mail.Import, error) {
+		mailImport.Id = 4
+		return mailImport, nil
+	}).Times(1)
+	mockRep.EXPECT().GetAliases("alt").Return([]mail.Alias{}, nil).Times(1)
+	mockRep.EXPECT().GetFolders(5).Return([]mail.Folder{}, nil).Times(1)
+	var saved mail.Import
+	mockRep.EXPECT().UpdateImportProgress(gomock.Any()).DoAndReturn(func(mailImport mail.Import) error {
+		saved = mailImport
+		return nil
+	}).MinTimes(1)
+	_, err := mailUC.ImportArchive("alt", 5, mail.ImportEml, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Didn't pass valid archive: %v\n", err)
+	}
+	if saved.Status != mail.ExportDone || saved.Processed != 1 || saved.Failed != 1 || saved.Imported != 0 {
+		t.Errorf("Didn't skip oversized message: %v\n", saved)
+	}
+
+	// the oversized archive isn't accepted
+	maxImportSize = int64(len(data) - 1)
+	_, err = mailUC.ImportArchive("alt", 5, mail.ImportEml, bytes.NewReader(data))
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Didn't fail on oversized archive: %v\n", err)
+	}
+	files, _ := ioutil.ReadDir(importConfig.ImportStoragePath)
+	if len(files) != 0 {
+		t.Errorf("Oversized archive isn't removed: %v\n", files)
+	}
+}
+
+func TestImportArchiveResume(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	importConfig := config
+	importConfig.ImportStoragePath = t.TempDir()
+	mailUC := MailUseCase{mockRep, importConfig, nil}
+	expectDefaultDomain(mockRep)
+
+	runAsync = func(f func()) { f() }
+	defer func() {
+		runAsync = func(f func()) { go f() }
+	}()
+
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	entry, _ := writer.Create("1.eml")
+	entry.Write([]byte("From: alt@liokor.ru\r\nTo: friend@ya.ru, boss@ya.ru\r\nSubject: Hi\r\n" +
+		"Message-ID: <5@liokor.ru>\r\n\r\nHello\r\n"))
+	writer.Close()
+
+	mockRep.EXPECT().CreateImport(gomock.Any()).DoAndReturn(func(mailImport mail.Import) (mail.Import, error) {
+		mailImport.Id = 4
+		return mailImport, nil
+	}).Times(1)
+	mockRep.EXPECT().GetAliases("alt").Return([]mail.Alias{}, nil).Times(2)
+	mockRep.EXPECT().GetFolders(5).Return([]mail.Folder{}, nil).Times(2)
+	var saved mail.Import
+	mockRep.EXPECT().UpdateImportProgress(gomock.Any()).DoAndReturn(func(mailImport mail.Import) error {
+		saved = mailImport
+		return nil
+	}).MinTimes(1)
+
+	// the copy to the second recipient fails, so the message isn't passed
+	gomock.InOrder(
+		mockRep.EXPECT().MailExists("alt@liokor.ru", "friend@ya.ru", "<5@liokor.ru>").Return(false, nil),
+		mockRep.EXPECT().MailExists("alt@liokor.ru", "boss@ya.ru", "<5@liokor.ru>").Return(false, nil),
+		mockRep.EXPECT().AddMail(gomock.Any(), nil).Return(1, nil),
+		mockRep.EXPECT().AddMail(gomock.Any(), nil).Return(0, errors.New("connection lost")),
+	)
+	mockRep.EXPECT().RebuildDialogue("alt", "friend@ya.ru", "liokor.ru").Return(11, nil).Times(1)
+	mailImport, err := mailUC.ImportArchive("alt", 5, mail.ImportEml, &archive)
+	if err != nil {
+		t.Fatalf("Didn't pass valid archive: %v\n", err)
+	}
+	if saved.Status != mail.ExportFailed || saved.Processed != 0 || saved.Imported != 0 || saved.Progress != "{}" {
+		t.Errorf("Wrong failed import: %v\n", saved)
+	}
+
+	// only the missing copy is added on resume
+	mockRep.EXPECT().GetImport("alt", 4).Return(saved, nil).Times(1)
+	mockRep.EXPECT().MailExists("alt@liokor.ru", "friend@ya.ru", "<5@liokor.ru>").Return(true, nil).Times(1)
+	mockRep.EXPECT().MailExists("alt@liokor.ru", "boss@ya.ru", "<5@liokor.ru>").Return(false, nil).Times(1)
+	mockRep.EXPECT().AddMail(gomock.Any(), nil).DoAndReturn(func(email mail.Mail, owners []string) (int, error) {
+		if email.Recipient != "boss@ya.ru" {
+			t.Errorf("Wrong resumed mail: %v\n", email)
+		}
+		return 2, nil
+	}).Times(1)
+	mockRep.EXPECT().RebuildDialogue("alt", "boss@ya.ru", "liokor.ru").Return(12, nil).Times(1)
+	_, err = mailUC.ResumeImport("alt", 5, mailImport.Id, "")
+	if err != nil {
+		t.Fatalf("Didn't resume failed import: %v\n", err)
+	}
+	if saved.Status != mail.ExportDone || saved.Processed != 1 || saved.Imported != 1 || saved.Progress != `{"":1}` {
+		t.Errorf("Wrong resumed import: %v\n", saved)
+	}
+}
+
+// fakeIMAPServer answers commands by the script without tags, every connection gets the same script
+func fakeIMAPServer(script map[string]string) func(host string) (*utils.IMAPClient, error) {
+	return func(host string) (*utils.IMAPClient, error) {
+		clientConn, serverConn := net.Pipe()
+		go func() {
+			defer serverConn.Close()
+			reader := bufio.NewReader(serverConn)
+			serverConn.Write([]byte("* OK ready\r\n"))
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				splitted := strings.SplitN(strings.TrimRight(line, "\r\n"), " ", 2)
+				response, ok := script[splitted[1]]
+				if !ok {
+					serverConn.Write([]byte(splitted[0] + " NO unknown command\r\n"))
+					continue
+				}
+				serverConn.Write([]byte(response + splitted[0] + " OK done\r\n"))
+			}
+		}()
+		return utils.NewIMAPClient(clientConn)
+	}
+}
+
+func TestImportIMAP(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRep := mocks.NewMockMailRepository(mockCtrl)
+	mailUC := MailUseCase{mockRep, config, nil}
+	expectDefaultDomain(mockRep)
+
+	message := "From: friend@ya.ru\r\nTo: alt@liokor.ru\r\nSubject: Hi\r\nMessage-ID: <1@ya.ru>\r\n\r\nHello\r\n"
+	dialIMAP = fakeIMAPServer(map[string]string{
+		`LOGIN "friend" "secret"`: "",
+		`LIST "" "*"`: "* LIST () \"/\" \"INBOX\"\r\n" +
+			"* LIST (\\Trash) \"/\" \"Trash\"\r\n" +
+			"* LIST () \"/\" \"INBOX/Work\"\r\n",
+		`EXAMINE "INBOX"`:      "",
+		`EXAMINE "INBOX/Work"`: "",
+		"UID SEARCH UID 1:*":   "* SEARCH\r\n",
+		"UID SEARCH UID 3:*":   "* SEARCH 3\r\n",
+		"UID FETCH 3 (UID FLAGS BODY.PEEK[])": "* 1 FETCH (UID 3 FLAGS () BODY[] {" + strconv.Itoa(len(message)) + "}\r\n" +
+			message + ")\r\n",
+		"LOGOUT": "",
+	})
+	runAsync = func(f func()) { f() }
+	defer func() {
+		runAsync = func(f func()) { go f() }
+		dialIMAP = utils.DialIMAP
+	}()
+
+	mockRep.EXPECT().GetImport("alt", 4).Return(mail.Import{
+		Id:       4,
+		Owner:    "alt",
+		Source:   mail.ImportIMAP,
+		Host:     "imap.ya.ru",
+		Username: "friend",
+		Status:   mail.ExportFailed,
+		Progress: `{"INBOX/Work":2}`,
+	}, nil).Times(1)
+	mockRep.EXPECT().GetAliases("alt").Return([]mail.Alias{}, nil).Times(1)
+	mockRep.EXPECT().GetFolders(5).Return([]mail.Folder{{Id: 8, FolderName: "work", Owner: 5}}, nil).Times(1)
+	var saved mail.Import
+	mockRep.EXPECT().UpdateImportProgress(gomock.Any()).DoAndReturn(func(mailImport mail.Import) error {
+		saved = mailImport
+		return nil
+	}).MinTimes(1)
+	mockRep.EXPECT().MailExists("friend@ya.ru", "alt@liokor.ru", "<1@ya.ru>").Return(false, nil).Times(1)
+	mockRep.EXPECT().AddMail(gomock.Any(), nil).DoAndReturn(func(email mail.Mail, owners []string) (int, error) {
+		if email.Sender != "friend@ya.ru" || email.Unread == nil || !*email.Unread {
+			t.Errorf("Wrong imported mail: %v\n", email)
+		}
+		return 1, nil
+	}).Times(1)
+	mockRep.EXPECT().RebuildDialogue("alt", "friend@ya.ru", "liokor.ru").Return(11, nil).Times(1)
+	mockRep.EXPECT().AddDialogueToFolder("alt", 8, 11).Return(nil).Times(1)
+
+	_, err := mailUC.ResumeImport("alt", 5, 4, "secret")
+	if err != nil {
+		t.Fatalf("Didn't resume failed import: %v\n", err)
+	}
+	if saved.Status != mail.ExportDone || saved.Imported != 1 || saved.Progress != `{"INBOX/Work":3}` {
+		t.Errorf("Wrong import result: %v\n", saved)
+	}
+
+	mockRep.EXPECT().GetImport("alt", 4).Return(mail.Import{Id: 4, Source: mail.ImportIMAP, Status: mail.ExportFailed}, nil).Times(1)
+	_, err = mailUC.ResumeImport("alt", 5, 4, "wrong")
+	if _, ok := err.(mail.InvalidEmailError); !ok {
+		t.Errorf("Didn't fail on wrong password: %v\n", err)
+	}
+
+	for _, host := range []string{"127.0.0.1:143", "localhost", "192.168.0.1"} {
+		_, err = mailUC.ImportIMAP("alt", 5, mail.IMAPAccount{Host: host, Username: "friend", Password: "secret"})
+		if _, ok := err.(mail.InvalidEmailError); !ok {
+			t.Errorf("Didn't reject internal imap server %s: %v\n", host, err)
+		}
+	}
+}
@@ -15,6 +15,7 @@ type UserSignUp struct {
 	AvatarURL    string `json:"avatarUrl"`
 	FullName     string `json:"fullname"`
 	ReserveEmail string `json:"reserveEmail"`
+	Domain       string `json:"domain"`
 }
 
 type User struct {
@@ -24,6 +25,7 @@ type User struct {
 	AvatarURL    common.NullString `json:"avatarUrl" gorm:"column:avatar_url"`
 	FullName     string            `json:"fullname" gorm:"column:fullname"`
 	ReserveEmail string            `json:"reserveEmail" gorm:"column:reserve_email"`
+	Domain       string            `json:"domain" gorm:"column:domain"`
 	RegisterDate string            `json:"-" gorm:"-"`
 	IsAdmin      bool              `json:"-" gorm:"-"`
 }
@@ -43,6 +43,8 @@ func (ur *GormPostgresUserRepository) CreateUser(user user.User) error {
 		if pgerr, ok := err.(*pgconn.PgError); ok {
 			if pgerr.ConstraintName == "users_username_key" {
 				return common.InvalidUserError{"username exists"}
+			} else if pgerr.ConstraintName == "users_domain_fkey" {
+				return common.InvalidUserError{"domain doesn't exist"}
 			}
 		}
 		return err
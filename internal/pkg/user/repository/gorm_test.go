@@ -49,6 +49,7 @@ func (s *Suite) SetupSuite() {
 		AvatarURL:    common.NullString{sql.NullString{String: "/media/test", Valid: true}},
 		FullName:     "Test User",
 		ReserveEmail: "test@test.test",
+		Domain:       "liokor.ru",
 		RegisterDate: "",
 		IsAdmin:      false,
 	}
@@ -130,6 +131,7 @@ func (s *Suite) TestGormCreateUser() {
 		s.u.AvatarURL.String,
 		s.u.FullName,
 		s.u.ReserveEmail,
+		s.u.Domain,
 		s.u.Id,
 		).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).
@@ -146,6 +148,7 @@ func (s *Suite) TestGormCreateUser() {
 			s.u.AvatarURL.String,
 			s.u.FullName,
 			s.u.ReserveEmail,
+			s.u.Domain,
 			s.u.Id,
 		).
 		WillReturnError(&pgconn.PgError{ConstraintName: "users_username_key"})
@@ -164,6 +167,7 @@ func (s *Suite) TestGormUpdateUser() {
 			s.u.AvatarURL.String,
 			s.u.FullName,
 			s.u.ReserveEmail,
+			s.u.Domain,
 			s.u.Id,
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
@@ -179,6 +183,7 @@ func (s *Suite) TestGormUpdateUser() {
 			s.u.AvatarURL.String,
 			s.u.FullName,
 			s.u.ReserveEmail,
+			s.u.Domain,
 			s.u.Id,
 		).
 		WillReturnError(&pgconn.PgError{ConstraintName: "users_username_key"})
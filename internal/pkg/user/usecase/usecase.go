@@ -100,6 +100,11 @@ func (uc *UserUseCase) SignUp(newUser user.UserSignUp) error {
 		return err
 	}
 
+	domain := strings.TrimSpace(newUser.Domain)
+	if domain == "" {
+		domain = uc.Config.MailDomain
+	}
+
 	err = uc.Repository.CreateUser(user.User{
 		0,
 		newUser.Username,
@@ -107,6 +112,7 @@ func (uc *UserUseCase) SignUp(newUser user.UserSignUp) error {
 		common.NullString{sql.NullString{String: newUser.AvatarURL, Valid: true}},
 		newUser.FullName,
 		newUser.ReserveEmail,
+		domain,
 		time.Now().String(),
 		false,
 	})
@@ -36,3 +36,13 @@ func ValidatePassword(password string) bool {
 	}
 	return digit && letter
 }
+
+func ValidateLabel(label string) bool {
+	checker, _ := regexp.Compile("^[A-Za-z0-9_.-]{1,64}$")
+	return checker.MatchString(label)
+}
+
+func ValidateDomain(domain string) bool {
+	checker, _ := regexp.Compile("^[A-Za-z0-9-]{1,63}(\\.[A-Za-z0-9-]{1,63})*$")
+	return len(domain) <= 253 && checker.MatchString(domain)
+}
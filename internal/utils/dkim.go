@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+// DkimKey is used to sign outgoing mail of the domain
+type DkimKey struct {
+	Domain     string
+	Selector   string
+	PrivateKey *rsa.PrivateKey
+}
+
+// ParsePrivateKey parses PEM encoded PKCS1 RSA private key
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM private key")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
@@ -0,0 +1,375 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const imapTimeout = 30 * time.Second
+const imapMaxLiteral = 32 << 20
+
+// literal at the end of the response line, its data follows the line
+var imapLiteral = regexp.MustCompile(`\{(\d+)\}$`)
+
+// IMAPMailbox is a selectable mailbox of the account
+type IMAPMailbox struct {
+	Name       string   // modified UTF-7 as used in commands
+	Delimiter  string   // separates names of the parent and child mailboxes, empty for flat accounts
+	Attributes []string // e.g. \Sent or \Trash of special-use mailboxes
+}
+
+// DisplayName decodes modified UTF-7 name of the mailbox
+func (m IMAPMailbox) DisplayName() string {
+	return decodeModifiedUTF7(m.Name)
+}
+
+func (m IMAPMailbox) HasAttribute(attribute string) bool {
+	for _, a := range m.Attributes {
+		if strings.EqualFold(a, attribute) {
+			return true
+		}
+	}
+	return false
+}
+
+type IMAPMessage struct {
+	Uid  int
+	Seen bool
+	Raw  []byte
+}
+
+// IMAPClient is a minimal IMAP4rev1 client reading messages of the account without changing them
+type IMAPClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+// DialIMAP connects to the server over TLS, port 993 is used if the host doesn't have one. The host is given
+// by users, so internal addresses are refused
+func DialIMAP(host string) (*IMAPClient, error) {
+	address := host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		address = net.JoinHostPort(host, "993")
+	}
+	serverName, _, _ := net.SplitHostPort(address)
+	conn, err := tls.DialWithDialer(PublicDialer(imapTimeout), "tcp", address, &tls.Config{ServerName: serverName})
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewIMAPClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// NewIMAPClient reads greeting of the server from the connection
+func NewIMAPClient(conn net.Conn) (*IMAPClient, error) {
+	client := &IMAPClient{conn: conn, reader: bufio.NewReader(conn)}
+	conn.SetDeadline(time.Now().Add(imapTimeout))
+	greeting, _, err := client.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return nil, fmt.Errorf("imap: unexpected greeting %s", greeting)
+	}
+	return client, nil
+}
+
+func (c *IMAPClient) Login(username string, password string) error {
+	user, err := imapQuote(username)
+	if err != nil {
+		return err
+	}
+	pass, err := imapQuote(password)
+	if err != nil {
+		return err
+	}
+	_, err = c.command("LOGIN " + user + " " + pass)
+	return err
+}
+
+// List returns mailboxes of the account which can be selected
+func (c *IMAPClient) List() ([]IMAPMailbox, error) {
+	responses, err := c.command(`LIST "" "*"`)
+	if err != nil {
+		return nil, err
+	}
+	mailboxes := make([]IMAPMailbox, 0)
+	for _, response := range responses {
+		fields := imapFields(response.line, response.literals)
+		if len(fields) != 4 || !strings.EqualFold(fields[0], "LIST") {
+			continue
+		}
+		mailbox := IMAPMailbox{
+			Name:       fields[3],
+			Attributes: imapFields(strings.Trim(fields[1], "()"), nil),
+		}
+		if !strings.EqualFold(fields[2], "NIL") {
+			mailbox.Delimiter = fields[2]
+		}
+		if mailbox.HasAttribute(`\Noselect`) || mailbox.HasAttribute(`\NonExistent`) {
+			continue
+		}
+		mailboxes = append(mailboxes, mailbox)
+	}
+	return mailboxes, nil
+}
+
+// Examine selects the mailbox read-only, so fetched messages stay unseen on the server
+func (c *IMAPClient) Examine(mailbox string) error {
+	name, err := imapQuote(mailbox)
+	if err != nil {
+		return err
+	}
+	_, err = c.command("EXAMINE " + name)
+	return err
+}
+
+// SearchAfter returns uids of the messages of the selected mailbox greater than the uid in ascending order
+func (c *IMAPClient) SearchAfter(uid int) ([]int, error) {
+	responses, err := c.command(fmt.Sprintf("UID SEARCH UID %d:*", uid+1))
+	if err != nil {
+		return nil, err
+	}
+	uids := make([]int, 0)
+	for _, response := range responses {
+		fields := strings.Fields(response.line)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "SEARCH") {
+			continue
+		}
+		for _, field := range fields[1:] {
+			// n:* matches the last message even if its uid is less than n
+			if found, err := strconv.Atoi(field); err == nil && found > uid {
+				uids = append(uids, found)
+			}
+		}
+	}
+	sort.Ints(uids)
+	return uids, nil
+}
+
+// Fetch returns messages of the selected mailbox by uids
+func (c *IMAPClient) Fetch(uids []int) ([]IMAPMessage, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	set := make([]string, len(uids))
+	for i, uid := range uids {
+		set[i] = strconv.Itoa(uid)
+	}
+	responses, err := c.command("UID FETCH " + strings.Join(set, ",") + " (UID FLAGS BODY.PEEK[])")
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]IMAPMessage, 0, len(uids))
+	for _, response := range responses {
+		fields := imapFields(response.line, response.literals)
+		if len(fields) != 3 || !strings.EqualFold(fields[1], "FETCH") {
+			continue
+		}
+		var message IMAPMessage
+		items := imapFields(strings.TrimSuffix(strings.TrimPrefix(fields[2], "("), ")"), response.literals)
+		for i := 0; i+1 < len(items); i += 2 {
+			switch strings.ToUpper(items[i]) {
+			case "UID":
+				message.Uid, _ = strconv.Atoi(items[i+1])
+			case "FLAGS":
+				for _, flag := range imapFields(strings.Trim(items[i+1], "()"), nil) {
+					if strings.EqualFold(flag, `\Seen`) {
+						message.Seen = true
+					}
+				}
+			case "BODY[]":
+				message.Raw = []byte(items[i+1])
+			}
+		}
+		if message.Uid != 0 && message.Raw != nil {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (c *IMAPClient) Logout() error {
+	_, err := c.command("LOGOUT")
+	c.conn.Close()
+	return err
+}
+
+type imapResponse struct {
+	line     string // without "* ", literals are replaced by {index}
+	literals [][]byte
+}
+
+// command sends the tagged command and returns untagged responses received before its completion
+func (c *IMAPClient) command(command string) ([]imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("a%d", c.tag)
+	c.conn.SetDeadline(time.Now().Add(imapTimeout))
+	_, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, command)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]imapResponse, 0)
+	for {
+		line, literals, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(line, tag+" ") {
+			status := line[len(tag)+1:]
+			if !strings.HasPrefix(strings.ToUpper(status), "OK") {
+				return nil, fmt.Errorf("imap: %s", status)
+			}
+			return responses, nil
+		}
+		if strings.HasPrefix(line, "* ") {
+			responses = append(responses, imapResponse{line: line[2:], literals: literals})
+		}
+	}
+}
+
+// readLine reads the response line with its literals
+func (c *IMAPClient) readLine() (string, [][]byte, error) {
+	var line strings.Builder
+	var literals [][]byte
+	for {
+		part, err := c.reader.ReadString('\n')
+		if err != nil {
+			return "", nil, err
+		}
+		part = strings.TrimRight(part, "\r\n")
+		match := imapLiteral.FindStringSubmatchIndex(part)
+		if match == nil {
+			line.WriteString(part)
+			return line.String(), literals, nil
+		}
+		size, _ := strconv.Atoi(part[match[2]:match[3]])
+		if size > imapMaxLiteral {
+			return "", nil, errors.New("imap: message is too large")
+		}
+		literal := make([]byte, size)
+		_, err = io.ReadFull(c.reader, literal)
+		if err != nil {
+			return "", nil, err
+		}
+		line.WriteString(part[:match[0]])
+		fmt.Fprintf(&line, "{%d}", len(literals))
+		literals = append(literals, literal)
+	}
+}
+
+// imapQuote returns quoted string, strings with line breaks can't be quoted
+func imapQuote(s string) (string, error) {
+	if strings.ContainsAny(s, "\r\n") {
+		return "", errors.New("imap: line breaks aren't allowed")
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`, nil
+}
+
+// imapFields splits the line into atoms, unquoted strings, literals and parenthesized lists kept as is
+func imapFields(line string, literals [][]byte) []string {
+	fields := make([]string, 0)
+	for i := 0; i < len(line); {
+		switch {
+		case line[i] == ' ':
+			i++
+		case line[i] == '"':
+			var field strings.Builder
+			for i++; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				field.WriteByte(line[i])
+			}
+			fields = append(fields, field.String())
+			i++
+		case line[i] == '(':
+			start, depth := i, 0
+			for ; i < len(line); i++ {
+				if line[i] == '(' {
+					depth++
+				} else if line[i] == ')' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			if i == len(line) {
+				fields = append(fields, line[start:])
+				return fields
+			}
+			fields = append(fields, line[start:i+1])
+			i++
+		case line[i] == '{':
+			end := strings.IndexByte(line[i:], '}')
+			if end < 0 {
+				fields = append(fields, line[i:])
+				return fields
+			}
+			index, err := strconv.Atoi(line[i+1 : i+end])
+			if err != nil || index >= len(literals) {
+				fields = append(fields, line[i:i+end+1])
+				i += end + 1
+				continue
+			}
+			fields = append(fields, string(literals[index]))
+			i += end + 1
+		default:
+			end := strings.IndexAny(line[i:], " ()")
+			if end < 0 {
+				end = len(line) - i
+			}
+			fields = append(fields, line[i:i+end])
+			i += end
+		}
+	}
+	return fields
+}
+
+// decodeModifiedUTF7 decodes mailbox name (RFC 3501 5.1.3), invalid names are returned as is
+func decodeModifiedUTF7(name string) string {
+	var decoded strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] != '&' {
+			decoded.WriteByte(name[i])
+			continue
+		}
+		end := strings.IndexByte(name[i:], '-')
+		if end < 0 {
+			return name
+		}
+		encoded := name[i+1 : i+end]
+		i += end
+		if encoded == "" {
+			decoded.WriteByte('&')
+			continue
+		}
+		data, err := base64.RawStdEncoding.DecodeString(strings.ReplaceAll(encoded, ",", "/"))
+		if err != nil || len(data)%2 != 0 {
+			return name
+		}
+		units := make([]uint16, len(data)/2)
+		for j := range units {
+			units[j] = uint16(data[2*j])<<8 | uint16(data[2*j+1])
+		}
+		decoded.WriteString(string(utf16.Decode(units)))
+	}
+	return decoded.String()
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// serveIMAP answers commands of the client by the script, commands are matched without tags
+func serveIMAP(t *testing.T, conn net.Conn, script map[string]string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	conn.Write([]byte("* OK IMAP4rev1 ready\r\n"))
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		splitted := strings.SplitN(strings.TrimRight(line, "\r\n"), " ", 2)
+		response, ok := script[splitted[1]]
+		if !ok {
+			conn.Write([]byte(splitted[0] + " BAD unknown command\r\n"))
+			continue
+		}
+		conn.Write([]byte(response + splitted[0] + " OK done\r\n"))
+		if splitted[1] == "LOGOUT" {
+			return
+		}
+	}
+}
+
+func TestIMAPClient(t *testing.T) {
+	message := "Subject: Hi\r\n\r\nHello\r\n"
+	script := map[string]string{
+		`LOGIN "user" "pa\"ss"`: "",
+		`LIST "" "*"`: "* LIST (\\HasNoChildren) \"/\" \"INBOX\"\r\n" +
+			"* LIST (\\Noselect) \"/\" \"[Gmail]\"\r\n" +
+			"* LIST (\\HasNoChildren \\Sent) \"/\" {24}\r\n&BB4EQgQ,BEAEMAQyBDsALg-\r\n" +
+			"* LIST () NIL Work\r\n",
+		`EXAMINE "INBOX"`:                     "* 2 EXISTS\r\n",
+		"UID SEARCH UID 5:*":                  "* SEARCH 4 7\r\n",
+		"UID FETCH 7 (UID FLAGS BODY.PEEK[])": "* 2 FETCH (UID 7 FLAGS (\\Seen \\Answered) BODY[] {" + strconv.Itoa(len(message)) + "}\r\n" + message + ")\r\n",
+		"LOGOUT":                              "* BYE\r\n",
+	}
+	clientConn, serverConn := net.Pipe()
+	go serveIMAP(t, serverConn, script)
+
+	client, err := NewIMAPClient(clientConn)
+	if err != nil {
+		t.Fatalf("Didn't connect: %v\n", err)
+	}
+	if err := client.Login("user", `pa"ss`); err != nil {
+		t.Fatalf("Didn't log in: %v\n", err)
+	}
+	mailboxes, err := client.List()
+	if err != nil || len(mailboxes) != 3 {
+		t.Fatalf("Wrong mailboxes: %v %v\n", mailboxes, err)
+	}
+	if mailboxes[1].DisplayName() != "Отправл." || !mailboxes[1].HasAttribute(`\Sent`) || mailboxes[2].Delimiter != "" {
+		t.Errorf("Wrong mailboxes: %v\n", mailboxes)
+	}
+
+	if err := client.Examine("INBOX"); err != nil {
+		t.Fatalf("Didn't select mailbox: %v\n", err)
+	}
+	uids, err := client.SearchAfter(4)
+	if err != nil || !reflect.DeepEqual(uids, []int{7}) {
+		t.Errorf("Wrong uids: %v %v\n", uids, err)
+	}
+	messages, err := client.Fetch(uids)
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("Wrong messages: %v %v\n", messages, err)
+	}
+	if messages[0].Uid != 7 || !messages[0].Seen || string(messages[0].Raw) != message {
+		t.Errorf("Wrong message: %v\n", messages[0])
+	}
+
+	if err := client.Examine("Missing"); err == nil {
+		t.Errorf("Didn't fail on error response\n")
+	}
+	if err := client.Logout(); err != nil {
+		t.Errorf("Didn't log out: %v\n", err)
+	}
+}
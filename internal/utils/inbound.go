@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"bytes"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+var inboundSubjectPolicy = bluemonday.StrictPolicy()
+var inboundBodyPolicy = bluemonday.UGCPolicy()
+
+// InboundMessage is a message received by smtp server or imported, parsed and sanitized for storing
+type InboundMessage struct {
+	Header        mail.Header
+	From          string   // address of From header, empty if it's invalid
+	Recipients    []string // addresses of To and Cc headers
+	Subject       string
+	Body          string
+	Date          time.Time // zero if Date header is invalid
+	MessageId     string
+	References    string
+	NotifyAddress string            // Disposition-Notification-To, only the valid address
+	Headers       map[string]string // repeated headers (e.g. forwarding trace) are joined to keep every value
+	HasAttachment bool
+}
+
+func ParseInboundMessage(raw []byte) (InboundMessage, error) {
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return InboundMessage{}, err
+	}
+	body, err := ParseBodyText(message)
+	if err != nil {
+		return InboundMessage{}, err
+	}
+
+	inbound := InboundMessage{
+		Header:        message.Header,
+		Subject:       inboundSubjectPolicy.Sanitize(ParseSubject(message.Header.Get("Subject"))),
+		Body:          inboundBodyPolicy.Sanitize(body),
+		MessageId:     message.Header.Get("Message-Id"),
+		References:    message.Header.Get("References"),
+		Headers:       make(map[string]string),
+		HasAttachment: HasAttachment(raw),
+	}
+	if from, err := mail.ParseAddress(message.Header.Get("From")); err == nil {
+		inbound.From = strings.ToLower(from.Address)
+	}
+	for _, header := range []string{"To", "Cc"} {
+		addresses, err := message.Header.AddressList(header)
+		if err != nil {
+			continue
+		}
+		for _, address := range addresses {
+			inbound.Recipients = append(inbound.Recipients, strings.ToLower(address.Address))
+		}
+	}
+	if date, err := message.Header.Date(); err == nil {
+		inbound.Date = date
+	}
+	if address, err := mail.ParseAddress(message.Header.Get("Disposition-Notification-To")); err == nil {
+		inbound.NotifyAddress = address.Address
+	}
+	for key, values := range message.Header {
+		inbound.Headers[key] = strings.Join(values, ", ")
+	}
+	return inbound, nil
+}
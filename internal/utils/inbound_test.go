@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInboundMessage(t *testing.T) {
+	raw := "From: Friend <Friend@ya.ru>\r\n" +
+		"To: liokor@liokor.ru, Other <other@ya.ru>\r\n" +
+		"Cc: third@ya.ru\r\n" +
+		"Subject: =?utf-8?b?0J/RgNC40LLQtdGC?=\r\n" +
+		"Date: Sat, 01 May 2021 12:00:00 +0000\r\n" +
+		"Message-Id: <abc@ya.ru>\r\n" +
+		"Received: from a\r\n" +
+		"Received: from b\r\n" +
+		"\r\n" +
+		"<p onclick=\"steal()\">Hello</p>\r\n"
+
+	inbound, err := ParseInboundMessage([]byte(raw))
+	if err != nil {
+		t.Fatalf("Didn't parse valid message: %v\n", err)
+	}
+	if inbound.From != "friend@ya.ru" || inbound.Subject != "Привет" || inbound.MessageId != "<abc@ya.ru>" {
+		t.Errorf("Wrong headers: %v\n", inbound)
+	}
+	if !reflect.DeepEqual(inbound.Recipients, []string{"liokor@liokor.ru", "other@ya.ru", "third@ya.ru"}) {
+		t.Errorf("Wrong recipients: %v\n", inbound.Recipients)
+	}
+	if !inbound.Date.Equal(time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Wrong date: %v\n", inbound.Date)
+	}
+	if strings.TrimSpace(inbound.Body) != "<p>Hello</p>" {
+		t.Errorf("Body isn't sanitized: %q\n", inbound.Body)
+	}
+	if inbound.Headers["Received"] != "from a, from b" {
+		t.Errorf("Repeated headers aren't joined: %v\n", inbound.Headers)
+	}
+
+	_, err = ParseInboundMessage([]byte("not a message"))
+	if err == nil {
+		t.Errorf("Parsed invalid message\n")
+	}
+}
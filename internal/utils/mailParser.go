@@ -1,12 +1,14 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/base64"
 	"io"
 	"io/ioutil"
 	"mime"
 	"mime/multipart"
 	"net/mail"
+	"net/textproto"
 	"strings"
 )
 
@@ -72,3 +74,33 @@ func ParseBodyText(message *mail.Message) (string, error) {
 
 	return body, nil
 }
+
+// HasAttachment reports whether the raw message has parts with attached files, nested multiparts are checked too
+func HasAttachment(raw []byte) bool {
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return false
+	}
+	return hasAttachedPart(textproto.MIMEHeader(message.Header), message.Body)
+}
+
+func hasAttachedPart(header textproto.MIMEHeader, body io.Reader) bool {
+	disposition, params, err := mime.ParseMediaType(header.Get("Content-Disposition"))
+	if err == nil && (disposition == "attachment" || params["filename"] != "") {
+		return true
+	}
+	contentType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(contentType, "multipart/") {
+		return false
+	}
+	parts := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			return false
+		}
+		if hasAttachedPart(part.Header, part) {
+			return true
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package utils
+
+import "testing"
+
+func TestHasAttachment(t *testing.T) {
+	withAttachment := "Content-Type: multipart/mixed; boundary=\"A\"\r\n\r\n" +
+		"--A\r\n" +
+		"Content-Type: multipart/alternative; boundary=\"B\"\r\n\r\n" +
+		"--B\r\nContent-Type: text/plain\r\n\r\nHello\r\n--B--\r\n" +
+		"--A\r\n" +
+		"Content-Type: application/pdf\r\n" +
+		"Content-Disposition: attachment; filename=\"cv.pdf\"\r\n\r\n" +
+		"JVBERi0=\r\n" +
+		"--A--\r\n"
+	if !HasAttachment([]byte(withAttachment)) {
+		t.Errorf("Attachment isn't found\n")
+	}
+
+	withoutAttachment := "Content-Type: multipart/alternative; boundary=\"B\"\r\n\r\n" +
+		"--B\r\nContent-Type: text/plain\r\n\r\nHello\r\n" +
+		"--B\r\nContent-Type: text/html\r\n\r\n<p>Hello</p>\r\n--B--\r\n"
+	if HasAttachment([]byte(withoutAttachment)) || HasAttachment([]byte("Subject: Hi\r\n\r\nHello\r\n")) {
+		t.Errorf("Attachment is found in plain mail\n")
+	}
+}
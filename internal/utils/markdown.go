@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"io"
+	"strings"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/parser"
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+)
+
+// MarkdownRenderer turns Markdown written by users into html, mail is sent and previewed with the same renderer
+type MarkdownRenderer struct {
+	Extensions   parser.Extensions
+	InputPolicy  *bluemonday.Policy // applied to Markdown source to strip raw html
+	OutputPolicy *bluemonday.Policy // applied to rendered html to remove restricted tags and add nofollow to links
+}
+
+func NewMarkdownRenderer() *MarkdownRenderer {
+	return &MarkdownRenderer{
+		Extensions:   parser.CommonExtensions | parser.AutoHeadingIDs,
+		InputPolicy:  bluemonday.StrictPolicy(),
+		OutputPolicy: bluemonday.UGCPolicy(),
+	}
+}
+
+// ToHTML converts Markdown to html without sanitizing
+func (r *MarkdownRenderer) ToHTML(md string) string {
+	return string(markdown.ToHTML([]byte(md), parser.NewWithExtensions(r.Extensions), nil))
+}
+
+// Render sanitizes and converts Markdown, appended html (e.g. quoted mail) only passes the output policy
+func (r *MarkdownRenderer) Render(md string, appended string) string {
+	rendered, _ := r.RenderWithReport(md, appended)
+	return rendered
+}
+
+// RenderWithReport is Render also listing tags and attributes removed by the policies,
+// e.g. "<script>" for the element and "<a onclick>" for its attribute
+func (r *MarkdownRenderer) RenderWithReport(md string, appended string) (string, []string) {
+	report := make([]string, 0)
+
+	sanitized := r.InputPolicy.Sanitize(md)
+	report = appendStripped(report, md, sanitized)
+
+	rendered := r.ToHTML(sanitized) + appended
+	result := r.OutputPolicy.Sanitize(rendered)
+	report = appendStripped(report, rendered, result)
+
+	return result, report
+}
+
+// htmlMarkup lists tags and tag attributes of the html in order of appearance, repeated ones are listed every time
+func htmlMarkup(source string) []string {
+	markup := make([]string, 0)
+	tokenizer := html.NewTokenizer(strings.NewReader(source))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if tokenizer.Err() != io.EOF {
+				return markup
+			}
+			break
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+		token := tokenizer.Token()
+		markup = append(markup, "<"+token.Data+">")
+		for _, attr := range token.Attr {
+			markup = append(markup, "<"+token.Data+" "+attr.Key+">")
+		}
+	}
+	return markup
+}
+
+// appendStripped adds markup of the source missing in the sanitized html to the report once
+func appendStripped(report []string, source string, sanitized string) []string {
+	left := make(map[string]int)
+	for _, item := range htmlMarkup(sanitized) {
+		left[item]++
+	}
+	reported := make(map[string]bool)
+	for _, item := range report {
+		reported[item] = true
+	}
+	for _, item := range htmlMarkup(source) {
+		if left[item] > 0 {
+			left[item]--
+			continue
+		}
+		if !reported[item] {
+			reported[item] = true
+			report = append(report, item)
+		}
+	}
+	return report
+}
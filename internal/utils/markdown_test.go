@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	renderer := NewMarkdownRenderer()
+
+	rendered := renderer.Render("Hello, **world**", "<blockquote>quote</blockquote>")
+	if rendered != "<p>Hello, <strong>world</strong></p>\n<blockquote>quote</blockquote>" {
+		t.Errorf("Wrong rendering: %q\n", rendered)
+	}
+
+	rendered, stripped := renderer.RenderWithReport("Hi <b>there</b><script>alert(1)</script>", `<a href="https://ya.ru" onclick="steal()">link</a><iframe src="https://ya.ru"></iframe>`)
+	if strings.Contains(rendered, "script") || strings.Contains(rendered, "onclick") || strings.Contains(rendered, "iframe") {
+		t.Errorf("Restricted markup wasn't removed: %s\n", rendered)
+	}
+	if !strings.Contains(rendered, `rel="nofollow"`) {
+		t.Errorf("Nofollow isn't added to links: %s\n", rendered)
+	}
+
+	expected := []string{"<b>", "<script>", "<a onclick>", "<iframe>", "<iframe src>"}
+	if strings.Join(stripped, ",") != strings.Join(expected, ",") {
+		t.Errorf("Wrong stripped markup: %v\n", stripped)
+	}
+
+	_, stripped = renderer.RenderWithReport("# Title\n\n[link](https://ya.ru)", "")
+	if len(stripped) != 0 {
+		t.Errorf("Markdown markup is reported as stripped: %v\n", stripped)
+	}
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"regexp"
+	"time"
+)
+
+// StoredMessage is a mail as it's kept in the database, only its html body is stored
+type StoredMessage struct {
+	From       string
+	To         string
+	Subject    string
+	Date       time.Time
+	MessageId  string // with angle brackets
+	References string // message ids separated by spaces
+	Html       string
+}
+
+// FormatMessage builds RFC 5322 message of the stored mail for clients downloading it
+func FormatMessage(m StoredMessage) []byte {
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: <%s>\r\n", m.From)
+	fmt.Fprintf(&message, "To: <%s>\r\n", m.To)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", m.Date.Format(time.RFC1123Z))
+	if m.MessageId != "" {
+		fmt.Fprintf(&message, "Message-ID: %s\r\n", m.MessageId)
+	}
+	if m.References != "" {
+		fmt.Fprintf(&message, "References: %s\r\n", m.References)
+	}
+	fmt.Fprint(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprint(&message, "Content-Type: text/html; charset=utf-8\r\n")
+	fmt.Fprint(&message, "Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	body := quotedprintable.NewWriter(&message)
+	body.Write([]byte(m.Html))
+	body.Close()
+	return message.Bytes()
+}
+
+// FormatForwardMessage builds outgoing html message with the forwarded one attached as message/rfc822
+func FormatForwardMessage(from string, to string, subject string, html string, headers map[string]string, forwarded []byte) []byte {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	text, _ := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	encoder := quotedprintable.NewWriter(text)
+	encoder.Write([]byte(html))
+	encoder.Close()
+
+	attached, _ := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {"message/rfc822"},
+		"Content-Disposition": {`attachment; filename="forwarded.eml"`},
+	})
+	attached.Write(forwarded)
+	parts.Close()
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: <%s>\r\nTo: %s\r\nSubject: %s\r\n%s", from, to, subject, FormatHeaders(headers))
+	fmt.Fprint(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/mixed; boundary=\"%s\"\r\n\r\n", parts.Boundary())
+	message.Write(body.Bytes())
+	return message.Bytes()
+}
+
+// lines of the message looking like mbox separator, quoted ones included
+var mboxFromLine = regexp.MustCompile(`(?m)^(>*From )`)
+
+// WriteMbox appends the message to mboxrd file: separator line, the message with LF line endings
+// and "From " lines quoted by one more '>', and the empty line
+func WriteMbox(w io.Writer, from string, date time.Time, message []byte) error {
+	message = bytes.ReplaceAll(message, []byte("\r\n"), []byte("\n"))
+	message = mboxFromLine.ReplaceAll(message, []byte(">$1"))
+	if !bytes.HasSuffix(message, []byte("\n")) {
+		message = append(message, '\n')
+	}
+	_, err := fmt.Fprintf(w, "From %s %s\n%s\n", from, date.UTC().Format(time.ANSIC), message)
+	return err
+}
+
+// quoted "From " lines, mboxrd readers remove one '>' from them
+var mboxQuotedFromLine = regexp.MustCompile(`^>+From `)
+
+// ReadMbox calls handle for every message of mboxrd or mboxo file in order, messages have CRLF line endings
+func ReadMbox(r io.Reader, handle func(message []byte) error) error {
+	reader := bufio.NewReader(r)
+	var message bytes.Buffer
+	started := false
+	flush := func() error {
+		if !started {
+			return nil
+		}
+		// the empty line separating messages isn't part of the message
+		raw := bytes.TrimSuffix(message.Bytes(), []byte("\r\n"))
+		message.Reset()
+		return handle(raw)
+	}
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			line = bytes.TrimRight(line, "\r\n")
+			if bytes.HasPrefix(line, []byte("From ")) {
+				if errFlush := flush(); errFlush != nil {
+					return errFlush
+				}
+				started = true
+			} else if started {
+				if mboxQuotedFromLine.Match(line) {
+					line = line[1:]
+				}
+				message.Write(line)
+				message.WriteString("\r\n")
+			}
+		}
+		if err == io.EOF {
+			return flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatMessage(t *testing.T) {
+	stored := StoredMessage{
+		From:       "liokor@liokor.ru",
+		To:         "friend@ya.ru",
+		Subject:    "Привет",
+		Date:       time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC),
+		MessageId:  "<2@liokor.ru>",
+		References: "<1@ya.ru>",
+		Html:       "<p>" + strings.Repeat("long line ", 20) + "</p>",
+	}
+	formatted := FormatMessage(stored)
+	for _, line := range strings.Split(string(formatted), "\r\n") {
+		if len(line) > 78 {
+			t.Errorf("Line is too long: %s\n", line)
+		}
+	}
+
+	message, err := mail.ReadMessage(strings.NewReader(string(formatted)))
+	if err != nil {
+		t.Fatalf("Didn't build valid message: %v\n", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	date, _ := message.Header.Date()
+	if subject != stored.Subject || !date.Equal(stored.Date) || message.Header.Get("Message-Id") != stored.MessageId {
+		t.Errorf("Wrong headers: %v\n", message.Header)
+	}
+	body, _ := ioutil.ReadAll(quotedprintable.NewReader(message.Body))
+	if string(body) != stored.Html {
+		t.Errorf("Wrong body: %s\n", body)
+	}
+}
+
+func TestWriteMbox(t *testing.T) {
+	var mbox bytes.Buffer
+	date := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	err := WriteMbox(&mbox, "liokor@liokor.ru", date, []byte("Subject: Hi\r\n\r\nFrom me\r\n>From you"))
+	if err != nil {
+		t.Fatalf("Didn't write message: %v\n", err)
+	}
+	expected := "From liokor@liokor.ru Sat May  1 12:00:00 2021\nSubject: Hi\n\n>From me\n>>From you\n\n"
+	if mbox.String() != expected {
+		t.Errorf("Wrong mbox: %q\n", mbox.String())
+	}
+}
+
+func TestReadMbox(t *testing.T) {
+	var mbox bytes.Buffer
+	date := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	first := "Subject: Hi\r\n\r\nFrom me\r\n>From you\r\n"
+	second := "Subject: Bye\r\n\r\nBye\r\n"
+	WriteMbox(&mbox, "liokor@liokor.ru", date, []byte(first))
+	WriteMbox(&mbox, "friend@ya.ru", date, []byte(second))
+
+	messages := make([]string, 0)
+	err := ReadMbox(&mbox, func(message []byte) error {
+		messages = append(messages, string(message))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Didn't read mbox: %v\n", err)
+	}
+	if len(messages) != 2 || messages[0] != first || messages[1] != second {
+		t.Errorf("Wrong messages: %q\n", messages)
+	}
+}
+
+func TestFormatForwardMessage(t *testing.T) {
+	forwarded := []byte("From: friend@ya.ru\r\nSubject: Report\r\n\r\nSee attached\r\n")
+	raw := FormatForwardMessage("alt@liokor.ru", "boss@ya.ru", "Fwd: Report", "<p>FYI</p>", map[string]string{"References": "<7@liokor.ru>"}, forwarded)
+
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Invalid message: %v\n", err)
+	}
+	if message.Header.Get("References") != "<7@liokor.ru>" || message.Header.Get("Subject") != "Fwd: Report" {
+		t.Errorf("Wrong headers: %v\n", message.Header)
+	}
+	if !HasAttachment(raw) {
+		t.Errorf("Forwarded message isn't attached: %s\n", raw)
+	}
+	if !bytes.Contains(raw, forwarded) {
+		t.Errorf("Forwarded message is changed: %s\n", raw)
+	}
+}
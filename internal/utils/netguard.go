@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrInternalAddress refuses connections of user given urls and hosts to the server's own network
+var ErrInternalAddress = errors.New("connections to internal addresses aren't allowed")
+
+// networks not reachable from the internet, besides loopback, link-local and unspecified addresses
+var internalNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10", // carrier-grade NAT
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"fc00::/7", // unique local
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, networks[i], _ = net.ParseCIDR(cidr)
+	}
+	return networks
+}
+
+// IsPublicIP reports whether the address may be connected to on behalf of users
+func IsPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicDialControl checks the address after DNS resolution, so names resolving to internal addresses
+// and redirects to them are refused too
+func publicDialControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !IsPublicIP(net.ParseIP(host)) {
+		return ErrInternalAddress
+	}
+	return nil
+}
+
+// PublicDialer connects to public addresses only
+func PublicDialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{Timeout: timeout, Control: publicDialControl}
+}
+
+// NewPublicHTTPClient returns client for requests to user given urls, environment proxy isn't used
+// as the proxy would connect to internal addresses instead of the dialer
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = PublicDialer(timeout).DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// CheckPublicHost rejects user given host when it's saved, host with port is accepted. Host which
+// can't be resolved now is accepted, the dialer checks it again on each connection
+func CheckPublicHost(host string) error {
+	if splitted, _, err := net.SplitHostPort(host); err == nil {
+		host = splitted
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrInternalAddress
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !IsPublicIP(ip) {
+			return ErrInternalAddress
+		}
+		return nil
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip) {
+			return ErrInternalAddress
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	internal := []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "0.0.0.0", "100.64.0.1", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1"}
+	for _, address := range internal {
+		if IsPublicIP(net.ParseIP(address)) {
+			t.Errorf("Internal address %s is public\n", address)
+		}
+	}
+	for _, address := range []string{"8.8.8.8", "2a00:1450:4010::8a"} {
+		if !IsPublicIP(net.ParseIP(address)) {
+			t.Errorf("Public address %s is internal\n", address)
+		}
+	}
+}
+
+func TestCheckPublicHost(t *testing.T) {
+	for _, host := range []string{"127.0.0.1:8080", "localhost", "api.localhost", "[::1]:993", "169.254.169.254"} {
+		if CheckPublicHost(host) != ErrInternalAddress {
+			t.Errorf("Internal host %s is accepted\n", host)
+		}
+	}
+	if err := CheckPublicHost("8.8.8.8:993"); err != nil {
+		t.Errorf("Public host is rejected: %v\n", err)
+	}
+}
+
+func TestPublicHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := NewPublicHTTPClient(time.Second).Get(server.URL)
+	if err == nil || !errors.Is(err, ErrInternalAddress) {
+		t.Errorf("Connected to loopback server: %v\n", err)
+	}
+}
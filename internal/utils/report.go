@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// report types of multipart/report (RFC 6522)
+const (
+	ReportDeliveryStatus = "delivery-status"          // DSN, RFC 3464
+	ReportDisposition    = "disposition-notification" // MDN, RFC 8098
+)
+
+type ReportRecipient struct {
+	Address    string
+	Original   string // Original-Recipient, the address the message was sent to, if reported
+	Action     string // failed, delayed, delivered, relayed or expanded
+	Status     string // e.g. 5.1.1
+	Diagnostic string // e.g. "smtp; 550 5.1.1 User unknown"
+}
+
+// OriginalAddress returns the address the message was sent to, Final-Recipient may differ after forwarding
+func (r ReportRecipient) OriginalAddress() string {
+	if r.Original != "" {
+		return r.Original
+	}
+	return r.Address
+}
+
+type Report struct {
+	Type              string
+	OriginalMessageId string
+	Recipients        []ReportRecipient // for MDN the only recipient that processed the message
+	Disposition       string            // MDN disposition type, e.g. displayed or deleted
+}
+
+// reportField returns value of address or status field without type and comment, e.g. "rfc822; alt@liokor.ru"
+func reportField(value string) string {
+	if i := strings.Index(value, ";"); i >= 0 {
+		value = value[i+1:]
+	}
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.Trim(fields[0], "<>")
+}
+
+// readFieldGroups reads groups of header fields separated by empty lines
+func readFieldGroups(r io.Reader) []textproto.MIMEHeader {
+	groups := make([]textproto.MIMEHeader, 0)
+	reader := textproto.NewReader(bufio.NewReader(r))
+	for {
+		fields, err := reader.ReadMIMEHeader()
+		if len(fields) > 0 {
+			groups = append(groups, fields)
+		}
+		if err != nil {
+			return groups
+		}
+	}
+}
+
+// ParseReport parses DSN or MDN, ok is false for any other message
+func ParseReport(raw []byte) (Report, bool) {
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return Report{}, false
+	}
+	contentType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || contentType != "multipart/report" {
+		return Report{}, false
+	}
+	report := Report{
+		Type:       strings.ToLower(params["report-type"]),
+		Recipients: make([]ReportRecipient, 0),
+	}
+	if report.Type != ReportDeliveryStatus && report.Type != ReportDisposition {
+		return Report{}, false
+	}
+
+	parts := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			break
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			groups := readFieldGroups(part)
+			// the first group is about the whole message
+			for i := 1; i < len(groups); i++ {
+				report.Recipients = append(report.Recipients, ReportRecipient{
+					Address:    reportField(groups[i].Get("Final-Recipient")),
+					Original:   reportField(groups[i].Get("Original-Recipient")),
+					Action:     strings.ToLower(strings.TrimSpace(groups[i].Get("Action"))),
+					Status:     reportField(groups[i].Get("Status")),
+					Diagnostic: strings.TrimSpace(groups[i].Get("Diagnostic-Code")),
+				})
+			}
+		case "message/disposition-notification", "message/global-disposition-notification":
+			groups := readFieldGroups(part)
+			if len(groups) == 0 {
+				continue
+			}
+			report.Recipients = append(report.Recipients, ReportRecipient{
+				Address:  reportField(groups[0].Get("Final-Recipient")),
+				Original: reportField(groups[0].Get("Original-Recipient")),
+			})
+			report.Disposition = strings.ToLower(reportField(groups[0].Get("Disposition")))
+			if id := groups[0].Get("Original-Message-Id"); id != "" {
+				report.OriginalMessageId = strings.TrimSpace(id)
+			}
+		case "message/rfc822", "text/rfc822-headers", "message/global", "message/global-headers":
+			groups := readFieldGroups(part)
+			if len(groups) > 0 && report.OriginalMessageId == "" {
+				report.OriginalMessageId = strings.TrimSpace(groups[0].Get("Message-Id"))
+			}
+		}
+	}
+	return report, true
+}
+
+type MDN struct {
+	From              string // recipient of the original message
+	To                string // Disposition-Notification-To of the original message
+	Subject           string
+	OriginalMessageId string
+	ReportingDomain   string
+}
+
+// FormatMDN builds displayed notification sent automatically on opening the message by the user
+func FormatMDN(mdn MDN) []byte {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	text, _ := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	fmt.Fprintf(
+		text,
+		"The message sent to %s with subject \"%s\" has been displayed.\r\n"+
+			"This is no guarantee that the message has been read or understood.\r\n",
+		mdn.From,
+		mdn.Subject,
+	)
+
+	notification, _ := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"message/disposition-notification"}})
+	fmt.Fprintf(notification, "Reporting-UA: %s; LioKor Mail\r\n", mdn.ReportingDomain)
+	fmt.Fprintf(notification, "Final-Recipient: rfc822; %s\r\n", mdn.From)
+	if mdn.OriginalMessageId != "" {
+		fmt.Fprintf(notification, "Original-Message-ID: %s\r\n", mdn.OriginalMessageId)
+	}
+	fmt.Fprint(notification, "Disposition: manual-action/MDN-sent-automatically; displayed\r\n")
+	parts.Close()
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: <%s>\r\n", mdn.From)
+	fmt.Fprintf(&message, "To: <%s>\r\n", mdn.To)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Read: "+mdn.Subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprint(&message, "Auto-Submitted: auto-replied\r\n")
+	fmt.Fprint(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(
+		&message,
+		"Content-Type: multipart/report; report-type=disposition-notification; boundary=\"%s\"\r\n\r\n",
+		parts.Boundary(),
+	)
+	message.Write(body.Bytes())
+	return message.Bytes()
+}
+
+type DSN struct {
+	From            string // MAILER-DAEMON of the reporting domain
+	To              string // envelope sender of the original message
+	ReportingDomain string
+	Recipients      []ReportRecipient
+	OriginalHeaders []byte
+}
+
+// FormatDSN builds delivery status notification with original headers attached
+func FormatDSN(dsn DSN) []byte {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	text, _ := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	fmt.Fprint(text, "Your message couldn't be delivered to the following recipients:\r\n\r\n")
+	for _, recipient := range dsn.Recipients {
+		fmt.Fprintf(text, "<%s>: %s\r\n", recipient.Address, recipient.Diagnostic)
+	}
+
+	status, _ := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"message/delivery-status"}})
+	fmt.Fprintf(status, "Reporting-MTA: dns; %s\r\n", dsn.ReportingDomain)
+	fmt.Fprintf(status, "Arrival-Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	for _, recipient := range dsn.Recipients {
+		fmt.Fprint(status, "\r\n")
+		fmt.Fprintf(status, "Final-Recipient: rfc822; %s\r\n", recipient.Address)
+		fmt.Fprintf(status, "Action: %s\r\n", recipient.Action)
+		fmt.Fprintf(status, "Status: %s\r\n", recipient.Status)
+		if recipient.Diagnostic != "" {
+			fmt.Fprintf(status, "Diagnostic-Code: %s\r\n", recipient.Diagnostic)
+		}
+	}
+
+	headers, _ := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/rfc822-headers"}})
+	headers.Write(dsn.OriginalHeaders)
+	parts.Close()
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: Mail Delivery System <%s>\r\n", dsn.From)
+	fmt.Fprintf(&message, "To: <%s>\r\n", dsn.To)
+	fmt.Fprint(&message, "Subject: Undelivered Mail Returned to Sender\r\n")
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprint(&message, "Auto-Submitted: auto-replied\r\n")
+	fmt.Fprint(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(
+		&message,
+		"Content-Type: multipart/report; report-type=delivery-status; boundary=\"%s\"\r\n\r\n",
+		parts.Boundary(),
+	)
+	message.Write(body.Bytes())
+	return message.Bytes()
+}
+
+// MessageHeaders returns header section of the raw message
+func MessageHeaders(raw []byte) []byte {
+	for _, separator := range []string{"\r\n\r\n", "\n\n"} {
+		if i := bytes.Index(raw, []byte(separator)); i >= 0 {
+			return raw[:i+len(separator)/2]
+		}
+	}
+	return raw
+}
+
+// PermanentFailure reports whether the delivery error won't go away on retry: 5xx replies, domains
+// without mail servers and errors of the message itself. 4xx replies and network errors are temporary
+func PermanentFailure(err error) bool {
+	if smtpErr, ok := err.(*textproto.Error); ok {
+		return smtpErr.Code >= 500
+	}
+	if dnsErr, ok := err.(*net.DNSError); ok {
+		return dnsErr.IsNotFound
+	}
+	if _, ok := err.(net.Error); ok {
+		return false
+	}
+	return true
+}
+
+// FailedRecipient describes permanent delivery failure, see PermanentFailure
+func FailedRecipient(address string, err error) ReportRecipient {
+	recipient := ReportRecipient{
+		Address:    address,
+		Action:     "failed",
+		Status:     "5.4.4", // unable to route, e.g. no MX records
+		Diagnostic: "x-unix; " + err.Error(),
+	}
+	if smtpErr, ok := err.(*textproto.Error); ok {
+		recipient.Status = strconv.Itoa(smtpErr.Code/100) + ".0.0"
+		recipient.Diagnostic = "smtp; " + strconv.Itoa(smtpErr.Code) + " " + smtpErr.Msg
+	}
+	return recipient
+}
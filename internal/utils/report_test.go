@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+const dsn = "From: Mail Delivery System <MAILER-DAEMON@ya.ru>\r\n" +
+	"To: <alt@liokor.ru>\r\n" +
+	"Subject: Undelivered Mail Returned to Sender\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=delivery-status; boundary=\"B\"\r\n" +
+	"\r\n" +
+	"--B\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Your message could not be delivered.\r\n" +
+	"--B\r\n" +
+	"Content-Type: message/delivery-status\r\n" +
+	"\r\n" +
+	"Reporting-MTA: dns; mx.ya.ru\r\n" +
+	"\r\n" +
+	"Original-Recipient: rfc822; friend@ya.ru\r\n" +
+	"Final-Recipient: rfc822; nobody@ya.ru\r\n" +
+	"Action: failed\r\n" +
+	"Status: 5.1.1 (user unknown)\r\n" +
+	"\r\n" +
+	"--B\r\n" +
+	"Content-Type: text/rfc822-headers\r\n" +
+	"\r\n" +
+	"From: <alt@liokor.ru>\r\n" +
+	"Message-ID: <12@liokor.ru>\r\n" +
+	"\r\n" +
+	"--B--\r\n"
+
+func TestParseReport(t *testing.T) {
+	report, ok := ParseReport([]byte(dsn))
+	if !ok || report.Type != ReportDeliveryStatus {
+		t.Errorf("DSN isn't parsed: %v\n", report)
+	}
+	if report.OriginalMessageId != "<12@liokor.ru>" || len(report.Recipients) != 1 {
+		t.Errorf("Wrong DSN: %v\n", report)
+	}
+	if report.Recipients[0] != (ReportRecipient{Address: "nobody@ya.ru", Original: "friend@ya.ru", Action: "failed", Status: "5.1.1"}) {
+		t.Errorf("Wrong DSN recipient: %v\n", report.Recipients[0])
+	}
+	if report.Recipients[0].OriginalAddress() != "friend@ya.ru" {
+		t.Errorf("Wrong original recipient: %v\n", report.Recipients[0])
+	}
+
+	_, ok = ParseReport([]byte("From: <alt@liokor.ru>\r\nSubject: Hi\r\n\r\nHello\r\n"))
+	if ok {
+		t.Errorf("Regular mail is parsed as report\n")
+	}
+}
+
+func TestFormatMDN(t *testing.T) {
+	raw := FormatMDN(MDN{
+		From:              "alt@liokor.ru",
+		To:                "friend@ya.ru",
+		Subject:           "Hi",
+		OriginalMessageId: "<abc@ya.ru>",
+		ReportingDomain:   "liokor.ru",
+	})
+	if !strings.Contains(string(raw), "Subject: Read: Hi\r\n") {
+		t.Errorf("Wrong MDN subject: %s\n", raw)
+	}
+
+	report, ok := ParseReport(raw)
+	if !ok || report.Type != ReportDisposition || report.Disposition != "displayed" {
+		t.Errorf("MDN isn't parsed back: %v\n", report)
+	}
+	if report.OriginalMessageId != "<abc@ya.ru>" || report.Recipients[0].Address != "alt@liokor.ru" {
+		t.Errorf("Wrong MDN fields: %v\n", report)
+	}
+}
+
+func TestFormatDSN(t *testing.T) {
+	raw := FormatDSN(DSN{
+		From:            "MAILER-DAEMON@liokor.ru",
+		To:              "friend@ya.ru",
+		ReportingDomain: "liokor.ru",
+		Recipients: []ReportRecipient{
+			{Address: "nobody@liokor.ru", Action: "failed", Status: "5.1.1", Diagnostic: "smtp; 550 5.1.1 Recipient doesn't exist"},
+		},
+		OriginalHeaders: MessageHeaders([]byte("From: <friend@ya.ru>\r\nMessage-ID: <abc@ya.ru>\r\n\r\nHello\r\n")),
+	})
+	if strings.Contains(string(raw), "Hello") {
+		t.Errorf("Original body is attached to DSN: %s\n", raw)
+	}
+
+	report, ok := ParseReport(raw)
+	if !ok || report.Type != ReportDeliveryStatus || report.OriginalMessageId != "<abc@ya.ru>" {
+		t.Errorf("DSN isn't parsed back: %v\n", report)
+	}
+	expected := ReportRecipient{Address: "nobody@liokor.ru", Action: "failed", Status: "5.1.1", Diagnostic: "smtp; 550 5.1.1 Recipient doesn't exist"}
+	if len(report.Recipients) != 1 || report.Recipients[0] != expected {
+		t.Errorf("Wrong DSN recipients: %v\n", report.Recipients)
+	}
+}
+
+func TestPermanentFailure(t *testing.T) {
+	permanent := []error{
+		&textproto.Error{Code: 550, Msg: "5.1.1 User unknown"},
+		&net.DNSError{Err: "no such host", Name: "nowhere.ru", IsNotFound: true},
+		errors.New("invalid recipient address!"),
+	}
+	for _, err := range permanent {
+		if !PermanentFailure(err) {
+			t.Errorf("Permanent failure is temporary: %v\n", err)
+		}
+	}
+	temporary := []error{
+		&textproto.Error{Code: 451, Msg: "4.7.1 Try again later"},
+		&net.DNSError{Err: "server misbehaving", Name: "ya.ru", IsTemporary: true},
+		&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+	}
+	for _, err := range temporary {
+		if PermanentFailure(err) {
+			t.Errorf("Temporary failure is permanent: %v\n", err)
+		}
+	}
+}
@@ -2,18 +2,40 @@ package utils
 
 import (
 	"bytes"
-	"crypto/rsa"
 	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/smtp"
+	"sort"
 	"strings"
 
 	"github.com/emersion/go-msgauth/dkim"
 )
 
-func SMTPSendMail(from string, to string, subject string, data string, privateKey *rsa.PrivateKey) error {
+func SMTPSendMail(from string, to string, subject string, data string, headers map[string]string, key *DkimKey) error {
+	mail := fmt.Sprintf("From: <%s>\r\nTo: %s\r\nContent-Type: text/html\r\nSubject: %s\r\n%s\r\n%s\r\n", from, to, subject, FormatHeaders(headers), data)
+
+	return SMTPRelayMail(from, to, []byte(mail), key)
+}
+
+// FormatHeaders returns headers sorted by key, so the message is always built the same way
+func FormatHeaders(headers map[string]string) string {
+	headerKeys := make([]string, 0, len(headers))
+	for key := range headers {
+		headerKeys = append(headerKeys, key)
+	}
+	sort.Strings(headerKeys)
+	var formatted strings.Builder
+	for _, key := range headerKeys {
+		formatted.WriteString(fmt.Sprintf("%s: %s\r\n", key, headers[key]))
+	}
+	return formatted.String()
+}
+
+// SMTPRelayMail signs and delivers already built message. Empty envelopeFrom is sent as null sender (<>),
+// nil key sends message unsigned
+func SMTPRelayMail(envelopeFrom string, to string, message []byte, key *DkimKey) error {
 	recipientSplitted := strings.Split(to, "@")
 	if len(recipientSplitted) != 2 {
 		return errors.New("invalid recipient address!")
@@ -28,18 +50,16 @@ func SMTPSendMail(from string, to string, subject string, data string, privateKe
 	host := mxrecords[0].Host
 	host = host[:len(host)-1]
 
-	mail := fmt.Sprintf("From: <%s>\r\nTo: %s\r\nContent-Type: text/html\r\nSubject: %s\r\n\r\n%s\r\n", from, to, subject, data)
-
 	var bodyBuffer bytes.Buffer
 
-	if privateKey == nil {
-		bodyBuffer.WriteString(mail)
+	if key == nil {
+		bodyBuffer.Write(message)
 	} else {
-		r := strings.NewReader(mail)
+		r := bytes.NewReader(message)
 		options := &dkim.SignOptions{
-			Domain:   "liokor.ru",
-			Selector: "wolf",
-			Signer:   privateKey,
+			Domain:   key.Domain,
+			Selector: key.Selector,
+			Signer:   key.PrivateKey,
 		}
 		err = dkim.Sign(&bodyBuffer, r, options)
 		if err != nil {
@@ -48,7 +68,7 @@ func SMTPSendMail(from string, to string, subject string, data string, privateKe
 		}
 	}
 
-	err = smtp.SendMail(host+":25", nil, from, []string{to}, bodyBuffer.Bytes())
+	err = smtp.SendMail(host+":25", nil, envelopeFrom, []string{to}, bodyBuffer.Bytes())
 	if err != nil {
 		log.Println(err)
 		return err
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Sender Rewriting Scheme (SRS0 only), so forwarded mail passes SPF of the forwarding domain:
+// user@example.com -> SRS0=HHHH=TT=example.com=user@forwarder.domain
+const srsTimestampAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+const srsTimestampPrecision = 24 * time.Hour
+const srsTimestampSlots = 1024 // 2 base32 chars
+
+func srsTimestamp(now time.Time) string {
+	day := int(now.Unix()/int64(srsTimestampPrecision/time.Second)) % srsTimestampSlots
+	return string([]byte{srsTimestampAlphabet[day>>5], srsTimestampAlphabet[day&31]})
+}
+
+func srsHash(secret string, parts ...string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	for _, part := range parts {
+		mac.Write([]byte(strings.ToLower(part)))
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))[:4]
+}
+
+func IsSRSAddress(address string) bool {
+	return strings.HasPrefix(strings.ToUpper(address), "SRS0=")
+}
+
+func SRSForward(address string, domain string, secret string) (string, error) {
+	splitted := strings.Split(address, "@")
+	if len(splitted) != 2 || splitted[0] == "" || splitted[1] == "" {
+		return "", errors.New("invalid address to rewrite")
+	}
+	local, host := splitted[0], splitted[1]
+
+	timestamp := srsTimestamp(time.Now())
+	hash := srsHash(secret, timestamp, host, local)
+	return "SRS0=" + hash + "=" + timestamp + "=" + host + "=" + local + "@" + domain, nil
+}
+
+func SRSReverse(address string, secret string, maxAge time.Duration) (string, error) {
+	if !IsSRSAddress(address) {
+		return "", errors.New("not an SRS address")
+	}
+	atIndex := strings.LastIndex(address, "@")
+	if atIndex < 0 {
+		return "", errors.New("invalid SRS address")
+	}
+	// local part of the original address may contain "=", so split only 4 times
+	parts := strings.SplitN(address[len("SRS0="):atIndex], "=", 4)
+	if len(parts) != 4 {
+		return "", errors.New("invalid SRS address")
+	}
+	hash, timestamp, host, local := parts[0], parts[1], parts[2], parts[3]
+
+	// mail servers on the way may change the case of the local part, so the hash is compared ignoring it
+	expected := srsHash(secret, timestamp, host, local)
+	if !hmac.Equal([]byte(strings.ToLower(hash)), []byte(strings.ToLower(expected))) {
+		return "", errors.New("invalid SRS hash")
+	}
+
+	if len(timestamp) != 2 {
+		return "", errors.New("invalid SRS timestamp")
+	}
+	high := strings.IndexByte(srsTimestampAlphabet, strings.ToUpper(timestamp)[0])
+	low := strings.IndexByte(srsTimestampAlphabet, strings.ToUpper(timestamp)[1])
+	if high < 0 || low < 0 {
+		return "", errors.New("invalid SRS timestamp")
+	}
+	today := int(time.Now().Unix() / int64(srsTimestampPrecision/time.Second))
+	age := (today - (high<<5 | low)) % srsTimestampSlots
+	if age < 0 {
+		age += srsTimestampSlots
+	}
+	if time.Duration(age)*srsTimestampPrecision > maxAge {
+		return "", errors.New("SRS address expired")
+	}
+
+	return local + "@" + host, nil
+}
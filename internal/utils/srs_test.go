@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSRS(t *testing.T) {
+	rewritten, err := SRSForward("friend@ya.ru", "liokor.ru", "secret")
+	if err != nil {
+		t.Errorf("Didn't rewrite valid address: %v\n", err)
+	}
+	if !IsSRSAddress(rewritten) {
+		t.Errorf("Rewritten address isn't SRS: %s\n", rewritten)
+	}
+
+	original, err := SRSReverse(rewritten, "secret", 7*24*time.Hour)
+	if err != nil || original != "friend@ya.ru" {
+		t.Errorf("Didn't reverse valid address: %s %v\n", original, err)
+	}
+
+	original, err = SRSReverse(strings.ToLower(rewritten), "secret", 7*24*time.Hour)
+	if err != nil || original != "friend@ya.ru" {
+		t.Errorf("Didn't reverse lowercased address: %s %v\n", original, err)
+	}
+
+	_, err = SRSReverse(rewritten, "another secret", 7*24*time.Hour)
+	if err == nil {
+		t.Errorf("Reversed address with invalid hash\n")
+	}
+
+	_, err = SRSForward("invalid", "liokor.ru", "secret")
+	if err == nil {
+		t.Errorf("Rewrote invalid address\n")
+	}
+
+	_, err = SRSReverse("friend@ya.ru", "secret", 7*24*time.Hour)
+	if err == nil {
+		t.Errorf("Reversed not SRS address\n")
+	}
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+// VCard holds the subset of vCard (RFC 2426) properties kept in the address book
+type VCard struct {
+	Uid        string
+	FullName   string
+	Emails     []string
+	Phone      string
+	Note       string
+	Categories []string
+}
+
+var vCardEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`)
+
+func vCardLine(b *strings.Builder, line string) {
+	// lines longer than 75 octets are folded, continuation starts with a space
+	for len(line) > 75 {
+		cut := 75
+		for cut > 1 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func FormatVCard(card VCard) string {
+	var b strings.Builder
+	vCardLine(&b, "BEGIN:VCARD")
+	vCardLine(&b, "VERSION:3.0")
+	vCardLine(&b, "UID:"+vCardEscaper.Replace(card.Uid))
+	vCardLine(&b, "FN:"+vCardEscaper.Replace(card.FullName))
+	vCardLine(&b, "N:"+vCardEscaper.Replace(card.FullName)+";;;;")
+	for _, email := range card.Emails {
+		vCardLine(&b, "EMAIL;TYPE=INTERNET:"+vCardEscaper.Replace(email))
+	}
+	if card.Phone != "" {
+		vCardLine(&b, "TEL;TYPE=VOICE:"+vCardEscaper.Replace(card.Phone))
+	}
+	if card.Note != "" {
+		vCardLine(&b, "NOTE:"+vCardEscaper.Replace(card.Note))
+	}
+	if len(card.Categories) != 0 {
+		categories := make([]string, len(card.Categories))
+		for i, category := range card.Categories {
+			categories[i] = vCardEscaper.Replace(category)
+		}
+		vCardLine(&b, "CATEGORIES:"+strings.Join(categories, ","))
+	}
+	vCardLine(&b, "END:VCARD")
+	return b.String()
+}
+
+// splitVCardValue splits the value by unescaped separator and unescapes the parts
+func splitVCardValue(value string, sep byte) []string {
+	parts := make([]string, 0)
+	var part strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && i+1 < len(value):
+			i++
+			if value[i] == 'n' || value[i] == 'N' {
+				part.WriteByte('\n')
+			} else {
+				part.WriteByte(value[i])
+			}
+		case c == sep:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(c)
+		}
+	}
+	return append(parts, part.String())
+}
+
+func unescapeVCardValue(value string) string {
+	return splitVCardValue(value, 0)[0]
+}
+
+func ParseVCard(data string) (VCard, error) {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	data = strings.ReplaceAll(data, "\n\t", "")
+
+	var card VCard
+	var structuredName string
+	begun, ended := false, false
+	for _, line := range strings.Split(data, "\n") {
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		params := strings.Split(line[:colon], ";")
+		name := strings.ToUpper(params[0])
+		// grouped properties, e.g. item1.EMAIL
+		if dot := strings.LastIndex(name, "."); dot >= 0 {
+			name = name[dot+1:]
+		}
+		value := line[colon+1:]
+
+		switch name {
+		case "BEGIN":
+			begun = strings.EqualFold(value, "VCARD")
+		case "END":
+			ended = begun && strings.EqualFold(value, "VCARD")
+		case "UID":
+			card.Uid = unescapeVCardValue(value)
+		case "FN":
+			card.FullName = unescapeVCardValue(value)
+		case "N":
+			// family;given;additional;prefix;suffix
+			parts := splitVCardValue(value, ';')
+			names := make([]string, 0)
+			for _, i := range []int{3, 1, 2, 0, 4} {
+				if i < len(parts) && strings.TrimSpace(parts[i]) != "" {
+					names = append(names, strings.TrimSpace(parts[i]))
+				}
+			}
+			structuredName = strings.Join(names, " ")
+		case "EMAIL":
+			card.Emails = append(card.Emails, unescapeVCardValue(value))
+		case "TEL":
+			if card.Phone == "" {
+				card.Phone = strings.TrimPrefix(unescapeVCardValue(value), "tel:")
+			}
+		case "NOTE":
+			card.Note = unescapeVCardValue(value)
+		case "CATEGORIES":
+			card.Categories = append(card.Categories, splitVCardValue(value, ',')...)
+		}
+	}
+	if !begun || !ended {
+		return VCard{}, errors.New("invalid vcard")
+	}
+	if card.FullName == "" {
+		card.FullName = structuredName
+	}
+	return card, nil
+}
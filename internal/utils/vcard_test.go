@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVCard(t *testing.T) {
+	card := VCard{
+		Uid:        "42",
+		FullName:   "Doe, John; Jr",
+		Emails:     []string{"john@ya.ru", "doe@liokor.ru"},
+		Phone:      "+7 999 123-45-67",
+		Note:       "first line\nsecond line " + strings.Repeat("long ", 20),
+		Categories: []string{"work", "friends"},
+	}
+	formatted := FormatVCard(card)
+	for _, line := range strings.Split(formatted, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Line is not folded: %s\n", line)
+		}
+	}
+
+	parsed, err := ParseVCard(formatted)
+	if err != nil {
+		t.Errorf("Didn't parse valid vcard: %v\n", err)
+	}
+	if !reflect.DeepEqual(card, parsed) {
+		t.Errorf("Parsed vcard differs: %v\n", parsed)
+	}
+
+	parsed, err = ParseVCard("BEGIN:VCARD\nVERSION:4.0\nN:Doe;John;;Dr.;\nitem1.EMAIL;type=INTERNET:john@ya.ru\nTEL;VALUE=uri:tel:+79991234567\nEND:VCARD\n")
+	if err != nil {
+		t.Errorf("Didn't parse valid vcard: %v\n", err)
+	}
+	if parsed.FullName != "Dr. John Doe" || parsed.Phone != "+79991234567" || len(parsed.Emails) != 1 {
+		t.Errorf("Parsed vcard differs: %v\n", parsed)
+	}
+
+	_, err = ParseVCard("FN:John\n")
+	if err == nil {
+		t.Errorf("Parsed invalid vcard\n")
+	}
+}
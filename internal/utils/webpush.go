@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// push service drops the message if it isn't delivered in time
+const WebPushTTL = 24 * 60 * 60
+
+// VAPID tokens may be valid for at most a day
+const vapidTokenExpiration = 12 * time.Hour
+
+const webPushRecordSize = 4096
+
+// push services accept 4096 bytes of body including header, delimiter and tag
+const webPushMaxPayload = webPushRecordSize - 86 - 1 - 16
+
+// endpoints are given by browsers of users, so they mustn't reach the server's own network
+var webPushClient = NewPublicHTTPClient(10 * time.Second)
+
+// VapidKeys identify the application server to push services (RFC 8292)
+type VapidKeys struct {
+	PrivateKey *ecdsa.PrivateKey
+	PublicKey  string // uncompressed point in base64url, applicationServerKey of the browser subscription
+}
+
+// decodeBase64 accepts both padded and raw url-safe base64 as browsers use either
+func decodeBase64(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func GenerateVapidKeys() (string, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	public := elliptic.Marshal(elliptic.P256(), key.X, key.Y)
+	private := make([]byte, 32)
+	key.D.FillBytes(private)
+	return base64.RawURLEncoding.EncodeToString(public), base64.RawURLEncoding.EncodeToString(private), nil
+}
+
+func ParseVapidKeys(public string, private string) (VapidKeys, error) {
+	privateBytes, err := decodeBase64(private)
+	if err != nil || len(privateBytes) != 32 {
+		return VapidKeys{}, errors.New("invalid VAPID private key")
+	}
+	curve := elliptic.P256()
+	key := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(privateBytes)}
+	key.Curve = curve
+	key.X, key.Y = curve.ScalarBaseMult(privateBytes)
+
+	publicBytes, err := decodeBase64(public)
+	if err != nil || !bytes.Equal(publicBytes, elliptic.Marshal(curve, key.X, key.Y)) {
+		return VapidKeys{}, errors.New("VAPID public key doesn't match the private one")
+	}
+	return VapidKeys{key, base64.RawURLEncoding.EncodeToString(publicBytes)}, nil
+}
+
+// VapidAuthorization returns Authorization header for the push service of the endpoint
+func VapidAuthorization(endpoint string, keys VapidKeys, subject string, now time.Time) (string, error) {
+	endpointUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header, _ := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": endpointUrl.Scheme + "://" + endpointUrl.Host,
+		"exp": now.Add(vapidTokenExpiration).Unix(),
+		"sub": subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, keys.PrivateKey, hash[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, keys.PublicKey), nil
+}
+
+func hkdfExpand(secret []byte, salt []byte, info []byte, length int) ([]byte, error) {
+	result := make([]byte, length)
+	_, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), result)
+	return result, err
+}
+
+// EncryptPush encrypts payload for the subscription keys with aes128gcm content coding (RFC 8291)
+func EncryptPush(payload []byte, p256dh string, auth string) ([]byte, error) {
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+	return encryptPush(payload, p256dh, auth, serverKey, salt)
+}
+
+func encryptPush(payload []byte, p256dh string, auth string, serverKey *ecdsa.PrivateKey, salt []byte) ([]byte, error) {
+	curve := elliptic.P256()
+	userPublic, err := decodeBase64(p256dh)
+	if err != nil {
+		return nil, errors.New("invalid subscription p256dh key")
+	}
+	userX, userY := elliptic.Unmarshal(curve, userPublic)
+	if userX == nil {
+		return nil, errors.New("invalid subscription p256dh key")
+	}
+	authSecret, err := decodeBase64(auth)
+	if err != nil || len(authSecret) == 0 {
+		return nil, errors.New("invalid subscription auth secret")
+	}
+	if len(payload) > webPushMaxPayload {
+		return nil, errors.New("push payload is too large")
+	}
+
+	serverPublic := elliptic.Marshal(curve, serverKey.X, serverKey.Y)
+	sharedX, _ := curve.ScalarMult(userX, userY, serverKey.D.Bytes())
+	sharedSecret := make([]byte, 32)
+	sharedX.FillBytes(sharedSecret)
+
+	keyInfo := append([]byte("WebPush: info\x00"), userPublic...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm, err := hkdfExpand(sharedSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	contentKey, err := hkdfExpand(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdfExpand(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// single record ends with 0x02 delimiter without further padding
+	plaintext := append(append([]byte{}, payload...), 2)
+	record := gcm.Seal(nil, nonce, plaintext, nil)
+
+	var body bytes.Buffer
+	body.Write(salt)
+	binary.Write(&body, binary.BigEndian, uint32(webPushRecordSize))
+	body.WriteByte(byte(len(serverPublic)))
+	body.Write(serverPublic)
+	body.Write(record)
+	return body.Bytes(), nil
+}
+
+// SendWebPush delivers encrypted payload to the subscription endpoint and returns status code of the push service
+func SendWebPush(endpoint string, p256dh string, auth string, payload []byte, keys VapidKeys, subject string) (int, error) {
+	body, err := EncryptPush(payload, p256dh, auth)
+	if err != nil {
+		return 0, err
+	}
+	authorization, err := VapidAuthorization(endpoint, keys, subject, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Authorization", authorization)
+	request.Header.Set("Content-Encoding", "aes128gcm")
+	request.Header.Set("Content-Type", "application/octet-stream")
+	request.Header.Set("TTL", fmt.Sprint(WebPushTTL))
+	response, err := webPushClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, errors.New("push service responded " + response.Status)
+	}
+	return response.StatusCode, nil
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decryptPush does what the browser does with the received message
+func decryptPush(t *testing.T, body []byte, userKey *ecdsa.PrivateKey, authSecret []byte) []byte {
+	curve := elliptic.P256()
+	salt := body[:16]
+	if binary.BigEndian.Uint32(body[16:20]) != webPushRecordSize {
+		t.Fatalf("Wrong record size\n")
+	}
+	keyLength := int(body[20])
+	serverPublic := body[21 : 21+keyLength]
+	record := body[21+keyLength:]
+
+	serverX, serverY := elliptic.Unmarshal(curve, serverPublic)
+	sharedX, _ := curve.ScalarMult(serverX, serverY, userKey.D.Bytes())
+	sharedSecret := make([]byte, 32)
+	sharedX.FillBytes(sharedSecret)
+
+	userPublic := elliptic.Marshal(curve, userKey.X, userKey.Y)
+	keyInfo := append([]byte("WebPush: info\x00"), userPublic...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm, _ := hkdfExpand(sharedSecret, authSecret, keyInfo, 32)
+	contentKey, _ := hkdfExpand(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce, _ := hkdfExpand(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, _ := aes.NewCipher(contentKey)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, record, nil)
+	if err != nil {
+		t.Fatalf("Unable to decrypt: %v\n", err)
+	}
+	if plaintext[len(plaintext)-1] != 2 {
+		t.Fatalf("Wrong padding delimiter\n")
+	}
+	return plaintext[:len(plaintext)-1]
+}
+
+func TestEncryptPush(t *testing.T) {
+	userKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	authSecret := make([]byte, 16)
+	rand.Read(authSecret)
+	p256dh := base64.RawURLEncoding.EncodeToString(elliptic.Marshal(elliptic.P256(), userKey.X, userKey.Y))
+	// browsers may send padded keys
+	auth := base64.URLEncoding.EncodeToString(authSecret)
+
+	payload := []byte(`{"title":"friend@ya.ru","body":"Hello"}`)
+	body, err := EncryptPush(payload, p256dh, auth)
+	if err != nil {
+		t.Fatalf("Didn't encrypt valid data: %v\n", err)
+	}
+	decrypted := decryptPush(t, body, userKey, authSecret)
+	if string(decrypted) != string(payload) {
+		t.Errorf("Wrong decrypted payload: %s\n", decrypted)
+	}
+
+	_, err = EncryptPush(payload, "invalid", auth)
+	if err == nil {
+		t.Errorf("Didn't fail on invalid key\n")
+	}
+	_, err = EncryptPush(make([]byte, webPushMaxPayload+1), p256dh, auth)
+	if err == nil {
+		t.Errorf("Didn't fail on too large payload\n")
+	}
+}
+
+// RFC 8291 Appendix A
+func TestEncryptPushVector(t *testing.T) {
+	private, _ := decodeBase64("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw")
+	serverKey := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(private)}
+	serverKey.Curve = elliptic.P256()
+	serverKey.X, serverKey.Y = serverKey.Curve.ScalarBaseMult(private)
+	salt, _ := decodeBase64("DGv6ra1nlYgDCS1FRnbzlw")
+
+	body, err := encryptPush(
+		[]byte("When I grow up, I want to be a watermelon"),
+		"BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4",
+		"BTBZMqHH6r4Tts7J_aSIgg",
+		serverKey,
+		salt,
+	)
+	expected := "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+	if err != nil || base64.RawURLEncoding.EncodeToString(body) != expected {
+		t.Errorf("Wrong encrypted message: %v\n", err)
+	}
+}
+
+func TestVapidAuthorization(t *testing.T) {
+	public, private, err := GenerateVapidKeys()
+	if err != nil {
+		t.Fatalf("Unable to generate keys: %v\n", err)
+	}
+	keys, err := ParseVapidKeys(public, private)
+	if err != nil {
+		t.Fatalf("Didn't parse generated keys: %v\n", err)
+	}
+	otherPublic, _, _ := GenerateVapidKeys()
+	_, err = ParseVapidKeys(otherPublic, private)
+	if err == nil {
+		t.Errorf("Didn't fail on mismatched keys\n")
+	}
+
+	now := time.Now()
+	authorization, err := VapidAuthorization("https://fcm.googleapis.com/fcm/send/abc", keys, "mailto:admin@liokor.ru", now)
+	if err != nil {
+		t.Fatalf("Didn't authorize: %v\n", err)
+	}
+	if !strings.HasPrefix(authorization, "vapid t=") || !strings.HasSuffix(authorization, ", k="+public) {
+		t.Fatalf("Wrong authorization: %s\n", authorization)
+	}
+
+	token := strings.Split(strings.TrimSuffix(strings.TrimPrefix(authorization, "vapid t="), ", k="+public), ".")
+	claimsJson, _ := base64.RawURLEncoding.DecodeString(token[1])
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	json.Unmarshal(claimsJson, &claims)
+	if claims.Aud != "https://fcm.googleapis.com" || claims.Sub != "mailto:admin@liokor.ru" || claims.Exp <= now.Unix() {
+		t.Errorf("Wrong claims: %s\n", claimsJson)
+	}
+
+	signature, _ := base64.RawURLEncoding.DecodeString(token[2])
+	hash := sha256.Sum256([]byte(token[0] + "." + token[1]))
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(&keys.PrivateKey.PublicKey, hash[:], r, s) {
+		t.Errorf("Wrong signature\n")
+	}
+}
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS vacations (
+    owner CITEXT PRIMARY KEY REFERENCES users (username) ON DELETE CASCADE,
+    enabled BOOLEAN DEFAULT FALSE,
+    start_date TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    end_date TIMESTAMP WITH TIME ZONE,
+    subject TEXT,
+    body TEXT,
+    only_contacts BOOLEAN DEFAULT FALSE,
+    reply_interval INT DEFAULT 7
+);
+
+CREATE TABLE IF NOT EXISTS vacation_replies (
+    owner CITEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE,
+    sender CITEXT NOT NULL,
+    replied_date TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (owner, sender)
+);
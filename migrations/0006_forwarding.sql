@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS forwardings (
+    owner CITEXT PRIMARY KEY REFERENCES users (username) ON DELETE CASCADE,
+    enabled BOOLEAN DEFAULT FALSE,
+    address CITEXT NOT NULL,
+    verified BOOLEAN DEFAULT FALSE,
+    token CITEXT UNIQUE,
+    keep_copy BOOLEAN DEFAULT TRUE,
+    filter_sender TEXT DEFAULT '',
+    filter_subject TEXT DEFAULT ''
+);
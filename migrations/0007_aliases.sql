@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS aliases (
+    id BIGSERIAL PRIMARY KEY,
+    owner CITEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE,
+    alias CITEXT UNIQUE NOT NULL
+);
+
+-- usernames and aliases share the same namespace of local parts
+CREATE OR REPLACE FUNCTION check_username_is_not_alias() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM aliases WHERE alias = NEW.username) THEN
+        RAISE EXCEPTION 'username is taken by alias' USING ERRCODE = 'unique_violation', CONSTRAINT = 'users_username_key';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION check_alias_is_not_username() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users WHERE username = NEW.alias) THEN
+        RAISE EXCEPTION 'alias is taken by user' USING ERRCODE = 'unique_violation', CONSTRAINT = 'aliases_alias_key';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_username_is_not_alias ON users;
+CREATE TRIGGER users_username_is_not_alias BEFORE INSERT OR UPDATE OF username ON users
+    FOR EACH ROW EXECUTE PROCEDURE check_username_is_not_alias();
+
+DROP TRIGGER IF EXISTS aliases_alias_is_not_username ON aliases;
+CREATE TRIGGER aliases_alias_is_not_username BEFORE INSERT OR UPDATE OF alias ON aliases
+    FOR EACH ROW EXECUTE PROCEDURE check_alias_is_not_username();
+
+ALTER TABLE mails ADD sender_alias CITEXT DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS mail_labels (
+    mail_id BIGINT NOT NULL REFERENCES mails (id) ON DELETE CASCADE,
+    owner CITEXT NOT NULL,
+    label CITEXT NOT NULL,
+    UNIQUE (mail_id, owner, label)
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS domains (
+    id SERIAL PRIMARY KEY,
+    name CITEXT UNIQUE NOT NULL,
+    dkim_selector VARCHAR(63) NOT NULL DEFAULT 'wolf',
+    -- PEM encoded PKCS1 key, installation key from config is used for the default domain if empty
+    dkim_private_key TEXT DEFAULT NULL,
+    -- user of the domain receiving mail to unknown addresses
+    catch_all CITEXT DEFAULT NULL REFERENCES users (username) ON DELETE SET NULL
+);
+
+-- local parts stay unique across all domains, so users are still identified by username.
+-- The default domain is created from config on server start, existing users are moved to it then
+ALTER TABLE users ADD domain CITEXT REFERENCES domains (name) ON UPDATE CASCADE;
@@ -0,0 +1,60 @@
+-- shared mailbox is a user without credentials, its mail is managed by the members
+ALTER TABLE users ADD is_shared BOOLEAN DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS mailbox_members (
+    mailbox CITEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+    member CITEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+    is_admin BOOLEAN DEFAULT FALSE,
+    UNIQUE (mailbox, member)
+);
+
+CREATE TABLE IF NOT EXISTS distribution_lists (
+    id BIGSERIAL PRIMARY KEY,
+    name CITEXT UNIQUE NOT NULL,
+    domain CITEXT NOT NULL REFERENCES domains (name) ON UPDATE CASCADE,
+    owner CITEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS distribution_list_members (
+    list_id BIGINT NOT NULL REFERENCES distribution_lists (id) ON DELETE CASCADE,
+    address CITEXT NOT NULL,
+    UNIQUE (list_id, address)
+);
+
+-- copy of the mail delivered to a list member
+ALTER TABLE mails ADD list_address CITEXT DEFAULT NULL;
+
+-- usernames, aliases and lists share the same namespace of local parts
+CREATE OR REPLACE FUNCTION check_username_is_not_alias() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM aliases WHERE alias = NEW.username)
+        OR EXISTS (SELECT 1 FROM distribution_lists WHERE name = NEW.username) THEN
+        RAISE EXCEPTION 'username is taken by alias' USING ERRCODE = 'unique_violation', CONSTRAINT = 'users_username_key';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION check_alias_is_not_username() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users WHERE username = NEW.alias)
+        OR EXISTS (SELECT 1 FROM distribution_lists WHERE name = NEW.alias) THEN
+        RAISE EXCEPTION 'alias is taken by user' USING ERRCODE = 'unique_violation', CONSTRAINT = 'aliases_alias_key';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION check_list_is_not_username() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users WHERE username = NEW.name)
+        OR EXISTS (SELECT 1 FROM aliases WHERE alias = NEW.name) THEN
+        RAISE EXCEPTION 'list name is taken' USING ERRCODE = 'unique_violation', CONSTRAINT = 'distribution_lists_name_key';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS distribution_lists_name_is_not_username ON distribution_lists;
+CREATE TRIGGER distribution_lists_name_is_not_username BEFORE INSERT OR UPDATE OF name ON distribution_lists
+    FOR EACH ROW EXECUTE PROCEDURE check_list_is_not_username();
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS contacts (
+    id BIGSERIAL PRIMARY KEY,
+    owner CITEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+    -- vCard UID, resource name for CardDAV clients
+    uid VARCHAR(255) NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    phone TEXT NOT NULL DEFAULT '',
+    notes TEXT NOT NULL DEFAULT '',
+    -- contact was added automatically when the owner has sent mail to it
+    auto_collected BOOLEAN DEFAULT FALSE,
+    updated TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (owner, uid)
+);
+
+CREATE TABLE IF NOT EXISTS contact_emails (
+    contact_id BIGINT NOT NULL REFERENCES contacts (id) ON DELETE CASCADE,
+    email CITEXT NOT NULL,
+    UNIQUE (contact_id, email)
+);
+
+CREATE INDEX IF NOT EXISTS contact_emails_email_idx ON contact_emails (email);
+
+CREATE TABLE IF NOT EXISTS contact_groups (
+    contact_id BIGINT NOT NULL REFERENCES contacts (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (contact_id, name)
+);
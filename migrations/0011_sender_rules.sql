@@ -0,0 +1,12 @@
+-- pattern is exact address or domain (matches subdomains too), rules without owner are global blocklist of admins
+CREATE TABLE IF NOT EXISTS sender_rules (
+    id BIGSERIAL PRIMARY KEY,
+    owner CITEXT DEFAULT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+    pattern CITEXT NOT NULL,
+    allow BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS sender_rules_owner_pattern_idx ON sender_rules (COALESCE(owner, ''), pattern);
+
+-- blocked mail is accepted and silently dropped instead of being rejected
+ALTER TABLE users ADD drop_blocked BOOLEAN DEFAULT FALSE;
@@ -0,0 +1,4 @@
+-- Message-ID of the received mail, mail sent from the installation uses <id@domain>
+ALTER TABLE mails ADD message_id TEXT DEFAULT NULL;
+-- References header, ids of the previous mails in the thread
+ALTER TABLE mails ADD message_references TEXT DEFAULT NULL;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS signatures (
+    id BIGSERIAL PRIMARY KEY,
+    owner CITEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+    name TEXT NOT NULL,
+    body TEXT NOT NULL
+);
+
+-- alias is local part of the own address the signature is appended to by default, username for the main address
+CREATE TABLE IF NOT EXISTS signature_defaults (
+    owner CITEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+    alias CITEXT NOT NULL,
+    signature_id BIGINT NOT NULL REFERENCES signatures (id) ON DELETE CASCADE,
+    PRIMARY KEY (owner, alias)
+);
+
+-- canned responses, body is Markdown with placeholders like {{recipient.name}}
+CREATE TABLE IF NOT EXISTS templates (
+    id BIGSERIAL PRIMARY KEY,
+    owner CITEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+    name TEXT NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL
+);
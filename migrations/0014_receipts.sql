@@ -0,0 +1,7 @@
+-- sender asked for read receipt, notify_address is Disposition-Notification-To of the received external mail
+ALTER TABLE mails ADD receipt_requested BOOLEAN DEFAULT FALSE;
+ALTER TABLE mails ADD notify_address TEXT DEFAULT NULL;
+ALTER TABLE mails ADD read_date TIMESTAMPTZ DEFAULT NULL;
+
+-- read receipts are sent only by users who opted in
+ALTER TABLE users ADD read_receipts BOOLEAN DEFAULT FALSE;
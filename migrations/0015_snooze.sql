@@ -0,0 +1,3 @@
+-- snoozed dialogue is hidden from its folder until the time and then comes back as unread
+ALTER TABLE dialogues ADD snoozed_until TIMESTAMPTZ DEFAULT NULL;
+CREATE INDEX IF NOT EXISTS dialogues_snoozed_until_idx ON dialogues (snoozed_until) WHERE snoozed_until IS NOT NULL;
@@ -0,0 +1,3 @@
+-- pinned dialogues are shown above the others, muted ones don't count new mails as unread
+ALTER TABLE dialogues ADD pinned BOOLEAN DEFAULT FALSE;
+ALTER TABLE dialogues ADD muted BOOLEAN DEFAULT FALSE;
@@ -0,0 +1,8 @@
+-- folders form a tree, subfolders are deleted with the parent
+ALTER TABLE folders ADD parent INTEGER REFERENCES folders (id) ON DELETE CASCADE DEFAULT NULL;
+-- user-defined order of the folders with the same parent
+ALTER TABLE folders ADD position INTEGER NOT NULL DEFAULT 0;
+
+-- names are unique among the siblings, the index keeps the name of the constraint it replaces
+ALTER TABLE folders DROP CONSTRAINT IF EXISTS folders_folder_name_owner_key;
+CREATE UNIQUE INDEX IF NOT EXISTS folders_folder_name_owner_key ON folders (owner, COALESCE(parent, 0), folder_name);
@@ -0,0 +1,16 @@
+-- set by smtp server for received mail with attached files
+ALTER TABLE mails ADD has_attachment BOOLEAN DEFAULT FALSE;
+
+-- virtual folders showing dialogues with mails matching all the set conditions, evaluated on every request
+CREATE TABLE IF NOT EXISTS smart_folders (
+    id BIGSERIAL PRIMARY KEY,
+    owner CITEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+    name TEXT NOT NULL,
+    sender_domain CITEXT NOT NULL DEFAULT '',
+    unread_only BOOLEAN NOT NULL DEFAULT FALSE,
+    received_after TIMESTAMPTZ DEFAULT NULL,
+    received_before TIMESTAMPTZ DEFAULT NULL,
+    label CITEXT NOT NULL DEFAULT '',
+    has_attachment BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE (owner, name)
+);
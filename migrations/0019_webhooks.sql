@@ -0,0 +1,21 @@
+-- user subscriptions to mail events, payloads are signed with HMAC-SHA256 keyed by the secret
+CREATE TABLE IF NOT EXISTS webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    owner CITEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+    url TEXT NOT NULL,
+    events TEXT NOT NULL, -- comma separated event names
+    secret TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhooks_owner_idx ON webhooks (owner);
+
+-- every delivery attempt, shown to the owner as delivery log
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook BIGINT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    attempt INT NOT NULL DEFAULT 1,
+    status_code INT NOT NULL DEFAULT 0, -- 0 if no response was received
+    error TEXT NOT NULL DEFAULT '',
+    delivery_date TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries (webhook, delivery_date DESC);
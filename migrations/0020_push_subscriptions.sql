@@ -0,0 +1,10 @@
+-- browser push subscriptions, one per session and removed on logout
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    owner CITEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+    session CITEXT NOT NULL UNIQUE REFERENCES sessions (token) ON DELETE CASCADE,
+    endpoint TEXT NOT NULL,
+    p256dh TEXT NOT NULL,
+    auth TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS push_subscriptions_owner_idx ON push_subscriptions (owner);
@@ -0,0 +1,92 @@
+-- every change of mails, dialogues and folders takes the next number of the sequence,
+-- sync clients ask for changes with modseq greater than the last one they have seen
+CREATE SEQUENCE IF NOT EXISTS modseq;
+
+ALTER TABLE mails ADD COLUMN IF NOT EXISTS modseq BIGINT;
+ALTER TABLE mails ADD COLUMN IF NOT EXISTS created_modseq BIGINT;
+ALTER TABLE dialogues ADD COLUMN IF NOT EXISTS modseq BIGINT;
+ALTER TABLE dialogues ADD COLUMN IF NOT EXISTS created_modseq BIGINT;
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS modseq BIGINT;
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS created_modseq BIGINT;
+
+UPDATE mails SET modseq = nextval('modseq') WHERE modseq IS NULL;
+UPDATE mails SET created_modseq = modseq WHERE created_modseq IS NULL;
+UPDATE dialogues SET modseq = nextval('modseq') WHERE modseq IS NULL;
+UPDATE dialogues SET created_modseq = modseq WHERE created_modseq IS NULL;
+UPDATE folders SET modseq = nextval('modseq') WHERE modseq IS NULL;
+UPDATE folders SET created_modseq = modseq WHERE created_modseq IS NULL;
+
+ALTER TABLE mails ALTER COLUMN modseq SET NOT NULL;
+ALTER TABLE mails ALTER COLUMN created_modseq SET NOT NULL;
+ALTER TABLE dialogues ALTER COLUMN modseq SET NOT NULL;
+ALTER TABLE dialogues ALTER COLUMN created_modseq SET NOT NULL;
+ALTER TABLE folders ALTER COLUMN modseq SET NOT NULL;
+ALTER TABLE folders ALTER COLUMN created_modseq SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS mails_sender_modseq_idx ON mails (sender, modseq);
+CREATE INDEX IF NOT EXISTS mails_recipient_modseq_idx ON mails (recipient, modseq);
+CREATE INDEX IF NOT EXISTS dialogues_owner_modseq_idx ON dialogues (owner, modseq);
+CREATE INDEX IF NOT EXISTS folders_owner_modseq_idx ON folders (owner, modseq);
+
+CREATE OR REPLACE FUNCTION set_modseq() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.modseq := nextval('modseq');
+    IF TG_OP = 'INSERT' THEN
+        NEW.created_modseq := NEW.modseq;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- updates not changing the row, like reading an already read dialogue, keep its modseq
+DROP TRIGGER IF EXISTS mails_insert_modseq ON mails;
+CREATE TRIGGER mails_insert_modseq BEFORE INSERT ON mails
+    FOR EACH ROW EXECUTE PROCEDURE set_modseq();
+DROP TRIGGER IF EXISTS mails_update_modseq ON mails;
+CREATE TRIGGER mails_update_modseq BEFORE UPDATE ON mails
+    FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE PROCEDURE set_modseq();
+DROP TRIGGER IF EXISTS dialogues_insert_modseq ON dialogues;
+CREATE TRIGGER dialogues_insert_modseq BEFORE INSERT ON dialogues
+    FOR EACH ROW EXECUTE PROCEDURE set_modseq();
+DROP TRIGGER IF EXISTS dialogues_update_modseq ON dialogues;
+CREATE TRIGGER dialogues_update_modseq BEFORE UPDATE ON dialogues
+    FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE PROCEDURE set_modseq();
+DROP TRIGGER IF EXISTS folders_insert_modseq ON folders;
+CREATE TRIGGER folders_insert_modseq BEFORE INSERT ON folders
+    FOR EACH ROW EXECUTE PROCEDURE set_modseq();
+DROP TRIGGER IF EXISTS folders_update_modseq ON folders;
+CREATE TRIGGER folders_update_modseq BEFORE UPDATE ON folders
+    FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE PROCEDURE set_modseq();
+
+-- deleted dialogues and folders leave tombstones, mails are never deleted but flagged by each side
+CREATE TABLE IF NOT EXISTS sync_tombstones (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    owner CITEXT,        -- owner of the dialogue
+    owner_id INTEGER,    -- owner of the folder
+    object_id INTEGER NOT NULL,
+    modseq BIGINT NOT NULL DEFAULT nextval('modseq')
+);
+CREATE INDEX IF NOT EXISTS sync_tombstones_owner_idx ON sync_tombstones (owner, modseq);
+CREATE INDEX IF NOT EXISTS sync_tombstones_owner_id_idx ON sync_tombstones (owner_id, modseq);
+
+CREATE OR REPLACE FUNCTION add_dialogue_tombstone() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO sync_tombstones (kind, owner, object_id) VALUES ('dialogue', OLD.owner, OLD.id);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION add_folder_tombstone() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO sync_tombstones (kind, owner_id, object_id) VALUES ('folder', OLD.owner, OLD.id);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS dialogues_tombstone ON dialogues;
+CREATE TRIGGER dialogues_tombstone AFTER DELETE ON dialogues
+    FOR EACH ROW EXECUTE PROCEDURE add_dialogue_tombstone();
+DROP TRIGGER IF EXISTS folders_tombstone ON folders;
+CREATE TRIGGER folders_tombstone AFTER DELETE ON folders
+    FOR EACH ROW EXECUTE PROCEDURE add_folder_tombstone();
@@ -0,0 +1,16 @@
+-- mailbox export jobs, the archive is written to the export storage and downloaded by the token until expiration
+CREATE TABLE IF NOT EXISTS exports (
+    id BIGSERIAL PRIMARY KEY,
+    owner CITEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+    format TEXT NOT NULL,                -- mbox or eml
+    dialogues TEXT NOT NULL DEFAULT '',  -- comma separated ids of exported dialogues
+    folders TEXT NOT NULL DEFAULT '',    -- comma separated ids of exported folders, everything is exported without both
+    status TEXT NOT NULL DEFAULT 'pending',
+    total INT NOT NULL DEFAULT 0,
+    processed INT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    token TEXT NOT NULL UNIQUE,
+    created_date TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_date TIMESTAMPTZ             -- set when the archive is ready
+);
+CREATE INDEX IF NOT EXISTS exports_owner_idx ON exports (owner, created_date DESC);
@@ -0,0 +1,21 @@
+-- mailbox import jobs, uploaded archives are kept in the import storage until the import is done
+CREATE TABLE IF NOT EXISTS imports (
+    id BIGSERIAL PRIMARY KEY,
+    owner CITEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+    source TEXT NOT NULL,                 -- mbox, eml or imap
+    imap_host TEXT NOT NULL DEFAULT '',
+    imap_username TEXT NOT NULL DEFAULT '', -- the password isn't stored, it's asked again to resume
+    status TEXT NOT NULL DEFAULT 'pending',
+    processed INT NOT NULL DEFAULT 0,
+    imported INT NOT NULL DEFAULT 0,
+    duplicates INT NOT NULL DEFAULT 0,    -- skipped by Message-ID
+    failed INT NOT NULL DEFAULT 0,        -- messages which couldn't be parsed or have no recipients
+    progress TEXT NOT NULL DEFAULT '{}',  -- JSON of the last imported position by source folder
+    error TEXT NOT NULL DEFAULT '',
+    token TEXT NOT NULL UNIQUE,
+    created_date TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS imports_owner_idx ON imports (owner, created_date DESC);
+
+-- imported messages are deduplicated by Message-ID
+CREATE INDEX IF NOT EXISTS mails_message_id_idx ON mails (message_id);
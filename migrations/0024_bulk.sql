@@ -0,0 +1,6 @@
+-- starred mails are flagged by each side like deleted ones
+ALTER TABLE mails ADD COLUMN IF NOT EXISTS starred_by_sender BOOLEAN DEFAULT FALSE;
+ALTER TABLE mails ADD COLUMN IF NOT EXISTS starred_by_recipient BOOLEAN DEFAULT FALSE;
+-- archived dialogue is hidden from its folder until a new mail is received
+ALTER TABLE dialogues ADD COLUMN IF NOT EXISTS archived BOOLEAN DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS dialogues_archived_idx ON dialogues (owner, received_date DESC) WHERE archived;
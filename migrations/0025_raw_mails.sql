@@ -0,0 +1,3 @@
+-- original message of mails with attachments, as the body is the only part stored otherwise.
+-- Forwards keep the forwarded message here, so attachments are carried on by the next forward
+ALTER TABLE mails ADD COLUMN IF NOT EXISTS raw BYTEA DEFAULT NULL;
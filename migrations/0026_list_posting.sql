@@ -0,0 +1,2 @@
+-- who may post to the list: members (with the owner), local senders or anyone
+ALTER TABLE distribution_lists ADD COLUMN IF NOT EXISTS posting TEXT NOT NULL DEFAULT 'members';
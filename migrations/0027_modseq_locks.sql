@@ -0,0 +1,25 @@
+-- modseq is taken when the row is written, but transactions commit in any order, so sync could return
+-- a change and pass over an older one committed later. Every transaction holds shared advisory lock
+-- on the last taken modseq before it takes its first one, sync doesn't return changes after the lowest
+-- locked modseq, they are returned by the next sync
+CREATE OR REPLACE FUNCTION next_modseq() RETURNS BIGINT AS $$
+BEGIN
+    IF current_setting('liokor.modseq_locked', true) IS DISTINCT FROM 'on' THEN
+        PERFORM pg_advisory_xact_lock_shared((SELECT last_value FROM modseq));
+        PERFORM set_config('liokor.modseq_locked', 'on', true);
+    END IF;
+    RETURN nextval('modseq');
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION set_modseq() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.modseq := next_modseq();
+    IF TG_OP = 'INSERT' THEN
+        NEW.created_modseq := NEW.modseq;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE sync_tombstones ALTER COLUMN modseq SET DEFAULT next_modseq();
@@ -0,0 +1,9 @@
+-- failed webhook deliveries waiting for the next attempt, they survive restarts of the server
+CREATE TABLE IF NOT EXISTS webhook_retries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook BIGINT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    payload TEXT NOT NULL,
+    attempt INT NOT NULL, -- number of the next attempt
+    next_attempt TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhook_retries_next_attempt_idx ON webhook_retries (next_attempt);
@@ -0,0 +1,5 @@
+-- a user has one unfinished export at a time, older unfinished ones are failed before the index is built
+UPDATE exports SET status='failed', error='export was interrupted'
+WHERE status IN ('pending', 'running')
+AND id NOT IN (SELECT MAX(id) FROM exports WHERE status IN ('pending', 'running') GROUP BY owner);
+CREATE UNIQUE INDEX IF NOT EXISTS exports_unfinished_owner_key ON exports (owner) WHERE status IN ('pending', 'running');